@@ -0,0 +1,558 @@
+package pubsub
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	"github.com/libp2p/go-msgio/protoio"
+)
+
+const (
+	defaultAuditQueueSize    = 1024
+	defaultAuditSyncInterval = time.Second
+	defaultAuditMaxFileSize  = 64 << 20
+
+	auditFilePrefix = "audit-"
+	auditFileSuffix = ".log"
+
+	// records are small; anything bigger than this is corruption
+	maxAuditRecordSize = 1 << 20
+)
+
+// AuditLog is a durable, append-only log of the messages delivered on a set of topics, meant as a
+// compliance record of what the node accepted. Each delivered message is written as a
+// length-prefixed pb.AuditRecord, carrying the message ID, topic, author, receive time and a hash
+// of the data; every record also carries a chain hash over the previous record, so that removing,
+// reordering or altering records breaks the chain, which is checked by VerifyAuditLog.
+//
+// Records are written by a background goroutine from a bounded queue, so that the event loop never
+// waits for the disk. When the queue is full, the record is dropped by default and the number of
+// dropped records is noted in the next record that makes it to the log; use WithAuditLogBlocking
+// to apply backpressure to the event loop instead.
+type AuditLog struct {
+	ch    chan auditEntry
+	block bool
+
+	mx     sync.RWMutex
+	closed bool
+	done   chan struct{}
+
+	// records dropped since the last written record, and in total
+	pending uint64
+	dropped uint64
+
+	syncInterval time.Duration
+
+	// the output; in directory mode the file is rotated when it exceeds maxFileSize
+	w           io.Writer
+	dir         string
+	file        *os.File
+	fileIdx     int
+	fileSize    int64
+	maxFileSize int64
+
+	// the chain hash of the last written record
+	prev []byte
+	err  error
+
+	// the logger of the PubSub instance feeding the log, taken from the last record; only accessed
+	// by the writing goroutine
+	logger Logger
+}
+
+type auditEntry struct {
+	msg    *Message
+	recv   time.Time
+	logger Logger
+}
+
+// AuditLogOpt is an option for an AuditLog.
+type AuditLogOpt func(*AuditLog) error
+
+// WithAuditLogQueueSize sets the number of delivered messages that can be queued for writing.
+// The default is 1024.
+func WithAuditLogQueueSize(size int) AuditLogOpt {
+	return func(a *AuditLog) error {
+		if size <= 0 {
+			return fmt.Errorf("audit log queue size must be positive")
+		}
+		a.ch = make(chan auditEntry, size)
+		return nil
+	}
+}
+
+// WithAuditLogBlocking makes the delivery of messages wait for space in the queue when it is full,
+// instead of dropping their records. Note that this stalls the whole event loop while the log
+// is catching up with the disk.
+func WithAuditLogBlocking() AuditLogOpt {
+	return func(a *AuditLog) error {
+		a.block = true
+		return nil
+	}
+}
+
+// WithAuditLogSyncInterval sets how often the log is synced to stable storage. The default is 1s.
+func WithAuditLogSyncInterval(interval time.Duration) AuditLogOpt {
+	return func(a *AuditLog) error {
+		if interval <= 0 {
+			return fmt.Errorf("audit log sync interval must be positive")
+		}
+		a.syncInterval = interval
+		return nil
+	}
+}
+
+// WithAuditLogMaxFileSize sets the size at which the files of an audit log opened with
+// OpenAuditLog are rotated. The default is 64MiB.
+func WithAuditLogMaxFileSize(size int64) AuditLogOpt {
+	return func(a *AuditLog) error {
+		if size <= 0 {
+			return fmt.Errorf("audit log file size must be positive")
+		}
+		a.maxFileSize = size
+		return nil
+	}
+}
+
+// NewAuditLog creates an audit log writing to w. If w has a Sync method, such as an *os.File, it
+// is called periodically. The writer is not closed by the audit log.
+func NewAuditLog(w io.Writer, opts ...AuditLogOpt) (*AuditLog, error) {
+	a, err := newAuditLog(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	a.w = w
+	go a.run()
+
+	return a, nil
+}
+
+// OpenAuditLog creates an audit log writing to a series of files in dir, which is created if it
+// doesn't exist. A new file is started every time the log is opened and whenever the current file
+// exceeds the maximum size; the chain continues from the last record of the existing files.
+func OpenAuditLog(dir string, opts ...AuditLogOpt) (*AuditLog, error) {
+	a, err := newAuditLog(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	files, err := auditLogFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) > 0 {
+		last := files[len(files)-1]
+		a.fileIdx = last.idx
+		var end int64
+		a.prev, end, err = lastAuditChainHash(last.path)
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// a crash can leave a partial record at the end of the last file; drop it so that the
+			// file verifies, and continue the chain from the last complete record.
+			a.logger.Warnw("truncating partial audit log record", "file", last.path, "offset", end)
+			err = os.Truncate(last.path, end)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading audit log file %s: %w", last.path, err)
+		}
+	}
+
+	a.dir = dir
+	if err := a.rotate(); err != nil {
+		return nil, err
+	}
+
+	go a.run()
+
+	return a, nil
+}
+
+func newAuditLog(opts ...AuditLogOpt) (*AuditLog, error) {
+	a := &AuditLog{
+		done:         make(chan struct{}),
+		syncInterval: defaultAuditSyncInterval,
+		maxFileSize:  defaultAuditMaxFileSize,
+		logger:       log,
+	}
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+
+	if a.ch == nil {
+		a.ch = make(chan auditEntry, defaultAuditQueueSize)
+	}
+
+	return a, nil
+}
+
+// WithAuditLog records the messages delivered on the given topics in an audit log.
+// The audit log should be closed after the PubSub instance has been shut down.
+func WithAuditLog(topics []string, al *AuditLog) Option {
+	return func(ps *PubSub) error {
+		if len(topics) == 0 {
+			return fmt.Errorf("no topics to audit")
+		}
+
+		tr := &auditTracer{p: ps, log: al, topics: make(map[string]struct{}, len(topics))}
+		for _, topic := range topics {
+			tr.topics[topic] = struct{}{}
+		}
+
+		return WithRawTracer(tr)(ps)
+	}
+}
+
+// Dropped returns the total number of records dropped because the queue was full.
+func (a *AuditLog) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+// Close writes out the queued records, syncs the log and closes it; it returns the first error
+// encountered while writing the log, if any.
+func (a *AuditLog) Close() error {
+	a.mx.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.ch)
+	}
+	a.mx.Unlock()
+
+	<-a.done
+	return a.err
+}
+
+// add queues a record of a message, logging through the logger of the PubSub instance it comes from.
+func (a *AuditLog) add(msg *Message, logger Logger) {
+	e := auditEntry{msg: msg, recv: time.Now(), logger: logger}
+
+	a.mx.RLock()
+	defer a.mx.RUnlock()
+
+	if a.closed {
+		return
+	}
+
+	if a.block {
+		a.ch <- e
+		return
+	}
+
+	select {
+	case a.ch <- e:
+	default:
+		logger.Debugw("audit log queue full; dropping record", "topic", msg.GetTopic(), "msgid", msg.ID)
+		atomic.AddUint64(&a.pending, 1)
+		atomic.AddUint64(&a.dropped, 1)
+	}
+}
+
+func (a *AuditLog) run() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e, ok := <-a.ch:
+			if !ok {
+				a.sync()
+				if a.file != nil {
+					a.setErr(a.file.Close())
+				}
+				return
+			}
+			a.logger = e.logger
+			a.write(e)
+
+		case <-ticker.C:
+			a.sync()
+		}
+	}
+}
+
+func (a *AuditLog) write(e auditEntry) {
+	dataHash := sha256.Sum256(e.msg.Data)
+	rec := &pb.AuditRecord{
+		MessageID: []byte(e.msg.ID),
+		Topic:     e.msg.Topic,
+		From:      e.msg.From,
+		Timestamp: int64Ptr(e.recv.UnixNano()),
+		DataHash:  dataHash[:],
+	}
+	if dropped := atomic.SwapUint64(&a.pending, 0); dropped > 0 {
+		rec.Dropped = &dropped
+	}
+
+	chainHash, err := auditChainHash(a.prev, rec)
+	if err != nil {
+		a.setErr(err)
+		return
+	}
+	rec.ChainHash = chainHash
+
+	data, err := rec.Marshal()
+	if err != nil {
+		a.setErr(err)
+		return
+	}
+
+	var prefix [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(prefix[:], uint64(len(data)))
+	size := int64(n + len(data))
+
+	if a.dir != "" && a.fileSize > 0 && a.fileSize+size > a.maxFileSize {
+		a.sync()
+		if err := a.file.Close(); err != nil {
+			a.setErr(err)
+		}
+		if err := a.rotate(); err != nil {
+			a.setErr(err)
+			return
+		}
+	}
+
+	if _, err := a.w.Write(append(prefix[:n], data...)); err != nil {
+		a.setErr(err)
+		return
+	}
+
+	a.fileSize += size
+	a.prev = chainHash
+}
+
+// rotate opens the next file of an audit log directory.
+func (a *AuditLog) rotate() error {
+	a.fileIdx++
+	f, err := os.OpenFile(auditFilePath(a.dir, a.fileIdx), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	a.file = f
+	a.w = f
+	a.fileSize = 0
+
+	return nil
+}
+
+func (a *AuditLog) sync() {
+	if s, ok := a.w.(interface{ Sync() error }); ok {
+		a.setErr(s.Sync())
+	}
+}
+
+func (a *AuditLog) setErr(err error) {
+	if err == nil {
+		return
+	}
+
+	a.logger.Errorw("error writing audit log", "err", err)
+	if a.err == nil {
+		a.err = err
+	}
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+// auditChainHash computes the chain hash of a record, which is the hash of the previous chain hash
+// followed by the serialization of the record without its chain hash.
+func auditChainHash(prev []byte, rec *pb.AuditRecord) ([]byte, error) {
+	unchained := *rec
+	unchained.ChainHash = nil
+
+	data, err := unchained.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	h.Write(prev)
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// ErrAuditChainBroken is returned when verifying an audit log whose chain of hashes doesn't add up.
+var ErrAuditChainBroken = errors.New("audit log chain is broken")
+
+// VerifyAuditLog reads an audit log from r and verifies its chain of hashes, starting from the
+// chain hash prev of the record preceding the log (nil for the start of a log). It returns the
+// number of records read and the chain hash of the last record, to continue the verification
+// with the next part of the log.
+func VerifyAuditLog(r io.Reader, prev []byte) (records int, last []byte, err error) {
+	err = readAuditLog(r, func(rec *pb.AuditRecord) error {
+		expected, err := auditChainHash(prev, rec)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(expected, rec.ChainHash) {
+			return fmt.Errorf("%w at record %d", ErrAuditChainBroken, records)
+		}
+
+		prev = rec.ChainHash
+		records++
+		return nil
+	})
+
+	return records, prev, err
+}
+
+// VerifyAuditLogDir verifies the chain of hashes across all the files of an audit log directory,
+// returning the number of records in the log.
+func VerifyAuditLogDir(dir string) (records int, err error) {
+	files, err := auditLogFiles(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var prev []byte
+	for _, file := range files {
+		f, err := os.Open(file.path)
+		if err != nil {
+			return records, err
+		}
+
+		var n int
+		n, prev, err = VerifyAuditLog(f, prev)
+		f.Close()
+		records += n
+		if err != nil {
+			return records, fmt.Errorf("%s: %w", file.path, err)
+		}
+	}
+
+	return records, nil
+}
+
+// readAuditLog calls fn for every record of an audit log, until the end of the log.
+func readAuditLog(r io.Reader, fn func(*pb.AuditRecord) error) error {
+	rd := protoio.NewDelimitedReader(r, maxAuditRecordSize)
+	for {
+		var rec pb.AuditRecord
+		err := rd.ReadMsg(&rec)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := fn(&rec); err != nil {
+			return err
+		}
+	}
+}
+
+// lastAuditChainHash returns the chain hash of the last complete record of an audit log file,
+// and the offset of the end of that record.
+func lastAuditChainHash(path string) (last []byte, end int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var prefix [binary.MaxVarintLen64]byte
+	err = readAuditLog(f, func(rec *pb.AuditRecord) error {
+		size := rec.Size()
+		end += int64(binary.PutUvarint(prefix[:], uint64(size)) + size)
+		last = rec.ChainHash
+		return nil
+	})
+
+	return last, end, err
+}
+
+type auditFile struct {
+	idx  int
+	path string
+}
+
+// auditLogFiles returns the files of an audit log directory, in order.
+func auditLogFiles(dir string) ([]auditFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []auditFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, auditFilePrefix) || !strings.HasSuffix(name, auditFileSuffix) {
+			continue
+		}
+
+		idx, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, auditFilePrefix), auditFileSuffix))
+		if err != nil {
+			continue
+		}
+		files = append(files, auditFile{idx: idx, path: filepath.Join(dir, name)})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].idx < files[j].idx
+	})
+
+	return files, nil
+}
+
+func auditFilePath(dir string, idx int) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%08d%s", auditFilePrefix, idx, auditFileSuffix))
+}
+
+// auditTracer feeds the messages delivered on the audited topics to an audit log.
+type auditTracer struct {
+	p      *PubSub
+	log    *AuditLog
+	topics map[string]struct{}
+}
+
+var _ RawTracer = (*auditTracer)(nil)
+
+func (t *auditTracer) DeliverMessage(msg *Message) {
+	if _, ok := t.topics[msg.GetTopic()]; ok {
+		t.log.add(msg, t.p.logger)
+	}
+}
+
+func (t *auditTracer) AddPeer(p peer.ID, proto protocol.ID)      {}
+func (t *auditTracer) RemovePeer(p peer.ID)                      {}
+func (t *auditTracer) Join(topic string)                         {}
+func (t *auditTracer) Leave(topic string)                        {}
+func (t *auditTracer) Graft(p peer.ID, topic string)             {}
+func (t *auditTracer) Prune(p peer.ID, topic string)             {}
+func (t *auditTracer) ValidateMessage(msg *Message)              {}
+func (t *auditTracer) RejectMessage(msg *Message, reason string) {}
+func (t *auditTracer) DuplicateMessage(msg *Message)             {}
+func (t *auditTracer) ThrottlePeer(p peer.ID)                    {}
+func (t *auditTracer) RecvRPC(rpc *RPC)                          {}
+func (t *auditTracer) SendRPC(rpc *RPC, p peer.ID)               {}
+func (t *auditTracer) DropRPC(rpc *RPC, p peer.ID)               {}
+func (t *auditTracer) UndeliverableMessage(msg *Message)         {}
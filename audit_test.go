@@ -0,0 +1,296 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-msgio/protoio"
+)
+
+func makeAuditMessage(i int) *Message {
+	topic := "foobar"
+	return &Message{
+		Message: &pb.Message{
+			Data:  []byte(fmt.Sprintf("message %d", i)),
+			Topic: &topic,
+			From:  []byte("author"),
+		},
+		ID: fmt.Sprintf("msgid-%d", i),
+	}
+}
+
+func TestAuditLogChain(t *testing.T) {
+	var buf bytes.Buffer
+	al, err := NewAuditLog(&buf, WithAuditLogBlocking())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		al.add(makeAuditMessage(i), log)
+	}
+	if err := al.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	n, last, err := VerifyAuditLog(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 {
+		t.Fatalf("expected 10 records, but got %d", n)
+	}
+	if len(last) == 0 {
+		t.Fatal("expected the chain hash of the last record")
+	}
+
+	// read the records back and tamper with the log
+	var recs []*pb.AuditRecord
+	rd := protoio.NewDelimitedReader(bytes.NewReader(buf.Bytes()), maxAuditRecordSize)
+	for i := 0; i < 10; i++ {
+		rec := new(pb.AuditRecord)
+		if err := rd.ReadMsg(rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec.GetTopic() != "foobar" || string(rec.GetMessageID()) != fmt.Sprintf("msgid-%d", i) {
+			t.Fatalf("unexpected record %d: %v", i, rec)
+		}
+		recs = append(recs, rec)
+	}
+
+	writeLog := func(recs []*pb.AuditRecord) []byte {
+		var buf bytes.Buffer
+		w := protoio.NewDelimitedWriter(&buf)
+		for _, rec := range recs {
+			if err := w.WriteMsg(rec); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return buf.Bytes()
+	}
+
+	// the records written back as they are verify
+	if _, _, err := VerifyAuditLog(bytes.NewReader(writeLog(recs)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// removing a record breaks the chain
+	removed := append(append([]*pb.AuditRecord{}, recs[:4]...), recs[5:]...)
+	if _, _, err := VerifyAuditLog(bytes.NewReader(writeLog(removed)), nil); !errors.Is(err, ErrAuditChainBroken) {
+		t.Fatalf("expected a broken chain after removing a record, but got %v", err)
+	}
+
+	// so does altering one
+	recs[7].Topic = &[]string{"barfoo"}[0]
+	if _, _, err := VerifyAuditLog(bytes.NewReader(writeLog(recs)), nil); !errors.Is(err, ErrAuditChainBroken) {
+		t.Fatalf("expected a broken chain after altering a record, but got %v", err)
+	}
+}
+
+func TestAuditLogRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	al, err := OpenAuditLog(dir, WithAuditLogBlocking(), WithAuditLogMaxFileSize(512))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		al.add(makeAuditMessage(i), log)
+	}
+	if err := al.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := auditLogFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) < 2 {
+		t.Fatalf("expected the log to be rotated, but got %d files", len(files))
+	}
+	for _, file := range files {
+		fi, err := os.Stat(file.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() > 512 {
+			t.Fatalf("file %s exceeds the maximum size: %d", file.path, fi.Size())
+		}
+	}
+
+	// reopening the log continues the chain in a new file
+	al, err = OpenAuditLog(dir, WithAuditLogBlocking(), WithAuditLogMaxFileSize(512))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 20; i < 25; i++ {
+		al.add(makeAuditMessage(i), log)
+	}
+	if err := al.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := VerifyAuditLogDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 25 {
+		t.Fatalf("expected 25 records, but got %d", n)
+	}
+
+	// verification spans the files, so dropping one breaks the chain
+	if err := os.Remove(filepath.Join(dir, filepath.Base(files[0].path))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyAuditLogDir(dir); !errors.Is(err, ErrAuditChainBroken) {
+		t.Fatalf("expected a broken chain after removing a file, but got %v", err)
+	}
+}
+
+func TestAuditLogPartialRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	al, err := OpenAuditLog(dir, WithAuditLogBlocking())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		al.add(makeAuditMessage(i), log)
+	}
+	if err := al.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a crash in the middle of writing the last record
+	files, err := auditLogFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := files[len(files)-1].path
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, fi.Size()-5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyAuditLogDir(dir); err == nil {
+		t.Fatal("expected the partial record to fail verification")
+	}
+
+	// reopening the log drops the partial record and continues the chain from the previous one
+	al, err = OpenAuditLog(dir, WithAuditLogBlocking())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 10; i < 15; i++ {
+		al.add(makeAuditMessage(i), log)
+	}
+	if err := al.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := VerifyAuditLogDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 14 {
+		t.Fatalf("expected 14 records, but got %d", n)
+	}
+}
+
+func TestAuditLogDrop(t *testing.T) {
+	al := &AuditLog{ch: make(chan auditEntry, 2), done: make(chan struct{}), syncInterval: time.Second}
+
+	// the writer is not running yet, so the queue fills up
+	for i := 0; i < 5; i++ {
+		al.add(makeAuditMessage(i), log)
+	}
+	if al.Dropped() != 3 {
+		t.Fatalf("expected 3 dropped records, but got %d", al.Dropped())
+	}
+
+	var buf bytes.Buffer
+	al.w = &buf
+	go al.run()
+	for len(al.ch) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	al.add(makeAuditMessage(5), log)
+	if err := al.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var dropped uint64
+	err := readAuditLog(bytes.NewReader(buf.Bytes()), func(rec *pb.AuditRecord) error {
+		dropped += rec.GetDropped()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 3 {
+		t.Fatalf("expected the records to account for 3 dropped records, but got %d", dropped)
+	}
+}
+
+func TestAuditLogDelivery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var buf bytes.Buffer
+	al, err := NewAuditLog(&buf, WithAuditLogBlocking())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0]),
+		getPubsub(ctx, hosts[1], WithAuditLog([]string{"audited"}, al)),
+	}
+	connect(t, hosts[0], hosts[1])
+
+	var subs []*Subscription
+	for _, topic := range []string{"audited", "other"} {
+		sub, err := psubs[1].Subscribe(topic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		for j, topic := range []string{"audited", "other"} {
+			if err := psubs[0].Publish(topic, []byte(fmt.Sprintf("message %d", i))); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := subs[j].Next(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+	if err := al.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	n, _, err := VerifyAuditLog(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Fatalf("expected 5 records for the audited topic, but got %d", n)
+	}
+}
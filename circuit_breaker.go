@@ -0,0 +1,300 @@
+package pubsub
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// CircuitBreakerState is the state of the circuit breaker of a topic.
+type CircuitBreakerState int32
+
+const (
+	// CircuitClosed is the normal state, in which messages are forwarded.
+	CircuitClosed CircuitBreakerState = CircuitBreakerState(pb.TraceEvent_CircuitBreaker_CLOSED)
+	// CircuitOpen is the state of a tripped breaker: messages are still validated and delivered,
+	// but not forwarded, until the cool-down expires.
+	CircuitOpen CircuitBreakerState = CircuitBreakerState(pb.TraceEvent_CircuitBreaker_OPEN)
+	// CircuitHalfOpen is the state of a breaker after the cool-down: messages are forwarded again
+	// while the rate of invalid messages is probed; the breaker closes if it stays below the
+	// threshold for a window, and trips again otherwise.
+	CircuitHalfOpen CircuitBreakerState = CircuitBreakerState(pb.TraceEvent_CircuitBreaker_HALF_OPEN)
+)
+
+func (s CircuitBreakerState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitBreakerState(%d)", int32(s))
+	}
+}
+
+func (s CircuitBreakerState) pb() *pb.TraceEvent_CircuitBreaker_State {
+	return pb.TraceEvent_CircuitBreaker_State(s).Enum()
+}
+
+// CircuitBreakerParams are the parameters of the circuit breaker of a topic.
+type CircuitBreakerParams struct {
+	// Threshold is the fraction of the validated messages that must be rejected by the validators
+	// within a window for the breaker to trip; it must be in (0, 1].
+	Threshold float64
+	// Window is the duration of the windows over which the rejection rate is measured.
+	Window time.Duration
+	// MinMessages is the minimum number of validated messages in a window for the breaker to trip,
+	// so that a few invalid messages in a quiet topic don't trip it.
+	MinMessages int
+	// CoolDown is how long the breaker stays open before probing the topic in half-open state.
+	CoolDown time.Duration
+	// BlockLocal stops forwarding our own messages too while forwarding is disabled; they are
+	// still forwarded by default.
+	BlockLocal bool
+}
+
+// DefaultCircuitBreakerParams returns the default circuit breaker parameters: the breaker trips
+// when half of at least 100 messages in 10s are rejected, and probes the topic after a minute.
+func DefaultCircuitBreakerParams() CircuitBreakerParams {
+	return CircuitBreakerParams{
+		Threshold:   0.5,
+		Window:      10 * time.Second,
+		MinMessages: 100,
+		CoolDown:    time.Minute,
+	}
+}
+
+func (p *CircuitBreakerParams) validate() error {
+	if p.Threshold <= 0 || p.Threshold > 1 {
+		return fmt.Errorf("invalid circuit breaker threshold; must be in (0, 1]")
+	}
+	if p.Window <= 0 {
+		return fmt.Errorf("invalid circuit breaker window; must be positive")
+	}
+	if p.MinMessages < 1 {
+		return fmt.Errorf("invalid circuit breaker minimum messages; must be at least 1")
+	}
+	if p.CoolDown <= 0 {
+		return fmt.Errorf("invalid circuit breaker cool-down; must be positive")
+	}
+	return nil
+}
+
+// WithTopicCircuitBreaker installs a circuit breaker on a topic, which stops forwarding the messages
+// of the topic when too many of them are rejected by the validators, to avoid amplifying an attack.
+// Messages are still validated and delivered while the breaker is open, and forwarding is resumed
+// once the topic has calmed down; state transitions are logged and traced.
+// Forwarding can also be disabled manually with Topic.SetForwarding.
+func WithTopicCircuitBreaker(topic string, params CircuitBreakerParams) Option {
+	return func(ps *PubSub) error {
+		if err := params.validate(); err != nil {
+			return err
+		}
+
+		b := &circuitBreaker{
+			ps:     ps,
+			topic:  topic,
+			params: params,
+			start:  time.Now(),
+		}
+
+		if ps.breakers == nil {
+			ps.breakers = make(map[string]*circuitBreaker)
+		}
+		ps.breakers[topic] = b
+
+		// hook the tracer
+		if ps.tracer != nil {
+			ps.tracer.raw = append(ps.tracer.raw, b)
+		} else {
+			ps.tracer = &pubsubTracer{
+				raw:   []RawTracer{b},
+				pid:   ps.tr.ID(),
+				idGen: ps.idGen,
+			}
+		}
+
+		return nil
+	}
+}
+
+// CircuitBreakerState returns the state of the circuit breaker of a topic; it returns false if the
+// topic has no circuit breaker.
+func (p *PubSub) CircuitBreakerState(topic string) (CircuitBreakerState, bool) {
+	b, ok := p.breakers[topic]
+	if !ok {
+		return CircuitClosed, false
+	}
+	return b.State(), true
+}
+
+// SetForwarding enables or disables forwarding the messages of the topic, overriding its circuit
+// breaker: forwarding stays disabled until it is enabled again, which also closes the breaker.
+// Our own messages are still forwarded while forwarding is disabled, unless the circuit breaker of
+// the topic is configured with BlockLocal.
+func (t *Topic) SetForwarding(enabled bool) error {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if err := t.checkOpen(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	select {
+	case t.p.eval <- func() {
+		t.noForwarding = !enabled
+		if b, ok := t.p.breakers[t.topic]; ok && enabled {
+			b.reset()
+		}
+		close(done)
+	}:
+		<-done
+		return nil
+	case <-t.p.ctx.Done():
+		return t.p.ctx.Err()
+	}
+}
+
+// forwarding returns whether the messages of a topic may be forwarded; local is true for our own
+// messages.
+// Only called from processLoop.
+func (p *PubSub) forwarding(topic string, local bool) bool {
+	b := p.breakers[topic]
+	if local && (b == nil || !b.params.BlockLocal) {
+		return true
+	}
+
+	if t, ok := p.myTopics[topic]; ok && t.noForwarding {
+		return false
+	}
+
+	return b == nil || b.State() != CircuitOpen
+}
+
+// circuitBreaker is the circuit breaker of a topic; it tracks the validation outcomes of the
+// messages in the topic as a raw tracer.
+type circuitBreaker struct {
+	ps     *PubSub
+	topic  string
+	params CircuitBreakerParams
+
+	mx    sync.Mutex
+	state CircuitBreakerState
+	// start of the current window, or the time the breaker was opened
+	start time.Time
+	// validation outcomes in the current window
+	valid, invalid int
+}
+
+var _ RawTracer = (*circuitBreaker)(nil)
+
+// State returns the current state of the breaker.
+func (b *circuitBreaker) State() CircuitBreakerState {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	b.update(time.Now())
+	return b.state
+}
+
+// reset closes the breaker.
+func (b *circuitBreaker) reset() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	b.setState(CircuitClosed, time.Now())
+}
+
+// update applies the transitions due to the passage of time; it must be called with the lock held.
+func (b *circuitBreaker) update(now time.Time) {
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.start) >= b.params.CoolDown {
+			b.setState(CircuitHalfOpen, now)
+		}
+
+	case CircuitHalfOpen:
+		if now.Sub(b.start) >= b.params.Window {
+			// the topic stayed calm for a whole window
+			b.setState(CircuitClosed, now)
+		}
+
+	case CircuitClosed:
+		if now.Sub(b.start) >= b.params.Window {
+			b.start = now
+			b.valid, b.invalid = 0, 0
+		}
+	}
+}
+
+// setState transitions the breaker to a new state, starting a new window; it must be called with
+// the lock held.
+func (b *circuitBreaker) setState(state CircuitBreakerState, now time.Time) {
+	b.start = now
+	b.valid, b.invalid = 0, 0
+	if b.state == state {
+		return
+	}
+
+	b.ps.logger.Infow("circuit breaker state change", "topic", b.topic, "from", b.state, "to", state)
+	b.state = state
+	b.ps.tracer.CircuitBreaker(b.topic, state)
+}
+
+// record records the validation outcome of a message.
+func (b *circuitBreaker) record(msg *Message, valid bool) {
+	if msg.GetTopic() != b.topic {
+		return
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	now := time.Now()
+	b.update(now)
+	if b.state == CircuitOpen {
+		return
+	}
+
+	if valid {
+		b.valid++
+	} else {
+		b.invalid++
+	}
+
+	total := b.valid + b.invalid
+	if total >= b.params.MinMessages && float64(b.invalid)/float64(total) >= b.params.Threshold {
+		b.setState(CircuitOpen, now)
+	}
+}
+
+func (b *circuitBreaker) DeliverMessage(msg *Message) {
+	b.record(msg, true)
+}
+
+func (b *circuitBreaker) RejectMessage(msg *Message, reason string) {
+	if reason == RejectValidationFailed {
+		b.record(msg, false)
+	}
+}
+
+func (b *circuitBreaker) AddPeer(p peer.ID, proto protocol.ID) {}
+func (b *circuitBreaker) RemovePeer(p peer.ID)                 {}
+func (b *circuitBreaker) Join(topic string)                    {}
+func (b *circuitBreaker) Leave(topic string)                   {}
+func (b *circuitBreaker) Graft(p peer.ID, topic string)        {}
+func (b *circuitBreaker) Prune(p peer.ID, topic string)        {}
+func (b *circuitBreaker) ValidateMessage(msg *Message)         {}
+func (b *circuitBreaker) DuplicateMessage(msg *Message)        {}
+func (b *circuitBreaker) ThrottlePeer(p peer.ID)               {}
+func (b *circuitBreaker) RecvRPC(rpc *RPC)                     {}
+func (b *circuitBreaker) SendRPC(rpc *RPC, p peer.ID)          {}
+func (b *circuitBreaker) DropRPC(rpc *RPC, p peer.ID)          {}
+func (b *circuitBreaker) UndeliverableMessage(msg *Message)    {}
@@ -0,0 +1,190 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type circuitBreakerTracer struct {
+	mx     sync.Mutex
+	states []CircuitBreakerState
+}
+
+func (t *circuitBreakerTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_CIRCUIT_BREAKER {
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.states = append(t.states, CircuitBreakerState(evt.GetCircuitBreaker().GetState()))
+}
+
+func (t *circuitBreakerTracer) get() []CircuitBreakerState {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return append([]CircuitBreakerState(nil), t.states...)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "foobar"
+	hosts := getNetHosts(t, ctx, 3)
+
+	// the middle node has the breaker, and rejects bad messages
+	tracer := &circuitBreakerTracer{}
+	params := CircuitBreakerParams{
+		Threshold:   0.5,
+		Window:      2 * time.Second,
+		MinMessages: 10,
+		CoolDown:    time.Second,
+	}
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0]),
+		getPubsub(ctx, hosts[1], WithTopicCircuitBreaker(topic, params), WithEventTracer(tracer)),
+		getPubsub(ctx, hosts[2]),
+	}
+	err := psubs[1].RegisterTopicValidator(topic, func(ctx context.Context, from peer.ID, msg *Message) bool {
+		return string(msg.Data) != "bad"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topics := getTopics(psubs, topic)
+	var subs []*Subscription
+	for _, tp := range topics[1:] {
+		sub, err := tp.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+	time.Sleep(100 * time.Millisecond)
+
+	publish := func(data string, from int) {
+		t.Helper()
+		if err := topics[from].Publish(ctx, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := func(sub *Subscription, data string) {
+		t.Helper()
+		rctx, rcancel := context.WithTimeout(ctx, time.Second)
+		defer rcancel()
+		msg, err := sub.Next(rctx)
+		if err != nil {
+			t.Fatalf("expected to receive %q: %s", data, err)
+		}
+		if string(msg.Data) != data {
+			t.Fatalf("expected to receive %q, got %q", data, msg.Data)
+		}
+	}
+	expectNothing := func(sub *Subscription) {
+		t.Helper()
+		rctx, rcancel := context.WithTimeout(ctx, 300*time.Millisecond)
+		defer rcancel()
+		if msg, err := sub.Next(rctx); err == nil {
+			t.Fatalf("unexpected message %q", msg.Data)
+		}
+	}
+	expectState := func(state CircuitBreakerState) {
+		t.Helper()
+		if s, ok := psubs[1].CircuitBreakerState(topic); !ok || s != state {
+			t.Fatalf("expected the breaker to be %s, got %s", state, s)
+		}
+	}
+
+	publish("good", 0)
+	expect(subs[0], "good")
+	expect(subs[1], "good")
+	expectState(CircuitClosed)
+
+	// trip the breaker
+	for i := 0; i < params.MinMessages; i++ {
+		publish("bad", 0)
+	}
+	time.Sleep(100 * time.Millisecond)
+	expectState(CircuitOpen)
+
+	// messages are still delivered but not forwarded, except for our own
+	publish("unforwarded", 0)
+	expect(subs[0], "unforwarded")
+	expectNothing(subs[1])
+	publish("local", 1)
+	expect(subs[0], "local")
+	expect(subs[1], "local")
+
+	// probing after the cool-down
+	time.Sleep(params.CoolDown)
+	expectState(CircuitHalfOpen)
+	publish("probe", 0)
+	expect(subs[0], "probe")
+	expect(subs[1], "probe")
+
+	// recovered after a calm window
+	time.Sleep(params.Window)
+	expectState(CircuitClosed)
+	publish("recovered", 0)
+	expect(subs[0], "recovered")
+	expect(subs[1], "recovered")
+
+	expected := []CircuitBreakerState{CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if states := tracer.get(); len(states) != len(expected) {
+		t.Fatalf("expected state transitions %v, got %v", expected, states)
+	} else {
+		for i := range states {
+			if states[i] != expected[i] {
+				t.Fatalf("expected state transitions %v, got %v", expected, states)
+			}
+		}
+	}
+
+	// manual override
+	if err := topics[1].SetForwarding(false); err != nil {
+		t.Fatal(err)
+	}
+	publish("disabled", 0)
+	expect(subs[0], "disabled")
+	expectNothing(subs[1])
+
+	if err := topics[1].SetForwarding(true); err != nil {
+		t.Fatal(err)
+	}
+	publish("enabled", 0)
+	expect(subs[0], "enabled")
+	expect(subs[1], "enabled")
+}
+
+func TestCircuitBreakerParams(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	for _, params := range []CircuitBreakerParams{
+		{Threshold: 0, Window: time.Second, MinMessages: 1, CoolDown: time.Second},
+		{Threshold: 1.5, Window: time.Second, MinMessages: 1, CoolDown: time.Second},
+		{Threshold: 0.5, Window: 0, MinMessages: 1, CoolDown: time.Second},
+		{Threshold: 0.5, Window: time.Second, MinMessages: 0, CoolDown: time.Second},
+		{Threshold: 0.5, Window: time.Second, MinMessages: 1, CoolDown: 0},
+	} {
+		if _, err := NewFloodSub(ctx, hosts[0], WithTopicCircuitBreaker("foobar", params)); err == nil {
+			t.Fatalf("expected an error for invalid parameters %+v", params)
+		}
+	}
+
+	if _, err := NewFloodSub(ctx, hosts[0], WithTopicCircuitBreaker("foobar", DefaultCircuitBreakerParams())); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,18 +1,18 @@
 package pubsub
 
 import (
-	"context"
 	"encoding/binary"
+	"errors"
 	"io"
+	"net"
+	"sync/atomic"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
 	pool "github.com/libp2p/go-buffer-pool"
 	"github.com/multiformats/go-varint"
 
-	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
-	"github.com/libp2p/go-msgio"
 
 	pb "github.com/libp2p/go-libp2p-pubsub/pb"
 )
@@ -41,34 +41,56 @@ func (p *PubSub) getHelloPacket() *RPC {
 	return &rpc
 }
 
-func (p *PubSub) handleNewStream(s network.Stream) {
-	peer := s.Conn().RemotePeer()
+func (p *PubSub) handleNewStream(s TransportStream) {
+	peer := s.RemotePeer()
+	logger := withFields(p.logger, "peer", peer)
+
+	in := &inboundStream{s: s, done: make(chan struct{})}
+	defer close(in.done)
 
 	p.inboundStreamsMx.Lock()
 	other, dup := p.inboundStreams[peer]
-	if dup {
-		log.Debugf("duplicate inbound stream from %s; resetting other stream", peer)
-		other.Reset()
-	}
-	p.inboundStreams[peer] = s
+	p.inboundStreams[peer] = in
 	p.inboundStreamsMx.Unlock()
 
 	defer func() {
 		p.inboundStreamsMx.Lock()
-		if p.inboundStreams[peer] == s {
+		if p.inboundStreams[peer] == in {
 			delete(p.inboundStreams, peer)
 		}
 		p.inboundStreamsMx.Unlock()
 	}()
 
-	r := msgio.NewVarintReaderSize(s, p.maxMessageSize)
+	if dup {
+		// the peer may be moving its outbound stream to a better connection, closing the other
+		// stream once the new one is open; wait for the other stream to be drained, so as to read
+		// the RPCs in order
+		timer := time.NewTimer(StreamHandoverTimeout)
+		select {
+		case <-other.done:
+		case <-timer.C:
+			logger.Debugf("duplicate inbound stream; resetting other stream")
+			other.s.Reset()
+			<-other.done
+		case <-p.ctx.Done():
+			timer.Stop()
+			s.Reset()
+			return
+		}
+		timer.Stop()
+	}
+
+	r := newRPCReader(s, p.maxMessageSize, &p.readBufferBytes)
+	defer r.release()
+
+	hello := !dup
+
 	for {
-		msgbytes, err := r.ReadMsg()
+		msgbytes, err := r.next()
 		if err != nil {
-			r.ReleaseMsg(msgbytes)
 			if err != io.EOF {
 				s.Reset()
-				log.Debugf("error reading rpc from %s: %s", s.Conn().RemotePeer(), err)
+				logger.Debugf("error reading rpc: %s", err)
 			} else {
 				// Just be nice. They probably won't read this
 				// but it doesn't hurt to send it.
@@ -78,19 +100,39 @@ func (p *PubSub) handleNewStream(s network.Stream) {
 			return
 		}
 
+		// check the limits before decoding, as decoding allocates in proportion to the entries
+		if err := checkRPCLimits(msgbytes, &p.rpcLimits); err != nil {
+			logger.Debugf("dropping rpc: %s", err)
+			p.rejectRPC(peer, err.(*rpcLimitError))
+			continue
+		}
+
 		rpc := new(RPC)
-		err = rpc.Unmarshal(msgbytes)
-		r.ReleaseMsg(msgbytes)
+		lazy, err := unmarshalRPC(rpc, msgbytes)
 		if err != nil {
 			s.Reset()
-			log.Warnf("bogus rpc from %s: %s", s.Conn().RemotePeer(), err)
+			logger.Warnf("bogus rpc: %s", err)
 			return
 		}
+		if lazy {
+			// the payloads refer to the read buffer, so it is handed over to the RPC until it has
+			// been handled, and the next one is read into another buffer
+			rpc.buf = r.detach()
+		}
 
 		rpc.from = peer
+		rpc.hello = hello
+		hello = false
+		if !p.prepareIncoming(rpc) {
+			p.releaseReadBuffer(rpc)
+			s.Reset()
+			return
+		}
+
 		select {
 		case p.incoming <- rpc:
 		case <-p.ctx.Done():
+			p.releaseReadBuffer(rpc)
 			// Close is useless because the other side isn't reading.
 			s.Reset()
 			return
@@ -98,6 +140,135 @@ func (p *PubSub) handleNewStream(s network.Stream) {
 	}
 }
 
+// prepareIncoming does the work on an RPC read from a peer that can be done off the event loop. The
+// inbound faults are injected first, as they may modify the messages, whose IDs and signatures must
+// match what the event loop handles. It returns false if the PubSub is closed while the RPC is
+// held back.
+func (p *PubSub) prepareIncoming(rpc *RPC) bool {
+	rpc.malformed = sanitizeRPC(rpc)
+
+	if p.faults != nil && !p.injectInbound(rpc) {
+		return false
+	}
+
+	p.identifyIncoming(rpc)
+	p.dropSeenIncoming(rpc)
+	p.val.verifyIncoming(rpc)
+	return true
+}
+
+// unmarshalRPC decodes an RPC without copying the payload of published messages: the Data field of
+// each message is a sub-slice of buf, while the rest of the RPC is decoded eagerly.
+// Payloads are copied out of the buffer only when messages are queued for validation (see
+// Message.detachData), which spares the copy for the duplicates and the other messages dropped on
+// receipt.
+// It returns true if any message refers to buf, in which case the buffer must not be reused.
+// RPCs with fields we don't know about are decoded with the generated decoder.
+func unmarshalRPC(rpc *RPC, buf []byte) (bool, error) {
+	lazy := false
+	for i := 0; i < len(buf); {
+		field, val, n, ok := nextField(buf[i:])
+		if !ok {
+			return false, unmarshalRPCEager(rpc, buf)
+		}
+		i += n
+
+		switch field {
+		case 1:
+			sub := new(pb.RPC_SubOpts)
+			if err := sub.Unmarshal(val); err != nil {
+				return false, err
+			}
+			rpc.Subscriptions = append(rpc.Subscriptions, sub)
+
+		case 2:
+			msg, ok := unmarshalMessage(val)
+			if !ok {
+				return false, unmarshalRPCEager(rpc, buf)
+			}
+			if msg.Data != nil {
+				lazy = true
+			}
+			rpc.Publish = append(rpc.Publish, msg)
+
+		case 3:
+			if rpc.Control == nil {
+				rpc.Control = new(pb.ControlMessage)
+			}
+			if err := rpc.Control.Unmarshal(val); err != nil {
+				return false, err
+			}
+
+		default:
+			return false, unmarshalRPCEager(rpc, buf)
+		}
+	}
+
+	return lazy, nil
+}
+
+func unmarshalRPCEager(rpc *RPC, buf []byte) error {
+	rpc.RPC.Reset()
+	return rpc.RPC.Unmarshal(buf)
+}
+
+// unmarshalMessage decodes a message, with its Data referring to buf; it returns false if the
+// message contains fields we don't know about.
+func unmarshalMessage(buf []byte) (*pb.Message, bool) {
+	msg := new(pb.Message)
+	for i := 0; i < len(buf); {
+		field, val, n, ok := nextField(buf[i:])
+		if !ok {
+			return nil, false
+		}
+		i += n
+
+		switch field {
+		case 1:
+			msg.From = append([]byte{}, val...)
+		case 2:
+			msg.Data = val
+		case 3:
+			msg.Seqno = append([]byte{}, val...)
+		case 4:
+			topic := string(val)
+			msg.Topic = &topic
+		case 5:
+			msg.Signature = append([]byte{}, val...)
+		case 6:
+			msg.Key = append([]byte{}, val...)
+		default:
+			return nil, false
+		}
+	}
+
+	return msg, true
+}
+
+// nextField reads the next length-delimited field from a protobuf encoded buffer, returning its
+// field number, its value and the number of bytes consumed; ok is false if the field has a
+// different wire type or the buffer is malformed.
+// The value is capped, so that appending to it can never clobber the rest of the buffer.
+func nextField(buf []byte) (field uint64, val []byte, n int, ok bool) {
+	key, k := binary.Uvarint(buf)
+	if k <= 0 || key&7 != 2 {
+		return 0, nil, 0, false
+	}
+
+	length, l := binary.Uvarint(buf[k:])
+	if l <= 0 {
+		return 0, nil, 0, false
+	}
+
+	start := k + l
+	if length > uint64(len(buf)-start) {
+		return 0, nil, 0, false
+	}
+	end := start + int(length)
+
+	return key >> 3, buf[start:end:end], end, true
+}
+
 func (p *PubSub) notifyPeerDead(pid peer.ID) {
 	p.peerDeadPrioLk.RLock()
 	p.peerDeadMx.Lock()
@@ -111,49 +282,70 @@ func (p *PubSub) notifyPeerDead(pid peer.ID) {
 	}
 }
 
-func (p *PubSub) handleNewPeer(ctx context.Context, pid peer.ID, outgoing <-chan *RPC) {
-	s, err := p.host.NewStream(p.ctx, pid, p.rt.Protocols()...)
+func (p *PubSub) handleNewPeer(w *peerWriter, outgoing <-chan *RPC) {
+	s, err := p.tr.NewStream(w.ctx, w.pid, p.wireProtocols()...)
 	if err != nil {
-		log.Debug("opening new stream to peer: ", err, pid)
+		p.logger.Debugw("error opening new stream to peer", "peer", w.pid, "err", err)
 
 		select {
-		case p.newPeerError <- pid:
-		case <-ctx.Done():
+		case p.newPeerError <- w:
+		case <-w.ctx.Done():
 		}
 
 		return
 	}
 
-	go p.handleSendingMessages(ctx, s, outgoing)
-	go p.handlePeerDead(s)
+	go p.handleSendingMessages(w, s, outgoing)
+	go p.handlePeerDead(w, s)
 	select {
-	case p.newPeerStream <- s:
-	case <-ctx.Done():
+	case p.newPeerStream <- &peerStream{w: w, s: s}:
+	case <-w.ctx.Done():
 	}
 }
 
-func (p *PubSub) handleNewPeerWithBackoff(ctx context.Context, pid peer.ID, backoff time.Duration, outgoing <-chan *RPC) {
+func (p *PubSub) handleNewPeerWithBackoff(w *peerWriter, backoff time.Duration, outgoing <-chan *RPC) {
 	select {
 	case <-time.After(backoff):
-		p.handleNewPeer(ctx, pid, outgoing)
-	case <-ctx.Done():
+		p.handleNewPeer(w, outgoing)
+	case <-w.ctx.Done():
 		return
 	}
 }
 
-func (p *PubSub) handlePeerDead(s network.Stream) {
-	pid := s.Conn().RemotePeer()
+func (p *PubSub) handlePeerDead(w *peerWriter, s TransportStream) {
+	pid := s.RemotePeer()
 
 	_, err := s.Read([]byte{0})
 	if err == nil {
-		log.Debugf("unexpected message from %s", pid)
+		p.logger.Debugw("unexpected message from peer", "peer", pid)
+	}
+
+	if w.isRetired(s) {
+		// the writer has moved to a stream on a better connection, and closed this one
+		return
 	}
 
 	s.Reset()
+	if w.ctx.Err() != nil {
+		// the writer has been torn down, and possibly replaced
+		return
+	}
 	p.notifyPeerDead(pid)
 }
 
-func (p *PubSub) handleSendingMessages(ctx context.Context, s network.Stream, outgoing <-chan *RPC) {
+// writeDeadliner is implemented by streams that support write deadlines.
+type writeDeadliner interface {
+	SetWriteDeadline(time.Time) error
+}
+
+func (p *PubSub) handleSendingMessages(w *peerWriter, s TransportStream, outgoing <-chan *RPC) {
+	defer atomic.StoreInt32(&w.exited, 1)
+
+	wd, _ := s.(writeDeadliner)
+	if p.streamWriteTimeout == 0 {
+		wd = nil
+	}
+
 	writeRpc := func(rpc *RPC) error {
 		size := uint64(rpc.Size())
 
@@ -166,30 +358,104 @@ func (p *PubSub) handleSendingMessages(ctx context.Context, s network.Stream, ou
 			return err
 		}
 
+		if wd != nil {
+			if err := wd.SetWriteDeadline(time.Now().Add(p.streamWriteTimeout)); err != nil {
+				return err
+			}
+		}
+
 		_, err = s.Write(buf)
+		if err == nil {
+			atomic.StoreInt64(&w.lastWrite, time.Now().UnixNano())
+		}
 		return err
 	}
 
-	defer s.Close()
+	defer func() {
+		s.Close()
+	}()
 	for {
 		select {
+		case ns := <-w.upgrade:
+			// the peer reads the new stream once this one is drained, so the order of the RPCs is
+			// preserved
+			w.retire(s)
+			s.Close()
+			s = ns
+			wd, _ = s.(writeDeadliner)
+			if p.streamWriteTimeout == 0 {
+				wd = nil
+			}
+			go p.handlePeerDead(w, s)
+			p.streamUpgraded(w, s)
+
 		case rpc, ok := <-outgoing:
 			if !ok {
 				return
 			}
-
-			err := writeRpc(rpc)
-			if err != nil {
-				s.Reset()
-				log.Debugf("writing message to %s: %s", s.Conn().RemotePeer(), err)
+			w.queue.dequeue()
+
+			closed := false
+			rpc = p.prepareRPC(rpc, w.pid)
+			for rpc != nil {
+				// merge the RPCs queued behind this one, if we coalesce them
+				var next *RPC
+				if p.coalesceRPCs {
+					rpc, next, closed = p.coalesceRPC(rpc, outgoing, w)
+				}
+
+				err := writeRpc(rpc)
+				if err != nil {
+					s.Reset()
+					p.logger.Debugw("error writing message to peer", "peer", s.RemotePeer(), "err", err)
+					var nerr net.Error
+					if errors.As(err, &nerr) && nerr.Timeout() {
+						p.handleWriteTimeout(w, outgoing)
+					}
+					return
+				}
+
+				rpc = next
+			}
+			if closed {
 				return
 			}
-		case <-ctx.Done():
+		case <-w.ctx.Done():
 			return
 		}
 	}
 }
 
+// handleWriteTimeout drops the RPCs queued for a peer whose stream write timed out, so that they
+// don't pin memory until the peer is declared dead, and reports the timeout.
+func (p *PubSub) handleWriteTimeout(w *peerWriter, outgoing <-chan *RPC) {
+	pid := w.pid
+drain:
+	for {
+		select {
+		case _, ok := <-outgoing:
+			if !ok {
+				break drain
+			}
+			w.queue.dequeue()
+		default:
+			break drain
+		}
+	}
+
+	select {
+	case p.eval <- func() {
+		p.tracer.WriteTimeout(pid)
+		if p.writeTimeoutPenalty > 0 {
+			if gs, ok := p.rt.(*GossipSubRouter); ok {
+				gs.score.AddPenalty(pid, p.writeTimeoutPenalty, PenaltyWriteTimeout)
+			}
+		}
+	}:
+	case <-p.ctx.Done():
+	}
+}
+
 func rpcWithSubs(subs ...*pb.RPC_SubOpts) *RPC {
 	return &RPC{
 		RPC: pb.RPC{
@@ -0,0 +1,674 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-msgio"
+	"github.com/multiformats/go-varint"
+)
+
+func makeTestRPC(nMessages, size int) *pb.RPC {
+	rpc := &pb.RPC{
+		Subscriptions: []*pb.RPC_SubOpts{
+			{Topicid: proto.String("foobar"), Subscribe: proto.Bool(true)},
+			{Topicid: proto.String("barfoo"), Subscribe: proto.Bool(false)},
+		},
+		Control: &pb.ControlMessage{
+			Ihave: []*pb.ControlIHave{{TopicID: proto.String("foobar"), MessageIDs: []string{"a", "b"}}},
+			Graft: []*pb.ControlGraft{{TopicID: proto.String("foobar")}},
+		},
+	}
+
+	for i := 0; i < nMessages; i++ {
+		data := make([]byte, size)
+		rand.Read(data)
+		rpc.Publish = append(rpc.Publish, &pb.Message{
+			From:      []byte("from"),
+			Data:      data,
+			Seqno:     []byte(fmt.Sprintf("%d", i)),
+			Topic:     proto.String("foobar"),
+			Signature: []byte("signature"),
+			Key:       []byte("key"),
+		})
+	}
+
+	return rpc
+}
+
+func TestUnmarshalRPCLazy(t *testing.T) {
+	src := makeTestRPC(5, 128)
+	// messages without payload don't refer to the buffer
+	src.Publish = append(src.Publish, &pb.Message{Topic: proto.String("foobar")}, &pb.Message{Data: []byte{}, Topic: proto.String("foobar")})
+
+	buf, err := src.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eager := new(pb.RPC)
+	if err := eager.Unmarshal(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	lazy := new(RPC)
+	ok, err := unmarshalRPC(lazy, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected the message payloads to refer to the buffer")
+	}
+
+	eagerBytes, _ := eager.Marshal()
+	lazyBytes, _ := lazy.RPC.Marshal()
+	if !bytes.Equal(eagerBytes, lazyBytes) {
+		t.Fatal("lazily decoded RPC differs from the eagerly decoded one")
+	}
+
+	// the payload must not have been copied, while the other fields must have
+	msg := lazy.Publish[0]
+	data := append([]byte(nil), msg.Data...)
+	seqno := append([]byte(nil), msg.Seqno...)
+	for i := range buf {
+		buf[i] = ^buf[i]
+	}
+	if bytes.Equal(msg.Data, data) {
+		t.Fatal("expected the payload to refer to the buffer")
+	}
+	if !bytes.Equal(msg.Seqno, seqno) {
+		t.Fatal("expected the seqno to be copied out of the buffer")
+	}
+	if cap(msg.Data) != len(msg.Data) {
+		t.Fatal("expected the payload to be capped")
+	}
+
+	// restore the buffer; detaching copies the payload out of it
+	for i := range buf {
+		buf[i] = ^buf[i]
+	}
+	m := &Message{Message: msg, lazy: true}
+	m.detachData()
+	for i := range buf {
+		buf[i] = ^buf[i]
+	}
+	if !bytes.Equal(m.Data, data) {
+		t.Fatal("expected the detached payload to no longer refer to the buffer")
+	}
+}
+
+func TestUnmarshalRPCFallback(t *testing.T) {
+	src := makeTestRPC(2, 16)
+	// an unknown field in the message makes us fall back to the generated decoder
+	src.Publish[1].XXX_unrecognized = []byte{0x38, 0x01}
+
+	buf, err := src.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rpc := new(RPC)
+	ok, err := unmarshalRPC(rpc, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("expected the RPC to be decoded eagerly")
+	}
+	if len(rpc.Publish) != 2 || len(rpc.Subscriptions) != 2 || rpc.Control == nil {
+		t.Fatal("expected the RPC to be fully decoded")
+	}
+
+	srcBytes, _ := src.Marshal()
+	rpcBytes, _ := rpc.RPC.Marshal()
+	if !bytes.Equal(srcBytes, rpcBytes) {
+		t.Fatal("decoded RPC differs from the original one")
+	}
+
+	// malformed RPCs must be rejected
+	if _, err := unmarshalRPC(new(RPC), buf[:len(buf)-1]); err == nil {
+		t.Fatal("expected an error decoding a truncated RPC")
+	}
+}
+
+func TestLazyRPCValidation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := getPubsubs(ctx, hosts, WithValidateQueueSize(256), WithPeerOutboundQueueSize(256))
+
+	// reject half of the messages, asynchronously
+	err := psubs[1].RegisterTopicValidator("foobar", func(ctx context.Context, from peer.ID, msg *Message) bool {
+		return msg.Data[0]%2 == 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := psubs[1].Subscribe("foobar", WithBufferSize(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	expected := make(map[string]struct{})
+	for i := 0; i < 100; i++ {
+		data := make([]byte, 1024)
+		rand.Read(data)
+		data[0] = byte(i)
+		if i%2 == 0 {
+			expected[string(data)] = struct{}{}
+		}
+
+		if err := psubs[0].Publish("foobar", data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// validation is asynchronous, so messages may be delivered out of order
+	for len(expected) > 0 {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		msg, err := sub.Next(ctx)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := expected[string(msg.Data)]; !ok {
+			t.Fatal("received message payload differs from the published ones")
+		}
+		delete(expected, string(msg.Data))
+	}
+}
+
+func BenchmarkUnmarshalRPC(b *testing.B) {
+	buf, err := makeTestRPC(10, 16384).Marshal()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// most messages get rejected by a cheap validator, so they are dropped right after decoding
+	b.Run("Eager", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			rpc := new(RPC)
+			if err := rpc.Unmarshal(buf); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Lazy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			rpc := new(RPC)
+			if _, err := unmarshalRPC(rpc, buf); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestRPCReader(t *testing.T) {
+	var used int64
+	var stream bytes.Buffer
+	w := msgio.NewVarintWriter(&stream)
+
+	sizes := []int{10, 5000, 100, 20000, 0}
+	for _, size := range sizes {
+		msg := make([]byte, size)
+		rand.Read(msg)
+		if err := w.WriteMsg(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := append([]byte(nil), stream.Bytes()...)
+
+	r := newRPCReader(&stream, 16384, &used)
+	offset := 0
+	for i, size := range sizes[:2] {
+		msg, err := r.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		offset += len(varint.ToUvarint(uint64(size)))
+		if !bytes.Equal(msg, expected[offset:offset+size]) {
+			t.Fatalf("message %d differs from the written one", i)
+		}
+		offset += size
+	}
+
+	// the buffer grows to fit the largest message read so far, and is reused for smaller ones
+	if used != int64(cap(r.buf)) || cap(r.buf) < 5000 {
+		t.Fatalf("expected the gauge to track a buffer of at least 5000 bytes, got %d", used)
+	}
+	capBefore := cap(r.buf)
+	msg, err := r.next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg) != 100 || cap(r.buf) != capBefore {
+		t.Fatal("expected the buffer to be reused")
+	}
+
+	// messages over the maximum size are rejected
+	if _, err := r.next(); err != msgio.ErrMsgTooLarge {
+		t.Fatalf("expected ErrMsgTooLarge, got %v", err)
+	}
+
+	r.release()
+	if used != 0 {
+		t.Fatalf("expected the gauge to be 0 after release, got %d", used)
+	}
+
+	// a truncated message is an error
+	stream.Reset()
+	stream.Write(varint.ToUvarint(100))
+	stream.Write(make([]byte, 50))
+	r = newRPCReader(&stream, 16384, &used)
+	defer r.release()
+	if _, err := r.next(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestRPCReaderBufferHandling(t *testing.T) {
+	var used int64
+	var stream bytes.Buffer
+	w := msgio.NewVarintWriter(&stream)
+
+	large := make([]byte, 4*maxIdleReadBufferSize)
+	rand.Read(large)
+	for _, msg := range [][]byte{large, make([]byte, 10), make([]byte, 10)} {
+		if err := w.WriteMsg(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := newRPCReader(&stream, len(large), &used)
+	defer r.release()
+
+	// an RPC whose messages refer to the buffer takes it over, and it is accounted until the RPC
+	// returns it
+	msg, err := r.next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := r.detach()
+	if r.buf != nil || used != int64(cap(buf)) {
+		t.Fatalf("expected the reader to hand over its buffer, got %d bytes in use", used)
+	}
+	if _, err := r.next(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(msg, large) {
+		t.Fatal("expected the detached buffer not to be reused")
+	}
+	if cap(r.buf) > maxIdleReadBufferSize || used != int64(cap(buf)+cap(r.buf)) {
+		t.Fatalf("expected a new buffer for the next RPC, got %d bytes", cap(r.buf))
+	}
+	putReadBuffer(buf, &used)
+	if used != int64(cap(r.buf)) {
+		t.Fatalf("expected the detached buffer to be released, got %d bytes in use", used)
+	}
+
+	// a large buffer is not kept while waiting for the next RPC
+	stream.Reset()
+	for _, msg := range [][]byte{large, make([]byte, 10)} {
+		if err := w.WriteMsg(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.next(); err != nil {
+		t.Fatal(err)
+	}
+	if cap(r.buf) < len(large) {
+		t.Fatalf("expected the buffer to grow to the RPC size, got %d bytes", cap(r.buf))
+	}
+	if _, err := r.next(); err != nil {
+		t.Fatal(err)
+	}
+	if cap(r.buf) > maxIdleReadBufferSize || used != int64(cap(r.buf)) {
+		t.Fatalf("expected the large buffer to go back to the pool, got %d bytes", cap(r.buf))
+	}
+}
+
+func TestRPCReaderGauge(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := getPubsubs(ctx, hosts)
+
+	sub, err := psubs[1].Subscribe("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	if err := psubs[0].Publish("foobar", make([]byte, 64*1024)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.Next(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the buffer of the RPC carrying the message goes back to the pool once the RPC has been
+	// handled, and the following RPCs are read into a new one
+	if _, err := psubs[0].Subscribe("other"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; psubs[1].ReadBufferBytes() < minReadBufferSize; i++ {
+		if i == 100 {
+			t.Fatalf("expected a read buffer in use, got %d bytes", psubs[1].ReadBufferBytes())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := psubs[1].ReadBufferBytes(); n > maxIdleReadBufferSize {
+		t.Fatalf("expected at most %d bytes of read buffers in use, got %d", maxIdleReadBufferSize, n)
+	}
+
+	hosts[0].Close()
+	for i := 0; psubs[1].ReadBufferBytes() != 0; i++ {
+		if i == 100 {
+			t.Fatalf("expected the read buffers to be released, got %d bytes in use", psubs[1].ReadBufferBytes())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// BenchmarkReadRPC reads large RPCs from many peers concurrently, retaining the decoded payloads as
+// validation would; compare with the msgio reader, which allocates a buffer per RPC.
+func BenchmarkReadRPC(b *testing.B) {
+	const peers = 500
+	const rpcsPerPeer = 10
+
+	buf, err := makeTestRPC(4, 64*1024).Marshal()
+	if err != nil {
+		b.Fatal(err)
+	}
+	var stream bytes.Buffer
+	w := msgio.NewVarintWriter(&stream)
+	for i := 0; i < rpcsPerPeer; i++ {
+		if err := w.WriteMsg(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+	data := stream.Bytes()
+
+	run := func(b *testing.B, read func(r io.Reader) error) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var wg sync.WaitGroup
+			wg.Add(peers)
+			for j := 0; j < peers; j++ {
+				go func() {
+					defer wg.Done()
+					if err := read(bytes.NewReader(data)); err != nil {
+						b.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+		}
+	}
+
+	b.Run("Msgio", func(b *testing.B) {
+		run(b, func(s io.Reader) error {
+			r := msgio.NewVarintReaderSize(s, DefaultMaxMessageSize)
+			for {
+				msgbytes, err := r.ReadMsg()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				rpc := new(RPC)
+				if _, err := unmarshalRPC(rpc, msgbytes); err != nil {
+					return err
+				}
+			}
+		})
+	})
+
+	b.Run("Pooled", func(b *testing.B) {
+		var used int64
+		run(b, func(s io.Reader) error {
+			r := newRPCReader(s, DefaultMaxMessageSize, &used)
+			defer r.release()
+			for {
+				msgbytes, err := r.next()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				rpc := new(RPC)
+				lazy, err := unmarshalRPC(rpc, msgbytes)
+				if err != nil {
+					return err
+				}
+				if lazy {
+					// the payloads are copied out as the messages are queued for validation, and
+					// the buffer goes back to the pool once the RPC has been handled
+					buf := r.detach()
+					for _, pmsg := range rpc.Publish {
+						msg := &Message{Message: pmsg, lazy: true}
+						msg.detachData()
+					}
+					putReadBuffer(buf, &used)
+				}
+			}
+		})
+	})
+}
+
+// stalledStream is a stream whose remote end never reads: writes block until the write deadline
+// expires or the stream is reset.
+type stalledStream struct {
+	pid peer.ID
+
+	mx       sync.Mutex
+	deadline time.Time
+	reset    chan struct{}
+	isReset  bool
+}
+
+func newStalledStream(pid peer.ID) *stalledStream {
+	return &stalledStream{pid: pid, reset: make(chan struct{})}
+}
+
+func (s *stalledStream) Read(b []byte) (int, error) {
+	<-s.reset
+	return 0, io.ErrClosedPipe
+}
+
+func (s *stalledStream) Write(b []byte) (int, error) {
+	s.mx.Lock()
+	deadline := s.deadline
+	s.mx.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-timeout:
+		return 0, os.ErrDeadlineExceeded
+	case <-s.reset:
+		return 0, io.ErrClosedPipe
+	}
+}
+
+func (s *stalledStream) SetWriteDeadline(t time.Time) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.deadline = t
+	return nil
+}
+
+func (s *stalledStream) Close() error {
+	return s.Reset()
+}
+
+func (s *stalledStream) Reset() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if !s.isReset {
+		s.isReset = true
+		close(s.reset)
+	}
+	return nil
+}
+
+func (s *stalledStream) RemotePeer() peer.ID {
+	return s.pid
+}
+
+func (s *stalledStream) Protocol() protocol.ID {
+	return GossipSubID_v11
+}
+
+type writeTimeoutTracer struct {
+	mx    sync.Mutex
+	peers []peer.ID
+}
+
+func (t *writeTimeoutTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_WRITE_TIMEOUT {
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.peers = append(t.peers, peer.ID(evt.GetWriteTimeout().GetPeerID()))
+}
+
+func (t *writeTimeoutTracer) get() []peer.ID {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return append([]peer.ID(nil), t.peers...)
+}
+
+func TestStreamWriteTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	tracer := &writeTimeoutTracer{}
+	ps := getPubsub(ctx, hosts[0],
+		WithStreamWriteTimeout(100*time.Millisecond),
+		WithEventTracer(tracer))
+
+	pid := hosts[1].ID()
+	s := newStalledStream(pid)
+
+	outgoing := make(chan *RPC, 10)
+	for i := 0; i < cap(outgoing); i++ {
+		outgoing <- rpcWithMessages(&pb.Message{Data: []byte("hello")})
+	}
+
+	// run the writer and the dead peer detector as they are run for a new peer
+	w := newPeerWriter(ctx, pid)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		ps.handleSendingMessages(w, s, outgoing)
+	}()
+	go func() {
+		defer wg.Done()
+		ps.handlePeerDead(w, s)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writer for a stalled stream did not return")
+	}
+
+	if !s.isReset {
+		t.Fatal("expected the stalled stream to be reset")
+	}
+	if n := len(outgoing); n != 0 {
+		t.Fatalf("expected the outbound queue to be cleared, but it has %d rpcs", n)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if peers := tracer.get(); len(peers) != 1 || peers[0] != pid {
+		t.Fatalf("expected a write timeout trace for %s, got %v", pid, peers)
+	}
+}
+
+func TestStreamWriteTimeoutDisabled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0])
+
+	s := newStalledStream(peer.ID("stalled"))
+	outgoing := make(chan *RPC, 1)
+	outgoing <- rpcWithMessages(&pb.Message{Data: []byte("hello")})
+
+	done := make(chan struct{})
+	go func() {
+		ps.handleSendingMessages(newPeerWriter(ctx, s.pid), s, outgoing)
+		close(done)
+	}()
+
+	// without a deadline the writer blocks until the stream is reset
+	select {
+	case <-done:
+		t.Fatal("writer returned without a write deadline")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	s.Reset()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writer did not return after the stream was reset")
+	}
+}
+
+func TestStreamWriteTimeoutOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	if _, err := NewFloodSub(ctx, hosts[0], WithStreamWriteTimeout(-time.Second)); err == nil {
+		t.Fatal("expected an error for a negative write timeout")
+	}
+	if _, err := NewFloodSub(ctx, hosts[0], WithStreamWriteTimeoutPenalty(-1)); err == nil {
+		t.Fatal("expected an error for a negative write timeout penalty")
+	}
+}
@@ -0,0 +1,221 @@
+package pubsub
+
+import (
+	"fmt"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// WithDisconnectGracePeriod debounces the connection events of flappy peers: when a peer
+// disconnects, it is only removed from the router once it has stayed disconnected for the grace
+// period, and if it reconnects within the grace period we open a new stream to it without the
+// router noticing. Likewise, a peer that has reconnected by the time we notice that its stream
+// died gets a new stream without being removed from the router. This avoids the churn of the
+// announcements, the mesh repairs, the connection manager tags and the peer scores that a
+// remove/add cycle causes.
+// While the peer is away, the messages routed to it are queued for the new stream, up to the
+// outbound queue size, and its subscriptions are kept until its hello packet on the new stream
+// tells us its current subscriptions. See also ConnectionDebounceStats.
+func WithDisconnectGracePeriod(grace time.Duration) Option {
+	return func(ps *PubSub) error {
+		if grace <= 0 {
+			return fmt.Errorf("invalid disconnect grace period; must be positive")
+		}
+
+		ps.connDebounce = &connDebounce{
+			grace:   grace,
+			pending: make(map[peer.ID]*clock.Timer),
+			resync:  make(map[peer.ID]struct{}),
+		}
+		return nil
+	}
+}
+
+// ConnectionDebounceStats counts the connection events debounced with WithDisconnectGracePeriod.
+type ConnectionDebounceStats struct {
+	// Deferred is the number of disconnects whose processing was deferred for the grace period.
+	Deferred uint64
+	// Cancelled is the number of deferred disconnects cancelled by the peer reconnecting within
+	// the grace period.
+	Cancelled uint64
+	// Coalesced is the number of peers that had reconnected by the time we noticed their stream
+	// died.
+	Coalesced uint64
+	// Expired is the number of peers removed after staying disconnected for the grace period.
+	Expired uint64
+	// Pending is the number of peers currently disconnected within the grace period.
+	Pending int
+}
+
+// ConnectionDebounceStats returns the connection events debounced so far. It returns false if
+// the connection events are not debounced.
+func (p *PubSub) ConnectionDebounceStats() (ConnectionDebounceStats, bool) {
+	cd := p.connDebounce
+	if cd == nil {
+		return ConnectionDebounceStats{}, false
+	}
+
+	out := make(chan ConnectionDebounceStats, 1)
+	select {
+	case p.eval <- func() {
+		out <- ConnectionDebounceStats{
+			Deferred:  cd.deferred,
+			Cancelled: cd.cancelled,
+			Coalesced: cd.coalesced,
+			Expired:   cd.expired,
+			Pending:   len(cd.pending),
+		}
+	}:
+		return <-out, true
+	case <-p.ctx.Done():
+		return ConnectionDebounceStats{}, false
+	}
+}
+
+// connDebounce tracks the peers whose connection events are being debounced; it is only accessed
+// from the event loop, and a nil connDebounce debounces nothing.
+type connDebounce struct {
+	grace time.Duration
+
+	// the peers whose disconnect is deferred, with the timer of their removal
+	pending map[peer.ID]*clock.Timer
+	// the peers that have kept their subscriptions over a stream failure, until their next hello
+	// packet
+	resync map[peer.ID]struct{}
+
+	deferred, cancelled, coalesced, expired uint64
+}
+
+// debounceDeadPeer handles a peer whose stream died, after its writer has been torn down. It
+// returns false if the peer must be forgotten.
+// Only called from processLoop.
+func (p *PubSub) debounceDeadPeer(pid peer.ID) bool {
+	cd := p.connDebounce
+	if cd == nil {
+		return false
+	}
+
+	if p.tr.Connected(pid) {
+		backoff, err := p.deadPeerBackoff.updateAndGet(pid)
+		if err != nil {
+			p.logger.Debugw("not respawning writer for dead peer", "peer", pid, "err", err)
+			return false
+		}
+
+		p.logger.Debugw("peer declared dead but reconnected; respawning writer", "peer", pid)
+		cd.coalesced++
+		cd.resync[pid] = struct{}{}
+		w, messages := p.newWriter(pid)
+		go p.handleNewPeerWithBackoff(w, backoff, messages)
+		return true
+	}
+
+	// the writer is only started if the peer reconnects, and queues the messages routed to the
+	// peer in the meantime
+	p.logger.Debugw("peer disconnected; deferring removal", "peer", pid, "grace", cd.grace)
+	cd.deferred++
+	cd.resync[pid] = struct{}{}
+	p.newWriter(pid)
+
+	var timer *clock.Timer
+	timer = p.clock.AfterFunc(cd.grace, func() {
+		select {
+		case p.eval <- func() {
+			if cd.pending[pid] == timer {
+				p.expireDisconnect(pid)
+			}
+		}:
+		case <-p.ctx.Done():
+		}
+	})
+	cd.pending[pid] = timer
+	return true
+}
+
+// expireDisconnect removes a peer that has stayed disconnected for the grace period.
+// Only called from processLoop.
+func (p *PubSub) expireDisconnect(pid peer.ID) {
+	cd := p.connDebounce
+	delete(cd.pending, pid)
+
+	ch, ok := p.peers[pid]
+	if !ok {
+		// the peer has been removed in the meantime
+		delete(cd.resync, pid)
+		return
+	}
+
+	p.logger.Debugw("peer stayed disconnected; removing peer", "peer", pid)
+	cd.expired++
+	close(ch)
+	delete(p.peers, pid)
+	p.removeWriter(pid)
+	p.forgetPeer(pid)
+}
+
+// reconnectPeer starts the writer of a peer that reconnected within the grace period. It returns
+// false if the disconnect of the peer is not deferred.
+// Only called from processLoop.
+func (p *PubSub) reconnectPeer(pid peer.ID) bool {
+	cd := p.connDebounce
+	if cd == nil {
+		return false
+	}
+	timer, ok := cd.pending[pid]
+	if !ok {
+		return false
+	}
+
+	p.logger.Debugw("peer reconnected within the grace period", "peer", pid)
+	timer.Stop()
+	delete(cd.pending, pid)
+	cd.cancelled++
+	go p.handleNewPeer(p.writers[pid], p.peers[pid])
+	return true
+}
+
+// resyncSubscriptions removes the topics that a peer which kept its subscriptions over a stream
+// failure no longer subscribes to, on receiving its hello packet.
+// Only called from processLoop.
+func (p *PubSub) resyncSubscriptions(pid peer.ID, subs []*pb.RPC_SubOpts) {
+	cd := p.connDebounce
+	if cd == nil {
+		return
+	}
+	if _, ok := cd.resync[pid]; !ok {
+		return
+	}
+	delete(cd.resync, pid)
+
+	subscribed := make(map[string]struct{}, len(subs))
+	for _, sub := range subs {
+		if sub.GetSubscribe() {
+			subscribed[sub.GetTopicid()] = struct{}{}
+		}
+	}
+	for t, tmap := range p.topics {
+		if _, ok := tmap[pid]; !ok {
+			continue
+		}
+		if _, ok := subscribed[t]; !ok {
+			p.handleSubscription(pid, t, false)
+		}
+	}
+}
+
+// forgetPeer stops debouncing the connection events of a peer being removed.
+func (cd *connDebounce) forgetPeer(pid peer.ID) {
+	if cd == nil {
+		return
+	}
+
+	if timer, ok := cd.pending[pid]; ok {
+		timer.Stop()
+		delete(cd.pending, pid)
+	}
+	delete(cd.resync, pid)
+}
@@ -0,0 +1,171 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+// peerChurnTracer counts the router events for peers that a flapping peer churns.
+type peerChurnTracer struct {
+	mx     sync.Mutex
+	counts map[pb.TraceEvent_Type]int
+}
+
+func (t *peerChurnTracer) Trace(evt *pb.TraceEvent) {
+	switch evt.GetType() {
+	case pb.TraceEvent_ADD_PEER, pb.TraceEvent_REMOVE_PEER, pb.TraceEvent_GRAFT, pb.TraceEvent_PRUNE:
+		t.mx.Lock()
+		t.counts[evt.GetType()]++
+		t.mx.Unlock()
+	}
+}
+
+func (t *peerChurnTracer) count(typ pb.TraceEvent_Type) int {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.counts[typ]
+}
+
+func TestDisconnectGracePeriod(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	tracer := &peerChurnTracer{counts: make(map[pb.TraceEvent_Type]int)}
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0], WithDisconnectGracePeriod(time.Minute), WithEventTracer(tracer)),
+		getGossipsub(ctx, hosts[1], WithDisconnectGracePeriod(time.Minute)),
+	}
+
+	// the grace period is virtual
+	clk := clock.NewMock()
+	psubs[0].clock = clk
+
+	var subs []*Subscription
+	for _, ps := range psubs {
+		sub, err := ps.Subscribe("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(2 * time.Second)
+
+	if n := tracer.count(pb.TraceEvent_ADD_PEER); n != 1 {
+		t.Fatalf("expected the peer to be added once, got %d", n)
+	}
+	grafts := tracer.count(pb.TraceEvent_GRAFT)
+	if grafts == 0 {
+		t.Fatal("expected the peer to be grafted")
+	}
+
+	// the peer flaps
+	const flaps = 5
+	for i := 0; i < flaps; i++ {
+		hosts[0].Network().ClosePeer(hosts[1].ID())
+		time.Sleep(100 * time.Millisecond)
+		connect(t, hosts[0], hosts[1])
+		time.Sleep(200 * time.Millisecond)
+	}
+	time.Sleep(time.Second)
+
+	// the router didn't notice
+	if n := tracer.count(pb.TraceEvent_ADD_PEER); n != 1 {
+		t.Fatalf("expected the peer to be added once, got %d", n)
+	}
+	if n := tracer.count(pb.TraceEvent_REMOVE_PEER); n != 0 {
+		t.Fatalf("expected the peer not to be removed, got %d removals", n)
+	}
+	// and neither did the tag tracer, which follows the mesh
+	if n := tracer.count(pb.TraceEvent_PRUNE); n != 0 {
+		t.Fatalf("expected the peer not to be pruned, got %d prunes", n)
+	}
+	if n := tracer.count(pb.TraceEvent_GRAFT); n != grafts {
+		t.Fatalf("expected the peer not to be grafted again, got %d grafts", n-grafts)
+	}
+
+	stats, ok := psubs[0].ConnectionDebounceStats()
+	if !ok {
+		t.Fatal("expected debounce stats")
+	}
+	if stats.Deferred+stats.Coalesced != flaps || stats.Cancelled != stats.Deferred || stats.Expired != 0 || stats.Pending != 0 {
+		t.Fatalf("unexpected debounce stats %+v", stats)
+	}
+
+	// the streams are fresh
+	for i, ps := range psubs {
+		if err := ps.Publish("foobar", []byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		expectMessage(t, ctx, subs[1-i], "hello")
+	}
+
+	// a peer that stays disconnected is removed after the grace period
+	hosts[0].Network().ClosePeer(hosts[1].ID())
+	time.Sleep(100 * time.Millisecond)
+	if n := tracer.count(pb.TraceEvent_REMOVE_PEER); n != 0 {
+		t.Fatalf("expected the removal to be deferred, got %d removals", n)
+	}
+	if stats, _ := psubs[0].ConnectionDebounceStats(); stats.Pending != 1 {
+		t.Fatalf("expected a pending disconnect, got %+v", stats)
+	}
+
+	clk.Add(time.Minute)
+	time.Sleep(100 * time.Millisecond)
+	if n := tracer.count(pb.TraceEvent_REMOVE_PEER); n != 1 {
+		t.Fatalf("expected the peer to be removed, got %d removals", n)
+	}
+	if stats, _ := psubs[0].ConnectionDebounceStats(); stats.Expired != 1 || stats.Pending != 0 {
+		t.Fatalf("expected the disconnect to expire, got %+v", stats)
+	}
+}
+
+// Test that a peer that comes back within the grace period with fewer subscriptions is resynced
+// from its hello packet.
+func TestDisconnectGracePeriodResync(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0], WithDisconnectGracePeriod(time.Minute)),
+		getGossipsub(ctx, hosts[1]),
+	}
+
+	subs := make(map[string]*Subscription)
+	for _, topic := range []string{"foo", "bar"} {
+		sub, err := psubs[1].Subscribe(topic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs[topic] = sub
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	hosts[0].Network().ClosePeer(hosts[1].ID())
+	time.Sleep(100 * time.Millisecond)
+
+	// the unsubscription is not announced to the disconnected peer
+	subs["bar"].Cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	if peers := psubs[0].ListPeers("foo"); len(peers) != 1 {
+		t.Fatalf("expected the peer to be kept in foo, got %v", peers)
+	}
+	if peers := psubs[0].ListPeers("bar"); len(peers) != 0 {
+		t.Fatalf("expected the peer to be removed from bar, got %v", peers)
+	}
+}
@@ -2,6 +2,7 @@ package pubsub
 
 import (
 	"context"
+	"fmt"
 	"math/rand"
 	"time"
 
@@ -88,6 +89,10 @@ func (d *discover) Start(p *PubSub, opts ...DiscoverOpt) error {
 		return nil
 	}
 
+	if p.host == nil {
+		return fmt.Errorf("discovery requires a libp2p host")
+	}
+
 	d.p = p
 	d.advertising = make(map[string]context.CancelFunc)
 	d.discoverQ = make(chan *discoverReq, 32)
@@ -190,7 +195,7 @@ func (d *discover) Advertise(topic string) {
 	go func() {
 		next, err := d.discovery.Advertise(advertisingCtx, topic)
 		if err != nil {
-			log.Warnf("bootstrap: error providing rendezvous for %s: %s", topic, err.Error())
+			d.p.logger.Warnw("bootstrap: error providing rendezvous", "topic", topic, "err", err)
 			if next == 0 {
 				next = discoveryAdvertiseRetryInterval
 			}
@@ -204,7 +209,7 @@ func (d *discover) Advertise(topic string) {
 			case <-t.C:
 				next, err = d.discovery.Advertise(advertisingCtx, topic)
 				if err != nil {
-					log.Warnf("bootstrap: error providing rendezvous for %s: %s", topic, err.Error())
+					d.p.logger.Warnw("bootstrap: error providing rendezvous", "topic", topic, "err", err)
 					if next == 0 {
 						next = discoveryAdvertiseRetryInterval
 					}
@@ -302,7 +307,7 @@ func (d *discover) handleDiscovery(ctx context.Context, topic string, opts []dis
 
 	peerCh, err := d.discovery.FindPeers(discoverCtx, topic, opts...)
 	if err != nil {
-		log.Debugf("error finding peers for topic %s: %v", topic, err)
+		d.p.logger.Debugw("error finding peers", "topic", topic, "err", err)
 		return
 	}
 
@@ -0,0 +1,157 @@
+package pubsub
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// WithDuplicateTracking tracks the duplicate copies of the messages we receive, while their ids
+// are in the seen messages cache, recording up to senders peers that sent a duplicate copy of
+// each message. The number of duplicates received before delivery is reported in the Duplicates
+// field of delivered messages, and the duplicates received so far by DuplicateCount and
+// DuplicateSenders.
+func WithDuplicateTracking(senders int) Option {
+	return func(p *PubSub) error {
+		if senders < 0 {
+			return fmt.Errorf("invalid number of duplicate senders; must be non-negative")
+		}
+		p.dupStats = newDupStats(p.idGen, senders)
+		return nil
+	}
+}
+
+// DuplicateCount returns the number of duplicate copies of a message received so far, and whether
+// the message is known; messages are known from their delivery or their first duplicate, for
+// the lifetime of their id in the seen messages cache. It always returns false if duplicate
+// tracking is not enabled with WithDuplicateTracking.
+func (p *PubSub) DuplicateCount(msgID string) (int, bool) {
+	if p.dupStats == nil {
+		return 0, false
+	}
+
+	p.dupStats.mx.Lock()
+	defer p.dupStats.mx.Unlock()
+
+	p.dupStats.expire(time.Now())
+	d, ok := p.dupStats.msgs[msgID]
+	if !ok {
+		return 0, false
+	}
+	return d.count, true
+}
+
+// DuplicateSenders returns the first peers that sent a duplicate copy of a message, up to the
+// number set with WithDuplicateTracking.
+func (p *PubSub) DuplicateSenders(msgID string) []peer.ID {
+	if p.dupStats == nil {
+		return nil
+	}
+
+	p.dupStats.mx.Lock()
+	defer p.dupStats.mx.Unlock()
+
+	p.dupStats.expire(time.Now())
+	d, ok := p.dupStats.msgs[msgID]
+	if !ok {
+		return nil
+	}
+	return append([]peer.ID(nil), d.senders...)
+}
+
+// dupStats is a raw tracer counting the duplicate copies of messages.
+type dupStats struct {
+	idGen      *msgIDGenerator
+	maxSenders int
+	// the lifetime of the entries; the TTL of the seen messages cache
+	ttl time.Duration
+
+	mx   sync.Mutex
+	msgs map[string]*dupEntry
+	// the entries in the order they were created, for their expiry
+	order []*dupEntry
+}
+
+type dupEntry struct {
+	id      string
+	created time.Time
+	count   int
+	senders []peer.ID
+}
+
+func newDupStats(idGen *msgIDGenerator, senders int) *dupStats {
+	return &dupStats{
+		idGen:      idGen,
+		maxSenders: senders,
+		msgs:       make(map[string]*dupEntry),
+	}
+}
+
+// entry returns the entry of a message, creating it if needed; it assumes the lock is held.
+func (d *dupStats) entry(id string) *dupEntry {
+	now := time.Now()
+	d.expire(now)
+
+	e, ok := d.msgs[id]
+	if !ok {
+		e = &dupEntry{id: id, created: now}
+		d.msgs[id] = e
+		d.order = append(d.order, e)
+	}
+	return e
+}
+
+// expire drops the entries of the messages no longer in the seen messages cache; it assumes the
+// lock is held.
+func (d *dupStats) expire(now time.Time) {
+	n := 0
+	for n < len(d.order) && now.Sub(d.order[n].created) > d.ttl {
+		delete(d.msgs, d.order[n].id)
+		d.order[n] = nil
+		n++
+	}
+	if n > 0 {
+		d.order = d.order[n:]
+	}
+}
+
+// delivered returns the number of duplicates of a message received before its delivery.
+func (d *dupStats) delivered(id string) int {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	return d.entry(id).count
+}
+
+var _ RawTracer = (*dupStats)(nil)
+
+func (d *dupStats) DuplicateMessage(msg *Message) {
+	id := d.idGen.ID(msg)
+
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	e := d.entry(id)
+	e.count++
+	if len(e.senders) < d.maxSenders {
+		e.senders = append(e.senders, msg.ReceivedFrom)
+	}
+}
+
+func (d *dupStats) AddPeer(p peer.ID, proto protocol.ID)      {}
+func (d *dupStats) RemovePeer(p peer.ID)                      {}
+func (d *dupStats) Join(topic string)                         {}
+func (d *dupStats) Leave(topic string)                        {}
+func (d *dupStats) Graft(p peer.ID, topic string)             {}
+func (d *dupStats) Prune(p peer.ID, topic string)             {}
+func (d *dupStats) ValidateMessage(msg *Message)              {}
+func (d *dupStats) DeliverMessage(msg *Message)               {}
+func (d *dupStats) RejectMessage(msg *Message, reason string) {}
+func (d *dupStats) ThrottlePeer(p peer.ID)                    {}
+func (d *dupStats) RecvRPC(rpc *RPC)                          {}
+func (d *dupStats) SendRPC(rpc *RPC, p peer.ID)               {}
+func (d *dupStats) DropRPC(rpc *RPC, p peer.ID)               {}
+func (d *dupStats) UndeliverableMessage(msg *Message)         {}
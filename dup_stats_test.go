@@ -0,0 +1,96 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestDuplicateTracking(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 4)
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0], WithDuplicateTracking(1)),
+		getGossipsub(ctx, hosts[1]),
+		getGossipsub(ctx, hosts[2]),
+		getGossipsub(ctx, hosts[3]),
+	}
+
+	// the validation holds the message, so that the duplicates arrive before delivery
+	err := psubs[0].RegisterTopicValidator("foobar", func(context.Context, peer.ID, *Message) bool {
+		time.Sleep(500 * time.Millisecond)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var subs []*Subscription
+	for _, ps := range psubs {
+		sub, err := ps.Subscribe("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+
+	// the message is published by the first relay, and reaches us via the three relays
+	for _, h := range hosts[1:] {
+		connect(t, hosts[0], h)
+	}
+	connect(t, hosts[1], hosts[2])
+	connect(t, hosts[1], hosts[3])
+	time.Sleep(2 * time.Second)
+
+	if err := psubs[1].Publish("foobar", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+	defer rcancel()
+	msg, err := subs[0].Next(rctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Duplicates != 2 {
+		t.Fatalf("expected 2 duplicates before delivery, got %d", msg.Duplicates)
+	}
+
+	if n, ok := psubs[0].DuplicateCount(msg.ID); !ok || n != 2 {
+		t.Fatalf("expected 2 duplicates, got %d (known: %t)", n, ok)
+	}
+	senders := psubs[0].DuplicateSenders(msg.ID)
+	if len(senders) != 1 || senders[0] == hosts[0].ID() || senders[0] == msg.ReceivedFrom {
+		t.Fatalf("unexpected duplicate senders: %v", senders)
+	}
+
+	if _, ok := psubs[0].DuplicateCount("unknown"); ok {
+		t.Fatal("expected an unknown message")
+	}
+	if _, ok := psubs[1].DuplicateCount(msg.ID); ok {
+		t.Fatal("expected no duplicate counts without duplicate tracking")
+	}
+}
+
+func TestDuplicateTrackingExpiry(t *testing.T) {
+	d := newDupStats(newMsgIdGenerator(), 0)
+	d.ttl = 100 * time.Millisecond
+
+	d.mx.Lock()
+	d.entry("a").count++
+	d.mx.Unlock()
+	time.Sleep(150 * time.Millisecond)
+	if n := d.delivered("b"); n != 0 {
+		t.Fatalf("expected no duplicates, got %d", n)
+	}
+
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	if _, ok := d.msgs["a"]; ok || len(d.order) != 1 {
+		t.Fatal("expected the entry to expire with the seen messages")
+	}
+}
@@ -0,0 +1,90 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+// envelopeMagic prefixes enveloped payloads, to tell them apart from opaque ones.
+var envelopeMagic = []byte{0x00, 'e', 'n', 'v'}
+
+// Envelope is the decoded envelope of a message published with Topic.PublishEnveloped: the
+// payload and the application metadata it was published with, such as its content type or
+// schema version.
+type Envelope struct {
+	Data     []byte
+	Metadata map[string]string
+}
+
+// envelopeResult caches the decoded envelope of a message.
+type envelopeResult struct {
+	env *Envelope
+	ok  bool
+}
+
+// PublishEnveloped publishes data to the topic, wrapped in an envelope with the given metadata;
+// subscribers and validators get the envelope with Message.Envelope. The envelope is an ordinary
+// payload, so nodes that don't use envelopes see opaque bytes, and the message ID is computed over
+// the wrapped payload.
+func (t *Topic) PublishEnveloped(ctx context.Context, data []byte, md map[string]string, opts ...PubOpt) error {
+	payload, err := encodeEnvelope(data, md)
+	if err != nil {
+		return err
+	}
+	return t.Publish(ctx, payload, opts...)
+}
+
+// Envelope returns the envelope of a message published with Topic.PublishEnveloped; it returns
+// false if the message is not enveloped. The envelope is decoded once and cached on the message,
+// and must not be modified.
+func (m *Message) Envelope() (*Envelope, bool) {
+	if res, ok := m.envelope.Load().(*envelopeResult); ok {
+		return res.env, res.ok
+	}
+
+	env, ok := decodeEnvelope(m.GetData())
+	m.envelope.Store(&envelopeResult{env: env, ok: ok})
+	return env, ok
+}
+
+func encodeEnvelope(data []byte, md map[string]string) ([]byte, error) {
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	env := &pb.Envelope{Data: data}
+	for _, k := range keys {
+		k, v := k, md[k]
+		env.Metadata = append(env.Metadata, &pb.Envelope_Metadata{Key: &k, Value: &v})
+	}
+
+	out := make([]byte, len(envelopeMagic)+env.Size())
+	copy(out, envelopeMagic)
+	if _, err := env.MarshalTo(out[len(envelopeMagic):]); err != nil {
+		return nil, fmt.Errorf("error encoding envelope: %w", err)
+	}
+	return out, nil
+}
+
+func decodeEnvelope(payload []byte) (*Envelope, bool) {
+	if !bytes.HasPrefix(payload, envelopeMagic) {
+		return nil, false
+	}
+
+	var env pb.Envelope
+	if err := env.Unmarshal(payload[len(envelopeMagic):]); err != nil {
+		return nil, false
+	}
+
+	res := &Envelope{Data: env.GetData(), Metadata: make(map[string]string, len(env.Metadata))}
+	for _, md := range env.Metadata {
+		res.Metadata[md.GetKey()] = md.GetValue()
+	}
+	return res, true
+}
@@ -0,0 +1,131 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestEnvelope(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "foobar"
+	hosts := getNetHosts(t, ctx, 3)
+	psubs := getGossipsubs(ctx, hosts)
+
+	md := map[string]string{"content-type": "application/json", "schema": "v2"}
+	data := []byte(`{"hello":"world"}`)
+
+	// validators can use the envelope
+	err := psubs[1].RegisterTopicValidator(topic, func(ctx context.Context, from peer.ID, msg *Message) bool {
+		env, ok := msg.Envelope()
+		return !ok || env.Metadata["schema"] == "v2"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topics := getTopics(psubs, topic)
+	var subs []*Subscription
+	for _, tp := range topics[1:] {
+		sub, err := tp.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+
+	connectAll(t, hosts)
+	time.Sleep(time.Second)
+
+	next := func(sub *Subscription) *Message {
+		t.Helper()
+		rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+		defer rcancel()
+		msg, err := sub.Next(rctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	if err := topics[0].PublishEnveloped(ctx, data, md); err != nil {
+		t.Fatal(err)
+	}
+
+	// the metadata round-trips
+	msg := next(subs[0])
+	env, ok := msg.Envelope()
+	if !ok {
+		t.Fatal("expected an enveloped message")
+	}
+	if !bytes.Equal(env.Data, data) || len(env.Metadata) != len(md) {
+		t.Fatalf("unexpected envelope %+v", env)
+	}
+	for k, v := range md {
+		if env.Metadata[k] != v {
+			t.Fatalf("expected metadata %s=%s, got %s", k, v, env.Metadata[k])
+		}
+	}
+	if env2, _ := msg.Envelope(); env2 != env {
+		t.Fatal("expected the envelope to be cached")
+	}
+
+	// consumers that don't use envelopes get the wrapped payload
+	msg = next(subs[1])
+	wrapped, err := encodeEnvelope(data, md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(msg.Data, wrapped) {
+		t.Fatalf("expected the wrapped payload, got %q", msg.Data)
+	}
+
+	// plain messages have no envelope
+	if err := topics[0].Publish(ctx, data); err != nil {
+		t.Fatal(err)
+	}
+	msg = next(subs[0])
+	if _, ok := msg.Envelope(); ok || !bytes.Equal(msg.Data, data) {
+		t.Fatalf("unexpected envelope for a plain message %q", msg.Data)
+	}
+
+	// the validator rejects other schema versions
+	if err := topics[0].PublishEnveloped(ctx, data, map[string]string{"schema": "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	rctx, rcancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer rcancel()
+	if msg, err := subs[0].Next(rctx); err == nil {
+		t.Fatalf("unexpected message %q", msg.Data)
+	}
+}
+
+func TestEnvelopeEncoding(t *testing.T) {
+	md := map[string]string{"a": "1", "b": "2", "c": "3"}
+	first, err := encodeEnvelope([]byte("data"), md)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the encoding is deterministic
+	for i := 0; i < 10; i++ {
+		out, err := encodeEnvelope([]byte("data"), md)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, first) {
+			t.Fatal("expected a deterministic encoding")
+		}
+	}
+
+	for _, payload := range [][]byte{nil, []byte("data"), append(append([]byte{}, envelopeMagic...), 0xff)} {
+		if _, ok := decodeEnvelope(payload); ok {
+			t.Fatalf("unexpected envelope in %q", payload)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package pubsub
+
+import (
+	"encoding/hex"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// FaultInjector injects faults in the RPCs exchanged with peers, for testing the behaviour of
+// applications under pubsub-level faults; see WithFaultInjector.
+// The hooks are invoked from the reader and writer goroutines of each peer, so they must be safe
+// for concurrent use.
+type FaultInjector interface {
+	// DropOutboundRPC is invoked before writing an RPC to a peer; the RPC is not written if it
+	// returns true. The RPC may be shared with other peers, and must not be modified.
+	DropOutboundRPC(to peer.ID, rpc *RPC) bool
+	// DelayInbound is invoked before processing each RPC read from a peer; the RPCs of the peer
+	// are held back for the returned duration.
+	DelayInbound(from peer.ID) time.Duration
+	// CorruptControl is invoked with each RPC read from a peer that carries control messages,
+	// before it is processed; it may modify the control messages, as if corrupted in transit.
+	CorruptControl(rpc *RPC)
+}
+
+// WithFaultInjector injects faults in the RPCs exchanged with peers with fi, for resilience
+// testing; faults are not injected by default.
+func WithFaultInjector(fi FaultInjector) Option {
+	return func(p *PubSub) error {
+		p.faults = fi
+		return nil
+	}
+}
+
+// ScriptedInjector is a FaultInjector that injects faults at random, with the configured
+// probabilities, in the RPCs exchanged with the selected peers and topics.
+type ScriptedInjector struct {
+	// DropRate is the probability of dropping an outbound RPC.
+	DropRate float64
+	// DelayRate is the probability of delaying an inbound RPC by Delay.
+	DelayRate float64
+	Delay     time.Duration
+	// CorruptRate is the probability of corrupting the control messages of an inbound RPC; the
+	// message IDs and topics of the control messages are replaced with random ones.
+	CorruptRate float64
+
+	// Peers selects the peers the faults are injected for; all peers are selected if nil.
+	Peers func(peer.ID) bool
+	// Topics selects the topics the faults are injected for; an RPC is selected if any of its
+	// messages, subscriptions or control messages is in a selected topic. All RPCs are selected if
+	// nil. Delays are injected regardless of the topics, as DelayInbound is not given the RPC.
+	Topics func(string) bool
+
+	// Seed seeds the random decisions, for reproducible faults; a zero seed uses the clock.
+	Seed int64
+
+	mx  sync.Mutex
+	rng *rand.Rand
+}
+
+var _ FaultInjector = (*ScriptedInjector)(nil)
+
+func (si *ScriptedInjector) DropOutboundRPC(to peer.ID, rpc *RPC) bool {
+	return si.selected(to, rpc) && si.roll(si.DropRate)
+}
+
+func (si *ScriptedInjector) DelayInbound(from peer.ID) time.Duration {
+	if si.selected(from, nil) && si.roll(si.DelayRate) {
+		return si.Delay
+	}
+	return 0
+}
+
+func (si *ScriptedInjector) CorruptControl(rpc *RPC) {
+	if !si.selected(rpc.from, rpc) || !si.roll(si.CorruptRate) {
+		return
+	}
+
+	ctl := rpc.Control
+	for _, ihave := range ctl.GetIhave() {
+		ihave.TopicID = si.garbageString()
+		for i := range ihave.MessageIDs {
+			ihave.MessageIDs[i] = *si.garbageString()
+		}
+	}
+	for _, iwant := range ctl.GetIwant() {
+		for i := range iwant.MessageIDs {
+			iwant.MessageIDs[i] = *si.garbageString()
+		}
+	}
+	for _, graft := range ctl.GetGraft() {
+		graft.TopicID = si.garbageString()
+	}
+	for _, prune := range ctl.GetPrune() {
+		prune.TopicID = si.garbageString()
+	}
+}
+
+// selected returns whether faults are injected for a peer and an RPC, if any.
+func (si *ScriptedInjector) selected(p peer.ID, rpc *RPC) bool {
+	if si.Peers != nil && !si.Peers(p) {
+		return false
+	}
+	if si.Topics == nil || rpc == nil {
+		return true
+	}
+
+	for _, msg := range rpc.GetPublish() {
+		if si.Topics(msg.GetTopic()) {
+			return true
+		}
+	}
+	for _, sub := range rpc.GetSubscriptions() {
+		if si.Topics(sub.GetTopicid()) {
+			return true
+		}
+	}
+	ctl := rpc.GetControl()
+	for _, ihave := range ctl.GetIhave() {
+		if si.Topics(ihave.GetTopicID()) {
+			return true
+		}
+	}
+	for _, graft := range ctl.GetGraft() {
+		if si.Topics(graft.GetTopicID()) {
+			return true
+		}
+	}
+	for _, prune := range ctl.GetPrune() {
+		if si.Topics(prune.GetTopicID()) {
+			return true
+		}
+	}
+	return false
+}
+
+// roll returns true with probability rate.
+func (si *ScriptedInjector) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	si.mx.Lock()
+	defer si.mx.Unlock()
+	return si.random().Float64() < rate
+}
+
+func (si *ScriptedInjector) garbageString() *string {
+	buf := make([]byte, 8)
+
+	si.mx.Lock()
+	si.random().Read(buf)
+	si.mx.Unlock()
+
+	s := hex.EncodeToString(buf)
+	return &s
+}
+
+// random returns the random source; it assumes the lock is held.
+func (si *ScriptedInjector) random() *rand.Rand {
+	if si.rng == nil {
+		seed := si.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		si.rng = rand.New(rand.NewSource(seed))
+	}
+	return si.rng
+}
+
+// injectInbound injects the inbound faults in an RPC read from a peer; it returns false if the
+// PubSub is closed while the RPC is held back.
+func (p *PubSub) injectInbound(rpc *RPC) bool {
+	if rpc.Control != nil {
+		p.faults.CorruptControl(rpc)
+	}
+
+	delay := p.faults.DelayInbound(rpc.from)
+	if delay <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-p.ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,166 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type countingInjector struct {
+	*ScriptedInjector
+	dropped int64
+}
+
+func (ci *countingInjector) DropOutboundRPC(to peer.ID, rpc *RPC) bool {
+	if ci.ScriptedInjector.DropOutboundRPC(to, rpc) {
+		atomic.AddInt64(&ci.dropped, 1)
+		return true
+	}
+	return false
+}
+
+// seqnoInjector is a FaultInjector rewriting the sequence numbers of the messages in the RPCs with
+// control messages.
+type seqnoInjector struct {
+	ScriptedInjector
+}
+
+func (si *seqnoInjector) CorruptControl(rpc *RPC) {
+	for _, pmsg := range rpc.GetPublish() {
+		pmsg.Seqno = []byte("corrupted")
+	}
+}
+
+// Test that the IDs of the messages received from peers are computed after injecting the inbound
+// faults, which may modify the messages.
+func TestFaultInjectionMessageID(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0], WithMessageSignaturePolicy(LaxNoSign), WithFaultInjector(&seqnoInjector{}), WithSeenMessagesShards(16))
+	if _, err := ps.Subscribe("foobar"); err != nil {
+		t.Fatal(err)
+	}
+
+	topic := "foobar"
+	msg := &pb.Message{From: []byte("author"), Seqno: []byte("1"), Topic: &topic, Data: []byte("hello")}
+	rpc := rpcWithControl([]*pb.Message{msg}, nil, nil, []*pb.ControlGraft{{TopicID: &topic}}, nil)
+	rpc.from = "peer"
+
+	if !ps.prepareIncoming(rpc) {
+		t.Fatal("expected the RPC to be prepared")
+	}
+	if id := rpc.id(0); id != DefaultMsgIdFn(msg) || string(msg.GetSeqno()) != "corrupted" {
+		t.Fatalf("expected the ID of the corrupted message, got %q", id)
+	}
+}
+
+// Test that gossipsub tolerates the loss of a fifth of the RPCs, recovering the lost messages
+// through gossip.
+func TestGossipsubFaultInjectionDrop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 10)
+	var psubs []*PubSub
+	var injectors []*countingInjector
+	for i, h := range hosts {
+		fi := &countingInjector{ScriptedInjector: &ScriptedInjector{DropRate: 0.2, Seed: int64(i + 1)}}
+		psubs = append(psubs, getGossipsub(ctx, h, WithFaultInjector(fi)))
+		injectors = append(injectors, fi)
+	}
+
+	var subs []*Subscription
+	for _, ps := range psubs {
+		sub, err := ps.Subscribe("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+
+	denseConnect(t, hosts)
+	time.Sleep(2 * time.Second)
+
+	for i := 0; i < 20; i++ {
+		msg := []byte(fmt.Sprintf("message %d", i))
+		if err := psubs[rand.Intn(len(psubs))].Publish("foobar", msg); err != nil {
+			t.Fatal(err)
+		}
+
+		for j, sub := range subs {
+			rctx, rcancel := context.WithTimeout(ctx, 10*time.Second)
+			got, err := sub.Next(rctx)
+			rcancel()
+			if err != nil {
+				t.Fatalf("peer %d didn't receive message %d: %s", j, i, err)
+			}
+			if string(got.Data) != string(msg) {
+				t.Fatalf("peer %d received %s instead of %s", j, got.Data, msg)
+			}
+		}
+	}
+
+	var dropped int64
+	for _, fi := range injectors {
+		dropped += atomic.LoadInt64(&fi.dropped)
+	}
+	if dropped == 0 {
+		t.Fatal("expected RPCs to be dropped")
+	}
+}
+
+func TestScriptedInjector(t *testing.T) {
+	selected := peer.ID("selected")
+	topic := "foobar"
+	si := &ScriptedInjector{
+		DropRate:    1,
+		DelayRate:   1,
+		Delay:       time.Second,
+		CorruptRate: 1,
+		Peers:       func(p peer.ID) bool { return p == selected },
+		Topics:      func(t string) bool { return t == topic },
+		Seed:        1,
+	}
+
+	msg := rpcWithMessages(&pb.Message{Topic: &topic})
+	other := "other"
+	otherMsg := rpcWithMessages(&pb.Message{Topic: &other})
+
+	if !si.DropOutboundRPC(selected, msg) {
+		t.Fatal("expected the RPC to be dropped")
+	}
+	if si.DropOutboundRPC("other", msg) {
+		t.Fatal("expected the RPC to another peer not to be dropped")
+	}
+	if si.DropOutboundRPC(selected, otherMsg) {
+		t.Fatal("expected the RPC in another topic not to be dropped")
+	}
+	if d := si.DelayInbound(selected); d != time.Second {
+		t.Fatalf("expected the RPCs from the peer to be delayed, got %s", d)
+	}
+	if d := si.DelayInbound("other"); d != 0 {
+		t.Fatalf("expected the RPCs from another peer not to be delayed, got %s", d)
+	}
+
+	ctl := rpcWithControl(nil, []*pb.ControlIHave{{TopicID: &topic, MessageIDs: []string{"msg"}}}, nil, nil, nil)
+	ctl.from = selected
+	si.CorruptControl(ctl)
+	if ihave := ctl.Control.Ihave[0]; ihave.GetTopicID() == topic || ihave.MessageIDs[0] == "msg" {
+		t.Fatalf("expected the control message to be corrupted, got %v", ihave)
+	}
+
+	// nothing is injected without rates
+	si = &ScriptedInjector{}
+	if si.DropOutboundRPC(selected, msg) || si.DelayInbound(selected) != 0 {
+		t.Fatal("expected no faults without rates")
+	}
+}
@@ -2,6 +2,7 @@ package pubsub
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -26,10 +27,63 @@ func NewFloodSub(ctx context.Context, h host.Host, opts ...Option) (*PubSub, err
 	return NewFloodsubWithProtocols(ctx, h, []protocol.ID{FloodSubID}, opts...)
 }
 
+// NewFloodSubWithTransport returns a new PubSub object running on a custom transport, using the
+// FloodSubRouter; see NewPubSubWithTransport.
+func NewFloodSubWithTransport(ctx context.Context, tr Transport, opts ...Option) (*PubSub, error) {
+	rt := &FloodSubRouter{
+		protocols: []protocol.ID{FloodSubID},
+	}
+	return NewPubSubWithTransport(ctx, tr, rt, opts...)
+}
+
+// WithFloodSubQueueHighWater is a floodsub router option that skips the peers whose outbound
+// queue holds highWater RPCs or more when routing a message, instead of filling their queue up,
+// so that slow peers shed load early; the skipped peers are traced with a DropRPC event.
+func WithFloodSubQueueHighWater(highWater int) Option {
+	return func(ps *PubSub) error {
+		fs, ok := ps.rt.(*FloodSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not floodsub")
+		}
+		if highWater <= 0 {
+			return fmt.Errorf("invalid outbound queue high-water mark; must be positive")
+		}
+
+		fs.highWater = highWater
+		return nil
+	}
+}
+
+// WithFloodSubPeerMaxMessageSize is a floodsub router option that doesn't route messages larger
+// than maxSize bytes to the peers that speak the floodsub protocol: unlike gossipsub peers, they
+// can't ask for them later with IWANT, so the messages would only be pushed to them eagerly.
+func WithFloodSubPeerMaxMessageSize(maxSize int) Option {
+	return func(ps *PubSub) error {
+		fs, ok := ps.rt.(*FloodSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not floodsub")
+		}
+		if maxSize <= 0 {
+			return fmt.Errorf("invalid floodsub peer max message size; must be positive")
+		}
+
+		fs.peerMaxMessageSize = maxSize
+		return nil
+	}
+}
+
 type FloodSubRouter struct {
 	p         *PubSub
 	protocols []protocol.ID
 	tracer    *pubsubTracer
+
+	// the protocol of each peer
+	peers map[peer.ID]protocol.ID
+
+	// outbound queue length at which we skip a peer; 0 disables the high-water mark
+	highWater int
+	// max size of the messages we route to floodsub peers; 0 disables the limit
+	peerMaxMessageSize int
 }
 
 func (fs *FloodSubRouter) Protocols() []protocol.ID {
@@ -39,14 +93,17 @@ func (fs *FloodSubRouter) Protocols() []protocol.ID {
 func (fs *FloodSubRouter) Attach(p *PubSub) {
 	fs.p = p
 	fs.tracer = p.tracer
+	fs.peers = make(map[peer.ID]protocol.ID)
 }
 
 func (fs *FloodSubRouter) AddPeer(p peer.ID, proto protocol.ID) {
 	fs.tracer.AddPeer(p, proto)
+	fs.peers[p] = proto
 }
 
 func (fs *FloodSubRouter) RemovePeer(p peer.ID) {
 	fs.tracer.RemovePeer(p)
+	delete(fs.peers, p)
 }
 
 func (fs *FloodSubRouter) EnoughPeers(topic string, suggested int) bool {
@@ -74,10 +131,24 @@ func (fs *FloodSubRouter) AcceptFrom(peer.ID) AcceptStatus {
 func (fs *FloodSubRouter) HandleRPC(rpc *RPC) {}
 
 func (fs *FloodSubRouter) Publish(msg *Message) {
+	fs.publish(msg, nil)
+}
+
+func (fs *FloodSubRouter) publish(msg *Message, res *PublishResult) {
+	fs.route(msg, res)
+}
+
+func (fs *FloodSubRouter) route(msg *Message, res *PublishResult) {
 	from := msg.ReceivedFrom
 	topic := msg.GetTopic()
 
+	var recipients []peer.ID
+	observe := fs.p.fwdObserver.observing()
+
+	large := fs.peerMaxMessageSize > 0 && msg.Size() > fs.peerMaxMessageSize
+
 	out := rpcWithMessages(msg.Message)
+	out.setExpiry(0, fs.p.forwardExpiry(msg))
 	for pid := range fs.p.topics[topic] {
 		if pid == from || pid == peer.ID(msg.GetFrom()) {
 			continue
@@ -88,15 +159,35 @@ func (fs *FloodSubRouter) Publish(msg *Message) {
 			continue
 		}
 
+		if large && fs.peers[pid] == FloodSubID {
+			fs.p.logger.Debugw("not routing large message to floodsub peer", "peer", pid, "topic", topic, "size", msg.Size())
+			continue
+		}
+
+		// shed load from slow peers before their queue fills up
+		if fs.highWater > 0 && len(mch) >= fs.highWater {
+			fs.p.logger.Debugw("skipping peer: outbound queue above the high-water mark", "peer", pid, "topic", topic, "queued", len(mch))
+			fs.tracer.DropRPC(out, pid)
+			res.addRecipient(pid, false)
+			continue
+		}
+
 		select {
 		case mch <- out:
 			fs.tracer.SendRPC(out, pid)
+			fs.p.enqueued(pid, mch)
+			res.addRecipient(pid, true)
+			if observe {
+				recipients = append(recipients, pid)
+			}
 		default:
-			log.Infof("dropping message to peer %s: queue full", pid)
+			fs.p.logger.Infow("dropping message to peer: queue full", "peer", pid, "topic", msg.GetTopic())
 			fs.tracer.DropRPC(out, pid)
+			res.addRecipient(pid, false)
 			// Drop it. The peer is too slow.
 		}
 	}
+	fs.p.fwdObserver.observe(msg, recipients)
 }
 
 func (fs *FloodSubRouter) Join(topic string) {
@@ -9,6 +9,7 @@ import (
 	"io"
 	"math/rand"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -1060,7 +1061,8 @@ func TestAnnounceRetry(t *testing.T) {
 
 	// wait a bit for the first subscription to be emitted and trigger announce retry
 	time.Sleep(100 * time.Millisecond)
-	go ps.announceRetry(hosts[1].ID(), "test", true)
+	topic, sub := "test", true
+	go ps.announceRetry(hosts[1].ID(), []*pb.RPC_SubOpts{{Topicid: &topic, Subscribe: &sub}})
 
 	// wait a bit for the subscription to propagate and ensure it was received twice
 	time.Sleep(time.Second + 100*time.Millisecond)
@@ -1107,6 +1109,168 @@ func (aw *announceWatcher) countSubs() int {
 	return aw.subs
 }
 
+func TestAnnounceBatching(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	ps := getPubsub(ctx, hosts[0], WithAnnounceDebounce(100*time.Millisecond))
+	watcher := &announceBatchWatcher{}
+	hosts[1].SetStreamHandler(FloodSubID, watcher.handleStream)
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	const count = 500
+	for i := 0; i < count; i++ {
+		if _, err := ps.Subscribe(fmt.Sprintf("topic-%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	rpcs := watcher.announcements()
+	if len(rpcs) != 1 {
+		t.Fatalf("expected 1 announcement, but got %d", len(rpcs))
+	}
+	if len(rpcs[0]) != count {
+		t.Fatalf("expected %d subscriptions in the announcement, but got %d", count, len(rpcs[0]))
+	}
+	for _, sub := range rpcs[0] {
+		if !sub.GetSubscribe() {
+			t.Fatalf("unexpected unsubscription from %s", sub.GetTopicid())
+		}
+	}
+}
+
+func TestAnnounceCoalescing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	ps := getPubsub(ctx, hosts[0], WithAnnounceDebounce(100*time.Millisecond))
+	watcher := &announceBatchWatcher{}
+	hosts[1].SetStreamHandler(FloodSubID, watcher.handleStream)
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	// subscribing to and unsubscribing from a topic within the window announces nothing
+	sub, err := ps.Subscribe("transient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.Subscribe("kept"); err != nil {
+		t.Fatal(err)
+	}
+	sub.Cancel()
+
+	time.Sleep(300 * time.Millisecond)
+	rpcs := watcher.announcements()
+	if len(rpcs) != 1 {
+		t.Fatalf("expected 1 announcement, but got %d", len(rpcs))
+	}
+	if len(rpcs[0]) != 1 || rpcs[0][0].GetTopicid() != "kept" || !rpcs[0][0].GetSubscribe() {
+		t.Fatalf("unexpected announcement %v", rpcs[0])
+	}
+}
+
+func TestAnnounceAfterGreeting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	ps := getPubsub(ctx, hosts[0], WithAnnounceDebounce(time.Second))
+	watcher := &announceBatchWatcher{}
+	hosts[1].SetStreamHandler(FloodSubID, watcher.handleStream)
+
+	// the peer is greeted within the window, with the pending subscription
+	sub, err := ps.Subscribe("early")
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	// the changes made after greeting the peer are still announced to it
+	if _, err := ps.Subscribe("late"); err != nil {
+		t.Fatal(err)
+	}
+	sub.Cancel()
+
+	time.Sleep(300 * time.Millisecond)
+	var changes []string
+	for _, rpc := range watcher.announcements() {
+		for _, subopt := range rpc {
+			changes = append(changes, fmt.Sprintf("%s:%t", subopt.GetTopicid(), subopt.GetSubscribe()))
+		}
+	}
+	if got := strings.Join(changes, " "); got != "early:true late:true early:false" {
+		t.Fatalf("unexpected announcements %q", got)
+	}
+}
+
+func TestAnnounceNoDebounce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	ps := getPubsub(ctx, hosts[0], WithAnnounceDebounce(0))
+	watcher := &announceBatchWatcher{}
+	hosts[1].SetStreamHandler(FloodSubID, watcher.handleStream)
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	// without debouncing, every change is announced on its own
+	for i := 0; i < 3; i++ {
+		if _, err := ps.Subscribe(fmt.Sprintf("topic-%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	rpcs := watcher.announcements()
+	if len(rpcs) != 3 {
+		t.Fatalf("expected 3 announcements, but got %d", len(rpcs))
+	}
+}
+
+// announceBatchWatcher records the subscription changes of every RPC it receives.
+type announceBatchWatcher struct {
+	mx   sync.Mutex
+	rpcs [][]*pb.RPC_SubOpts
+}
+
+func (aw *announceBatchWatcher) handleStream(s network.Stream) {
+	defer s.Close()
+
+	r := protoio.NewDelimitedReader(s, 1<<20)
+
+	for {
+		var rpc pb.RPC
+		err := r.ReadMsg(&rpc)
+		if err != nil {
+			if err != io.EOF {
+				s.Reset()
+			}
+			return
+		}
+
+		if len(rpc.GetSubscriptions()) > 0 {
+			aw.mx.Lock()
+			aw.rpcs = append(aw.rpcs, rpc.GetSubscriptions())
+			aw.mx.Unlock()
+		}
+	}
+}
+
+func (aw *announceBatchWatcher) announcements() [][]*pb.RPC_SubOpts {
+	aw.mx.Lock()
+	defer aw.mx.Unlock()
+	return aw.rpcs
+}
+
 func TestPubsubWithAssortedOptions(t *testing.T) {
 	// this test uses assorted options that are not covered in other tests
 	ctx, cancel := context.WithCancel(context.Background())
@@ -1280,3 +1444,160 @@ func TestDedupInboundStreams(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+type dropRPCTracer struct {
+	mx    sync.Mutex
+	drops map[peer.ID]int
+}
+
+func (t *dropRPCTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_DROP_RPC {
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.drops[peer.ID(evt.GetDropRPC().GetSendTo())]++
+}
+
+func (t *dropRPCTracer) get(p peer.ID) int {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.drops[p]
+}
+
+// Test that a peer that doesn't read its stream is skipped once its queue is above the high-water
+// mark, without delaying the delivery to the other peers.
+func TestFloodSubQueueHighWater(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 4)
+
+	// the first peer we connect to is saturated
+	tr := &wedgingTransport{hostTransport: newHostTransport(hosts[0])}
+	tracer := &dropRPCTracer{drops: make(map[peer.ID]int)}
+	ps, err := NewFloodSubWithTransport(ctx, tr, WithFloodSubQueueHighWater(4), WithEventTracer(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	psubs := getPubsubs(ctx, hosts[1:])
+
+	topic, err := ps.Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subs []*Subscription
+	for _, other := range psubs {
+		sub, err := other.Subscribe("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+	connect(t, hosts[0], hosts[2])
+	connect(t, hosts[0], hosts[3])
+	time.Sleep(500 * time.Millisecond)
+
+	const count = 50
+	published := make([]time.Time, count)
+	latencies := make([][]time.Duration, len(subs))
+	var wg sync.WaitGroup
+	for i, sub := range subs[1:] {
+		wg.Add(1)
+		go func(i int, sub *Subscription) {
+			defer wg.Done()
+			for j := 0; j < count; j++ {
+				rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+				msg, err := sub.Next(rctx)
+				rcancel()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				var k int
+				fmt.Sscanf(string(msg.Data[:8]), "%08d", &k)
+				latencies[i] = append(latencies[i], time.Since(published[k]))
+			}
+		}(i+1, sub)
+	}
+
+	payload := make([]byte, 64*1024)
+	for i := 0; i < count; i++ {
+		copy(payload, fmt.Sprintf("%08d", i))
+		published[i] = time.Now()
+		if err := topic.Publish(ctx, payload); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+
+	// the saturated peer is skipped instead of having its queue filled up
+	if n := tracer.get(hosts[1].ID()); n < count-5 {
+		t.Fatalf("expected the saturated peer to be skipped at least %d times, got %d", count-5, n)
+	}
+	for _, other := range hosts[2:] {
+		if n := tracer.get(other.ID()); n != 0 {
+			t.Fatalf("expected no drops for the healthy peers, got %d", n)
+		}
+	}
+
+	// and the delivery latency to the other peers stays flat
+	for i, lat := range latencies[1:] {
+		if len(lat) != count {
+			t.Fatalf("expected %d messages for peer %d, got %d", count, i, len(lat))
+		}
+		for _, d := range lat {
+			if d > 250*time.Millisecond {
+				t.Fatalf("expected the messages to be delivered promptly, got a latency of %s", d)
+			}
+		}
+	}
+}
+
+func TestFloodSubPeerMaxMessageSize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0], WithFloodSubPeerMaxMessageSize(1024)),
+		getPubsub(ctx, hosts[1]),
+	}
+
+	var subs []*Subscription
+	for _, ps := range psubs {
+		sub, err := ps.Subscribe("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	large := string(make([]byte, 2048))
+	for _, data := range []string{"small", large, "again"} {
+		if err := psubs[0].Publish("foobar", []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the large message is delivered locally, but not routed to the floodsub peer
+	for _, data := range []string{"small", large, "again"} {
+		expectMessage(t, ctx, subs[0], data)
+	}
+	expectMessage(t, ctx, subs[1], "small")
+	expectMessage(t, ctx, subs[1], "again")
+
+	if _, err := NewGossipSub(ctx, hosts[0], WithFloodSubPeerMaxMessageSize(1024)); err == nil {
+		t.Fatal("expected the option to require floodsub")
+	}
+	if _, err := NewFloodSub(ctx, hosts[0], WithFloodSubQueueHighWater(0)); err == nil {
+		t.Fatal("expected a zero high-water mark to be invalid")
+	}
+}
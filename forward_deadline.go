@@ -0,0 +1,162 @@
+package pubsub
+
+import (
+	"fmt"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// OriginTimeFn returns the origin timestamp embedded in a message by its publisher, if any.
+type OriginTimeFn func(msg *Message) (time.Time, bool)
+
+// ForwardDeadlineOpt is an option for WithForwardDeadline.
+type ForwardDeadlineOpt func(fd *forwardDeadline) error
+
+// WithOriginTime measures the age of the messages from the origin timestamp returned by fn,
+// instead of their local arrival, for the messages that embed one.
+func WithOriginTime(fn OriginTimeFn) ForwardDeadlineOpt {
+	return func(fd *forwardDeadline) error {
+		fd.originTime = fn
+		return nil
+	}
+}
+
+// WithStaleDelivery sets whether the messages that are older than the deadline are still
+// delivered to our subscriptions; they are by default.
+func WithStaleDelivery(deliver bool) ForwardDeadlineOpt {
+	return func(fd *forwardDeadline) error {
+		fd.dropStale = !deliver
+		return nil
+	}
+}
+
+// WithForwardDeadline is a topic option that stops forwarding the messages of the topic that are
+// older than deadline, for topics whose messages lose their value quickly. The age of a message
+// is measured from its local arrival, or from its origin timestamp with WithOriginTime. Stale
+// messages are checked when the router selects the peers to forward a message to, and again
+// before the message is written to each peer, as it may have aged in the outbound queue of the
+// peer, and they are not sent in response to IWANT either; stale messages are traced with a
+// STALE_MESSAGE event.
+// Stale messages are still validated, and delivered to our subscriptions unless disabled with
+// WithStaleDelivery.
+func WithForwardDeadline(deadline time.Duration, opts ...ForwardDeadlineOpt) TopicOpt {
+	return func(t *Topic) error {
+		if deadline <= 0 {
+			return fmt.Errorf("invalid forward deadline; must be positive")
+		}
+
+		fd := &forwardDeadline{deadline: deadline}
+		for _, opt := range opts {
+			if err := opt(fd); err != nil {
+				return err
+			}
+		}
+
+		t.forwardDeadline = fd
+		return nil
+	}
+}
+
+// forwardDeadline is the forward deadline of a topic.
+type forwardDeadline struct {
+	deadline   time.Duration
+	originTime OriginTimeFn
+	dropStale  bool
+}
+
+// expiry returns the time after which a message is not worth forwarding, or the zero time if its
+// age is unknown.
+func (fd *forwardDeadline) expiry(msg *Message) time.Time {
+	origin := msg.arrived
+	if fd.originTime != nil {
+		if t, ok := fd.originTime(msg); ok {
+			origin = t
+		}
+	}
+	if origin.IsZero() {
+		return time.Time{}
+	}
+	return origin.Add(fd.deadline)
+}
+
+// forwardExpiry returns the time after which a message is not worth forwarding, or the zero time if
+// its topic has no forward deadline.
+// Only called from processLoop.
+func (p *PubSub) forwardExpiry(msg *Message) time.Time {
+	t, ok := p.myTopics[msg.GetTopic()]
+	if !ok || t.forwardDeadline == nil {
+		return time.Time{}
+	}
+	return t.forwardDeadline.expiry(msg)
+}
+
+// staleMessage reports whether a message is older than the forward deadline of its topic, and
+// whether it should be delivered to our subscriptions nonetheless.
+// Only called from processLoop.
+func (p *PubSub) staleMessage(msg *Message) (stale, deliver bool) {
+	t, ok := p.myTopics[msg.GetTopic()]
+	if !ok || t.forwardDeadline == nil {
+		return false, true
+	}
+
+	expires := t.forwardDeadline.expiry(msg)
+	if expires.IsZero() || time.Now().Before(expires) {
+		return false, true
+	}
+	return true, !t.forwardDeadline.dropStale
+}
+
+// expiry returns the time after which the i-th published message is not worth writing, if any.
+func (rpc *RPC) expiry(i int) time.Time {
+	if i < len(rpc.expires) {
+		return rpc.expires[i]
+	}
+	return time.Time{}
+}
+
+// setExpiry sets the time after which the i-th published message is not worth writing; the zero
+// time is no expiry.
+func (rpc *RPC) setExpiry(i int, expires time.Time) {
+	if expires.IsZero() {
+		return
+	}
+	for len(rpc.expires) <= i {
+		rpc.expires = append(rpc.expires, time.Time{})
+	}
+	rpc.expires[i] = expires
+}
+
+// dropExpired drops the published messages of an outbound RPC that went stale in the outbound
+// queue of a peer; it returns nil if nothing is left to write.
+func (p *PubSub) dropExpired(rpc *RPC, pid peer.ID) *RPC {
+	if len(rpc.expires) == 0 {
+		return rpc
+	}
+
+	now := time.Now()
+	var kept []*pb.Message
+	for i, msg := range rpc.Publish {
+		if expires := rpc.expiry(i); !expires.IsZero() && now.After(expires) {
+			p.logger.Debugw("not writing stale message to peer", "peer", pid, "topic", msg.GetTopic())
+			p.tracer.StaleMessage(msg, pid)
+			continue
+		}
+		kept = append(kept, msg)
+	}
+
+	if len(kept) == len(rpc.Publish) {
+		return rpc
+	}
+	if len(kept) == 0 && len(rpc.Subscriptions) == 0 && rpc.Control == nil {
+		return nil
+	}
+
+	// the RPC may be shared with other peers
+	out := copyRPC(rpc)
+	out.Publish = kept
+	out.expires = nil
+	return out
+}
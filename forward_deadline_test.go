@@ -0,0 +1,229 @@
+package pubsub
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type staleMessageTracer struct {
+	mx    sync.Mutex
+	stale map[string]int
+}
+
+func (t *staleMessageTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_STALE_MESSAGE {
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.stale[string(evt.GetStaleMessage().GetPeerID())]++
+}
+
+// originTime reads the origin timestamp from the "ts" metadata of enveloped messages.
+func originTime(msg *Message) (time.Time, bool) {
+	env, ok := msg.Envelope()
+	if !ok {
+		return time.Time{}, false
+	}
+	ts, err := strconv.ParseInt(env.Metadata["ts"], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ts), true
+}
+
+func TestForwardDeadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 3)
+	tracer := &staleMessageTracer{stale: make(map[string]int)}
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0]),
+		getGossipsub(ctx, hosts[1], WithEventTracer(tracer)),
+		getGossipsub(ctx, hosts[2]),
+	}
+
+	const topic = "telemetry"
+
+	// the relay is congested: the validation of the slow messages takes longer than the deadline
+	err := psubs[1].RegisterTopicValidator(topic, func(ctx context.Context, _ peer.ID, msg *Message) bool {
+		if string(msg.GetData()) == "slow" {
+			time.Sleep(time.Second)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var topics []*Topic
+	var subs []*Subscription
+	for i, ps := range psubs {
+		var opts []TopicOpt
+		if i == 1 {
+			opts = append(opts, WithForwardDeadline(500*time.Millisecond, WithOriginTime(originTime)))
+		}
+		tp, err := ps.Join(topic, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := tp.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, tp)
+		subs = append(subs, sub)
+	}
+
+	// the relay is the only path between the publisher and the last peer
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+	time.Sleep(2 * time.Second)
+
+	if err := topics[0].Publish(ctx, []byte("slow")); err != nil {
+		t.Fatal(err)
+	}
+	old := strconv.FormatInt(time.Now().Add(-time.Minute).UnixNano(), 10)
+	if err := topics[0].PublishEnveloped(ctx, []byte("old"), map[string]string{"ts": old}); err != nil {
+		t.Fatal(err)
+	}
+	if err := topics[0].Publish(ctx, []byte("fresh")); err != nil {
+		t.Fatal(err)
+	}
+
+	received := func(sub *Subscription, n int) map[string]bool {
+		t.Helper()
+		got := make(map[string]bool)
+		for len(got) < n {
+			rctx, rcancel := context.WithTimeout(ctx, 3*time.Second)
+			msg, err := sub.Next(rctx)
+			rcancel()
+			if err != nil {
+				break
+			}
+			data := msg.GetData()
+			if env, ok := msg.Envelope(); ok {
+				data = env.Data
+			}
+			got[string(data)] = true
+		}
+		return got
+	}
+
+	// the relay delivers the stale messages, but only forwards the fresh one
+	if got := received(subs[1], 3); len(got) != 3 {
+		t.Fatalf("expected the relay to deliver all the messages, got %v", got)
+	}
+	if got := received(subs[2], 3); len(got) != 1 || !got["fresh"] {
+		t.Fatalf("expected only the fresh message to be forwarded, got %v", got)
+	}
+
+	tracer.mx.Lock()
+	defer tracer.mx.Unlock()
+	if n := tracer.stale[""]; n != 2 {
+		t.Fatalf("expected 2 stale messages, got %d", n)
+	}
+}
+
+func TestForwardDeadlineStaleDelivery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getGossipsub(ctx, hosts[0])
+
+	tp, err := ps.Join("telemetry", WithForwardDeadline(time.Second, WithOriginTime(originTime), WithStaleDelivery(false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := tp.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := strconv.FormatInt(time.Now().Add(-time.Minute).UnixNano(), 10)
+	if err := tp.PublishEnveloped(ctx, []byte("old"), map[string]string{"ts": old}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tp.Publish(ctx, []byte("fresh")); err != nil {
+		t.Fatal(err)
+	}
+
+	rctx, rcancel := context.WithTimeout(ctx, time.Second)
+	defer rcancel()
+	msg, err := sub.Next(rctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.GetData()) != "fresh" {
+		t.Fatalf("expected the stale message not to be delivered, got %q", msg.GetData())
+	}
+
+	if _, err := ps.Join("other", WithForwardDeadline(0)); err == nil {
+		t.Fatal("expected an error for an invalid forward deadline")
+	}
+}
+
+func TestDropExpired(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	tracer := &staleMessageTracer{stale: make(map[string]int)}
+	ps := getGossipsub(ctx, hosts[0], WithEventTracer(tracer))
+
+	topic := "telemetry"
+	stale := &pb.Message{Data: []byte("stale"), Topic: &topic, Seqno: []byte{1}}
+	fresh := &pb.Message{Data: []byte("fresh"), Topic: &topic, Seqno: []byte{2}}
+	pid := peer.ID("peer")
+
+	// an RPC without expiry is written as is
+	rpc := rpcWithMessages(stale, fresh)
+	if out := ps.dropExpired(rpc, pid); out != rpc {
+		t.Fatal("expected the RPC to be written as is")
+	}
+
+	// stale messages are dropped from a copy of the RPC, which may be shared
+	rpc.setExpiry(0, time.Now().Add(-time.Second))
+	rpc.setExpiry(1, time.Now().Add(time.Minute))
+	out := ps.dropExpired(rpc, pid)
+	if out == rpc || len(out.Publish) != 1 || out.Publish[0] != fresh {
+		t.Fatalf("expected only the fresh message to be written, got %v", out.Publish)
+	}
+	if len(rpc.Publish) != 2 {
+		t.Fatal("expected the original RPC to be left intact")
+	}
+
+	// nothing is written if all the messages are stale
+	rpc = rpcWithMessages(stale)
+	rpc.setExpiry(0, time.Now().Add(-time.Second))
+	if out := ps.dropExpired(rpc, pid); out != nil {
+		t.Fatalf("expected nothing to be written, got %v", out)
+	}
+
+	// the expiries survive fragmentation
+	rpc = rpcWithMessages(stale, fresh)
+	rpc.setExpiry(1, time.Now().Add(-time.Second))
+	frags, err := fragmentRPC(rpc, stale.Size()+4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frags) != 2 || !frags[0].expiry(0).IsZero() || frags[1].expiry(0).IsZero() {
+		t.Fatal("expected the expiry of the second message to be carried to its fragment")
+	}
+
+	tracer.mx.Lock()
+	defer tracer.mx.Unlock()
+	if n := tracer.stale[string(pid)]; n != 2 {
+		t.Fatalf("expected 2 stale messages traced for the peer, got %d", n)
+	}
+}
@@ -0,0 +1,85 @@
+package pubsub
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ForwardObserverQueueSize is the number of forwarding decisions queued for a forward observer;
+// decisions are dropped when the observer falls behind.
+var ForwardObserverQueueSize = 256
+
+// ForwardObserver is invoked with every message the router forwards, whether published by us or
+// by another peer, and the peers the message was queued for. The recipients slice is owned by the
+// observer.
+type ForwardObserver func(msg *Message, recipients []peer.ID)
+
+// WithForwardObserver installs an observer of the forwarding decisions of the router, for
+// building an application-level map of the propagation of messages without a tracer. The
+// observer is invoked off the event loop, in order, from a queue of ForwardObserverQueueSize
+// decisions; the decisions made while the queue is full are dropped, and counted in
+// ForwardObserverDropped.
+func WithForwardObserver(obs ForwardObserver) Option {
+	return func(p *PubSub) error {
+		p.fwdObserver = newForwardObserver(p.ctx, obs)
+		return nil
+	}
+}
+
+// ForwardObserverDropped returns the number of forwarding decisions dropped because the forward
+// observer fell behind.
+func (p *PubSub) ForwardObserverDropped() uint64 {
+	if p.fwdObserver == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&p.fwdObserver.dropped)
+}
+
+type forwardDecision struct {
+	msg        *Message
+	recipients []peer.ID
+}
+
+// forwardObserver queues the forwarding decisions for the observer. A nil observer observes
+// nothing.
+type forwardObserver struct {
+	queue   chan forwardDecision
+	dropped uint64
+}
+
+func newForwardObserver(ctx context.Context, obs ForwardObserver) *forwardObserver {
+	fo := &forwardObserver{queue: make(chan forwardDecision, ForwardObserverQueueSize)}
+	go func() {
+		for {
+			select {
+			case d := <-fo.queue:
+				obs(d.msg, d.recipients)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return fo
+}
+
+// observing returns whether the routers should collect the recipients of the messages they
+// forward.
+func (fo *forwardObserver) observing() bool {
+	return fo != nil
+}
+
+// observe queues a forwarding decision for the observer.
+// Only called from processLoop.
+func (fo *forwardObserver) observe(msg *Message, recipients []peer.ID) {
+	if fo == nil {
+		return
+	}
+
+	select {
+	case fo.queue <- forwardDecision{msg: msg, recipients: recipients}:
+	default:
+		atomic.AddUint64(&fo.dropped, 1)
+	}
+}
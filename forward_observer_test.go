@@ -0,0 +1,155 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+type forwardKey struct {
+	msg  string
+	peer peer.ID
+}
+
+// sentMessageTracer records the messages sent to each peer.
+type sentMessageTracer struct {
+	mx   sync.Mutex
+	sent map[forwardKey]struct{}
+}
+
+func (t *sentMessageTracer) SendRPC(rpc *RPC, p peer.ID) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for _, msg := range rpc.GetPublish() {
+		t.sent[forwardKey{string(msg.GetData()), p}] = struct{}{}
+	}
+}
+
+func (t *sentMessageTracer) AddPeer(p peer.ID, proto protocol.ID)      {}
+func (t *sentMessageTracer) RemovePeer(p peer.ID)                      {}
+func (t *sentMessageTracer) Join(topic string)                         {}
+func (t *sentMessageTracer) Leave(topic string)                        {}
+func (t *sentMessageTracer) Graft(p peer.ID, topic string)             {}
+func (t *sentMessageTracer) Prune(p peer.ID, topic string)             {}
+func (t *sentMessageTracer) ValidateMessage(msg *Message)              {}
+func (t *sentMessageTracer) DeliverMessage(msg *Message)               {}
+func (t *sentMessageTracer) RejectMessage(msg *Message, reason string) {}
+func (t *sentMessageTracer) DuplicateMessage(msg *Message)             {}
+func (t *sentMessageTracer) ThrottlePeer(p peer.ID)                    {}
+func (t *sentMessageTracer) RecvRPC(rpc *RPC)                          {}
+func (t *sentMessageTracer) DropRPC(rpc *RPC, p peer.ID)               {}
+func (t *sentMessageTracer) UndeliverableMessage(msg *Message)         {}
+
+func TestForwardObserver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 6)
+
+	var mx sync.Mutex
+	observed := make(map[forwardKey]struct{})
+	decisions := 0
+	obs := func(msg *Message, recipients []peer.ID) {
+		mx.Lock()
+		defer mx.Unlock()
+		decisions++
+		for _, p := range recipients {
+			observed[forwardKey{string(msg.GetData()), p}] = struct{}{}
+		}
+	}
+	tracer := &sentMessageTracer{sent: make(map[forwardKey]struct{})}
+
+	// every peer is in the mesh, so the messages are only sent by forwarding
+	params := DefaultGossipSubParams()
+	params.D = 6
+	params.Dlo = 5
+	params.Dhi = 12
+	psubs := []*PubSub{getGossipsub(ctx, hosts[0], WithGossipSubParams(params), WithForwardObserver(obs), WithRawTracer(tracer))}
+	psubs = append(psubs, getGossipsubs(ctx, hosts[1:], WithGossipSubParams(params))...)
+
+	var subs []*Subscription
+	for _, ps := range psubs {
+		sub, err := ps.Subscribe("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+	denseConnect(t, hosts)
+	time.Sleep(2 * time.Second)
+
+	// both the messages we publish and the ones we forward are observed
+	for i := 0; i < 10; i++ {
+		msg := []byte(fmt.Sprintf("message %d", i))
+		if err := psubs[i%2].Publish("foobar", msg); err != nil {
+			t.Fatal(err)
+		}
+		for _, sub := range subs {
+			if _, err := sub.Next(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	mx.Lock()
+	defer mx.Unlock()
+	tracer.mx.Lock()
+	defer tracer.mx.Unlock()
+
+	if decisions != 10 {
+		t.Fatalf("expected 10 forwarding decisions, got %d", decisions)
+	}
+	if len(observed) == 0 || len(observed) != len(tracer.sent) {
+		t.Fatalf("expected to observe %d recipients, got %d", len(tracer.sent), len(observed))
+	}
+	for k := range observed {
+		if _, ok := tracer.sent[k]; !ok {
+			t.Fatalf("observed recipient %s of %q was not sent the message", k.peer, k.msg)
+		}
+	}
+	if n := psubs[0].ForwardObserverDropped(); n != 0 {
+		t.Fatalf("expected no dropped decisions, got %d", n)
+	}
+}
+
+func TestForwardObserverDropped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	size := ForwardObserverQueueSize
+	ForwardObserverQueueSize = 1
+	defer func() { ForwardObserverQueueSize = size }()
+
+	hosts := getNetHosts(t, ctx, 2)
+	block := make(chan struct{})
+	defer close(block)
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0], WithForwardObserver(func(*Message, []peer.ID) { <-block })),
+		getGossipsub(ctx, hosts[1]),
+	}
+	for _, ps := range psubs {
+		if _, err := ps.Subscribe("foobar"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	// the observer takes at most the first decision and the queue the second one
+	for i := 0; i < 5; i++ {
+		if err := psubs[0].Publish("foobar", []byte(fmt.Sprintf("message %d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if n := psubs[0].ForwardObserverDropped(); n < 3 {
+		t.Fatalf("expected at least 3 dropped decisions, got %d", n)
+	}
+}
@@ -18,10 +18,19 @@ type gossipTracer struct {
 	idGen *msgIDGenerator
 
 	followUpTime time.Duration
+	// per peer override of the follow-up time, if any
+	followUpOverride PromiseFollowupFn
+	// maximum number of promises tracked per peer; 0 means no limit.
+	maxPromises int
+
+	// random source for picking the tracked promise; shared with the router.
+	rng *rand.Rand
 
 	// promises for messages by message ID; for each message tracked, we track the promise
 	// expiration time for each peer.
 	promises map[string]map[peer.ID]time.Time
+	// the topic of each promised message, so that its promises are voided when we leave the topic.
+	topics map[string]string
 	// promises for each peer; for each peer, we track the promised message IDs.
 	// this index allows us to quickly void promises when a peer is throttled.
 	peerPromises map[peer.ID]map[string]struct{}
@@ -30,7 +39,9 @@ type gossipTracer struct {
 func newGossipTracer() *gossipTracer {
 	return &gossipTracer{
 		idGen:        newMsgIdGenerator(),
+		rng:          newRand(),
 		promises:     make(map[string]map[peer.ID]time.Time),
+		topics:       make(map[string]string),
 		peerPromises: make(map[peer.ID]map[string]struct{}),
 	}
 }
@@ -42,17 +53,28 @@ func (gt *gossipTracer) Start(gs *GossipSubRouter) {
 
 	gt.idGen = gs.p.idGen
 	gt.followUpTime = gs.params.IWantFollowupTime
+	gt.followUpOverride = gs.promiseFollowup
+	gt.maxPromises = gs.params.MaxIWantPromises
+	gt.rng = gs.rng
 }
 
-// track a promise to deliver a message from a list of msgIDs we are requesting
-func (gt *gossipTracer) AddPromise(p peer.ID, msgIDs []string) {
+// track a promise to deliver a message from a list of msgIDs we are requesting, in the topics
+// given by message ID
+func (gt *gossipTracer) AddPromise(p peer.ID, msgIDs []string, topics map[string]string) {
 	if gt == nil {
 		return
 	}
 
-	idx := rand.Intn(len(msgIDs))
+	idx := gt.rng.Intn(len(msgIDs))
 	mid := msgIDs[idx]
 
+	followUp := gt.followUpTime
+	if gt.followUpOverride != nil {
+		if d := gt.followUpOverride(p); d > 0 {
+			followUp = d
+		}
+	}
+
 	gt.Lock()
 	defer gt.Unlock()
 
@@ -60,11 +82,21 @@ func (gt *gossipTracer) AddPromise(p peer.ID, msgIDs []string) {
 	if !ok {
 		promises = make(map[peer.ID]time.Time)
 		gt.promises[mid] = promises
+		gt.topics[mid] = topics[mid]
 	}
 
 	_, ok = promises[p]
 	if !ok {
-		promises[p] = time.Now().Add(gt.followUpTime)
+		if gt.maxPromises > 0 && len(gt.peerPromises[p]) >= gt.maxPromises {
+			// bound the memory we spend on a peer; we already have enough to penalize it
+			if len(promises) == 0 {
+				delete(gt.promises, mid)
+				delete(gt.topics, mid)
+			}
+			return
+		}
+
+		promises[p] = time.Now().Add(followUp)
 		peerPromises, ok := gt.peerPromises[p]
 		if !ok {
 			peerPromises = make(map[string]struct{})
@@ -108,6 +140,7 @@ func (gt *gossipTracer) GetBrokenPromises() map[peer.ID]int {
 
 		if len(promises) == 0 {
 			delete(gt.promises, mid)
+			delete(gt.topics, mid)
 		}
 	}
 
@@ -126,7 +159,14 @@ func (gt *gossipTracer) fulfillPromise(msg *Message) {
 	if !ok {
 		return
 	}
+	gt.voidPromises(mid, promises)
+}
+
+// voidPromises deletes the promises of all peers for a message.
+// It must be called with the lock held.
+func (gt *gossipTracer) voidPromises(mid string, promises map[peer.ID]time.Time) {
 	delete(gt.promises, mid)
+	delete(gt.topics, mid)
 
 	// delete the promise for all peers that promised it, as they have no way to fulfill it.
 	for p := range promises {
@@ -170,7 +210,6 @@ func (gt *gossipTracer) ValidateMessage(msg *Message) {
 func (gt *gossipTracer) AddPeer(p peer.ID, proto protocol.ID) {}
 func (gt *gossipTracer) RemovePeer(p peer.ID)                 {}
 func (gt *gossipTracer) Join(topic string)                    {}
-func (gt *gossipTracer) Leave(topic string)                   {}
 func (gt *gossipTracer) Graft(p peer.ID, topic string)        {}
 func (gt *gossipTracer) Prune(p peer.ID, topic string)        {}
 func (gt *gossipTracer) DuplicateMessage(msg *Message)        {}
@@ -193,8 +232,22 @@ func (gt *gossipTracer) ThrottlePeer(p peer.ID) {
 		delete(promises, p)
 		if len(promises) == 0 {
 			delete(gt.promises, mid)
+			delete(gt.topics, mid)
 		}
 	}
 
 	delete(gt.peerPromises, p)
 }
+
+func (gt *gossipTracer) Leave(topic string) {
+	// the messages we asked for in the topic are dropped on arrival, so the peers can't
+	// follow up on their promises anymore.
+	gt.Lock()
+	defer gt.Unlock()
+
+	for mid, promises := range gt.promises {
+		if gt.topics[mid] == topic {
+			gt.voidPromises(mid, promises)
+		}
+	}
+}
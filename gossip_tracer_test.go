@@ -26,9 +26,9 @@ func TestBrokenPromises(t *testing.T) {
 		mids = append(mids, mid)
 	}
 
-	gt.AddPromise(peerA, mids)
-	gt.AddPromise(peerB, mids)
-	gt.AddPromise(peerC, mids)
+	gt.AddPromise(peerA, mids, nil)
+	gt.AddPromise(peerB, mids, nil)
+	gt.AddPromise(peerC, mids, nil)
 
 	// no broken promises yet
 	brokenPromises := gt.GetBrokenPromises()
@@ -81,8 +81,8 @@ func TestNoBrokenPromises(t *testing.T) {
 		mids = append(mids, mid)
 	}
 
-	gt.AddPromise(peerA, mids)
-	gt.AddPromise(peerB, mids)
+	gt.AddPromise(peerA, mids, nil)
+	gt.AddPromise(peerB, mids, nil)
 
 	for _, m := range msgs {
 		gt.DeliverMessage(&Message{Message: m})
@@ -101,3 +101,89 @@ func TestNoBrokenPromises(t *testing.T) {
 		t.Fatal("expected empty peerPromises map")
 	}
 }
+
+func TestPromisesLimit(t *testing.T) {
+	// tests that the promises tracked per peer are capped
+	gt := newGossipTracer()
+	gt.followUpTime = 100 * time.Millisecond
+	gt.maxPromises = 3
+
+	peerA := peer.ID("A")
+	peerB := peer.ID("B")
+
+	for i := 0; i < 10; i++ {
+		m := makeTestMessage(i)
+		m.From = []byte(peerA)
+		mid := DefaultMsgIdFn(m)
+		gt.AddPromise(peerA, []string{mid}, nil)
+		if i == 0 {
+			gt.AddPromise(peerB, []string{mid}, nil)
+		}
+	}
+
+	if n := len(gt.peerPromises[peerA]); n != 3 {
+		t.Fatalf("expected 3 tracked promises for A, got %d", n)
+	}
+	if n := len(gt.promises); n != 3 {
+		t.Fatalf("expected 3 tracked messages, got %d", n)
+	}
+
+	// make promises break
+	time.Sleep(gt.followUpTime + time.Millisecond)
+
+	brokenPromises := gt.GetBrokenPromises()
+	if brokenPromises[peerA] != 3 {
+		t.Fatalf("expected 3 broken promises from A, got %d", brokenPromises[peerA])
+	}
+	if brokenPromises[peerB] != 1 {
+		t.Fatalf("expected 1 broken promise from B, got %d", brokenPromises[peerB])
+	}
+}
+
+func TestLeaveVoidsPromises(t *testing.T) {
+	// tests that the promises for the messages of a topic we leave are voided
+	gt := newGossipTracer()
+	gt.followUpTime = 100 * time.Millisecond
+
+	peerA := peer.ID("A")
+	peerB := peer.ID("B")
+
+	topics := make(map[string]string)
+	var fooMids, barMids []string
+	for i := 0; i < 10; i++ {
+		m := makeTestMessage(i)
+		m.From = []byte(peerA)
+		mid := DefaultMsgIdFn(m)
+		if i%2 == 0 {
+			topics[mid] = "foo"
+			fooMids = append(fooMids, mid)
+		} else {
+			topics[mid] = "bar"
+			barMids = append(barMids, mid)
+		}
+	}
+
+	gt.AddPromise(peerA, fooMids, topics)
+	gt.AddPromise(peerB, fooMids, topics)
+	gt.AddPromise(peerB, barMids, topics)
+
+	gt.Leave("foo")
+
+	if n := len(gt.peerPromises[peerA]); n != 0 {
+		t.Fatalf("expected no promises for A, got %d", n)
+	}
+	if n := len(gt.peerPromises[peerB]); n != 1 {
+		t.Fatalf("expected 1 promise for B, got %d", n)
+	}
+
+	// make promises break
+	time.Sleep(gt.followUpTime + time.Millisecond)
+
+	brokenPromises := gt.GetBrokenPromises()
+	if len(brokenPromises) != 1 || brokenPromises[peerB] != 1 {
+		t.Fatalf("expected only the promise of B in the other topic to break, got %v", brokenPromises)
+	}
+	if len(gt.topics) != 0 {
+		t.Fatal("expected empty topics map")
+	}
+}
@@ -2,19 +2,24 @@ package pubsub
 
 import (
 	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"math/rand"
 	"sort"
+	"sync"
 	"time"
 
 	pb "github.com/libp2p/go-libp2p-pubsub/pb"
 
 	"github.com/libp2p/go-libp2p/core/host"
-	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/core/record"
+
+	ma "github.com/multiformats/go-multiaddr"
 )
 
 const (
@@ -49,6 +54,7 @@ var (
 	GossipSubConnectors                       = 8
 	GossipSubMaxPendingConnections            = 128
 	GossipSubConnectionTimeout                = 30 * time.Second
+	GossipSubPXConfirmationTimeout            = time.Minute
 	GossipSubDirectConnectTicks        uint64 = 300
 	GossipSubDirectConnectInitialDelay        = time.Second
 	GossipSubOpportunisticGraftTicks   uint64 = 60
@@ -56,9 +62,16 @@ var (
 	GossipSubGraftFloodThreshold              = 10 * time.Second
 	GossipSubMaxIHaveLength                   = 5000
 	GossipSubMaxIHaveMessages                 = 10
+	GossipSubMaxIWantPromises                 = 64
 	GossipSubIWantFollowupTime                = 3 * time.Second
 )
 
+// minimum interval between warnings about heartbeats overrunning the heartbeat interval
+const heartbeatOverrunWarningInterval = time.Minute
+
+// number of recent heartbeat durations kept for introspection
+const recentHeartbeats = 16
+
 // GossipSubParams defines all the gossipsub specific parameters.
 type GossipSubParams struct {
 	// overlay parameters.
@@ -168,6 +181,12 @@ type GossipSubParams struct {
 	// ConnectionTimeout controls the timeout for connection attempts.
 	ConnectionTimeout time.Duration
 
+	// PXConfirmationTimeout controls how long the connections to peers obtained through px are
+	// protected from the connection manager while we wait for the peers to confirm that they speak
+	// pubsub by sending us an RPC. Peers are only considered for grafting (and counted towards the
+	// topic peers we need) once confirmed.
+	PXConfirmationTimeout time.Duration
+
 	// DirectConnectTicks is the number of heartbeat ticks for attempting to reconnect direct peers
 	// that are not currently connected.
 	DirectConnectTicks uint64
@@ -198,10 +217,23 @@ type GossipSubParams struct {
 	// MaxIHaveMessages is the maximum number of IHAVE messages to accept from a peer within a heartbeat.
 	MaxIHaveMessages int
 
+	// MaxIWantPromises is the maximum number of outstanding IWANT promises we track for a peer;
+	// once reached, further IWANT requests to the peer are not tracked until some promises are
+	// fulfilled or expire. A value of 0 disables the limit.
+	MaxIWantPromises int
+
 	// Time to wait for a message requested through IWANT following an IHAVE advertisement.
 	// If the message is not received within this window, a broken promise is declared and
 	// the router may apply bahavioural penalties.
 	IWantFollowupTime time.Duration
+
+	// AdaptToSmallNetworks enables the small network mode for topics with fewer than Dlo known
+	// peers, which would otherwise suffer from mesh churn and under-delivery penalties with the
+	// default degree. In this mode, we don't prune the mesh for oversubscription, we emit gossip to
+	// all our non-mesh peers in the topic regardless of GossipFactor, and the mesh message delivery
+	// penalty (P3) is not activated; its activation window restarts once the mode is left.
+	// A topic leaves the mode once it has more than Dhi known peers.
+	AdaptToSmallNetworks bool
 }
 
 // NewGossipSub returns a new PubSub object using the default GossipSubRouter as the router.
@@ -211,14 +243,26 @@ func NewGossipSub(ctx context.Context, h host.Host, opts ...Option) (*PubSub, er
 	return NewGossipSubWithRouter(ctx, h, rt, opts...)
 }
 
+// NewGossipSubWithTransport returns a new PubSub object running on a custom transport, using the
+// default GossipSubRouter as the router; see NewPubSubWithTransport.
+func NewGossipSubWithTransport(ctx context.Context, tr Transport, opts ...Option) (*PubSub, error) {
+	return NewPubSubWithTransport(ctx, tr, DefaultGossipSubRouter(nil), opts...)
+}
+
 // NewGossipSubWithRouter returns a new PubSub object using the given router.
 func NewGossipSubWithRouter(ctx context.Context, h host.Host, rt PubSubRouter, opts ...Option) (*PubSub, error) {
 	return NewPubSub(ctx, h, rt, opts...)
 }
 
 // DefaultGossipSubRouter returns a new GossipSubRouter with default parameters.
+// The host is only used for connection manager tagging; it may be nil for routers running on a custom
+// transport, in which case tagging is disabled.
 func DefaultGossipSubRouter(h host.Host) *GossipSubRouter {
 	params := DefaultGossipSubParams()
+	var tagTracer *tagTracer
+	if h != nil {
+		tagTracer = newTagTracer(h.ConnManager())
+	}
 	return &GossipSubRouter{
 		peers:     make(map[peer.ID]protocol.ID),
 		mesh:      make(map[string]map[peer.ID]struct{}),
@@ -229,13 +273,33 @@ func DefaultGossipSubRouter(h host.Host) *GossipSubRouter {
 		backoff:   make(map[string]map[peer.ID]time.Time),
 		peerhave:  make(map[peer.ID]int),
 		iasked:    make(map[peer.ID]int),
+		topichave: make(map[peer.ID]map[string]*ihaveCounts),
 		outbound:  make(map[peer.ID]bool),
 		connect:   make(chan connectInfo, params.MaxPendingConnections),
 		mcache:    NewMessageCache(params.HistoryGossip, params.HistoryLength),
 		protos:    GossipSubDefaultProtocols,
 		feature:   GossipSubDefaultFeatures,
-		tagTracer: newTagTracer(h.ConnManager()),
+		tagTracer: tagTracer,
+		rng:       newRand(),
 		params:    params,
+
+		topicIHaveLimits: make(map[string]ihaveLimits),
+		sendPruneReasons: makePruneReasonSet(DefaultPruneReasons),
+		pruneReasons:     make(map[string]map[peer.ID]PruneReason),
+		smallTopics:      make(map[string]bool),
+
+		topicOutboundReserve: make(map[string]int),
+
+		confirmed: make(map[peer.ID]bool),
+		pxPending: make(map[peer.ID]time.Time),
+
+		resolvedTopics: make(map[string]bool),
+
+		probes: make(map[peer.ID]*probeState),
+
+		transient: make(map[peer.ID]struct{}),
+
+		skipHolders: true,
 	}
 }
 
@@ -262,6 +326,7 @@ func DefaultGossipSubParams() GossipSubParams {
 		Connectors:                GossipSubConnectors,
 		MaxPendingConnections:     GossipSubMaxPendingConnections,
 		ConnectionTimeout:         GossipSubConnectionTimeout,
+		PXConfirmationTimeout:     GossipSubPXConfirmationTimeout,
 		DirectConnectTicks:        GossipSubDirectConnectTicks,
 		DirectConnectInitialDelay: GossipSubDirectConnectInitialDelay,
 		OpportunisticGraftTicks:   GossipSubOpportunisticGraftTicks,
@@ -269,6 +334,7 @@ func DefaultGossipSubParams() GossipSubParams {
 		GraftFloodThreshold:       GossipSubGraftFloodThreshold,
 		MaxIHaveLength:            GossipSubMaxIHaveLength,
 		MaxIHaveMessages:          GossipSubMaxIHaveMessages,
+		MaxIWantPromises:          GossipSubMaxIWantPromises,
 		IWantFollowupTime:         GossipSubIWantFollowupTime,
 		SlowHeartbeatWarning:      0.1,
 	}
@@ -282,14 +348,9 @@ func WithPeerScore(params *PeerScoreParams, thresholds *PeerScoreThresholds) Opt
 			return fmt.Errorf("pubsub router is not gossipsub")
 		}
 
-		// sanity check: validate the score parameters
-		err := params.validate()
-		if err != nil {
-			return err
-		}
-
-		// sanity check: validate the threshold values
-		err = thresholds.validate()
+		// sanity check: validate the score parameters and the threshold values, reporting all
+		// the violations at once
+		err := errors.Join(params.Validate(), thresholds.Validate())
 		if err != nil {
 			return err
 		}
@@ -309,7 +370,7 @@ func WithPeerScore(params *PeerScoreParams, thresholds *PeerScoreThresholds) Opt
 		} else {
 			ps.tracer = &pubsubTracer{
 				raw:   []RawTracer{gs.score, gs.gossipTracer},
-				pid:   ps.host.ID(),
+				pid:   ps.tr.ID(),
 				idGen: ps.idGen,
 			}
 		}
@@ -334,6 +395,23 @@ func WithFloodPublish(floodPublish bool) Option {
 	}
 }
 
+// WithKnownHolderExclusion is a gossipsub router option that controls whether we skip the peers
+// known to have a message when forwarding it: the peers that advertised it to us with IHAVE or
+// sent it to us, within the message cache window. This saves bandwidth without affecting
+// delivery, as these peers already have the message; it is enabled by default.
+func WithKnownHolderExclusion(enabled bool) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+
+		gs.skipHolders = enabled
+
+		return nil
+	}
+}
+
 // WithPeerExchange is a gossipsub router option that enables Peer eXchange on PRUNE.
 // This should generally be enabled in bootstrappers and well connected/trusted nodes
 // used for bootstrapping.
@@ -365,7 +443,9 @@ func WithDirectPeers(pis []peer.AddrInfo) Option {
 		direct := make(map[peer.ID]struct{})
 		for _, pi := range pis {
 			direct[pi.ID] = struct{}{}
-			ps.host.Peerstore().AddAddrs(pi.ID, pi.Addrs, peerstore.PermanentAddrTTL)
+			if ps.host != nil {
+				ps.host.Peerstore().AddAddrs(pi.ID, pi.Addrs, peerstore.PermanentAddrTTL)
+			}
 		}
 
 		gs.direct = direct
@@ -410,6 +490,102 @@ func WithGossipSubParams(cfg GossipSubParams) Option {
 	}
 }
 
+// WithTopicIHaveLimits is a gossipsub router option that sets the MaxIHaveLength and
+// MaxIHaveMessages limits for a topic. For IHAVEs received, the topic limits are applied to each
+// peer in addition to the global limits in GossipSubParams; for gossip we emit, the topic
+// MaxIHaveLength replaces the global one.
+func WithTopicIHaveLimits(topic string, maxIHaveLength, maxIHaveMessages int) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+		if maxIHaveLength <= 0 || maxIHaveMessages <= 0 {
+			return fmt.Errorf("invalid IHAVE limits for topic %s; must be positive", topic)
+		}
+		gs.topicIHaveLimits[topic] = ihaveLimits{
+			maxIHaveLength:   maxIHaveLength,
+			maxIHaveMessages: maxIHaveMessages,
+		}
+		return nil
+	}
+}
+
+// WithOutboundMeshReservation is a gossipsub router option that reserves Dout mesh slots for peers
+// we have outbound connections to, so that our mesh can't fill up with inbound peers before our own
+// grafts happen, as it may in NATed deployments. When the reservation is enabled, we refuse GRAFTs
+// from inbound peers that would bring the inbound peers in the mesh above Dhi - Dout, responding with
+// a PRUNE, even if the mesh is below Dhi; the reserved slots are used by heartbeat grafting.
+// The reservation can be set per topic with WithTopicOutboundMeshReservation.
+func WithOutboundMeshReservation() Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+		gs.reserveOutbound = true
+		return nil
+	}
+}
+
+// WithTopicOutboundMeshReservation is a gossipsub router option that sets the number of mesh slots
+// reserved for outbound peers in a topic, overriding WithOutboundMeshReservation; a value of 0
+// disables the reservation for the topic.
+func WithTopicOutboundMeshReservation(topic string, slots int) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+		if slots < 0 {
+			return fmt.Errorf("invalid outbound mesh reservation for topic %s; must be non-negative", topic)
+		}
+		gs.topicOutboundReserve[topic] = slots
+		return nil
+	}
+}
+
+// WithRandomSource is a gossipsub router option that sets the source of randomness used for
+// peer selection in mesh maintenance, gossip emission and peer exchange.
+// By default, each router uses its own source seeded from crypto/rand. When a source is supplied,
+// candidate peers are put in a canonical order before being shuffled, so that routers using
+// sources with the same seed make identical choices for identical sets of peers; this is
+// useful for simulations and testing.
+func WithRandomSource(src rand.Source) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+		gs.rng = rand.New(src)
+		gs.fixedRand = true
+		return nil
+	}
+}
+
+// WithHeartbeatStallNotify is a gossipsub router option that enables a watchdog for the heartbeat.
+// The watchdog runs on its own timer, independently of the event loop, and invokes fn with the
+// time elapsed since the last completed heartbeat when no heartbeat has completed for longer than
+// threshold. The notification fires once per stall and is rearmed when a heartbeat completes.
+// The threshold should be larger than the heartbeat interval.
+func WithHeartbeatStallNotify(threshold time.Duration, fn func(lag time.Duration)) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+		if threshold <= 0 {
+			return fmt.Errorf("invalid heartbeat stall threshold; must be positive")
+		}
+		if fn == nil {
+			return fmt.Errorf("nil heartbeat stall notification function")
+		}
+		gs.stallThreshold = threshold
+		gs.stallNotify = fn
+		return nil
+	}
+}
+
 // GossipSubRouter is a router that implements the gossipsub protocol.
 // For each topic we have joined, we maintain an overlay through which
 // messages flow; this is the mesh map.
@@ -438,6 +614,7 @@ type GossipSubRouter struct {
 	mcache       *MessageCache
 	tracer       *pubsubTracer
 	score        *peerScore
+	scoreDist    *scoreDistribution
 	gossipTracer *gossipTracer
 	tagTracer    *tagTracer
 	gate         *peerGater
@@ -445,6 +622,12 @@ type GossipSubRouter struct {
 	// config for gossipsub parameters
 	params GossipSubParams
 
+	// source of randomness for peer selection; only used from the event loop.
+	rng *rand.Rand
+	// whether the random source was supplied by the user, in which case candidates are
+	// canonically ordered before shuffling to make the selection reproducible.
+	fixedRand bool
+
 	// whether PX is enabled; this should be enabled in bootstrappers and other well connected/trusted
 	// nodes.
 	doPX bool
@@ -471,14 +654,126 @@ type GossipSubRouter struct {
 	// whether to use flood publishing
 	floodPublish bool
 
+	// whether to skip the peers known to have a message when forwarding it
+	skipHolders bool
+
+	// application callback vetoing peers from the mesh; nil admits all peers
+	graftAdmission GraftAdmissionFn
+	// the time the callback took in the current heartbeat interval, and whether it exceeded the
+	// budget
+	graftAdmissionSpent     time.Duration
+	graftAdmissionExhausted bool
+
+	// activity of the topics we have joined; nil unless an idle topic policy is set
+	idle *idleTopics
+
+	// topic traffic of the peers, for re-announcing our subscriptions; nil unless enabled
+	reannounce *subReannounce
+
+	// messages dropped by our subscriptions, for throttling topics; nil unless enabled
+	throttle *slowConsumerThrottle
+
+	// topics we have left recently; nil unless graceful leaves are enabled
+	gracefulLeave *gracefulLeave
+
+	// the peers that asked for the messages we are fetching; nil unless enabled
+	pendingIWants *pendingIWants
+
+	// runtime configuration of the topics; nil unless a config provider is set
+	topicConfigs *topicConfigs
+
 	// number of heartbeats since the beginning of time; this allows us to amortize some resource
 	// clean up -- eg backoff clean up.
 	heartbeatTicks uint64
+
+	// completion time and number of the last heartbeat; these are read outside the event loop,
+	// so they are protected by hbMx.
+	hbMx   sync.Mutex
+	hbLast time.Time
+	hbSeq  uint64
+	// durations of the recent heartbeats, oldest first
+	hbRecent []time.Duration
+
+	// heartbeat stall watchdog, if enabled
+	stallThreshold time.Duration
+	stallNotify    func(lag time.Duration)
+
+	// last time we warned about a heartbeat overrunning the heartbeat interval
+	lastOverrunWarning time.Time
+
+	// per topic IHAVE limits, and the IHAVEs received from and messages asked from each peer in
+	// the last heartbeat for topics with limits
+	topicIHaveLimits map[string]ihaveLimits
+	topichave        map[peer.ID]map[string]*ihaveCounts
+
+	// prune reasons we include in the PRUNEs we send
+	sendPruneReasons map[PruneReason]bool
+	// last prune reason given by peers that pruned us, by topic
+	pruneReasons map[string]map[peer.ID]PruneReason
+
+	// topics in small network mode; see GossipSubParams.AdaptToSmallNetworks
+	smallTopics map[string]bool
+
+	// mesh slots reserved for outbound peers; Dout for all topics if reserveOutbound is set, unless
+	// overridden for the topic
+	reserveOutbound      bool
+	topicOutboundReserve map[string]int
+
+	// peers that confirmed they speak pubsub by sending us an RPC, and the peers we connected to
+	// through px pending confirmation, with the expiration of their connection protection
+	confirmed map[peer.ID]bool
+	pxPending map[peer.ID]time.Time
+
+	// topic score parameters resolver, and whether the topics it was consulted for are scored
+	scoreResolver  TopicScoreParamsResolver
+	resolvedTopics map[string]bool
+
+	// prune backoff strategy, if any, and the consecutive prunes with peers by topic
+	backoffStrategy     BackoffStrategy
+	backoffStablePeriod time.Duration
+	pruneHistory        map[string]map[peer.ID]*pruneHistory
+
+	// membership sketches of the topics we have joined, if topic size estimation is enabled, and
+	// the number of samples in each sketch
+	topicSizes       map[string]*topicSizeSketch
+	topicSizeSamples int
+
+	// follow-up time override for the IWANT promises of a peer, and the peers exempt from the
+	// broken promise penalty
+	promiseFollowup PromiseFollowupFn
+	promiseExempt   map[peer.ID]struct{}
+
+	// application hooks for the peers obtained through PX, and the PX dial slots, if limited
+	pxDialer   PXDialerFn
+	pxObserver PXObserverFn
+	pxDials    chan struct{}
+
+	// liveness probe parameters, if mesh probes are enabled, and the probe state of the peers
+	probeParams *MeshProbeParams
+	probes      map[peer.ID]*probeState
+
+	// peers we are only connected to over transient connections, and whether they are allowed in
+	// our meshes
+	transient      map[peer.ID]struct{}
+	allowTransient bool
+}
+
+type ihaveLimits struct {
+	maxIHaveLength   int
+	maxIHaveMessages int
+}
+
+type ihaveCounts struct {
+	ihaves int
+	asked  int
 }
 
 type connectInfo struct {
-	p   peer.ID
-	spr *record.Envelope
+	p     peer.ID
+	spr   *record.Envelope
+	addrs []ma.Multiaddr
+	// whether the connection attempt holds a PX dial slot
+	px bool
 }
 
 func (gs *GossipSubRouter) Protocols() []protocol.ID {
@@ -498,17 +793,31 @@ func (gs *GossipSubRouter) Attach(p *PubSub) {
 	// and the tracer for connmgr tags
 	gs.tagTracer.Start(gs)
 
+	// and the peer gater
+	gs.gate.Start(gs)
+
 	// start using the same msg ID function as PubSub for caching messages.
 	gs.mcache.SetMsgIdFn(p.idGen.ID)
+	gs.mcache.budget = p.memBudget
 
 	// start the heartbeat
 	go gs.heartbeatTimer()
 
+	// and its watchdog
+	if gs.stallNotify != nil {
+		go gs.heartbeatWatchdog()
+	}
+
 	// start the PX connectors
 	for i := 0; i < gs.params.Connectors; i++ {
 		go gs.connector()
 	}
 
+	// and the topic config refresher
+	if gs.topicConfigs != nil {
+		go gs.refreshTopicConfigs()
+	}
+
 	// connect to direct peers
 	if len(gs.direct) > 0 {
 		go func() {
@@ -523,36 +832,27 @@ func (gs *GossipSubRouter) Attach(p *PubSub) {
 }
 
 func (gs *GossipSubRouter) AddPeer(p peer.ID, proto protocol.ID) {
-	log.Debugf("PEERUP: Add new peer %s using %s", p, proto)
+	gs.p.logger.Debugw("PEERUP: Add new peer", "peer", p, "protocol", proto)
 	gs.tracer.AddPeer(p, proto)
 	gs.peers[p] = proto
 
 	// track the connection direction
-	outbound := false
-	conns := gs.p.host.Network().ConnsToPeer(p)
-loop:
-	for _, c := range conns {
-		stat := c.Stat()
+	gs.outbound[p] = gs.p.tr.Outbound(p, gs.p.wireProtocol(proto))
 
-		if stat.Transient {
-			continue
-		}
+	// and whether it is only connected over transient connections
+	gs.classifyPeer(p)
 
-		if stat.Direction == network.DirOutbound {
-			// only count the connection if it has a pubsub stream
-			for _, s := range c.GetStreams() {
-				if s.Protocol() == proto {
-					outbound = true
-					break loop
-				}
-			}
-		}
+	// start the wait for traffic for our topics
+	gs.reannounce.heard(p, gs.heartbeatTicks)
+
+	// announce support for liveness probes to gossipsub peers
+	if gs.feature(GossipSubFeatureMesh, proto) {
+		gs.announceProbes(p)
 	}
-	gs.outbound[p] = outbound
 }
 
 func (gs *GossipSubRouter) RemovePeer(p peer.ID) {
-	log.Debugf("PEERDOWN: Remove disconnected peer %s", p)
+	gs.p.logger.Debugw("PEERDOWN: Remove disconnected peer", "peer", p)
 	gs.tracer.RemovePeer(p)
 	delete(gs.peers, p)
 	for _, peers := range gs.mesh {
@@ -561,9 +861,23 @@ func (gs *GossipSubRouter) RemovePeer(p peer.ID) {
 	for _, peers := range gs.fanout {
 		delete(peers, p)
 	}
+	for _, reasons := range gs.pruneReasons {
+		delete(reasons, p)
+	}
+	for _, history := range gs.pruneHistory {
+		delete(history, p)
+	}
 	delete(gs.gossip, p)
 	delete(gs.control, p)
 	delete(gs.outbound, p)
+	delete(gs.confirmed, p)
+	delete(gs.probes, p)
+	delete(gs.transient, p)
+	gs.reannounce.removePeer(p)
+	if _, ok := gs.pxPending[p]; ok {
+		delete(gs.pxPending, p)
+		gs.tagTracer.unprotectPXPeer(p)
+	}
 }
 
 func (gs *GossipSubRouter) EnoughPeers(topic string, suggested int) bool {
@@ -576,7 +890,7 @@ func (gs *GossipSubRouter) EnoughPeers(topic string, suggested int) bool {
 	fsPeers, gsPeers := 0, 0
 	// floodsub peers
 	for p := range tmap {
-		if !gs.feature(GossipSubFeatureMesh, gs.peers[p]) {
+		if !gs.feature(GossipSubFeatureMesh, gs.peers[p]) && gs.confirmed[p] {
 			fsPeers++
 		}
 	}
@@ -596,6 +910,10 @@ func (gs *GossipSubRouter) EnoughPeers(topic string, suggested int) bool {
 }
 
 func (gs *GossipSubRouter) AcceptFrom(p peer.ID) AcceptStatus {
+	// we are consulted for every RPC, after its subscriptions are applied, so this is where the
+	// peer is confirmed; graylisted peers are confirmed too, as they can recover
+	gs.confirmPeer(p)
+
 	_, direct := gs.direct[p]
 	if direct {
 		return AcceptAll
@@ -609,7 +927,21 @@ func (gs *GossipSubRouter) AcceptFrom(p peer.ID) AcceptStatus {
 }
 
 func (gs *GossipSubRouter) HandleRPC(rpc *RPC) {
+	if gs.reannounce != nil && gs.topicTraffic(rpc) {
+		gs.reannounce.heard(rpc.from, gs.heartbeatTicks)
+	}
+
+	if gs.skipHolders {
+		for _, pmsg := range rpc.GetPublish() {
+			gs.mcache.AddHolder(gs.p.idGen.RawID(pmsg), rpc.from)
+		}
+		for _, msg := range rpc.dups {
+			gs.mcache.AddHolder(msg.ID, rpc.from)
+		}
+	}
+
 	ctl := rpc.GetControl()
+	gs.handleProbe(rpc.from, ctl)
 	if ctl == nil {
 		return
 	}
@@ -618,6 +950,7 @@ func (gs *GossipSubRouter) HandleRPC(rpc *RPC) {
 	ihave := gs.handleIWant(rpc.from, ctl)
 	prune := gs.handleGraft(rpc.from, ctl)
 	gs.handlePrune(rpc.from, ctl)
+	gs.handleTopicSize(rpc.from, ctl)
 
 	if len(iwant) == 0 && len(ihave) == 0 && len(prune) == 0 {
 		return
@@ -631,23 +964,27 @@ func (gs *GossipSubRouter) handleIHave(p peer.ID, ctl *pb.ControlMessage) []*pb.
 	// we ignore IHAVE gossip from any peer whose score is below the gossip threshold
 	score := gs.score.Score(p)
 	if score < gs.gossipThreshold {
-		log.Debugf("IHAVE: ignoring peer %s with score below threshold [score = %f]", p, score)
+		gs.p.logger.Debugw("IHAVE: ignoring peer with score below threshold", "peer", p, "score", score)
+		gs.tracer.IgnoreIHave(p, pb.TraceEvent_IgnoreIHave_LOW_SCORE, "")
 		return nil
 	}
 
 	// IHAVE flood protection
 	gs.peerhave[p]++
 	if gs.peerhave[p] > gs.params.MaxIHaveMessages {
-		log.Debugf("IHAVE: peer %s has advertised too many times (%d) within this heartbeat interval; ignoring", p, gs.peerhave[p])
+		gs.p.logger.Debugw("IHAVE: peer has advertised too many times within this heartbeat interval; ignoring", "peer", p, "ihaves", gs.peerhave[p])
+		gs.tracer.IgnoreIHave(p, pb.TraceEvent_IgnoreIHave_TOO_MANY_IHAVES, "")
 		return nil
 	}
 
 	if gs.iasked[p] >= gs.params.MaxIHaveLength {
-		log.Debugf("IHAVE: peer %s has already advertised too many messages (%d); ignoring", p, gs.iasked[p])
+		gs.p.logger.Debugw("IHAVE: peer has already advertised too many messages; ignoring", "peer", p, "asked", gs.iasked[p])
+		gs.tracer.IgnoreIHave(p, pb.TraceEvent_IgnoreIHave_TOO_MANY_IWANTS, "")
 		return nil
 	}
 
-	iwant := make(map[string]struct{})
+	// the messages we ask for, with their topic
+	iwant := make(map[string]string)
 	for _, ihave := range ctl.GetIhave() {
 		topic := ihave.GetTopicID()
 		_, ok := gs.mesh[topic]
@@ -655,15 +992,48 @@ func (gs *GossipSubRouter) handleIHave(p peer.ID, ctl *pb.ControlMessage) []*pb.
 			continue
 		}
 
+		// don't pull more messages in topics our subscribers can't keep up with
+		if gs.throttle.throttled(topic) {
+			continue
+		}
+
 		if !gs.p.peerFilter(p, topic) {
 			continue
 		}
 
+		// topic IHAVE flood protection
+		limits, hasLimits := gs.topicIHaveLimits[topic]
+		var counts *ihaveCounts
+		if hasLimits {
+			counts = gs.topicIHaveCounts(p, topic)
+			counts.ihaves++
+			if counts.ihaves > limits.maxIHaveMessages {
+				gs.p.logger.Debugw("IHAVE: peer has advertised too many times within this heartbeat interval; ignoring", "peer", p, "topic", topic, "ihaves", counts.ihaves)
+				gs.tracer.IgnoreIHave(p, pb.TraceEvent_IgnoreIHave_TOO_MANY_IHAVES, topic)
+				continue
+			}
+			if counts.asked >= limits.maxIHaveLength {
+				gs.p.logger.Debugw("IHAVE: peer has already advertised too many messages; ignoring", "peer", p, "topic", topic, "asked", counts.asked)
+				gs.tracer.IgnoreIHave(p, pb.TraceEvent_IgnoreIHave_TOO_MANY_IWANTS, topic)
+				continue
+			}
+		}
+
 		for _, mid := range ihave.GetMessageIDs() {
-			if gs.p.seenMessage(mid) {
+			if gs.p.seenMessage(mid) || gs.p.val.validating(mid) {
 				continue
 			}
-			iwant[mid] = struct{}{}
+			if _, ok := iwant[mid]; ok {
+				continue
+			}
+			if hasLimits {
+				if counts.asked >= limits.maxIHaveLength {
+					break
+				}
+				// this may overcount if we end up asking for fewer messages below, which is fine
+				counts.asked++
+			}
+			iwant[mid] = topic
 		}
 	}
 
@@ -676,7 +1046,7 @@ func (gs *GossipSubRouter) handleIHave(p peer.ID, ctl *pb.ControlMessage) []*pb.
 		iask = gs.params.MaxIHaveLength - gs.iasked[p]
 	}
 
-	log.Debugf("IHAVE: Asking for %d out of %d messages from %s", iask, len(iwant), p)
+	gs.p.logger.Debugw("IHAVE: Asking for messages", "peer", p, "asked", iask, "advertised", len(iwant))
 
 	iwantlst := make([]string, 0, len(iwant))
 	for mid := range iwant {
@@ -684,13 +1054,22 @@ func (gs *GossipSubRouter) handleIHave(p peer.ID, ctl *pb.ControlMessage) []*pb.
 	}
 
 	// ask in random order
-	shuffleStrings(iwantlst)
+	gs.shuffleStrings(iwantlst)
 
 	// truncate to the messages we are actually asking for and update the iasked counter
 	iwantlst = iwantlst[:iask]
 	gs.iasked[p] += iask
 
-	gs.gossipTracer.AddPromise(p, iwantlst)
+	gs.gossipTracer.AddPromise(p, iwantlst, iwant)
+	gs.pendingIWants.asked(iwantlst, time.Now().Add(gs.params.IWantFollowupTime))
+
+	// the peer has the messages, so we don't need to forward them if they reach us first from
+	// another peer
+	if gs.skipHolders {
+		for _, mid := range iwantlst {
+			gs.mcache.AddHolder(mid, p)
+		}
+	}
 
 	return []*pb.ControlIWant{{MessageIDs: iwantlst}}
 }
@@ -699,7 +1078,7 @@ func (gs *GossipSubRouter) handleIWant(p peer.ID, ctl *pb.ControlMessage) []*pb.
 	// we don't respond to IWANT requests from any peer whose score is below the gossip threshold
 	score := gs.score.Score(p)
 	if score < gs.gossipThreshold {
-		log.Debugf("IWANT: ignoring peer %s with score below threshold [score = %f]", p, score)
+		gs.p.logger.Debugw("IWANT: ignoring peer with score below threshold", "peer", p, "score", score)
 		return nil
 	}
 
@@ -708,6 +1087,9 @@ func (gs *GossipSubRouter) handleIWant(p peer.ID, ctl *pb.ControlMessage) []*pb.
 		for _, mid := range iwant.GetMessageIDs() {
 			msg, count, ok := gs.mcache.GetForPeer(mid, p)
 			if !ok {
+				gs.tracer.IWantMiss(p, mid)
+				// we may be fetching it ourselves, in which case we send it once it arrives
+				gs.pendingIWants.request(mid, p)
 				continue
 			}
 
@@ -715,8 +1097,22 @@ func (gs *GossipSubRouter) handleIWant(p peer.ID, ctl *pb.ControlMessage) []*pb.
 				continue
 			}
 
+			// we no longer serve the topics we left once their grace period is over
+			if gs.gracefulLeave.expired(msg.GetTopic()) {
+				continue
+			}
+
+			if !gs.p.forwarding(msg.GetTopic(), msg.ReceivedFrom == gs.p.tr.ID()) {
+				continue
+			}
+
+			if stale, _ := gs.p.staleMessage(msg); stale {
+				gs.p.logger.Debugw("IWANT: not sending message older than the forward deadline", "peer", p, "msgid", mid)
+				continue
+			}
+
 			if count > gs.params.GossipRetransmission {
-				log.Debugf("IWANT: Peer %s has asked for message %s too many times; ignoring request", p, mid)
+				gs.p.logger.Debugw("IWANT: Peer has asked for message too many times; ignoring request", "peer", p, "msgid", mid)
 				continue
 			}
 
@@ -728,7 +1124,7 @@ func (gs *GossipSubRouter) handleIWant(p peer.ID, ctl *pb.ControlMessage) []*pb.
 		return nil
 	}
 
-	log.Debugf("IWANT: Sending %d messages to %s", len(ihave), p)
+	gs.p.logger.Debugw("IWANT: Sending messages", "peer", p, "messages", len(ihave))
 
 	msgs := make([]*pb.Message, 0, len(ihave))
 	for _, msg := range ihave {
@@ -739,7 +1135,8 @@ func (gs *GossipSubRouter) handleIWant(p peer.ID, ctl *pb.ControlMessage) []*pb.
 }
 
 func (gs *GossipSubRouter) handleGraft(p peer.ID, ctl *pb.ControlMessage) []*pb.ControlPrune {
-	var prune []string
+	var prune, leaving []string
+	reasons := make(map[string]PruneReason)
 
 	doPX := gs.doPX
 	score := gs.score.Score(p)
@@ -752,10 +1149,21 @@ func (gs *GossipSubRouter) handleGraft(p peer.ID, ctl *pb.ControlMessage) []*pb.
 			continue
 		}
 
+		// ignore GRAFTs from peers in subscription churn cool-down for the topic
+		if gs.p.subChurnCooldown(p, topic) {
+			gs.p.logger.Debugw("GRAFT: ignoring peer in subscription churn cool-down", "peer", p, "topic", topic)
+			continue
+		}
+
 		peers, ok := gs.mesh[topic]
 		if !ok {
 			// don't do PX when there is an unknown topic to avoid leaking our peers
 			doPX = false
+			// we have just left the topic; remind the peer, so that it stops adding us back
+			if gs.gracefulLeave.leaving(topic) {
+				leaving = append(leaving, topic)
+				continue
+			}
 			// spam hardening: ignore GRAFTs for unknown topics
 			continue
 		}
@@ -769,7 +1177,7 @@ func (gs *GossipSubRouter) handleGraft(p peer.ID, ctl *pb.ControlMessage) []*pb.
 		// we don't GRAFT to/from direct peers; complain loudly if this happens
 		_, direct := gs.direct[p]
 		if direct {
-			log.Warnf("GRAFT: ignoring request from direct peer %s", p)
+			gs.p.logger.Warnw("GRAFT: ignoring request from direct peer", "peer", p, "topic", topic)
 			// this is possibly a bug from non-reciprocal configuration; send a PRUNE
 			prune = append(prune, topic)
 			// but don't PX
@@ -780,16 +1188,16 @@ func (gs *GossipSubRouter) handleGraft(p peer.ID, ctl *pb.ControlMessage) []*pb.
 		// make sure we are not backing off that peer
 		expire, backoff := gs.backoff[topic][p]
 		if backoff && now.Before(expire) {
-			log.Debugf("GRAFT: ignoring backed off peer %s", p)
+			gs.p.logger.Debugw("GRAFT: ignoring backed off peer", "peer", p, "topic", topic)
 			// add behavioural penalty
-			gs.score.AddPenalty(p, 1)
+			gs.score.AddPenalty(p, 1, PenaltyGraftBackoff)
 			// no PX
 			doPX = false
 			// check the flood cutoff -- is the GRAFT coming too fast?
 			floodCutoff := expire.Add(gs.params.GraftFloodThreshold - gs.params.PruneBackoff)
 			if now.Before(floodCutoff) {
 				// extra penalty
-				gs.score.AddPenalty(p, 1)
+				gs.score.AddPenalty(p, 1, PenaltyGraftFlood)
 			}
 			// refresh the backoff
 			gs.addBackoff(p, topic, false)
@@ -797,12 +1205,32 @@ func (gs *GossipSubRouter) handleGraft(p peer.ID, ctl *pb.ControlMessage) []*pb.
 			continue
 		}
 
+		// we don't GRAFT peers over transient connections
+		if gs.transientPeer(p) {
+			gs.p.logger.Debugw("GRAFT: refusing peer only connected over transient connections", "peer", p, "topic", topic)
+			prune = append(prune, topic)
+			doPX = false
+			gs.addBackoff(p, topic, false)
+			continue
+		}
+
+		// check with the application
+		if !gs.admitGraft(p, topic) {
+			gs.p.logger.Debugw("GRAFT: refusing peer not admitted by the application", "peer", p, "topic", topic)
+			prune = append(prune, topic)
+			// we don't PX to peers we don't admit
+			doPX = false
+			gs.addBackoff(p, topic, false)
+			continue
+		}
+
 		// check the score
 		if score < 0 {
 			// we don't GRAFT peers with negative score
-			log.Debugf("GRAFT: ignoring peer %s with negative score [score = %f, topic = %s]", p, score, topic)
+			gs.p.logger.Debugw("GRAFT: ignoring peer with negative score", "peer", p, "topic", topic, "score", score)
 			// we do send them PRUNE however, because it's a matter of protocol correctness
 			prune = append(prune, topic)
+			reasons[topic] = PruneReasonLowScore
 			// but we won't PX to them
 			doPX = false
 			// add/refresh backoff so that we don't reGRAFT too early even if the score decays back up
@@ -813,46 +1241,95 @@ func (gs *GossipSubRouter) handleGraft(p peer.ID, ctl *pb.ControlMessage) []*pb.
 		// check the number of mesh peers; if it is at (or over) Dhi, we only accept grafts
 		// from peers with outbound connections; this is a defensive check to restrict potential
 		// mesh takeover attacks combined with love bombing
-		if len(peers) >= gs.params.Dhi && !gs.outbound[p] {
+		if _, _, dhi := gs.meshDegree(topic); len(peers) >= dhi && !gs.outbound[p] {
 			prune = append(prune, topic)
+			reasons[topic] = PruneReasonOversubscribed
 			gs.addBackoff(p, topic, false)
 			continue
 		}
 
-		log.Debugf("GRAFT: add mesh link from %s in %s", p, topic)
+		// if we reserve mesh slots for outbound peers, inbound peers can't take them either
+		if reserve := gs.outboundReservation(topic); reserve > 0 && !gs.outbound[p] {
+			inbound := 0
+			for mp := range peers {
+				if !gs.outbound[mp] {
+					inbound++
+				}
+			}
+			if inbound >= gs.params.Dhi-reserve {
+				gs.p.logger.Debugw("GRAFT: refusing inbound peer; the remaining mesh slots are reserved for outbound peers", "peer", p, "topic", topic)
+				prune = append(prune, topic)
+				reasons[topic] = PruneReasonOversubscribed
+				gs.addBackoff(p, topic, false)
+				continue
+			}
+		}
+
+		gs.p.logger.Debugw("GRAFT: add mesh link", "peer", p, "topic", topic)
 		gs.tracer.Graft(p, topic)
 		peers[p] = struct{}{}
 	}
 
-	if len(prune) == 0 {
+	if len(prune) == 0 && len(leaving) == 0 {
 		return nil
 	}
 
-	cprune := make([]*pb.ControlPrune, 0, len(prune))
+	cprune := make([]*pb.ControlPrune, 0, len(prune)+len(leaving))
 	for _, topic := range prune {
-		cprune = append(cprune, gs.makePrune(p, topic, doPX, false))
+		cprune = append(cprune, gs.makePrune(p, topic, doPX, false, reasons[topic]))
+	}
+	for _, topic := range leaving {
+		cprune = append(cprune, gs.makePrune(p, topic, false, true, PruneReasonLeave))
 	}
 
 	return cprune
 }
 
+// outboundReservation returns the number of mesh slots reserved for outbound peers in a topic.
+func (gs *GossipSubRouter) outboundReservation(topic string) int {
+	if reserve, ok := gs.topicOutboundReserve[topic]; ok {
+		return reserve
+	}
+	if gs.reserveOutbound {
+		return gs.params.Dout
+	}
+	return 0
+}
+
 func (gs *GossipSubRouter) handlePrune(p peer.ID, ctl *pb.ControlMessage) {
 	score := gs.score.Score(p)
 
 	for _, prune := range ctl.GetPrune() {
 		topic := prune.GetTopicID()
+
+		// the peer is leaving the topic; don't wait for its unsubscription to stop counting it
+		// as a peer of the topic
+		if prune.GetLeaving() {
+			gs.p.handleSubscription(p, topic, false)
+		}
+
 		peers, ok := gs.mesh[topic]
 		if !ok {
 			continue
 		}
 
-		log.Debugf("PRUNE: Remove mesh link to %s in %s", p, topic)
-		gs.tracer.Prune(p, topic)
+		reason := PruneReason(prune.GetReason())
+		gs.p.logger.Debugw("PRUNE: Remove mesh link", "peer", p, "topic", topic, "reason", reason)
+		gs.tracer.Prune(p, topic, reason)
 		delete(peers, p)
+		gs.recordPruneReason(p, topic, reason)
+		gs.p.notifyPruned(topic, p, reason)
 		// is there a backoff specified by the peer? if so obey it.
 		backoff := prune.GetBackoff()
 		if backoff > 0 {
-			gs.doAddBackoff(p, topic, time.Duration(backoff)*time.Second)
+			interval := time.Duration(backoff) * time.Second
+			// with a backoff strategy, we may back off for longer than the peer asks
+			if gs.backoffStrategy != nil {
+				if local := gs.nextPruneBackoff(p, topic); local > interval {
+					interval = local
+				}
+			}
+			gs.doAddBackoff(p, topic, interval)
 		} else {
 			gs.addBackoff(p, topic, false)
 		}
@@ -861,19 +1338,21 @@ func (gs *GossipSubRouter) handlePrune(p peer.ID, ctl *pb.ControlMessage) {
 		if len(px) > 0 {
 			// we ignore PX from peers with insufficient score
 			if score < gs.acceptPXThreshold {
-				log.Debugf("PRUNE: ignoring PX from peer %s with insufficient score [score = %f, topic = %s]", p, score, topic)
+				gs.p.logger.Debugw("PRUNE: ignoring PX from peer with insufficient score", "peer", p, "topic", topic, "score", score)
 				continue
 			}
 
-			gs.pxConnect(px)
+			gs.pxConnect(p, px)
 		}
 	}
 }
 
 func (gs *GossipSubRouter) addBackoff(p peer.ID, topic string, isUnsubscribe bool) {
-	backoff := gs.params.PruneBackoff
+	var backoff time.Duration
 	if isUnsubscribe {
 		backoff = gs.params.UnsubscribeBackoff
+	} else {
+		backoff = gs.nextPruneBackoff(p, topic)
 	}
 	gs.doAddBackoff(p, topic, backoff)
 }
@@ -890,9 +1369,13 @@ func (gs *GossipSubRouter) doAddBackoff(p peer.ID, topic string, interval time.D
 	}
 }
 
-func (gs *GossipSubRouter) pxConnect(peers []*pb.PeerInfo) {
+func (gs *GossipSubRouter) pxConnect(from peer.ID, peers []*pb.PeerInfo) {
+	if gs.pxObserver != nil {
+		gs.pxObserver(from, pxCandidates(peers))
+	}
+
 	if len(peers) > gs.params.PrunePeers {
-		shufflePeerInfo(peers)
+		gs.shufflePeerInfo(peers)
 		peers = peers[:gs.params.PrunePeers]
 	}
 
@@ -907,37 +1390,94 @@ func (gs *GossipSubRouter) pxConnect(peers []*pb.PeerInfo) {
 		}
 
 		var spr *record.Envelope
+		var addrs []ma.Multiaddr
 		if pi.SignedPeerRecord != nil {
 			// the peer sent us a signed record; ensure that it is valid
 			envelope, r, err := record.ConsumeEnvelope(pi.SignedPeerRecord, peer.PeerRecordEnvelopeDomain)
 			if err != nil {
-				log.Warnf("error unmarshalling peer record obtained through px: %s", err)
+				gs.p.logger.Warnw("error unmarshalling peer record obtained through px", "peer", p, "err", err)
 				continue
 			}
 			rec, ok := r.(*peer.PeerRecord)
 			if !ok {
-				log.Warnf("bogus peer record obtained through px: envelope payload is not PeerRecord")
+				gs.p.logger.Warnw("bogus peer record obtained through px: envelope payload is not PeerRecord", "peer", p)
 				continue
 			}
 			if rec.PeerID != p {
-				log.Warnf("bogus peer record obtained through px: peer ID %s doesn't match expected peer %s", rec.PeerID, p)
+				gs.p.logger.Warnw("bogus peer record obtained through px: peer ID doesn't match expected peer", "peer", p, "record", rec.PeerID)
 				continue
 			}
 			spr = envelope
+			addrs = rec.Addrs
 		}
 
-		toconnect = append(toconnect, connectInfo{p, spr})
+		toconnect = append(toconnect, connectInfo{p: p, spr: spr, addrs: addrs})
 	}
 
 	if len(toconnect) == 0 {
 		return
 	}
 
+	// let the application vet the candidates, off the event loop
+	if gs.pxDialer != nil {
+		go gs.pxDial(toconnect)
+		return
+	}
+
+	gs.queuePXConnect(toconnect)
+}
+
+// queuePXConnect queues the connection attempts to peers obtained through PX.
+func (gs *GossipSubRouter) queuePXConnect(toconnect []connectInfo) {
 	for _, ci := range toconnect {
+		if gs.pxDials != nil {
+			select {
+			case gs.pxDials <- struct{}{}:
+				ci.px = true
+			default:
+				gs.p.logger.Debugw("ignoring px peer connection attempt; too many px connection attempts", "peer", ci.p)
+				continue
+			}
+		}
+
 		select {
 		case gs.connect <- ci:
+			// protect the connection until the peer confirms it speaks pubsub, or the protection expires
+			if _, pending := gs.pxPending[ci.p]; !pending {
+				gs.tagTracer.protectPXPeer(ci.p)
+			}
+			gs.pxPending[ci.p] = time.Now().Add(gs.params.PXConfirmationTimeout)
 		default:
-			log.Debugf("ignoring peer connection attempt; too many pending connections")
+			gs.p.logger.Debugf("ignoring peer connection attempt; too many pending connections")
+			if ci.px {
+				<-gs.pxDials
+			}
+		}
+	}
+}
+
+// confirmPeer marks a peer that sent us an RPC as speaking pubsub.
+func (gs *GossipSubRouter) confirmPeer(p peer.ID) {
+	if gs.confirmed[p] {
+		return
+	}
+
+	gs.confirmed[p] = true
+	if _, ok := gs.pxPending[p]; ok {
+		delete(gs.pxPending, p)
+		gs.tagTracer.unprotectPXPeer(p)
+	}
+}
+
+// releasePXPeers removes the connection protection of the peers obtained through px that failed to
+// confirm they speak pubsub in time.
+func (gs *GossipSubRouter) releasePXPeers() {
+	now := time.Now()
+	for p, expire := range gs.pxPending {
+		if now.After(expire) {
+			gs.p.logger.Debugw("releasing unconfirmed px peer", "peer", p)
+			delete(gs.pxPending, p)
+			gs.tagTracer.unprotectPXPeer(p)
 		}
 	}
 }
@@ -946,35 +1486,60 @@ func (gs *GossipSubRouter) connector() {
 	for {
 		select {
 		case ci := <-gs.connect:
-			if gs.p.host.Network().Connectedness(ci.p) == network.Connected {
-				continue
+			gs.connectPeer(ci)
+			if ci.px {
+				<-gs.pxDials
 			}
 
-			log.Debugf("connecting to %s", ci.p)
-			cab, ok := peerstore.GetCertifiedAddrBook(gs.p.host.Peerstore())
-			if ok && ci.spr != nil {
-				_, err := cab.ConsumePeerRecord(ci.spr, peerstore.TempAddrTTL)
-				if err != nil {
-					log.Debugf("error processing peer record: %s", err)
-				}
-			}
+		case <-gs.p.ctx.Done():
+			return
+		}
+	}
+}
+
+// connectPeer attempts to connect to a peer, unless we are already connected.
+func (gs *GossipSubRouter) connectPeer(ci connectInfo) {
+	if gs.p.tr.Connected(ci.p) {
+		return
+	}
 
-			ctx, cancel := context.WithTimeout(gs.p.ctx, gs.params.ConnectionTimeout)
-			err := gs.p.host.Connect(ctx, peer.AddrInfo{ID: ci.p})
-			cancel()
+	gs.p.logger.Debugw("connecting to peer", "peer", ci.p)
+	if gs.p.host != nil && ci.spr != nil {
+		cab, ok := peerstore.GetCertifiedAddrBook(gs.p.host.Peerstore())
+		if ok {
+			_, err := cab.ConsumePeerRecord(ci.spr, peerstore.TempAddrTTL)
 			if err != nil {
-				log.Debugf("error connecting to %s: %s", ci.p, err)
+				gs.p.logger.Debugw("error processing peer record", "peer", ci.p, "err", err)
 			}
-
-		case <-gs.p.ctx.Done():
-			return
 		}
 	}
+
+	// the addresses of a signed record are already in the certified address book
+	pi := peer.AddrInfo{ID: ci.p}
+	if ci.spr == nil {
+		pi.Addrs = ci.addrs
+	}
+
+	ctx, cancel := context.WithTimeout(gs.p.ctx, gs.params.ConnectionTimeout)
+	err := gs.p.tr.Connect(ctx, pi)
+	cancel()
+	if err != nil {
+		gs.p.logger.Debugw("error connecting to peer", "peer", ci.p, "err", err)
+	}
 }
 
 func (gs *GossipSubRouter) Publish(msg *Message) {
+	gs.publish(msg, nil)
+}
+
+func (gs *GossipSubRouter) publish(msg *Message, res *PublishResult) {
+	gs.topicActivity(msg.GetTopic())
 	gs.mcache.Put(msg)
+	gs.route(msg, res)
+	gs.servePendingIWants(msg)
+}
 
+func (gs *GossipSubRouter) route(msg *Message, res *PublishResult) {
 	from := msg.ReceivedFrom
 	topic := msg.GetTopic()
 
@@ -986,7 +1551,7 @@ func (gs *GossipSubRouter) Publish(msg *Message) {
 		return
 	}
 
-	if gs.floodPublish && from == gs.p.host.ID() {
+	if from == gs.p.tr.ID() && gs.floodPublishTopic(topic) {
 		for p := range tmap {
 			_, direct := gs.direct[p]
 			if direct || gs.score.Score(p) >= gs.publishThreshold {
@@ -1034,46 +1599,93 @@ func (gs *GossipSubRouter) Publish(msg *Message) {
 		}
 	}
 
+	var mid string
+	if gs.skipHolders {
+		mid = gs.p.idGen.ID(msg)
+	}
+
+	var recipients []peer.ID
+	observe := gs.p.fwdObserver.observing()
+
 	out := rpcWithMessages(msg.Message)
+	out.setExpiry(0, gs.p.forwardExpiry(msg))
 	for pid := range tosend {
 		if pid == from || pid == peer.ID(msg.GetFrom()) {
 			continue
 		}
+		if gs.skipHolders && gs.mcache.IsHolder(mid, pid) {
+			gs.p.logger.Debugw("not forwarding message: the peer has it", "peer", pid, "topic", topic, "msgid", mid)
+			continue
+		}
 
-		gs.sendRPC(pid, out)
+		queued := gs.sendRPC(pid, out)
+		res.addRecipient(pid, queued)
+		if observe && queued {
+			recipients = append(recipients, pid)
+		}
+	}
+	gs.p.fwdObserver.observe(msg, recipients)
+
+	if res != nil {
+		gs.explainExclusions(topic, msg, tmap, tosend, res)
+	}
+}
+
+// explainExclusions records why the peers in a topic were not selected to receive a message we
+// published.
+func (gs *GossipSubRouter) explainExclusions(topic string, msg *Message, tmap map[peer.ID]struct{}, tosend map[peer.ID]struct{}, res *PublishResult) {
+	backoff := gs.backoff[topic]
+	for p := range tmap {
+		if _, ok := tosend[p]; ok || p == msg.ReceivedFrom || p == peer.ID(msg.GetFrom()) {
+			continue
+		}
+
+		if gs.score.Score(p) < gs.publishThreshold {
+			res.BelowPublishThreshold++
+		} else if _, ok := backoff[p]; ok {
+			res.Backoff++
+		}
 	}
 }
 
 func (gs *GossipSubRouter) Join(topic string) {
+	gs.join(topic)
+	gs.idle.join(topic)
+	gs.gracefulLeave.join(topic)
+}
+
+func (gs *GossipSubRouter) join(topic string) {
 	gmap, ok := gs.mesh[topic]
 	if ok {
 		return
 	}
 
-	log.Debugf("JOIN %s", topic)
+	gs.p.logger.Debugw("JOIN", "topic", topic)
 	gs.tracer.Join(topic)
+	gs.resolveTopicScoreParams(topic)
+	d, _, _ := gs.meshDegree(topic)
 
 	gmap, ok = gs.fanout[topic]
 	if ok {
 		backoff := gs.backoff[topic]
 		// these peers have a score above the publish threshold, which may be negative
-		// so drop the ones with a negative score
+		// so drop the ones with a negative score, and the ones the application doesn't admit
 		for p := range gmap {
 			_, doBackOff := backoff[p]
-			if gs.score.Score(p) < 0 || doBackOff {
+			if gs.score.Score(p) < 0 || doBackOff || gs.transientPeer(p) || !gs.admitMeshCandidate(p, topic) {
 				delete(gmap, p)
 			}
 		}
 
-		if len(gmap) < gs.params.D {
+		if len(gmap) < d {
 			// we need more peers; eager, as this would get fixed in the next heartbeat
-			more := gs.getPeers(topic, gs.params.D-len(gmap), func(p peer.ID) bool {
-				// filter our current peers, direct peers, peers we are backing off, and
-				// peers with negative scores
+			more := gs.getAdmittedPeers(topic, d-len(gmap), func(p peer.ID) bool {
+				// filter our current peers, direct peers, peers we are backing off,
+				// peers with negative scores and peers over transient connections
 				_, inMesh := gmap[p]
 				_, direct := gs.direct[p]
 				_, doBackOff := backoff[p]
-				return !inMesh && !direct && !doBackOff && gs.score.Score(p) >= 0
+				return !inMesh && !direct && !doBackOff && gs.score.Score(p) >= 0 && !gs.transientPeer(p)
 			})
 			for _, p := range more {
 				gmap[p] = struct{}{}
@@ -1084,38 +1696,52 @@ func (gs *GossipSubRouter) Join(topic string) {
 		delete(gs.lastpub, topic)
 	} else {
 		backoff := gs.backoff[topic]
-		peers := gs.getPeers(topic, gs.params.D, func(p peer.ID) bool {
-			// filter direct peers, peers we are backing off and peers with negative score
+		peers := gs.getAdmittedPeers(topic, d, func(p peer.ID) bool {
+			// filter direct peers, peers we are backing off, peers with negative score and
+			// peers over transient connections
 			_, direct := gs.direct[p]
 			_, doBackOff := backoff[p]
-			return !direct && !doBackOff && gs.score.Score(p) >= 0
+			return !direct && !doBackOff && gs.score.Score(p) >= 0 && !gs.transientPeer(p)
 		})
 		gmap = peerListToMap(peers)
 		gs.mesh[topic] = gmap
 	}
 
 	for p := range gmap {
-		log.Debugf("JOIN: Add mesh link to %s in %s", p, topic)
+		gs.p.logger.Debugw("JOIN: Add mesh link", "peer", p, "topic", topic)
 		gs.tracer.Graft(p, topic)
 		gs.sendGraft(p, topic)
 	}
 }
 
 func (gs *GossipSubRouter) Leave(topic string) {
+	gs.idle.leave(topic)
+	gs.throttle.leave(topic)
+	gs.gracefulLeave.leave(topic)
+	gs.leave(topic)
+}
+
+func (gs *GossipSubRouter) leave(topic string) {
 	gmap, ok := gs.mesh[topic]
 	if !ok {
 		return
 	}
 
-	log.Debugf("LEAVE %s", topic)
+	gs.p.logger.Debugw("LEAVE", "topic", topic)
 	gs.tracer.Leave(topic)
 
 	delete(gs.mesh, topic)
+	delete(gs.pruneHistory, topic)
+
+	if gs.smallTopics[topic] {
+		delete(gs.smallTopics, topic)
+		gs.score.SetSmallNetwork(topic, false)
+	}
 
 	for p := range gmap {
-		log.Debugf("LEAVE: Remove mesh link to %s in %s", p, topic)
-		gs.tracer.Prune(p, topic)
-		gs.sendPrune(p, topic, true)
+		gs.p.logger.Debugw("LEAVE: Remove mesh link", "peer", p, "topic", topic)
+		gs.tracer.Prune(p, topic, PruneReasonLeave)
+		gs.sendPrune(p, topic, true, PruneReasonLeave)
 		// Add a backoff to this peer to prevent us from eagerly
 		// re-grafting this peer into our mesh if we rejoin this
 		// topic before the backoff period ends.
@@ -1129,13 +1755,14 @@ func (gs *GossipSubRouter) sendGraft(p peer.ID, topic string) {
 	gs.sendRPC(p, out)
 }
 
-func (gs *GossipSubRouter) sendPrune(p peer.ID, topic string, isUnsubscribe bool) {
-	prune := []*pb.ControlPrune{gs.makePrune(p, topic, gs.doPX, isUnsubscribe)}
+func (gs *GossipSubRouter) sendPrune(p peer.ID, topic string, isUnsubscribe bool, reason PruneReason) {
+	prune := []*pb.ControlPrune{gs.makePrune(p, topic, gs.doPX, isUnsubscribe, reason)}
 	out := rpcWithControl(nil, nil, nil, nil, prune)
 	gs.sendRPC(p, out)
 }
 
-func (gs *GossipSubRouter) sendRPC(p peer.ID, out *RPC) {
+// sendRPC sends an RPC to a peer, returning false if it had to be dropped.
+func (gs *GossipSubRouter) sendRPC(p peer.ID, out *RPC) bool {
 	// do we own the RPC?
 	own := false
 
@@ -1161,29 +1788,32 @@ func (gs *GossipSubRouter) sendRPC(p peer.ID, out *RPC) {
 
 	mch, ok := gs.p.peers[p]
 	if !ok {
-		return
+		return false
 	}
 
 	// If we're below the max message size, go ahead and send
 	if out.Size() < gs.p.maxMessageSize {
-		gs.doSendRPC(out, p, mch)
-		return
+		return gs.doSendRPC(out, p, mch)
 	}
 
 	// If we're too big, fragment into multiple RPCs and send each sequentially
 	outRPCs, err := fragmentRPC(out, gs.p.maxMessageSize)
 	if err != nil {
 		gs.doDropRPC(out, p, fmt.Sprintf("unable to fragment RPC: %s", err))
-		return
+		return false
 	}
 
+	sent := true
 	for _, rpc := range outRPCs {
-		gs.doSendRPC(rpc, p, mch)
+		if !gs.doSendRPC(rpc, p, mch) {
+			sent = false
+		}
 	}
+	return sent
 }
 
 func (gs *GossipSubRouter) doDropRPC(rpc *RPC, p peer.ID, reason string) {
-	log.Debugf("dropping message to peer %s: %s", p, reason)
+	gs.p.logger.Debugw("dropping message to peer", "peer", p, "reason", reason)
 	gs.tracer.DropRPC(rpc, p)
 	// push control messages that need to be retried
 	ctl := rpc.GetControl()
@@ -1192,12 +1822,15 @@ func (gs *GossipSubRouter) doDropRPC(rpc *RPC, p peer.ID, reason string) {
 	}
 }
 
-func (gs *GossipSubRouter) doSendRPC(rpc *RPC, p peer.ID, mch chan *RPC) {
+func (gs *GossipSubRouter) doSendRPC(rpc *RPC, p peer.ID, mch chan *RPC) bool {
 	select {
 	case mch <- rpc:
 		gs.tracer.SendRPC(rpc, p)
+		gs.p.enqueued(p, mch)
+		return true
 	default:
 		gs.doDropRPC(rpc, p, "queue full")
+		return false
 	}
 }
 
@@ -1231,7 +1864,7 @@ func fragmentRPC(rpc *RPC, limit int) ([]*RPC, error) {
 		return next
 	}
 
-	for _, msg := range rpc.GetPublish() {
+	for i, msg := range rpc.GetPublish() {
 		s := msg.Size()
 		// if an individual message is too large, we can't fragment it and have to fail entirely
 		if s > limit {
@@ -1239,6 +1872,7 @@ func fragmentRPC(rpc *RPC, limit int) ([]*RPC, error) {
 		}
 		out := outRPC(s, false)
 		out.Publish = append(out.Publish, msg)
+		out.setExpiry(len(out.Publish)-1, rpc.expiry(i))
 	}
 
 	for _, sub := range rpc.GetSubscriptions() {
@@ -1267,6 +1901,14 @@ func fragmentRPC(rpc *RPC, limit int) ([]*RPC, error) {
 		out := outRPC(prune.Size(), true)
 		out.Control.Prune = append(out.Control.Prune, prune)
 	}
+	for _, size := range ctl.TopicSize {
+		out := outRPC(size.Size(), true)
+		out.Control.TopicSize = append(out.Control.TopicSize, size)
+	}
+	if ctl.Probe != nil {
+		out := outRPC(ctl.Probe.Size(), true)
+		out.Control.Probe = ctl.Probe
+	}
 
 	// An individual IWANT or IHAVE message could be larger than the limit if we have
 	// a lot of message IDs. fragmentMessageIds will split them into buckets that
@@ -1342,35 +1984,125 @@ func (gs *GossipSubRouter) heartbeatTimer() {
 	}
 }
 
+// heartbeatWatchdog periodically checks for heartbeat progress and notifies when the heartbeat
+// has stalled.
+func (gs *GossipSubRouter) heartbeatWatchdog() {
+	ticker := time.NewTicker(gs.stallThreshold / 4)
+	defer ticker.Stop()
+
+	// the first heartbeat is expected after the initial delay
+	start := time.Now().Add(gs.params.HeartbeatInitialDelay)
+
+	var notified uint64
+	stalled := false
+	for {
+		select {
+		case now := <-ticker.C:
+			last, seq := gs.lastHeartbeat()
+			if seq == 0 {
+				last = start
+			}
+			if stalled && seq == notified {
+				continue
+			}
+			stalled = false
+
+			if lag := now.Sub(last); lag > gs.stallThreshold {
+				stalled = true
+				notified = seq
+				gs.p.logger.Warnw("heartbeat stalled", "lag", lag, "heartbeat", seq)
+				gs.stallNotify(lag)
+			}
+
+		case <-gs.p.ctx.Done():
+			return
+		}
+	}
+}
+
+// lastHeartbeat returns the completion time and number of the last heartbeat.
+func (gs *GossipSubRouter) lastHeartbeat() (time.Time, uint64) {
+	gs.hbMx.Lock()
+	defer gs.hbMx.Unlock()
+
+	return gs.hbLast, gs.hbSeq
+}
+
 func (gs *GossipSubRouter) heartbeat() {
 	start := time.Now()
 	defer func() {
-		if gs.params.SlowHeartbeatWarning > 0 {
+		end := time.Now()
+		dt := end.Sub(start)
+
+		gs.hbMx.Lock()
+		gs.hbLast = end
+		gs.hbSeq = gs.heartbeatTicks
+		if len(gs.hbRecent) == recentHeartbeats {
+			copy(gs.hbRecent, gs.hbRecent[1:])
+			gs.hbRecent = gs.hbRecent[:recentHeartbeats-1]
+		}
+		gs.hbRecent = append(gs.hbRecent, dt)
+		gs.hbMx.Unlock()
+
+		if dt > gs.params.HeartbeatInterval {
+			// this is bad, as it delays the next heartbeat; only warn once in a while though
+			// so that we don't spam the logs when the node is overloaded.
+			if end.Sub(gs.lastOverrunWarning) > heartbeatOverrunWarningInterval {
+				gs.lastOverrunWarning = end
+				gs.p.logger.Warnw("heartbeat took longer than the heartbeat interval", "took", dt, "interval", gs.params.HeartbeatInterval)
+			}
+		} else if gs.params.SlowHeartbeatWarning > 0 {
 			slowWarning := time.Duration(gs.params.SlowHeartbeatWarning * float64(gs.params.HeartbeatInterval))
-			if dt := time.Since(start); dt > slowWarning {
-				log.Warnw("slow heartbeat", "took", dt)
+			if dt > slowWarning {
+				gs.p.logger.Warnw("slow heartbeat", "took", dt)
 			}
 		}
 	}()
 
 	gs.heartbeatTicks++
+	gs.resetGraftAdmission()
 
 	tograft := make(map[peer.ID][]string)
 	toprune := make(map[peer.ID][]string)
+	pruneReasons := make(map[peer.ID]map[string]PruneReason)
 	noPX := make(map[peer.ID]bool)
 
 	// clean up expired backoffs
 	gs.clearBackoff()
 
+	// forget the prunes with peers that have been in the mesh long enough
+	gs.resetStablePrunes()
+
 	// clean up iasked counters
 	gs.clearIHaveCounters()
 
 	// apply IWANT request penalties
 	gs.applyIwantPenalties()
 
+	// forget the IWANTs we are no longer waiting for
+	gs.pendingIWants.expire(time.Now())
+
+	// release px peers that didn't confirm in time
+	gs.releasePXPeers()
+
+	// probe the liveness of silent mesh peers, and prune the unresponsive ones
+	gs.probeMesh(toprune, pruneReasons)
+
 	// ensure direct peers are connected
 	gs.directConnect()
 
+	// act on the topics that have gone idle
+	gs.checkIdleTopics()
+
+	// throttle the topics with slow subscribers
+	gs.checkSlowConsumers()
+
+	// announce our subscriptions again to the peers that may have missed them
+	gs.reannounceSubscriptions()
+
+	// forget the topics we left gracefully once their messages are gone
+	gs.expireGracefulLeaves()
+
 	// cache scores throughout the heartbeat
 	scores := make(map[peer.ID]float64)
 	score := func(p peer.ID) float64 {
@@ -1384,16 +2116,25 @@ func (gs *GossipSubRouter) heartbeat() {
 
 	// maintain the mesh for topics we have joined
 	for topic, peers := range gs.mesh {
-		prunePeer := func(p peer.ID) {
-			gs.tracer.Prune(p, topic)
+		small := gs.updateSmallNetwork(topic)
+		d, dlo, dhi := gs.meshDegree(topic)
+
+		prunePeer := func(p peer.ID, reason PruneReason) {
+			gs.tracer.Prune(p, topic, reason)
 			delete(peers, p)
 			gs.addBackoff(p, topic, false)
 			topics := toprune[p]
 			toprune[p] = append(topics, topic)
+			reasons, ok := pruneReasons[p]
+			if !ok {
+				reasons = make(map[string]PruneReason)
+				pruneReasons[p] = reasons
+			}
+			reasons[topic] = reason
 		}
 
 		graftPeer := func(p peer.ID) {
-			log.Debugf("HEARTBEAT: Add mesh link to %s in %s", p, topic)
+			gs.p.logger.Debugw("HEARTBEAT: Add mesh link", "peer", p, "topic", topic)
 			gs.tracer.Graft(p, topic)
 			peers[p] = struct{}{}
 			topics := tograft[p]
@@ -1403,22 +2144,23 @@ func (gs *GossipSubRouter) heartbeat() {
 		// drop all peers with negative score, without PX
 		for p := range peers {
 			if score(p) < 0 {
-				log.Debugf("HEARTBEAT: Prune peer %s with negative score [score = %f, topic = %s]", p, score(p), topic)
-				prunePeer(p)
+				gs.p.logger.Debugw("HEARTBEAT: Prune peer with negative score", "peer", p, "topic", topic, "score", score(p))
+				prunePeer(p, PruneReasonLowScore)
 				noPX[p] = true
 			}
 		}
 
 		// do we have enough peers?
-		if l := len(peers); l < gs.params.Dlo {
+		if l := len(peers); l < dlo {
 			backoff := gs.backoff[topic]
-			ineed := gs.params.D - l
-			plst := gs.getPeers(topic, ineed, func(p peer.ID) bool {
-				// filter our current and direct peers, peers we are backing off, and peers with negative score
+			ineed := d - l
+			plst := gs.getAdmittedPeers(topic, ineed, func(p peer.ID) bool {
+				// filter our current and direct peers, peers we are backing off, peers with negative
+				// score and peers over transient connections
 				_, inMesh := peers[p]
 				_, doBackoff := backoff[p]
 				_, direct := gs.direct[p]
-				return !inMesh && !doBackoff && !direct && score(p) >= 0
+				return !inMesh && !doBackoff && !direct && score(p) >= 0 && !gs.transientPeer(p)
 			})
 
 			for _, p := range plst {
@@ -1426,23 +2168,27 @@ func (gs *GossipSubRouter) heartbeat() {
 			}
 		}
 
-		// do we have too many peers?
-		if len(peers) > gs.params.Dhi {
+		// do we have too many peers? (we never prune in small network mode, to avoid churn)
+		if len(peers) > dhi && !small {
 			plst := peerMapToList(peers)
 
 			// sort by score (but shuffle first for the case we don't use the score)
-			shufflePeers(plst)
+			gs.shufflePeers(plst)
 			sort.Slice(plst, func(i, j int) bool {
 				return score(plst[i]) > score(plst[j])
 			})
 
 			// We keep the first D_score peers by score and the remaining up to D randomly
 			// under the constraint that we keep D_out peers in the mesh (if we have that many)
-			shufflePeers(plst[gs.params.Dscore:])
+			dscore := gs.params.Dscore
+			if dscore > d {
+				dscore = d
+			}
+			gs.shufflePeers(plst[dscore:])
 
 			// count the outbound peers we are keeping
 			outbound := 0
-			for _, p := range plst[:gs.params.D] {
+			for _, p := range plst[:d] {
 				if gs.outbound[p] {
 					outbound++
 				}
@@ -1462,7 +2208,7 @@ func (gs *GossipSubRouter) heartbeat() {
 				// first bubble up all outbound peers already in the selection to the front
 				if outbound > 0 {
 					ihave := outbound
-					for i := 1; i < gs.params.D && ihave > 0; i++ {
+					for i := 1; i < d && ihave > 0; i++ {
 						p := plst[i]
 						if gs.outbound[p] {
 							rotate(i)
@@ -1473,7 +2219,7 @@ func (gs *GossipSubRouter) heartbeat() {
 
 				// now bubble up enough outbound peers outside the selection to the front
 				ineed := gs.params.Dout - outbound
-				for i := gs.params.D; i < len(plst) && ineed > 0; i++ {
+				for i := d; i < len(plst) && ineed > 0; i++ {
 					p := plst[i]
 					if gs.outbound[p] {
 						rotate(i)
@@ -1483,14 +2229,14 @@ func (gs *GossipSubRouter) heartbeat() {
 			}
 
 			// prune the excess peers
-			for _, p := range plst[gs.params.D:] {
-				log.Debugf("HEARTBEAT: Remove mesh link to %s in %s", p, topic)
-				prunePeer(p)
+			for _, p := range plst[d:] {
+				gs.p.logger.Debugw("HEARTBEAT: Remove mesh link", "peer", p, "topic", topic)
+				prunePeer(p, PruneReasonOversubscribed)
 			}
 		}
 
 		// do we have enough outboud peers?
-		if len(peers) >= gs.params.Dlo {
+		if len(peers) >= dlo {
 			// count the outbound peers we have
 			outbound := 0
 			for p := range peers {
@@ -1503,12 +2249,13 @@ func (gs *GossipSubRouter) heartbeat() {
 			if outbound < gs.params.Dout {
 				ineed := gs.params.Dout - outbound
 				backoff := gs.backoff[topic]
-				plst := gs.getPeers(topic, ineed, func(p peer.ID) bool {
-					// filter our current and direct peers, peers we are backing off, and peers with negative score
+				plst := gs.getAdmittedPeers(topic, ineed, func(p peer.ID) bool {
+					// filter our current and direct peers, peers we are backing off, peers with negative
+					// score and peers over transient connections
 					_, inMesh := peers[p]
 					_, doBackoff := backoff[p]
 					_, direct := gs.direct[p]
-					return !inMesh && !doBackoff && !direct && gs.outbound[p] && score(p) >= 0
+					return !inMesh && !doBackoff && !direct && gs.outbound[p] && score(p) >= 0 && !gs.transientPeer(p)
 				})
 
 				for _, p := range plst {
@@ -1537,15 +2284,15 @@ func (gs *GossipSubRouter) heartbeat() {
 			// if the median score is below the threshold, select a better peer (if any) and GRAFT
 			if medianScore < gs.opportunisticGraftThreshold {
 				backoff := gs.backoff[topic]
-				plst = gs.getPeers(topic, gs.params.OpportunisticGraftPeers, func(p peer.ID) bool {
+				plst = gs.getAdmittedPeers(topic, gs.params.OpportunisticGraftPeers, func(p peer.ID) bool {
 					_, inMesh := peers[p]
 					_, doBackoff := backoff[p]
 					_, direct := gs.direct[p]
-					return !inMesh && !doBackoff && !direct && score(p) > medianScore
+					return !inMesh && !doBackoff && !direct && score(p) > medianScore && !gs.transientPeer(p)
 				})
 
 				for _, p := range plst {
-					log.Debugf("HEARTBEAT: Opportunistically graft peer %s on topic %s", p, topic)
+					gs.p.logger.Debugw("HEARTBEAT: Opportunistically graft peer", "peer", p, "topic", topic)
 					graftPeer(p)
 				}
 			}
@@ -1596,7 +2343,11 @@ func (gs *GossipSubRouter) heartbeat() {
 	}
 
 	// send coalesced GRAFT/PRUNE messages (will piggyback gossip)
-	gs.sendGraftPrune(tograft, toprune, noPX)
+	gs.sendGraftPrune(tograft, toprune, pruneReasons, noPX)
+
+	// update and send the topic membership sketches (will piggyback gossip)
+	gs.updateTopicSizes()
+	gs.sendTopicSizes()
 
 	// flush all pending gossip that wasn't piggybacked above
 	gs.flush()
@@ -1615,12 +2366,37 @@ func (gs *GossipSubRouter) clearIHaveCounters() {
 		// throw away the old map and make a new one
 		gs.iasked = make(map[peer.ID]int)
 	}
+
+	if len(gs.topichave) > 0 {
+		// throw away the old map and make a new one
+		gs.topichave = make(map[peer.ID]map[string]*ihaveCounts)
+	}
+}
+
+func (gs *GossipSubRouter) topicIHaveCounts(p peer.ID, topic string) *ihaveCounts {
+	topics, ok := gs.topichave[p]
+	if !ok {
+		topics = make(map[string]*ihaveCounts)
+		gs.topichave[p] = topics
+	}
+
+	counts, ok := topics[topic]
+	if !ok {
+		counts = new(ihaveCounts)
+		topics[topic] = counts
+	}
+
+	return counts
 }
 
 func (gs *GossipSubRouter) applyIwantPenalties() {
 	for p, count := range gs.gossipTracer.GetBrokenPromises() {
-		log.Infof("peer %s didn't follow up in %d IWANT requests; adding penalty", p, count)
-		gs.score.AddPenalty(p, count)
+		if _, ok := gs.promiseExempt[p]; ok {
+			gs.p.logger.Debugw("peer didn't follow up in IWANT requests; exempt from penalty", "peer", p, "requests", count)
+			continue
+		}
+		gs.p.logger.Infow("peer didn't follow up in IWANT requests; adding penalty", "peer", p, "requests", count)
+		gs.score.AddPenalty(p, count, PenaltyBrokenPromise)
 	}
 }
 
@@ -1669,7 +2445,7 @@ func (gs *GossipSubRouter) directConnect() {
 	}
 }
 
-func (gs *GossipSubRouter) sendGraftPrune(tograft, toprune map[peer.ID][]string, noPX map[peer.ID]bool) {
+func (gs *GossipSubRouter) sendGraftPrune(tograft, toprune map[peer.ID][]string, pruneReasons map[peer.ID]map[string]PruneReason, noPX map[peer.ID]bool) {
 	for p, topics := range tograft {
 		graft := make([]*pb.ControlGraft, 0, len(topics))
 		for _, topic := range topics {
@@ -1687,7 +2463,7 @@ func (gs *GossipSubRouter) sendGraftPrune(tograft, toprune map[peer.ID][]string,
 			delete(toprune, p)
 			prune = make([]*pb.ControlPrune, 0, len(pruning))
 			for _, topic := range pruning {
-				prune = append(prune, gs.makePrune(p, topic, gs.doPX && !noPX[p], false))
+				prune = append(prune, gs.makePrune(p, topic, gs.doPX && !noPX[p], false, pruneReasons[p][topic]))
 			}
 		}
 
@@ -1698,7 +2474,7 @@ func (gs *GossipSubRouter) sendGraftPrune(tograft, toprune map[peer.ID][]string,
 	for p, topics := range toprune {
 		prune := make([]*pb.ControlPrune, 0, len(topics))
 		for _, topic := range topics {
-			prune = append(prune, gs.makePrune(p, topic, gs.doPX && !noPX[p], false))
+			prune = append(prune, gs.makePrune(p, topic, gs.doPX && !noPX[p], false, pruneReasons[p][topic]))
 		}
 
 		out := rpcWithControl(nil, nil, nil, nil, prune)
@@ -1706,21 +2482,63 @@ func (gs *GossipSubRouter) sendGraftPrune(tograft, toprune map[peer.ID][]string,
 	}
 }
 
+// updateSmallNetwork updates the small network mode of a topic we have joined, based on the number
+// of peers we know in the topic, and returns whether the topic is in small network mode.
+func (gs *GossipSubRouter) updateSmallNetwork(topic string) bool {
+	if !gs.params.AdaptToSmallNetworks {
+		return false
+	}
+
+	small := gs.smallTopics[topic]
+	npeers := len(gs.p.topics[topic])
+
+	switch {
+	case !small && npeers < gs.params.Dlo:
+		gs.p.logger.Debugw("HEARTBEAT: Entering small network mode", "topic", topic, "peers", npeers)
+		gs.smallTopics[topic] = true
+		gs.score.SetSmallNetwork(topic, true)
+		return true
+
+	case small && npeers > gs.params.Dhi:
+		gs.p.logger.Debugw("HEARTBEAT: Leaving small network mode", "topic", topic, "peers", npeers)
+		delete(gs.smallTopics, topic)
+		gs.score.SetSmallNetwork(topic, false)
+		return false
+	}
+
+	return small
+}
+
 // emitGossip emits IHAVE gossip advertising items in the message cache window
 // of this topic.
 func (gs *GossipSubRouter) emitGossip(topic string, exclude map[peer.ID]struct{}) {
+	// we don't advertise messages we wouldn't send
+	if !gs.p.forwarding(topic, false) {
+		return
+	}
+
+	// nor in downgraded idle topics
+	if gs.idle.downgraded(topic) {
+		return
+	}
+
 	mids := gs.mcache.GetGossipIDs(topic)
 	if len(mids) == 0 {
 		return
 	}
 
 	// shuffle to emit in random order
-	shuffleStrings(mids)
+	gs.shuffleStrings(mids)
+
+	maxIHaveLength := gs.params.MaxIHaveLength
+	if limits, ok := gs.topicIHaveLimits[topic]; ok {
+		maxIHaveLength = limits.maxIHaveLength
+	}
 
 	// if we are emitting more than GossipSubMaxIHaveLength mids, truncate the list
-	if len(mids) > gs.params.MaxIHaveLength {
+	if len(mids) > maxIHaveLength {
 		// we do the truncation (with shuffling) per peer below
-		log.Debugf("too many messages for gossip; will truncate IHAVE list (%d messages)", len(mids))
+		gs.p.logger.Debugw("too many messages for gossip; will truncate IHAVE list", "topic", topic, "messages", len(mids))
 	}
 
 	// Send gossip to GossipFactor peers above threshold, with a minimum of D_lazy.
@@ -1736,7 +2554,10 @@ func (gs *GossipSubRouter) emitGossip(topic string, exclude map[peer.ID]struct{}
 		}
 	}
 
-	if len(peers) < gs.params.Dlo {
+	// in small network mode, we emit gossip to all of them
+	small := gs.smallTopics[topic]
+
+	if len(peers) < gs.params.Dlo && !small {
 		for p := range gs.p.topics[topic] {
 			if gs.feature(GossipSubFeatureMesh, gs.peers[p]) {
 				peers = append(peers, p)
@@ -1751,25 +2572,37 @@ func (gs *GossipSubRouter) emitGossip(topic string, exclude map[peer.ID]struct{}
 	if factor > target {
 		target = factor
 	}
+	if small {
+		target = len(peers)
+	}
 
 	if target > len(peers) {
 		target = len(peers)
 	} else {
-		shufflePeers(peers)
+		gs.shufflePeers(peers)
 	}
 	peers = peers[:target]
 
-	// Emit the IHAVE gossip to the selected peers.
+	// Emit the IHAVE gossip to the selected peers, leaving out the messages they already know of
+	// from us.
 	for _, p := range peers {
-		peerMids := mids
-		if len(mids) > gs.params.MaxIHaveLength {
+		peerMids := make([]string, 0, len(mids))
+		for _, mid := range mids {
+			if !gs.mcache.Advertised(mid, p) {
+				peerMids = append(peerMids, mid)
+			}
+		}
+		if len(peerMids) == 0 {
+			continue
+		}
+		if len(peerMids) > maxIHaveLength {
 			// we do this per peer so that we emit a different set for each peer.
 			// we have enough redundancy in the system that this will significantly increase the message
 			// coverage when we do truncate.
-			peerMids = make([]string, gs.params.MaxIHaveLength)
-			shuffleStrings(mids)
-			copy(peerMids, mids)
+			gs.shuffleStrings(peerMids)
+			peerMids = peerMids[:maxIHaveLength]
 		}
+		gs.mcache.MarkAdvertised(p, peerMids)
 		gs.enqueueGossip(p, &pb.ControlIHave{TopicID: &topic, MessageIDs: peerMids})
 	}
 }
@@ -1863,13 +2696,26 @@ func (gs *GossipSubRouter) piggybackControl(p peer.ID, out *RPC, ctl *pb.Control
 	}
 }
 
-func (gs *GossipSubRouter) makePrune(p peer.ID, topic string, doPX bool, isUnsubscribe bool) *pb.ControlPrune {
+func (gs *GossipSubRouter) makePrune(p peer.ID, topic string, doPX bool, isUnsubscribe bool, reason PruneReason) *pb.ControlPrune {
+	// only disclose the reason if we are configured to; peers that don't know about it ignore it
+	var preason *pb.ControlPrune_Reason
+	if gs.sendPruneReasons[reason] {
+		preason = reason.pb()
+	}
+
+	// hint that we are leaving the topic when we leave it gracefully; peers that don't know
+	// about the hint ignore it
+	var leaving *bool
+	if isUnsubscribe && gs.gracefulLeave.leaving(topic) {
+		leaving = &isUnsubscribe
+	}
+
 	if !gs.feature(GossipSubFeaturePX, gs.peers[p]) {
 		// GossipSub v1.0 -- no peer exchange, the peer won't be able to parse it anyway
-		return &pb.ControlPrune{TopicID: &topic}
+		return &pb.ControlPrune{TopicID: &topic, Reason: preason, Leaving: leaving}
 	}
 
-	backoff := uint64(gs.params.PruneBackoff / time.Second)
+	backoff := uint64(gs.pruneBackoff(p, topic) / time.Second)
 	if isUnsubscribe {
 		backoff = uint64(gs.params.UnsubscribeBackoff / time.Second)
 	}
@@ -1878,10 +2724,14 @@ func (gs *GossipSubRouter) makePrune(p peer.ID, topic string, doPX bool, isUnsub
 	if doPX {
 		// select peers for Peer eXchange
 		peers := gs.getPeers(topic, gs.params.PrunePeers, func(xp peer.ID) bool {
-			return p != xp && gs.score.Score(xp) >= 0
+			return p != xp && gs.score.Score(xp) >= 0 && !gs.transientPeer(xp)
 		})
 
-		cab, ok := peerstore.GetCertifiedAddrBook(gs.p.host.Peerstore())
+		var cab peerstore.CertifiedAddrBook
+		ok := false
+		if gs.p.host != nil {
+			cab, ok = peerstore.GetCertifiedAddrBook(gs.p.host.Peerstore())
+		}
 		px = make([]*pb.PeerInfo, 0, len(peers))
 		for _, p := range peers {
 			// see if we have a signed peer record to send back; if we don't, just send
@@ -1894,7 +2744,7 @@ func (gs *GossipSubRouter) makePrune(p peer.ID, topic string, doPX bool, isUnsub
 				if spr != nil {
 					recordBytes, err = spr.Marshal()
 					if err != nil {
-						log.Warnf("error marshaling signed peer record for %s: %s", p, err)
+						gs.p.logger.Warnw("error marshaling signed peer record", "peer", p, "err", err)
 					}
 				}
 			}
@@ -1902,7 +2752,7 @@ func (gs *GossipSubRouter) makePrune(p peer.ID, topic string, doPX bool, isUnsub
 		}
 	}
 
-	return &pb.ControlPrune{TopicID: &topic, Peers: px, Backoff: &backoff}
+	return &pb.ControlPrune{TopicID: &topic, Peers: px, Backoff: &backoff, Reason: preason, Leaving: leaving}
 }
 
 func (gs *GossipSubRouter) getPeers(topic string, count int, filter func(peer.ID) bool) []peer.ID {
@@ -1913,12 +2763,12 @@ func (gs *GossipSubRouter) getPeers(topic string, count int, filter func(peer.ID
 
 	peers := make([]peer.ID, 0, len(tmap))
 	for p := range tmap {
-		if gs.feature(GossipSubFeatureMesh, gs.peers[p]) && filter(p) && gs.p.peerFilter(p, topic) {
+		if gs.feature(GossipSubFeatureMesh, gs.peers[p]) && gs.confirmed[p] && filter(p) && gs.p.peerFilter(p, topic) {
 			peers = append(peers, p)
 		}
 	}
 
-	shufflePeers(peers)
+	gs.shufflePeers(peers)
 
 	if count > 0 && len(peers) > count {
 		peers = peers[:count]
@@ -1951,23 +2801,53 @@ func peerMapToList(peers map[peer.ID]struct{}) []peer.ID {
 	return plst
 }
 
-func shufflePeers(peers []peer.ID) {
+func (gs *GossipSubRouter) shufflePeers(peers []peer.ID) {
+	if gs.fixedRand {
+		sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+	}
+	shufflePeers(gs.rng, peers)
+}
+
+func (gs *GossipSubRouter) shufflePeerInfo(peers []*pb.PeerInfo) {
+	if gs.fixedRand {
+		sort.Slice(peers, func(i, j int) bool { return string(peers[i].PeerID) < string(peers[j].PeerID) })
+	}
+	shufflePeerInfo(gs.rng, peers)
+}
+
+func (gs *GossipSubRouter) shuffleStrings(lst []string) {
+	if gs.fixedRand {
+		sort.Strings(lst)
+	}
+	shuffleStrings(gs.rng, lst)
+}
+
+func shufflePeers(rng *rand.Rand, peers []peer.ID) {
 	for i := range peers {
-		j := rand.Intn(i + 1)
+		j := rng.Intn(i + 1)
 		peers[i], peers[j] = peers[j], peers[i]
 	}
 }
 
-func shufflePeerInfo(peers []*pb.PeerInfo) {
+func shufflePeerInfo(rng *rand.Rand, peers []*pb.PeerInfo) {
 	for i := range peers {
-		j := rand.Intn(i + 1)
+		j := rng.Intn(i + 1)
 		peers[i], peers[j] = peers[j], peers[i]
 	}
 }
 
-func shuffleStrings(lst []string) {
+func shuffleStrings(rng *rand.Rand, lst []string) {
 	for i := range lst {
-		j := rand.Intn(i + 1)
+		j := rng.Intn(i + 1)
 		lst[i], lst[j] = lst[j], lst[i]
 	}
 }
+
+// newRand returns a random number generator seeded from crypto/rand.
+func newRand() *rand.Rand {
+	var seed [8]byte
+	if _, err := crand.Read(seed[:]); err != nil {
+		return rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return rand.New(rand.NewSource(int64(binary.LittleEndian.Uint64(seed[:]))))
+}
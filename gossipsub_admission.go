@@ -0,0 +1,97 @@
+package pubsub
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// GraftAdmissionBudget is the time the graft admission callback set with WithGraftAdmissionControl
+// may take in total in a heartbeat interval; once it is spent, the candidates we would graft are
+// refused until the next heartbeat.
+var GraftAdmissionBudget = 10 * time.Millisecond
+
+// GraftAdmissionFn decides whether a peer may join our mesh for a topic.
+type GraftAdmissionFn func(p peer.ID, topic string) bool
+
+// WithGraftAdmissionControl is a gossipsub router option that sets a callback vetoing peers from
+// our mesh for a topic, regardless of their score, e.g. to enforce a per topic allowlist. It is
+// consulted when a peer grafts us, in which case a veto prunes the peer with a backoff, and before
+// we graft a peer when joining a topic or filling the mesh in the heartbeat, including
+// opportunistic grafting.
+// The callback runs on the event loop, so it must be fast: once the callback has taken
+// GraftAdmissionBudget in a heartbeat interval, we stop grafting peers until the next heartbeat,
+// though the peers grafting us are still checked. If the callback panics, the peer is admitted.
+func WithGraftAdmissionControl(admit GraftAdmissionFn) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+
+		gs.graftAdmission = admit
+
+		return nil
+	}
+}
+
+// admitGraft consults the graft admission callback, if any, accounting the time it takes against
+// the budget of the heartbeat interval.
+func (gs *GossipSubRouter) admitGraft(p peer.ID, topic string) (admit bool) {
+	if gs.graftAdmission == nil {
+		return true
+	}
+
+	start := time.Now()
+	defer func() {
+		gs.graftAdmissionSpent += time.Since(start)
+		if r := recover(); r != nil {
+			gs.p.logger.Errorw("panic in graft admission callback; admitting peer", "peer", p, "topic", topic, "panic", r)
+			admit = true
+		}
+	}()
+
+	return gs.graftAdmission(p, topic)
+}
+
+// admitMeshCandidate consults the graft admission callback for a peer we would graft, refusing
+// the peer if the budget of the heartbeat interval is spent.
+func (gs *GossipSubRouter) admitMeshCandidate(p peer.ID, topic string) bool {
+	if gs.graftAdmission != nil && gs.graftAdmissionSpent >= GraftAdmissionBudget {
+		if !gs.graftAdmissionExhausted {
+			gs.graftAdmissionExhausted = true
+			gs.p.logger.Warnw("graft admission budget spent; refusing candidates until the next heartbeat", "topic", topic, "budget", GraftAdmissionBudget)
+		}
+		return false
+	}
+
+	return gs.admitGraft(p, topic)
+}
+
+// resetGraftAdmission starts a new graft admission budget for the heartbeat interval.
+func (gs *GossipSubRouter) resetGraftAdmission() {
+	gs.graftAdmissionSpent = 0
+	gs.graftAdmissionExhausted = false
+}
+
+// getAdmittedPeers is like getPeers, but only returns peers admitted by the graft admission
+// callback, which is consulted for the selected candidates only.
+func (gs *GossipSubRouter) getAdmittedPeers(topic string, count int, filter func(peer.ID) bool) []peer.ID {
+	if gs.graftAdmission == nil {
+		return gs.getPeers(topic, count, filter)
+	}
+
+	candidates := gs.getPeers(topic, 0, filter)
+	peers := candidates[:0]
+	for _, p := range candidates {
+		if count > 0 && len(peers) == count {
+			break
+		}
+		if gs.admitMeshCandidate(p, topic) {
+			peers = append(peers, p)
+		}
+	}
+
+	return peers
+}
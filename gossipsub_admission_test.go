@@ -0,0 +1,123 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestGossipsubGraftAdmissionControl(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "foobar"
+	hosts := getNetHosts(t, ctx, 3)
+
+	params := DefaultGossipSubParams()
+	params.HeartbeatInterval = 100 * time.Millisecond
+
+	// A doesn't admit B, which grafts A regardless
+	vetoed := hosts[1].ID()
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0], WithGossipSubParams(params), WithGraftAdmissionControl(func(p peer.ID, tp string) bool {
+			return !(p == vetoed && tp == topic)
+		})),
+		getGossipsub(ctx, hosts[1], WithGossipSubParams(params)),
+		getGossipsub(ctx, hosts[2], WithGossipSubParams(params)),
+	}
+	for _, ps := range psubs {
+		if _, err := ps.Subscribe(topic); err != nil {
+			t.Fatal(err)
+		}
+	}
+	connectAll(t, hosts)
+
+	inMesh := func(ps *PubSub, p peer.ID) bool {
+		res := make(chan bool, 1)
+		ps.eval <- func() {
+			_, ok := ps.rt.(*GossipSubRouter).mesh[topic][p]
+			res <- ok
+		}
+		return <-res
+	}
+
+	// A never grafts B; B is pruned by A, and backs off
+	for i := 0; i < 30; i++ {
+		if inMesh(psubs[0], hosts[1].ID()) {
+			t.Fatal("expected the vetoed peer to never be in the mesh")
+		}
+		if i >= 10 && inMesh(psubs[1], hosts[0].ID()) {
+			t.Fatal("expected the vetoed peer to be pruned")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// the other peers are admitted
+	if !inMesh(psubs[0], hosts[2].ID()) || !inMesh(psubs[2], hosts[0].ID()) {
+		t.Fatal("expected the admitted peers in the mesh")
+	}
+	if !inMesh(psubs[1], hosts[2].ID()) || !inMesh(psubs[2], hosts[1].ID()) {
+		t.Fatal("expected the peers without admission control in the mesh")
+	}
+}
+
+func TestGossipsubGraftAdmissionFallback(t *testing.T) {
+	gs := &GossipSubRouter{p: &PubSub{logger: log}}
+	if !gs.admitGraft("peer", "foobar") {
+		t.Fatal("expected peers to be admitted without a callback")
+	}
+
+	gs.graftAdmission = func(peer.ID, string) bool { return false }
+	if gs.admitGraft("peer", "foobar") {
+		t.Fatal("expected the callback to veto the peer")
+	}
+
+	// peers are admitted when the callback panics
+	gs.graftAdmission = func(peer.ID, string) bool { panic("boom") }
+	if !gs.admitGraft("peer", "foobar") {
+		t.Fatal("expected the peer to be admitted when the callback panics")
+	}
+}
+
+func TestGossipsubGraftAdmissionBudget(t *testing.T) {
+	gs := &GossipSubRouter{p: &PubSub{logger: log}}
+
+	var calls int
+	gs.graftAdmission = func(peer.ID, string) bool {
+		calls++
+		if calls == 2 {
+			time.Sleep(GraftAdmissionBudget)
+		}
+		return true
+	}
+
+	// the candidates are admitted until the callback has taken the budget of the interval
+	for i := 0; i < 2; i++ {
+		if !gs.admitMeshCandidate("peer", "foobar") {
+			t.Fatalf("expected candidate %d to be admitted within the budget", i)
+		}
+	}
+	if gs.admitMeshCandidate("peer", "foobar") {
+		t.Fatal("expected the candidate to be refused once the budget is spent")
+	}
+	if calls != 2 {
+		t.Fatalf("expected the callback to be called twice, got %d", calls)
+	}
+
+	// the peers grafting us are still checked
+	gs.graftAdmission = func(peer.ID, string) bool {
+		calls++
+		return false
+	}
+	if gs.admitGraft("peer", "foobar") || calls != 3 {
+		t.Fatal("expected the callback to veto the grafting peer")
+	}
+
+	// until the next heartbeat
+	gs.resetGraftAdmission()
+	if gs.admitMeshCandidate("peer", "foobar") || calls != 4 {
+		t.Fatal("expected the callback to be consulted again in the next heartbeat")
+	}
+}
@@ -0,0 +1,138 @@
+package pubsub
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// BackoffStrategy computes the backoff applied to a peer after it has been pruned from the mesh of
+// a topic, or after it pruned us; consecutivePrunes counts the prunes with that peer in the topic
+// since the mesh link was last stable, including the current one, so it is at least 1.
+// Strategies are called from the event loop, so they must not block.
+type BackoffStrategy interface {
+	NextBackoff(p peer.ID, topic string, consecutivePrunes int) time.Duration
+}
+
+// ConstantBackoffStrategy is a BackoffStrategy that always backs off for the same duration; this
+// is the behaviour of the router without a strategy, with GossipSubParams.PruneBackoff.
+type ConstantBackoffStrategy time.Duration
+
+func (s ConstantBackoffStrategy) NextBackoff(peer.ID, string, int) time.Duration {
+	return time.Duration(s)
+}
+
+// ExponentialBackoffStrategy is a BackoffStrategy that doubles the backoff with every consecutive
+// prune, starting from Base and up to Max.
+type ExponentialBackoffStrategy struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func (s ExponentialBackoffStrategy) NextBackoff(_ peer.ID, _ string, consecutivePrunes int) time.Duration {
+	backoff := s.Base
+	for i := 1; i < consecutivePrunes && backoff < s.Max; i++ {
+		backoff *= 2
+	}
+	if backoff > s.Max {
+		backoff = s.Max
+	}
+	return backoff
+}
+
+// WithPruneBackoffStrategy is a gossipsub router option that computes the prune backoff with a
+// strategy, instead of using GossipSubParams.PruneBackoff for every prune. The strategy sets the
+// backoff we advertise in the PRUNEs we send, and applies locally when a peer prunes us, as the
+// maximum of its own backoff and the one requested by the peer. The count of consecutive prunes
+// with a peer in a topic is reset once the peer has stayed in the mesh for stablePeriod.
+// The backoff after unsubscribing is still GossipSubParams.UnsubscribeBackoff.
+func WithPruneBackoffStrategy(strategy BackoffStrategy, stablePeriod time.Duration) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+		if strategy == nil {
+			return fmt.Errorf("nil backoff strategy")
+		}
+		if stablePeriod <= 0 {
+			return fmt.Errorf("stable mesh period must be positive")
+		}
+
+		gs.backoffStrategy = strategy
+		gs.backoffStablePeriod = stablePeriod
+		gs.pruneHistory = make(map[string]map[peer.ID]*pruneHistory)
+		return nil
+	}
+}
+
+// pruneHistory tracks the consecutive prunes with a peer in a topic.
+type pruneHistory struct {
+	prunes int
+	// when the peer was first seen in the mesh by the heartbeat since the last prune
+	meshSince time.Time
+}
+
+// nextPruneBackoff records a prune with a peer in a topic and returns the backoff to apply.
+func (gs *GossipSubRouter) nextPruneBackoff(p peer.ID, topic string) time.Duration {
+	if gs.backoffStrategy == nil {
+		return gs.params.PruneBackoff
+	}
+
+	history, ok := gs.pruneHistory[topic]
+	if !ok {
+		history = make(map[peer.ID]*pruneHistory)
+		gs.pruneHistory[topic] = history
+	}
+	h, ok := history[p]
+	if !ok {
+		h = &pruneHistory{}
+		history[p] = h
+	}
+	h.prunes++
+	h.meshSince = time.Time{}
+
+	return gs.backoffStrategy.NextBackoff(p, topic, h.prunes)
+}
+
+// pruneBackoff returns the backoff for the last prune with a peer in a topic, without recording
+// another one; this is what we advertise in our PRUNEs.
+func (gs *GossipSubRouter) pruneBackoff(p peer.ID, topic string) time.Duration {
+	if gs.backoffStrategy == nil {
+		return gs.params.PruneBackoff
+	}
+
+	prunes := 1
+	if h, ok := gs.pruneHistory[topic][p]; ok {
+		prunes = h.prunes
+	}
+	return gs.backoffStrategy.NextBackoff(p, topic, prunes)
+}
+
+// resetStablePrunes forgets the prunes with the peers that have stayed in the mesh for the stable
+// period; it is called in every heartbeat.
+func (gs *GossipSubRouter) resetStablePrunes() {
+	if gs.backoffStrategy == nil {
+		return
+	}
+
+	now := time.Now()
+	for topic, history := range gs.pruneHistory {
+		peers := gs.mesh[topic]
+		for p, h := range history {
+			_, inMesh := peers[p]
+			switch {
+			case !inMesh:
+				h.meshSince = time.Time{}
+			case h.meshSince.IsZero():
+				h.meshSince = now
+			case now.Sub(h.meshSince) >= gs.backoffStablePeriod:
+				delete(history, p)
+			}
+		}
+		if len(history) == 0 {
+			delete(gs.pruneHistory, topic)
+		}
+	}
+}
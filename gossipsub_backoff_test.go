@@ -0,0 +1,149 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+func TestExponentialBackoffStrategy(t *testing.T) {
+	s := ExponentialBackoffStrategy{Base: time.Second, Max: 10 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, backoff := range expected {
+		if b := s.NextBackoff("", "foobar", i+1); b != backoff {
+			t.Fatalf("expected backoff %s after %d prunes, got %s", backoff, i+1, b)
+		}
+	}
+
+	// huge counts don't overflow
+	if b := s.NextBackoff("", "foobar", 1000); b != 10*time.Second {
+		t.Fatalf("expected the backoff to be capped, got %s", b)
+	}
+}
+
+func TestPruneBackoffStrategy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	strategy := ExponentialBackoffStrategy{Base: 10 * time.Second, Max: 80 * time.Second}
+	psubs := getGossipsubs(ctx, hosts, WithPruneBackoffStrategy(strategy, time.Second))
+
+	for _, ps := range psubs {
+		if _, err := ps.Subscribe("foobar"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(2 * time.Second)
+
+	ps := psubs[0]
+	gs := ps.rt.(*GossipSubRouter)
+	remote := hosts[1].ID()
+
+	eval := func(f func()) {
+		done := make(chan struct{})
+		ps.eval <- func() {
+			f()
+			close(done)
+		}
+		<-done
+	}
+	backoff := func() time.Duration {
+		var expire time.Time
+		eval(func() { expire = gs.backoff["foobar"][remote] })
+		return time.Until(expire).Round(time.Second)
+	}
+
+	// the peer prunes us repeatedly, asking for a short backoff; we back off exponentially
+	topic := "foobar"
+	short := uint64(1)
+	expected := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, 80 * time.Second, 80 * time.Second}
+	for i, b := range expected {
+		var advertised uint64
+		eval(func() {
+			gs.mesh[topic][remote] = struct{}{}
+			delete(gs.backoff[topic], remote)
+			gs.handlePrune(remote, &pb.ControlMessage{Prune: []*pb.ControlPrune{{TopicID: &topic, Backoff: &short}}})
+			advertised = gs.makePrune(remote, topic, false, false, PruneReasonUnknown).GetBackoff()
+		})
+		if d := backoff(); d != b {
+			t.Fatalf("expected a backoff of %s after %d prunes, got %s", b, i+1, d)
+		}
+		if advertised != uint64(b/time.Second) {
+			t.Fatalf("expected to advertise a backoff of %s after %d prunes, got %ds", b, i+1, advertised)
+		}
+	}
+
+	// a longer backoff requested by the peer is honored
+	long := uint64(200)
+	eval(func() {
+		gs.mesh[topic][remote] = struct{}{}
+		gs.handlePrune(remote, &pb.ControlMessage{Prune: []*pb.ControlPrune{{TopicID: &topic, Backoff: &long}}})
+	})
+	if d := backoff(); d != 200*time.Second {
+		t.Fatalf("expected the requested backoff of 200s, got %s", d)
+	}
+
+	// once the peer has stayed in the mesh for the stable period, the count is reset
+	eval(func() {
+		gs.mesh[topic][remote] = struct{}{}
+		gs.resetStablePrunes()
+	})
+	time.Sleep(1100 * time.Millisecond)
+	var prunes int
+	eval(func() {
+		gs.resetStablePrunes()
+		if h, ok := gs.pruneHistory[topic][remote]; ok {
+			prunes = h.prunes
+		}
+	})
+	if prunes != 0 {
+		t.Fatalf("expected the prune count to be reset, got %d", prunes)
+	}
+
+	// we prune the peer ourselves: the count starts over, and we advertise our backoff
+	var advertised uint64
+	eval(func() {
+		delete(gs.backoff[topic], remote)
+		gs.addBackoff(remote, topic, false)
+		advertised = gs.makePrune(remote, topic, false, false, PruneReasonUnknown).GetBackoff()
+	})
+	if d := backoff(); d != 10*time.Second || advertised != 10 {
+		t.Fatalf("expected a backoff of 10s after the reset, got %s and advertised %ds", d, advertised)
+	}
+
+	// leaving the topic uses the unsubscribe backoff
+	eval(func() {
+		advertised = gs.makePrune(remote, topic, false, true, PruneReasonLeave).GetBackoff()
+	})
+	if advertised != uint64(GossipSubUnsubscribeBackoff/time.Second) {
+		t.Fatalf("expected to advertise the unsubscribe backoff, got %ds", advertised)
+	}
+
+	// the history is forgotten when the peer disconnects
+	eval(func() {
+		gs.RemovePeer(remote)
+		if _, ok := gs.pruneHistory[topic][remote]; ok {
+			t.Error("expected the prune history to be forgotten")
+		}
+	})
+}
+
+func TestPruneBackoffStrategyOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	if _, err := NewGossipSub(ctx, hosts[0], WithPruneBackoffStrategy(nil, time.Minute)); err == nil {
+		t.Fatal("expected an error for a nil strategy")
+	}
+	if _, err := NewGossipSub(ctx, hosts[0], WithPruneBackoffStrategy(ConstantBackoffStrategy(time.Minute), 0)); err == nil {
+		t.Fatal("expected an error for a zero stable period")
+	}
+	if _, err := NewFloodSub(ctx, hosts[0], WithPruneBackoffStrategy(ConstantBackoffStrategy(time.Minute), time.Minute)); err == nil {
+		t.Fatal("expected an error for a floodsub router")
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"time"
 
 	"github.com/benbjohnson/clock"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peerstore"
 	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
 
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -170,3 +173,121 @@ func TestGossipsubConnTagMessageDeliveries(t *testing.T) {
 		}
 	}
 }
+
+func TestGossipsubPXConfirmation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cmgr, err := connmgr.NewConnManager(10, 20, connmgr.WithGracePeriod(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	netw := swarmt.GenSwarm(t)
+	defer netw.Close()
+	h := bhost.NewBlankHost(netw, bhost.WithConnectionManager(cmgr))
+
+	hosts := getNetHosts(t, ctx, 2)
+	good, mute := hosts[0], hosts[1]
+
+	params := DefaultGossipSubParams()
+	params.HeartbeatInterval = 100 * time.Millisecond
+	params.PXConfirmationTimeout = time.Second
+	ps := getGossipsub(ctx, h, WithGossipSubParams(params))
+	gps := getGossipsub(ctx, good)
+
+	// the mute peer accepts connections, but doesn't speak pubsub
+	for _, p := range []*PubSub{ps, gps} {
+		if _, err := p.Subscribe("foobar"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// connect to both peers as if we got them through px
+	for _, ph := range hosts {
+		h.Peerstore().AddAddrs(ph.ID(), ph.Addrs(), peerstore.PermanentAddrTTL)
+	}
+	ps.eval <- func() {
+		gs := ps.rt.(*GossipSubRouter)
+		gs.pxConnect("", []*pb.PeerInfo{{PeerID: []byte(good.ID())}, {PeerID: []byte(mute.ID())}})
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	if h.Network().Connectedness(mute.ID()) != network.Connected {
+		t.Fatal("expected to be connected to the mute peer")
+	}
+	if !cmgr.IsProtected(mute.ID(), pxTag) {
+		t.Fatal("expected the unconfirmed px peer to be protected")
+	}
+	if cmgr.IsProtected(good.ID(), pxTag) {
+		t.Fatal("expected the confirmed px peer to be released")
+	}
+
+	time.Sleep(time.Second)
+
+	if cmgr.IsProtected(mute.ID(), pxTag) {
+		t.Fatal("expected the unconfirmed px peer to be released after the confirmation timeout")
+	}
+
+	mesh := make(chan map[peer.ID]struct{})
+	ps.eval <- func() {
+		gs := ps.rt.(*GossipSubRouter)
+		m := make(map[peer.ID]struct{})
+		for p := range gs.mesh["foobar"] {
+			m[p] = struct{}{}
+		}
+		mesh <- m
+	}
+	m := <-mesh
+	if _, ok := m[good.ID()]; !ok {
+		t.Fatal("expected the confirmed peer in the mesh")
+	}
+
+	// put the mute peer in the topic, as if we had its subscriptions but no confirmation
+	ps.eval <- func() {
+		gs := ps.rt.(*GossipSubRouter)
+		gs.peers[mute.ID()] = GossipSubID_v11
+		ps.topics["foobar"][mute.ID()] = struct{}{}
+	}
+	time.Sleep(3 * params.HeartbeatInterval)
+
+	type state struct {
+		inMesh, enough bool
+	}
+	res := make(chan state)
+	ps.eval <- func() {
+		gs := ps.rt.(*GossipSubRouter)
+		_, inMesh := gs.mesh["foobar"][mute.ID()]
+		res <- state{inMesh: inMesh, enough: gs.EnoughPeers("foobar", 2)}
+	}
+	st := <-res
+	if st.inMesh {
+		t.Fatal("expected the unconfirmed peer to never enter the mesh")
+	}
+	if st.enough {
+		t.Fatal("expected the unconfirmed peer to not be counted as a topic peer")
+	}
+
+	// an RPC from the peer confirms it, even while graylisted, so it is grafted once it recovers
+	ps.eval <- func() {
+		gs := ps.rt.(*GossipSubRouter)
+		gs.graylistThreshold = 1
+		if gs.AcceptFrom(mute.ID()) != AcceptNone {
+			t.Error("expected the peer to be graylisted")
+		}
+		gs.graylistThreshold = 0
+	}
+	time.Sleep(3 * params.HeartbeatInterval)
+
+	ps.eval <- func() {
+		gs := ps.rt.(*GossipSubRouter)
+		_, inMesh := gs.mesh["foobar"][mute.ID()]
+		res <- state{inMesh: inMesh, enough: gs.EnoughPeers("foobar", 2)}
+	}
+	st = <-res
+	if !st.inMesh {
+		t.Fatal("expected the confirmed peer to be grafted")
+	}
+	if !st.enough {
+		t.Fatal("expected the confirmed peer to be counted as a topic peer")
+	}
+}
@@ -0,0 +1,204 @@
+package pubsub
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// IdleTopicAction is the action taken when a topic goes idle; see WithIdleTopicPolicy.
+type IdleTopicAction int
+
+const (
+	// IdleTopicNotify only invokes the idle topic handler, leaving the decision to the application.
+	IdleTopicNotify IdleTopicAction = iota
+	// IdleTopicDowngrade shrinks the mesh of the topic to D_lo peers, and stops emitting gossip
+	// for it.
+	IdleTopicDowngrade
+	// IdleTopicLeave leaves the mesh of the topic, releasing its resources, including the
+	// connection manager delivery tags; the subscriptions of the application are kept, and the
+	// topic is joined again on the next local publish or subscription change of a peer.
+	IdleTopicLeave
+)
+
+func (a IdleTopicAction) String() string {
+	switch a {
+	case IdleTopicNotify:
+		return "notify"
+	case IdleTopicDowngrade:
+		return "downgrade"
+	case IdleTopicLeave:
+		return "leave"
+	default:
+		return fmt.Sprintf("IdleTopicAction(%d)", int(a))
+	}
+}
+
+// IdleTopicHandler is invoked when a topic we have joined goes idle, and when it becomes active
+// again. It runs on the event loop, so it must not block.
+type IdleTopicHandler func(topic string, idle bool)
+
+// WithIdleTopicPolicy is a gossipsub router option that takes an action on the topics we have
+// joined that have no activity for idleAfter: no message published or delivered, and no peer
+// subscribing or unsubscribing. The topic returns to normal as soon as there is activity again,
+// with the mesh refilled to D peers. The handler is optional, except with IdleTopicNotify;
+// idleness is checked in the heartbeat.
+func WithIdleTopicPolicy(idleAfter time.Duration, action IdleTopicAction, handler IdleTopicHandler) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+		if idleAfter <= 0 {
+			return fmt.Errorf("invalid idle topic duration; must be positive")
+		}
+		switch action {
+		case IdleTopicNotify:
+			if handler == nil {
+				return fmt.Errorf("the idle topic notify action requires a handler")
+			}
+		case IdleTopicDowngrade, IdleTopicLeave:
+		default:
+			return fmt.Errorf("invalid idle topic action %s", action)
+		}
+
+		gs.idle = &idleTopics{
+			after:   idleAfter,
+			action:  action,
+			handler: handler,
+			clock:   clock.New(),
+			topics:  make(map[string]*idleTopicState),
+		}
+		return nil
+	}
+}
+
+// idleTopics tracks the activity of the topics we have joined. A nil tracker tracks nothing.
+type idleTopics struct {
+	after   time.Duration
+	action  IdleTopicAction
+	handler IdleTopicHandler
+	clock   clock.Clock
+
+	topics map[string]*idleTopicState
+}
+
+type idleTopicState struct {
+	lastActive time.Time
+	idle       bool
+}
+
+// join starts tracking a topic we have joined.
+func (it *idleTopics) join(topic string) {
+	if it == nil {
+		return
+	}
+	it.topics[topic] = &idleTopicState{lastActive: it.clock.Now()}
+}
+
+// leave stops tracking a topic the application has left.
+func (it *idleTopics) leave(topic string) {
+	if it == nil {
+		return
+	}
+	delete(it.topics, topic)
+}
+
+// downgraded returns whether a topic is idle with the IdleTopicDowngrade action.
+func (it *idleTopics) downgraded(topic string) bool {
+	if it == nil || it.action != IdleTopicDowngrade {
+		return false
+	}
+	st, ok := it.topics[topic]
+	return ok && st.idle
+}
+
+// meshDegree returns the target, low and high watermarks of the mesh of a topic, which are all
+// D_lo while the topic is downgraded. The runtime configuration of the topic may override them.
+func (gs *GossipSubRouter) meshDegree(topic string) (d, dlo, dhi int) {
+	d, dlo, dhi, ok := gs.topicConfigs.meshDegree(topic)
+	if !ok {
+		d, dlo, dhi = gs.params.D, gs.params.Dlo, gs.params.Dhi
+	}
+	if gs.idle.downgraded(topic) || gs.throttle.throttled(topic) {
+		return dlo, dlo, dlo
+	}
+	return d, dlo, dhi
+}
+
+// topicActivity records activity in a topic, restoring it if it is idle.
+func (gs *GossipSubRouter) topicActivity(topic string) {
+	if gs.idle == nil {
+		return
+	}
+	st, ok := gs.idle.topics[topic]
+	if !ok {
+		return
+	}
+
+	st.lastActive = gs.idle.clock.Now()
+	if !st.idle {
+		return
+	}
+
+	gs.p.logger.Debugw("topic is active again", "topic", topic, "action", gs.idle.action)
+	st.idle = false
+	switch gs.idle.action {
+	case IdleTopicDowngrade:
+		gs.refillMesh(topic)
+	case IdleTopicLeave:
+		gs.join(topic)
+	}
+	if gs.idle.handler != nil {
+		gs.idle.handler(topic, false)
+	}
+}
+
+// checkIdleTopics takes the idle action on the topics that have become idle.
+// Only called from the heartbeat.
+func (gs *GossipSubRouter) checkIdleTopics() {
+	if gs.idle == nil {
+		return
+	}
+
+	now := gs.idle.clock.Now()
+	for topic, st := range gs.idle.topics {
+		if st.idle || now.Sub(st.lastActive) < gs.idle.after {
+			continue
+		}
+
+		gs.p.logger.Debugw("topic is idle", "topic", topic, "action", gs.idle.action)
+		st.idle = true
+		if gs.idle.action == IdleTopicLeave {
+			gs.leave(topic)
+		}
+		if gs.idle.handler != nil {
+			gs.idle.handler(topic, true)
+		}
+	}
+}
+
+// refillMesh eagerly grafts peers into the mesh of a topic up to D peers, as when joining it.
+func (gs *GossipSubRouter) refillMesh(topic string) {
+	peers, ok := gs.mesh[topic]
+	if !ok || len(peers) >= gs.params.D {
+		return
+	}
+
+	backoff := gs.backoff[topic]
+	more := gs.getAdmittedPeers(topic, gs.params.D-len(peers), func(p peer.ID) bool {
+		// filter our current and direct peers, peers we are backing off, and peers with negative score
+		_, inMesh := peers[p]
+		_, direct := gs.direct[p]
+		_, doBackOff := backoff[p]
+		return !inMesh && !direct && !doBackOff && gs.score.Score(p) >= 0
+	})
+	for _, p := range more {
+		gs.p.logger.Debugw("IDLE: Add mesh link", "peer", p, "topic", topic)
+		gs.tracer.Graft(p, topic)
+		peers[p] = struct{}{}
+		gs.sendGraft(p, topic)
+	}
+}
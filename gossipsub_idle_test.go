@@ -0,0 +1,217 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/benbjohnson/clock"
+)
+
+type joinLeaveTracer struct {
+	mx     sync.Mutex
+	events []pb.TraceEvent_Type
+}
+
+func (t *joinLeaveTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_JOIN && evt.GetType() != pb.TraceEvent_LEAVE {
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.events = append(t.events, evt.GetType())
+}
+
+func (t *joinLeaveTracer) get() []pb.TraceEvent_Type {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return append([]pb.TraceEvent_Type(nil), t.events...)
+}
+
+func TestGossipsubIdleTopicPolicy(t *testing.T) {
+	for _, action := range []IdleTopicAction{IdleTopicNotify, IdleTopicDowngrade, IdleTopicLeave} {
+		t.Run(action.String(), func(t *testing.T) {
+			testGossipsubIdleTopicPolicy(t, action)
+		})
+	}
+}
+
+func testGossipsubIdleTopicPolicy(t *testing.T, action IdleTopicAction) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "foobar"
+	hosts := getNetHosts(t, ctx, 5)
+
+	params := DefaultGossipSubParams()
+	params.D = 3
+	params.Dlo = 2
+	params.Dhi = 4
+	params.Dscore = 1
+	params.Dout = 1
+	params.HeartbeatInterval = 100 * time.Millisecond
+	params.PruneBackoff = time.Second
+	params.UnsubscribeBackoff = time.Second
+
+	events := make(chan bool, 10)
+	tracer := &joinLeaveTracer{}
+	ps := getGossipsub(ctx, hosts[0], WithGossipSubParams(params), WithEventTracer(tracer),
+		WithIdleTopicPolicy(time.Minute, action, func(tp string, idle bool) {
+			if tp == topic {
+				events <- idle
+			}
+		}))
+	psubs := append([]*PubSub{ps}, getGossipsubs(ctx, hosts[1:], WithGossipSubParams(params))...)
+
+	// the clock is virtual
+	gs := ps.rt.(*GossipSubRouter)
+	clk := clock.NewMock()
+	ps.eval <- func() {
+		gs.idle.clock = clk
+	}
+
+	var subs []*Subscription
+	for _, p := range psubs {
+		sub, err := p.Subscribe(topic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+	connectAll(t, hosts)
+
+	meshSize := func() int {
+		res := make(chan int, 1)
+		ps.eval <- func() {
+			peers, ok := gs.mesh[topic]
+			if !ok {
+				res <- -1
+				return
+			}
+			res <- len(peers)
+		}
+		return <-res
+	}
+	waitMeshSize := func(min, max int) {
+		t.Helper()
+		for i := 0; ; i++ {
+			size := meshSize()
+			if size >= min && size <= max {
+				return
+			}
+			if i == 50 {
+				t.Fatalf("expected a mesh of %d to %d peers, got %d", min, max, size)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	waitEvent := func(idle bool) {
+		t.Helper()
+		select {
+		case ev := <-events:
+			if ev != idle {
+				t.Fatalf("expected idle=%t, got idle=%t", idle, ev)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected an idle=%t notification", idle)
+		}
+	}
+	downgraded := func() bool {
+		res := make(chan bool, 1)
+		ps.eval <- func() {
+			res <- gs.idle.downgraded(topic)
+		}
+		return <-res
+	}
+
+	waitMeshSize(params.D, params.Dhi)
+	size := meshSize()
+
+	// not idle yet
+	clk.Add(30 * time.Second)
+	time.Sleep(3 * params.HeartbeatInterval)
+	select {
+	case <-events:
+		t.Fatal("unexpected idle notification")
+	default:
+	}
+
+	clk.Add(31 * time.Second)
+	waitEvent(true)
+
+	switch action {
+	case IdleTopicNotify:
+		// nothing changes
+		time.Sleep(3 * params.HeartbeatInterval)
+		if n := meshSize(); n != size {
+			t.Fatalf("expected the mesh of %d peers to be kept, got %d peers", size, n)
+		}
+	case IdleTopicDowngrade:
+		waitMeshSize(params.Dlo, params.Dlo)
+		if !downgraded() {
+			t.Fatal("expected the topic to be downgraded")
+		}
+	case IdleTopicLeave:
+		waitMeshSize(-1, -1)
+		if evts := tracer.get(); len(evts) != 2 || evts[1] != pb.TraceEvent_LEAVE {
+			t.Fatalf("expected the topic to be left, got %v", evts)
+		}
+	}
+
+	// the pruned peers can be grafted again once the backoffs, with their slack, are cleared
+	time.Sleep(5 * time.Second)
+
+	// new activity restores the topic; when the mesh is left, messages from other peers don't
+	// reach us, so we publish ourselves
+	publisher := psubs[1]
+	if action == IdleTopicLeave {
+		publisher = ps
+	}
+	if err := publisher.Publish(topic, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(false)
+	if downgraded() {
+		t.Fatal("expected the topic to be restored")
+	}
+	waitMeshSize(params.D, params.Dhi)
+
+	if action == IdleTopicLeave {
+		if evts := tracer.get(); len(evts) != 3 || evts[2] != pb.TraceEvent_JOIN {
+			t.Fatalf("expected the topic to be joined again, got %v", evts)
+		}
+	}
+
+	// the subscriptions are kept throughout
+	for i, sub := range subs[1:] {
+		rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+		msg, err := sub.Next(rctx)
+		rcancel()
+		if err != nil {
+			t.Fatalf("peer %d: %s", i+1, err)
+		}
+		if string(msg.Data) != "hello" {
+			t.Fatalf("unexpected message %q", msg.Data)
+		}
+	}
+}
+
+func TestGossipsubIdleTopicPolicyOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	if _, err := NewGossipSub(ctx, hosts[0], WithIdleTopicPolicy(0, IdleTopicLeave, nil)); err == nil {
+		t.Fatal("expected an error for a zero idle duration")
+	}
+	if _, err := NewGossipSub(ctx, hosts[0], WithIdleTopicPolicy(time.Minute, IdleTopicNotify, nil)); err == nil {
+		t.Fatal("expected an error for the notify action without a handler")
+	}
+	if _, err := NewGossipSub(ctx, hosts[0], WithIdleTopicPolicy(time.Minute, IdleTopicAction(42), nil)); err == nil {
+		t.Fatal("expected an error for an invalid action")
+	}
+}
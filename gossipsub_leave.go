@@ -0,0 +1,91 @@
+package pubsub
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithGracefulLeave is a gossipsub router option that makes us leave topics gracefully. When the
+// application leaves a topic, the PRUNEs we send to our mesh peers carry a leaving hint, so that
+// they remove us from the peers of the topic right away instead of waiting for our unsubscription;
+// peers that don't know about the hint ignore it.
+// For the grace period that follows, we keep answering IWANTs for the messages of the topic that
+// are still in our message cache, and we answer GRAFTs for the topic with a hinted PRUNE, so that
+// peers with a stale view of our subscriptions stop trying to add us back to their mesh. Once the
+// grace period is over, we no longer serve the messages of the topic.
+func WithGracefulLeave(grace time.Duration) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+		if grace <= 0 {
+			return fmt.Errorf("invalid graceful leave period; must be positive")
+		}
+
+		gs.gracefulLeave = &gracefulLeave{
+			grace:  grace,
+			topics: make(map[string]time.Time),
+		}
+		return nil
+	}
+}
+
+// gracefulLeave tracks the topics we have left gracefully. A nil tracker tracks nothing.
+type gracefulLeave struct {
+	grace time.Duration
+
+	// end of the grace period of the topics we have left
+	topics map[string]time.Time
+}
+
+// leave starts the grace period of a topic the application has left.
+func (gl *gracefulLeave) leave(topic string) {
+	if gl == nil {
+		return
+	}
+	gl.topics[topic] = time.Now().Add(gl.grace)
+}
+
+// join ends the grace period of a topic we have joined again.
+func (gl *gracefulLeave) join(topic string) {
+	if gl == nil {
+		return
+	}
+	delete(gl.topics, topic)
+}
+
+// leaving returns whether a topic we have left is in its grace period.
+func (gl *gracefulLeave) leaving(topic string) bool {
+	if gl == nil {
+		return false
+	}
+	until, ok := gl.topics[topic]
+	return ok && time.Now().Before(until)
+}
+
+// expired returns whether the grace period of a topic we have left is over.
+func (gl *gracefulLeave) expired(topic string) bool {
+	if gl == nil {
+		return false
+	}
+	until, ok := gl.topics[topic]
+	return ok && !time.Now().Before(until)
+}
+
+// expireGracefulLeaves forgets the topics we have left once the messages we had cached for them
+// have been shifted out of the message cache.
+func (gs *GossipSubRouter) expireGracefulLeaves() {
+	gl := gs.gracefulLeave
+	if gl == nil {
+		return
+	}
+
+	window := time.Duration(gs.params.HistoryLength) * gs.params.HeartbeatInterval
+	now := time.Now()
+	for topic, until := range gl.topics {
+		if now.After(until.Add(window)) {
+			delete(gl.topics, topic)
+		}
+	}
+}
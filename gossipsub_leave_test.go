@@ -0,0 +1,174 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+func hasTopicPeer(ps *PubSub, topic string, p peer.ID) bool {
+	for _, tp := range ps.ListPeers(topic) {
+		if tp == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Test that a peer is removed from the peers of a topic as soon as it sends us a PRUNE with the
+// leaving hint, without waiting for its unsubscription.
+func TestGracefulLeaveHintedPrune(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	ps := getGossipsub(ctx, hosts[0])
+	neighbor := hosts[1]
+	mytopic := "mytopic"
+
+	if _, err := ps.Subscribe(mytopic); err != nil {
+		t.Fatal(err)
+	}
+
+	writers := make(chan func(*pb.RPC), 1)
+	var once sync.Once
+	newMockGS(ctx, t, neighbor, func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+		once.Do(func() {
+			subscribe := true
+			writeMsg(&pb.RPC{
+				Subscriptions: []*pb.RPC_SubOpts{{Subscribe: &subscribe, Topicid: &mytopic}},
+				Control:       &pb.ControlMessage{Graft: []*pb.ControlGraft{{TopicID: &mytopic}}},
+			})
+			writers <- writeMsg
+		})
+	})
+
+	connect(t, hosts[0], neighbor)
+
+	writeMsg := <-writers
+	time.Sleep(100 * time.Millisecond)
+	if !hasTopicPeer(ps, mytopic, neighbor.ID()) {
+		t.Fatal("expected the neighbor to be a peer of the topic")
+	}
+
+	// a plain PRUNE only removes the mesh link
+	writeMsg(&pb.RPC{Control: &pb.ControlMessage{Prune: []*pb.ControlPrune{{TopicID: &mytopic}}}})
+	time.Sleep(100 * time.Millisecond)
+	if !hasTopicPeer(ps, mytopic, neighbor.ID()) {
+		t.Fatal("expected the neighbor to remain a peer of the topic after a plain PRUNE")
+	}
+
+	// a hinted PRUNE removes the peer from the topic, even without an unsubscription
+	leaving := true
+	writeMsg(&pb.RPC{Control: &pb.ControlMessage{Prune: []*pb.ControlPrune{{TopicID: &mytopic, Leaving: &leaving}}}})
+	time.Sleep(100 * time.Millisecond)
+	if hasTopicPeer(ps, mytopic, neighbor.ID()) {
+		t.Fatal("expected the neighbor to be removed from the topic upon the hinted PRUNE")
+	}
+}
+
+// Test that when we leave a topic gracefully, we hint our mesh peers, we keep answering IWANTs
+// during the grace period, and we answer GRAFTs with a hinted PRUNE.
+func TestGracefulLeave(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	ps := getGossipsub(ctx, hosts[0], WithGracefulLeave(time.Second))
+	neighbor := hosts[1]
+	mytopic := "mytopic"
+
+	topic, err := ps.Join(mytopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := topic.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writers := make(chan func(*pb.RPC), 1)
+	prunes := make(chan *pb.ControlPrune, 10)
+	msgs := make(chan *pb.Message, 10)
+	var once sync.Once
+	newMockGS(ctx, t, neighbor, func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+		once.Do(func() {
+			subscribe := true
+			writeMsg(&pb.RPC{
+				Subscriptions: []*pb.RPC_SubOpts{{Subscribe: &subscribe, Topicid: &mytopic}},
+				Control:       &pb.ControlMessage{Graft: []*pb.ControlGraft{{TopicID: &mytopic}}},
+			})
+			writers <- writeMsg
+		})
+		for _, prune := range irpc.GetControl().GetPrune() {
+			prunes <- prune
+		}
+		for _, msg := range irpc.GetPublish() {
+			msgs <- msg
+		}
+	})
+
+	connect(t, hosts[0], neighbor)
+
+	writeMsg := <-writers
+	time.Sleep(100 * time.Millisecond)
+
+	if err := topic.Publish(ctx, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	var mid string
+	select {
+	case msg := <-msgs:
+		mid = DefaultMsgIdFn(msg)
+	case <-time.After(time.Second):
+		t.Fatal("expected the neighbor to receive the message")
+	}
+
+	expectPrune := func() {
+		t.Helper()
+		select {
+		case prune := <-prunes:
+			if prune.GetTopicID() != mytopic || !prune.GetLeaving() {
+				t.Fatalf("expected a hinted PRUNE, got %v", prune)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected a hinted PRUNE")
+		}
+	}
+	iwant := func() *pb.RPC {
+		return &pb.RPC{Control: &pb.ControlMessage{Iwant: []*pb.ControlIWant{{MessageIDs: []string{mid}}}}}
+	}
+
+	// leaving hints our mesh peers
+	sub.Cancel()
+	if err := topic.Close(); err != nil {
+		t.Fatal(err)
+	}
+	expectPrune()
+
+	// stale GRAFTs are answered with a hinted PRUNE during the grace period
+	writeMsg(&pb.RPC{Control: &pb.ControlMessage{Graft: []*pb.ControlGraft{{TopicID: &mytopic}}}})
+	expectPrune()
+
+	// IWANTs are answered during the grace period
+	writeMsg(iwant())
+	select {
+	case <-msgs:
+	case <-time.After(time.Second):
+		t.Fatal("expected the message to be served during the grace period")
+	}
+
+	// but not once it is over
+	time.Sleep(time.Second)
+	writeMsg(iwant())
+	select {
+	case <-msgs:
+		t.Fatal("expected the message not to be served after the grace period")
+	case <-time.After(500 * time.Millisecond):
+	}
+}
@@ -0,0 +1,151 @@
+package pubsub
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// WithPendingIWants is a gossipsub router option that answers the IWANTs for the messages we are
+// fetching ourselves. Without it, an IWANT for a message we don't have yet, because a peer
+// advertised it to us and we asked for it too, is wasted, and the requester has to wait for our
+// gossip to ask again. With it, we record the requester while our own IWANT is outstanding, up to
+// maxRequesters peers per message and maxPending messages in total, and we send it the message as
+// soon as it arrives and validates, subject to the same checks as an IWANT answer. The requests
+// are forgotten once our own IWANT is past its follow-up time.
+func WithPendingIWants(maxRequesters, maxPending int) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+		if maxRequesters <= 0 || maxPending <= 0 {
+			return fmt.Errorf("invalid pending IWANT limits; must be positive")
+		}
+
+		gs.pendingIWants = &pendingIWants{
+			maxRequesters: maxRequesters,
+			maxPending:    maxPending,
+			pending:       make(map[string]*pendingIWant),
+		}
+		return nil
+	}
+}
+
+// pendingIWants tracks the messages we asked for with IWANT, and the peers that asked us for them
+// in the meantime. A nil tracker tracks nothing.
+type pendingIWants struct {
+	maxRequesters int
+	maxPending    int
+
+	pending map[string]*pendingIWant
+}
+
+type pendingIWant struct {
+	expire     time.Time
+	requesters []peer.ID
+}
+
+// asked records the messages we asked for, until expire.
+func (pw *pendingIWants) asked(mids []string, expire time.Time) {
+	if pw == nil {
+		return
+	}
+
+	for _, mid := range mids {
+		if pi, ok := pw.pending[mid]; ok {
+			pi.expire = expire
+			continue
+		}
+		if len(pw.pending) >= pw.maxPending {
+			return
+		}
+		pw.pending[mid] = &pendingIWant{expire: expire}
+	}
+}
+
+// request records a peer asking for a message we don't have, if we are fetching it.
+func (pw *pendingIWants) request(mid string, p peer.ID) {
+	if pw == nil {
+		return
+	}
+
+	pi, ok := pw.pending[mid]
+	if !ok || len(pi.requesters) >= pw.maxRequesters {
+		return
+	}
+	for _, r := range pi.requesters {
+		if r == p {
+			return
+		}
+	}
+	pi.requesters = append(pi.requesters, p)
+}
+
+// take forgets a message that arrived, returning the peers that asked for it.
+func (pw *pendingIWants) take(mid string) []peer.ID {
+	if pw == nil {
+		return nil
+	}
+
+	pi, ok := pw.pending[mid]
+	if !ok {
+		return nil
+	}
+	delete(pw.pending, mid)
+	return pi.requesters
+}
+
+// expire forgets the messages we are no longer waiting for.
+func (pw *pendingIWants) expire(now time.Time) {
+	if pw == nil {
+		return
+	}
+
+	for mid, pi := range pw.pending {
+		if pi.expire.Before(now) {
+			delete(pw.pending, mid)
+		}
+	}
+}
+
+// servePendingIWants sends a message that arrived and validated to the peers that asked for it
+// while we were fetching it.
+func (gs *GossipSubRouter) servePendingIWants(msg *Message) {
+	if gs.pendingIWants == nil {
+		return
+	}
+
+	mid := gs.p.idGen.ID(msg)
+	requesters := gs.pendingIWants.take(mid)
+	if len(requesters) == 0 {
+		return
+	}
+
+	topic := msg.GetTopic()
+	out := rpcWithMessages(msg.Message)
+	out.setExpiry(0, gs.p.forwardExpiry(msg))
+	for _, p := range requesters {
+		if _, ok := gs.peers[p]; !ok {
+			continue
+		}
+		if p == msg.ReceivedFrom || p == peer.ID(msg.GetFrom()) {
+			continue
+		}
+		// our mesh peers got it with the forwarding
+		if _, ok := gs.mesh[topic][p]; ok {
+			continue
+		}
+		if gs.score.Score(p) < gs.gossipThreshold || !gs.p.peerFilter(p, topic) {
+			continue
+		}
+		// count the transmission like an IWANT answer
+		if _, count, ok := gs.mcache.GetForPeer(mid, p); !ok || count > gs.params.GossipRetransmission {
+			continue
+		}
+
+		gs.p.logger.Debugw("IWANT: sending pending message", "peer", p, "msgid", mid)
+		gs.sendRPC(p, out)
+	}
+}
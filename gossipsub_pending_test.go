@@ -0,0 +1,183 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestGossipsubPendingIWants(t *testing.T) {
+	// a chain of a source, us and a requester that asks us for the message while we are fetching
+	// it from the source; the requester is not in our mesh, so it can only get the message by
+	// asking for it
+	for _, tc := range []struct {
+		name   string
+		opts   []Option
+		iwants int
+	}{
+		// we answer the pending request right away
+		{"enabled", []Option{WithPendingIWants(4, 100)}, 1},
+		// the requester has to wait for our gossip to ask again, one round-trip later
+		{"disabled", nil, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			hosts := getNetHosts(t, ctx, 3)
+			source, requester := hosts[1], hosts[2]
+
+			opts := append([]Option{WithMessageSignaturePolicy(LaxNoSign)}, tc.opts...)
+			ps := getGossipsub(ctx, hosts[0], opts...)
+			if _, err := ps.Subscribe("foobar"); err != nil {
+				t.Fatal(err)
+			}
+
+			topic := "foobar"
+			seqno := make([]byte, 8)
+			binary.BigEndian.PutUint64(seqno, 1)
+			msg := &pb.Message{From: []byte(source.ID()), Seqno: seqno, Data: []byte("hello"), Topic: &topic}
+			mid := DefaultMsgIdFn(msg)
+
+			// the requester subscribes, but stays out of our mesh
+			var mx sync.Mutex
+			var writeRequester func(*pb.RPC)
+			var iwants int
+			var pruneOnce sync.Once
+			pruned := make(chan struct{})
+			received := make(chan int, 1)
+			newMockGS(ctx, t, requester, func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+				mx.Lock()
+				defer mx.Unlock()
+
+				writeRequester = writeMsg
+				for _, sub := range irpc.GetSubscriptions() {
+					if sub.GetSubscribe() {
+						writeMsg(&pb.RPC{Subscriptions: []*pb.RPC_SubOpts{{Subscribe: sub.Subscribe, Topicid: sub.Topicid}}})
+					}
+				}
+				for _, graft := range irpc.GetControl().GetGraft() {
+					writeMsg(&pb.RPC{Control: &pb.ControlMessage{Prune: []*pb.ControlPrune{{TopicID: graft.TopicID}}}})
+					pruneOnce.Do(func() { close(pruned) })
+				}
+				if iwants == 0 {
+					return
+				}
+				for _, m := range irpc.GetPublish() {
+					if DefaultMsgIdFn(m) == mid {
+						received <- iwants
+						iwants = -1
+						return
+					}
+				}
+				for _, ihave := range irpc.GetControl().GetIhave() {
+					for _, id := range ihave.GetMessageIDs() {
+						if id == mid && iwants > 0 {
+							iwants++
+							writeMsg(&pb.RPC{Control: &pb.ControlMessage{Iwant: []*pb.ControlIWant{{MessageIDs: []string{mid}}}}})
+						}
+					}
+				}
+			})
+
+			// the source advertises the message, and sends it when told to
+			iwanted := make(chan struct{})
+			respond := make(chan struct{})
+			var once sync.Once
+			newMockGS(ctx, t, source, func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+				for _, sub := range irpc.GetSubscriptions() {
+					if sub.GetSubscribe() {
+						writeMsg(&pb.RPC{
+							Subscriptions: []*pb.RPC_SubOpts{{Subscribe: sub.Subscribe, Topicid: sub.Topicid}},
+							Control:       &pb.ControlMessage{Ihave: []*pb.ControlIHave{{TopicID: sub.Topicid, MessageIDs: []string{mid}}}},
+						})
+					}
+				}
+				if len(irpc.GetControl().GetIwant()) > 0 {
+					once.Do(func() {
+						close(iwanted)
+						<-respond
+						writeMsg(&pb.RPC{Publish: []*pb.Message{msg}})
+					})
+				}
+			})
+
+			connect(t, hosts[0], requester)
+			select {
+			case <-pruned:
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected a GRAFT for the requester")
+			}
+			time.Sleep(100 * time.Millisecond)
+
+			connect(t, hosts[0], source)
+			select {
+			case <-iwanted:
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected an IWANT for the advertised message")
+			}
+
+			// the requester asks for the message before we have it
+			mx.Lock()
+			iwants = 1
+			writeRequester(&pb.RPC{Control: &pb.ControlMessage{Iwant: []*pb.ControlIWant{{MessageIDs: []string{mid}}}}})
+			mx.Unlock()
+			time.Sleep(100 * time.Millisecond)
+			close(respond)
+
+			select {
+			case n := <-received:
+				if n != tc.iwants {
+					t.Fatalf("expected the message after %d IWANTs, got %d", tc.iwants, n)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected the requester to receive the message")
+			}
+		})
+	}
+}
+
+func TestGossipsubPendingIWantsLimits(t *testing.T) {
+	pw := &pendingIWants{maxRequesters: 2, maxPending: 2, pending: make(map[string]*pendingIWant)}
+
+	now := time.Now()
+	pw.asked([]string{"a", "b", "c"}, now.Add(time.Second))
+	if len(pw.pending) != 2 {
+		t.Fatalf("expected 2 pending messages, got %d", len(pw.pending))
+	}
+
+	// requests for messages we are not fetching are not recorded
+	pw.request("c", "p1")
+	if r := pw.take("c"); r != nil {
+		t.Fatalf("expected no requesters for a message we are not fetching, got %v", r)
+	}
+
+	for _, p := range []string{"p1", "p1", "p2", "p3"} {
+		pw.request("a", peer.ID(p))
+	}
+	if r := pw.take("a"); len(r) != 2 || r[0] != "p1" || r[1] != "p2" {
+		t.Fatalf("expected requesters [p1 p2], got %v", r)
+	}
+	if r := pw.take("a"); r != nil {
+		t.Fatalf("expected the message to be forgotten once taken, got %v", r)
+	}
+
+	pw.expire(now.Add(2 * time.Second))
+	if len(pw.pending) != 0 {
+		t.Fatalf("expected the pending messages to expire, got %d", len(pw.pending))
+	}
+
+	var nilpw *pendingIWants
+	nilpw.asked([]string{"a"}, now)
+	nilpw.request("a", "p1")
+	nilpw.expire(now)
+	if r := nilpw.take("a"); r != nil {
+		t.Fatalf("expected a nil tracker to track nothing, got %v", r)
+	}
+}
@@ -0,0 +1,241 @@
+package pubsub
+
+import (
+	"fmt"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// MeshProbeParams are the parameters of the liveness probes of mesh peers; see WithMeshProbes.
+type MeshProbeParams struct {
+	// Interval is the number of heartbeats between the probes of a peer.
+	Interval int
+	// Window is how long a mesh peer must have been silent before we probe it.
+	Window time.Duration
+	// Timeout is how long a peer has to answer a probe.
+	Timeout time.Duration
+	// MaxFailures is the number of consecutive unanswered probes after which the peer is pruned
+	// from our meshes.
+	MaxFailures int
+	// Penalty is the behaviour penalty applied to the pruned peers; 0 applies none.
+	Penalty int
+}
+
+// DefaultMeshProbeParams returns the default liveness probe parameters: silent mesh peers are
+// probed every 5 heartbeats, and pruned after 3 probes unanswered for 5s each.
+func DefaultMeshProbeParams() MeshProbeParams {
+	return MeshProbeParams{
+		Interval:    5,
+		Window:      10 * time.Second,
+		Timeout:     5 * time.Second,
+		MaxFailures: 3,
+	}
+}
+
+func (p *MeshProbeParams) validate() error {
+	if p.Interval < 1 {
+		return fmt.Errorf("invalid probe Interval; must be at least 1 heartbeat")
+	}
+	if p.Window < 0 {
+		return fmt.Errorf("invalid probe Window; must not be negative")
+	}
+	if p.Timeout <= 0 {
+		return fmt.Errorf("invalid probe Timeout; must be positive")
+	}
+	if p.MaxFailures < 1 {
+		return fmt.Errorf("invalid probe MaxFailures; must be at least 1")
+	}
+	if p.Penalty < 0 {
+		return fmt.Errorf("invalid probe Penalty; must not be negative")
+	}
+	return nil
+}
+
+// WithMeshProbes is a gossipsub router option that probes the liveness of the mesh peers that
+// have been silent for the probe window, so that peers that are connected but silently broken
+// are pruned quickly instead of once their score decays.
+// The probes are a control message extension: we announce support to every new peer, and only
+// probe the peers that announce it back, so that older peers are exempt. A peer answers a probe
+// with a pong, at most once per heartbeat, but any RPC it sends counts as an answer. Peers that
+// leave MaxFailures consecutive probes unanswered are pruned from all our meshes, with
+// PruneReasonUnresponsive, and penalized with the probe Penalty, if any.
+// Peers answer probes whether or not they enable this option.
+func WithMeshProbes(params MeshProbeParams) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+
+		if err := params.validate(); err != nil {
+			return err
+		}
+
+		gs.probeParams = &params
+		return nil
+	}
+}
+
+// probeState is the probe state of a peer.
+type probeState struct {
+	// whether the peer announced support for probes, and whether we announced it to the peer
+	supported, announced bool
+	// whether we answered a probe of the peer in this heartbeat
+	ponged bool
+
+	// time we last received an RPC from the peer
+	lastSeen time.Time
+	// nonce and deadline of the outstanding probe, if any
+	pending  uint64
+	deadline time.Time
+	// number of consecutive unanswered probes
+	failures int
+}
+
+func (gs *GossipSubRouter) getProbeState(p peer.ID) *probeState {
+	st, ok := gs.probes[p]
+	if !ok {
+		st = &probeState{lastSeen: time.Now()}
+		gs.probes[p] = st
+	}
+	return st
+}
+
+// announceProbes announces support for probes to a new peer, when we probe our mesh peers.
+func (gs *GossipSubRouter) announceProbes(p peer.ID) {
+	if gs.probeParams == nil {
+		return
+	}
+
+	st := gs.getProbeState(p)
+	st.announced = true
+	out := rpcWithControl(nil, nil, nil, nil, nil)
+	out.Control.Probe = &pb.ControlProbe{Supported: &st.announced}
+	gs.sendRPC(p, out)
+}
+
+// handleProbe answers the probes of a peer, and records its liveness and its support for probes.
+func (gs *GossipSubRouter) handleProbe(p peer.ID, ctl *pb.ControlMessage) {
+	if gs.probeParams != nil {
+		// any RPC answers the outstanding probe
+		st := gs.getProbeState(p)
+		st.lastSeen = time.Now()
+		st.pending = 0
+		st.failures = 0
+	}
+
+	probe := ctl.GetProbe()
+	if probe == nil {
+		return
+	}
+
+	st := gs.getProbeState(p)
+	var reply pb.ControlProbe
+	send := false
+
+	if probe.GetSupported() {
+		st.supported = true
+		if !st.announced {
+			st.announced = true
+			reply.Supported = &st.announced
+			send = true
+		}
+	}
+
+	if probe.Ping != nil {
+		if st.ponged {
+			gs.p.logger.Debugw("PROBE: ignoring excessive probes from peer", "peer", p)
+		} else {
+			st.ponged = true
+			reply.Pong = probe.Ping
+			send = true
+		}
+	}
+
+	if send {
+		out := rpcWithControl(nil, nil, nil, nil, nil)
+		out.Control.Probe = &reply
+		gs.sendRPC(p, out)
+	}
+}
+
+// probeMesh expires the outstanding probes, prunes the mesh peers that left too many probes
+// unanswered, and probes the silent mesh peers every probe interval.
+func (gs *GossipSubRouter) probeMesh(toprune map[peer.ID][]string, pruneReasons map[peer.ID]map[string]PruneReason) {
+	for _, st := range gs.probes {
+		st.ponged = false
+	}
+
+	params := gs.probeParams
+	if params == nil {
+		return
+	}
+
+	now := time.Now()
+	for p, st := range gs.probes {
+		if st.pending == 0 || now.Before(st.deadline) {
+			continue
+		}
+
+		st.pending = 0
+		st.failures++
+		if st.failures < params.MaxFailures {
+			continue
+		}
+
+		st.failures = 0
+		gs.p.logger.Debugw("HEARTBEAT: Prune unresponsive peer", "peer", p)
+		pruned := false
+		for topic, peers := range gs.mesh {
+			if _, ok := peers[p]; !ok {
+				continue
+			}
+
+			gs.tracer.Prune(p, topic, PruneReasonUnresponsive)
+			delete(peers, p)
+			gs.addBackoff(p, topic, false)
+			toprune[p] = append(toprune[p], topic)
+			reasons, ok := pruneReasons[p]
+			if !ok {
+				reasons = make(map[string]PruneReason)
+				pruneReasons[p] = reasons
+			}
+			reasons[topic] = PruneReasonUnresponsive
+			pruned = true
+		}
+		if pruned && params.Penalty > 0 {
+			gs.score.AddPenalty(p, params.Penalty, PenaltyUnresponsive)
+		}
+	}
+
+	if gs.heartbeatTicks%uint64(params.Interval) != 0 {
+		return
+	}
+
+	probed := make(map[peer.ID]struct{})
+	for _, peers := range gs.mesh {
+		for p := range peers {
+			if _, ok := probed[p]; ok {
+				continue
+			}
+			probed[p] = struct{}{}
+
+			st, ok := gs.probes[p]
+			if !ok || !st.supported || st.pending != 0 || now.Sub(st.lastSeen) < params.Window {
+				continue
+			}
+
+			// the nonce is never 0, which marks no outstanding probe
+			nonce := gs.rng.Uint64() | 1
+			st.pending = nonce
+			st.deadline = now.Add(params.Timeout)
+
+			out := rpcWithControl(nil, nil, nil, nil, nil)
+			out.Control.Probe = &pb.ControlProbe{Ping: &nonce}
+			gs.sendRPC(p, out)
+		}
+	}
+}
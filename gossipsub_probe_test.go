@@ -0,0 +1,210 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestGossipsubMeshProbes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 4)
+	alive, dead, legacy := hosts[1], hosts[2], hosts[3]
+
+	tracer := &pruneReasonTracer{reasons: make(map[pb.ControlPrune_Reason]int)}
+	ps, err := NewGossipSub(ctx, hosts[0],
+		WithEventTracer(tracer),
+		WithMeshProbes(MeshProbeParams{
+			Interval:    1,
+			Timeout:     100 * time.Millisecond,
+			MaxFailures: 2,
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mytopic := "mytopic"
+	if _, err := ps.Subscribe(mytopic); err != nil {
+		t.Fatal(err)
+	}
+
+	// the mock peers join the mesh; the alive peer answers all probes, the dead peer answers two
+	// probes and then goes silent, and the legacy peer does not support probes
+	var mx sync.Mutex
+	pings := make(map[peer.ID]int)
+	prunes := make(map[peer.ID]int)
+	mockPeer := func(h host.Host, supported bool, maxPongs int) {
+		newMockGS(ctx, t, h, func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+			for _, sub := range irpc.GetSubscriptions() {
+				if !sub.GetSubscribe() {
+					continue
+				}
+				graft := []*pb.ControlGraft{{TopicID: sub.Topicid}}
+				out := rpcWithControl(nil, nil, nil, graft, nil)
+				out.Subscriptions = []*pb.RPC_SubOpts{{Subscribe: sub.Subscribe, Topicid: sub.Topicid}}
+				writeMsg(&out.RPC)
+			}
+
+			mx.Lock()
+			defer mx.Unlock()
+			prunes[h.ID()] += len(irpc.GetControl().GetPrune())
+
+			probe := irpc.GetControl().GetProbe()
+			if probe == nil || !supported {
+				return
+			}
+			if probe.GetSupported() {
+				out := rpcWithControl(nil, nil, nil, nil, nil)
+				out.Control.Probe = &pb.ControlProbe{Supported: &supported}
+				writeMsg(&out.RPC)
+			}
+			if probe.Ping == nil {
+				return
+			}
+			pings[h.ID()]++
+			if maxPongs >= 0 && pings[h.ID()] > maxPongs {
+				return
+			}
+			out := rpcWithControl(nil, nil, nil, nil, nil)
+			out.Control.Probe = &pb.ControlProbe{Pong: probe.Ping}
+			writeMsg(&out.RPC)
+		})
+	}
+	mockPeer(alive, true, -1)
+	mockPeer(dead, true, 2)
+	mockPeer(legacy, false, 0)
+
+	for _, h := range hosts[1:] {
+		connect(t, hosts[0], h)
+	}
+
+	// wait for the dead peer to leave two probes unanswered
+	time.Sleep(6 * time.Second)
+
+	inMesh := make(chan map[peer.ID]bool, 1)
+	ps.eval <- func() {
+		gs := ps.rt.(*GossipSubRouter)
+		res := make(map[peer.ID]bool)
+		for p := range gs.mesh[mytopic] {
+			res[p] = true
+		}
+		inMesh <- res
+	}
+	mesh := <-inMesh
+
+	if !mesh[alive.ID()] {
+		t.Fatal("expected the responsive peer to remain in the mesh")
+	}
+	if !mesh[legacy.ID()] {
+		t.Fatal("expected the peer without probe support to remain in the mesh")
+	}
+	if mesh[dead.ID()] {
+		t.Fatal("expected the unresponsive peer to be pruned from the mesh")
+	}
+
+	mx.Lock()
+	defer mx.Unlock()
+	if pings[alive.ID()] < 4 {
+		t.Fatalf("expected the responsive peer to be probed every heartbeat, got %d probes", pings[alive.ID()])
+	}
+	if pings[dead.ID()] != 4 {
+		t.Fatalf("expected 4 probes to the unresponsive peer, got %d", pings[dead.ID()])
+	}
+	if pings[legacy.ID()] != 0 {
+		t.Fatalf("expected no probes to the peer without probe support, got %d", pings[legacy.ID()])
+	}
+	if prunes[dead.ID()] != 1 {
+		t.Fatalf("expected a PRUNE to the unresponsive peer, got %d", prunes[dead.ID()])
+	}
+	if prunes[alive.ID()] != 0 || prunes[legacy.ID()] != 0 {
+		t.Fatal("expected no PRUNE to the other peers")
+	}
+
+	tracer.mx.Lock()
+	defer tracer.mx.Unlock()
+	if n := tracer.reasons[pb.ControlPrune_UNRESPONSIVE]; n != 1 {
+		t.Fatalf("expected a prune trace for the unresponsive peer, got %d", n)
+	}
+
+	// probing is not penalized by default
+	if s := ps.rt.(*GossipSubRouter).score.Score(dead.ID()); s != 0 {
+		t.Fatalf("expected no penalty for the unresponsive peer, got score %f", s)
+	}
+}
+
+func TestGossipsubMeshProbesResponder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+
+	// the router answers probes without probing itself
+	_ = getGossipsub(ctx, hosts[0])
+
+	var mx sync.Mutex
+	var pongs []uint64
+	var write func(*pb.RPC)
+	supported := false
+	newMockGS(ctx, t, hosts[1], func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+		mx.Lock()
+		defer mx.Unlock()
+		write = writeMsg
+		probe := irpc.GetControl().GetProbe()
+		if probe == nil {
+			return
+		}
+		if probe.GetSupported() {
+			supported = true
+		}
+		if probe.Pong != nil {
+			pongs = append(pongs, probe.GetPong())
+		}
+	})
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	// a burst of probes is answered once per heartbeat
+	sendPings := func(nonces ...uint64) {
+		mx.Lock()
+		defer mx.Unlock()
+		if write == nil {
+			t.Fatal("expected the router to greet the mock peer")
+		}
+		for _, nonce := range nonces {
+			nonce, supported := nonce, true
+			out := rpcWithControl(nil, nil, nil, nil, nil)
+			out.Control.Probe = &pb.ControlProbe{Supported: &supported, Ping: &nonce}
+			write(&out.RPC)
+		}
+	}
+	sendPings(1, 2, 3)
+	time.Sleep(100 * time.Millisecond)
+
+	mx.Lock()
+	if !supported {
+		t.Fatal("expected the router to announce probe support once we did")
+	}
+	if len(pongs) != 1 || pongs[0] != 1 {
+		t.Fatalf("expected a single pong for the first probe, got %v", pongs)
+	}
+	mx.Unlock()
+
+	time.Sleep(GossipSubHeartbeatInterval + GossipSubHeartbeatInitialDelay)
+	sendPings(4)
+	time.Sleep(100 * time.Millisecond)
+
+	mx.Lock()
+	defer mx.Unlock()
+	if len(pongs) != 2 || pongs[1] != 4 {
+		t.Fatalf("expected a pong in the next heartbeat, got %v", pongs)
+	}
+}
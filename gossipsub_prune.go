@@ -0,0 +1,129 @@
+package pubsub
+
+import (
+	"fmt"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// PruneReason is the reason for pruning a peer from a topic mesh, as carried in PRUNE control
+// messages. Peers that don't send a reason, or that are configured to withhold it, are reported
+// with PruneReasonUnknown.
+type PruneReason int32
+
+const (
+	// PruneReasonUnknown is used when no reason was given.
+	PruneReasonUnknown PruneReason = PruneReason(pb.ControlPrune_UNKNOWN)
+	// PruneReasonLeave is used when the pruning peer left the topic.
+	PruneReasonLeave PruneReason = PruneReason(pb.ControlPrune_LEAVE)
+	// PruneReasonOversubscribed is used when the pruning peer's mesh had too many peers.
+	PruneReasonOversubscribed PruneReason = PruneReason(pb.ControlPrune_OVERSUBSCRIBED)
+	// PruneReasonLowScore is used when the pruned peer's score was too low.
+	PruneReasonLowScore PruneReason = PruneReason(pb.ControlPrune_LOW_SCORE)
+	// PruneReasonOpportunisticReplaced is used when the pruned peer was replaced by a better
+	// scoring peer.
+	PruneReasonOpportunisticReplaced PruneReason = PruneReason(pb.ControlPrune_OPPORTUNISTIC_REPLACED)
+	// PruneReasonUnresponsive is used when the pruned peer left our liveness probes unanswered;
+	// see WithMeshProbes.
+	PruneReasonUnresponsive PruneReason = PruneReason(pb.ControlPrune_UNRESPONSIVE)
+)
+
+// DefaultPruneReasons are the prune reasons we disclose to pruned peers by default.
+// Disclosing PruneReasonLowScore tells peers how we score them, so it is withheld unless
+// explicitly enabled with WithPruneReasons.
+var DefaultPruneReasons = []PruneReason{PruneReasonLeave, PruneReasonOversubscribed}
+
+func (r PruneReason) String() string {
+	switch r {
+	case PruneReasonUnknown:
+		return "unknown"
+	case PruneReasonLeave:
+		return "leave"
+	case PruneReasonOversubscribed:
+		return "oversubscribed"
+	case PruneReasonLowScore:
+		return "low score"
+	case PruneReasonOpportunisticReplaced:
+		return "opportunistic replaced"
+	case PruneReasonUnresponsive:
+		return "unresponsive"
+	default:
+		return fmt.Sprintf("PruneReason(%d)", int32(r))
+	}
+}
+
+func makePruneReasonSet(reasons []PruneReason) map[PruneReason]bool {
+	set := make(map[PruneReason]bool, len(reasons))
+	for _, r := range reasons {
+		set[r] = true
+	}
+	return set
+}
+
+func (r PruneReason) pb() *pb.ControlPrune_Reason {
+	return pb.ControlPrune_Reason(r).Enum()
+}
+
+// WithPruneReasons is a gossipsub router option that sets the prune reasons that are included
+// in the PRUNE messages we send; all other reasons are withheld. It replaces DefaultPruneReasons;
+// without arguments, no reasons are sent at all.
+func WithPruneReasons(reasons ...PruneReason) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+
+		send := make(map[PruneReason]bool, len(reasons))
+		for _, r := range reasons {
+			if r == PruneReasonUnknown {
+				continue
+			}
+			if _, ok := pb.ControlPrune_Reason_name[int32(r)]; !ok {
+				return fmt.Errorf("invalid prune reason %d", int32(r))
+			}
+			send[r] = true
+		}
+		gs.sendPruneReasons = send
+		return nil
+	}
+}
+
+// recordPruneReason records the reason given by a peer that pruned us from a topic mesh.
+func (gs *GossipSubRouter) recordPruneReason(p peer.ID, topic string, reason PruneReason) {
+	reasons, ok := gs.pruneReasons[topic]
+	if !ok {
+		reasons = make(map[peer.ID]PruneReason)
+		gs.pruneReasons[topic] = reasons
+	}
+	reasons[p] = reason
+}
+
+// LastPruneReason returns the reason given by a peer the last time it pruned us from the mesh of
+// a topic. It returns false if the peer hasn't pruned us since it connected, or if the router is
+// not gossipsub.
+func (p *PubSub) LastPruneReason(topic string, pid peer.ID) (PruneReason, bool) {
+	type result struct {
+		reason PruneReason
+		ok     bool
+	}
+
+	out := make(chan result, 1)
+	select {
+	case p.eval <- func() {
+		gs, ok := p.rt.(*GossipSubRouter)
+		if !ok {
+			out <- result{}
+			return
+		}
+		reason, ok := gs.pruneReasons[topic][pid]
+		out <- result{reason, ok}
+	}:
+		res := <-out
+		return res.reason, res.ok
+	case <-p.ctx.Done():
+		return PruneReasonUnknown, false
+	}
+}
@@ -0,0 +1,126 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/record"
+)
+
+// PXDialerFn vets the peers obtained through PX before we connect to them, returning the
+// candidates to dial; it may drop candidates, or change the addresses to dial them at.
+// The context expires after the ConnectionTimeout of the gossipsub parameters.
+type PXDialerFn func(ctx context.Context, candidates []peer.AddrInfo) []peer.AddrInfo
+
+// PXObserverFn receives the peers exchanged in a PRUNE by a peer, as sent, before we vet them.
+type PXObserverFn func(from peer.ID, candidates []peer.AddrInfo)
+
+// WithPXDialer is a gossipsub router option that routes the connection attempts to peers
+// obtained through PX through an application dialer policy. The dialer is called in its own
+// goroutine with the candidates we would connect to, with the addresses from their signed peer
+// records, if any, and we only connect to the candidates it returns, at the addresses it
+// returns; returned peers that were not candidates are ignored.
+func WithPXDialer(dialer PXDialerFn) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+
+		gs.pxDialer = dialer
+
+		return nil
+	}
+}
+
+// WithPXObserver is a gossipsub router option that sets a callback receiving the PX of every
+// PRUNE we accept PX from, before we vet the exchanged peers, for logging and monitoring.
+// The callback is called from the event loop, so it must be fast and must not block.
+func WithPXObserver(observer PXObserverFn) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+
+		gs.pxObserver = observer
+
+		return nil
+	}
+}
+
+// WithPXDialLimit is a gossipsub router option that limits the number of connection attempts to
+// peers obtained through PX that are queued or in progress at a time; the peers exchanged while
+// the limit is reached are ignored. By default, PX connection attempts are only bounded by the
+// MaxPendingConnections of the gossipsub parameters.
+func WithPXDialLimit(limit int) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+		if limit < 1 {
+			return fmt.Errorf("invalid PX dial limit; must be at least 1")
+		}
+
+		gs.pxDials = make(chan struct{}, limit)
+
+		return nil
+	}
+}
+
+// pxDial passes the candidates obtained through PX through the application dialer, and queues
+// the connection attempts to the candidates it returns.
+func (gs *GossipSubRouter) pxDial(toconnect []connectInfo) {
+	candidates := make([]peer.AddrInfo, 0, len(toconnect))
+	allowed := make(map[peer.ID]struct{}, len(toconnect))
+	for _, ci := range toconnect {
+		candidates = append(candidates, peer.AddrInfo{ID: ci.p, Addrs: ci.addrs})
+		allowed[ci.p] = struct{}{}
+	}
+
+	ctx, cancel := context.WithTimeout(gs.p.ctx, gs.params.ConnectionTimeout)
+	dial := gs.pxDialer(ctx, candidates)
+	cancel()
+
+	toconnect = make([]connectInfo, 0, len(dial))
+	for _, pi := range dial {
+		if _, ok := allowed[pi.ID]; !ok {
+			gs.p.logger.Debugw("ignoring px peer returned by the dialer that is not a candidate", "peer", pi.ID)
+			continue
+		}
+		delete(allowed, pi.ID)
+		toconnect = append(toconnect, connectInfo{p: pi.ID, addrs: pi.Addrs})
+	}
+
+	if len(toconnect) == 0 {
+		return
+	}
+
+	select {
+	case gs.p.eval <- func() { gs.queuePXConnect(toconnect) }:
+	case <-gs.p.ctx.Done():
+	}
+}
+
+// pxCandidates returns the peers exchanged in a PRUNE, with the addresses of their signed peer
+// records, if any.
+func pxCandidates(peers []*pb.PeerInfo) []peer.AddrInfo {
+	candidates := make([]peer.AddrInfo, 0, len(peers))
+	for _, pi := range peers {
+		ai := peer.AddrInfo{ID: peer.ID(pi.PeerID)}
+		if pi.SignedPeerRecord != nil {
+			_, r, err := record.ConsumeEnvelope(pi.SignedPeerRecord, peer.PeerRecordEnvelopeDomain)
+			if err == nil {
+				if rec, ok := r.(*peer.PeerRecord); ok && rec.PeerID == ai.ID {
+					ai.Addrs = rec.Addrs
+				}
+			}
+		}
+		candidates = append(candidates, ai)
+	}
+	return candidates
+}
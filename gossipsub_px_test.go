@@ -0,0 +1,125 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+)
+
+func TestGossipsubPXDialer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 4)
+	h, allowed, denied, unknown := hosts[0], hosts[1], hosts[2], hosts[3]
+
+	// the denied and unknown peers are dialable, while the dialer supplies the addresses of the
+	// allowed peer
+	h.Peerstore().AddAddrs(denied.ID(), denied.Addrs(), peerstore.PermanentAddrTTL)
+	h.Peerstore().AddAddrs(unknown.ID(), unknown.Addrs(), peerstore.PermanentAddrTTL)
+
+	var mx sync.Mutex
+	var observed, dialed []peer.AddrInfo
+	var observedFrom peer.ID
+	ps := getGossipsub(ctx, h,
+		WithPXObserver(func(from peer.ID, candidates []peer.AddrInfo) {
+			mx.Lock()
+			defer mx.Unlock()
+			observedFrom = from
+			observed = candidates
+		}),
+		WithPXDialer(func(ctx context.Context, candidates []peer.AddrInfo) []peer.AddrInfo {
+			mx.Lock()
+			defer mx.Unlock()
+			dialed = candidates
+
+			var out []peer.AddrInfo
+			for _, ai := range candidates {
+				if ai.ID == allowed.ID() {
+					out = append(out, peer.AddrInfo{ID: ai.ID, Addrs: allowed.Addrs()})
+				}
+			}
+			// peers that were not candidates are never dialed
+			return append(out, peer.AddrInfo{ID: unknown.ID()})
+		}))
+
+	from := peer.ID("pruner")
+	ps.eval <- func() {
+		gs := ps.rt.(*GossipSubRouter)
+		gs.pxConnect(from, []*pb.PeerInfo{{PeerID: []byte(allowed.ID())}, {PeerID: []byte(denied.ID())}})
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	if h.Network().Connectedness(allowed.ID()) != network.Connected {
+		t.Fatal("expected to connect to the allowed peer")
+	}
+	if h.Network().Connectedness(denied.ID()) == network.Connected {
+		t.Fatal("expected the denied peer to never be dialed")
+	}
+	if h.Network().Connectedness(unknown.ID()) == network.Connected {
+		t.Fatal("expected the peer returned by the dialer that is not a candidate to never be dialed")
+	}
+
+	mx.Lock()
+	defer mx.Unlock()
+	if observedFrom != from || len(observed) != 2 {
+		t.Fatalf("expected the observer to receive both candidates from %s, got %v from %s", from, observed, observedFrom)
+	}
+	if len(dialed) != 2 {
+		t.Fatalf("expected the dialer to receive both candidates, got %v", dialed)
+	}
+}
+
+func TestGossipsubPXDialLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 3)
+	h := hosts[0]
+	for _, ph := range hosts[1:] {
+		h.Peerstore().AddAddrs(ph.ID(), ph.Addrs(), peerstore.PermanentAddrTTL)
+	}
+
+	ps := getGossipsub(ctx, h, WithPXDialLimit(1))
+
+	// only one of the peers is dialed, as the second is exchanged while the first is being dialed
+	ps.eval <- func() {
+		gs := ps.rt.(*GossipSubRouter)
+		gs.pxConnect("", []*pb.PeerInfo{{PeerID: []byte(hosts[1].ID())}, {PeerID: []byte(hosts[2].ID())}})
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	var ignored []peer.ID
+	for _, ph := range hosts[1:] {
+		if h.Network().Connectedness(ph.ID()) != network.Connected {
+			ignored = append(ignored, ph.ID())
+		}
+	}
+	if len(ignored) != 1 {
+		t.Fatalf("expected to connect to a single peer, ignored %d", len(ignored))
+	}
+
+	// the dial slot is released once the dial completes
+	ps.eval <- func() {
+		gs := ps.rt.(*GossipSubRouter)
+		gs.pxConnect("", []*pb.PeerInfo{{PeerID: []byte(ignored[0])}})
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	for _, ph := range hosts[1:] {
+		if h.Network().Connectedness(ph.ID()) != network.Connected {
+			t.Fatalf("expected to connect to %s", ph.ID())
+		}
+	}
+
+	if _, err := NewGossipSub(ctx, hosts[1], WithPXDialLimit(0)); err == nil {
+		t.Fatal("expected an error for an invalid PX dial limit")
+	}
+}
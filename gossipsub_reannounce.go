@@ -0,0 +1,150 @@
+package pubsub
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// WithSubscriptionReannouncement is a gossipsub router option that announces our subscriptions
+// again to the connected peers that may have missed them: peers from which we have received no
+// traffic for the topics we have joined, neither messages nor control messages, for
+// idleHeartbeats heartbeats. A peer missing our subscription announcement, eg during connection
+// churn, never sends us traffic for our topics until something changes; the re-announcement
+// recovers it without reconnecting. Each peer gets at most one re-announcement per cooldown, and
+// each re-announcement is traced with a REANNOUNCE_SUBSCRIPTIONS event.
+func WithSubscriptionReannouncement(idleHeartbeats int, cooldown time.Duration) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+		if idleHeartbeats <= 0 {
+			return fmt.Errorf("invalid number of idle heartbeats; must be positive")
+		}
+		if cooldown < 0 {
+			return fmt.Errorf("invalid re-announcement cooldown; must be non-negative")
+		}
+
+		gs.reannounce = &subReannounce{
+			idleHeartbeats: uint64(idleHeartbeats),
+			cooldown:       cooldown,
+			clock:          clock.New(),
+			lastHeard:      make(map[peer.ID]uint64),
+			lastSent:       make(map[peer.ID]time.Time),
+		}
+		return nil
+	}
+}
+
+// subReannounce tracks the traffic received from each peer for our topics, and the subscription
+// re-announcements sent to them. A nil tracker tracks nothing.
+type subReannounce struct {
+	idleHeartbeats uint64
+	cooldown       time.Duration
+	clock          clock.Clock
+
+	// heartbeat of the last traffic for our topics, by peer
+	lastHeard map[peer.ID]uint64
+	// time of the last re-announcement, by peer
+	lastSent map[peer.ID]time.Time
+}
+
+// heard records traffic for our topics from a peer, or the start of its tracking.
+func (sr *subReannounce) heard(p peer.ID, tick uint64) {
+	if sr == nil {
+		return
+	}
+	sr.lastHeard[p] = tick
+}
+
+// removePeer stops tracking a disconnected peer.
+func (sr *subReannounce) removePeer(p peer.ID) {
+	if sr == nil {
+		return
+	}
+	delete(sr.lastHeard, p)
+	delete(sr.lastSent, p)
+}
+
+// topicTraffic returns whether an RPC carries messages or control messages for the topics we have
+// joined.
+func (gs *GossipSubRouter) topicTraffic(rpc *RPC) bool {
+	joined := func(topic string) bool {
+		_, ok := gs.mesh[topic]
+		return ok
+	}
+
+	for _, msg := range rpc.GetPublish() {
+		if joined(msg.GetTopic()) {
+			return true
+		}
+	}
+	for _, msg := range rpc.dups {
+		if joined(msg.GetTopic()) {
+			return true
+		}
+	}
+
+	ctl := rpc.GetControl()
+	for _, ihave := range ctl.GetIhave() {
+		if joined(ihave.GetTopicID()) {
+			return true
+		}
+	}
+	// IWANTs answer our gossip, which only goes to peers in our topics
+	if len(ctl.GetIwant()) > 0 {
+		return true
+	}
+	for _, graft := range ctl.GetGraft() {
+		if joined(graft.GetTopicID()) {
+			return true
+		}
+	}
+	for _, prune := range ctl.GetPrune() {
+		if joined(prune.GetTopicID()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reannounceSubscriptions announces our subscriptions again to the peers without traffic for our
+// topics for too long.
+// Only called from the heartbeat.
+func (gs *GossipSubRouter) reannounceSubscriptions() {
+	sr := gs.reannounce
+	if sr == nil {
+		return
+	}
+
+	var hello *RPC
+	now := sr.clock.Now()
+	for p, tick := range sr.lastHeard {
+		if gs.heartbeatTicks-tick < sr.idleHeartbeats {
+			continue
+		}
+		if last, ok := sr.lastSent[p]; ok && now.Sub(last) < sr.cooldown {
+			continue
+		}
+
+		if hello == nil {
+			hello = gs.p.getHelloPacket()
+			if len(hello.Subscriptions) == 0 {
+				return
+			}
+		}
+
+		topics := make([]string, 0, len(hello.Subscriptions))
+		for _, sub := range hello.Subscriptions {
+			topics = append(topics, sub.GetTopicid())
+		}
+		gs.p.logger.Debugw("re-announcing subscriptions to peer without topic traffic", "peer", p, "heartbeats", gs.heartbeatTicks-tick)
+		gs.tracer.ReannounceSubscriptions(p, topics)
+		sr.lastSent[p] = now
+		gs.sendRPC(p, hello)
+	}
+}
@@ -0,0 +1,165 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// announceDropInjector drops our first subscription announcement, and every GRAFT, so that the
+// peer never learns about our subscriptions until they are announced again.
+type announceDropInjector struct {
+	*ScriptedInjector
+
+	mx       sync.Mutex
+	dropped  bool
+	announce int
+}
+
+func (fi *announceDropInjector) DropOutboundRPC(to peer.ID, rpc *RPC) bool {
+	fi.mx.Lock()
+	defer fi.mx.Unlock()
+
+	if len(rpc.GetControl().GetGraft()) > 0 {
+		return true
+	}
+	if len(rpc.GetSubscriptions()) == 0 {
+		return false
+	}
+
+	fi.announce++
+	if !fi.dropped {
+		fi.dropped = true
+		return true
+	}
+	return false
+}
+
+type reannounceTracer struct {
+	mx    sync.Mutex
+	peers []peer.ID
+}
+
+func (t *reannounceTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_REANNOUNCE_SUBSCRIPTIONS {
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.peers = append(t.peers, peer.ID(evt.GetReannounceSubscriptions().GetPeerID()))
+}
+
+func TestGossipsubSubscriptionReannouncement(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	params := DefaultGossipSubParams()
+	params.HeartbeatInterval = 100 * time.Millisecond
+
+	hosts := getNetHosts(t, ctx, 2)
+	fi := &announceDropInjector{ScriptedInjector: &ScriptedInjector{}}
+	tracer := &reannounceTracer{}
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0],
+			WithGossipSubParams(params),
+			WithFaultInjector(fi),
+			WithEventTracer(tracer),
+			WithSubscriptionReannouncement(10, time.Minute)),
+		getGossipsub(ctx, hosts[1], WithGossipSubParams(params)),
+	}
+
+	var topics []*Topic
+	var subs []*Subscription
+	for _, ps := range psubs {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+		subs = append(subs, sub)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(300 * time.Millisecond)
+
+	// the peer missed our announcement
+	if peers := topics[1].ListPeers(); len(peers) != 0 {
+		t.Fatalf("expected the peer to miss our subscription, got %v", peers)
+	}
+
+	// until we announce it again, without reconnecting
+	time.Sleep(2 * time.Second)
+	if peers := topics[1].ListPeers(); len(peers) != 1 || peers[0] != hosts[0].ID() {
+		t.Fatalf("expected the peer to learn about our subscription, got %v", peers)
+	}
+	if conns := hosts[0].Network().ConnsToPeer(hosts[1].ID()); len(conns) != 1 {
+		t.Fatalf("expected the original connection, got %d connections", len(conns))
+	}
+
+	if err := topics[1].Publish(ctx, []byte("recovered")); err != nil {
+		t.Fatal(err)
+	}
+	rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+	defer rcancel()
+	if _, err := subs[0].Next(rctx); err != nil {
+		t.Fatalf("expected the message of the peer after the re-announcement: %s", err)
+	}
+
+	// the traffic of the peer keeps it from further re-announcements, as does the cooldown
+	time.Sleep(2 * time.Second)
+	tracer.mx.Lock()
+	defer tracer.mx.Unlock()
+	if len(tracer.peers) != 1 || tracer.peers[0] != hosts[1].ID() {
+		t.Fatalf("expected a single re-announcement to the peer, got %v", tracer.peers)
+	}
+	fi.mx.Lock()
+	defer fi.mx.Unlock()
+	if fi.announce != 2 {
+		t.Fatalf("expected the dropped announcement and the re-announcement, got %d announcements", fi.announce)
+	}
+}
+
+func TestGossipsubSubscriptionReannouncementCooldown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	params := DefaultGossipSubParams()
+	params.HeartbeatInterval = 100 * time.Millisecond
+
+	// the peer is not in our topic, so it never sends us traffic for it
+	hosts := getNetHosts(t, ctx, 2)
+	tracer := &reannounceTracer{}
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0],
+			WithGossipSubParams(params),
+			WithEventTracer(tracer),
+			WithSubscriptionReannouncement(2, 500*time.Millisecond)),
+		getGossipsub(ctx, hosts[1], WithGossipSubParams(params)),
+	}
+	if _, err := psubs[0].Subscribe("foobar"); err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(1600 * time.Millisecond)
+
+	tracer.mx.Lock()
+	defer tracer.mx.Unlock()
+	if n := len(tracer.peers); n < 2 || n > 4 {
+		t.Fatalf("expected a re-announcement every 500ms, got %d", n)
+	}
+
+	if _, err := NewGossipSub(ctx, hosts[0], WithSubscriptionReannouncement(0, time.Minute)); err == nil {
+		t.Fatal("expected zero idle heartbeats to be invalid")
+	}
+}
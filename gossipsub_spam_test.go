@@ -2,9 +2,12 @@ package pubsub
 
 import (
 	"context"
+	"encoding/binary"
 	"math/rand"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -285,6 +288,296 @@ func TestGossipsubAttackSpamIHAVE(t *testing.T) {
 	<-ctx.Done()
 }
 
+type ignoreIHaveTracer struct {
+	mx      sync.Mutex
+	reasons map[pb.TraceEvent_IgnoreIHave_Reason]int
+}
+
+func (t *ignoreIHaveTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_IGNORE_IHAVE {
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.reasons[evt.GetIgnoreIHave().GetReason()]++
+}
+
+func (t *ignoreIHaveTracer) count(reason pb.TraceEvent_IgnoreIHave_Reason) int {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.reasons[reason]
+}
+
+// Test that Gossipsub ignores IHAVE from a peer with score below the gossip threshold
+func TestGossipsubAttackIHAVELowScore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Create legitimate and attacker hosts
+	hosts := getNetHosts(t, ctx, 2)
+	legit := hosts[0]
+	attacker := hosts[1]
+
+	// Set up gossipsub on the legit host, with the attacker scoring below the gossip threshold
+	tracer := &ignoreIHaveTracer{reasons: make(map[pb.TraceEvent_IgnoreIHave_Reason]int)}
+	ps, err := NewGossipSub(ctx, legit,
+		WithEventTracer(tracer),
+		WithPeerScore(
+			&PeerScoreParams{
+				AppSpecificScore: func(p peer.ID) float64 {
+					if p == attacker.ID() {
+						return -200
+					}
+					return 0
+				},
+				AppSpecificWeight: 1,
+				DecayInterval:     DefaultDecayInterval,
+				DecayToZero:       DefaultDecayToZero,
+			},
+			&PeerScoreThresholds{
+				GossipThreshold:   -100,
+				PublishThreshold:  -500,
+				GraylistThreshold: -1000,
+			}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mytopic := "mytopic"
+	_, err = ps.Subscribe(mytopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var iWantCount int32
+	newMockGS(ctx, t, attacker, func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+		// When the legit host connects it will send us its subscriptions
+		for _, sub := range irpc.GetSubscriptions() {
+			if sub.GetSubscribe() {
+				writeMsg(&pb.RPC{
+					Subscriptions: []*pb.RPC_SubOpts{{Subscribe: sub.Subscribe, Topicid: sub.Topicid}},
+				})
+
+				// Advertise some messages
+				for i := 0; i < 10; i++ {
+					ihavelst := []string{"someid" + strconv.Itoa(i)}
+					ihave := []*pb.ControlIHave{{TopicID: sub.Topicid, MessageIDs: ihavelst}}
+					orpc := rpcWithControl(nil, ihave, nil, nil, nil)
+					writeMsg(&orpc.RPC)
+				}
+			}
+		}
+
+		// Record the count of received IWANT messages
+		if ctl := irpc.GetControl(); ctl != nil {
+			atomic.AddInt32(&iWantCount, int32(len(ctl.GetIwant())))
+		}
+	})
+
+	connect(t, hosts[0], hosts[1])
+
+	time.Sleep(time.Second)
+
+	if n := atomic.LoadInt32(&iWantCount); n != 0 {
+		t.Fatalf("expected no IWANT in response to IHAVE from a peer below the gossip threshold, but got %d", n)
+	}
+	if n := tracer.count(pb.TraceEvent_IgnoreIHave_LOW_SCORE); n != 10 {
+		t.Fatalf("expected 10 traced ignored IHAVEs, but got %d", n)
+	}
+}
+
+// Test that Gossipsub applies the per topic IHAVE limits
+func TestGossipsubAttackIHAVETopicLimits(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Create legitimate and attacker hosts
+	hosts := getNetHosts(t, ctx, 2)
+	legit := hosts[0]
+	attacker := hosts[1]
+
+	tracer := &ignoreIHaveTracer{reasons: make(map[pb.TraceEvent_IgnoreIHave_Reason]int)}
+	ps, err := NewGossipSub(ctx, legit,
+		WithEventTracer(tracer),
+		WithTopicIHaveLimits("limited", 15, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, topic := range []string{"limited", "unlimited"} {
+		if _, err := ps.Subscribe(topic); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var iwantMx sync.Mutex
+	iwants := make(map[string]struct{})
+	newMockGS(ctx, t, attacker, func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+		// When the legit host connects it will send us its subscriptions
+		for _, sub := range irpc.GetSubscriptions() {
+			if sub.GetSubscribe() {
+				writeMsg(&pb.RPC{
+					Subscriptions: []*pb.RPC_SubOpts{{Subscribe: sub.Subscribe, Topicid: sub.Topicid}},
+				})
+
+				// Advertise 3 batches of 10 messages
+				for i := 0; i < 3; i++ {
+					var ihavelst []string
+					for j := 0; j < 10; j++ {
+						ihavelst = append(ihavelst, sub.GetTopicid()+strconv.Itoa(10*i+j))
+					}
+					ihave := []*pb.ControlIHave{{TopicID: sub.Topicid, MessageIDs: ihavelst}}
+					orpc := rpcWithControl(nil, ihave, nil, nil, nil)
+					writeMsg(&orpc.RPC)
+				}
+			}
+		}
+
+		// Record the received IWANTs
+		iwantMx.Lock()
+		defer iwantMx.Unlock()
+		for _, iwant := range irpc.GetControl().GetIwant() {
+			for _, mid := range iwant.GetMessageIDs() {
+				iwants[mid] = struct{}{}
+			}
+		}
+	})
+
+	connect(t, hosts[0], hosts[1])
+
+	// the heartbeat resets the limits, so check before it runs
+	time.Sleep(500 * time.Millisecond)
+
+	iwantMx.Lock()
+	defer iwantMx.Unlock()
+
+	limited, unlimited := 0, 0
+	for mid := range iwants {
+		if strings.HasPrefix(mid, "limited") {
+			limited++
+		} else {
+			unlimited++
+		}
+	}
+
+	if limited != 15 {
+		t.Fatalf("expected to ask for 15 messages in the limited topic, but asked for %d", limited)
+	}
+	if unlimited != 30 {
+		t.Fatalf("expected to ask for 30 messages in the unlimited topic, but asked for %d", unlimited)
+	}
+	if n := tracer.count(pb.TraceEvent_IgnoreIHave_TOO_MANY_IHAVES); n != 1 {
+		t.Fatalf("expected 1 IHAVE ignored for exceeding the topic limit, but got %d", n)
+	}
+}
+
+type rejectRPCTracer struct {
+	mx     sync.Mutex
+	limits map[pb.TraceEvent_RejectRPC_Limit]int
+}
+
+func (t *rejectRPCTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_REJECT_RPC {
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.limits[evt.GetRejectRPC().GetLimit()]++
+}
+
+func (t *rejectRPCTracer) count(limit pb.TraceEvent_RejectRPC_Limit) int {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.limits[limit]
+}
+
+// Test that Gossipsub drops RPCs exceeding the RPC limits before processing them, penalizing the
+// sender, and keeps processing the RPCs that follow
+func TestGossipsubAttackRPCLimits(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	legit := hosts[0]
+	attacker := hosts[1]
+
+	tracer := &rejectRPCTracer{limits: make(map[pb.TraceEvent_RejectRPC_Limit]int)}
+	ps, err := NewGossipSub(ctx, legit,
+		WithEventTracer(tracer),
+		WithRPCLimits(RPCLimits{MaxIHave: 5}),
+		WithPeerScore(
+			&PeerScoreParams{
+				AppSpecificScore:       func(peer.ID) float64 { return 0 },
+				BehaviourPenaltyWeight: -1,
+				BehaviourPenaltyDecay:  0.99,
+				DecayInterval:          DefaultDecayInterval,
+				DecayToZero:            DefaultDecayToZero,
+			},
+			&PeerScoreThresholds{
+				GossipThreshold:   -100,
+				PublishThreshold:  -500,
+				GraylistThreshold: -1000,
+			}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mytopic := "mytopic"
+	if _, err := ps.Subscribe(mytopic); err != nil {
+		t.Fatal(err)
+	}
+
+	iwanted := make(chan string, 10)
+	newMockGS(ctx, t, attacker, func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+		for _, sub := range irpc.GetSubscriptions() {
+			if sub.GetSubscribe() {
+				writeMsg(&pb.RPC{
+					Subscriptions: []*pb.RPC_SubOpts{{Subscribe: sub.Subscribe, Topicid: sub.Topicid}},
+				})
+
+				// one IHAVE too many, followed by an acceptable IHAVE
+				var ihaves []*pb.ControlIHave
+				for i := 0; i < 6; i++ {
+					ihaves = append(ihaves, &pb.ControlIHave{TopicID: sub.Topicid, MessageIDs: []string{"spam" + strconv.Itoa(i)}})
+				}
+				writeMsg(&rpcWithControl(nil, ihaves, nil, nil, nil).RPC)
+				writeMsg(&rpcWithControl(nil, ihaves[:1], nil, nil, nil).RPC)
+			}
+		}
+
+		for _, iwant := range irpc.GetControl().GetIwant() {
+			for _, mid := range iwant.GetMessageIDs() {
+				iwanted <- mid
+			}
+		}
+	})
+
+	connect(t, hosts[0], hosts[1])
+
+	select {
+	case mid := <-iwanted:
+		if mid != "spam0" {
+			t.Fatalf("expected an IWANT for the acceptable IHAVE, but got one for %s", mid)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the IWANT")
+	}
+
+	if n := tracer.count(pb.TraceEvent_RejectRPC_IHAVE); n != 1 {
+		t.Fatalf("expected 1 RPC rejected for exceeding the IHAVE limit, but got %d", n)
+	}
+
+	score := make(chan float64, 1)
+	ps.eval <- func() {
+		score <- ps.rt.(*GossipSubRouter).score.Score(attacker.ID())
+	}
+	if s := <-score; s >= 0 {
+		t.Fatalf("expected the attacker to be penalized, but its score is %f", s)
+	}
+}
+
 // Test that when Gossipsub receives GRAFT for an unknown topic, it ignores
 // the request
 func TestGossipsubAttackGRAFTNonExistentTopic(t *testing.T) {
@@ -762,6 +1055,127 @@ func TestGossipsubAttackInvalidMessageSpam(t *testing.T) {
 	<-ctx.Done()
 }
 
+// Test that the responses to our IWANTs arriving after we left the topic are dropped without
+// validation, and that the responding peer is not penalized for them.
+func TestGossipsubLeaveWithIWANTInFlight(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	legit := hosts[0]
+	responder := hosts[1]
+	mytopic := "mytopic"
+
+	params := DefaultGossipSubParams()
+	params.IWantFollowupTime = 500 * time.Millisecond
+	tracer := &rejectReasonTracer{reasons: make(map[string]int)}
+	ps, err := NewGossipSub(ctx, legit,
+		WithGossipSubParams(params),
+		WithPeerScore(
+			&PeerScoreParams{
+				AppSpecificScore:            func(peer.ID) float64 { return 0 },
+				BehaviourPenaltyWeight:      -1,
+				BehaviourPenaltyDecay:       ScoreParameterDecay(time.Minute),
+				DecayInterval:               DefaultDecayInterval,
+				DecayToZero:                 DefaultDecayToZero,
+				IPColocationFactorThreshold: 10,
+			},
+			&PeerScoreThresholds{
+				GossipThreshold:   -10,
+				PublishThreshold:  -100,
+				GraylistThreshold: -1000,
+			}),
+		WithPeerScoreEventLog(100, 10),
+		WithPeerGater(DefaultPeerGaterParams()),
+		WithMessageSignaturePolicy(LaxNoSign),
+		WithEventTracer(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var validated int32
+	err = ps.RegisterTopicValidator(mytopic, func(context.Context, peer.ID, *Message) bool {
+		atomic.AddInt32(&validated, 1)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := ps.Subscribe(mytopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs []*pb.Message
+	var mids []string
+	for i := 0; i < 5; i++ {
+		seqno := make([]byte, 8)
+		binary.BigEndian.PutUint64(seqno, uint64(i))
+		msg := &pb.Message{From: []byte(responder.ID()), Seqno: seqno, Data: []byte("hello"), Topic: &mytopic}
+		msgs = append(msgs, msg)
+		mids = append(mids, DefaultMsgIdFn(msg))
+	}
+
+	iwanted := make(chan struct{})
+	respond := make(chan struct{})
+	var once sync.Once
+	newMockGS(ctx, t, responder, func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+		for _, sub := range irpc.GetSubscriptions() {
+			if sub.GetSubscribe() {
+				writeMsg(&pb.RPC{
+					Subscriptions: []*pb.RPC_SubOpts{{Subscribe: sub.Subscribe, Topicid: sub.Topicid}},
+					Control:       &pb.ControlMessage{Ihave: []*pb.ControlIHave{{TopicID: sub.Topicid, MessageIDs: mids}}},
+				})
+			}
+		}
+
+		if len(irpc.GetControl().GetIwant()) > 0 {
+			once.Do(func() {
+				close(iwanted)
+				// respond once we have left the topic
+				<-respond
+				writeMsg(&pb.RPC{Publish: msgs})
+			})
+		}
+	})
+
+	connect(t, legit, responder)
+
+	select {
+	case <-iwanted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an IWANT for the advertised messages")
+	}
+	sub.Cancel()
+	time.Sleep(100 * time.Millisecond)
+	close(respond)
+
+	// wait for the promises to expire
+	time.Sleep(params.IWantFollowupTime + 2*params.HeartbeatInterval)
+
+	if n := atomic.LoadInt32(&validated); n != 0 {
+		t.Fatalf("expected no validation in the topic we left, got %d", n)
+	}
+	if n := tracer.count(RejectTopicLeft); n != len(msgs) {
+		t.Fatalf("expected %d messages to be rejected in the topic we left, got %d", len(msgs), n)
+	}
+	for _, evt := range ps.PeerScoreEvents(responder.ID()) {
+		if evt.Type == ScoreEventBehaviourPenalty {
+			t.Fatalf("expected no behaviour penalty for the responder, got %q", evt.Reason)
+		}
+	}
+
+	// the gater doesn't count the responder as a rejecter either
+	gate := ps.rt.(*GossipSubRouter).gate
+	gate.Lock()
+	st := gate.getPeerStats(responder.ID())
+	reject := st.reject
+	gate.Unlock()
+	if reject != 0 {
+		t.Fatalf("expected no rejections by the gater for the responder, got %f", reject)
+	}
+}
+
 type mockGSOnRead func(writeMsg func(*pb.RPC), irpc *pb.RPC)
 
 func newMockGS(ctx context.Context, t *testing.T, attacker host.Host, onReadMsg mockGSOnRead) {
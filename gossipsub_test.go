@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -17,6 +18,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/core/record"
 
 	bhost "github.com/libp2p/go-libp2p/p2p/host/blank"
@@ -582,6 +584,154 @@ func TestGossipsubPrune(t *testing.T) {
 	}
 }
 
+type pruneReasonTracer struct {
+	mx      sync.Mutex
+	reasons map[pb.ControlPrune_Reason]int
+}
+
+func (t *pruneReasonTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_PRUNE {
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.reasons[evt.GetPrune().GetReason()]++
+}
+
+func TestGossipsubPruneReasonOversubscribed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hosts := getNetHosts(t, ctx, 20)
+
+	tracer := &pruneReasonTracer{reasons: make(map[pb.ControlPrune_Reason]int)}
+	hub := getGossipsub(ctx, hosts[0], WithEventTracer(tracer))
+	leaves := getGossipsubs(ctx, hosts[1:])
+
+	if _, err := hub.Subscribe("foobar"); err != nil {
+		t.Fatal(err)
+	}
+
+	var evts []*TopicEventHandler
+	for _, ps := range leaves {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		evt, err := topic.EventHandler(WithPruneEvents())
+		if err != nil {
+			t.Fatal(err)
+		}
+		evts = append(evts, evt)
+		if _, err := topic.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, h := range hosts[1:] {
+		connect(t, hosts[0], h)
+	}
+
+	// wait for the subscriptions to propagate
+	time.Sleep(time.Second)
+
+	// oversubscribe the hub's mesh with all the leaves
+	done := make(chan struct{})
+	hub.eval <- func() {
+		gs := hub.rt.(*GossipSubRouter)
+		for _, h := range hosts[1:] {
+			gs.mesh["foobar"][h.ID()] = struct{}{}
+		}
+		close(done)
+	}
+	<-done
+
+	// wait for the heartbeat to prune the excess peers
+	time.Sleep(2 * time.Second)
+
+	pruned := 0
+	for i, ps := range leaves {
+		reason, ok := ps.LastPruneReason("foobar", hosts[0].ID())
+		if !ok {
+			continue
+		}
+		if reason != PruneReasonOversubscribed {
+			t.Fatalf("expected the hub to prune with reason %s, but got %s", PruneReasonOversubscribed, reason)
+		}
+		pruned++
+
+		// the prune must also have been surfaced in the topic event stream
+		found := false
+		for !found {
+			ctx, cancel := context.WithTimeout(ctx, time.Second)
+			e, err := evts[i].NextPeerEvent(ctx)
+			cancel()
+			if err != nil {
+				t.Fatal("expected a prune event")
+			}
+			if e.Type == PeerPruned {
+				if e.Peer != hosts[0].ID() || e.Reason != PruneReasonOversubscribed {
+					t.Fatalf("unexpected prune event %v", e)
+				}
+				found = true
+			}
+		}
+	}
+
+	if pruned < len(leaves)-GossipSubD {
+		t.Fatalf("expected at least %d leaves to be pruned, but %d were", len(leaves)-GossipSubD, pruned)
+	}
+
+	tracer.mx.Lock()
+	defer tracer.mx.Unlock()
+	if tracer.reasons[pb.ControlPrune_OVERSUBSCRIBED] < len(leaves)-GossipSubD {
+		t.Fatalf("expected at least %d traced oversubscription prunes, but got %v", len(leaves)-GossipSubD, tracer.reasons)
+	}
+}
+
+func TestGossipsubPruneReasonLeave(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hosts := getNetHosts(t, ctx, 4)
+
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0]),
+		getGossipsub(ctx, hosts[1]),
+		// this one withholds all prune reasons
+		getGossipsub(ctx, hosts[2], WithPruneReasons()),
+		getGossipsub(ctx, hosts[3]),
+	}
+
+	var subs []*Subscription
+	for _, ps := range psubs {
+		subs = append(subs, mustSubscribe(t, ps, "foobar"))
+	}
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[2], hosts[3])
+
+	// wait for the mesh to form
+	time.Sleep(time.Second)
+
+	if _, ok := psubs[1].LastPruneReason("foobar", hosts[0].ID()); ok {
+		t.Fatal("expected no prune reason before the peer leaves")
+	}
+
+	subs[0].Cancel()
+	subs[2].Cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	reason, ok := psubs[1].LastPruneReason("foobar", hosts[0].ID())
+	if !ok || reason != PruneReasonLeave {
+		t.Fatalf("expected prune reason %s, but got %s", PruneReasonLeave, reason)
+	}
+
+	reason, ok = psubs[3].LastPruneReason("foobar", hosts[2].ID())
+	if !ok || reason != PruneReasonUnknown {
+		t.Fatalf("expected the prune reason to be withheld, but got %s", reason)
+	}
+}
+
 func TestGossipsubPruneBackoffTime(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -1782,7 +1932,7 @@ func TestGossipsubMultipleGraftTopics(t *testing.T) {
 	// 1st peer
 	p1Router.sendGraftPrune(map[peer.ID][]string{
 		secondPeer: {firstTopic, secondTopic, thirdTopic},
-	}, map[peer.ID][]string{}, map[peer.ID]bool{})
+	}, map[peer.ID][]string{}, nil, map[peer.ID]bool{})
 
 	time.Sleep(time.Second * 1)
 
@@ -2126,6 +2276,120 @@ func TestGossipsubPeerScoreInspect(t *testing.T) {
 	}
 }
 
+func TestGossipsubShadowScore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+
+	makeParams := func(appScore float64) *PeerScoreParams {
+		return &PeerScoreParams{
+			Topics: map[string]*TopicScoreParams{
+				"test": {
+					TopicWeight:                    1,
+					TimeInMeshQuantum:              time.Second,
+					FirstMessageDeliveriesWeight:   1,
+					FirstMessageDeliveriesDecay:    0.999,
+					FirstMessageDeliveriesCap:      100,
+					InvalidMessageDeliveriesWeight: -1,
+					InvalidMessageDeliveriesDecay:  0.9999,
+				},
+			},
+			AppSpecificScore:  func(peer.ID) float64 { return appScore },
+			AppSpecificWeight: 1,
+			DecayInterval:     time.Second,
+			DecayToZero:       0.01,
+		}
+	}
+
+	var mx sync.Mutex
+	var live, shadow float64
+	inspect := func(scores map[peer.ID]*PeerScoreSnapshot) {
+		mx.Lock()
+		defer mx.Unlock()
+		for p, s := range scores {
+			if p != hosts[1].ID() {
+				continue
+			}
+			if s.Shadow {
+				shadow = s.Score
+			} else {
+				live = s.Score
+			}
+		}
+	}
+
+	psub1 := getGossipsub(ctx, hosts[0],
+		WithPeerScore(makeParams(0),
+			&PeerScoreThresholds{
+				GossipThreshold:   -1,
+				PublishThreshold:  -10,
+				GraylistThreshold: -1000,
+			}),
+		WithShadowScoreParams(makeParams(-100)),
+		WithPeerScoreInspect(ExtendedPeerScoreInspectFn(inspect), 100*time.Millisecond))
+	psub2 := getGossipsub(ctx, hosts[1])
+
+	connect(t, hosts[0], hosts[1])
+
+	sub, err := psub1.Subscribe("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := psub2.Subscribe("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Second)
+
+	// the shadow scores the peer negatively, below the publish threshold, but the router doesn't
+	// consult it: we keep receiving the messages of the peer and publishing to it
+	for i := 0; i < 20; i++ {
+		msg := fmt.Sprintf("message %d", i)
+		if err := psub2.Publish("test", []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		expectMessage(t, ctx, sub, msg)
+	}
+
+	waitFor(t, "the scores to be inspected", func() bool {
+		mx.Lock()
+		defer mx.Unlock()
+		return live >= 19 && shadow <= -80 && shadow >= -82
+	})
+
+	sub2, err := psub2.Subscribe("test2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := psub1.Publish("test2", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	expectMessage(t, ctx, sub2, "hello")
+}
+
+func TestGossipsubShadowScoreOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+
+	params := &PeerScoreParams{
+		AppSpecificScore: func(peer.ID) float64 { return 0 },
+		DecayInterval:    time.Second,
+		DecayToZero:      0.01,
+	}
+	if _, err := NewGossipSub(ctx, hosts[0], WithShadowScoreParams(params)); err == nil {
+		t.Fatal("expected an error without peer scoring")
+	}
+	if _, err := NewGossipSub(ctx, hosts[0],
+		WithPeerScore(params, &PeerScoreThresholds{}),
+		WithShadowScoreParams(&PeerScoreParams{})); err == nil {
+		t.Fatal("expected an error for invalid shadow parameters")
+	}
+}
+
 func TestGossipsubPeerScoreResetTopicParams(t *testing.T) {
 	// this test exercises the code path sof peer score inspection
 	ctx, cancel := context.WithCancel(context.Background())
@@ -2500,3 +2764,398 @@ func TestFragmentRPCFunction(t *testing.T) {
 			results[0].Control.Iwant[0].MessageIDs[0])
 	}
 }
+
+func TestGossipsubRandomSource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+
+	// routers with sources using the same seed must make identical choices for the same peers
+	var meshes [][]peer.ID
+	for _, h := range hosts {
+		ps := getGossipsub(ctx, h, WithRandomSource(rand.NewSource(42)))
+
+		done := make(chan []peer.ID)
+		ps.eval <- func() {
+			gs := ps.rt.(*GossipSubRouter)
+
+			tmap := make(map[peer.ID]struct{})
+			for i := 0; i < 20; i++ {
+				p := peer.ID(fmt.Sprintf("peer-%d", i))
+				gs.peers[p] = GossipSubID_v11
+				gs.confirmed[p] = true
+				tmap[p] = struct{}{}
+			}
+			ps.topics["test"] = tmap
+
+			gs.Join("test")
+
+			mesh := peerMapToList(gs.mesh["test"])
+			sort.Slice(mesh, func(i, j int) bool { return mesh[i] < mesh[j] })
+			done <- mesh
+		}
+		meshes = append(meshes, <-done)
+	}
+
+	if len(meshes[0]) != GossipSubD {
+		t.Fatalf("expected %d mesh peers, got %d", GossipSubD, len(meshes[0]))
+	}
+	for i := range meshes[0] {
+		if meshes[0][i] != meshes[1][i] {
+			t.Fatalf("expected identical graft choices, got %v and %v", meshes[0], meshes[1])
+		}
+	}
+}
+
+func TestGossipsubHeartbeatStallNotify(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+
+	params := DefaultGossipSubParams()
+	params.HeartbeatInitialDelay = 10 * time.Millisecond
+	params.HeartbeatInterval = 50 * time.Millisecond
+
+	stalls := make(chan time.Duration, 10)
+	threshold := 200 * time.Millisecond
+	ps := getGossipsub(ctx, hosts[0],
+		WithGossipSubParams(params),
+		WithHeartbeatStallNotify(threshold, func(lag time.Duration) {
+			stalls <- lag
+		}))
+
+	time.Sleep(300 * time.Millisecond)
+
+	last, seq := ps.LastHeartbeat()
+	if seq == 0 || time.Since(last) > threshold {
+		t.Fatalf("expected recent heartbeats, but the last one was %d at %s", seq, last)
+	}
+	select {
+	case lag := <-stalls:
+		t.Fatalf("unexpected stall notification with lag %s", lag)
+	default:
+	}
+
+	// block the event loop so that the heartbeat can't run
+	ps.eval <- func() {
+		time.Sleep(time.Second)
+	}
+
+	select {
+	case lag := <-stalls:
+		if lag < threshold {
+			t.Fatalf("expected a lag of at least %s, but got %s", threshold, lag)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a stall notification")
+	}
+
+	// the notification should fire only once for the stall
+	time.Sleep(time.Second)
+	if len(stalls) != 0 {
+		t.Fatalf("expected a single stall notification, but got %d more", len(stalls))
+	}
+
+	_, seq2 := ps.LastHeartbeat()
+	if seq2 <= seq {
+		t.Fatal("expected the heartbeat to resume")
+	}
+}
+
+func TestGossipsubSmallNetwork(t *testing.T) {
+	// in a 4 node network, the peers can't deliver enough messages to satisfy the mesh message
+	// delivery threshold, so they all get penalized and pruned unless we adapt to the network size
+	countPrunes := func(adapt bool) int {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		hosts := getNetHosts(t, ctx, 4)
+
+		params := DefaultGossipSubParams()
+		params.HeartbeatInterval = 100 * time.Millisecond
+		params.AdaptToSmallNetworks = adapt
+
+		var tracers []*pruneReasonTracer
+		var psubs []*PubSub
+		for _, h := range hosts {
+			tracer := &pruneReasonTracer{reasons: make(map[pb.ControlPrune_Reason]int)}
+			tracers = append(tracers, tracer)
+			psubs = append(psubs, getGossipsub(ctx, h,
+				WithGossipSubParams(params),
+				WithEventTracer(tracer),
+				WithPeerScore(
+					&PeerScoreParams{
+						AppSpecificScore: func(peer.ID) float64 { return 0 },
+						Topics: map[string]*TopicScoreParams{
+							"foobar": {
+								TopicWeight:                     1,
+								TimeInMeshQuantum:               time.Second,
+								MeshMessageDeliveriesWeight:     -1,
+								MeshMessageDeliveriesDecay:      0.9,
+								MeshMessageDeliveriesCap:        100,
+								MeshMessageDeliveriesThreshold:  10,
+								MeshMessageDeliveriesActivation: time.Second,
+								InvalidMessageDeliveriesDecay:   0.9,
+							},
+						},
+						DecayInterval: time.Second,
+						DecayToZero:   0.01,
+					},
+					&PeerScoreThresholds{
+						GossipThreshold:   -1000,
+						PublishThreshold:  -2000,
+						GraylistThreshold: -3000,
+					})))
+		}
+
+		for _, ps := range psubs {
+			if _, err := ps.Subscribe("foobar"); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		connectAll(t, hosts)
+
+		// let the mesh run for many heartbeats past the activation of the delivery penalty
+		time.Sleep(5 * time.Second)
+
+		prunes := 0
+		for _, tracer := range tracers {
+			tracer.mx.Lock()
+			for _, n := range tracer.reasons {
+				prunes += n
+			}
+			tracer.mx.Unlock()
+		}
+		return prunes
+	}
+
+	if prunes := countPrunes(false); prunes == 0 {
+		t.Fatal("expected mesh churn without adapting to the network size")
+	}
+	if prunes := countPrunes(true); prunes != 0 {
+		t.Fatalf("expected no prunes in small network mode, but got %d", prunes)
+	}
+}
+
+func TestGossipsubOutboundMeshReservation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hosts := getNetHosts(t, ctx, 17)
+
+	hub := getGossipsub(ctx, hosts[0],
+		WithOutboundMeshReservation(),
+		WithTopicOutboundMeshReservation("unreserved", 0))
+	leaves := getGossipsubs(ctx, hosts[1:15])
+	outbound := getGossipsubs(ctx, hosts[15:])
+
+	for _, topic := range []string{"foobar", "unreserved"} {
+		if _, err := hub.Subscribe(topic); err != nil {
+			t.Fatal(err)
+		}
+		for _, ps := range leaves {
+			if _, err := ps.Subscribe(topic); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, ps := range outbound {
+		if _, err := ps.Subscribe("foobar"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	meshPeers := func(topic string) (inbound, outbound int) {
+		done := make(chan struct{})
+		hub.eval <- func() {
+			gs := hub.rt.(*GossipSubRouter)
+			for p := range gs.mesh[topic] {
+				if gs.outbound[p] {
+					outbound++
+				} else {
+					inbound++
+				}
+			}
+			close(done)
+		}
+		<-done
+		return inbound, outbound
+	}
+
+	// the leaves dial the hub and graft it, filling its mesh with inbound peers
+	for _, h := range hosts[1:15] {
+		connect(t, hosts[0], h)
+	}
+	time.Sleep(2 * time.Second)
+
+	params := DefaultGossipSubParams()
+	if in, out := meshPeers("foobar"); in != params.Dhi-params.Dout || out != 0 {
+		t.Fatalf("expected %d inbound mesh peers in the reserved topic, but got %d inbound and %d outbound", params.Dhi-params.Dout, in, out)
+	}
+	if in, _ := meshPeers("unreserved"); in != params.Dhi {
+		t.Fatalf("expected %d inbound mesh peers in the unreserved topic, but got %d", params.Dhi, in)
+	}
+
+	// the reserved slots are taken by the peers the hub dials
+	for _, h := range hosts[15:] {
+		connect(t, h, hosts[0])
+	}
+	time.Sleep(2 * time.Second)
+
+	if in, out := meshPeers("foobar"); in != params.Dhi-params.Dout || out != params.Dout {
+		t.Fatalf("expected %d outbound mesh peers in the reserved slots, but got %d inbound and %d outbound", params.Dout, in, out)
+	}
+}
+
+func TestGossipsubKnownHolderExclusion(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			const topic = "foobar"
+			hosts := getNetHosts(t, ctx, 3)
+
+			// message IDs are the payloads, so that the mock can advertise the message before it
+			// is published
+			msgID := func(pmsg *pb.Message) string { return string(pmsg.Data) }
+			a := getGossipsub(ctx, hosts[0], WithMessageIdFn(msgID), WithKnownHolderExclusion(enabled))
+			c := getGossipsub(ctx, hosts[2], WithMessageIdFn(msgID))
+
+			if _, err := a.Subscribe(topic); err != nil {
+				t.Fatal(err)
+			}
+			ct, err := c.Join(topic)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ct.Subscribe(); err != nil {
+				t.Fatal(err)
+			}
+
+			// B joins the mesh of A, and advertises the message
+			var pushed atomic.Bool
+			var once sync.Once
+			newMockGS(ctx, t, hosts[1], func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+				once.Do(func() {
+					tp := topic
+					writeMsg(&pb.RPC{
+						Subscriptions: []*pb.RPC_SubOpts{{Subscribe: &[]bool{true}[0], Topicid: &tp}},
+						Control: &pb.ControlMessage{
+							Graft: []*pb.ControlGraft{{TopicID: &tp}},
+							Ihave: []*pb.ControlIHave{{TopicID: &tp, MessageIDs: []string{"hello"}}},
+						},
+					})
+				})
+				for _, msg := range irpc.GetPublish() {
+					if string(msg.Data) == "hello" {
+						pushed.Store(true)
+					}
+				}
+			})
+
+			connect(t, hosts[0], hosts[1])
+			connect(t, hosts[0], hosts[2])
+			time.Sleep(time.Second)
+
+			// A receives the message from C, and forwards it to its mesh
+			if err := ct.Publish(ctx, []byte("hello")); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(500 * time.Millisecond)
+
+			if pushed.Load() == enabled {
+				t.Fatalf("expected the message to be pushed to the peer that advertised it: %t", !enabled)
+			}
+		})
+	}
+}
+
+type ihaveTracer struct {
+	mx     sync.Mutex
+	ihaves map[string]int
+}
+
+func (t *ihaveTracer) SendRPC(rpc *RPC, p peer.ID) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for _, ihave := range rpc.GetControl().GetIhave() {
+		for _, mid := range ihave.GetMessageIDs() {
+			t.ihaves[mid]++
+		}
+	}
+}
+
+func (t *ihaveTracer) get() map[string]int {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	ihaves := make(map[string]int, len(t.ihaves))
+	for mid, n := range t.ihaves {
+		ihaves[mid] = n
+	}
+	return ihaves
+}
+
+func (t *ihaveTracer) AddPeer(p peer.ID, proto protocol.ID)      {}
+func (t *ihaveTracer) RemovePeer(p peer.ID)                      {}
+func (t *ihaveTracer) Join(topic string)                         {}
+func (t *ihaveTracer) Leave(topic string)                        {}
+func (t *ihaveTracer) Graft(p peer.ID, topic string)             {}
+func (t *ihaveTracer) Prune(p peer.ID, topic string)             {}
+func (t *ihaveTracer) ValidateMessage(msg *Message)              {}
+func (t *ihaveTracer) DeliverMessage(msg *Message)               {}
+func (t *ihaveTracer) RejectMessage(msg *Message, reason string) {}
+func (t *ihaveTracer) DuplicateMessage(msg *Message)             {}
+func (t *ihaveTracer) ThrottlePeer(p peer.ID)                    {}
+func (t *ihaveTracer) RecvRPC(rpc *RPC)                          {}
+func (t *ihaveTracer) DropRPC(rpc *RPC, p peer.ID)               {}
+func (t *ihaveTracer) UndeliverableMessage(msg *Message)         {}
+
+// Test that we don't advertise again the messages a peer already knows of from us in the following
+// heartbeats.
+func TestGossipsubGossipDedup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	tracer := &ihaveTracer{ihaves: make(map[string]int)}
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0], WithRawTracer(tracer)),
+		getGossipsub(ctx, hosts[1]),
+	}
+	for _, ps := range psubs {
+		if _, err := ps.Subscribe("foobar"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	// with fewer than D_lo peers, we gossip to the mesh peer in every heartbeat
+	publish := func(n int) {
+		for i := 0; i < n; i++ {
+			if err := psubs[0].Publish("foobar", []byte(fmt.Sprintf("message %d", i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		time.Sleep(2 * time.Second)
+	}
+
+	publish(3)
+	ihaves := tracer.get()
+	if len(ihaves) != 3 {
+		t.Fatalf("expected to advertise 3 messages, got %d", len(ihaves))
+	}
+
+	publish(2)
+	ihaves = tracer.get()
+	if len(ihaves) != 5 {
+		t.Fatalf("expected to advertise 5 messages, got %d", len(ihaves))
+	}
+	for mid, n := range ihaves {
+		if n != 1 {
+			t.Fatalf("expected to advertise message %s once, got %d", mid, n)
+		}
+	}
+}
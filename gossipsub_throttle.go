@@ -0,0 +1,193 @@
+package pubsub
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+// WithSlowConsumerThrottle is a gossipsub router option that throttles the topics whose local
+// subscribers can't keep up: when the subscriptions of a topic drop more than threshold messages
+// within a window, the mesh of the topic contracts to D_lo peers and we stop asking for the
+// messages advertised in gossip for it. The topic is restored at the end of the first window with
+// threshold dropped messages or less, with the mesh refilled towards D peers at each heartbeat as
+// the peers pruned while throttled come out of backoff. Transitions are traced
+// with a SLOW_CONSUMER_THROTTLE event; see also SlowConsumerThrottleStats.
+// Throttling trades the completeness of the delivery for the stability of the node, so it is
+// disabled by default.
+func WithSlowConsumerThrottle(threshold int, window time.Duration) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+		if threshold < 0 {
+			return fmt.Errorf("invalid slow consumer threshold; must be non-negative")
+		}
+		if window <= 0 {
+			return fmt.Errorf("invalid slow consumer window; must be positive")
+		}
+
+		gs.throttle = &slowConsumerThrottle{
+			threshold: uint64(threshold),
+			window:    window,
+			clock:     clock.New(),
+			topics:    make(map[string]*topicThrottle),
+		}
+		return nil
+	}
+}
+
+// SlowConsumerTopicStats is the throttling state of a topic with dropped messages.
+type SlowConsumerTopicStats struct {
+	// Throttled is true while the topic is throttled.
+	Throttled bool
+	// Dropped is the number of messages dropped by the subscriptions in the current window.
+	Dropped uint64
+	// Throttles is the number of times the topic has been throttled.
+	Throttles uint64
+}
+
+// SlowConsumerThrottleStats returns the throttling state of the topics whose subscriptions have
+// dropped messages. It returns false if there is no slow consumer throttle.
+func (p *PubSub) SlowConsumerThrottleStats() (map[string]SlowConsumerTopicStats, bool) {
+	type result struct {
+		stats map[string]SlowConsumerTopicStats
+		ok    bool
+	}
+
+	out := make(chan result, 1)
+	select {
+	case p.eval <- func() {
+		gs, ok := p.rt.(*GossipSubRouter)
+		if !ok || gs.throttle == nil {
+			out <- result{}
+			return
+		}
+
+		stats := make(map[string]SlowConsumerTopicStats, len(gs.throttle.topics))
+		for topic, tt := range gs.throttle.topics {
+			stats[topic] = SlowConsumerTopicStats{
+				Throttled: tt.throttled,
+				Dropped:   tt.dropped,
+				Throttles: tt.throttles,
+			}
+		}
+		out <- result{stats, true}
+	}:
+		res := <-out
+		return res.stats, res.ok
+	case <-p.ctx.Done():
+		return nil, false
+	}
+}
+
+// slowConsumerThrottle tracks the messages dropped by the subscriptions of each topic. A nil
+// throttle tracks nothing.
+type slowConsumerThrottle struct {
+	threshold uint64
+	window    time.Duration
+	clock     clock.Clock
+
+	topics map[string]*topicThrottle
+}
+
+type topicThrottle struct {
+	windowStart time.Time
+	dropped     uint64
+	throttled   bool
+	throttles   uint64
+	// the mesh is refilled until then after restoring the topic
+	recoverUntil time.Time
+}
+
+// drop records a message dropped by a subscription of a topic.
+func (st *slowConsumerThrottle) drop(topic string) {
+	if st == nil {
+		return
+	}
+
+	tt, ok := st.topics[topic]
+	if !ok {
+		tt = &topicThrottle{windowStart: st.clock.Now()}
+		st.topics[topic] = tt
+	}
+	tt.dropped++
+}
+
+// throttled returns whether a topic is throttled.
+func (st *slowConsumerThrottle) throttled(topic string) bool {
+	if st == nil {
+		return false
+	}
+	tt, ok := st.topics[topic]
+	return ok && tt.throttled
+}
+
+// leave stops tracking a topic the application has left.
+func (st *slowConsumerThrottle) leave(topic string) {
+	if st == nil {
+		return
+	}
+	delete(st.topics, topic)
+}
+
+// slowConsumer records a message dropped by a subscription that is too slow.
+// Only called from processLoop.
+func (p *PubSub) slowConsumer(topic string) {
+	if gs, ok := p.rt.(*GossipSubRouter); ok {
+		gs.throttle.drop(topic)
+	}
+}
+
+// checkSlowConsumers throttles the topics whose subscriptions drop too many messages, and
+// restores them once the drops subside.
+// Only called from the heartbeat.
+func (gs *GossipSubRouter) checkSlowConsumers() {
+	st := gs.throttle
+	if st == nil {
+		return
+	}
+
+	now := st.clock.Now()
+	for topic, tt := range st.topics {
+		if !tt.throttled && now.Before(tt.recoverUntil) {
+			if len(gs.mesh[topic]) < gs.params.D {
+				gs.refillMesh(topic)
+			} else {
+				tt.recoverUntil = time.Time{}
+			}
+		}
+
+		if tt.dropped > st.threshold && !tt.throttled {
+			gs.p.logger.Debugw("throttling topic with slow subscribers", "topic", topic, "dropped", tt.dropped)
+			tt.throttled = true
+			tt.throttles++
+			gs.tracer.SlowConsumerThrottle(topic, true, tt.dropped)
+		}
+
+		if now.Sub(tt.windowStart) < st.window {
+			continue
+		}
+
+		if tt.dropped <= st.threshold {
+			if tt.throttled {
+				gs.p.logger.Debugw("restoring topic with slow subscribers", "topic", topic, "dropped", tt.dropped)
+				tt.throttled = false
+				gs.tracer.SlowConsumerThrottle(topic, false, tt.dropped)
+				gs.refillMesh(topic)
+				// until the peers pruned while throttled have been cleared from backoff
+				tt.recoverUntil = now.Add(gs.params.PruneBackoff + 2*GossipSubHeartbeatInterval + 15*gs.params.HeartbeatInterval)
+			}
+			if tt.dropped == 0 && tt.throttles == 0 {
+				// nothing to track until the next drop
+				delete(st.topics, topic)
+				continue
+			}
+		}
+
+		tt.windowStart = now
+		tt.dropped = 0
+	}
+}
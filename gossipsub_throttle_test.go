@@ -0,0 +1,156 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+type throttleTracer struct {
+	mx          sync.Mutex
+	transitions []bool
+}
+
+func (t *throttleTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_SLOW_CONSUMER_THROTTLE {
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.transitions = append(t.transitions, evt.GetSlowConsumerThrottle().GetThrottled())
+}
+
+func (t *throttleTracer) get() []bool {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return append([]bool(nil), t.transitions...)
+}
+
+func TestGossipsubSlowConsumerThrottle(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	params := DefaultGossipSubParams()
+	params.D = 6
+	params.Dlo = 3
+	params.Dhi = 8
+	params.HeartbeatInterval = 100 * time.Millisecond
+	// the peers pruned while throttled are backed off for a short while
+	params.PruneBackoff = time.Second
+
+	hosts := getNetHosts(t, ctx, 14)
+	tracer := &throttleTracer{}
+	psubs := []*PubSub{getGossipsub(ctx, hosts[0],
+		WithGossipSubParams(params),
+		WithEventTracer(tracer),
+		WithSlowConsumerThrottle(5, 500*time.Millisecond))}
+	psubs = append(psubs, getGossipsubs(ctx, hosts[1:], WithGossipSubParams(params))...)
+
+	// our subscriber is slow, with no room in its buffer
+	slow, err := psubs[0].Subscribe("foobar", WithBufferSize(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ps := range psubs[1:] {
+		sub, err := ps.Subscribe("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			for {
+				if _, err := sub.Next(ctx); err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	connectAll(t, hosts)
+	time.Sleep(time.Second)
+
+	gs := psubs[0].rt.(*GossipSubRouter)
+	meshSize := func() int {
+		res := make(chan int, 1)
+		psubs[0].eval <- func() { res <- len(gs.mesh["foobar"]) }
+		return <-res
+	}
+	// whether we ask for the messages advertised in gossip
+	asks := func() bool {
+		res := make(chan bool, 1)
+		topic := "foobar"
+		ihave := &pb.ControlMessage{Ihave: []*pb.ControlIHave{{TopicID: &topic, MessageIDs: []string{"unseen"}}}}
+		psubs[0].eval <- func() { res <- len(gs.handleIHave(hosts[1].ID(), ihave)) > 0 }
+		return <-res
+	}
+
+	if n := meshSize(); n < params.D {
+		t.Fatalf("expected a mesh of at least %d peers, got %d", params.D, n)
+	}
+	if !asks() {
+		t.Fatal("expected to ask for the advertised messages")
+	}
+
+	for i := 0; i < 30; i++ {
+		if err := psubs[1+i%13].Publish("foobar", []byte(fmt.Sprintf("message %d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	// the mesh contracts, and we stop asking for gossip
+	if n := meshSize(); n > params.Dlo {
+		t.Fatalf("expected the mesh to contract to %d peers, got %d", params.Dlo, n)
+	}
+	if asks() {
+		t.Fatal("expected not to ask for the advertised messages while throttled")
+	}
+	stats, ok := psubs[0].SlowConsumerThrottleStats()
+	if !ok || !stats["foobar"].Throttled || stats["foobar"].Throttles != 1 {
+		t.Fatalf("expected the topic to be throttled, got %v", stats)
+	}
+
+	// the subscriber catches up
+	for {
+		rctx, rcancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		_, err := slow.Next(rctx)
+		rcancel()
+		if err != nil {
+			break
+		}
+	}
+	time.Sleep(5 * time.Second)
+
+	if n := meshSize(); n < params.D {
+		t.Fatalf("expected the mesh to be refilled to %d peers, got %d", params.D, n)
+	}
+	if !asks() {
+		t.Fatal("expected to ask for the advertised messages again")
+	}
+	stats, _ = psubs[0].SlowConsumerThrottleStats()
+	if stats["foobar"].Throttled {
+		t.Fatalf("expected the topic to be restored, got %v", stats)
+	}
+	if transitions := tracer.get(); len(transitions) != 2 || !transitions[0] || transitions[1] {
+		t.Fatalf("expected the topic to be throttled and restored, got %v", transitions)
+	}
+}
+
+func TestGossipsubSlowConsumerThrottleDisabled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getGossipsub(ctx, hosts[0])
+	if _, ok := ps.SlowConsumerThrottleStats(); ok {
+		t.Fatal("expected no slow consumer throttle by default")
+	}
+
+	if _, err := NewGossipSub(ctx, hosts[0], WithSlowConsumerThrottle(5, 0)); err == nil {
+		t.Fatal("expected a zero window to be invalid")
+	}
+}
@@ -0,0 +1,229 @@
+package pubsub
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// TopicRuntimeConfig is the runtime configuration of a topic, provided by a ConfigProvider. The
+// unset fields keep the configuration of the router.
+type TopicRuntimeConfig struct {
+	// ScoreParams are the score parameters of the topic; they require peer scoring, and they take
+	// precedence over the topic score parameters resolver. Once set, they are kept when the
+	// configuration no longer sets them.
+	ScoreParams *TopicScoreParams
+
+	// D, Dlo and Dhi override the mesh degree parameters of the router for the topic; they must
+	// be set together, with 0 < Dlo <= D <= Dhi.
+	D, Dlo, Dhi int
+
+	// FloodPublish overrides the flood publishing of the router for the messages we publish in
+	// the topic.
+	FloodPublish *bool
+}
+
+func (c *TopicRuntimeConfig) validate() error {
+	if c.ScoreParams != nil {
+		if err := c.ScoreParams.Validate(); err != nil {
+			return fmt.Errorf("invalid topic score parameters: %w", err)
+		}
+	}
+	if c.D != 0 || c.Dlo != 0 || c.Dhi != 0 {
+		if c.Dlo <= 0 || c.Dlo > c.D || c.D > c.Dhi {
+			return fmt.Errorf("invalid mesh degree override; must be 0 < Dlo <= D <= Dhi")
+		}
+	}
+	return nil
+}
+
+// clone copies the configuration, so that the provider can reuse it.
+func (c *TopicRuntimeConfig) clone() *TopicRuntimeConfig {
+	cc := *c
+	if c.ScoreParams != nil {
+		sp := *c.ScoreParams
+		cc.ScoreParams = &sp
+	}
+	if c.FloodPublish != nil {
+		fp := *c.FloodPublish
+		cc.FloodPublish = &fp
+	}
+	return &cc
+}
+
+// ConfigProvider provides the runtime configuration of the topics we have joined; see
+// WithConfigProvider. TopicConfig returns nil when there is no configuration for the topic.
+type ConfigProvider interface {
+	TopicConfig(topic string) (*TopicRuntimeConfig, error)
+}
+
+// ConfigNotifier is optionally implemented by a ConfigProvider that pushes configuration changes;
+// the topics whose configuration has changed are sent on the channel, and refreshed right away.
+type ConfigNotifier interface {
+	ConfigUpdates() <-chan string
+}
+
+// WithConfigProvider is a gossipsub router option that drives the runtime configuration of the
+// topics we have joined from a provider, such as a configuration service: the configuration of
+// each topic is refreshed every refresh interval, and whenever the provider notifies a change
+// with ConfigNotifier.
+// The changed configurations are applied atomically per topic, and logged and traced with a
+// TOPIC_CONFIG event; invalid configurations are rejected as a whole, and provider errors keep
+// the current configuration of the topic.
+func WithConfigProvider(provider ConfigProvider, refresh time.Duration) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+		if provider == nil {
+			return fmt.Errorf("nil config provider")
+		}
+		if refresh <= 0 {
+			return fmt.Errorf("invalid config refresh interval; must be positive")
+		}
+
+		gs.topicConfigs = &topicConfigs{
+			provider: provider,
+			refresh:  refresh,
+			applied:  make(map[string]*TopicRuntimeConfig),
+		}
+		return nil
+	}
+}
+
+// topicConfigs holds the runtime configuration of the topics. A nil store configures nothing.
+type topicConfigs struct {
+	provider ConfigProvider
+	refresh  time.Duration
+
+	// the configurations applied to the topics; accessed from the event loop
+	applied map[string]*TopicRuntimeConfig
+}
+
+// meshDegree returns the mesh degree override of a topic, if any.
+func (tc *topicConfigs) meshDegree(topic string) (d, dlo, dhi int, ok bool) {
+	if tc == nil {
+		return 0, 0, 0, false
+	}
+	cfg, ok := tc.applied[topic]
+	if !ok || cfg.D == 0 {
+		return 0, 0, 0, false
+	}
+	return cfg.D, cfg.Dlo, cfg.Dhi, true
+}
+
+// floodPublishTopic returns whether we flood publish the messages we publish in a topic.
+func (gs *GossipSubRouter) floodPublishTopic(topic string) bool {
+	if tc := gs.topicConfigs; tc != nil {
+		if cfg, ok := tc.applied[topic]; ok && cfg.FloodPublish != nil {
+			return *cfg.FloodPublish
+		}
+	}
+	return gs.floodPublish
+}
+
+// refreshTopicConfigs refreshes the runtime configuration of the topics we have joined, until
+// pubsub is shut down.
+func (gs *GossipSubRouter) refreshTopicConfigs() {
+	tc := gs.topicConfigs
+
+	var updates <-chan string
+	if n, ok := tc.provider.(ConfigNotifier); ok {
+		updates = n.ConfigUpdates()
+	}
+
+	ticker := time.NewTicker(tc.refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, topic := range gs.joinedTopics() {
+				gs.refreshTopicConfig(topic)
+			}
+
+		case topic, ok := <-updates:
+			if !ok {
+				updates = nil
+				continue
+			}
+			gs.refreshTopicConfig(topic)
+
+		case <-gs.p.ctx.Done():
+			return
+		}
+	}
+}
+
+// joinedTopics returns the topics we have joined.
+func (gs *GossipSubRouter) joinedTopics() []string {
+	result := make(chan []string, 1)
+	select {
+	case gs.p.eval <- func() {
+		topics := make([]string, 0, len(gs.p.myTopics))
+		for topic := range gs.p.myTopics {
+			topics = append(topics, topic)
+		}
+		result <- topics
+	}:
+		return <-result
+	case <-gs.p.ctx.Done():
+		return nil
+	}
+}
+
+// refreshTopicConfig asks the provider for the configuration of a topic, and applies it if it has
+// changed. The provider is called outside the event loop, as it may be slow.
+func (gs *GossipSubRouter) refreshTopicConfig(topic string) {
+	cfg, err := gs.topicConfigs.provider.TopicConfig(topic)
+	if err != nil {
+		gs.p.logger.Warnw("error getting the topic config; keeping the current config", "topic", topic, "err", err)
+		return
+	}
+	if cfg != nil {
+		cfg = cfg.clone()
+	}
+
+	select {
+	case gs.p.eval <- func() { gs.applyTopicConfig(topic, cfg) }:
+	case <-gs.p.ctx.Done():
+	}
+}
+
+// applyTopicConfig applies the configuration of a topic, if it has changed and is valid.
+func (gs *GossipSubRouter) applyTopicConfig(topic string, cfg *TopicRuntimeConfig) {
+	tc := gs.topicConfigs
+
+	old := tc.applied[topic]
+	if reflect.DeepEqual(old, cfg) {
+		return
+	}
+
+	if cfg == nil {
+		gs.p.logger.Infow("removing the topic config", "topic", topic)
+		delete(tc.applied, topic)
+		gs.tracer.TopicConfig(topic, &TopicRuntimeConfig{}, false)
+		return
+	}
+
+	if err := cfg.validate(); err != nil {
+		gs.p.logger.Warnw("rejecting invalid topic config", "topic", topic, "err", err)
+		return
+	}
+	setScore := cfg.ScoreParams != nil && (old == nil || !reflect.DeepEqual(old.ScoreParams, cfg.ScoreParams))
+	if setScore && gs.score == nil {
+		gs.p.logger.Warnw("rejecting topic config with score parameters; peer scoring is not enabled", "topic", topic)
+		return
+	}
+
+	if setScore {
+		// configured parameters take precedence over the topic score parameters resolver
+		delete(gs.resolvedTopics, topic)
+		gs.score.SetTopicScoreParams(topic, cfg.ScoreParams)
+	}
+	tc.applied[topic] = cfg
+
+	gs.p.logger.Infow("applied the topic config", "topic", topic, "scoreParams", setScore, "D", cfg.D, "Dlo", cfg.Dlo, "Dhi", cfg.Dhi, "floodPublish", cfg.FloodPublish)
+	gs.tracer.TopicConfig(topic, cfg, setScore)
+}
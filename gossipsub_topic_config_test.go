@@ -0,0 +1,217 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+type memConfigProvider struct {
+	mx      sync.Mutex
+	configs map[string]*TopicRuntimeConfig
+	err     error
+}
+
+func (mp *memConfigProvider) TopicConfig(topic string) (*TopicRuntimeConfig, error) {
+	mp.mx.Lock()
+	defer mp.mx.Unlock()
+	return mp.configs[topic], mp.err
+}
+
+func (mp *memConfigProvider) set(topic string, cfg *TopicRuntimeConfig, err error) {
+	mp.mx.Lock()
+	defer mp.mx.Unlock()
+	mp.configs[topic] = cfg
+	mp.err = err
+}
+
+type topicConfigTracer struct {
+	mx      sync.Mutex
+	configs []*pb.TraceEvent_TopicConfig
+}
+
+func (t *topicConfigTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_TOPIC_CONFIG {
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.configs = append(t.configs, evt.GetTopicConfig())
+}
+
+func (t *topicConfigTracer) get() []*pb.TraceEvent_TopicConfig {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return append([]*pb.TraceEvent_TopicConfig(nil), t.configs...)
+}
+
+func TestConfigProvider(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	params := DefaultGossipSubParams()
+	params.D = 6
+	params.Dlo = 4
+	params.Dhi = 10
+	params.HeartbeatInterval = 100 * time.Millisecond
+
+	provider := &memConfigProvider{configs: make(map[string]*TopicRuntimeConfig)}
+	tracer := &topicConfigTracer{}
+
+	hosts := getNetHosts(t, ctx, 10)
+	psubs := []*PubSub{getGossipsub(ctx, hosts[0],
+		WithGossipSubParams(params),
+		WithFloodPublish(true),
+		WithEventTracer(tracer),
+		WithConfigProvider(provider, 200*time.Millisecond))}
+	psubs = append(psubs, getGossipsubs(ctx, hosts[1:], WithGossipSubParams(params))...)
+
+	var topics []*Topic
+	for _, ps := range psubs {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := topic.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+
+	for _, h := range hosts[1:] {
+		connect(t, hosts[0], h)
+	}
+	time.Sleep(time.Second)
+
+	meshSize := func() int {
+		result := make(chan int, 1)
+		psubs[0].eval <- func() {
+			result <- len(psubs[0].rt.(*GossipSubRouter).mesh["foobar"])
+		}
+		return <-result
+	}
+	publish := func() PublishResult {
+		t.Helper()
+		var res PublishResult
+		if err := topics[0].Publish(ctx, []byte("hello"), WithPublishResult(&res, false)); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	if n := meshSize(); n < params.D {
+		t.Fatalf("expected at least %d mesh peers, got %d", params.D, n)
+	}
+	if res := publish(); res.Recipients != 9 {
+		t.Fatalf("expected the message to be flood published to 9 peers, got %d", res.Recipients)
+	}
+
+	// an invalid config is rejected as a whole
+	floodPublish := false
+	provider.set("foobar", &TopicRuntimeConfig{D: 2, Dlo: 3, Dhi: 3, FloodPublish: &floodPublish}, nil)
+	time.Sleep(500 * time.Millisecond)
+	if res := publish(); res.Recipients != 9 {
+		t.Fatalf("expected the invalid config to be rejected, got %d recipients", res.Recipients)
+	}
+	if n := len(tracer.get()); n != 0 {
+		t.Fatalf("expected no applied config, got %d", n)
+	}
+
+	// the valid config is applied at the next refresh
+	provider.set("foobar", &TopicRuntimeConfig{D: 2, Dlo: 1, Dhi: 3, FloodPublish: &floodPublish}, nil)
+	time.Sleep(500 * time.Millisecond)
+
+	n := meshSize()
+	if n < 1 || n > 3 {
+		t.Fatalf("expected between 1 and 3 mesh peers with the D override, got %d", n)
+	}
+	if res := publish(); res.Recipients != n {
+		t.Fatalf("expected the message to be published to the %d mesh peers, got %d", n, res.Recipients)
+	}
+
+	configs := tracer.get()
+	if len(configs) != 1 {
+		t.Fatalf("expected 1 applied config, got %d", len(configs))
+	}
+	if cfg := configs[0]; cfg.GetTopic() != "foobar" || cfg.GetD() != 2 || cfg.GetFloodPublish() || cfg.FloodPublish == nil {
+		t.Fatalf("unexpected traced config %v", cfg)
+	}
+
+	// provider errors keep the current config
+	provider.set("foobar", nil, errors.New("config service unavailable"))
+	time.Sleep(500 * time.Millisecond)
+	if res := publish(); res.Recipients == 9 {
+		t.Fatal("expected the config to be kept on provider errors")
+	}
+
+	// and removing the config restores the router configuration
+	provider.set("foobar", nil, nil)
+	time.Sleep(500 * time.Millisecond)
+	if res := publish(); res.Recipients != 9 {
+		t.Fatalf("expected the message to be flood published to 9 peers again, got %d", res.Recipients)
+	}
+}
+
+type pushConfigProvider struct {
+	memConfigProvider
+	updates chan string
+}
+
+func (pp *pushConfigProvider) ConfigUpdates() <-chan string {
+	return pp.updates
+}
+
+// Test that the provider can push configuration changes, without waiting for the next refresh.
+func TestConfigProviderPush(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	provider := &pushConfigProvider{
+		memConfigProvider: memConfigProvider{configs: make(map[string]*TopicRuntimeConfig)},
+		updates:           make(chan string),
+	}
+	tracer := &topicConfigTracer{}
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getGossipsub(ctx, hosts[0], WithEventTracer(tracer), WithConfigProvider(provider, time.Hour))
+	if _, err := ps.Join("foobar"); err != nil {
+		t.Fatal(err)
+	}
+
+	floodPublish := false
+	provider.set("foobar", &TopicRuntimeConfig{FloodPublish: &floodPublish}, nil)
+	provider.updates <- "foobar"
+	time.Sleep(100 * time.Millisecond)
+
+	configs := tracer.get()
+	if len(configs) != 1 || configs[0].GetTopic() != "foobar" || configs[0].FloodPublish == nil {
+		t.Fatalf("expected the pushed config to be applied, got %v", configs)
+	}
+
+	// score parameters require peer scoring
+	provider.set("foobar", &TopicRuntimeConfig{ScoreParams: &TopicScoreParams{SkipAtomicValidation: true, TopicWeight: 1}}, nil)
+	provider.updates <- "foobar"
+	time.Sleep(100 * time.Millisecond)
+	if n := len(tracer.get()); n != 1 {
+		t.Fatalf("expected the config with score parameters to be rejected, got %d applied configs", n)
+	}
+}
+
+func TestConfigProviderInvalid(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	if _, err := NewGossipSub(ctx, hosts[0], WithConfigProvider(nil, time.Second)); err == nil {
+		t.Fatal("expected a nil provider to be invalid")
+	}
+	provider := &memConfigProvider{configs: make(map[string]*TopicRuntimeConfig)}
+	if _, err := NewGossipSub(ctx, hosts[0], WithConfigProvider(provider, 0)); err == nil {
+		t.Fatal("expected a zero refresh interval to be invalid")
+	}
+}
@@ -0,0 +1,242 @@
+package pubsub
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// TopicSizeMaxAge is the number of heartbeats after which a sample of the membership of a topic
+// expires, unless it is refreshed by the peer it stands for or one of its neighbours; it should
+// be well above the diameter of the topic mesh, as samples propagate by one hop per heartbeat.
+var TopicSizeMaxAge = 16
+
+// TopicSizeMaxTopics is the maximum number of topics for which we send membership samples to a
+// peer in a heartbeat, and accept samples from a peer in an RPC.
+var TopicSizeMaxTopics = 32
+
+// WithTopicSizeEstimation is a gossipsub router option that estimates the number of peers
+// subscribed to each topic we have joined across the whole network, as opposed to the peers we
+// are connected to; see Topic.EstimatedSize.
+// Every heartbeat, we send our mesh peers a sketch of the membership of the topic, made of the
+// hashes of at most samples member peer IDs, the smallest ones we know of, in a control message
+// extension that older peers ignore; the sketch takes about 12*samples bytes per topic. Sizes up
+// to samples peers are counted exactly, and larger sizes are estimated from the hashes, with a
+// relative error of about 1/sqrt(samples). Samples of peers that leave the topic expire after
+// TopicSizeMaxAge heartbeats.
+func WithTopicSizeEstimation(samples int) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+		if samples < 2 {
+			return fmt.Errorf("invalid number of topic size samples; must be at least 2")
+		}
+		gs.topicSizeSamples = samples
+		gs.topicSizes = make(map[string]*topicSizeSketch)
+		return nil
+	}
+}
+
+// EstimatedSize returns the estimated number of peers in the topic network-wide, including
+// ourselves, and the time of the estimate. It returns a zero time
+// if there is no estimate yet, or if the estimation is not enabled with WithTopicSizeEstimation.
+func (t *Topic) EstimatedSize() (int, time.Time) {
+	type result struct {
+		size    int
+		updated time.Time
+	}
+
+	out := make(chan result, 1)
+	select {
+	case t.p.eval <- func() {
+		gs, ok := t.p.rt.(*GossipSubRouter)
+		if !ok || gs.topicSizes == nil {
+			out <- result{}
+			return
+		}
+		s, ok := gs.topicSizes[t.topic]
+		if !ok {
+			out <- result{}
+			return
+		}
+		out <- result{s.estimate, s.updated}
+	}:
+		res := <-out
+		return res.size, res.updated
+	case <-t.p.ctx.Done():
+		return 0, time.Time{}
+	}
+}
+
+// topicSizeSketch is a k minimum values sketch of the membership of a topic: the smallest hashes
+// of the peer IDs of the members we know of, with their age in heartbeats.
+type topicSizeSketch struct {
+	samples  map[uint64]uint32
+	estimate int
+	updated  time.Time
+}
+
+// topicSizeHash is the hash of a peer ID in the sketches; all peers must use the same hash.
+func topicSizeHash(p peer.ID) uint64 {
+	h := sha256.Sum256([]byte(p))
+	return binary.BigEndian.Uint64(h[:8])
+}
+
+// add adds a sample to the sketch, keeping the youngest age of the samples for the same peer.
+func (s *topicSizeSketch) add(hash uint64, age uint32) {
+	if old, ok := s.samples[hash]; ok && old <= age {
+		return
+	}
+	s.samples[hash] = age
+}
+
+// hashes returns the hashes of the sketch in ascending order.
+func (s *topicSizeSketch) hashes() []uint64 {
+	hashes := make([]uint64, 0, len(s.samples))
+	for h := range s.samples {
+		hashes = append(hashes, h)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	return hashes
+}
+
+// truncate drops all but the k smallest hashes, returning the remaining hashes in ascending order.
+func (s *topicSizeSketch) truncate(k int) []uint64 {
+	hashes := s.hashes()
+	if len(hashes) <= k {
+		return hashes
+	}
+	for _, h := range hashes[k:] {
+		delete(s.samples, h)
+	}
+	return hashes[:k]
+}
+
+// estimateTopicSize estimates the number of distinct peers from the k smallest of their hashes,
+// given in ascending order; if there are fewer than k hashes, they are all the peers.
+func estimateTopicSize(hashes []uint64, k int) int {
+	if len(hashes) < k {
+		return len(hashes)
+	}
+	// the k-th smallest of n uniform hashes is expected at about k/n of the hash space
+	frac := (float64(hashes[k-1]) + 1) / math.Exp2(64)
+	return int(math.Round(float64(k-1) / frac))
+}
+
+// updateTopicSizes ages the sketches of the topics we have joined, refreshes them with ourselves
+// and the topic peers we know of, and updates the estimates.
+// Only called from the heartbeat.
+func (gs *GossipSubRouter) updateTopicSizes() {
+	if gs.topicSizes == nil {
+		return
+	}
+
+	now := time.Now()
+	for topic := range gs.mesh {
+		s, ok := gs.topicSizes[topic]
+		if !ok {
+			s = &topicSizeSketch{samples: make(map[uint64]uint32)}
+			gs.topicSizes[topic] = s
+		}
+
+		for h, age := range s.samples {
+			age++
+			if int(age) > TopicSizeMaxAge {
+				delete(s.samples, h)
+				continue
+			}
+			s.samples[h] = age
+		}
+
+		s.add(topicSizeHash(gs.p.tr.ID()), 0)
+		for p := range gs.p.topics[topic] {
+			s.add(topicSizeHash(p), 1)
+		}
+
+		s.estimate = estimateTopicSize(s.truncate(gs.topicSizeSamples), gs.topicSizeSamples)
+		s.updated = now
+	}
+
+	for topic := range gs.topicSizes {
+		if _, ok := gs.mesh[topic]; !ok {
+			delete(gs.topicSizes, topic)
+		}
+	}
+}
+
+// sendTopicSizes sends the sketches of the topics we have joined to our mesh peers, piggybacking
+// any pending gossip and control messages.
+// Only called from the heartbeat.
+func (gs *GossipSubRouter) sendTopicSizes() {
+	if gs.topicSizes == nil {
+		return
+	}
+
+	tosend := make(map[peer.ID][]*pb.ControlTopicSize)
+	for topic, s := range gs.topicSizes {
+		topic := topic
+		ctl := &pb.ControlTopicSize{TopicID: &topic}
+		for _, h := range s.hashes() {
+			hash, age := h, s.samples[h]
+			ctl.Samples = append(ctl.Samples, &pb.TopicSizeSample{Hash: &hash, Age: &age})
+		}
+
+		for p := range gs.mesh[topic] {
+			if len(tosend[p]) < TopicSizeMaxTopics {
+				tosend[p] = append(tosend[p], ctl)
+			}
+		}
+	}
+
+	for p, sizes := range tosend {
+		out := rpcWithControl(nil, nil, nil, nil, nil)
+		out.Control.TopicSize = sizes
+		gs.sendRPC(p, out)
+	}
+}
+
+// handleTopicSize merges the sketches received from a peer into ours.
+func (gs *GossipSubRouter) handleTopicSize(p peer.ID, ctl *pb.ControlMessage) {
+	if gs.topicSizes == nil || len(ctl.GetTopicSize()) == 0 {
+		return
+	}
+
+	// like gossip, we ignore sketches from peers whose score is below the gossip threshold
+	if gs.score.Score(p) < gs.gossipThreshold {
+		gs.p.logger.Debugw("TOPICSIZE: ignoring peer with score below threshold", "peer", p)
+		return
+	}
+
+	for i, ts := range ctl.GetTopicSize() {
+		if i >= TopicSizeMaxTopics {
+			gs.p.logger.Debugw("TOPICSIZE: ignoring excessive topics from peer", "peer", p)
+			return
+		}
+
+		s, ok := gs.topicSizes[ts.GetTopicID()]
+		if !ok {
+			continue
+		}
+
+		samples := ts.GetSamples()
+		if len(samples) > gs.topicSizeSamples {
+			samples = samples[:gs.topicSizeSamples]
+		}
+		for _, sample := range samples {
+			if int(sample.GetAge()) > TopicSizeMaxAge {
+				continue
+			}
+			s.add(sample.GetHash(), sample.GetAge())
+		}
+		s.truncate(gs.topicSizeSamples)
+	}
+}
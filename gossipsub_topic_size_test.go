@@ -0,0 +1,97 @@
+package pubsub
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestTopicSizeEstimation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "foobar"
+	hosts := getNetHosts(t, ctx, 20)
+
+	params := DefaultGossipSubParams()
+	params.HeartbeatInterval = 200 * time.Millisecond
+
+	// the first peer doesn't support the extension, and must still be counted by the others
+	psubs := []*PubSub{getGossipsub(ctx, hosts[0], WithGossipSubParams(params))}
+	psubs = append(psubs, getGossipsubs(ctx, hosts[1:], WithGossipSubParams(params), WithTopicSizeEstimation(32))...)
+
+	topics := getTopics(psubs, topic)
+	var subs []*Subscription
+	for _, tp := range topics {
+		sub, err := tp.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+
+	denseConnect(t, hosts)
+
+	waitSize := func(tps []*Topic, expected int) {
+		t.Helper()
+		deadline := time.Now().Add(15 * time.Second)
+		for {
+			done := true
+			for i, tp := range tps {
+				size, updated := tp.EstimatedSize()
+				if size != expected || updated.IsZero() {
+					if time.Now().After(deadline) {
+						t.Fatalf("peer %d: expected an estimated size of %d, got %d", i, expected, size)
+					}
+					done = false
+					break
+				}
+			}
+			if done {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	waitSize(topics[1:], 20)
+
+	if size, updated := topics[0].EstimatedSize(); size != 0 || !updated.IsZero() {
+		t.Fatalf("expected no estimate without the extension, got %d at %s", size, updated)
+	}
+
+	// the samples of the peers that leave expire
+	for i := 15; i < 20; i++ {
+		subs[i].Cancel()
+		if err := topics[i].Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitSize(topics[1:15], 15)
+}
+
+func TestEstimateTopicSize(t *testing.T) {
+	const n = 10000
+
+	rng := rand.New(rand.NewSource(1))
+	hashes := make([]uint64, n)
+	for i := range hashes {
+		hashes[i] = rng.Uint64()
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+
+	// exact below the number of samples
+	if size := estimateTopicSize(hashes[:10], 32); size != 10 {
+		t.Fatalf("expected an exact size of 10, got %d", size)
+	}
+
+	for _, k := range []int{64, 256, 1024} {
+		size := estimateTopicSize(hashes[:k], k)
+		if err := math.Abs(float64(size-n)) / n; err > 3/math.Sqrt(float64(k)) {
+			t.Fatalf("expected an estimated size close to %d with %d samples, got %d", n, k, size)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package pubsub
+
+import (
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// WithTransientMeshPeers is a gossipsub router option that lets peers we are only connected to
+// over transient connections, such as limited relayed connections, into our meshes.
+// By default, such peers are not grafted, their GRAFTs are refused, and they are not included in
+// PX, as the streams over transient connections are unreliable and size-limited; they become
+// eligible as soon as a direct connection to them appears. Allowing them is useful in small
+// networks, where they may be the only peers available.
+// Connections are only classified with transports implementing ConnTransport, such as the default
+// libp2p transport.
+func WithTransientMeshPeers() Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+
+		gs.allowTransient = true
+
+		return nil
+	}
+}
+
+// connRouter is implemented by routers that track the connections to their peers.
+type connRouter interface {
+	// connChanged is invoked when a new connection to a peer appears.
+	connChanged(p peer.ID)
+}
+
+var _ connRouter = (*GossipSubRouter)(nil)
+
+// connChanged re-classifies the connections to a peer when a new connection appears.
+func (gs *GossipSubRouter) connChanged(p peer.ID) {
+	if _, ok := gs.peers[p]; !ok {
+		return
+	}
+
+	_, wasTransient := gs.transient[p]
+	gs.classifyPeer(p)
+	if _, transient := gs.transient[p]; wasTransient && !transient {
+		gs.p.logger.Debugw("peer is now directly connected; eligible for the mesh", "peer", p)
+	}
+}
+
+// classifyPeer records whether we are only connected to a peer over transient connections.
+func (gs *GossipSubRouter) classifyPeer(p peer.ID) {
+	ct, ok := gs.p.tr.(ConnTransport)
+	if !ok {
+		return
+	}
+
+	conns := ct.Conns(p)
+	transient := len(conns) > 0
+	for _, c := range conns {
+		if !c.Transient {
+			transient = false
+			break
+		}
+	}
+
+	if transient {
+		gs.transient[p] = struct{}{}
+	} else {
+		delete(gs.transient, p)
+	}
+}
+
+// transientPeer returns true if a peer is only connected over transient connections, and is
+// thus excluded from our meshes and from PX.
+func (gs *GossipSubRouter) transientPeer(p peer.ID) bool {
+	if gs.allowTransient {
+		return false
+	}
+	_, transient := gs.transient[p]
+	return transient
+}
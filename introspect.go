@@ -0,0 +1,220 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// debugState is the router state served by the debug handler.
+type debugState struct {
+	Time time.Time `json:"time"`
+	// Topics are the topics we have joined or subscribed to.
+	Topics map[string]*debugTopic `json:"topics"`
+	// Fanout are the fanout peers of the topics we publish to without having joined them.
+	Fanout map[string][]debugPeer `json:"fanout,omitempty"`
+	// Backoff maps topics to the peers we are backing off from, with the expiration of the backoff.
+	Backoff map[string]map[string]time.Time `json:"backoff,omitempty"`
+	// Gater is the state of the peer gater, if enabled.
+	Gater *debugGater `json:"gater,omitempty"`
+	// SeenMessages is the number of message IDs in the seen messages cache, or -1 if unknown.
+	SeenMessages int `json:"seenMessages"`
+	// OutboundQueues are the outbound queues of the connected peers.
+	OutboundQueues map[string]debugQueue `json:"outboundQueues"`
+	// Heartbeat is the timing of the router heartbeat, if the router has one.
+	Heartbeat *debugHeartbeat `json:"heartbeat,omitempty"`
+}
+
+type debugTopic struct {
+	Subscriptions int         `json:"subscriptions"`
+	Peers         []peer.ID   `json:"peers"`
+	Mesh          []debugPeer `json:"mesh,omitempty"`
+}
+
+type debugPeer struct {
+	ID    peer.ID `json:"id"`
+	Score float64 `json:"score"`
+}
+
+type debugGater struct {
+	Validate     float64                       `json:"validate"`
+	Throttle     float64                       `json:"throttle"`
+	LastThrottle time.Time                     `json:"lastThrottle"`
+	Peers        map[string]debugGaterCounters `json:"peers"`
+}
+
+type debugGaterCounters struct {
+	Deliver   float64 `json:"deliver"`
+	Duplicate float64 `json:"duplicate"`
+	Ignore    float64 `json:"ignore"`
+	Reject    float64 `json:"reject"`
+}
+
+type debugQueue struct {
+	Length    int    `json:"length"`
+	Capacity  int    `json:"capacity"`
+	HighWater int    `json:"highWater"`
+	Enqueued  uint64 `json:"enqueued"`
+	Dequeued  uint64 `json:"dequeued"`
+}
+
+type debugHeartbeat struct {
+	Last     time.Time `json:"last"`
+	Seq      uint64    `json:"seq"`
+	Interval string    `json:"interval"`
+	// Recent are the durations of the recent heartbeats, oldest first.
+	Recent []string `json:"recent"`
+}
+
+// DebugHandler returns an HTTP handler serving a JSON snapshot of the router state, for debugging
+// live nodes: the joined topics with their peers, the mesh and fanout peers with their scores, the
+// backoffs, the peer gater state, the size of the seen messages cache, the outbound queue occupancy
+// and the recent heartbeat timing. The snapshot is taken in the event loop.
+// The handler discloses the peers and scores of the node, so it should not be exposed publicly.
+func (p *PubSub) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		out := make(chan *debugState, 1)
+		select {
+		case p.eval <- func() { out <- p.debugState() }:
+		case <-r.Context().Done():
+			return
+		case <-p.ctx.Done():
+			http.Error(w, "pubsub is closed", http.StatusServiceUnavailable)
+			return
+		}
+		st := <-out
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(st); err != nil {
+			p.logger.Debugf("error writing debug state: %s", err)
+		}
+	})
+}
+
+// debugState snapshots the router state; it must be called from the event loop.
+func (p *PubSub) debugState() *debugState {
+	st := &debugState{
+		Time:           time.Now(),
+		Topics:         make(map[string]*debugTopic),
+		SeenMessages:   -1,
+		OutboundQueues: make(map[string]debugQueue, len(p.peers)),
+	}
+
+	topic := func(name string) *debugTopic {
+		t, ok := st.Topics[name]
+		if !ok {
+			t = &debugTopic{Peers: []peer.ID{}}
+			for pid := range p.topics[name] {
+				t.Peers = append(t.Peers, pid)
+			}
+			st.Topics[name] = t
+		}
+		return t
+	}
+	for name := range p.myTopics {
+		topic(name)
+	}
+	for name, subs := range p.mySubs {
+		topic(name).Subscriptions = len(subs)
+	}
+
+	if c, ok := p.seenMessages.(interface{ Len() int }); ok {
+		st.SeenMessages = c.Len()
+	}
+
+	for pid, q := range p.peers {
+		dq := debugQueue{Length: len(q), Capacity: cap(q)}
+		if qs, ok := p.peerQueueStats(pid, st.Time); ok {
+			dq.HighWater = qs.HighWater
+			dq.Enqueued = qs.Enqueued
+			dq.Dequeued = qs.Dequeued
+		}
+		st.OutboundQueues[pid.String()] = dq
+	}
+
+	if gs, ok := p.rt.(*GossipSubRouter); ok {
+		gs.debugState(st, topic)
+	}
+
+	return st
+}
+
+func (gs *GossipSubRouter) debugState(st *debugState, topic func(string) *debugTopic) {
+	peers := func(set map[peer.ID]struct{}) []debugPeer {
+		out := make([]debugPeer, 0, len(set))
+		for pid := range set {
+			out = append(out, debugPeer{ID: pid, Score: gs.score.Score(pid)})
+		}
+		return out
+	}
+
+	for name, mesh := range gs.mesh {
+		topic(name).Mesh = peers(mesh)
+	}
+	if len(gs.fanout) > 0 {
+		st.Fanout = make(map[string][]debugPeer, len(gs.fanout))
+		for name, fanout := range gs.fanout {
+			st.Fanout[name] = peers(fanout)
+		}
+	}
+
+	if len(gs.backoff) > 0 {
+		st.Backoff = make(map[string]map[string]time.Time, len(gs.backoff))
+		for name, backoff := range gs.backoff {
+			expire := make(map[string]time.Time, len(backoff))
+			for pid, t := range backoff {
+				expire[pid.String()] = t
+			}
+			st.Backoff[name] = expire
+		}
+	}
+
+	st.Gater = gs.gate.debugState()
+
+	gs.hbMx.Lock()
+	hb := &debugHeartbeat{
+		Last:     gs.hbLast,
+		Seq:      gs.hbSeq,
+		Interval: gs.params.HeartbeatInterval.String(),
+		Recent:   make([]string, 0, len(gs.hbRecent)),
+	}
+	for _, dt := range gs.hbRecent {
+		hb.Recent = append(hb.Recent, dt.String())
+	}
+	gs.hbMx.Unlock()
+	st.Heartbeat = hb
+}
+
+func (pg *peerGater) debugState() *debugGater {
+	if pg == nil {
+		return nil
+	}
+
+	pg.Lock()
+	defer pg.Unlock()
+
+	out := &debugGater{
+		Validate:     pg.validate,
+		Throttle:     pg.throttle,
+		LastThrottle: pg.lastThrottle,
+		Peers:        make(map[string]debugGaterCounters, len(pg.peerStats)),
+	}
+	for pid, s := range pg.peerStats {
+		out.Peers[pid.String()] = debugGaterCounters{
+			Deliver:   s.deliver,
+			Duplicate: s.duplicate,
+			Ignore:    s.ignore,
+			Reject:    s.reject,
+		}
+	}
+	return out
+}
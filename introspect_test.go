@@ -0,0 +1,158 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDebugHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := getGossipsubs(ctx, hosts, WithPeerGater(DefaultPeerGaterParams()))
+
+	for _, ps := range psubs {
+		if _, err := ps.Subscribe("foobar"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the remote peer is in the fanout of a topic we publish to
+	if _, err := psubs[1].Subscribe("fanout"); err != nil {
+		t.Fatal(err)
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(2 * time.Second)
+
+	if err := psubs[1].Publish("foobar", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := psubs[0].Publish("fanout", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	srv := httptest.NewServer(psubs[0].DebugHandler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	var st map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := hosts[1].ID().String()
+	object := func(v interface{}, name string) map[string]interface{} {
+		t.Helper()
+		o, ok := v.(map[string]interface{})
+		if !ok {
+			t.Fatalf("expected %s to be an object, got %v", name, v)
+		}
+		return o
+	}
+	list := func(v interface{}, name string) []interface{} {
+		t.Helper()
+		l, ok := v.([]interface{})
+		if !ok {
+			t.Fatalf("expected %s to be a list, got %v", name, v)
+		}
+		return l
+	}
+
+	// the topic, with the remote peer in the mesh
+	topic := object(object(st["topics"], "topics")["foobar"], "topics.foobar")
+	if n := topic["subscriptions"]; n != 1.0 {
+		t.Fatalf("expected 1 subscription, got %v", n)
+	}
+	if peers := list(topic["peers"], "topics.foobar.peers"); len(peers) != 1 || peers[0] != remote {
+		t.Fatalf("expected the remote peer in the topic, got %v", peers)
+	}
+	mesh := list(topic["mesh"], "topics.foobar.mesh")
+	if len(mesh) != 1 {
+		t.Fatalf("expected one mesh peer, got %v", mesh)
+	}
+	mp := object(mesh[0], "topics.foobar.mesh[0]")
+	if mp["id"] != remote {
+		t.Fatalf("expected the remote peer in the mesh, got %v", mp["id"])
+	}
+	if _, ok := mp["score"].(float64); !ok {
+		t.Fatalf("expected a score, got %v", mp["score"])
+	}
+
+	fanout := list(object(st["fanout"], "fanout")["fanout"], "fanout.fanout")
+	if len(fanout) != 1 || object(fanout[0], "fanout.fanout[0]")["id"] != remote {
+		t.Fatalf("expected the remote peer in the fanout, got %v", fanout)
+	}
+
+	gater := object(st["gater"], "gater")
+	for _, key := range []string{"validate", "throttle"} {
+		if _, ok := gater[key].(float64); !ok {
+			t.Fatalf("expected gater.%s to be a number, got %v", key, gater[key])
+		}
+	}
+	if _, err := time.Parse(time.RFC3339Nano, gater["lastThrottle"].(string)); err != nil {
+		t.Fatal(err)
+	}
+	counters := object(object(gater["peers"], "gater.peers")[remote], "gater.peers.remote")
+	if counters["deliver"] != 1.0 {
+		t.Fatalf("expected one delivery from the remote peer, got %v", counters["deliver"])
+	}
+
+	if n, ok := st["seenMessages"].(float64); !ok || n != 2 {
+		t.Fatalf("expected 2 seen messages, got %v", st["seenMessages"])
+	}
+
+	queue := object(object(st["outboundQueues"], "outboundQueues")[remote], "outboundQueues.remote")
+	if queue["capacity"] != float64(psubs[0].peerOutboundQueueSize) {
+		t.Fatalf("unexpected queue capacity %v", queue["capacity"])
+	}
+	if _, ok := queue["length"].(float64); !ok {
+		t.Fatalf("expected a queue length, got %v", queue["length"])
+	}
+
+	hb := object(st["heartbeat"], "heartbeat")
+	if seq, ok := hb["seq"].(float64); !ok || seq < 1 {
+		t.Fatalf("expected a heartbeat sequence number, got %v", hb["seq"])
+	}
+	if hb["interval"] != GossipSubHeartbeatInterval.String() {
+		t.Fatalf("unexpected heartbeat interval %v", hb["interval"])
+	}
+	recent := list(hb["recent"], "heartbeat.recent")
+	if len(recent) == 0 || len(recent) > recentHeartbeats {
+		t.Fatalf("unexpected number of recent heartbeats %d", len(recent))
+	}
+	if _, err := time.ParseDuration(recent[0].(string)); err != nil {
+		t.Fatal(err)
+	}
+
+	// no backoffs yet
+	if _, ok := st["backoff"]; ok {
+		t.Fatalf("expected no backoffs, got %v", st["backoff"])
+	}
+
+	// only GET is allowed
+	resp, err = http.Post(srv.URL, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+}
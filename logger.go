@@ -0,0 +1,104 @@
+package pubsub
+
+import (
+	"fmt"
+
+	logging "github.com/ipfs/go-log/v2"
+	"go.uber.org/zap"
+)
+
+// Logger is a minimal leveled logger, with printf-style and structured key-value variants.
+// It is satisfied by the loggers of go-log and by zap's SugaredLogger.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+}
+
+// WithLogger is an option to use a logger specific to this pubsub instance, instead of the
+// package logger. All the messages logged by the instance, its router and their components carry
+// the host ID as the "host" field, and where applicable the "topic" and "peer" fields.
+// Fields are passed through the key-value variants of the logger, formatting the printf-style
+// messages beforehand; loggers that can attach fields themselves, like zap's SugaredLogger, get
+// them attached instead.
+// Tracers and validators constructed independently of the instance keep using the package logger.
+func WithLogger(l Logger) Option {
+	return func(p *PubSub) error {
+		if l == nil {
+			return fmt.Errorf("nil logger")
+		}
+		p.logger = l
+		return nil
+	}
+}
+
+// withFields returns a logger adding the given key-value pairs to every message.
+func withFields(l Logger, keysAndValues ...interface{}) Logger {
+	switch l := l.(type) {
+	case *logging.ZapEventLogger:
+		return l.With(keysAndValues...)
+	case *zap.SugaredLogger:
+		return l.With(keysAndValues...)
+	case *fieldLogger:
+		kv := make([]interface{}, 0, len(l.kv)+len(keysAndValues))
+		kv = append(kv, l.kv...)
+		return &fieldLogger{l: l.l, kv: append(kv, keysAndValues...)}
+	default:
+		return &fieldLogger{l: l, kv: keysAndValues}
+	}
+}
+
+// fieldLogger adds fields to the messages of a logger through its key-value variants.
+type fieldLogger struct {
+	l  Logger
+	kv []interface{}
+}
+
+var _ Logger = (*fieldLogger)(nil)
+
+func (l *fieldLogger) fields(keysAndValues []interface{}) []interface{} {
+	if len(keysAndValues) == 0 {
+		return l.kv
+	}
+	kv := make([]interface{}, 0, len(l.kv)+len(keysAndValues))
+	kv = append(kv, l.kv...)
+	return append(kv, keysAndValues...)
+}
+
+func (l *fieldLogger) Debugf(format string, args ...interface{}) {
+	l.l.Debugw(fmt.Sprintf(format, args...), l.kv...)
+}
+
+func (l *fieldLogger) Infof(format string, args ...interface{}) {
+	l.l.Infow(fmt.Sprintf(format, args...), l.kv...)
+}
+
+func (l *fieldLogger) Warnf(format string, args ...interface{}) {
+	l.l.Warnw(fmt.Sprintf(format, args...), l.kv...)
+}
+
+func (l *fieldLogger) Errorf(format string, args ...interface{}) {
+	l.l.Errorw(fmt.Sprintf(format, args...), l.kv...)
+}
+
+func (l *fieldLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.l.Debugw(msg, l.fields(keysAndValues)...)
+}
+
+func (l *fieldLogger) Infow(msg string, keysAndValues ...interface{}) {
+	l.l.Infow(msg, l.fields(keysAndValues)...)
+}
+
+func (l *fieldLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	l.l.Warnw(msg, l.fields(keysAndValues)...)
+}
+
+func (l *fieldLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.l.Errorw(msg, l.fields(keysAndValues)...)
+}
@@ -0,0 +1,156 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type logEntry struct {
+	level  string
+	msg    string
+	fields map[string]interface{}
+}
+
+// captureLogger records the messages logged through it.
+type captureLogger struct {
+	mx      sync.Mutex
+	entries []logEntry
+}
+
+func (l *captureLogger) log(level, msg string, keysAndValues []interface{}) {
+	fields := make(map[string]interface{})
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		fields[keysAndValues[i].(string)] = keysAndValues[i+1]
+	}
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.entries = append(l.entries, logEntry{level: level, msg: msg, fields: fields})
+}
+
+func (l *captureLogger) Debugf(format string, args ...interface{}) {
+	l.log("debug", fmt.Sprintf(format, args...), nil)
+}
+
+func (l *captureLogger) Infof(format string, args ...interface{}) {
+	l.log("info", fmt.Sprintf(format, args...), nil)
+}
+
+func (l *captureLogger) Warnf(format string, args ...interface{}) {
+	l.log("warn", fmt.Sprintf(format, args...), nil)
+}
+
+func (l *captureLogger) Errorf(format string, args ...interface{}) {
+	l.log("error", fmt.Sprintf(format, args...), nil)
+}
+
+func (l *captureLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.log("debug", msg, keysAndValues)
+}
+
+func (l *captureLogger) Infow(msg string, keysAndValues ...interface{}) {
+	l.log("info", msg, keysAndValues)
+}
+
+func (l *captureLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	l.log("warn", msg, keysAndValues)
+}
+
+func (l *captureLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.log("error", msg, keysAndValues)
+}
+
+// find returns the first entry with the given message and field values.
+func (l *captureLogger) find(msg string, fields map[string]interface{}) (logEntry, bool) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+next:
+	for _, e := range l.entries {
+		if e.msg != msg {
+			continue
+		}
+		for k, v := range fields {
+			if e.fields[k] != v {
+				continue next
+			}
+		}
+		return e, true
+	}
+	return logEntry{}, false
+}
+
+func TestLogger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "foobar"
+	hosts := getNetHosts(t, ctx, 2)
+	loggers := []*captureLogger{{}, {}}
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0], WithLogger(loggers[0])),
+		getGossipsub(ctx, hosts[1], WithLogger(loggers[1])),
+	}
+
+	for _, ps := range psubs {
+		if _, err := ps.Subscribe(topic); err != nil {
+			t.Fatal(err)
+		}
+	}
+	connect(t, hosts[0], hosts[1])
+
+	// the mesh links of each instance are logged with its own logger
+	for i, l := range loggers {
+		other := hosts[1-i].ID()
+		for j := 0; ; j++ {
+			if _, ok := l.find("JOIN", map[string]interface{}{"topic": topic}); ok {
+				if _, ok := l.find("GRAFT: add mesh link", map[string]interface{}{"peer": other, "topic": topic}); ok {
+					break
+				}
+				if _, ok := l.find("HEARTBEAT: Add mesh link", map[string]interface{}{"peer": other, "topic": topic}); ok {
+					break
+				}
+			}
+			if j == 50 {
+				t.Fatalf("peer %d: expected a mesh link to %s to be logged", i, other)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	// all the messages carry the host of the instance
+	for i, l := range loggers {
+		l.mx.Lock()
+		for _, e := range l.entries {
+			if e.fields["host"] != hosts[i].ID() {
+				t.Errorf("peer %d: expected host %s in %q, got %v", i, hosts[i].ID(), e.msg, e.fields["host"])
+			}
+		}
+		l.mx.Unlock()
+	}
+}
+
+func TestLoggerFields(t *testing.T) {
+	l := &captureLogger{}
+	pid := peer.ID("peer")
+
+	logger := withFields(withFields(l, "host", pid), "topic", "foobar")
+	logger.Infof("hello %s", "world")
+	logger.Warnw("bye", "peer", pid)
+
+	if _, ok := l.find("hello world", map[string]interface{}{"host": pid, "topic": "foobar"}); !ok {
+		t.Fatal("expected the fields in a formatted message")
+	}
+	if _, ok := l.find("bye", map[string]interface{}{"host": pid, "topic": "foobar", "peer": pid}); !ok {
+		t.Fatal("expected the fields in a structured message")
+	}
+
+	if _, err := NewGossipSub(context.Background(), getNetHosts(t, context.Background(), 1)[0], WithLogger(nil)); err == nil {
+		t.Fatal("expected an error for a nil logger")
+	}
+}
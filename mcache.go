@@ -25,10 +25,13 @@ func NewMessageCache(gossip, history int) *MessageCache {
 		panic(err)
 	}
 	return &MessageCache{
-		msgs:    make(map[string]*Message),
-		peertx:  make(map[string]map[peer.ID]int),
-		history: make([][]CacheEntry, history),
-		gossip:  gossip,
+		msgs:          make(map[string]*Message),
+		peertx:        make(map[string]map[peer.ID]int),
+		advertised:    make(map[string]map[peer.ID]struct{}),
+		history:       make([][]CacheEntry, history),
+		holders:       make(map[string]map[peer.ID]struct{}),
+		holderHistory: make([][]string, history),
+		gossip:        gossip,
 		msgID: func(msg *Message) string {
 			return DefaultMsgIdFn(msg.Message)
 		},
@@ -41,6 +44,17 @@ type MessageCache struct {
 	history [][]CacheEntry
 	gossip  int
 	msgID   func(*Message) string
+
+	// peers we advertised a message to in an IHAVE, by message ID; the records expire with the
+	// message
+	advertised map[string]map[peer.ID]struct{}
+
+	// peers known to have a message, by message ID, and the IDs by slot for expiration
+	holders       map[string]map[peer.ID]struct{}
+	holderHistory [][]string
+
+	// accounts the cached messages against the memory budget; nil if unlimited
+	budget *memoryBudget
 }
 
 func (mc *MessageCache) SetMsgIdFn(msgID func(*Message) string) {
@@ -54,6 +68,10 @@ type CacheEntry struct {
 
 func (mc *MessageCache) Put(msg *Message) {
 	mid := mc.msgID(msg)
+	if old, ok := mc.msgs[mid]; ok {
+		mc.budget.release(old)
+	}
+	mc.budget.acquire(msg)
 	mc.msgs[mid] = msg
 	mc.history[0] = append(mc.history[0], CacheEntry{mid: mid, topic: msg.GetTopic()})
 }
@@ -79,6 +97,48 @@ func (mc *MessageCache) GetForPeer(mid string, p peer.ID) (*Message, int, bool)
 	return m, tx[p], true
 }
 
+// AddHolder records that a peer has a message, because it advertised or sent it to us; the
+// record expires with the history window, whether the message is in the cache or not.
+func (mc *MessageCache) AddHolder(mid string, p peer.ID) {
+	holders, ok := mc.holders[mid]
+	if !ok {
+		holders = make(map[peer.ID]struct{})
+		mc.holders[mid] = holders
+		mc.holderHistory[0] = append(mc.holderHistory[0], mid)
+	}
+	holders[p] = struct{}{}
+}
+
+// IsHolder returns whether a peer is known to have a message.
+func (mc *MessageCache) IsHolder(mid string, p peer.ID) bool {
+	_, ok := mc.holders[mid][p]
+	return ok
+}
+
+// MarkAdvertised records that we advertised messages to a peer in an IHAVE.
+func (mc *MessageCache) MarkAdvertised(p peer.ID, mids []string) {
+	for _, mid := range mids {
+		if _, ok := mc.msgs[mid]; !ok {
+			continue
+		}
+		peers, ok := mc.advertised[mid]
+		if !ok {
+			peers = make(map[peer.ID]struct{})
+			mc.advertised[mid] = peers
+		}
+		peers[p] = struct{}{}
+	}
+}
+
+// Advertised returns whether we already advertised a message to a peer, or sent it the message
+// in response to an IWANT, so that it need not be advertised again.
+func (mc *MessageCache) Advertised(mid string, p peer.ID) bool {
+	if _, ok := mc.advertised[mid][p]; ok {
+		return true
+	}
+	return mc.peertx[mid][p] > 0
+}
+
 func (mc *MessageCache) GetGossipIDs(topic string) []string {
 	var mids []string
 	for _, entries := range mc.history[:mc.gossip] {
@@ -94,11 +154,63 @@ func (mc *MessageCache) GetGossipIDs(topic string) []string {
 func (mc *MessageCache) Shift() {
 	last := mc.history[len(mc.history)-1]
 	for _, entry := range last {
+		if msg, ok := mc.msgs[entry.mid]; ok {
+			mc.budget.release(msg)
+		}
 		delete(mc.msgs, entry.mid)
 		delete(mc.peertx, entry.mid)
+		delete(mc.advertised, entry.mid)
 	}
 	for i := len(mc.history) - 2; i >= 0; i-- {
 		mc.history[i+1] = mc.history[i]
 	}
 	mc.history[0] = nil
+
+	for _, mid := range mc.holderHistory[len(mc.holderHistory)-1] {
+		delete(mc.holders, mid)
+	}
+	for i := len(mc.holderHistory) - 2; i >= 0; i-- {
+		mc.holderHistory[i+1] = mc.holderHistory[i]
+	}
+	mc.holderHistory[0] = nil
+}
+
+// info returns the number of messages of a topic in each history window.
+func (mc *MessageCache) info(topic string) MCacheInfo {
+	info := MCacheInfo{Windows: make([]int, len(mc.history))}
+	for i, entries := range mc.history {
+		for _, entry := range entries {
+			if entry.topic == topic {
+				info.Windows[i]++
+				info.Total++
+			}
+		}
+	}
+	return info
+}
+
+// lookup returns whether a message is in the cache, the history window it was cached in and the
+// number of IWANT requests for it.
+func (mc *MessageCache) lookup(mid string) (bool, int, int) {
+	if _, ok := mc.msgs[mid]; !ok {
+		return false, 0, 0
+	}
+
+	window := 0
+loop:
+	for i, entries := range mc.history {
+		for _, entry := range entries {
+			if entry.mid == mid {
+				window = i
+				break loop
+			}
+		}
+	}
+
+	served := 0
+	for _, count := range mc.peertx[mid] {
+		served += count
+	}
+
+	return true, window, served
 }
@@ -0,0 +1,61 @@
+package pubsub
+
+// MCacheInfo describes the messages of a topic in the message cache of gossipsub.
+type MCacheInfo struct {
+	// Windows is the number of message IDs of the topic cached in each history window, the most
+	// recent first; the messages of the first HistoryGossip windows are advertised in gossip, and
+	// the messages of the last window are dropped at the next heartbeat.
+	Windows []int
+	// Total is the number of message IDs of the topic in the cache.
+	Total int
+}
+
+// MessageCacheInfo returns the number of messages of a topic in the message cache, by history
+// window, to check which messages we can still serve to the peers asking for them with IWANT.
+// It returns an empty MCacheInfo if the router is not gossipsub.
+func (p *PubSub) MessageCacheInfo(topic string) MCacheInfo {
+	out := make(chan MCacheInfo, 1)
+	select {
+	case p.eval <- func() {
+		gs, ok := p.rt.(*GossipSubRouter)
+		if !ok {
+			out <- MCacheInfo{}
+			return
+		}
+		out <- gs.mcache.info(topic)
+	}:
+		return <-out
+	case <-p.ctx.Done():
+		return MCacheInfo{}
+	}
+}
+
+// HasMessage returns whether a message is in the message cache of gossipsub, so that we can
+// serve it to the peers asking for it with IWANT, with the history window it was cached in, 0
+// being the most recent, and the number of IWANT requests for it we looked up in the cache,
+// including the ones refused because the peer asked for it too many times.
+// It returns false if the router is not gossipsub.
+func (p *PubSub) HasMessage(msgID string) (inMCache bool, windowAge int, servedCount int) {
+	type result struct {
+		ok     bool
+		window int
+		served int
+	}
+
+	out := make(chan result, 1)
+	select {
+	case p.eval <- func() {
+		gs, ok := p.rt.(*GossipSubRouter)
+		if !ok {
+			out <- result{}
+			return
+		}
+		ok, window, served := gs.mcache.lookup(msgID)
+		out <- result{ok, window, served}
+	}:
+		res := <-out
+		return res.ok, res.window, res.served
+	case <-p.ctx.Done():
+		return false, 0, 0
+	}
+}
@@ -0,0 +1,130 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+type iwantMissTracer struct {
+	mx     sync.Mutex
+	misses []*pb.TraceEvent_IWantMiss
+}
+
+func (t *iwantMissTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_IWANT_MISS {
+		return
+	}
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.misses = append(t.misses, evt.GetIwantMiss())
+}
+
+func (t *iwantMissTracer) get() []*pb.TraceEvent_IWantMiss {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return append([]*pb.TraceEvent_IWantMiss(nil), t.misses...)
+}
+
+func TestMessageCacheInfo(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+
+	// the history is shifted by hand
+	params := DefaultGossipSubParams()
+	params.HeartbeatInitialDelay = time.Hour
+	params.HeartbeatInterval = time.Hour
+	tracer := &iwantMissTracer{}
+	ps := getGossipsub(ctx, hosts[0], WithGossipSubParams(params), WithEventTracer(tracer))
+	shift := func() {
+		done := make(chan struct{})
+		ps.eval <- func() {
+			ps.rt.(*GossipSubRouter).mcache.Shift()
+			close(done)
+		}
+		<-done
+	}
+
+	sub, err := ps.Subscribe("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.Publish("foobar", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := sub.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mid := msg.ID
+
+	// a peer asks for the message while we have it, and after it aged out
+	iwant := make(chan struct{}, 1)
+	received := make(chan struct{}, 1)
+	newMockGS(ctx, t, hosts[1], func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+		select {
+		case <-iwant:
+			writeMsg(&pb.RPC{Control: &pb.ControlMessage{Iwant: []*pb.ControlIWant{{MessageIDs: []string{mid}}}}})
+		default:
+		}
+		if len(irpc.GetPublish()) > 0 {
+			select {
+			case received <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	shift()
+	if ok, window, served := ps.HasMessage(mid); !ok || window != 1 || served != 0 {
+		t.Fatalf("unexpected message cache entry: %t %d %d", ok, window, served)
+	}
+	info := ps.MessageCacheInfo("foobar")
+	if info.Total != 1 || len(info.Windows) != params.HistoryLength || info.Windows[1] != 1 {
+		t.Fatalf("unexpected message cache info %+v", info)
+	}
+	if info := ps.MessageCacheInfo("barfoo"); info.Total != 0 {
+		t.Fatalf("unexpected message cache info %+v", info)
+	}
+
+	iwant <- struct{}{}
+	connect(t, hosts[0], hosts[1])
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the message in response to the IWANT")
+	}
+	if ok, _, served := ps.HasMessage(mid); !ok || served != 1 {
+		t.Fatalf("expected the message to be served once, got %t %d", ok, served)
+	}
+	if misses := tracer.get(); len(misses) != 0 {
+		t.Fatalf("unexpected IWANT misses %v", misses)
+	}
+
+	// age the message out of the cache
+	for i := 1; i < params.HistoryLength; i++ {
+		shift()
+	}
+	if ok, _, _ := ps.HasMessage(mid); ok {
+		t.Fatal("expected the message to have aged out of the cache")
+	}
+	if info := ps.MessageCacheInfo("foobar"); info.Total != 0 {
+		t.Fatalf("unexpected message cache info %+v", info)
+	}
+
+	// the peer asks for it again, with the next RPC we send it
+	iwant <- struct{}{}
+	if _, err := ps.Subscribe("barfoo"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the IWANT miss", func() bool { return len(tracer.get()) == 1 })
+	miss := tracer.get()[0]
+	if string(miss.GetPeerID()) != string(hosts[1].ID()) || string(miss.GetMessageID()) != mid {
+		t.Fatalf("unexpected IWANT miss %+v", miss)
+	}
+}
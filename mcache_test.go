@@ -165,3 +165,54 @@ func makeTestMessage(n int) *pb.Message {
 		Seqno: seqno,
 	}
 }
+
+func TestMessageCacheHolders(t *testing.T) {
+	mcache := NewMessageCache(3, 5)
+
+	mcache.AddHolder("foo", "A")
+	mcache.AddHolder("foo", "B")
+	if !mcache.IsHolder("foo", "A") || !mcache.IsHolder("foo", "B") || mcache.IsHolder("foo", "C") {
+		t.Fatal("unexpected holders")
+	}
+
+	// the holders expire with the history window
+	for i := 0; i < 4; i++ {
+		mcache.Shift()
+		mcache.AddHolder("foo", "C")
+	}
+	if !mcache.IsHolder("foo", "A") {
+		t.Fatal("holders expired too early")
+	}
+	mcache.Shift()
+	if mcache.IsHolder("foo", "A") || mcache.IsHolder("foo", "C") {
+		t.Fatal("holders did not expire")
+	}
+}
+
+func TestMessageCacheAdvertised(t *testing.T) {
+	mcache := NewMessageCache(3, 5)
+
+	msgs := []*Message{{Message: makeTestMessage(0)}, {Message: makeTestMessage(1)}}
+	mids := []string{mcache.msgID(msgs[0]), mcache.msgID(msgs[1])}
+	for _, msg := range msgs {
+		mcache.Put(msg)
+	}
+
+	mcache.MarkAdvertised("A", mids[:1])
+	mcache.MarkAdvertised("A", []string{"unknown"})
+	mcache.GetForPeer(mids[1], "B")
+	if !mcache.Advertised(mids[0], "A") || !mcache.Advertised(mids[1], "B") {
+		t.Fatal("expected the messages to be advertised")
+	}
+	if mcache.Advertised(mids[1], "A") || mcache.Advertised(mids[0], "B") || mcache.Advertised("unknown", "A") {
+		t.Fatal("unexpected advertised messages")
+	}
+
+	// the records expire with the messages
+	for i := 0; i < 5; i++ {
+		mcache.Shift()
+	}
+	if mcache.Advertised(mids[0], "A") || len(mcache.advertised) != 0 {
+		t.Fatal("advertised messages did not expire")
+	}
+}
@@ -0,0 +1,210 @@
+package pubsub
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MemoryBudgetLowWater is the fraction of a memory budget that the usage must fall below, once the
+// budget is exceeded, before inbound messages are accepted again; see WithMemoryBudget.
+var MemoryBudgetLowWater = 0.8
+
+// WithMemoryBudget limits the memory held by the messages we receive, in bytes of message Size,
+// globally and in each topic; a zero budget is unlimited. The messages are accounted while queued
+// for validation and until their validation completes, in the gossipsub message cache and in the
+// subscription buffers, once in each, as the memory they hold is only released when they leave all
+// of them.
+// When the budget of a topic is exceeded, the inbound messages in the topic are dropped before
+// validation, and traced as rejected with RejectMemoryBudget, until its usage falls below
+// MemoryBudgetLowWater of the budget. When the global budget is exceeded, the same applies to the
+// topics using at least an even share of the global usage, so that the topics with a light
+// traffic are unaffected. Our own messages are not dropped, but are accounted.
+func WithMemoryBudget(global, perTopic int64) Option {
+	return func(ps *PubSub) error {
+		if global < 0 || perTopic < 0 {
+			return fmt.Errorf("memory budget must not be negative")
+		}
+		if global == 0 && perTopic == 0 {
+			return fmt.Errorf("memory budget must be limited globally or by topic")
+		}
+
+		ps.memBudget = &memoryBudget{
+			global: global,
+			topic:  perTopic,
+			topics: make(map[string]*topicMemory),
+		}
+		return nil
+	}
+}
+
+// MemoryBudgetStats is the memory usage accounted against the budget set with WithMemoryBudget.
+type MemoryBudgetStats struct {
+	// Used is the number of bytes held by the messages in all topics.
+	Used int64
+	// Blocked is true while the global budget is exceeded.
+	Blocked bool
+	// Topics are the usage of the topics that hold messages or dropped some.
+	Topics map[string]TopicMemoryStats
+}
+
+// TopicMemoryStats is the memory usage of a topic.
+type TopicMemoryStats struct {
+	// Used is the number of bytes held by the messages in the topic.
+	Used int64
+	// Blocked is true while the budget of the topic is exceeded, or while the global budget is
+	// exceeded and the topic uses at least an even share of it.
+	Blocked bool
+	// Dropped is the number of inbound messages dropped for exceeding the budget.
+	Dropped uint64
+}
+
+// MemoryBudgetStats returns the memory usage of the messages we hold. It returns false if there is
+// no memory budget.
+func (p *PubSub) MemoryBudgetStats() (MemoryBudgetStats, bool) {
+	b := p.memBudget
+	if b == nil {
+		return MemoryBudgetStats{}, false
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	stats := MemoryBudgetStats{
+		Used:    b.used,
+		Blocked: b.blocked,
+		Topics:  make(map[string]TopicMemoryStats, len(b.topics)),
+	}
+	for topic, tm := range b.topics {
+		stats.Topics[topic] = TopicMemoryStats{
+			Used:    tm.used,
+			Blocked: tm.blocked || b.offending(tm),
+			Dropped: tm.dropped,
+		}
+	}
+	return stats, true
+}
+
+// memoryBudget accounts the memory held by messages, globally and by topic; it is shared by the
+// event loop, the validation workers and the subscribers, and a nil budget accounts nothing.
+type memoryBudget struct {
+	global int64
+	topic  int64
+
+	mx      sync.Mutex
+	used    int64
+	blocked bool
+	topics  map[string]*topicMemory
+	// number of topics holding messages
+	active int
+}
+
+type topicMemory struct {
+	used    int64
+	blocked bool
+	dropped uint64
+}
+
+// checkMemoryBudget returns false if a received message exceeds the memory budget of its topic or
+// the global budget, in which case it has been dropped.
+func (p *PubSub) checkMemoryBudget(msg *Message) bool {
+	if p.memBudget.admit(msg.GetTopic()) {
+		return true
+	}
+
+	p.logger.Debugw("dropping message: memory budget exceeded", "peer", msg.ReceivedFrom, "topic", msg.GetTopic())
+	p.tracer.RejectMessage(msg, RejectMemoryBudget)
+	return false
+}
+
+// admit returns whether a message can be accepted in a topic, counting it as dropped otherwise.
+func (b *memoryBudget) admit(topic string) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	tm := b.topics[topic]
+	if tm == nil {
+		return true
+	}
+
+	if tm.blocked || b.offending(tm) {
+		tm.dropped++
+		return false
+	}
+	return true
+}
+
+// update blocks the inbound messages in a topic, or globally, when the budget is exceeded, and
+// unblocks them when the usage falls below the low-water mark.
+func (b *memoryBudget) update(tm *topicMemory) {
+	if b.topic > 0 {
+		if tm.blocked && float64(tm.used) < MemoryBudgetLowWater*float64(b.topic) {
+			tm.blocked = false
+		} else if !tm.blocked && tm.used >= b.topic {
+			tm.blocked = true
+		}
+	}
+	if b.global > 0 {
+		if b.blocked && float64(b.used) < MemoryBudgetLowWater*float64(b.global) {
+			b.blocked = false
+		} else if !b.blocked && b.used >= b.global {
+			b.blocked = true
+		}
+	}
+}
+
+// offending returns whether a topic uses at least an even share of the global usage, while the
+// global budget is exceeded.
+func (b *memoryBudget) offending(tm *topicMemory) bool {
+	return b.blocked && tm.used > 0 && tm.used*int64(b.active) >= b.used
+}
+
+// acquire accounts the memory held by a message.
+func (b *memoryBudget) acquire(msg *Message) {
+	if b == nil {
+		return
+	}
+
+	b.add(msg.GetTopic(), int64(msg.Size()))
+}
+
+// release accounts the memory released by a message, once acquired.
+func (b *memoryBudget) release(msg *Message) {
+	if b == nil {
+		return
+	}
+
+	b.add(msg.GetTopic(), -int64(msg.Size()))
+}
+
+// add accounts size bytes held, or released if negative, in a topic.
+func (b *memoryBudget) add(topic string, size int64) {
+	if b == nil || size == 0 {
+		return
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	tm := b.topics[topic]
+	if tm == nil {
+		tm = &topicMemory{}
+		b.topics[topic] = tm
+	}
+
+	if tm.used == 0 {
+		b.active++
+	}
+	tm.used += size
+	b.used += size
+	if tm.used == 0 {
+		b.active--
+	}
+	b.update(tm)
+	if tm.used == 0 && !tm.blocked && tm.dropped == 0 {
+		delete(b.topics, topic)
+	}
+}
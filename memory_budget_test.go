@@ -0,0 +1,289 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	receiver := getPubsub(ctx, hosts[0], WithMemoryBudget(48<<10, 32<<10))
+	sender := getPubsub(ctx, hosts[1])
+
+	heavy, err := receiver.Subscribe("heavy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	light, err := receiver.Subscribe("light")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, topic := range []string{"heavy", "light"} {
+		if _, err := sender.Subscribe(topic); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	// the heavy messages are not read, so they fill the budget of their topic from the 4th one
+	data := bytes.Repeat([]byte("x"), 8<<10)
+	for i := 0; i < 20; i++ {
+		if err := sender.Publish("heavy", append([]byte(fmt.Sprint(i)), data...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the other topic is unaffected
+	for i := 0; i < 10; i++ {
+		if err := sender.Publish("light", []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		expectMessage(t, ctx, light, fmt.Sprint(i))
+	}
+
+	stats, ok := receiver.MemoryBudgetStats()
+	if !ok {
+		t.Fatal("expected memory budget stats")
+	}
+	hs := stats.Topics["heavy"]
+	if !hs.Blocked || hs.Dropped != 16 || hs.Used < 32<<10 || hs.Used > 36<<10 {
+		t.Fatalf("unexpected heavy topic stats %+v", hs)
+	}
+	if ls, ok := stats.Topics["light"]; ok {
+		t.Fatalf("unexpected light topic stats %+v", ls)
+	}
+	if stats.Blocked || stats.Used != hs.Used {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// reading the heavy messages releases the budget
+	for i := 0; i < 4; i++ {
+		msg, err := heavy.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(msg.Data, []byte(fmt.Sprint(i))) {
+			t.Fatalf("unexpected message %d", i)
+		}
+	}
+	stats, _ = receiver.MemoryBudgetStats()
+	if hs := stats.Topics["heavy"]; hs.Blocked || hs.Used != 0 || hs.Dropped != 16 {
+		t.Fatalf("unexpected heavy topic stats %+v", hs)
+	}
+
+	if err := sender.Publish("heavy", []byte("again")); err != nil {
+		t.Fatal(err)
+	}
+	expectMessage(t, ctx, heavy, "again")
+
+	// the buffer of a cancelled subscription is released
+	if err := sender.Publish("heavy", []byte("unread")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the message to be buffered", func() bool {
+		stats, _ := receiver.MemoryBudgetStats()
+		return stats.Used > 0
+	})
+	heavy.Cancel()
+	waitFor(t, "the buffer to be released", func() bool {
+		stats, _ := receiver.MemoryBudgetStats()
+		return stats.Used == 0
+	})
+}
+
+func TestMemoryBudgetSubscribeHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	receiver := getPubsub(ctx, hosts[0], WithMemoryBudget(48<<10, 32<<10))
+	sender := getPubsub(ctx, hosts[1])
+
+	topic, err := receiver.Join("heavy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan struct{}, 20)
+	hcancel, err := topic.SubscribeHandler(ctx, func(msg *Message) {
+		received <- struct{}{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hcancel()
+	if _, err := sender.Subscribe("heavy"); err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	// the messages handled are released, so they never fill the budget of the topic
+	data := bytes.Repeat([]byte("x"), 8<<10)
+	for i := 0; i < 20; i++ {
+		if err := sender.Publish("heavy", append([]byte(fmt.Sprint(i)), data...)); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for message %d", i)
+		}
+	}
+
+	waitFor(t, "the handled messages to be released", func() bool {
+		stats, _ := receiver.MemoryBudgetStats()
+		return stats.Used == 0
+	})
+	stats, _ := receiver.MemoryBudgetStats()
+	if hs, ok := stats.Topics["heavy"]; ok {
+		t.Fatalf("unexpected heavy topic stats %+v", hs)
+	}
+}
+
+func TestMemoryBudgetAsyncValidation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	receiver := getPubsub(ctx, hosts[0], WithMemoryBudget(0, 32<<10))
+	sender := getPubsub(ctx, hosts[1])
+
+	// the validator holds the messages until released
+	release := make(chan struct{})
+	err := receiver.RegisterTopicValidator("heavy", func(ctx context.Context, _ peer.ID, _ *Message) bool {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	heavy, err := receiver.Subscribe("heavy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sender.Subscribe("heavy"); err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	data := bytes.Repeat([]byte("x"), 8<<10)
+	if err := sender.Publish("heavy", data); err != nil {
+		t.Fatal(err)
+	}
+
+	// the message pending async validation holds its budget
+	waitFor(t, "the message to be validated", func() bool {
+		stats, _ := receiver.MemoryBudgetStats()
+		return stats.Topics["heavy"].Used >= int64(len(data))
+	})
+	time.Sleep(100 * time.Millisecond)
+	stats, _ := receiver.MemoryBudgetStats()
+	if hs := stats.Topics["heavy"]; hs.Used < int64(len(data)) {
+		t.Fatalf("expected the message pending validation to be accounted, got %+v", hs)
+	}
+
+	// and releases it once validated and read
+	close(release)
+	msg, err := heavy.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(msg.Data, data) {
+		t.Fatal("unexpected message")
+	}
+	waitFor(t, "the message to be released", func() bool {
+		stats, _ := receiver.MemoryBudgetStats()
+		return stats.Used == 0
+	})
+}
+
+func TestMemoryBudgetGlobal(t *testing.T) {
+	lowWater := MemoryBudgetLowWater
+	MemoryBudgetLowWater = 0.5
+	defer func() { MemoryBudgetLowWater = lowWater }()
+
+	b := &memoryBudget{global: 100, topics: make(map[string]*topicMemory)}
+
+	b.add("heavy", 90)
+	b.add("light", 5)
+	if !b.admit("heavy") || !b.admit("light") || !b.admit("new") {
+		t.Fatal("expected the messages to be admitted under the budget")
+	}
+
+	// over the global budget, only the topics using an even share are blocked
+	b.add("heavy", 10)
+	if !b.blocked {
+		t.Fatal("expected the budget to be exceeded")
+	}
+	if b.admit("heavy") {
+		t.Fatal("expected the heavy topic to be blocked")
+	}
+	if !b.admit("light") || !b.admit("new") {
+		t.Fatal("expected the light topics to be admitted")
+	}
+
+	// until the usage falls below the low-water mark
+	b.add("heavy", -40)
+	if b.admit("heavy") {
+		t.Fatal("expected the heavy topic to be blocked above the low-water mark")
+	}
+	b.add("heavy", -20)
+	if !b.admit("heavy") {
+		t.Fatal("expected the heavy topic to be admitted below the low-water mark")
+	}
+
+	if b.topics["heavy"].dropped != 2 {
+		t.Fatalf("expected 2 dropped messages, got %d", b.topics["heavy"].dropped)
+	}
+
+	// released topics are forgotten, unless they dropped messages
+	b.add("light", -5)
+	if _, ok := b.topics["light"]; ok || b.active != 1 {
+		t.Fatal("expected the light topic to be forgotten")
+	}
+}
+
+func TestMemoryBudgetMessageCache(t *testing.T) {
+	b := &memoryBudget{topic: 1 << 20, topics: make(map[string]*topicMemory)}
+	mcache := NewMessageCache(1, 2)
+	mcache.budget = b
+
+	topic := "test"
+	msg := &Message{Message: &pb.Message{Topic: &topic, Seqno: []byte("1"), Data: []byte("hello")}}
+	size := int64(msg.Size())
+
+	mcache.Put(msg)
+	mcache.Put(msg)
+	if b.used != size {
+		t.Fatalf("expected %d bytes used, got %d", size, b.used)
+	}
+
+	mcache.Shift()
+	if b.used != size {
+		t.Fatalf("expected %d bytes used, got %d", size, b.used)
+	}
+	mcache.Shift()
+	if b.used != 0 {
+		t.Fatalf("expected the message to be released, got %d bytes used", b.used)
+	}
+}
@@ -22,18 +22,7 @@ func (p *PubSubNotif) Connected(n network.Network, c network.Conn) {
 		return
 	}
 
-	go func() {
-		p.newPeersPrioLk.RLock()
-		p.newPeersMx.Lock()
-		p.newPeersPend[c.RemotePeer()] = struct{}{}
-		p.newPeersMx.Unlock()
-		p.newPeersPrioLk.RUnlock()
-
-		select {
-		case p.newPeers <- struct{}{}:
-		default:
-		}
-	}()
+	(*PubSub)(p).notifyNewPeer(c.RemotePeer())
 }
 
 func (p *PubSubNotif) Disconnected(n network.Network, c network.Conn) {
@@ -46,23 +35,9 @@ func (p *PubSubNotif) ListenClose(n network.Network, _ ma.Multiaddr) {
 }
 
 func (p *PubSubNotif) Initialize() {
-	isTransient := func(pid peer.ID) bool {
-		for _, c := range p.host.Network().ConnsToPeer(pid) {
-			if !c.Stat().Transient {
-				return false
-			}
-		}
-
-		return true
-	}
-
 	p.newPeersPrioLk.RLock()
 	p.newPeersMx.Lock()
-	for _, pid := range p.host.Network().Peers() {
-		if isTransient(pid) {
-			continue
-		}
-
+	for _, pid := range p.tr.Peers() {
 		p.newPeersPend[pid] = struct{}{}
 	}
 	p.newPeersMx.Unlock()
@@ -73,3 +48,19 @@ func (p *PubSubNotif) Initialize() {
 	default:
 	}
 }
+
+// notifyNewPeer queues a newly connected peer for the event loop.
+func (p *PubSub) notifyNewPeer(pid peer.ID) {
+	go func() {
+		p.newPeersPrioLk.RLock()
+		p.newPeersMx.Lock()
+		p.newPeersPend[pid] = struct{}{}
+		p.newPeersMx.Unlock()
+		p.newPeersPrioLk.RUnlock()
+
+		select {
+		case p.newPeers <- struct{}{}:
+		default:
+		}
+	}()
+}
@@ -0,0 +1,220 @@
+package pubsub
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// OutboundQueueHighWaterHalfLife is the half-life of the high-water mark of the outbound queues of
+// the peers: the mark halves every half-life, unless the queue reaches it again.
+var OutboundQueueHighWaterHalfLife = time.Minute
+
+// PeerQueueStats describes the occupancy of the outbound queue of a peer, since its writer was
+// spawned.
+type PeerQueueStats struct {
+	// Depth is the number of RPCs waiting in the queue.
+	Depth int
+	// Capacity is the capacity of the queue; see WithPeerOutboundQueueSize.
+	Capacity int
+	// HighWater is the decaying high-water mark of the depth of the queue; see
+	// OutboundQueueHighWaterHalfLife.
+	HighWater int
+	// Enqueued and Dequeued are the number of RPCs queued, and taken out of the queue by the
+	// writer, respectively.
+	Enqueued uint64
+	Dequeued uint64
+}
+
+// OutboundQueueStats aggregates the occupancy of the outbound queues of our peers.
+type OutboundQueueStats struct {
+	// Peers is the number of peers with an outbound queue.
+	Peers int
+	// Depth and HighWater are the distributions of the depth and the high-water mark of the queues
+	// across peers.
+	Depth     QueueDepthPercentiles
+	HighWater QueueDepthPercentiles
+	// Enqueued and Dequeued are the totals for the current queues.
+	Enqueued uint64
+	Dequeued uint64
+}
+
+// QueueDepthPercentiles is a distribution of queue depths across peers.
+type QueueDepthPercentiles struct {
+	P50, P90, P99, Max int
+}
+
+// PeerQueueStats returns the occupancy of the outbound queue of a peer. It returns false if the
+// peer is not one of our peers.
+func (p *PubSub) PeerQueueStats(pid peer.ID) (PeerQueueStats, bool) {
+	type result struct {
+		stats PeerQueueStats
+		ok    bool
+	}
+
+	out := make(chan result, 1)
+	select {
+	case p.eval <- func() {
+		stats, ok := p.peerQueueStats(pid, time.Now())
+		out <- result{stats, ok}
+	}:
+		res := <-out
+		return res.stats, res.ok
+	case <-p.ctx.Done():
+		return PeerQueueStats{}, false
+	}
+}
+
+// OutboundQueueStats returns the occupancy of the outbound queues of our peers. It returns false
+// if pubsub has been shut down.
+func (p *PubSub) OutboundQueueStats() (OutboundQueueStats, bool) {
+	out := make(chan OutboundQueueStats, 1)
+	select {
+	case p.eval <- func() {
+		now := time.Now()
+		var stats OutboundQueueStats
+		var depths, highWaters []int
+		for pid := range p.peers {
+			qs, ok := p.peerQueueStats(pid, now)
+			if !ok {
+				continue
+			}
+			stats.Peers++
+			stats.Enqueued += qs.Enqueued
+			stats.Dequeued += qs.Dequeued
+			depths = append(depths, qs.Depth)
+			highWaters = append(highWaters, qs.HighWater)
+		}
+		stats.Depth = queueDepthPercentiles(depths)
+		stats.HighWater = queueDepthPercentiles(highWaters)
+		out <- stats
+	}:
+		return <-out, true
+	case <-p.ctx.Done():
+		return OutboundQueueStats{}, false
+	}
+}
+
+// peerQueueStats returns the occupancy of the outbound queue of a peer.
+// Only called from processLoop.
+func (p *PubSub) peerQueueStats(pid peer.ID, now time.Time) (PeerQueueStats, bool) {
+	ch, ok := p.peers[pid]
+	if !ok {
+		return PeerQueueStats{}, false
+	}
+	w, ok := p.writers[pid]
+	if !ok {
+		return PeerQueueStats{}, false
+	}
+
+	depth := len(ch)
+	highWater := w.queue.highWaterAt(now)
+	if depth > highWater {
+		highWater = depth
+	}
+	return PeerQueueStats{
+		Depth:     depth,
+		Capacity:  cap(ch),
+		HighWater: highWater,
+		Enqueued:  atomic.LoadUint64(&w.queue.enqueued),
+		Dequeued:  atomic.LoadUint64(&w.queue.dequeued),
+	}, true
+}
+
+// queueDepthPercentiles computes the distribution of queue depths.
+func queueDepthPercentiles(depths []int) QueueDepthPercentiles {
+	if len(depths) == 0 {
+		return QueueDepthPercentiles{}
+	}
+
+	sort.Ints(depths)
+	at := func(q float64) int {
+		return depths[int(math.Ceil(q*float64(len(depths))))-1]
+	}
+	return QueueDepthPercentiles{
+		P50: at(0.5),
+		P90: at(0.9),
+		P99: at(0.99),
+		Max: depths[len(depths)-1],
+	}
+}
+
+// queueStats tracks the occupancy of the outbound queue of a peer. The RPCs are queued from the
+// event loop and dequeued by the writer, so the counters are accessed atomically.
+type queueStats struct {
+	enqueued uint64
+	// the counters are kept on separate cache lines, as they are updated from different goroutines
+	_        [56]byte
+	dequeued uint64
+	_        [56]byte
+
+	// the high-water mark and the unix time in nanoseconds it was reached at
+	highWater     int64
+	highWaterTime int64
+
+	// the high-water mark decayed at the last reading of the clock; only accessed from the event
+	// loop
+	floor int
+}
+
+// queueStatsClockInterval is the number of RPCs queued in a burst between two readings of the clock
+// for decaying the high-water mark.
+const queueStatsClockInterval = 64
+
+// enqueued accounts an RPC queued for a peer, given the depth of the queue.
+// Only called from processLoop.
+func (p *PubSub) enqueued(pid peer.ID, ch chan *RPC) {
+	w, ok := p.writers[pid]
+	if !ok {
+		return
+	}
+	w.queue.enqueue(len(ch))
+}
+
+func (qs *queueStats) enqueue(depth int) {
+	n := atomic.AddUint64(&qs.enqueued, 1)
+
+	// reading the clock is comparatively expensive, so the decayed mark is only refreshed when the
+	// queue builds up, and periodically while it stays up, as a burst is short compared to the
+	// half-life
+	if depth == 1 && qs.floor >= 1 {
+		// the mark may have decayed since the last burst
+		qs.floor = 1
+		return
+	}
+	if depth <= qs.floor && n%queueStatsClockInterval != 0 {
+		return
+	}
+
+	// the mark is only raised from the event loop
+	now := time.Now()
+	hw := qs.highWaterAt(now)
+	if depth > hw {
+		atomic.StoreInt64(&qs.highWaterTime, now.UnixNano())
+		atomic.StoreInt64(&qs.highWater, int64(depth))
+		hw = depth
+	}
+	qs.floor = hw
+}
+
+// dequeue accounts an RPC taken out of the queue by the writer.
+func (qs *queueStats) dequeue() {
+	atomic.AddUint64(&qs.dequeued, 1)
+}
+
+// highWaterAt returns the high-water mark decayed to a point in time.
+func (qs *queueStats) highWaterAt(now time.Time) int {
+	hw := atomic.LoadInt64(&qs.highWater)
+	if hw == 0 {
+		return 0
+	}
+
+	elapsed := now.Sub(time.Unix(0, atomic.LoadInt64(&qs.highWaterTime)))
+	if elapsed <= 0 {
+		return int(hw)
+	}
+	return int(math.Round(float64(hw) * math.Exp2(-float64(elapsed)/float64(OutboundQueueHighWaterHalfLife))))
+}
@@ -0,0 +1,195 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// stallingTransport hands out streams whose writes block until they are released.
+type stallingTransport struct {
+	*hostTransport
+
+	release chan struct{}
+}
+
+func (t *stallingTransport) NewStream(ctx context.Context, p peer.ID, protos ...protocol.ID) (TransportStream, error) {
+	s, err := t.hostTransport.NewStream(ctx, p, protos...)
+	if err != nil {
+		return nil, err
+	}
+	return &stallingStream{TransportStream: s, release: t.release}, nil
+}
+
+type stallingStream struct {
+	TransportStream
+
+	release chan struct{}
+}
+
+func (s *stallingStream) Write(b []byte) (int, error) {
+	<-s.release
+	return s.TransportStream.Write(b)
+}
+
+func TestPeerQueueStats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	tr := &stallingTransport{hostTransport: newHostTransport(hosts[0]), release: make(chan struct{})}
+	var releaseOnce sync.Once
+	release := func() { releaseOnce.Do(func() { close(tr.release) }) }
+	defer release()
+
+	psA, err := NewFloodSubWithTransport(ctx, tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	psB := getPubsub(ctx, hosts[1])
+
+	topic, err := psA.Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := psB.Subscribe("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	pid := hosts[1].ID()
+	stats, ok := psA.PeerQueueStats(pid)
+	if !ok {
+		t.Fatal("expected queue stats for the peer")
+	}
+	// the writer is stalled writing our hello packet
+	if stats.Depth != 0 || stats.Enqueued != 1 || stats.Dequeued != 1 {
+		t.Fatalf("unexpected queue stats %+v", stats)
+	}
+
+	// a burst piles up in the stalled queue
+	const burst = 20
+	for i := 0; i < burst; i++ {
+		if err := topic.Publish(ctx, []byte(fmt.Sprintf("burst%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the messages are queued once they have gone through validation
+	time.Sleep(100 * time.Millisecond)
+
+	stats, _ = psA.PeerQueueStats(pid)
+	if stats.Depth != burst || stats.HighWater != burst || stats.Enqueued != burst+1 || stats.Capacity != 32 {
+		t.Fatalf("expected the burst in the queue, got %+v", stats)
+	}
+
+	release()
+	for i := 0; i < burst; i++ {
+		expectMessage(t, ctx, sub, fmt.Sprintf("burst%d", i))
+	}
+
+	// the smaller bursts after the queue has drained don't lower the mark
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 5; j++ {
+			if err := topic.Publish(ctx, []byte("small")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	for i := 0; i < 15; i++ {
+		expectMessage(t, ctx, sub, "small")
+	}
+
+	stats, _ = psA.PeerQueueStats(pid)
+	if stats.Depth != 0 || stats.HighWater != burst || stats.Enqueued != stats.Dequeued || stats.Enqueued != burst+16 {
+		t.Fatalf("expected the drained queue to keep the high-water mark of the burst, got %+v", stats)
+	}
+
+	all, ok := psA.OutboundQueueStats()
+	if !ok {
+		t.Fatal("expected outbound queue stats")
+	}
+	if all.Peers != 1 || all.HighWater.Max != burst || all.HighWater.P50 != burst || all.Enqueued != burst+16 {
+		t.Fatalf("unexpected aggregated queue stats %+v", all)
+	}
+
+	if _, ok := psA.PeerQueueStats("unknown"); ok {
+		t.Fatal("expected no queue stats for an unknown peer")
+	}
+}
+
+func TestQueueStatsHighWaterDecay(t *testing.T) {
+	var qs queueStats
+	qs.enqueue(10)
+
+	now := time.Now()
+	if hw := qs.highWaterAt(now); hw != 10 {
+		t.Fatalf("expected a high-water mark of 10, got %d", hw)
+	}
+	if hw := qs.highWaterAt(now.Add(OutboundQueueHighWaterHalfLife)); hw != 5 {
+		t.Fatalf("expected the high-water mark to halve after a half-life, got %d", hw)
+	}
+	if hw := qs.highWaterAt(now.Add(10 * OutboundQueueHighWaterHalfLife)); hw != 0 {
+		t.Fatalf("expected the high-water mark to decay, got %d", hw)
+	}
+
+	// a lower depth doesn't lower the mark
+	qs.enqueue(3)
+	if hw := qs.highWaterAt(time.Now()); hw != 10 {
+		t.Fatalf("expected a high-water mark of 10, got %d", hw)
+	}
+}
+
+func TestQueueDepthPercentiles(t *testing.T) {
+	var depths []int
+	for i := 1; i <= 100; i++ {
+		depths = append(depths, 101-i)
+	}
+
+	pct := queueDepthPercentiles(depths)
+	if pct != (QueueDepthPercentiles{P50: 50, P90: 90, P99: 99, Max: 100}) {
+		t.Fatalf("unexpected percentiles %+v", pct)
+	}
+	if pct := queueDepthPercentiles(nil); pct != (QueueDepthPercentiles{}) {
+		t.Fatalf("expected no percentiles, got %+v", pct)
+	}
+}
+
+// BenchmarkQueueStats measures the cost of the queue instrumentation on the throughput of an
+// outbound queue, with a writer writing the queued messages to a stream.
+func BenchmarkQueueStats(b *testing.B) {
+	for _, instrumented := range []bool{false, true} {
+		b.Run(fmt.Sprintf("Instrumented=%t", instrumented), func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			p := &PubSub{maxMessageSize: DefaultMaxMessageSize, logger: log}
+			w := newPeerWriter(ctx, "peer")
+			outgoing := make(chan *RPC, 32)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				p.handleSendingMessages(w, &writeCountingStream{}, outgoing)
+			}()
+
+			rpc := msgRPC(string(make([]byte, 256)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				outgoing <- rpc
+				if instrumented {
+					w.queue.enqueue(len(outgoing))
+				}
+			}
+			close(outgoing)
+			<-done
+		})
+	}
+}
@@ -4,6 +4,7 @@
 package pubsub_pb
 
 import (
+	encoding_binary "encoding/binary"
 	fmt "fmt"
 	proto "github.com/gogo/protobuf/proto"
 	io "io"
@@ -22,6 +23,58 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
 
+type ControlPrune_Reason int32
+
+const (
+	ControlPrune_UNKNOWN                ControlPrune_Reason = 0
+	ControlPrune_LEAVE                  ControlPrune_Reason = 1
+	ControlPrune_OVERSUBSCRIBED         ControlPrune_Reason = 2
+	ControlPrune_LOW_SCORE              ControlPrune_Reason = 3
+	ControlPrune_OPPORTUNISTIC_REPLACED ControlPrune_Reason = 4
+	ControlPrune_UNRESPONSIVE           ControlPrune_Reason = 5
+)
+
+var ControlPrune_Reason_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "LEAVE",
+	2: "OVERSUBSCRIBED",
+	3: "LOW_SCORE",
+	4: "OPPORTUNISTIC_REPLACED",
+	5: "UNRESPONSIVE",
+}
+
+var ControlPrune_Reason_value = map[string]int32{
+	"UNKNOWN":                0,
+	"LEAVE":                  1,
+	"OVERSUBSCRIBED":         2,
+	"LOW_SCORE":              3,
+	"OPPORTUNISTIC_REPLACED": 4,
+	"UNRESPONSIVE":           5,
+}
+
+func (x ControlPrune_Reason) Enum() *ControlPrune_Reason {
+	p := new(ControlPrune_Reason)
+	*p = x
+	return p
+}
+
+func (x ControlPrune_Reason) String() string {
+	return proto.EnumName(ControlPrune_Reason_name, int32(x))
+}
+
+func (x *ControlPrune_Reason) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(ControlPrune_Reason_value, data, "ControlPrune_Reason")
+	if err != nil {
+		return err
+	}
+	*x = ControlPrune_Reason(value)
+	return nil
+}
+
+func (ControlPrune_Reason) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_77a6da22d6a3feb1, []int{6, 0}
+}
+
 type RPC struct {
 	Subscriptions        []*RPC_SubOpts  `protobuf:"bytes,1,rep,name=subscriptions" json:"subscriptions,omitempty"`
 	Publish              []*Message      `protobuf:"bytes,2,rep,name=publish" json:"publish,omitempty"`
@@ -228,13 +281,17 @@ func (m *Message) GetKey() []byte {
 }
 
 type ControlMessage struct {
-	Ihave                []*ControlIHave `protobuf:"bytes,1,rep,name=ihave" json:"ihave,omitempty"`
-	Iwant                []*ControlIWant `protobuf:"bytes,2,rep,name=iwant" json:"iwant,omitempty"`
-	Graft                []*ControlGraft `protobuf:"bytes,3,rep,name=graft" json:"graft,omitempty"`
-	Prune                []*ControlPrune `protobuf:"bytes,4,rep,name=prune" json:"prune,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
-	XXX_unrecognized     []byte          `json:"-"`
-	XXX_sizecache        int32           `json:"-"`
+	Ihave []*ControlIHave `protobuf:"bytes,1,rep,name=ihave" json:"ihave,omitempty"`
+	Iwant []*ControlIWant `protobuf:"bytes,2,rep,name=iwant" json:"iwant,omitempty"`
+	Graft []*ControlGraft `protobuf:"bytes,3,rep,name=graft" json:"graft,omitempty"`
+	Prune []*ControlPrune `protobuf:"bytes,4,rep,name=prune" json:"prune,omitempty"`
+	// non-standard extension, ignored by peers that don't support it
+	TopicSize []*ControlTopicSize `protobuf:"bytes,100,rep,name=topicSize" json:"topicSize,omitempty"`
+	// non-standard extension, only sent to peers that announced support for it
+	Probe                *ControlProbe `protobuf:"bytes,101,opt,name=probe" json:"probe,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
 }
 
 func (m *ControlMessage) Reset()         { *m = ControlMessage{} }
@@ -298,6 +355,20 @@ func (m *ControlMessage) GetPrune() []*ControlPrune {
 	return nil
 }
 
+func (m *ControlMessage) GetTopicSize() []*ControlTopicSize {
+	if m != nil {
+		return m.TopicSize
+	}
+	return nil
+}
+
+func (m *ControlMessage) GetProbe() *ControlProbe {
+	if m != nil {
+		return m.Probe
+	}
+	return nil
+}
+
 type ControlIHave struct {
 	TopicID *string `protobuf:"bytes,1,opt,name=topicID" json:"topicID,omitempty"`
 	// implementors from other languages should use bytes here - go protobuf emits invalid utf8 strings
@@ -450,12 +521,15 @@ func (m *ControlGraft) GetTopicID() string {
 }
 
 type ControlPrune struct {
-	TopicID              *string     `protobuf:"bytes,1,opt,name=topicID" json:"topicID,omitempty"`
-	Peers                []*PeerInfo `protobuf:"bytes,2,rep,name=peers" json:"peers,omitempty"`
-	Backoff              *uint64     `protobuf:"varint,3,opt,name=backoff" json:"backoff,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
-	XXX_unrecognized     []byte      `json:"-"`
-	XXX_sizecache        int32       `json:"-"`
+	TopicID *string              `protobuf:"bytes,1,opt,name=topicID" json:"topicID,omitempty"`
+	Peers   []*PeerInfo          `protobuf:"bytes,2,rep,name=peers" json:"peers,omitempty"`
+	Backoff *uint64              `protobuf:"varint,3,opt,name=backoff" json:"backoff,omitempty"`
+	Reason  *ControlPrune_Reason `protobuf:"varint,4,opt,name=reason,enum=pubsub.pb.ControlPrune_Reason" json:"reason,omitempty"`
+	// non-standard extension, ignored by peers that don't support it
+	Leaving              *bool    `protobuf:"varint,5,opt,name=leaving" json:"leaving,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *ControlPrune) Reset()         { *m = ControlPrune{} }
@@ -512,6 +586,195 @@ func (m *ControlPrune) GetBackoff() uint64 {
 	return 0
 }
 
+func (m *ControlPrune) GetReason() ControlPrune_Reason {
+	if m != nil && m.Reason != nil {
+		return *m.Reason
+	}
+	return ControlPrune_UNKNOWN
+}
+
+func (m *ControlPrune) GetLeaving() bool {
+	if m != nil && m.Leaving != nil {
+		return *m.Leaving
+	}
+	return false
+}
+
+type ControlTopicSize struct {
+	TopicID              *string            `protobuf:"bytes,1,opt,name=topicID" json:"topicID,omitempty"`
+	Samples              []*TopicSizeSample `protobuf:"bytes,2,rep,name=samples" json:"samples,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *ControlTopicSize) Reset()         { *m = ControlTopicSize{} }
+func (m *ControlTopicSize) String() string { return proto.CompactTextString(m) }
+func (*ControlTopicSize) ProtoMessage()    {}
+func (*ControlTopicSize) Descriptor() ([]byte, []int) {
+	return fileDescriptor_77a6da22d6a3feb1, []int{7}
+}
+func (m *ControlTopicSize) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ControlTopicSize) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ControlTopicSize.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ControlTopicSize) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ControlTopicSize.Merge(m, src)
+}
+func (m *ControlTopicSize) XXX_Size() int {
+	return m.Size()
+}
+func (m *ControlTopicSize) XXX_DiscardUnknown() {
+	xxx_messageInfo_ControlTopicSize.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ControlTopicSize proto.InternalMessageInfo
+
+func (m *ControlTopicSize) GetTopicID() string {
+	if m != nil && m.TopicID != nil {
+		return *m.TopicID
+	}
+	return ""
+}
+
+func (m *ControlTopicSize) GetSamples() []*TopicSizeSample {
+	if m != nil {
+		return m.Samples
+	}
+	return nil
+}
+
+type TopicSizeSample struct {
+	Hash                 *uint64  `protobuf:"fixed64,1,opt,name=hash" json:"hash,omitempty"`
+	Age                  *uint32  `protobuf:"varint,2,opt,name=age" json:"age,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TopicSizeSample) Reset()         { *m = TopicSizeSample{} }
+func (m *TopicSizeSample) String() string { return proto.CompactTextString(m) }
+func (*TopicSizeSample) ProtoMessage()    {}
+func (*TopicSizeSample) Descriptor() ([]byte, []int) {
+	return fileDescriptor_77a6da22d6a3feb1, []int{8}
+}
+func (m *TopicSizeSample) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TopicSizeSample) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TopicSizeSample.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *TopicSizeSample) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TopicSizeSample.Merge(m, src)
+}
+func (m *TopicSizeSample) XXX_Size() int {
+	return m.Size()
+}
+func (m *TopicSizeSample) XXX_DiscardUnknown() {
+	xxx_messageInfo_TopicSizeSample.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TopicSizeSample proto.InternalMessageInfo
+
+func (m *TopicSizeSample) GetHash() uint64 {
+	if m != nil && m.Hash != nil {
+		return *m.Hash
+	}
+	return 0
+}
+
+func (m *TopicSizeSample) GetAge() uint32 {
+	if m != nil && m.Age != nil {
+		return *m.Age
+	}
+	return 0
+}
+
+type ControlProbe struct {
+	// announces that the sender answers probes
+	Supported *bool `protobuf:"varint,1,opt,name=supported" json:"supported,omitempty"`
+	// liveness probe, to be answered with a pong carrying the same nonce
+	Ping                 *uint64  `protobuf:"varint,2,opt,name=ping" json:"ping,omitempty"`
+	Pong                 *uint64  `protobuf:"varint,3,opt,name=pong" json:"pong,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ControlProbe) Reset()         { *m = ControlProbe{} }
+func (m *ControlProbe) String() string { return proto.CompactTextString(m) }
+func (*ControlProbe) ProtoMessage()    {}
+func (*ControlProbe) Descriptor() ([]byte, []int) {
+	return fileDescriptor_77a6da22d6a3feb1, []int{9}
+}
+func (m *ControlProbe) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ControlProbe) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ControlProbe.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ControlProbe) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ControlProbe.Merge(m, src)
+}
+func (m *ControlProbe) XXX_Size() int {
+	return m.Size()
+}
+func (m *ControlProbe) XXX_DiscardUnknown() {
+	xxx_messageInfo_ControlProbe.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ControlProbe proto.InternalMessageInfo
+
+func (m *ControlProbe) GetSupported() bool {
+	if m != nil && m.Supported != nil {
+		return *m.Supported
+	}
+	return false
+}
+
+func (m *ControlProbe) GetPing() uint64 {
+	if m != nil && m.Ping != nil {
+		return *m.Ping
+	}
+	return 0
+}
+
+func (m *ControlProbe) GetPong() uint64 {
+	if m != nil && m.Pong != nil {
+		return *m.Pong
+	}
+	return 0
+}
+
 type PeerInfo struct {
 	PeerID               []byte   `protobuf:"bytes,1,opt,name=peerID" json:"peerID,omitempty"`
 	SignedPeerRecord     []byte   `protobuf:"bytes,2,opt,name=signedPeerRecord" json:"signedPeerRecord,omitempty"`
@@ -524,7 +787,7 @@ func (m *PeerInfo) Reset()         { *m = PeerInfo{} }
 func (m *PeerInfo) String() string { return proto.CompactTextString(m) }
 func (*PeerInfo) ProtoMessage()    {}
 func (*PeerInfo) Descriptor() ([]byte, []int) {
-	return fileDescriptor_77a6da22d6a3feb1, []int{7}
+	return fileDescriptor_77a6da22d6a3feb1, []int{10}
 }
 func (m *PeerInfo) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -568,6 +831,7 @@ func (m *PeerInfo) GetSignedPeerRecord() []byte {
 }
 
 func init() {
+	proto.RegisterEnum("pubsub.pb.ControlPrune_Reason", ControlPrune_Reason_name, ControlPrune_Reason_value)
 	proto.RegisterType((*RPC)(nil), "pubsub.pb.RPC")
 	proto.RegisterType((*RPC_SubOpts)(nil), "pubsub.pb.RPC.SubOpts")
 	proto.RegisterType((*Message)(nil), "pubsub.pb.Message")
@@ -576,43 +840,63 @@ func init() {
 	proto.RegisterType((*ControlIWant)(nil), "pubsub.pb.ControlIWant")
 	proto.RegisterType((*ControlGraft)(nil), "pubsub.pb.ControlGraft")
 	proto.RegisterType((*ControlPrune)(nil), "pubsub.pb.ControlPrune")
+	proto.RegisterType((*ControlTopicSize)(nil), "pubsub.pb.ControlTopicSize")
+	proto.RegisterType((*TopicSizeSample)(nil), "pubsub.pb.TopicSizeSample")
+	proto.RegisterType((*ControlProbe)(nil), "pubsub.pb.ControlProbe")
 	proto.RegisterType((*PeerInfo)(nil), "pubsub.pb.PeerInfo")
 }
 
 func init() { proto.RegisterFile("rpc.proto", fileDescriptor_77a6da22d6a3feb1) }
 
 var fileDescriptor_77a6da22d6a3feb1 = []byte{
-	// 480 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x92, 0xc1, 0x8e, 0xd3, 0x3c,
-	0x10, 0xc7, 0xe5, 0x6d, 0xbb, 0xd9, 0xcc, 0xe6, 0xfb, 0xb4, 0x32, 0x68, 0x31, 0x08, 0x55, 0x55,
-	0x4e, 0x01, 0x41, 0x0e, 0xcb, 0x95, 0x0b, 0xb4, 0x12, 0x9b, 0x03, 0x50, 0x99, 0x03, 0x67, 0x27,
-	0x75, 0xba, 0xd1, 0x6e, 0x63, 0x63, 0x3b, 0x8b, 0x78, 0x08, 0xde, 0x8b, 0x03, 0x07, 0x1e, 0x01,
-	0xf5, 0xc6, 0x5b, 0x20, 0x3b, 0x4e, 0x9a, 0xa5, 0x94, 0x9b, 0xe7, 0xef, 0xdf, 0xcc, 0xfc, 0x3d,
-	0x1e, 0x08, 0x95, 0x2c, 0x52, 0xa9, 0x84, 0x11, 0x38, 0x94, 0x4d, 0xae, 0x9b, 0x3c, 0x95, 0x79,
-	0xfc, 0x0b, 0xc1, 0x88, 0x2e, 0xe7, 0xf8, 0x25, 0xfc, 0xa7, 0x9b, 0x5c, 0x17, 0xaa, 0x92, 0xa6,
-	0x12, 0xb5, 0x26, 0x68, 0x36, 0x4a, 0x4e, 0x2f, 0xce, 0xd3, 0x1e, 0x4d, 0xe9, 0x72, 0x9e, 0x7e,
-	0x68, 0xf2, 0xf7, 0xd2, 0x68, 0x7a, 0x17, 0xc6, 0xcf, 0x20, 0x90, 0x4d, 0x7e, 0x53, 0xe9, 0x2b,
-	0x72, 0xe4, 0xf2, 0xf0, 0x20, 0xef, 0x2d, 0xd7, 0x9a, 0xad, 0x39, 0xed, 0x10, 0xfc, 0x02, 0x82,
-	0x42, 0xd4, 0x46, 0x89, 0x1b, 0x32, 0x9a, 0xa1, 0xe4, 0xf4, 0xe2, 0xe1, 0x80, 0x9e, 0xb7, 0x37,
-	0x7d, 0x92, 0x27, 0x1f, 0xbd, 0x82, 0xc0, 0x37, 0xc7, 0x8f, 0x21, 0xf4, 0xed, 0x73, 0x4e, 0xd0,
-	0x0c, 0x25, 0x27, 0x74, 0x27, 0x60, 0x02, 0x81, 0x11, 0xb2, 0x2a, 0xaa, 0x15, 0x39, 0x9a, 0xa1,
-	0x24, 0xa4, 0x5d, 0x18, 0x7f, 0x45, 0x10, 0xf8, 0xba, 0x18, 0xc3, 0xb8, 0x54, 0x62, 0xe3, 0xd2,
-	0x23, 0xea, 0xce, 0x56, 0x5b, 0x31, 0xc3, 0x5c, 0x5a, 0x44, 0xdd, 0x19, 0xdf, 0x87, 0x89, 0xe6,
-	0x9f, 0x6a, 0xe1, 0x9c, 0x46, 0xb4, 0x0d, 0xac, 0xea, 0x8a, 0x92, 0xb1, 0xeb, 0xd0, 0x06, 0xce,
-	0x57, 0xb5, 0xae, 0x99, 0x69, 0x14, 0x27, 0x13, 0xc7, 0xef, 0x04, 0x7c, 0x06, 0xa3, 0x6b, 0xfe,
-	0x85, 0x1c, 0x3b, 0xdd, 0x1e, 0xe3, 0xef, 0x08, 0xfe, 0xbf, 0xfb, 0x5c, 0xfc, 0x1c, 0x26, 0xd5,
-	0x15, 0xbb, 0xe5, 0x7e, 0xfc, 0x0f, 0xf6, 0x07, 0x93, 0x5d, 0xb2, 0x5b, 0x4e, 0x5b, 0xca, 0xe1,
-	0x9f, 0x59, 0x6d, 0xfc, 0xd4, 0xff, 0x86, 0x7f, 0x64, 0xb5, 0xa1, 0x2d, 0x65, 0xf1, 0xb5, 0x62,
-	0xa5, 0x21, 0xa3, 0x43, 0xf8, 0x1b, 0x7b, 0x4d, 0x5b, 0xca, 0xe2, 0x52, 0x35, 0x35, 0x27, 0xe3,
-	0x43, 0xf8, 0xd2, 0x5e, 0xd3, 0x96, 0x8a, 0x2f, 0x21, 0x1a, 0x7a, 0xec, 0x3f, 0x22, 0x5b, 0xb8,
-	0x29, 0x77, 0x1f, 0x91, 0x2d, 0xf0, 0x14, 0x60, 0xd3, 0x3e, 0x38, 0x5b, 0x68, 0xe7, 0x3d, 0xa4,
-	0x03, 0x25, 0x4e, 0x77, 0x95, 0xac, 0xfd, 0x3f, 0x78, 0xb4, 0xc7, 0x27, 0x3d, 0xef, 0xfc, 0x1f,
-	0xee, 0x1c, 0x6f, 0x7a, 0xd2, 0x59, 0xff, 0x87, 0xc7, 0x27, 0x30, 0x91, 0x9c, 0x2b, 0xed, 0x47,
-	0x7b, 0x6f, 0xf0, 0xf8, 0x25, 0xe7, 0x2a, 0xab, 0x4b, 0x41, 0x5b, 0xc2, 0x16, 0xc9, 0x59, 0x71,
-	0x2d, 0xca, 0xd2, 0x6d, 0xc9, 0x98, 0x76, 0x61, 0xfc, 0x0e, 0x4e, 0x3a, 0x18, 0x9f, 0xc3, 0xb1,
-	0xc5, 0x7d, 0xa7, 0x88, 0xfa, 0x08, 0x3f, 0x85, 0x33, 0xbb, 0x24, 0x7c, 0x65, 0x49, 0xca, 0x0b,
-	0xa1, 0x56, 0x7e, 0x03, 0xf7, 0xf4, 0xd7, 0xd1, 0xb7, 0xed, 0x14, 0xfd, 0xd8, 0x4e, 0xd1, 0xcf,
-	0xed, 0x14, 0xfd, 0x0e, 0x00, 0x00, 0xff, 0xff, 0xb2, 0xf8, 0xc4, 0x6e, 0xd2, 0x03, 0x00, 0x00,
+	// 741 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x94, 0xcd, 0x6e, 0xdb, 0x46,
+	0x10, 0xc7, 0x4b, 0x7d, 0x9a, 0x63, 0xd9, 0x25, 0xb6, 0x85, 0xcb, 0xba, 0x85, 0x20, 0xf0, 0xa4,
+	0x16, 0xad, 0x0e, 0x4e, 0x90, 0x20, 0x40, 0x2e, 0xb6, 0x44, 0xc4, 0x44, 0x1c, 0x91, 0x18, 0x4a,
+	0xf6, 0xd1, 0x20, 0xa5, 0x95, 0x44, 0x58, 0xe6, 0xd2, 0x24, 0xe5, 0x20, 0x79, 0x87, 0xbc, 0x4c,
+	0x0e, 0x79, 0x86, 0x1c, 0xf3, 0x08, 0x81, 0x6f, 0x79, 0x8b, 0x60, 0x87, 0xa4, 0x24, 0x5b, 0x96,
+	0x6f, 0x33, 0xb3, 0xbf, 0x99, 0xe1, 0xfe, 0x67, 0x39, 0xa0, 0xc6, 0xd1, 0xa8, 0x13, 0xc5, 0x22,
+	0x15, 0x4c, 0x8d, 0x16, 0x7e, 0xb2, 0xf0, 0x3b, 0x91, 0x6f, 0xfc, 0x50, 0xa0, 0x8c, 0x4e, 0x97,
+	0xbd, 0x86, 0xbd, 0x64, 0xe1, 0x27, 0xa3, 0x38, 0x88, 0xd2, 0x40, 0x84, 0x89, 0xae, 0xb4, 0xca,
+	0xed, 0xdd, 0xa3, 0x83, 0xce, 0x12, 0xed, 0xa0, 0xd3, 0xed, 0xb8, 0x0b, 0xdf, 0x8e, 0xd2, 0x04,
+	0xef, 0xc3, 0xec, 0x3f, 0xa8, 0x47, 0x0b, 0x7f, 0x1e, 0x24, 0x33, 0xbd, 0x44, 0x79, 0x6c, 0x2d,
+	0xef, 0x1d, 0x4f, 0x12, 0x6f, 0xca, 0xb1, 0x40, 0xd8, 0x33, 0xa8, 0x8f, 0x44, 0x98, 0xc6, 0x62,
+	0xae, 0x97, 0x5b, 0x4a, 0x7b, 0xf7, 0xe8, 0xcf, 0x35, 0xba, 0x9b, 0x9d, 0x2c, 0x93, 0x72, 0xf2,
+	0xf0, 0x18, 0xea, 0x79, 0x73, 0xf6, 0x37, 0xa8, 0x79, 0x7b, 0x9f, 0xeb, 0x4a, 0x4b, 0x69, 0xef,
+	0xe0, 0x2a, 0xc0, 0x74, 0xa8, 0xa7, 0x22, 0x0a, 0x46, 0xc1, 0x58, 0x2f, 0xb5, 0x94, 0xb6, 0x8a,
+	0x85, 0x6b, 0x7c, 0x52, 0xa0, 0x9e, 0xd7, 0x65, 0x0c, 0x2a, 0x93, 0x58, 0x5c, 0x53, 0x7a, 0x03,
+	0xc9, 0x96, 0xb1, 0xb1, 0x97, 0x7a, 0x94, 0xd6, 0x40, 0xb2, 0xd9, 0xef, 0x50, 0x4d, 0xf8, 0x4d,
+	0x28, 0xe8, 0x4b, 0x1b, 0x98, 0x39, 0x32, 0x4a, 0x45, 0xf5, 0x0a, 0x75, 0xc8, 0x1c, 0xfa, 0xae,
+	0x60, 0x1a, 0x7a, 0xe9, 0x22, 0xe6, 0x7a, 0x95, 0xf8, 0x55, 0x80, 0x69, 0x50, 0xbe, 0xe2, 0x1f,
+	0xf4, 0x1a, 0xc5, 0xa5, 0x69, 0x7c, 0x29, 0xc1, 0xfe, 0xfd, 0xeb, 0xb2, 0xff, 0xa1, 0x1a, 0xcc,
+	0xbc, 0x5b, 0x9e, 0xcb, 0xff, 0xc7, 0xa6, 0x30, 0xd6, 0xa9, 0x77, 0xcb, 0x31, 0xa3, 0x08, 0x7f,
+	0xef, 0x85, 0x69, 0xae, 0xfa, 0x63, 0xf8, 0x85, 0x17, 0xa6, 0x98, 0x51, 0x12, 0x9f, 0xc6, 0xde,
+	0x24, 0xd5, 0xcb, 0xdb, 0xf0, 0x37, 0xf2, 0x18, 0x33, 0x4a, 0xe2, 0x51, 0xbc, 0x08, 0xb9, 0x5e,
+	0xd9, 0x86, 0x3b, 0xf2, 0x18, 0x33, 0x8a, 0xbd, 0x02, 0x95, 0x74, 0x70, 0x83, 0x8f, 0x5c, 0x1f,
+	0x53, 0xca, 0x5f, 0x9b, 0x29, 0x83, 0x02, 0xc1, 0x15, 0x9d, 0x75, 0x12, 0x3e, 0xd7, 0x39, 0xbd,
+	0x87, 0x47, 0x3b, 0x09, 0x9f, 0x3a, 0x09, 0x9f, 0x1b, 0xa7, 0xd0, 0x58, 0x57, 0x63, 0x39, 0x72,
+	0xab, 0x47, 0xf3, 0x2c, 0x46, 0x6e, 0xf5, 0x58, 0x13, 0xe0, 0x3a, 0x93, 0xd6, 0xea, 0x25, 0xa4,
+	0x92, 0x8a, 0x6b, 0x11, 0xa3, 0xb3, 0xaa, 0x24, 0x85, 0x7a, 0xc0, 0x2b, 0x1b, 0x7c, 0x7b, 0xc9,
+	0x93, 0x52, 0xdb, 0x3b, 0x1b, 0x9f, 0x4b, 0x4b, 0x94, 0x54, 0x7a, 0xe2, 0x23, 0xff, 0x81, 0x6a,
+	0xc4, 0x79, 0x9c, 0xe4, 0x53, 0xfc, 0x6d, 0xed, 0xf6, 0x0e, 0xe7, 0xb1, 0x15, 0x4e, 0x04, 0x66,
+	0x84, 0x2c, 0xe2, 0x7b, 0xa3, 0x2b, 0x31, 0x99, 0xd0, 0x83, 0xac, 0x60, 0xe1, 0xb2, 0x17, 0x50,
+	0x8b, 0xb9, 0x97, 0x88, 0x90, 0xde, 0xe4, 0xfe, 0x51, 0x73, 0xcb, 0xb4, 0x3a, 0x48, 0x14, 0xe6,
+	0xb4, 0xac, 0x38, 0xe7, 0xde, 0x6d, 0x10, 0x4e, 0xe9, 0xc9, 0xee, 0x60, 0xe1, 0x1a, 0x37, 0x50,
+	0xcb, 0x58, 0xb6, 0x0b, 0xf5, 0x61, 0xff, 0x6d, 0xdf, 0xbe, 0xe8, 0x6b, 0xbf, 0x30, 0x15, 0xaa,
+	0x67, 0xe6, 0xf1, 0xb9, 0xa9, 0x29, 0x8c, 0xc1, 0xbe, 0x7d, 0x6e, 0xa2, 0x3b, 0x3c, 0x71, 0xbb,
+	0x68, 0x9d, 0x98, 0x3d, 0xad, 0xc4, 0xf6, 0x40, 0x3d, 0xb3, 0x2f, 0x2e, 0xdd, 0xae, 0x8d, 0xa6,
+	0x56, 0x66, 0x87, 0x70, 0x60, 0x3b, 0x8e, 0x8d, 0x83, 0x61, 0xdf, 0x72, 0x07, 0x56, 0xf7, 0x12,
+	0x4d, 0xe7, 0xec, 0xb8, 0x6b, 0xf6, 0xb4, 0x0a, 0xd3, 0xa0, 0x31, 0xec, 0xa3, 0xe9, 0x3a, 0x76,
+	0xdf, 0xb5, 0xce, 0x4d, 0xad, 0x6a, 0xf8, 0xa0, 0x3d, 0x7c, 0x26, 0x4f, 0xe8, 0xf6, 0x1c, 0xea,
+	0x89, 0x77, 0x1d, 0xcd, 0x79, 0xa1, 0xdc, 0xe1, 0xda, 0x9d, 0x97, 0x05, 0x5c, 0x42, 0xb0, 0x40,
+	0x8d, 0x97, 0xf0, 0xeb, 0x83, 0x33, 0xf9, 0xe3, 0xcf, 0xbc, 0x64, 0x46, 0xf5, 0x6b, 0x48, 0xb6,
+	0xfc, 0x5d, 0xbd, 0x29, 0xa7, 0x5d, 0xb0, 0x87, 0xd2, 0x34, 0x06, 0x6b, 0x03, 0x15, 0x3e, 0xcf,
+	0xd6, 0x50, 0x14, 0x89, 0x38, 0xe5, 0xe3, 0xd5, 0x1a, 0xca, 0x03, 0xb2, 0x66, 0x24, 0x45, 0x2d,
+	0xd1, 0x98, 0xc8, 0xa6, 0x98, 0x08, 0xa7, 0xf9, 0xe8, 0xc8, 0x36, 0xfa, 0xb0, 0x53, 0x0c, 0x99,
+	0x1d, 0x40, 0x4d, 0x8e, 0x39, 0xbf, 0x69, 0x03, 0x73, 0x8f, 0xfd, 0x0b, 0x9a, 0xdc, 0x23, 0x7c,
+	0x2c, 0x49, 0xe4, 0x23, 0x11, 0x8f, 0xf3, 0x25, 0xb5, 0x11, 0x3f, 0x69, 0x7c, 0xbd, 0x6b, 0x2a,
+	0xdf, 0xee, 0x9a, 0xca, 0xf7, 0xbb, 0xa6, 0xf2, 0x33, 0x00, 0x00, 0xff, 0xff, 0x94, 0x94, 0x45,
+	0xda, 0xf5, 0x05, 0x00, 0x00,
 }
 
 func (m *RPC) Marshal() (dAtA []byte, err error) {
@@ -819,6 +1103,36 @@ func (m *ControlMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if m.Probe != nil {
+		{
+			size, err := m.Probe.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintRpc(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x6
+		i--
+		dAtA[i] = 0xaa
+	}
+	if len(m.TopicSize) > 0 {
+		for iNdEx := len(m.TopicSize) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.TopicSize[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintRpc(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x6
+			i--
+			dAtA[i] = 0xa2
+		}
+	}
 	if len(m.Prune) > 0 {
 		for iNdEx := len(m.Prune) - 1; iNdEx >= 0; iNdEx-- {
 			{
@@ -1015,6 +1329,21 @@ func (m *ControlPrune) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if m.Leaving != nil {
+		i--
+		if *m.Leaving {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.Reason != nil {
+		i = encodeVarintRpc(dAtA, i, uint64(*m.Reason))
+		i--
+		dAtA[i] = 0x20
+	}
 	if m.Backoff != nil {
 		i = encodeVarintRpc(dAtA, i, uint64(*m.Backoff))
 		i--
@@ -1044,7 +1373,7 @@ func (m *ControlPrune) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
-func (m *PeerInfo) Marshal() (dAtA []byte, err error) {
+func (m *ControlTopicSize) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalToSizedBuffer(dAtA[:size])
@@ -1054,12 +1383,12 @@ func (m *PeerInfo) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *PeerInfo) MarshalTo(dAtA []byte) (int, error) {
+func (m *ControlTopicSize) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *PeerInfo) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *ControlTopicSize) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
 	_ = i
 	var l int
@@ -1068,60 +1397,193 @@ func (m *PeerInfo) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	if m.SignedPeerRecord != nil {
-		i -= len(m.SignedPeerRecord)
-		copy(dAtA[i:], m.SignedPeerRecord)
-		i = encodeVarintRpc(dAtA, i, uint64(len(m.SignedPeerRecord)))
-		i--
-		dAtA[i] = 0x12
+	if len(m.Samples) > 0 {
+		for iNdEx := len(m.Samples) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Samples[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintRpc(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
+		}
 	}
-	if m.PeerID != nil {
-		i -= len(m.PeerID)
-		copy(dAtA[i:], m.PeerID)
-		i = encodeVarintRpc(dAtA, i, uint64(len(m.PeerID)))
+	if m.TopicID != nil {
+		i -= len(*m.TopicID)
+		copy(dAtA[i:], *m.TopicID)
+		i = encodeVarintRpc(dAtA, i, uint64(len(*m.TopicID)))
 		i--
 		dAtA[i] = 0xa
 	}
 	return len(dAtA) - i, nil
 }
 
-func encodeVarintRpc(dAtA []byte, offset int, v uint64) int {
-	offset -= sovRpc(v)
-	base := offset
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
-		v >>= 7
-		offset++
+func (m *TopicSizeSample) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
-	dAtA[offset] = uint8(v)
-	return base
+	return dAtA[:n], nil
 }
-func (m *RPC) Size() (n int) {
-	if m == nil {
-		return 0
-	}
+
+func (m *TopicSizeSample) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TopicSizeSample) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if len(m.Subscriptions) > 0 {
-		for _, e := range m.Subscriptions {
-			l = e.Size()
-			n += 1 + l + sovRpc(uint64(l))
-		}
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	if len(m.Publish) > 0 {
-		for _, e := range m.Publish {
-			l = e.Size()
-			n += 1 + l + sovRpc(uint64(l))
-		}
+	if m.Age != nil {
+		i = encodeVarintRpc(dAtA, i, uint64(*m.Age))
+		i--
+		dAtA[i] = 0x10
 	}
-	if m.Control != nil {
-		l = m.Control.Size()
-		n += 1 + l + sovRpc(uint64(l))
+	if m.Hash != nil {
+		i -= 8
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(*m.Hash))
+		i--
+		dAtA[i] = 0x9
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	return len(dAtA) - i, nil
+}
+
+func (m *ControlProbe) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
-	return n
+	return dAtA[:n], nil
+}
+
+func (m *ControlProbe) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ControlProbe) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.Pong != nil {
+		i = encodeVarintRpc(dAtA, i, uint64(*m.Pong))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.Ping != nil {
+		i = encodeVarintRpc(dAtA, i, uint64(*m.Ping))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.Supported != nil {
+		i--
+		if *m.Supported {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *PeerInfo) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PeerInfo) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *PeerInfo) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.SignedPeerRecord != nil {
+		i -= len(m.SignedPeerRecord)
+		copy(dAtA[i:], m.SignedPeerRecord)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.SignedPeerRecord)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.PeerID != nil {
+		i -= len(m.PeerID)
+		copy(dAtA[i:], m.PeerID)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.PeerID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintRpc(dAtA []byte, offset int, v uint64) int {
+	offset -= sovRpc(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *RPC) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Subscriptions) > 0 {
+		for _, e := range m.Subscriptions {
+			l = e.Size()
+			n += 1 + l + sovRpc(uint64(l))
+		}
+	}
+	if len(m.Publish) > 0 {
+		for _, e := range m.Publish {
+			l = e.Size()
+			n += 1 + l + sovRpc(uint64(l))
+		}
+	}
+	if m.Control != nil {
+		l = m.Control.Size()
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
 }
 
 func (m *RPC_SubOpts) Size() (n int) {
@@ -1209,6 +1671,16 @@ func (m *ControlMessage) Size() (n int) {
 			n += 1 + l + sovRpc(uint64(l))
 		}
 	}
+	if len(m.TopicSize) > 0 {
+		for _, e := range m.TopicSize {
+			l = e.Size()
+			n += 2 + l + sovRpc(uint64(l))
+		}
+	}
+	if m.Probe != nil {
+		l = m.Probe.Size()
+		n += 2 + l + sovRpc(uint64(l))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -1290,6 +1762,73 @@ func (m *ControlPrune) Size() (n int) {
 	if m.Backoff != nil {
 		n += 1 + sovRpc(uint64(*m.Backoff))
 	}
+	if m.Reason != nil {
+		n += 1 + sovRpc(uint64(*m.Reason))
+	}
+	if m.Leaving != nil {
+		n += 2
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *ControlTopicSize) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.TopicID != nil {
+		l = len(*m.TopicID)
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	if len(m.Samples) > 0 {
+		for _, e := range m.Samples {
+			l = e.Size()
+			n += 1 + l + sovRpc(uint64(l))
+		}
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TopicSizeSample) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Hash != nil {
+		n += 9
+	}
+	if m.Age != nil {
+		n += 1 + sovRpc(uint64(*m.Age))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *ControlProbe) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Supported != nil {
+		n += 2
+	}
+	if m.Ping != nil {
+		n += 1 + sovRpc(uint64(*m.Ping))
+	}
+	if m.Pong != nil {
+		n += 1 + sovRpc(uint64(*m.Pong))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -2001,6 +2540,76 @@ func (m *ControlMessage) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 100:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TopicSize", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TopicSize = append(m.TopicSize, &ControlTopicSize{})
+			if err := m.TopicSize[len(m.TopicSize)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 101:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Probe", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Probe == nil {
+				m.Probe = &ControlProbe{}
+			}
+			if err := m.Probe.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
@@ -2422,6 +3031,359 @@ func (m *ControlPrune) Unmarshal(dAtA []byte) error {
 				}
 			}
 			m.Backoff = &v
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
+			}
+			var v ControlPrune_Reason
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= ControlPrune_Reason(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Reason = &v
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Leaving", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			b := bool(v != 0)
+			m.Leaving = &b
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ControlTopicSize) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ControlTopicSize: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ControlTopicSize: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TopicID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			s := string(dAtA[iNdEx:postIndex])
+			m.TopicID = &s
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Samples", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Samples = append(m.Samples, &TopicSizeSample{})
+			if err := m.Samples[len(m.Samples)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TopicSizeSample) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TopicSizeSample: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TopicSizeSample: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Hash", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.Hash = &v
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Age", wireType)
+			}
+			var v uint32
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Age = &v
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ControlProbe) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ControlProbe: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ControlProbe: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Supported", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			b := bool(v != 0)
+			m.Supported = &b
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Ping", wireType)
+			}
+			var v uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Ping = &v
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Pong", wireType)
+			}
+			var v uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Pong = &v
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
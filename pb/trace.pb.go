@@ -25,19 +25,32 @@ const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
 type TraceEvent_Type int32
 
 const (
-	TraceEvent_PUBLISH_MESSAGE   TraceEvent_Type = 0
-	TraceEvent_REJECT_MESSAGE    TraceEvent_Type = 1
-	TraceEvent_DUPLICATE_MESSAGE TraceEvent_Type = 2
-	TraceEvent_DELIVER_MESSAGE   TraceEvent_Type = 3
-	TraceEvent_ADD_PEER          TraceEvent_Type = 4
-	TraceEvent_REMOVE_PEER       TraceEvent_Type = 5
-	TraceEvent_RECV_RPC          TraceEvent_Type = 6
-	TraceEvent_SEND_RPC          TraceEvent_Type = 7
-	TraceEvent_DROP_RPC          TraceEvent_Type = 8
-	TraceEvent_JOIN              TraceEvent_Type = 9
-	TraceEvent_LEAVE             TraceEvent_Type = 10
-	TraceEvent_GRAFT             TraceEvent_Type = 11
-	TraceEvent_PRUNE             TraceEvent_Type = 12
+	TraceEvent_PUBLISH_MESSAGE          TraceEvent_Type = 0
+	TraceEvent_REJECT_MESSAGE           TraceEvent_Type = 1
+	TraceEvent_DUPLICATE_MESSAGE        TraceEvent_Type = 2
+	TraceEvent_DELIVER_MESSAGE          TraceEvent_Type = 3
+	TraceEvent_ADD_PEER                 TraceEvent_Type = 4
+	TraceEvent_REMOVE_PEER              TraceEvent_Type = 5
+	TraceEvent_RECV_RPC                 TraceEvent_Type = 6
+	TraceEvent_SEND_RPC                 TraceEvent_Type = 7
+	TraceEvent_DROP_RPC                 TraceEvent_Type = 8
+	TraceEvent_JOIN                     TraceEvent_Type = 9
+	TraceEvent_LEAVE                    TraceEvent_Type = 10
+	TraceEvent_GRAFT                    TraceEvent_Type = 11
+	TraceEvent_PRUNE                    TraceEvent_Type = 12
+	TraceEvent_IGNORE_IHAVE             TraceEvent_Type = 13
+	TraceEvent_REJECT_RPC               TraceEvent_Type = 14
+	TraceEvent_WRITE_TIMEOUT            TraceEvent_Type = 15
+	TraceEvent_CIRCUIT_BREAKER          TraceEvent_Type = 16
+	TraceEvent_STALE_MESSAGE            TraceEvent_Type = 17
+	TraceEvent_SUBSCRIPTION_CHURN       TraceEvent_Type = 18
+	TraceEvent_MALFORMED_RPC            TraceEvent_Type = 19
+	TraceEvent_REANNOUNCE_SUBSCRIPTIONS TraceEvent_Type = 20
+	TraceEvent_SLOW_CONSUMER_THROTTLE   TraceEvent_Type = 21
+	TraceEvent_TOPIC_CONFIG             TraceEvent_Type = 22
+	TraceEvent_PEER_SUBSCRIBE           TraceEvent_Type = 23
+	TraceEvent_PEER_UNSUBSCRIBE         TraceEvent_Type = 24
+	TraceEvent_IWANT_MISS               TraceEvent_Type = 25
 )
 
 var TraceEvent_Type_name = map[int32]string{
@@ -54,22 +67,48 @@ var TraceEvent_Type_name = map[int32]string{
 	10: "LEAVE",
 	11: "GRAFT",
 	12: "PRUNE",
+	13: "IGNORE_IHAVE",
+	14: "REJECT_RPC",
+	15: "WRITE_TIMEOUT",
+	16: "CIRCUIT_BREAKER",
+	17: "STALE_MESSAGE",
+	18: "SUBSCRIPTION_CHURN",
+	19: "MALFORMED_RPC",
+	20: "REANNOUNCE_SUBSCRIPTIONS",
+	21: "SLOW_CONSUMER_THROTTLE",
+	22: "TOPIC_CONFIG",
+	23: "PEER_SUBSCRIBE",
+	24: "PEER_UNSUBSCRIBE",
+	25: "IWANT_MISS",
 }
 
 var TraceEvent_Type_value = map[string]int32{
-	"PUBLISH_MESSAGE":   0,
-	"REJECT_MESSAGE":    1,
-	"DUPLICATE_MESSAGE": 2,
-	"DELIVER_MESSAGE":   3,
-	"ADD_PEER":          4,
-	"REMOVE_PEER":       5,
-	"RECV_RPC":          6,
-	"SEND_RPC":          7,
-	"DROP_RPC":          8,
-	"JOIN":              9,
-	"LEAVE":             10,
-	"GRAFT":             11,
-	"PRUNE":             12,
+	"PUBLISH_MESSAGE":          0,
+	"REJECT_MESSAGE":           1,
+	"DUPLICATE_MESSAGE":        2,
+	"DELIVER_MESSAGE":          3,
+	"ADD_PEER":                 4,
+	"REMOVE_PEER":              5,
+	"RECV_RPC":                 6,
+	"SEND_RPC":                 7,
+	"DROP_RPC":                 8,
+	"JOIN":                     9,
+	"LEAVE":                    10,
+	"GRAFT":                    11,
+	"PRUNE":                    12,
+	"IGNORE_IHAVE":             13,
+	"REJECT_RPC":               14,
+	"WRITE_TIMEOUT":            15,
+	"CIRCUIT_BREAKER":          16,
+	"STALE_MESSAGE":            17,
+	"SUBSCRIPTION_CHURN":       18,
+	"MALFORMED_RPC":            19,
+	"REANNOUNCE_SUBSCRIPTIONS": 20,
+	"SLOW_CONSUMER_THROTTLE":   21,
+	"TOPIC_CONFIG":             22,
+	"PEER_SUBSCRIBE":           23,
+	"PEER_UNSUBSCRIBE":         24,
+	"IWANT_MISS":               25,
 }
 
 func (x TraceEvent_Type) Enum() *TraceEvent_Type {
@@ -95,26 +134,180 @@ func (TraceEvent_Type) EnumDescriptor() ([]byte, []int) {
 	return fileDescriptor_0571941a1d628a80, []int{0, 0}
 }
 
+type TraceEvent_IgnoreIHave_Reason int32
+
+const (
+	TraceEvent_IgnoreIHave_LOW_SCORE       TraceEvent_IgnoreIHave_Reason = 0
+	TraceEvent_IgnoreIHave_TOO_MANY_IHAVES TraceEvent_IgnoreIHave_Reason = 1
+	TraceEvent_IgnoreIHave_TOO_MANY_IWANTS TraceEvent_IgnoreIHave_Reason = 2
+)
+
+var TraceEvent_IgnoreIHave_Reason_name = map[int32]string{
+	0: "LOW_SCORE",
+	1: "TOO_MANY_IHAVES",
+	2: "TOO_MANY_IWANTS",
+}
+
+var TraceEvent_IgnoreIHave_Reason_value = map[string]int32{
+	"LOW_SCORE":       0,
+	"TOO_MANY_IHAVES": 1,
+	"TOO_MANY_IWANTS": 2,
+}
+
+func (x TraceEvent_IgnoreIHave_Reason) Enum() *TraceEvent_IgnoreIHave_Reason {
+	p := new(TraceEvent_IgnoreIHave_Reason)
+	*p = x
+	return p
+}
+
+func (x TraceEvent_IgnoreIHave_Reason) String() string {
+	return proto.EnumName(TraceEvent_IgnoreIHave_Reason_name, int32(x))
+}
+
+func (x *TraceEvent_IgnoreIHave_Reason) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(TraceEvent_IgnoreIHave_Reason_value, data, "TraceEvent_IgnoreIHave_Reason")
+	if err != nil {
+		return err
+	}
+	*x = TraceEvent_IgnoreIHave_Reason(value)
+	return nil
+}
+
+func (TraceEvent_IgnoreIHave_Reason) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 13, 0}
+}
+
+type TraceEvent_RejectRPC_Limit int32
+
+const (
+	TraceEvent_RejectRPC_SUBSCRIPTIONS TraceEvent_RejectRPC_Limit = 0
+	TraceEvent_RejectRPC_MESSAGES      TraceEvent_RejectRPC_Limit = 1
+	TraceEvent_RejectRPC_IHAVE         TraceEvent_RejectRPC_Limit = 2
+	TraceEvent_RejectRPC_IWANT         TraceEvent_RejectRPC_Limit = 3
+	TraceEvent_RejectRPC_GRAFT         TraceEvent_RejectRPC_Limit = 4
+	TraceEvent_RejectRPC_PRUNE         TraceEvent_RejectRPC_Limit = 5
+	TraceEvent_RejectRPC_MESSAGE_IDS   TraceEvent_RejectRPC_Limit = 6
+)
+
+var TraceEvent_RejectRPC_Limit_name = map[int32]string{
+	0: "SUBSCRIPTIONS",
+	1: "MESSAGES",
+	2: "IHAVE",
+	3: "IWANT",
+	4: "GRAFT",
+	5: "PRUNE",
+	6: "MESSAGE_IDS",
+}
+
+var TraceEvent_RejectRPC_Limit_value = map[string]int32{
+	"SUBSCRIPTIONS": 0,
+	"MESSAGES":      1,
+	"IHAVE":         2,
+	"IWANT":         3,
+	"GRAFT":         4,
+	"PRUNE":         5,
+	"MESSAGE_IDS":   6,
+}
+
+func (x TraceEvent_RejectRPC_Limit) Enum() *TraceEvent_RejectRPC_Limit {
+	p := new(TraceEvent_RejectRPC_Limit)
+	*p = x
+	return p
+}
+
+func (x TraceEvent_RejectRPC_Limit) String() string {
+	return proto.EnumName(TraceEvent_RejectRPC_Limit_name, int32(x))
+}
+
+func (x *TraceEvent_RejectRPC_Limit) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(TraceEvent_RejectRPC_Limit_value, data, "TraceEvent_RejectRPC_Limit")
+	if err != nil {
+		return err
+	}
+	*x = TraceEvent_RejectRPC_Limit(value)
+	return nil
+}
+
+func (TraceEvent_RejectRPC_Limit) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 14, 0}
+}
+
+type TraceEvent_CircuitBreaker_State int32
+
+const (
+	TraceEvent_CircuitBreaker_CLOSED    TraceEvent_CircuitBreaker_State = 0
+	TraceEvent_CircuitBreaker_OPEN      TraceEvent_CircuitBreaker_State = 1
+	TraceEvent_CircuitBreaker_HALF_OPEN TraceEvent_CircuitBreaker_State = 2
+)
+
+var TraceEvent_CircuitBreaker_State_name = map[int32]string{
+	0: "CLOSED",
+	1: "OPEN",
+	2: "HALF_OPEN",
+}
+
+var TraceEvent_CircuitBreaker_State_value = map[string]int32{
+	"CLOSED":    0,
+	"OPEN":      1,
+	"HALF_OPEN": 2,
+}
+
+func (x TraceEvent_CircuitBreaker_State) Enum() *TraceEvent_CircuitBreaker_State {
+	p := new(TraceEvent_CircuitBreaker_State)
+	*p = x
+	return p
+}
+
+func (x TraceEvent_CircuitBreaker_State) String() string {
+	return proto.EnumName(TraceEvent_CircuitBreaker_State_name, int32(x))
+}
+
+func (x *TraceEvent_CircuitBreaker_State) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(TraceEvent_CircuitBreaker_State_value, data, "TraceEvent_CircuitBreaker_State")
+	if err != nil {
+		return err
+	}
+	*x = TraceEvent_CircuitBreaker_State(value)
+	return nil
+}
+
+func (TraceEvent_CircuitBreaker_State) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 16, 0}
+}
+
 type TraceEvent struct {
-	Type                 *TraceEvent_Type             `protobuf:"varint,1,opt,name=type,enum=pubsub.pb.TraceEvent_Type" json:"type,omitempty"`
-	PeerID               []byte                       `protobuf:"bytes,2,opt,name=peerID" json:"peerID,omitempty"`
-	Timestamp            *int64                       `protobuf:"varint,3,opt,name=timestamp" json:"timestamp,omitempty"`
-	PublishMessage       *TraceEvent_PublishMessage   `protobuf:"bytes,4,opt,name=publishMessage" json:"publishMessage,omitempty"`
-	RejectMessage        *TraceEvent_RejectMessage    `protobuf:"bytes,5,opt,name=rejectMessage" json:"rejectMessage,omitempty"`
-	DuplicateMessage     *TraceEvent_DuplicateMessage `protobuf:"bytes,6,opt,name=duplicateMessage" json:"duplicateMessage,omitempty"`
-	DeliverMessage       *TraceEvent_DeliverMessage   `protobuf:"bytes,7,opt,name=deliverMessage" json:"deliverMessage,omitempty"`
-	AddPeer              *TraceEvent_AddPeer          `protobuf:"bytes,8,opt,name=addPeer" json:"addPeer,omitempty"`
-	RemovePeer           *TraceEvent_RemovePeer       `protobuf:"bytes,9,opt,name=removePeer" json:"removePeer,omitempty"`
-	RecvRPC              *TraceEvent_RecvRPC          `protobuf:"bytes,10,opt,name=recvRPC" json:"recvRPC,omitempty"`
-	SendRPC              *TraceEvent_SendRPC          `protobuf:"bytes,11,opt,name=sendRPC" json:"sendRPC,omitempty"`
-	DropRPC              *TraceEvent_DropRPC          `protobuf:"bytes,12,opt,name=dropRPC" json:"dropRPC,omitempty"`
-	Join                 *TraceEvent_Join             `protobuf:"bytes,13,opt,name=join" json:"join,omitempty"`
-	Leave                *TraceEvent_Leave            `protobuf:"bytes,14,opt,name=leave" json:"leave,omitempty"`
-	Graft                *TraceEvent_Graft            `protobuf:"bytes,15,opt,name=graft" json:"graft,omitempty"`
-	Prune                *TraceEvent_Prune            `protobuf:"bytes,16,opt,name=prune" json:"prune,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}                     `json:"-"`
-	XXX_unrecognized     []byte                       `json:"-"`
-	XXX_sizecache        int32                        `json:"-"`
+	Type                    *TraceEvent_Type                    `protobuf:"varint,1,opt,name=type,enum=pubsub.pb.TraceEvent_Type" json:"type,omitempty"`
+	PeerID                  []byte                              `protobuf:"bytes,2,opt,name=peerID" json:"peerID,omitempty"`
+	Timestamp               *int64                              `protobuf:"varint,3,opt,name=timestamp" json:"timestamp,omitempty"`
+	PublishMessage          *TraceEvent_PublishMessage          `protobuf:"bytes,4,opt,name=publishMessage" json:"publishMessage,omitempty"`
+	RejectMessage           *TraceEvent_RejectMessage           `protobuf:"bytes,5,opt,name=rejectMessage" json:"rejectMessage,omitempty"`
+	DuplicateMessage        *TraceEvent_DuplicateMessage        `protobuf:"bytes,6,opt,name=duplicateMessage" json:"duplicateMessage,omitempty"`
+	DeliverMessage          *TraceEvent_DeliverMessage          `protobuf:"bytes,7,opt,name=deliverMessage" json:"deliverMessage,omitempty"`
+	AddPeer                 *TraceEvent_AddPeer                 `protobuf:"bytes,8,opt,name=addPeer" json:"addPeer,omitempty"`
+	RemovePeer              *TraceEvent_RemovePeer              `protobuf:"bytes,9,opt,name=removePeer" json:"removePeer,omitempty"`
+	RecvRPC                 *TraceEvent_RecvRPC                 `protobuf:"bytes,10,opt,name=recvRPC" json:"recvRPC,omitempty"`
+	SendRPC                 *TraceEvent_SendRPC                 `protobuf:"bytes,11,opt,name=sendRPC" json:"sendRPC,omitempty"`
+	DropRPC                 *TraceEvent_DropRPC                 `protobuf:"bytes,12,opt,name=dropRPC" json:"dropRPC,omitempty"`
+	Join                    *TraceEvent_Join                    `protobuf:"bytes,13,opt,name=join" json:"join,omitempty"`
+	Leave                   *TraceEvent_Leave                   `protobuf:"bytes,14,opt,name=leave" json:"leave,omitempty"`
+	Graft                   *TraceEvent_Graft                   `protobuf:"bytes,15,opt,name=graft" json:"graft,omitempty"`
+	Prune                   *TraceEvent_Prune                   `protobuf:"bytes,16,opt,name=prune" json:"prune,omitempty"`
+	IgnoreIHave             *TraceEvent_IgnoreIHave             `protobuf:"bytes,17,opt,name=ignoreIHave" json:"ignoreIHave,omitempty"`
+	RejectRPC               *TraceEvent_RejectRPC               `protobuf:"bytes,18,opt,name=rejectRPC" json:"rejectRPC,omitempty"`
+	WriteTimeout            *TraceEvent_WriteTimeout            `protobuf:"bytes,19,opt,name=writeTimeout" json:"writeTimeout,omitempty"`
+	CircuitBreaker          *TraceEvent_CircuitBreaker          `protobuf:"bytes,20,opt,name=circuitBreaker" json:"circuitBreaker,omitempty"`
+	StaleMessage            *TraceEvent_StaleMessage            `protobuf:"bytes,21,opt,name=staleMessage" json:"staleMessage,omitempty"`
+	SubscriptionChurn       *TraceEvent_SubscriptionChurn       `protobuf:"bytes,22,opt,name=subscriptionChurn" json:"subscriptionChurn,omitempty"`
+	MalformedRPC            *TraceEvent_MalformedRPC            `protobuf:"bytes,23,opt,name=malformedRPC" json:"malformedRPC,omitempty"`
+	ReannounceSubscriptions *TraceEvent_ReannounceSubscriptions `protobuf:"bytes,24,opt,name=reannounceSubscriptions" json:"reannounceSubscriptions,omitempty"`
+	SlowConsumerThrottle    *TraceEvent_SlowConsumerThrottle    `protobuf:"bytes,25,opt,name=slowConsumerThrottle" json:"slowConsumerThrottle,omitempty"`
+	TopicConfig             *TraceEvent_TopicConfig             `protobuf:"bytes,26,opt,name=topicConfig" json:"topicConfig,omitempty"`
+	PeerSubscribe           *TraceEvent_PeerSubscribe           `protobuf:"bytes,27,opt,name=peerSubscribe" json:"peerSubscribe,omitempty"`
+	PeerUnsubscribe         *TraceEvent_PeerUnsubscribe         `protobuf:"bytes,28,opt,name=peerUnsubscribe" json:"peerUnsubscribe,omitempty"`
+	IwantMiss               *TraceEvent_IWantMiss               `protobuf:"bytes,29,opt,name=iwantMiss" json:"iwantMiss,omitempty"`
+	XXX_NoUnkeyedLiteral    struct{}                            `json:"-"`
+	XXX_unrecognized        []byte                              `json:"-"`
+	XXX_sizecache           int32                               `json:"-"`
 }
 
 func (m *TraceEvent) Reset()         { *m = TraceEvent{} }
@@ -262,6 +455,97 @@ func (m *TraceEvent) GetPrune() *TraceEvent_Prune {
 	return nil
 }
 
+func (m *TraceEvent) GetIgnoreIHave() *TraceEvent_IgnoreIHave {
+	if m != nil {
+		return m.IgnoreIHave
+	}
+	return nil
+}
+
+func (m *TraceEvent) GetRejectRPC() *TraceEvent_RejectRPC {
+	if m != nil {
+		return m.RejectRPC
+	}
+	return nil
+}
+
+func (m *TraceEvent) GetWriteTimeout() *TraceEvent_WriteTimeout {
+	if m != nil {
+		return m.WriteTimeout
+	}
+	return nil
+}
+
+func (m *TraceEvent) GetCircuitBreaker() *TraceEvent_CircuitBreaker {
+	if m != nil {
+		return m.CircuitBreaker
+	}
+	return nil
+}
+
+func (m *TraceEvent) GetStaleMessage() *TraceEvent_StaleMessage {
+	if m != nil {
+		return m.StaleMessage
+	}
+	return nil
+}
+
+func (m *TraceEvent) GetSubscriptionChurn() *TraceEvent_SubscriptionChurn {
+	if m != nil {
+		return m.SubscriptionChurn
+	}
+	return nil
+}
+
+func (m *TraceEvent) GetMalformedRPC() *TraceEvent_MalformedRPC {
+	if m != nil {
+		return m.MalformedRPC
+	}
+	return nil
+}
+
+func (m *TraceEvent) GetReannounceSubscriptions() *TraceEvent_ReannounceSubscriptions {
+	if m != nil {
+		return m.ReannounceSubscriptions
+	}
+	return nil
+}
+
+func (m *TraceEvent) GetSlowConsumerThrottle() *TraceEvent_SlowConsumerThrottle {
+	if m != nil {
+		return m.SlowConsumerThrottle
+	}
+	return nil
+}
+
+func (m *TraceEvent) GetTopicConfig() *TraceEvent_TopicConfig {
+	if m != nil {
+		return m.TopicConfig
+	}
+	return nil
+}
+
+func (m *TraceEvent) GetPeerSubscribe() *TraceEvent_PeerSubscribe {
+	if m != nil {
+		return m.PeerSubscribe
+	}
+	return nil
+}
+
+func (m *TraceEvent) GetPeerUnsubscribe() *TraceEvent_PeerUnsubscribe {
+	if m != nil {
+		return m.PeerUnsubscribe
+	}
+	return nil
+}
+
+func (m *TraceEvent) GetIwantMiss() *TraceEvent_IWantMiss {
+	if m != nil {
+		return m.IwantMiss
+	}
+	return nil
+}
+
 type TraceEvent_PublishMessage struct {
 	MessageID            []byte   `protobuf:"bytes,1,opt,name=messageID" json:"messageID,omitempty"`
 	Topic                *string  `protobuf:"bytes,2,opt,name=topic" json:"topic,omitempty"`
@@ -931,11 +1215,12 @@ func (m *TraceEvent_Graft) GetTopic() string {
 }
 
 type TraceEvent_Prune struct {
-	PeerID               []byte   `protobuf:"bytes,1,opt,name=peerID" json:"peerID,omitempty"`
-	Topic                *string  `protobuf:"bytes,2,opt,name=topic" json:"topic,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	PeerID               []byte               `protobuf:"bytes,1,opt,name=peerID" json:"peerID,omitempty"`
+	Topic                *string              `protobuf:"bytes,2,opt,name=topic" json:"topic,omitempty"`
+	Reason               *ControlPrune_Reason `protobuf:"varint,3,opt,name=reason,enum=pubsub.pb.ControlPrune_Reason" json:"reason,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
 }
 
 func (m *TraceEvent_Prune) Reset()         { *m = TraceEvent_Prune{} }
@@ -985,27 +1270,34 @@ func (m *TraceEvent_Prune) GetTopic() string {
 	return ""
 }
 
-type TraceEvent_RPCMeta struct {
-	Messages             []*TraceEvent_MessageMeta `protobuf:"bytes,1,rep,name=messages" json:"messages,omitempty"`
-	Subscription         []*TraceEvent_SubMeta     `protobuf:"bytes,2,rep,name=subscription" json:"subscription,omitempty"`
-	Control              *TraceEvent_ControlMeta   `protobuf:"bytes,3,opt,name=control" json:"control,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}                  `json:"-"`
-	XXX_unrecognized     []byte                    `json:"-"`
-	XXX_sizecache        int32                     `json:"-"`
+func (m *TraceEvent_Prune) GetReason() ControlPrune_Reason {
+	if m != nil && m.Reason != nil {
+		return *m.Reason
+	}
+	return ControlPrune_UNKNOWN
 }
 
-func (m *TraceEvent_RPCMeta) Reset()         { *m = TraceEvent_RPCMeta{} }
-func (m *TraceEvent_RPCMeta) String() string { return proto.CompactTextString(m) }
-func (*TraceEvent_RPCMeta) ProtoMessage()    {}
-func (*TraceEvent_RPCMeta) Descriptor() ([]byte, []int) {
+type TraceEvent_IgnoreIHave struct {
+	PeerID               []byte                         `protobuf:"bytes,1,opt,name=peerID" json:"peerID,omitempty"`
+	Reason               *TraceEvent_IgnoreIHave_Reason `protobuf:"varint,2,opt,name=reason,enum=pubsub.pb.TraceEvent_IgnoreIHave_Reason" json:"reason,omitempty"`
+	Topic                *string                        `protobuf:"bytes,3,opt,name=topic" json:"topic,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                       `json:"-"`
+	XXX_unrecognized     []byte                         `json:"-"`
+	XXX_sizecache        int32                          `json:"-"`
+}
+
+func (m *TraceEvent_IgnoreIHave) Reset()         { *m = TraceEvent_IgnoreIHave{} }
+func (m *TraceEvent_IgnoreIHave) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_IgnoreIHave) ProtoMessage()    {}
+func (*TraceEvent_IgnoreIHave) Descriptor() ([]byte, []int) {
 	return fileDescriptor_0571941a1d628a80, []int{0, 13}
 }
-func (m *TraceEvent_RPCMeta) XXX_Unmarshal(b []byte) error {
+func (m *TraceEvent_IgnoreIHave) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
-func (m *TraceEvent_RPCMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+func (m *TraceEvent_IgnoreIHave) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	if deterministic {
-		return xxx_messageInfo_TraceEvent_RPCMeta.Marshal(b, m, deterministic)
+		return xxx_messageInfo_TraceEvent_IgnoreIHave.Marshal(b, m, deterministic)
 	} else {
 		b = b[:cap(b)]
 		n, err := m.MarshalToSizedBuffer(b)
@@ -1015,59 +1307,60 @@ func (m *TraceEvent_RPCMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte,
 		return b[:n], nil
 	}
 }
-func (m *TraceEvent_RPCMeta) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_TraceEvent_RPCMeta.Merge(m, src)
+func (m *TraceEvent_IgnoreIHave) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_IgnoreIHave.Merge(m, src)
 }
-func (m *TraceEvent_RPCMeta) XXX_Size() int {
+func (m *TraceEvent_IgnoreIHave) XXX_Size() int {
 	return m.Size()
 }
-func (m *TraceEvent_RPCMeta) XXX_DiscardUnknown() {
-	xxx_messageInfo_TraceEvent_RPCMeta.DiscardUnknown(m)
+func (m *TraceEvent_IgnoreIHave) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_IgnoreIHave.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_TraceEvent_RPCMeta proto.InternalMessageInfo
+var xxx_messageInfo_TraceEvent_IgnoreIHave proto.InternalMessageInfo
 
-func (m *TraceEvent_RPCMeta) GetMessages() []*TraceEvent_MessageMeta {
+func (m *TraceEvent_IgnoreIHave) GetPeerID() []byte {
 	if m != nil {
-		return m.Messages
+		return m.PeerID
 	}
 	return nil
 }
 
-func (m *TraceEvent_RPCMeta) GetSubscription() []*TraceEvent_SubMeta {
-	if m != nil {
-		return m.Subscription
+func (m *TraceEvent_IgnoreIHave) GetReason() TraceEvent_IgnoreIHave_Reason {
+	if m != nil && m.Reason != nil {
+		return *m.Reason
 	}
-	return nil
+	return TraceEvent_IgnoreIHave_LOW_SCORE
 }
 
-func (m *TraceEvent_RPCMeta) GetControl() *TraceEvent_ControlMeta {
-	if m != nil {
-		return m.Control
+func (m *TraceEvent_IgnoreIHave) GetTopic() string {
+	if m != nil && m.Topic != nil {
+		return *m.Topic
 	}
-	return nil
+	return ""
 }
 
-type TraceEvent_MessageMeta struct {
-	MessageID            []byte   `protobuf:"bytes,1,opt,name=messageID" json:"messageID,omitempty"`
-	Topic                *string  `protobuf:"bytes,2,opt,name=topic" json:"topic,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+type TraceEvent_RejectRPC struct {
+	PeerID []byte `protobuf:"bytes,1,opt,name=peerID" json:"peerID,omitempty"`
+	// the violated limit
+	Limit                *TraceEvent_RejectRPC_Limit `protobuf:"varint,2,opt,name=limit,enum=pubsub.pb.TraceEvent_RejectRPC_Limit" json:"limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                    `json:"-"`
+	XXX_unrecognized     []byte                      `json:"-"`
+	XXX_sizecache        int32                       `json:"-"`
 }
 
-func (m *TraceEvent_MessageMeta) Reset()         { *m = TraceEvent_MessageMeta{} }
-func (m *TraceEvent_MessageMeta) String() string { return proto.CompactTextString(m) }
-func (*TraceEvent_MessageMeta) ProtoMessage()    {}
-func (*TraceEvent_MessageMeta) Descriptor() ([]byte, []int) {
+func (m *TraceEvent_RejectRPC) Reset()         { *m = TraceEvent_RejectRPC{} }
+func (m *TraceEvent_RejectRPC) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_RejectRPC) ProtoMessage()    {}
+func (*TraceEvent_RejectRPC) Descriptor() ([]byte, []int) {
 	return fileDescriptor_0571941a1d628a80, []int{0, 14}
 }
-func (m *TraceEvent_MessageMeta) XXX_Unmarshal(b []byte) error {
+func (m *TraceEvent_RejectRPC) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
-func (m *TraceEvent_MessageMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+func (m *TraceEvent_RejectRPC) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	if deterministic {
-		return xxx_messageInfo_TraceEvent_MessageMeta.Marshal(b, m, deterministic)
+		return xxx_messageInfo_TraceEvent_RejectRPC.Marshal(b, m, deterministic)
 	} else {
 		b = b[:cap(b)]
 		n, err := m.MarshalToSizedBuffer(b)
@@ -1077,52 +1370,51 @@ func (m *TraceEvent_MessageMeta) XXX_Marshal(b []byte, deterministic bool) ([]by
 		return b[:n], nil
 	}
 }
-func (m *TraceEvent_MessageMeta) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_TraceEvent_MessageMeta.Merge(m, src)
+func (m *TraceEvent_RejectRPC) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_RejectRPC.Merge(m, src)
 }
-func (m *TraceEvent_MessageMeta) XXX_Size() int {
+func (m *TraceEvent_RejectRPC) XXX_Size() int {
 	return m.Size()
 }
-func (m *TraceEvent_MessageMeta) XXX_DiscardUnknown() {
-	xxx_messageInfo_TraceEvent_MessageMeta.DiscardUnknown(m)
+func (m *TraceEvent_RejectRPC) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_RejectRPC.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_TraceEvent_MessageMeta proto.InternalMessageInfo
+var xxx_messageInfo_TraceEvent_RejectRPC proto.InternalMessageInfo
 
-func (m *TraceEvent_MessageMeta) GetMessageID() []byte {
+func (m *TraceEvent_RejectRPC) GetPeerID() []byte {
 	if m != nil {
-		return m.MessageID
+		return m.PeerID
 	}
 	return nil
 }
 
-func (m *TraceEvent_MessageMeta) GetTopic() string {
-	if m != nil && m.Topic != nil {
-		return *m.Topic
+func (m *TraceEvent_RejectRPC) GetLimit() TraceEvent_RejectRPC_Limit {
+	if m != nil && m.Limit != nil {
+		return *m.Limit
 	}
-	return ""
+	return TraceEvent_RejectRPC_SUBSCRIPTIONS
 }
 
-type TraceEvent_SubMeta struct {
-	Subscribe            *bool    `protobuf:"varint,1,opt,name=subscribe" json:"subscribe,omitempty"`
-	Topic                *string  `protobuf:"bytes,2,opt,name=topic" json:"topic,omitempty"`
+type TraceEvent_WriteTimeout struct {
+	PeerID               []byte   `protobuf:"bytes,1,opt,name=peerID" json:"peerID,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TraceEvent_SubMeta) Reset()         { *m = TraceEvent_SubMeta{} }
-func (m *TraceEvent_SubMeta) String() string { return proto.CompactTextString(m) }
-func (*TraceEvent_SubMeta) ProtoMessage()    {}
-func (*TraceEvent_SubMeta) Descriptor() ([]byte, []int) {
+func (m *TraceEvent_WriteTimeout) Reset()         { *m = TraceEvent_WriteTimeout{} }
+func (m *TraceEvent_WriteTimeout) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_WriteTimeout) ProtoMessage()    {}
+func (*TraceEvent_WriteTimeout) Descriptor() ([]byte, []int) {
 	return fileDescriptor_0571941a1d628a80, []int{0, 15}
 }
-func (m *TraceEvent_SubMeta) XXX_Unmarshal(b []byte) error {
+func (m *TraceEvent_WriteTimeout) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
-func (m *TraceEvent_SubMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+func (m *TraceEvent_WriteTimeout) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	if deterministic {
-		return xxx_messageInfo_TraceEvent_SubMeta.Marshal(b, m, deterministic)
+		return xxx_messageInfo_TraceEvent_WriteTimeout.Marshal(b, m, deterministic)
 	} else {
 		b = b[:cap(b)]
 		n, err := m.MarshalToSizedBuffer(b)
@@ -1132,54 +1424,46 @@ func (m *TraceEvent_SubMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte,
 		return b[:n], nil
 	}
 }
-func (m *TraceEvent_SubMeta) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_TraceEvent_SubMeta.Merge(m, src)
+func (m *TraceEvent_WriteTimeout) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_WriteTimeout.Merge(m, src)
 }
-func (m *TraceEvent_SubMeta) XXX_Size() int {
+func (m *TraceEvent_WriteTimeout) XXX_Size() int {
 	return m.Size()
 }
-func (m *TraceEvent_SubMeta) XXX_DiscardUnknown() {
-	xxx_messageInfo_TraceEvent_SubMeta.DiscardUnknown(m)
+func (m *TraceEvent_WriteTimeout) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_WriteTimeout.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_TraceEvent_SubMeta proto.InternalMessageInfo
-
-func (m *TraceEvent_SubMeta) GetSubscribe() bool {
-	if m != nil && m.Subscribe != nil {
-		return *m.Subscribe
-	}
-	return false
-}
+var xxx_messageInfo_TraceEvent_WriteTimeout proto.InternalMessageInfo
 
-func (m *TraceEvent_SubMeta) GetTopic() string {
-	if m != nil && m.Topic != nil {
-		return *m.Topic
+func (m *TraceEvent_WriteTimeout) GetPeerID() []byte {
+	if m != nil {
+		return m.PeerID
 	}
-	return ""
+	return nil
 }
 
-type TraceEvent_ControlMeta struct {
-	Ihave                []*TraceEvent_ControlIHaveMeta `protobuf:"bytes,1,rep,name=ihave" json:"ihave,omitempty"`
-	Iwant                []*TraceEvent_ControlIWantMeta `protobuf:"bytes,2,rep,name=iwant" json:"iwant,omitempty"`
-	Graft                []*TraceEvent_ControlGraftMeta `protobuf:"bytes,3,rep,name=graft" json:"graft,omitempty"`
-	Prune                []*TraceEvent_ControlPruneMeta `protobuf:"bytes,4,rep,name=prune" json:"prune,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}                       `json:"-"`
-	XXX_unrecognized     []byte                         `json:"-"`
-	XXX_sizecache        int32                          `json:"-"`
+type TraceEvent_CircuitBreaker struct {
+	Topic *string `protobuf:"bytes,1,opt,name=topic" json:"topic,omitempty"`
+	// the new state of the circuit breaker of the topic
+	State                *TraceEvent_CircuitBreaker_State `protobuf:"varint,2,opt,name=state,enum=pubsub.pb.TraceEvent_CircuitBreaker_State" json:"state,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                         `json:"-"`
+	XXX_unrecognized     []byte                           `json:"-"`
+	XXX_sizecache        int32                            `json:"-"`
 }
 
-func (m *TraceEvent_ControlMeta) Reset()         { *m = TraceEvent_ControlMeta{} }
-func (m *TraceEvent_ControlMeta) String() string { return proto.CompactTextString(m) }
-func (*TraceEvent_ControlMeta) ProtoMessage()    {}
-func (*TraceEvent_ControlMeta) Descriptor() ([]byte, []int) {
+func (m *TraceEvent_CircuitBreaker) Reset()         { *m = TraceEvent_CircuitBreaker{} }
+func (m *TraceEvent_CircuitBreaker) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_CircuitBreaker) ProtoMessage()    {}
+func (*TraceEvent_CircuitBreaker) Descriptor() ([]byte, []int) {
 	return fileDescriptor_0571941a1d628a80, []int{0, 16}
 }
-func (m *TraceEvent_ControlMeta) XXX_Unmarshal(b []byte) error {
+func (m *TraceEvent_CircuitBreaker) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
-func (m *TraceEvent_ControlMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+func (m *TraceEvent_CircuitBreaker) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	if deterministic {
-		return xxx_messageInfo_TraceEvent_ControlMeta.Marshal(b, m, deterministic)
+		return xxx_messageInfo_TraceEvent_CircuitBreaker.Marshal(b, m, deterministic)
 	} else {
 		b = b[:cap(b)]
 		n, err := m.MarshalToSizedBuffer(b)
@@ -1189,66 +1473,119 @@ func (m *TraceEvent_ControlMeta) XXX_Marshal(b []byte, deterministic bool) ([]by
 		return b[:n], nil
 	}
 }
-func (m *TraceEvent_ControlMeta) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_TraceEvent_ControlMeta.Merge(m, src)
+func (m *TraceEvent_CircuitBreaker) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_CircuitBreaker.Merge(m, src)
 }
-func (m *TraceEvent_ControlMeta) XXX_Size() int {
+func (m *TraceEvent_CircuitBreaker) XXX_Size() int {
 	return m.Size()
 }
-func (m *TraceEvent_ControlMeta) XXX_DiscardUnknown() {
-	xxx_messageInfo_TraceEvent_ControlMeta.DiscardUnknown(m)
+func (m *TraceEvent_CircuitBreaker) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_CircuitBreaker.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_TraceEvent_ControlMeta proto.InternalMessageInfo
+var xxx_messageInfo_TraceEvent_CircuitBreaker proto.InternalMessageInfo
 
-func (m *TraceEvent_ControlMeta) GetIhave() []*TraceEvent_ControlIHaveMeta {
-	if m != nil {
-		return m.Ihave
+func (m *TraceEvent_CircuitBreaker) GetTopic() string {
+	if m != nil && m.Topic != nil {
+		return *m.Topic
 	}
-	return nil
+	return ""
 }
 
-func (m *TraceEvent_ControlMeta) GetIwant() []*TraceEvent_ControlIWantMeta {
-	if m != nil {
-		return m.Iwant
+func (m *TraceEvent_CircuitBreaker) GetState() TraceEvent_CircuitBreaker_State {
+	if m != nil && m.State != nil {
+		return *m.State
 	}
-	return nil
+	return TraceEvent_CircuitBreaker_CLOSED
 }
 
-func (m *TraceEvent_ControlMeta) GetGraft() []*TraceEvent_ControlGraftMeta {
+type TraceEvent_StaleMessage struct {
+	MessageID []byte  `protobuf:"bytes,1,opt,name=messageID" json:"messageID,omitempty"`
+	Topic     *string `protobuf:"bytes,2,opt,name=topic" json:"topic,omitempty"`
+	// the peer the message was not written to, if it went stale in the outbound queue of the
+	// peer; unset if the message was not forwarded at all
+	PeerID               []byte   `protobuf:"bytes,3,opt,name=peerID" json:"peerID,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TraceEvent_StaleMessage) Reset()         { *m = TraceEvent_StaleMessage{} }
+func (m *TraceEvent_StaleMessage) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_StaleMessage) ProtoMessage()    {}
+func (*TraceEvent_StaleMessage) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 17}
+}
+func (m *TraceEvent_StaleMessage) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceEvent_StaleMessage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceEvent_StaleMessage.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *TraceEvent_StaleMessage) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_StaleMessage.Merge(m, src)
+}
+func (m *TraceEvent_StaleMessage) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceEvent_StaleMessage) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_StaleMessage.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TraceEvent_StaleMessage proto.InternalMessageInfo
+
+func (m *TraceEvent_StaleMessage) GetMessageID() []byte {
 	if m != nil {
-		return m.Graft
+		return m.MessageID
 	}
 	return nil
 }
 
-func (m *TraceEvent_ControlMeta) GetPrune() []*TraceEvent_ControlPruneMeta {
+func (m *TraceEvent_StaleMessage) GetTopic() string {
+	if m != nil && m.Topic != nil {
+		return *m.Topic
+	}
+	return ""
+}
+
+func (m *TraceEvent_StaleMessage) GetPeerID() []byte {
 	if m != nil {
-		return m.Prune
+		return m.PeerID
 	}
 	return nil
 }
 
-type TraceEvent_ControlIHaveMeta struct {
-	Topic                *string  `protobuf:"bytes,1,opt,name=topic" json:"topic,omitempty"`
-	MessageIDs           [][]byte `protobuf:"bytes,2,rep,name=messageIDs" json:"messageIDs,omitempty"`
+type TraceEvent_SubscriptionChurn struct {
+	PeerID []byte  `protobuf:"bytes,1,opt,name=peerID" json:"peerID,omitempty"`
+	Topic  *string `protobuf:"bytes,2,opt,name=topic" json:"topic,omitempty"`
+	// the duration of the cool-down, in nanoseconds
+	Cooldown             *int64   `protobuf:"varint,3,opt,name=cooldown" json:"cooldown,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TraceEvent_ControlIHaveMeta) Reset()         { *m = TraceEvent_ControlIHaveMeta{} }
-func (m *TraceEvent_ControlIHaveMeta) String() string { return proto.CompactTextString(m) }
-func (*TraceEvent_ControlIHaveMeta) ProtoMessage()    {}
-func (*TraceEvent_ControlIHaveMeta) Descriptor() ([]byte, []int) {
-	return fileDescriptor_0571941a1d628a80, []int{0, 17}
+func (m *TraceEvent_SubscriptionChurn) Reset()         { *m = TraceEvent_SubscriptionChurn{} }
+func (m *TraceEvent_SubscriptionChurn) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_SubscriptionChurn) ProtoMessage()    {}
+func (*TraceEvent_SubscriptionChurn) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 18}
 }
-func (m *TraceEvent_ControlIHaveMeta) XXX_Unmarshal(b []byte) error {
+func (m *TraceEvent_SubscriptionChurn) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
-func (m *TraceEvent_ControlIHaveMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+func (m *TraceEvent_SubscriptionChurn) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	if deterministic {
-		return xxx_messageInfo_TraceEvent_ControlIHaveMeta.Marshal(b, m, deterministic)
+		return xxx_messageInfo_TraceEvent_SubscriptionChurn.Marshal(b, m, deterministic)
 	} else {
 		b = b[:cap(b)]
 		n, err := m.MarshalToSizedBuffer(b)
@@ -1258,51 +1595,60 @@ func (m *TraceEvent_ControlIHaveMeta) XXX_Marshal(b []byte, deterministic bool)
 		return b[:n], nil
 	}
 }
-func (m *TraceEvent_ControlIHaveMeta) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_TraceEvent_ControlIHaveMeta.Merge(m, src)
+func (m *TraceEvent_SubscriptionChurn) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_SubscriptionChurn.Merge(m, src)
 }
-func (m *TraceEvent_ControlIHaveMeta) XXX_Size() int {
+func (m *TraceEvent_SubscriptionChurn) XXX_Size() int {
 	return m.Size()
 }
-func (m *TraceEvent_ControlIHaveMeta) XXX_DiscardUnknown() {
-	xxx_messageInfo_TraceEvent_ControlIHaveMeta.DiscardUnknown(m)
+func (m *TraceEvent_SubscriptionChurn) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_SubscriptionChurn.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_TraceEvent_ControlIHaveMeta proto.InternalMessageInfo
+var xxx_messageInfo_TraceEvent_SubscriptionChurn proto.InternalMessageInfo
 
-func (m *TraceEvent_ControlIHaveMeta) GetTopic() string {
+func (m *TraceEvent_SubscriptionChurn) GetPeerID() []byte {
+	if m != nil {
+		return m.PeerID
+	}
+	return nil
+}
+
+func (m *TraceEvent_SubscriptionChurn) GetTopic() string {
 	if m != nil && m.Topic != nil {
 		return *m.Topic
 	}
 	return ""
 }
 
-func (m *TraceEvent_ControlIHaveMeta) GetMessageIDs() [][]byte {
-	if m != nil {
-		return m.MessageIDs
+func (m *TraceEvent_SubscriptionChurn) GetCooldown() int64 {
+	if m != nil && m.Cooldown != nil {
+		return *m.Cooldown
 	}
-	return nil
+	return 0
 }
 
-type TraceEvent_ControlIWantMeta struct {
-	MessageIDs           [][]byte `protobuf:"bytes,1,rep,name=messageIDs" json:"messageIDs,omitempty"`
+type TraceEvent_MalformedRPC struct {
+	PeerID []byte `protobuf:"bytes,1,opt,name=peerID" json:"peerID,omitempty"`
+	// the number of malformed entries dropped from the RPC
+	Entries              *uint32  `protobuf:"varint,2,opt,name=entries" json:"entries,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TraceEvent_ControlIWantMeta) Reset()         { *m = TraceEvent_ControlIWantMeta{} }
-func (m *TraceEvent_ControlIWantMeta) String() string { return proto.CompactTextString(m) }
-func (*TraceEvent_ControlIWantMeta) ProtoMessage()    {}
-func (*TraceEvent_ControlIWantMeta) Descriptor() ([]byte, []int) {
-	return fileDescriptor_0571941a1d628a80, []int{0, 18}
+func (m *TraceEvent_MalformedRPC) Reset()         { *m = TraceEvent_MalformedRPC{} }
+func (m *TraceEvent_MalformedRPC) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_MalformedRPC) ProtoMessage()    {}
+func (*TraceEvent_MalformedRPC) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 19}
 }
-func (m *TraceEvent_ControlIWantMeta) XXX_Unmarshal(b []byte) error {
+func (m *TraceEvent_MalformedRPC) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
-func (m *TraceEvent_ControlIWantMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+func (m *TraceEvent_MalformedRPC) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	if deterministic {
-		return xxx_messageInfo_TraceEvent_ControlIWantMeta.Marshal(b, m, deterministic)
+		return xxx_messageInfo_TraceEvent_MalformedRPC.Marshal(b, m, deterministic)
 	} else {
 		b = b[:cap(b)]
 		n, err := m.MarshalToSizedBuffer(b)
@@ -1312,44 +1658,52 @@ func (m *TraceEvent_ControlIWantMeta) XXX_Marshal(b []byte, deterministic bool)
 		return b[:n], nil
 	}
 }
-func (m *TraceEvent_ControlIWantMeta) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_TraceEvent_ControlIWantMeta.Merge(m, src)
+func (m *TraceEvent_MalformedRPC) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_MalformedRPC.Merge(m, src)
 }
-func (m *TraceEvent_ControlIWantMeta) XXX_Size() int {
+func (m *TraceEvent_MalformedRPC) XXX_Size() int {
 	return m.Size()
 }
-func (m *TraceEvent_ControlIWantMeta) XXX_DiscardUnknown() {
-	xxx_messageInfo_TraceEvent_ControlIWantMeta.DiscardUnknown(m)
+func (m *TraceEvent_MalformedRPC) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_MalformedRPC.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_TraceEvent_ControlIWantMeta proto.InternalMessageInfo
+var xxx_messageInfo_TraceEvent_MalformedRPC proto.InternalMessageInfo
 
-func (m *TraceEvent_ControlIWantMeta) GetMessageIDs() [][]byte {
+func (m *TraceEvent_MalformedRPC) GetPeerID() []byte {
 	if m != nil {
-		return m.MessageIDs
+		return m.PeerID
 	}
 	return nil
 }
 
-type TraceEvent_ControlGraftMeta struct {
-	Topic                *string  `protobuf:"bytes,1,opt,name=topic" json:"topic,omitempty"`
+func (m *TraceEvent_MalformedRPC) GetEntries() uint32 {
+	if m != nil && m.Entries != nil {
+		return *m.Entries
+	}
+	return 0
+}
+
+type TraceEvent_ReannounceSubscriptions struct {
+	PeerID               []byte   `protobuf:"bytes,1,opt,name=peerID" json:"peerID,omitempty"`
+	Topics               []string `protobuf:"bytes,2,rep,name=topics" json:"topics,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TraceEvent_ControlGraftMeta) Reset()         { *m = TraceEvent_ControlGraftMeta{} }
-func (m *TraceEvent_ControlGraftMeta) String() string { return proto.CompactTextString(m) }
-func (*TraceEvent_ControlGraftMeta) ProtoMessage()    {}
-func (*TraceEvent_ControlGraftMeta) Descriptor() ([]byte, []int) {
-	return fileDescriptor_0571941a1d628a80, []int{0, 19}
+func (m *TraceEvent_ReannounceSubscriptions) Reset()         { *m = TraceEvent_ReannounceSubscriptions{} }
+func (m *TraceEvent_ReannounceSubscriptions) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_ReannounceSubscriptions) ProtoMessage()    {}
+func (*TraceEvent_ReannounceSubscriptions) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 20}
 }
-func (m *TraceEvent_ControlGraftMeta) XXX_Unmarshal(b []byte) error {
+func (m *TraceEvent_ReannounceSubscriptions) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
-func (m *TraceEvent_ControlGraftMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+func (m *TraceEvent_ReannounceSubscriptions) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	if deterministic {
-		return xxx_messageInfo_TraceEvent_ControlGraftMeta.Marshal(b, m, deterministic)
+		return xxx_messageInfo_TraceEvent_ReannounceSubscriptions.Marshal(b, m, deterministic)
 	} else {
 		b = b[:cap(b)]
 		n, err := m.MarshalToSizedBuffer(b)
@@ -1359,45 +1713,55 @@ func (m *TraceEvent_ControlGraftMeta) XXX_Marshal(b []byte, deterministic bool)
 		return b[:n], nil
 	}
 }
-func (m *TraceEvent_ControlGraftMeta) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_TraceEvent_ControlGraftMeta.Merge(m, src)
+func (m *TraceEvent_ReannounceSubscriptions) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_ReannounceSubscriptions.Merge(m, src)
 }
-func (m *TraceEvent_ControlGraftMeta) XXX_Size() int {
+func (m *TraceEvent_ReannounceSubscriptions) XXX_Size() int {
 	return m.Size()
 }
-func (m *TraceEvent_ControlGraftMeta) XXX_DiscardUnknown() {
-	xxx_messageInfo_TraceEvent_ControlGraftMeta.DiscardUnknown(m)
+func (m *TraceEvent_ReannounceSubscriptions) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_ReannounceSubscriptions.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_TraceEvent_ControlGraftMeta proto.InternalMessageInfo
+var xxx_messageInfo_TraceEvent_ReannounceSubscriptions proto.InternalMessageInfo
 
-func (m *TraceEvent_ControlGraftMeta) GetTopic() string {
-	if m != nil && m.Topic != nil {
-		return *m.Topic
+func (m *TraceEvent_ReannounceSubscriptions) GetPeerID() []byte {
+	if m != nil {
+		return m.PeerID
 	}
-	return ""
+	return nil
 }
 
-type TraceEvent_ControlPruneMeta struct {
-	Topic                *string  `protobuf:"bytes,1,opt,name=topic" json:"topic,omitempty"`
-	Peers                [][]byte `protobuf:"bytes,2,rep,name=peers" json:"peers,omitempty"`
+func (m *TraceEvent_ReannounceSubscriptions) GetTopics() []string {
+	if m != nil {
+		return m.Topics
+	}
+	return nil
+}
+
+type TraceEvent_SlowConsumerThrottle struct {
+	Topic *string `protobuf:"bytes,1,opt,name=topic" json:"topic,omitempty"`
+	// whether the topic is throttled or restored
+	Throttled *bool `protobuf:"varint,2,opt,name=throttled" json:"throttled,omitempty"`
+	// the number of messages dropped by the subscriptions in the window
+	Dropped              *uint64  `protobuf:"varint,3,opt,name=dropped" json:"dropped,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TraceEvent_ControlPruneMeta) Reset()         { *m = TraceEvent_ControlPruneMeta{} }
-func (m *TraceEvent_ControlPruneMeta) String() string { return proto.CompactTextString(m) }
-func (*TraceEvent_ControlPruneMeta) ProtoMessage()    {}
-func (*TraceEvent_ControlPruneMeta) Descriptor() ([]byte, []int) {
-	return fileDescriptor_0571941a1d628a80, []int{0, 20}
+func (m *TraceEvent_SlowConsumerThrottle) Reset()         { *m = TraceEvent_SlowConsumerThrottle{} }
+func (m *TraceEvent_SlowConsumerThrottle) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_SlowConsumerThrottle) ProtoMessage()    {}
+func (*TraceEvent_SlowConsumerThrottle) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 21}
 }
-func (m *TraceEvent_ControlPruneMeta) XXX_Unmarshal(b []byte) error {
+func (m *TraceEvent_SlowConsumerThrottle) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
-func (m *TraceEvent_ControlPruneMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+func (m *TraceEvent_SlowConsumerThrottle) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	if deterministic {
-		return xxx_messageInfo_TraceEvent_ControlPruneMeta.Marshal(b, m, deterministic)
+		return xxx_messageInfo_TraceEvent_SlowConsumerThrottle.Marshal(b, m, deterministic)
 	} else {
 		b = b[:cap(b)]
 		n, err := m.MarshalToSizedBuffer(b)
@@ -1407,51 +1771,66 @@ func (m *TraceEvent_ControlPruneMeta) XXX_Marshal(b []byte, deterministic bool)
 		return b[:n], nil
 	}
 }
-func (m *TraceEvent_ControlPruneMeta) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_TraceEvent_ControlPruneMeta.Merge(m, src)
+func (m *TraceEvent_SlowConsumerThrottle) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_SlowConsumerThrottle.Merge(m, src)
 }
-func (m *TraceEvent_ControlPruneMeta) XXX_Size() int {
+func (m *TraceEvent_SlowConsumerThrottle) XXX_Size() int {
 	return m.Size()
 }
-func (m *TraceEvent_ControlPruneMeta) XXX_DiscardUnknown() {
-	xxx_messageInfo_TraceEvent_ControlPruneMeta.DiscardUnknown(m)
+func (m *TraceEvent_SlowConsumerThrottle) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_SlowConsumerThrottle.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_TraceEvent_ControlPruneMeta proto.InternalMessageInfo
+var xxx_messageInfo_TraceEvent_SlowConsumerThrottle proto.InternalMessageInfo
 
-func (m *TraceEvent_ControlPruneMeta) GetTopic() string {
+func (m *TraceEvent_SlowConsumerThrottle) GetTopic() string {
 	if m != nil && m.Topic != nil {
 		return *m.Topic
 	}
 	return ""
 }
 
-func (m *TraceEvent_ControlPruneMeta) GetPeers() [][]byte {
-	if m != nil {
-		return m.Peers
+func (m *TraceEvent_SlowConsumerThrottle) GetThrottled() bool {
+	if m != nil && m.Throttled != nil {
+		return *m.Throttled
 	}
-	return nil
+	return false
 }
 
-type TraceEventBatch struct {
-	Batch                []*TraceEvent `protobuf:"bytes,1,rep,name=batch" json:"batch,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
-	XXX_unrecognized     []byte        `json:"-"`
-	XXX_sizecache        int32         `json:"-"`
+func (m *TraceEvent_SlowConsumerThrottle) GetDropped() uint64 {
+	if m != nil && m.Dropped != nil {
+		return *m.Dropped
+	}
+	return 0
 }
 
-func (m *TraceEventBatch) Reset()         { *m = TraceEventBatch{} }
-func (m *TraceEventBatch) String() string { return proto.CompactTextString(m) }
-func (*TraceEventBatch) ProtoMessage()    {}
-func (*TraceEventBatch) Descriptor() ([]byte, []int) {
-	return fileDescriptor_0571941a1d628a80, []int{1}
+type TraceEvent_TopicConfig struct {
+	Topic *string `protobuf:"bytes,1,opt,name=topic" json:"topic,omitempty"`
+	// whether the score parameters of the topic were set
+	ScoreParams *bool `protobuf:"varint,2,opt,name=scoreParams" json:"scoreParams,omitempty"`
+	// the mesh degree override, if any
+	D   *uint32 `protobuf:"varint,3,opt,name=d" json:"d,omitempty"`
+	Dlo *uint32 `protobuf:"varint,4,opt,name=dlo" json:"dlo,omitempty"`
+	Dhi *uint32 `protobuf:"varint,5,opt,name=dhi" json:"dhi,omitempty"`
+	// the flood publishing override, if any
+	FloodPublish         *bool    `protobuf:"varint,6,opt,name=floodPublish" json:"floodPublish,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
-func (m *TraceEventBatch) XXX_Unmarshal(b []byte) error {
+
+func (m *TraceEvent_TopicConfig) Reset()         { *m = TraceEvent_TopicConfig{} }
+func (m *TraceEvent_TopicConfig) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_TopicConfig) ProtoMessage()    {}
+func (*TraceEvent_TopicConfig) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 22}
+}
+func (m *TraceEvent_TopicConfig) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
-func (m *TraceEventBatch) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+func (m *TraceEvent_TopicConfig) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	if deterministic {
-		return xxx_messageInfo_TraceEventBatch.Marshal(b, m, deterministic)
+		return xxx_messageInfo_TraceEvent_TopicConfig.Marshal(b, m, deterministic)
 	} else {
 		b = b[:cap(b)]
 		n, err := m.MarshalToSizedBuffer(b)
@@ -1461,1031 +1840,968 @@ func (m *TraceEventBatch) XXX_Marshal(b []byte, deterministic bool) ([]byte, err
 		return b[:n], nil
 	}
 }
-func (m *TraceEventBatch) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_TraceEventBatch.Merge(m, src)
+func (m *TraceEvent_TopicConfig) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_TopicConfig.Merge(m, src)
 }
-func (m *TraceEventBatch) XXX_Size() int {
+func (m *TraceEvent_TopicConfig) XXX_Size() int {
 	return m.Size()
 }
-func (m *TraceEventBatch) XXX_DiscardUnknown() {
-	xxx_messageInfo_TraceEventBatch.DiscardUnknown(m)
+func (m *TraceEvent_TopicConfig) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_TopicConfig.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_TraceEventBatch proto.InternalMessageInfo
+var xxx_messageInfo_TraceEvent_TopicConfig proto.InternalMessageInfo
 
-func (m *TraceEventBatch) GetBatch() []*TraceEvent {
-	if m != nil {
-		return m.Batch
+func (m *TraceEvent_TopicConfig) GetTopic() string {
+	if m != nil && m.Topic != nil {
+		return *m.Topic
 	}
-	return nil
+	return ""
 }
 
-func init() {
-	proto.RegisterEnum("pubsub.pb.TraceEvent_Type", TraceEvent_Type_name, TraceEvent_Type_value)
-	proto.RegisterType((*TraceEvent)(nil), "pubsub.pb.TraceEvent")
-	proto.RegisterType((*TraceEvent_PublishMessage)(nil), "pubsub.pb.TraceEvent.PublishMessage")
-	proto.RegisterType((*TraceEvent_RejectMessage)(nil), "pubsub.pb.TraceEvent.RejectMessage")
-	proto.RegisterType((*TraceEvent_DuplicateMessage)(nil), "pubsub.pb.TraceEvent.DuplicateMessage")
-	proto.RegisterType((*TraceEvent_DeliverMessage)(nil), "pubsub.pb.TraceEvent.DeliverMessage")
-	proto.RegisterType((*TraceEvent_AddPeer)(nil), "pubsub.pb.TraceEvent.AddPeer")
-	proto.RegisterType((*TraceEvent_RemovePeer)(nil), "pubsub.pb.TraceEvent.RemovePeer")
-	proto.RegisterType((*TraceEvent_RecvRPC)(nil), "pubsub.pb.TraceEvent.RecvRPC")
-	proto.RegisterType((*TraceEvent_SendRPC)(nil), "pubsub.pb.TraceEvent.SendRPC")
-	proto.RegisterType((*TraceEvent_DropRPC)(nil), "pubsub.pb.TraceEvent.DropRPC")
-	proto.RegisterType((*TraceEvent_Join)(nil), "pubsub.pb.TraceEvent.Join")
-	proto.RegisterType((*TraceEvent_Leave)(nil), "pubsub.pb.TraceEvent.Leave")
-	proto.RegisterType((*TraceEvent_Graft)(nil), "pubsub.pb.TraceEvent.Graft")
-	proto.RegisterType((*TraceEvent_Prune)(nil), "pubsub.pb.TraceEvent.Prune")
-	proto.RegisterType((*TraceEvent_RPCMeta)(nil), "pubsub.pb.TraceEvent.RPCMeta")
-	proto.RegisterType((*TraceEvent_MessageMeta)(nil), "pubsub.pb.TraceEvent.MessageMeta")
-	proto.RegisterType((*TraceEvent_SubMeta)(nil), "pubsub.pb.TraceEvent.SubMeta")
-	proto.RegisterType((*TraceEvent_ControlMeta)(nil), "pubsub.pb.TraceEvent.ControlMeta")
-	proto.RegisterType((*TraceEvent_ControlIHaveMeta)(nil), "pubsub.pb.TraceEvent.ControlIHaveMeta")
-	proto.RegisterType((*TraceEvent_ControlIWantMeta)(nil), "pubsub.pb.TraceEvent.ControlIWantMeta")
-	proto.RegisterType((*TraceEvent_ControlGraftMeta)(nil), "pubsub.pb.TraceEvent.ControlGraftMeta")
-	proto.RegisterType((*TraceEvent_ControlPruneMeta)(nil), "pubsub.pb.TraceEvent.ControlPruneMeta")
-	proto.RegisterType((*TraceEventBatch)(nil), "pubsub.pb.TraceEventBatch")
+func (m *TraceEvent_TopicConfig) GetScoreParams() bool {
+	if m != nil && m.ScoreParams != nil {
+		return *m.ScoreParams
+	}
+	return false
 }
 
-func init() { proto.RegisterFile("trace.proto", fileDescriptor_0571941a1d628a80) }
-
-var fileDescriptor_0571941a1d628a80 = []byte{
-	// 999 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x96, 0x51, 0x6f, 0xda, 0x56,
-	0x14, 0xc7, 0xe7, 0x00, 0x01, 0x0e, 0x84, 0x78, 0x77, 0x6d, 0x65, 0xb1, 0x36, 0x62, 0x59, 0x55,
-	0x21, 0x4d, 0x42, 0x6a, 0xa4, 0xa9, 0x0f, 0x6b, 0xab, 0x11, 0xec, 0x26, 0x44, 0x24, 0xb1, 0x0e,
-	0x24, 0x7b, 0xcc, 0x0c, 0xdc, 0x35, 0x8e, 0xc0, 0xb6, 0xec, 0x0b, 0x53, 0x9f, 0xf6, 0xb4, 0xef,
-	0xd6, 0xb7, 0xed, 0x23, 0x54, 0xf9, 0x24, 0xd3, 0xbd, 0xd7, 0x36, 0x36, 0xd8, 0xb4, 0x8b, 0xfa,
-	0xe6, 0x73, 0xf3, 0xff, 0x9d, 0x7b, 0xce, 0xbd, 0xe7, 0x7f, 0x03, 0xd4, 0x98, 0x6f, 0x4d, 0x68,
-	0xc7, 0xf3, 0x5d, 0xe6, 0x92, 0xaa, 0xb7, 0x18, 0x07, 0x8b, 0x71, 0xc7, 0x1b, 0x1f, 0x7e, 0x7a,
-	0x02, 0x30, 0xe2, 0x7f, 0x32, 0x96, 0xd4, 0x61, 0xa4, 0x03, 0x45, 0xf6, 0xc1, 0xa3, 0x9a, 0xd2,
-	0x52, 0xda, 0x8d, 0xa3, 0x66, 0x27, 0x16, 0x76, 0x56, 0xa2, 0xce, 0xe8, 0x83, 0x47, 0x51, 0xe8,
-	0xc8, 0x13, 0xd8, 0xf5, 0x28, 0xf5, 0xfb, 0xba, 0xb6, 0xd3, 0x52, 0xda, 0x75, 0x0c, 0x23, 0xf2,
-	0x14, 0xaa, 0xcc, 0x9e, 0xd3, 0x80, 0x59, 0x73, 0x4f, 0x2b, 0xb4, 0x94, 0x76, 0x01, 0x57, 0x0b,
-	0x64, 0x00, 0x0d, 0x6f, 0x31, 0x9e, 0xd9, 0xc1, 0xed, 0x39, 0x0d, 0x02, 0xeb, 0x3d, 0xd5, 0x8a,
-	0x2d, 0xa5, 0x5d, 0x3b, 0x7a, 0x9e, 0xbd, 0x9f, 0x99, 0xd2, 0xe2, 0x1a, 0x4b, 0xfa, 0xb0, 0xe7,
-	0xd3, 0x3b, 0x3a, 0x61, 0x51, 0xb2, 0x92, 0x48, 0xf6, 0x63, 0x76, 0x32, 0x4c, 0x4a, 0x31, 0x4d,
-	0x12, 0x04, 0x75, 0xba, 0xf0, 0x66, 0xf6, 0xc4, 0x62, 0x34, 0xca, 0xb6, 0x2b, 0xb2, 0xbd, 0xc8,
-	0xce, 0xa6, 0xaf, 0xa9, 0x71, 0x83, 0xe7, 0xcd, 0x4e, 0xe9, 0xcc, 0x5e, 0x52, 0x3f, 0xca, 0x58,
-	0xde, 0xd6, 0xac, 0x9e, 0xd2, 0xe2, 0x1a, 0x4b, 0x5e, 0x41, 0xd9, 0x9a, 0x4e, 0x4d, 0x4a, 0x7d,
-	0xad, 0x22, 0xd2, 0x3c, 0xcb, 0x4e, 0xd3, 0x95, 0x22, 0x8c, 0xd4, 0xe4, 0x57, 0x00, 0x9f, 0xce,
-	0xdd, 0x25, 0x15, 0x6c, 0x55, 0xb0, 0xad, 0xbc, 0x23, 0x8a, 0x74, 0x98, 0x60, 0xf8, 0xd6, 0x3e,
-	0x9d, 0x2c, 0xd1, 0xec, 0x69, 0xb0, 0x6d, 0x6b, 0x94, 0x22, 0x8c, 0xd4, 0x1c, 0x0c, 0xa8, 0x33,
-	0xe5, 0x60, 0x6d, 0x1b, 0x38, 0x94, 0x22, 0x8c, 0xd4, 0x1c, 0x9c, 0xfa, 0xae, 0xc7, 0xc1, 0xfa,
-	0x36, 0x50, 0x97, 0x22, 0x8c, 0xd4, 0x7c, 0x8c, 0xef, 0x5c, 0xdb, 0xd1, 0xf6, 0x04, 0x95, 0x33,
-	0xc6, 0x67, 0xae, 0xed, 0xa0, 0xd0, 0x91, 0x97, 0x50, 0x9a, 0x51, 0x6b, 0x49, 0xb5, 0x86, 0x00,
-	0xbe, 0xcf, 0x06, 0x06, 0x5c, 0x82, 0x52, 0xc9, 0x91, 0xf7, 0xbe, 0xf5, 0x07, 0xd3, 0xf6, 0xb7,
-	0x21, 0x27, 0x5c, 0x82, 0x52, 0xc9, 0x11, 0xcf, 0x5f, 0x38, 0x54, 0x53, 0xb7, 0x21, 0x26, 0x97,
-	0xa0, 0x54, 0x36, 0x75, 0x68, 0xa4, 0xa7, 0x9f, 0x3b, 0x6b, 0x2e, 0x3f, 0xfb, 0xba, 0xb0, 0x69,
-	0x1d, 0x57, 0x0b, 0xe4, 0x11, 0x94, 0x98, 0xeb, 0xd9, 0x13, 0x61, 0xc7, 0x2a, 0xca, 0xa0, 0xf9,
-	0x17, 0xec, 0xa5, 0xc6, 0xfe, 0x33, 0x49, 0x0e, 0xa1, 0xee, 0xd3, 0x09, 0xb5, 0x97, 0x74, 0xfa,
-	0xce, 0x77, 0xe7, 0xa1, 0xb5, 0x53, 0x6b, 0xdc, 0xf8, 0x3e, 0xb5, 0x02, 0xd7, 0x11, 0xee, 0xae,
-	0x62, 0x18, 0xad, 0x0a, 0x28, 0x26, 0x0b, 0xb8, 0x03, 0x75, 0xdd, 0x29, 0x5f, 0xa1, 0x86, 0x78,
-	0xaf, 0x42, 0x72, 0xaf, 0x5b, 0x68, 0xa4, 0x3d, 0xf4, 0x90, 0x23, 0xdb, 0xd8, 0xbf, 0xb0, 0xb9,
-	0x7f, 0xf3, 0x15, 0x94, 0x43, 0x9b, 0x25, 0xde, 0x41, 0x25, 0xf5, 0x0e, 0x3e, 0xe2, 0x57, 0xee,
-	0x32, 0x37, 0x4a, 0x2e, 0x82, 0xe6, 0x73, 0x80, 0x95, 0xc7, 0xf2, 0xd8, 0xe6, 0xef, 0x50, 0x0e,
-	0xad, 0xb4, 0x51, 0x8d, 0x92, 0x71, 0x1a, 0x2f, 0xa1, 0x38, 0xa7, 0xcc, 0x12, 0x3b, 0xe5, 0x7b,
-	0xd3, 0xec, 0x9d, 0x53, 0x66, 0xa1, 0x90, 0x36, 0x47, 0x50, 0x0e, 0x3d, 0xc7, 0x8b, 0xe0, 0xae,
-	0x1b, 0xb9, 0x51, 0x11, 0x32, 0x7a, 0x60, 0xd6, 0xd0, 0x90, 0x5f, 0x33, 0xeb, 0x53, 0x28, 0x72,
-	0xc3, 0xae, 0xae, 0x4b, 0x49, 0x5e, 0xfa, 0x33, 0x28, 0x09, 0x77, 0xe6, 0x18, 0xe0, 0x67, 0x28,
-	0x09, 0x27, 0x6e, 0xbb, 0xa7, 0x6c, 0x4c, 0xb8, 0xf1, 0x7f, 0x62, 0x1f, 0x15, 0x28, 0x87, 0xc5,
-	0x93, 0x37, 0x50, 0x09, 0x47, 0x2d, 0xd0, 0x94, 0x56, 0xa1, 0x5d, 0x3b, 0xfa, 0x21, 0xbb, 0xdb,
-	0x70, 0x58, 0x45, 0xc7, 0x31, 0x42, 0xba, 0x50, 0x0f, 0x16, 0xe3, 0x60, 0xe2, 0xdb, 0x1e, 0xb3,
-	0x5d, 0x47, 0xdb, 0x11, 0x29, 0xf2, 0xde, 0xcf, 0xc5, 0x58, 0xe0, 0x29, 0x84, 0xfc, 0x02, 0xe5,
-	0x89, 0xeb, 0x30, 0xdf, 0x9d, 0x89, 0x21, 0xce, 0x2d, 0xa0, 0x27, 0x45, 0x22, 0x43, 0x44, 0x34,
-	0xbb, 0x50, 0x4b, 0x14, 0xf6, 0xa0, 0xc7, 0xe7, 0x0d, 0x94, 0xc3, 0xc2, 0x38, 0x1e, 0x96, 0x36,
-	0x96, 0x3f, 0x31, 0x2a, 0xb8, 0x5a, 0xc8, 0xc1, 0xff, 0xde, 0x81, 0x5a, 0xa2, 0x34, 0xf2, 0x1a,
-	0x4a, 0xf6, 0x2d, 0x7f, 0xaa, 0xe5, 0x69, 0xbe, 0xd8, 0xda, 0x4c, 0xff, 0xd4, 0x5a, 0xca, 0x23,
-	0x95, 0x90, 0xa0, 0xff, 0xb4, 0x1c, 0x16, 0x1e, 0xe4, 0x67, 0xe8, 0xdf, 0x2c, 0x87, 0x85, 0x34,
-	0x87, 0x38, 0x2d, 0xdf, 0xfc, 0xc2, 0x17, 0xd0, 0x62, 0xe0, 0x24, 0x2d, 0x9f, 0xff, 0xd7, 0xd1,
-	0xf3, 0x5f, 0xfc, 0x02, 0x5a, 0xcc, 0x9d, 0xa4, 0xe5, 0x7f, 0x82, 0x53, 0x50, 0xd7, 0x9b, 0xca,
-	0xf6, 0x02, 0x39, 0x00, 0x88, 0xef, 0x24, 0x10, 0x8d, 0xd6, 0x31, 0xb1, 0xd2, 0x3c, 0x5a, 0x65,
-	0x8a, 0x1a, 0x5c, 0x63, 0x94, 0x0d, 0xa6, 0x1d, 0x33, 0x71, 0x5b, 0x39, 0x4e, 0x7c, 0x1b, 0x2b,
-	0xe3, 0x16, 0x72, 0xea, 0xe4, 0x6f, 0x23, 0xa5, 0x7e, 0x54, 0xa2, 0x0c, 0x0e, 0xff, 0x51, 0xa0,
-	0xc8, 0x7f, 0x60, 0x92, 0xef, 0x60, 0xdf, 0xbc, 0x3a, 0x1e, 0xf4, 0x87, 0xa7, 0x37, 0xe7, 0xc6,
-	0x70, 0xd8, 0x3d, 0x31, 0xd4, 0x6f, 0x08, 0x81, 0x06, 0x1a, 0x67, 0x46, 0x6f, 0x14, 0xaf, 0x29,
-	0xe4, 0x31, 0x7c, 0xab, 0x5f, 0x99, 0x83, 0x7e, 0xaf, 0x3b, 0x32, 0xe2, 0xe5, 0x1d, 0xce, 0xeb,
-	0xc6, 0xa0, 0x7f, 0x6d, 0x60, 0xbc, 0x58, 0x20, 0x75, 0xa8, 0x74, 0x75, 0xfd, 0xc6, 0x34, 0x0c,
-	0x54, 0x8b, 0x64, 0x1f, 0x6a, 0x68, 0x9c, 0x5f, 0x5e, 0x1b, 0x72, 0xa1, 0xc4, 0xff, 0x8c, 0x46,
-	0xef, 0xfa, 0x06, 0xcd, 0x9e, 0xba, 0xcb, 0xa3, 0xa1, 0x71, 0xa1, 0x8b, 0xa8, 0xcc, 0x23, 0x1d,
-	0x2f, 0x4d, 0x11, 0x55, 0x48, 0x05, 0x8a, 0x67, 0x97, 0xfd, 0x0b, 0xb5, 0x4a, 0xaa, 0x50, 0x1a,
-	0x18, 0xdd, 0x6b, 0x43, 0x05, 0xfe, 0x79, 0x82, 0xdd, 0x77, 0x23, 0xb5, 0xc6, 0x3f, 0x4d, 0xbc,
-	0xba, 0x30, 0xd4, 0xfa, 0xe1, 0x5b, 0xd8, 0x5f, 0xdd, 0xef, 0xb1, 0xc5, 0x26, 0xb7, 0xe4, 0x27,
-	0x28, 0x8d, 0xf9, 0x47, 0x38, 0xc4, 0x8f, 0x33, 0x47, 0x01, 0xa5, 0xe6, 0xb8, 0xfe, 0xf1, 0xfe,
-	0x40, 0xf9, 0xf7, 0xfe, 0x40, 0xf9, 0x74, 0x7f, 0xa0, 0xfc, 0x17, 0x00, 0x00, 0xff, 0xff, 0xdb,
-	0x3a, 0x1c, 0xe4, 0xc9, 0x0b, 0x00, 0x00,
+func (m *TraceEvent_TopicConfig) GetD() uint32 {
+	if m != nil && m.D != nil {
+		return *m.D
+	}
+	return 0
 }
 
-func (m *TraceEvent) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceEvent_TopicConfig) GetDlo() uint32 {
+	if m != nil && m.Dlo != nil {
+		return *m.Dlo
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *TraceEvent) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func (m *TraceEvent_TopicConfig) GetDhi() uint32 {
+	if m != nil && m.Dhi != nil {
+		return *m.Dhi
+	}
+	return 0
 }
 
-func (m *TraceEvent) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.Prune != nil {
-		{
-			size, err := m.Prune.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
-		}
-		i--
-		dAtA[i] = 0x1
-		i--
-		dAtA[i] = 0x82
+func (m *TraceEvent_TopicConfig) GetFloodPublish() bool {
+	if m != nil && m.FloodPublish != nil {
+		return *m.FloodPublish
 	}
-	if m.Graft != nil {
-		{
-			size, err := m.Graft.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
-		}
-		i--
-		dAtA[i] = 0x7a
-	}
-	if m.Leave != nil {
-		{
-			size, err := m.Leave.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
-		}
-		i--
-		dAtA[i] = 0x72
-	}
-	if m.Join != nil {
-		{
-			size, err := m.Join.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
-		}
-		i--
-		dAtA[i] = 0x6a
-	}
-	if m.DropRPC != nil {
-		{
-			size, err := m.DropRPC.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
-		}
-		i--
-		dAtA[i] = 0x62
-	}
-	if m.SendRPC != nil {
-		{
-			size, err := m.SendRPC.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
-		}
-		i--
-		dAtA[i] = 0x5a
-	}
-	if m.RecvRPC != nil {
-		{
-			size, err := m.RecvRPC.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
-		}
-		i--
-		dAtA[i] = 0x52
-	}
-	if m.RemovePeer != nil {
-		{
-			size, err := m.RemovePeer.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
-		}
-		i--
-		dAtA[i] = 0x4a
-	}
-	if m.AddPeer != nil {
-		{
-			size, err := m.AddPeer.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
-		}
-		i--
-		dAtA[i] = 0x42
-	}
-	if m.DeliverMessage != nil {
-		{
-			size, err := m.DeliverMessage.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
-		}
-		i--
-		dAtA[i] = 0x3a
-	}
-	if m.DuplicateMessage != nil {
-		{
-			size, err := m.DuplicateMessage.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
-		}
-		i--
-		dAtA[i] = 0x32
-	}
-	if m.RejectMessage != nil {
-		{
-			size, err := m.RejectMessage.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
-		}
-		i--
-		dAtA[i] = 0x2a
-	}
-	if m.PublishMessage != nil {
-		{
-			size, err := m.PublishMessage.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
+	return false
+}
+
+type TraceEvent_PeerSubscribe struct {
+	PeerID               []byte   `protobuf:"bytes,1,opt,name=peerID" json:"peerID,omitempty"`
+	Topic                *string  `protobuf:"bytes,2,opt,name=topic" json:"topic,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TraceEvent_PeerSubscribe) Reset()         { *m = TraceEvent_PeerSubscribe{} }
+func (m *TraceEvent_PeerSubscribe) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_PeerSubscribe) ProtoMessage()    {}
+func (*TraceEvent_PeerSubscribe) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 23}
+}
+func (m *TraceEvent_PeerSubscribe) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceEvent_PeerSubscribe) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceEvent_PeerSubscribe.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
 		}
-		i--
-		dAtA[i] = 0x22
-	}
-	if m.Timestamp != nil {
-		i = encodeVarintTrace(dAtA, i, uint64(*m.Timestamp))
-		i--
-		dAtA[i] = 0x18
-	}
-	if m.PeerID != nil {
-		i -= len(m.PeerID)
-		copy(dAtA[i:], m.PeerID)
-		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
-		i--
-		dAtA[i] = 0x12
+		return b[:n], nil
 	}
-	if m.Type != nil {
-		i = encodeVarintTrace(dAtA, i, uint64(*m.Type))
-		i--
-		dAtA[i] = 0x8
+}
+func (m *TraceEvent_PeerSubscribe) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_PeerSubscribe.Merge(m, src)
+}
+func (m *TraceEvent_PeerSubscribe) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceEvent_PeerSubscribe) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_PeerSubscribe.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TraceEvent_PeerSubscribe proto.InternalMessageInfo
+
+func (m *TraceEvent_PeerSubscribe) GetPeerID() []byte {
+	if m != nil {
+		return m.PeerID
 	}
-	return len(dAtA) - i, nil
+	return nil
 }
 
-func (m *TraceEvent_PublishMessage) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceEvent_PeerSubscribe) GetTopic() string {
+	if m != nil && m.Topic != nil {
+		return *m.Topic
 	}
-	return dAtA[:n], nil
+	return ""
 }
 
-func (m *TraceEvent_PublishMessage) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type TraceEvent_PeerUnsubscribe struct {
+	PeerID               []byte   `protobuf:"bytes,1,opt,name=peerID" json:"peerID,omitempty"`
+	Topic                *string  `protobuf:"bytes,2,opt,name=topic" json:"topic,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TraceEvent_PublishMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.Topic != nil {
-		i -= len(*m.Topic)
-		copy(dAtA[i:], *m.Topic)
-		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
-		i--
-		dAtA[i] = 0x12
+func (m *TraceEvent_PeerUnsubscribe) Reset()         { *m = TraceEvent_PeerUnsubscribe{} }
+func (m *TraceEvent_PeerUnsubscribe) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_PeerUnsubscribe) ProtoMessage()    {}
+func (*TraceEvent_PeerUnsubscribe) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 24}
+}
+func (m *TraceEvent_PeerUnsubscribe) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceEvent_PeerUnsubscribe) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceEvent_PeerUnsubscribe.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	if m.MessageID != nil {
-		i -= len(m.MessageID)
-		copy(dAtA[i:], m.MessageID)
-		i = encodeVarintTrace(dAtA, i, uint64(len(m.MessageID)))
-		i--
-		dAtA[i] = 0xa
+}
+func (m *TraceEvent_PeerUnsubscribe) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_PeerUnsubscribe.Merge(m, src)
+}
+func (m *TraceEvent_PeerUnsubscribe) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceEvent_PeerUnsubscribe) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_PeerUnsubscribe.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TraceEvent_PeerUnsubscribe proto.InternalMessageInfo
+
+func (m *TraceEvent_PeerUnsubscribe) GetPeerID() []byte {
+	if m != nil {
+		return m.PeerID
 	}
-	return len(dAtA) - i, nil
+	return nil
 }
 
-func (m *TraceEvent_RejectMessage) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceEvent_PeerUnsubscribe) GetTopic() string {
+	if m != nil && m.Topic != nil {
+		return *m.Topic
 	}
-	return dAtA[:n], nil
+	return ""
 }
 
-func (m *TraceEvent_RejectMessage) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type TraceEvent_IWantMiss struct {
+	PeerID               []byte   `protobuf:"bytes,1,opt,name=peerID" json:"peerID,omitempty"`
+	MessageID            []byte   `protobuf:"bytes,2,opt,name=messageID" json:"messageID,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TraceEvent_RejectMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.Topic != nil {
-		i -= len(*m.Topic)
-		copy(dAtA[i:], *m.Topic)
-		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
-		i--
-		dAtA[i] = 0x22
-	}
-	if m.Reason != nil {
-		i -= len(*m.Reason)
-		copy(dAtA[i:], *m.Reason)
-		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Reason)))
-		i--
-		dAtA[i] = 0x1a
-	}
-	if m.ReceivedFrom != nil {
-		i -= len(m.ReceivedFrom)
-		copy(dAtA[i:], m.ReceivedFrom)
-		i = encodeVarintTrace(dAtA, i, uint64(len(m.ReceivedFrom)))
-		i--
-		dAtA[i] = 0x12
-	}
-	if m.MessageID != nil {
-		i -= len(m.MessageID)
-		copy(dAtA[i:], m.MessageID)
-		i = encodeVarintTrace(dAtA, i, uint64(len(m.MessageID)))
-		i--
-		dAtA[i] = 0xa
-	}
-	return len(dAtA) - i, nil
+func (m *TraceEvent_IWantMiss) Reset()         { *m = TraceEvent_IWantMiss{} }
+func (m *TraceEvent_IWantMiss) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_IWantMiss) ProtoMessage()    {}
+func (*TraceEvent_IWantMiss) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 25}
 }
-
-func (m *TraceEvent_DuplicateMessage) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceEvent_IWantMiss) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceEvent_IWantMiss) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceEvent_IWantMiss.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return dAtA[:n], nil
 }
-
-func (m *TraceEvent_DuplicateMessage) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func (m *TraceEvent_IWantMiss) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_IWantMiss.Merge(m, src)
+}
+func (m *TraceEvent_IWantMiss) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceEvent_IWantMiss) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_IWantMiss.DiscardUnknown(m)
 }
 
-func (m *TraceEvent_DuplicateMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.Topic != nil {
-		i -= len(*m.Topic)
-		copy(dAtA[i:], *m.Topic)
-		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
-		i--
-		dAtA[i] = 0x1a
-	}
-	if m.ReceivedFrom != nil {
-		i -= len(m.ReceivedFrom)
-		copy(dAtA[i:], m.ReceivedFrom)
-		i = encodeVarintTrace(dAtA, i, uint64(len(m.ReceivedFrom)))
-		i--
-		dAtA[i] = 0x12
-	}
-	if m.MessageID != nil {
-		i -= len(m.MessageID)
-		copy(dAtA[i:], m.MessageID)
-		i = encodeVarintTrace(dAtA, i, uint64(len(m.MessageID)))
-		i--
-		dAtA[i] = 0xa
+var xxx_messageInfo_TraceEvent_IWantMiss proto.InternalMessageInfo
+
+func (m *TraceEvent_IWantMiss) GetPeerID() []byte {
+	if m != nil {
+		return m.PeerID
 	}
-	return len(dAtA) - i, nil
+	return nil
 }
 
-func (m *TraceEvent_DeliverMessage) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceEvent_IWantMiss) GetMessageID() []byte {
+	if m != nil {
+		return m.MessageID
 	}
-	return dAtA[:n], nil
+	return nil
 }
 
-func (m *TraceEvent_DeliverMessage) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type TraceEvent_RPCMeta struct {
+	Messages             []*TraceEvent_MessageMeta `protobuf:"bytes,1,rep,name=messages" json:"messages,omitempty"`
+	Subscription         []*TraceEvent_SubMeta     `protobuf:"bytes,2,rep,name=subscription" json:"subscription,omitempty"`
+	Control              *TraceEvent_ControlMeta   `protobuf:"bytes,3,opt,name=control" json:"control,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                  `json:"-"`
+	XXX_unrecognized     []byte                    `json:"-"`
+	XXX_sizecache        int32                     `json:"-"`
 }
 
-func (m *TraceEvent_DeliverMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.ReceivedFrom != nil {
-		i -= len(m.ReceivedFrom)
-		copy(dAtA[i:], m.ReceivedFrom)
-		i = encodeVarintTrace(dAtA, i, uint64(len(m.ReceivedFrom)))
-		i--
-		dAtA[i] = 0x1a
-	}
-	if m.Topic != nil {
-		i -= len(*m.Topic)
-		copy(dAtA[i:], *m.Topic)
-		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
-		i--
-		dAtA[i] = 0x12
-	}
-	if m.MessageID != nil {
-		i -= len(m.MessageID)
-		copy(dAtA[i:], m.MessageID)
-		i = encodeVarintTrace(dAtA, i, uint64(len(m.MessageID)))
-		i--
-		dAtA[i] = 0xa
-	}
-	return len(dAtA) - i, nil
+func (m *TraceEvent_RPCMeta) Reset()         { *m = TraceEvent_RPCMeta{} }
+func (m *TraceEvent_RPCMeta) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_RPCMeta) ProtoMessage()    {}
+func (*TraceEvent_RPCMeta) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 26}
 }
-
-func (m *TraceEvent_AddPeer) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceEvent_RPCMeta) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceEvent_RPCMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceEvent_RPCMeta.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return dAtA[:n], nil
 }
-
-func (m *TraceEvent_AddPeer) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func (m *TraceEvent_RPCMeta) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_RPCMeta.Merge(m, src)
+}
+func (m *TraceEvent_RPCMeta) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceEvent_RPCMeta) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_RPCMeta.DiscardUnknown(m)
 }
 
-func (m *TraceEvent_AddPeer) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.Proto != nil {
-		i -= len(*m.Proto)
-		copy(dAtA[i:], *m.Proto)
-		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Proto)))
-		i--
-		dAtA[i] = 0x12
+var xxx_messageInfo_TraceEvent_RPCMeta proto.InternalMessageInfo
+
+func (m *TraceEvent_RPCMeta) GetMessages() []*TraceEvent_MessageMeta {
+	if m != nil {
+		return m.Messages
 	}
-	if m.PeerID != nil {
-		i -= len(m.PeerID)
-		copy(dAtA[i:], m.PeerID)
-		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
-		i--
-		dAtA[i] = 0xa
+	return nil
+}
+
+func (m *TraceEvent_RPCMeta) GetSubscription() []*TraceEvent_SubMeta {
+	if m != nil {
+		return m.Subscription
 	}
-	return len(dAtA) - i, nil
+	return nil
 }
 
-func (m *TraceEvent_RemovePeer) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceEvent_RPCMeta) GetControl() *TraceEvent_ControlMeta {
+	if m != nil {
+		return m.Control
 	}
-	return dAtA[:n], nil
+	return nil
 }
 
-func (m *TraceEvent_RemovePeer) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type TraceEvent_MessageMeta struct {
+	MessageID            []byte   `protobuf:"bytes,1,opt,name=messageID" json:"messageID,omitempty"`
+	Topic                *string  `protobuf:"bytes,2,opt,name=topic" json:"topic,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TraceEvent_RemovePeer) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.PeerID != nil {
-		i -= len(m.PeerID)
-		copy(dAtA[i:], m.PeerID)
-		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
-		i--
-		dAtA[i] = 0xa
-	}
-	return len(dAtA) - i, nil
+func (m *TraceEvent_MessageMeta) Reset()         { *m = TraceEvent_MessageMeta{} }
+func (m *TraceEvent_MessageMeta) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_MessageMeta) ProtoMessage()    {}
+func (*TraceEvent_MessageMeta) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 27}
 }
-
-func (m *TraceEvent_RecvRPC) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceEvent_MessageMeta) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceEvent_MessageMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceEvent_MessageMeta.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return dAtA[:n], nil
 }
-
-func (m *TraceEvent_RecvRPC) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func (m *TraceEvent_MessageMeta) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_MessageMeta.Merge(m, src)
+}
+func (m *TraceEvent_MessageMeta) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceEvent_MessageMeta) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_MessageMeta.DiscardUnknown(m)
 }
 
-func (m *TraceEvent_RecvRPC) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.Meta != nil {
-		{
-			size, err := m.Meta.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
-		}
-		i--
-		dAtA[i] = 0x12
-	}
-	if m.ReceivedFrom != nil {
-		i -= len(m.ReceivedFrom)
-		copy(dAtA[i:], m.ReceivedFrom)
-		i = encodeVarintTrace(dAtA, i, uint64(len(m.ReceivedFrom)))
-		i--
-		dAtA[i] = 0xa
+var xxx_messageInfo_TraceEvent_MessageMeta proto.InternalMessageInfo
+
+func (m *TraceEvent_MessageMeta) GetMessageID() []byte {
+	if m != nil {
+		return m.MessageID
 	}
-	return len(dAtA) - i, nil
+	return nil
 }
 
-func (m *TraceEvent_SendRPC) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceEvent_MessageMeta) GetTopic() string {
+	if m != nil && m.Topic != nil {
+		return *m.Topic
 	}
-	return dAtA[:n], nil
+	return ""
 }
 
-func (m *TraceEvent_SendRPC) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type TraceEvent_SubMeta struct {
+	Subscribe            *bool    `protobuf:"varint,1,opt,name=subscribe" json:"subscribe,omitempty"`
+	Topic                *string  `protobuf:"bytes,2,opt,name=topic" json:"topic,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TraceEvent_SendRPC) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.Meta != nil {
-		{
-			size, err := m.Meta.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
+func (m *TraceEvent_SubMeta) Reset()         { *m = TraceEvent_SubMeta{} }
+func (m *TraceEvent_SubMeta) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_SubMeta) ProtoMessage()    {}
+func (*TraceEvent_SubMeta) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 28}
+}
+func (m *TraceEvent_SubMeta) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceEvent_SubMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceEvent_SubMeta.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
 		}
-		i--
-		dAtA[i] = 0x12
+		return b[:n], nil
 	}
-	if m.SendTo != nil {
-		i -= len(m.SendTo)
-		copy(dAtA[i:], m.SendTo)
-		i = encodeVarintTrace(dAtA, i, uint64(len(m.SendTo)))
-		i--
-		dAtA[i] = 0xa
+}
+func (m *TraceEvent_SubMeta) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_SubMeta.Merge(m, src)
+}
+func (m *TraceEvent_SubMeta) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceEvent_SubMeta) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_SubMeta.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TraceEvent_SubMeta proto.InternalMessageInfo
+
+func (m *TraceEvent_SubMeta) GetSubscribe() bool {
+	if m != nil && m.Subscribe != nil {
+		return *m.Subscribe
 	}
-	return len(dAtA) - i, nil
+	return false
 }
 
-func (m *TraceEvent_DropRPC) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceEvent_SubMeta) GetTopic() string {
+	if m != nil && m.Topic != nil {
+		return *m.Topic
 	}
-	return dAtA[:n], nil
+	return ""
 }
 
-func (m *TraceEvent_DropRPC) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type TraceEvent_ControlMeta struct {
+	Ihave                []*TraceEvent_ControlIHaveMeta `protobuf:"bytes,1,rep,name=ihave" json:"ihave,omitempty"`
+	Iwant                []*TraceEvent_ControlIWantMeta `protobuf:"bytes,2,rep,name=iwant" json:"iwant,omitempty"`
+	Graft                []*TraceEvent_ControlGraftMeta `protobuf:"bytes,3,rep,name=graft" json:"graft,omitempty"`
+	Prune                []*TraceEvent_ControlPruneMeta `protobuf:"bytes,4,rep,name=prune" json:"prune,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                       `json:"-"`
+	XXX_unrecognized     []byte                         `json:"-"`
+	XXX_sizecache        int32                          `json:"-"`
 }
 
-func (m *TraceEvent_DropRPC) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.Meta != nil {
-		{
-			size, err := m.Meta.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
+func (m *TraceEvent_ControlMeta) Reset()         { *m = TraceEvent_ControlMeta{} }
+func (m *TraceEvent_ControlMeta) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_ControlMeta) ProtoMessage()    {}
+func (*TraceEvent_ControlMeta) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 29}
+}
+func (m *TraceEvent_ControlMeta) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceEvent_ControlMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceEvent_ControlMeta.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
 		}
-		i--
-		dAtA[i] = 0x12
-	}
-	if m.SendTo != nil {
-		i -= len(m.SendTo)
-		copy(dAtA[i:], m.SendTo)
-		i = encodeVarintTrace(dAtA, i, uint64(len(m.SendTo)))
-		i--
-		dAtA[i] = 0xa
+		return b[:n], nil
 	}
-	return len(dAtA) - i, nil
+}
+func (m *TraceEvent_ControlMeta) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_ControlMeta.Merge(m, src)
+}
+func (m *TraceEvent_ControlMeta) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceEvent_ControlMeta) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_ControlMeta.DiscardUnknown(m)
 }
 
-func (m *TraceEvent_Join) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_TraceEvent_ControlMeta proto.InternalMessageInfo
+
+func (m *TraceEvent_ControlMeta) GetIhave() []*TraceEvent_ControlIHaveMeta {
+	if m != nil {
+		return m.Ihave
 	}
-	return dAtA[:n], nil
+	return nil
 }
 
-func (m *TraceEvent_Join) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func (m *TraceEvent_ControlMeta) GetIwant() []*TraceEvent_ControlIWantMeta {
+	if m != nil {
+		return m.Iwant
+	}
+	return nil
 }
 
-func (m *TraceEvent_Join) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.Topic != nil {
-		i -= len(*m.Topic)
-		copy(dAtA[i:], *m.Topic)
-		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
-		i--
-		dAtA[i] = 0xa
+func (m *TraceEvent_ControlMeta) GetGraft() []*TraceEvent_ControlGraftMeta {
+	if m != nil {
+		return m.Graft
 	}
-	return len(dAtA) - i, nil
+	return nil
 }
 
-func (m *TraceEvent_Leave) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceEvent_ControlMeta) GetPrune() []*TraceEvent_ControlPruneMeta {
+	if m != nil {
+		return m.Prune
 	}
-	return dAtA[:n], nil
+	return nil
 }
 
-func (m *TraceEvent_Leave) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type TraceEvent_ControlIHaveMeta struct {
+	Topic                *string  `protobuf:"bytes,1,opt,name=topic" json:"topic,omitempty"`
+	MessageIDs           [][]byte `protobuf:"bytes,2,rep,name=messageIDs" json:"messageIDs,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TraceEvent_Leave) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
+func (m *TraceEvent_ControlIHaveMeta) Reset()         { *m = TraceEvent_ControlIHaveMeta{} }
+func (m *TraceEvent_ControlIHaveMeta) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_ControlIHaveMeta) ProtoMessage()    {}
+func (*TraceEvent_ControlIHaveMeta) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 30}
+}
+func (m *TraceEvent_ControlIHaveMeta) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceEvent_ControlIHaveMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceEvent_ControlIHaveMeta.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	if m.Topic != nil {
-		i -= len(*m.Topic)
-		copy(dAtA[i:], *m.Topic)
-		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
-		i--
-		dAtA[i] = 0x12
+}
+func (m *TraceEvent_ControlIHaveMeta) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_ControlIHaveMeta.Merge(m, src)
+}
+func (m *TraceEvent_ControlIHaveMeta) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceEvent_ControlIHaveMeta) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_ControlIHaveMeta.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TraceEvent_ControlIHaveMeta proto.InternalMessageInfo
+
+func (m *TraceEvent_ControlIHaveMeta) GetTopic() string {
+	if m != nil && m.Topic != nil {
+		return *m.Topic
 	}
-	return len(dAtA) - i, nil
+	return ""
 }
 
-func (m *TraceEvent_Graft) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceEvent_ControlIHaveMeta) GetMessageIDs() [][]byte {
+	if m != nil {
+		return m.MessageIDs
 	}
-	return dAtA[:n], nil
+	return nil
 }
 
-func (m *TraceEvent_Graft) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type TraceEvent_ControlIWantMeta struct {
+	MessageIDs           [][]byte `protobuf:"bytes,1,rep,name=messageIDs" json:"messageIDs,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TraceEvent_Graft) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.Topic != nil {
-		i -= len(*m.Topic)
-		copy(dAtA[i:], *m.Topic)
-		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
-		i--
-		dAtA[i] = 0x12
-	}
-	if m.PeerID != nil {
-		i -= len(m.PeerID)
-		copy(dAtA[i:], m.PeerID)
-		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
-		i--
-		dAtA[i] = 0xa
+func (m *TraceEvent_ControlIWantMeta) Reset()         { *m = TraceEvent_ControlIWantMeta{} }
+func (m *TraceEvent_ControlIWantMeta) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_ControlIWantMeta) ProtoMessage()    {}
+func (*TraceEvent_ControlIWantMeta) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 31}
+}
+func (m *TraceEvent_ControlIWantMeta) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceEvent_ControlIWantMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceEvent_ControlIWantMeta.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return len(dAtA) - i, nil
+}
+func (m *TraceEvent_ControlIWantMeta) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_ControlIWantMeta.Merge(m, src)
+}
+func (m *TraceEvent_ControlIWantMeta) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceEvent_ControlIWantMeta) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_ControlIWantMeta.DiscardUnknown(m)
 }
 
-func (m *TraceEvent_Prune) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_TraceEvent_ControlIWantMeta proto.InternalMessageInfo
+
+func (m *TraceEvent_ControlIWantMeta) GetMessageIDs() [][]byte {
+	if m != nil {
+		return m.MessageIDs
 	}
-	return dAtA[:n], nil
+	return nil
 }
 
-func (m *TraceEvent_Prune) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type TraceEvent_ControlGraftMeta struct {
+	Topic                *string  `protobuf:"bytes,1,opt,name=topic" json:"topic,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TraceEvent_Prune) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.Topic != nil {
-		i -= len(*m.Topic)
-		copy(dAtA[i:], *m.Topic)
-		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
-		i--
-		dAtA[i] = 0x12
-	}
-	if m.PeerID != nil {
-		i -= len(m.PeerID)
-		copy(dAtA[i:], m.PeerID)
-		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
-		i--
-		dAtA[i] = 0xa
+func (m *TraceEvent_ControlGraftMeta) Reset()         { *m = TraceEvent_ControlGraftMeta{} }
+func (m *TraceEvent_ControlGraftMeta) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_ControlGraftMeta) ProtoMessage()    {}
+func (*TraceEvent_ControlGraftMeta) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 32}
+}
+func (m *TraceEvent_ControlGraftMeta) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceEvent_ControlGraftMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceEvent_ControlGraftMeta.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return len(dAtA) - i, nil
+}
+func (m *TraceEvent_ControlGraftMeta) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_ControlGraftMeta.Merge(m, src)
+}
+func (m *TraceEvent_ControlGraftMeta) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceEvent_ControlGraftMeta) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_ControlGraftMeta.DiscardUnknown(m)
 }
 
-func (m *TraceEvent_RPCMeta) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_TraceEvent_ControlGraftMeta proto.InternalMessageInfo
+
+func (m *TraceEvent_ControlGraftMeta) GetTopic() string {
+	if m != nil && m.Topic != nil {
+		return *m.Topic
 	}
-	return dAtA[:n], nil
+	return ""
 }
 
-func (m *TraceEvent_RPCMeta) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type TraceEvent_ControlPruneMeta struct {
+	Topic                *string              `protobuf:"bytes,1,opt,name=topic" json:"topic,omitempty"`
+	Peers                [][]byte             `protobuf:"bytes,2,rep,name=peers" json:"peers,omitempty"`
+	Reason               *ControlPrune_Reason `protobuf:"varint,3,opt,name=reason,enum=pubsub.pb.ControlPrune_Reason" json:"reason,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
 }
 
-func (m *TraceEvent_RPCMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.Control != nil {
-		{
-			size, err := m.Control.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTrace(dAtA, i, uint64(size))
+func (m *TraceEvent_ControlPruneMeta) Reset()         { *m = TraceEvent_ControlPruneMeta{} }
+func (m *TraceEvent_ControlPruneMeta) String() string { return proto.CompactTextString(m) }
+func (*TraceEvent_ControlPruneMeta) ProtoMessage()    {}
+func (*TraceEvent_ControlPruneMeta) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{0, 33}
+}
+func (m *TraceEvent_ControlPruneMeta) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceEvent_ControlPruneMeta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceEvent_ControlPruneMeta.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
 		}
-		i--
-		dAtA[i] = 0x1a
+		return b[:n], nil
 	}
-	if len(m.Subscription) > 0 {
-		for iNdEx := len(m.Subscription) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size, err := m.Subscription[iNdEx].MarshalToSizedBuffer(dAtA[:i])
-				if err != nil {
-					return 0, err
-				}
-				i -= size
-				i = encodeVarintTrace(dAtA, i, uint64(size))
-			}
-			i--
-			dAtA[i] = 0x12
-		}
+}
+func (m *TraceEvent_ControlPruneMeta) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEvent_ControlPruneMeta.Merge(m, src)
+}
+func (m *TraceEvent_ControlPruneMeta) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceEvent_ControlPruneMeta) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEvent_ControlPruneMeta.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TraceEvent_ControlPruneMeta proto.InternalMessageInfo
+
+func (m *TraceEvent_ControlPruneMeta) GetTopic() string {
+	if m != nil && m.Topic != nil {
+		return *m.Topic
 	}
-	if len(m.Messages) > 0 {
-		for iNdEx := len(m.Messages) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size, err := m.Messages[iNdEx].MarshalToSizedBuffer(dAtA[:i])
-				if err != nil {
-					return 0, err
-				}
-				i -= size
-				i = encodeVarintTrace(dAtA, i, uint64(size))
-			}
-			i--
-			dAtA[i] = 0xa
-		}
+	return ""
+}
+
+func (m *TraceEvent_ControlPruneMeta) GetPeers() [][]byte {
+	if m != nil {
+		return m.Peers
 	}
-	return len(dAtA) - i, nil
+	return nil
 }
 
-func (m *TraceEvent_MessageMeta) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceEvent_ControlPruneMeta) GetReason() ControlPrune_Reason {
+	if m != nil && m.Reason != nil {
+		return *m.Reason
 	}
-	return dAtA[:n], nil
+	return ControlPrune_UNKNOWN
 }
 
-func (m *TraceEvent_MessageMeta) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type TraceEventBatch struct {
+	Batch []*TraceEvent `protobuf:"bytes,1,rep,name=batch" json:"batch,omitempty"`
+	// the negotiated trace schema version; only set if the collector sent a handshake
+	SchemaVersion        *uint32  `protobuf:"varint,2,opt,name=schemaVersion" json:"schemaVersion,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TraceEvent_MessageMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
-	}
-	if m.Topic != nil {
-		i -= len(*m.Topic)
-		copy(dAtA[i:], *m.Topic)
-		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
-		i--
-		dAtA[i] = 0x12
+func (m *TraceEventBatch) Reset()         { *m = TraceEventBatch{} }
+func (m *TraceEventBatch) String() string { return proto.CompactTextString(m) }
+func (*TraceEventBatch) ProtoMessage()    {}
+func (*TraceEventBatch) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{1}
+}
+func (m *TraceEventBatch) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceEventBatch) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceEventBatch.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	if m.MessageID != nil {
-		i -= len(m.MessageID)
-		copy(dAtA[i:], m.MessageID)
-		i = encodeVarintTrace(dAtA, i, uint64(len(m.MessageID)))
-		i--
-		dAtA[i] = 0xa
+}
+func (m *TraceEventBatch) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceEventBatch.Merge(m, src)
+}
+func (m *TraceEventBatch) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceEventBatch) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceEventBatch.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TraceEventBatch proto.InternalMessageInfo
+
+func (m *TraceEventBatch) GetBatch() []*TraceEvent {
+	if m != nil {
+		return m.Batch
 	}
-	return len(dAtA) - i, nil
+	return nil
 }
 
-func (m *TraceEvent_SubMeta) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *TraceEventBatch) GetSchemaVersion() uint32 {
+	if m != nil && m.SchemaVersion != nil {
+		return *m.SchemaVersion
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *TraceEvent_SubMeta) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+// TraceHandshake is sent by a remote trace collector when the tracer opens the stream.
+type TraceHandshake struct {
+	SchemaVersion *uint32 `protobuf:"varint,1,opt,name=schemaVersion" json:"schemaVersion,omitempty"`
+	// bitmask of the requested event types, with bit n set for the event type n;
+	// all event types are requested if unset or zero.
+	EventTypes           *uint64  `protobuf:"varint,2,opt,name=eventTypes" json:"eventTypes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *TraceEvent_SubMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
+func (m *TraceHandshake) Reset()         { *m = TraceHandshake{} }
+func (m *TraceHandshake) String() string { return proto.CompactTextString(m) }
+func (*TraceHandshake) ProtoMessage()    {}
+func (*TraceHandshake) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0571941a1d628a80, []int{2}
+}
+func (m *TraceHandshake) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceHandshake) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceHandshake.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	if m.Topic != nil {
-		i -= len(*m.Topic)
-		copy(dAtA[i:], *m.Topic)
-		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
-		i--
-		dAtA[i] = 0x12
+}
+func (m *TraceHandshake) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceHandshake.Merge(m, src)
+}
+func (m *TraceHandshake) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceHandshake) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceHandshake.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TraceHandshake proto.InternalMessageInfo
+
+func (m *TraceHandshake) GetSchemaVersion() uint32 {
+	if m != nil && m.SchemaVersion != nil {
+		return *m.SchemaVersion
 	}
-	if m.Subscribe != nil {
-		i--
-		if *m.Subscribe {
-			dAtA[i] = 1
-		} else {
-			dAtA[i] = 0
-		}
-		i--
-		dAtA[i] = 0x8
+	return 0
+}
+
+func (m *TraceHandshake) GetEventTypes() uint64 {
+	if m != nil && m.EventTypes != nil {
+		return *m.EventTypes
 	}
-	return len(dAtA) - i, nil
+	return 0
 }
 
-func (m *TraceEvent_ControlMeta) Marshal() (dAtA []byte, err error) {
+func init() {
+	proto.RegisterEnum("pubsub.pb.TraceEvent_Type", TraceEvent_Type_name, TraceEvent_Type_value)
+	proto.RegisterEnum("pubsub.pb.TraceEvent_IgnoreIHave_Reason", TraceEvent_IgnoreIHave_Reason_name, TraceEvent_IgnoreIHave_Reason_value)
+	proto.RegisterEnum("pubsub.pb.TraceEvent_RejectRPC_Limit", TraceEvent_RejectRPC_Limit_name, TraceEvent_RejectRPC_Limit_value)
+	proto.RegisterEnum("pubsub.pb.TraceEvent_CircuitBreaker_State", TraceEvent_CircuitBreaker_State_name, TraceEvent_CircuitBreaker_State_value)
+	proto.RegisterType((*TraceEvent)(nil), "pubsub.pb.TraceEvent")
+	proto.RegisterType((*TraceEvent_PublishMessage)(nil), "pubsub.pb.TraceEvent.PublishMessage")
+	proto.RegisterType((*TraceEvent_RejectMessage)(nil), "pubsub.pb.TraceEvent.RejectMessage")
+	proto.RegisterType((*TraceEvent_DuplicateMessage)(nil), "pubsub.pb.TraceEvent.DuplicateMessage")
+	proto.RegisterType((*TraceEvent_DeliverMessage)(nil), "pubsub.pb.TraceEvent.DeliverMessage")
+	proto.RegisterType((*TraceEvent_AddPeer)(nil), "pubsub.pb.TraceEvent.AddPeer")
+	proto.RegisterType((*TraceEvent_RemovePeer)(nil), "pubsub.pb.TraceEvent.RemovePeer")
+	proto.RegisterType((*TraceEvent_RecvRPC)(nil), "pubsub.pb.TraceEvent.RecvRPC")
+	proto.RegisterType((*TraceEvent_SendRPC)(nil), "pubsub.pb.TraceEvent.SendRPC")
+	proto.RegisterType((*TraceEvent_DropRPC)(nil), "pubsub.pb.TraceEvent.DropRPC")
+	proto.RegisterType((*TraceEvent_Join)(nil), "pubsub.pb.TraceEvent.Join")
+	proto.RegisterType((*TraceEvent_Leave)(nil), "pubsub.pb.TraceEvent.Leave")
+	proto.RegisterType((*TraceEvent_Graft)(nil), "pubsub.pb.TraceEvent.Graft")
+	proto.RegisterType((*TraceEvent_Prune)(nil), "pubsub.pb.TraceEvent.Prune")
+	proto.RegisterType((*TraceEvent_IgnoreIHave)(nil), "pubsub.pb.TraceEvent.IgnoreIHave")
+	proto.RegisterType((*TraceEvent_RejectRPC)(nil), "pubsub.pb.TraceEvent.RejectRPC")
+	proto.RegisterType((*TraceEvent_WriteTimeout)(nil), "pubsub.pb.TraceEvent.WriteTimeout")
+	proto.RegisterType((*TraceEvent_CircuitBreaker)(nil), "pubsub.pb.TraceEvent.CircuitBreaker")
+	proto.RegisterType((*TraceEvent_StaleMessage)(nil), "pubsub.pb.TraceEvent.StaleMessage")
+	proto.RegisterType((*TraceEvent_SubscriptionChurn)(nil), "pubsub.pb.TraceEvent.SubscriptionChurn")
+	proto.RegisterType((*TraceEvent_MalformedRPC)(nil), "pubsub.pb.TraceEvent.MalformedRPC")
+	proto.RegisterType((*TraceEvent_ReannounceSubscriptions)(nil), "pubsub.pb.TraceEvent.ReannounceSubscriptions")
+	proto.RegisterType((*TraceEvent_SlowConsumerThrottle)(nil), "pubsub.pb.TraceEvent.SlowConsumerThrottle")
+	proto.RegisterType((*TraceEvent_TopicConfig)(nil), "pubsub.pb.TraceEvent.TopicConfig")
+	proto.RegisterType((*TraceEvent_PeerSubscribe)(nil), "pubsub.pb.TraceEvent.PeerSubscribe")
+	proto.RegisterType((*TraceEvent_PeerUnsubscribe)(nil), "pubsub.pb.TraceEvent.PeerUnsubscribe")
+	proto.RegisterType((*TraceEvent_IWantMiss)(nil), "pubsub.pb.TraceEvent.IWantMiss")
+	proto.RegisterType((*TraceEvent_RPCMeta)(nil), "pubsub.pb.TraceEvent.RPCMeta")
+	proto.RegisterType((*TraceEvent_MessageMeta)(nil), "pubsub.pb.TraceEvent.MessageMeta")
+	proto.RegisterType((*TraceEvent_SubMeta)(nil), "pubsub.pb.TraceEvent.SubMeta")
+	proto.RegisterType((*TraceEvent_ControlMeta)(nil), "pubsub.pb.TraceEvent.ControlMeta")
+	proto.RegisterType((*TraceEvent_ControlIHaveMeta)(nil), "pubsub.pb.TraceEvent.ControlIHaveMeta")
+	proto.RegisterType((*TraceEvent_ControlIWantMeta)(nil), "pubsub.pb.TraceEvent.ControlIWantMeta")
+	proto.RegisterType((*TraceEvent_ControlGraftMeta)(nil), "pubsub.pb.TraceEvent.ControlGraftMeta")
+	proto.RegisterType((*TraceEvent_ControlPruneMeta)(nil), "pubsub.pb.TraceEvent.ControlPruneMeta")
+	proto.RegisterType((*TraceEventBatch)(nil), "pubsub.pb.TraceEventBatch")
+	proto.RegisterType((*TraceHandshake)(nil), "pubsub.pb.TraceHandshake")
+}
+
+func init() { proto.RegisterFile("trace.proto", fileDescriptor_0571941a1d628a80) }
+
+var fileDescriptor_0571941a1d628a80 = []byte{
+	// 1937 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x57, 0xcd, 0x6e, 0xdb, 0xd8,
+	0x15, 0x0e, 0x2d, 0xc9, 0xb2, 0x8e, 0x7e, 0x4c, 0xdf, 0x71, 0x1c, 0x0e, 0x27, 0x71, 0x5d, 0x35,
+	0x93, 0x1a, 0xd3, 0xa9, 0x81, 0x09, 0xd0, 0xce, 0x62, 0x26, 0x68, 0x64, 0x8a, 0xb6, 0x99, 0xea,
+	0x0f, 0x97, 0x94, 0x8d, 0x16, 0x68, 0x55, 0x5a, 0xba, 0xb1, 0x98, 0x91, 0x48, 0x81, 0xa4, 0x1c,
+	0x64, 0xd5, 0x55, 0x5f, 0xa1, 0x9b, 0xae, 0xfb, 0x1e, 0xdd, 0x75, 0x96, 0x7d, 0x81, 0x02, 0x45,
+	0x9e, 0xa4, 0x38, 0x97, 0xa4, 0x48, 0xca, 0xa4, 0x92, 0x18, 0xb3, 0xd3, 0x3d, 0xfa, 0xbe, 0xef,
+	0x9e, 0x73, 0x7f, 0x78, 0xbe, 0x0b, 0x55, 0xdf, 0x35, 0xc7, 0xec, 0x64, 0xe1, 0x3a, 0xbe, 0x43,
+	0x2a, 0x8b, 0xe5, 0xb5, 0xb7, 0xbc, 0x3e, 0x59, 0x5c, 0xcb, 0x15, 0x77, 0x31, 0x0e, 0xa2, 0xcd,
+	0x7f, 0x7e, 0x0d, 0x60, 0x20, 0x4a, 0xbd, 0x65, 0xb6, 0x4f, 0x4e, 0xa0, 0xe8, 0xbf, 0x5b, 0x30,
+	0x49, 0x38, 0x12, 0x8e, 0x1b, 0xcf, 0xe5, 0x93, 0x15, 0xe7, 0x24, 0x06, 0x9d, 0x18, 0xef, 0x16,
+	0x8c, 0x72, 0x1c, 0x39, 0x80, 0xed, 0x05, 0x63, 0xae, 0xd6, 0x96, 0xb6, 0x8e, 0x84, 0xe3, 0x1a,
+	0x0d, 0x47, 0xe4, 0x31, 0x54, 0x7c, 0x6b, 0xce, 0x3c, 0xdf, 0x9c, 0x2f, 0xa4, 0xc2, 0x91, 0x70,
+	0x5c, 0xa0, 0x71, 0x80, 0x74, 0xa0, 0xb1, 0x58, 0x5e, 0xcf, 0x2c, 0x6f, 0xda, 0x65, 0x9e, 0x67,
+	0xde, 0x30, 0xa9, 0x78, 0x24, 0x1c, 0x57, 0x9f, 0x3f, 0xcd, 0x9e, 0x6f, 0x90, 0xc2, 0xd2, 0x35,
+	0x2e, 0xd1, 0xa0, 0xee, 0xb2, 0x37, 0x6c, 0xec, 0x47, 0x62, 0x25, 0x2e, 0xf6, 0x8b, 0x6c, 0x31,
+	0x9a, 0x84, 0xd2, 0x34, 0x93, 0x50, 0x10, 0x27, 0xcb, 0xc5, 0xcc, 0x1a, 0x9b, 0x3e, 0x8b, 0xd4,
+	0xb6, 0xb9, 0xda, 0xb3, 0x6c, 0xb5, 0xf6, 0x1a, 0x9a, 0xde, 0xe1, 0x63, 0xb1, 0x13, 0x36, 0xb3,
+	0x6e, 0x99, 0x1b, 0x29, 0x96, 0x37, 0x15, 0xdb, 0x4e, 0x61, 0xe9, 0x1a, 0x97, 0x7c, 0x0b, 0x65,
+	0x73, 0x32, 0x19, 0x30, 0xe6, 0x4a, 0x3b, 0x5c, 0xe6, 0x49, 0xb6, 0x4c, 0x2b, 0x00, 0xd1, 0x08,
+	0x4d, 0x5e, 0x02, 0xb8, 0x6c, 0xee, 0xdc, 0x32, 0xce, 0xad, 0x70, 0xee, 0x51, 0xde, 0x12, 0x45,
+	0x38, 0x9a, 0xe0, 0xe0, 0xd4, 0x2e, 0x1b, 0xdf, 0xd2, 0x81, 0x22, 0xc1, 0xa6, 0xa9, 0x69, 0x00,
+	0xa2, 0x11, 0x1a, 0x89, 0x1e, 0xb3, 0x27, 0x48, 0xac, 0x6e, 0x22, 0xea, 0x01, 0x88, 0x46, 0x68,
+	0x24, 0x4e, 0x5c, 0x67, 0x81, 0xc4, 0xda, 0x26, 0x62, 0x3b, 0x00, 0xd1, 0x08, 0x8d, 0xc7, 0xf8,
+	0x8d, 0x63, 0xd9, 0x52, 0x9d, 0xb3, 0x72, 0x8e, 0xf1, 0x2b, 0xc7, 0xb2, 0x29, 0xc7, 0x91, 0x6f,
+	0xa0, 0x34, 0x63, 0xe6, 0x2d, 0x93, 0x1a, 0x9c, 0xf0, 0x45, 0x36, 0xa1, 0x83, 0x10, 0x1a, 0x20,
+	0x91, 0x72, 0xe3, 0x9a, 0xaf, 0x7d, 0x69, 0x77, 0x13, 0xe5, 0x1c, 0x21, 0x34, 0x40, 0x22, 0x65,
+	0xe1, 0x2e, 0x6d, 0x26, 0x89, 0x9b, 0x28, 0x03, 0x84, 0xd0, 0x00, 0x49, 0x14, 0xa8, 0x5a, 0x37,
+	0xb6, 0xe3, 0x32, 0xed, 0x02, 0xd3, 0xdb, 0xe3, 0xc4, 0x9f, 0x67, 0x13, 0xb5, 0x18, 0x48, 0x93,
+	0x2c, 0xf2, 0x02, 0x2a, 0xc1, 0x31, 0xc7, 0x85, 0x24, 0x5c, 0xe2, 0x67, 0x9b, 0x2e, 0x07, 0x2e,
+	0x65, 0xcc, 0x20, 0x67, 0x50, 0x7b, 0xeb, 0x5a, 0x3e, 0x33, 0xac, 0x39, 0x73, 0x96, 0xbe, 0xf4,
+	0x19, 0x57, 0x68, 0x66, 0x2b, 0x5c, 0x25, 0x90, 0x34, 0xc5, 0xc3, 0x8b, 0x30, 0xb6, 0xdc, 0xf1,
+	0xd2, 0xf2, 0x4f, 0x5d, 0x66, 0xfe, 0xc0, 0x5c, 0x69, 0x7f, 0xd3, 0x45, 0x50, 0x52, 0x58, 0xba,
+	0xc6, 0xc5, 0xac, 0x3c, 0xdf, 0x9c, 0xad, 0xae, 0xe9, 0xc3, 0x4d, 0x59, 0xe9, 0x09, 0x24, 0x4d,
+	0xf1, 0xc8, 0x10, 0xf6, 0xbc, 0xe5, 0xb5, 0x37, 0x76, 0xad, 0x85, 0x6f, 0x39, 0xb6, 0x32, 0x5d,
+	0xba, 0xb6, 0x74, 0xc0, 0xc5, 0x7e, 0x99, 0x23, 0xb6, 0x0e, 0xa7, 0x77, 0x15, 0x30, 0xbd, 0xb9,
+	0x39, 0x7b, 0xed, 0xb8, 0x73, 0xc6, 0x0f, 0xfe, 0xa3, 0x4d, 0xe9, 0x75, 0x13, 0x48, 0x9a, 0xe2,
+	0x91, 0x1b, 0x78, 0xe4, 0x32, 0xd3, 0xb6, 0x9d, 0xa5, 0x3d, 0x66, 0xc9, 0x99, 0x3d, 0x49, 0xe2,
+	0x92, 0xbf, 0xce, 0xdb, 0xc9, 0x4c, 0x12, 0xcd, 0x53, 0x23, 0x7f, 0x86, 0x7d, 0x6f, 0xe6, 0xbc,
+	0x55, 0x1c, 0xdb, 0x5b, 0xce, 0x99, 0x6b, 0x4c, 0x5d, 0xc7, 0xf7, 0x67, 0x4c, 0xfa, 0x9c, 0xcf,
+	0xf2, 0x55, 0xce, 0x52, 0x64, 0x30, 0x68, 0xa6, 0x0e, 0x9e, 0x64, 0xdf, 0x59, 0x58, 0x63, 0xc5,
+	0xb1, 0x5f, 0x5b, 0x37, 0x92, 0xbc, 0xe9, 0x24, 0x1b, 0x31, 0x90, 0x26, 0x59, 0xf8, 0xa9, 0xc7,
+	0x06, 0x13, 0x66, 0x7e, 0xcd, 0xa4, 0x2f, 0x36, 0x7d, 0xea, 0x07, 0x49, 0x28, 0x4d, 0x33, 0x49,
+	0x1f, 0x76, 0x31, 0x30, 0xb4, 0xbd, 0x95, 0xd8, 0x63, 0x2e, 0xf6, 0x65, 0xbe, 0x58, 0x02, 0x4c,
+	0xd7, 0xd9, 0x78, 0xcb, 0xac, 0xb7, 0xa6, 0xed, 0x77, 0x2d, 0xcf, 0x93, 0x9e, 0x6c, 0xba, 0x65,
+	0xda, 0x55, 0x08, 0xa3, 0x31, 0x43, 0x6e, 0x43, 0x23, 0xdd, 0xe7, 0xb0, 0x87, 0xce, 0x83, 0x9f,
+	0x5a, 0x9b, 0x37, 0xe4, 0x1a, 0x8d, 0x03, 0x64, 0x1f, 0x4a, 0x7c, 0x65, 0x78, 0xe3, 0xad, 0xd0,
+	0x60, 0x20, 0xff, 0x15, 0xea, 0xa9, 0x06, 0xf7, 0x01, 0x91, 0x26, 0xd4, 0x5c, 0x36, 0x66, 0xd6,
+	0x2d, 0x9b, 0x9c, 0xb9, 0xce, 0x3c, 0x6c, 0xe2, 0xa9, 0x18, 0xb6, 0x78, 0x97, 0x99, 0x9e, 0x63,
+	0xf3, 0x3e, 0x5e, 0xa1, 0xe1, 0x28, 0x4e, 0xa0, 0x98, 0x4c, 0xe0, 0x0d, 0x88, 0xeb, 0x3d, 0xf1,
+	0x27, 0xc8, 0x61, 0x35, 0x57, 0x21, 0x39, 0xd7, 0x14, 0x1a, 0xe9, 0x6e, 0x79, 0x9f, 0x25, 0xbb,
+	0x33, 0x7f, 0xe1, 0xee, 0xfc, 0xf2, 0xb7, 0x50, 0x0e, 0x1b, 0x6a, 0xc2, 0xf1, 0x08, 0x29, 0xc7,
+	0xb3, 0x8f, 0x1f, 0x77, 0xc7, 0x77, 0x22, 0x71, 0x3e, 0x90, 0x9f, 0x02, 0xc4, 0xdd, 0x34, 0x8f,
+	0x2b, 0xff, 0x05, 0xca, 0x61, 0xd3, 0xbc, 0x93, 0x8d, 0x90, 0xb1, 0x1a, 0xdf, 0x40, 0x71, 0xce,
+	0x7c, 0x93, 0xcf, 0x94, 0xdf, 0x85, 0x07, 0x4a, 0x97, 0xf9, 0x26, 0xe5, 0x50, 0xd9, 0x80, 0x72,
+	0xd8, 0x5d, 0x31, 0x09, 0xec, 0xaf, 0x86, 0x13, 0x25, 0x11, 0x8c, 0xee, 0xa9, 0x1a, 0xb6, 0xde,
+	0x9f, 0x52, 0xf5, 0x31, 0x14, 0xb1, 0x35, 0xc7, 0xdb, 0x25, 0x24, 0x37, 0xfd, 0x09, 0x94, 0x78,
+	0x1f, 0xce, 0xb9, 0x00, 0xbf, 0x81, 0x12, 0xef, 0xb9, 0x9b, 0xf6, 0x29, 0x83, 0x36, 0x87, 0x12,
+	0xef, 0xbb, 0x9f, 0x46, 0x23, 0xbf, 0x4d, 0xdd, 0x8d, 0xc6, 0xf3, 0xc3, 0x44, 0x7d, 0x8a, 0x63,
+	0xfb, 0xae, 0x33, 0xe3, 0xb2, 0xf8, 0x39, 0xf6, 0x1c, 0x3b, 0xba, 0x3b, 0xf2, 0xbf, 0x04, 0xa8,
+	0x26, 0xda, 0x75, 0xee, 0xac, 0x2f, 0x57, 0xfa, 0x5b, 0x5c, 0xff, 0xf8, 0x83, 0x9d, 0x7f, 0x6d,
+	0xa6, 0xec, 0x9b, 0xd3, 0x6c, 0xc1, 0x76, 0x80, 0x23, 0x75, 0xa8, 0x74, 0xfa, 0x57, 0x23, 0x5d,
+	0xe9, 0x53, 0x55, 0x7c, 0x40, 0x3e, 0x83, 0x5d, 0xa3, 0xdf, 0x1f, 0x75, 0x5b, 0xbd, 0x3f, 0x8c,
+	0xb4, 0x8b, 0xd6, 0xa5, 0xaa, 0x8b, 0x42, 0x3a, 0x78, 0xd5, 0xea, 0x19, 0xba, 0xb8, 0x25, 0xff,
+	0x5b, 0x80, 0xca, 0xca, 0x2e, 0xe4, 0x16, 0xf0, 0x1d, 0x94, 0x66, 0xd6, 0xdc, 0xf2, 0xc3, 0xfc,
+	0xbf, 0xfc, 0x80, 0xed, 0x38, 0xe9, 0x20, 0x98, 0x06, 0x9c, 0x26, 0x83, 0x12, 0x1f, 0x93, 0x3d,
+	0xa8, 0xeb, 0xc3, 0x53, 0x5d, 0xa1, 0xda, 0xc0, 0xd0, 0xfa, 0x3d, 0x5d, 0x7c, 0x40, 0x6a, 0xb0,
+	0xd3, 0x55, 0x75, 0xbd, 0x75, 0xce, 0x33, 0xac, 0x40, 0x89, 0x67, 0x2b, 0x6e, 0xf1, 0x9f, 0x98,
+	0xa3, 0x58, 0xc0, 0x9f, 0xe7, 0xb4, 0x75, 0x66, 0x88, 0x45, 0xfc, 0x39, 0xa0, 0xc3, 0x9e, 0x2a,
+	0x96, 0xc8, 0x2e, 0x54, 0x43, 0xe6, 0x48, 0x6b, 0xeb, 0xe2, 0xb6, 0xfc, 0x0c, 0x6a, 0x49, 0xd7,
+	0x92, 0x7b, 0x4b, 0xff, 0x2e, 0x40, 0x23, 0x6d, 0x4a, 0xb2, 0x8f, 0x28, 0x79, 0x09, 0x25, 0xcf,
+	0x37, 0x7d, 0x16, 0x16, 0xfd, 0xd5, 0xc7, 0xf8, 0x1b, 0xb4, 0x28, 0x3e, 0xa3, 0x01, 0xb1, 0xf9,
+	0x35, 0x94, 0xf8, 0x98, 0x00, 0x6c, 0x2b, 0x9d, 0xbe, 0xae, 0xb6, 0xc5, 0x07, 0x64, 0x07, 0x8a,
+	0xfd, 0x81, 0xda, 0x13, 0x05, 0xdc, 0xb4, 0x8b, 0x56, 0xe7, 0x6c, 0xc4, 0x87, 0x5b, 0xf2, 0x1f,
+	0xa1, 0x96, 0x34, 0x38, 0xf7, 0xfa, 0x0a, 0xc6, 0x45, 0x17, 0x52, 0x45, 0xff, 0x09, 0xf6, 0xee,
+	0xf8, 0x9d, 0x4f, 0xbc, 0x24, 0x32, 0xec, 0x8c, 0x1d, 0x67, 0x36, 0x71, 0xde, 0xda, 0xe1, 0x53,
+	0x70, 0x35, 0x96, 0x5f, 0x42, 0x2d, 0x69, 0x7e, 0x72, 0x95, 0x25, 0x28, 0x33, 0xdb, 0x77, 0x2d,
+	0xe6, 0x71, 0xed, 0x3a, 0x8d, 0x86, 0xb2, 0x06, 0x8f, 0x72, 0xbc, 0x4e, 0xae, 0xd8, 0x01, 0x6c,
+	0xf3, 0xcc, 0x50, 0xab, 0x80, 0x1d, 0x2d, 0x18, 0xc9, 0x13, 0xd8, 0xcf, 0x32, 0x34, 0x39, 0xbb,
+	0x8c, 0x4f, 0xdc, 0x10, 0x31, 0xe1, 0x49, 0xed, 0xd0, 0x38, 0x80, 0x09, 0xe3, 0x63, 0x64, 0xc1,
+	0x26, 0xbc, 0xe6, 0x22, 0x8d, 0x86, 0xf2, 0x3f, 0x04, 0xa8, 0x26, 0x0c, 0x4e, 0x8e, 0xfa, 0x11,
+	0x54, 0xbd, 0xb1, 0xe3, 0xb2, 0x81, 0xe9, 0x9a, 0x73, 0x2f, 0xd4, 0x4f, 0x86, 0x48, 0x0d, 0x84,
+	0x40, 0xbb, 0x4e, 0x85, 0x09, 0x11, 0xa1, 0x30, 0x99, 0x39, 0xbc, 0x17, 0xd7, 0x29, 0xfe, 0xe4,
+	0x91, 0xa9, 0xc5, 0x1f, 0xc3, 0x18, 0x99, 0x5a, 0xd8, 0x5b, 0x5e, 0xcf, 0x1c, 0x67, 0x12, 0xfa,
+	0x0c, 0xfe, 0xb2, 0xdd, 0xa1, 0xa9, 0x98, 0xfc, 0x02, 0xea, 0x29, 0xdb, 0xf4, 0x89, 0xdf, 0xd1,
+	0xdf, 0xc1, 0xee, 0x9a, 0x51, 0xfa, 0x44, 0x81, 0x16, 0x54, 0x56, 0xf6, 0x28, 0x97, 0x9a, 0x3a,
+	0xe0, 0x5b, 0x6b, 0x07, 0x5c, 0xfe, 0x51, 0x80, 0x72, 0xd8, 0x51, 0xc8, 0x0b, 0xd8, 0x09, 0xff,
+	0xf0, 0x24, 0xe1, 0xa8, 0x90, 0x6f, 0x39, 0xc3, 0xbb, 0xc3, 0xdb, 0xd0, 0x8a, 0x42, 0x5a, 0x50,
+	0x4b, 0x5a, 0x7b, 0x7e, 0x5e, 0xf2, 0x9f, 0xaf, 0xcb, 0x6b, 0x4e, 0x4f, 0x51, 0xc8, 0x77, 0x50,
+	0x1e, 0x07, 0x9d, 0x80, 0x6f, 0x56, 0x6e, 0x02, 0x61, 0xbb, 0xe0, 0x0a, 0x11, 0x43, 0x6e, 0x41,
+	0x35, 0x91, 0xd8, 0xbd, 0x1c, 0xe1, 0x0b, 0x28, 0x87, 0x89, 0x21, 0x3d, 0x36, 0xbb, 0x42, 0x70,
+	0x62, 0xe3, 0x7d, 0xca, 0xa6, 0xff, 0x6d, 0x0b, 0xaa, 0x89, 0xd4, 0xc8, 0xf7, 0x50, 0xb2, 0xa6,
+	0xf8, 0x14, 0x0d, 0x56, 0xf3, 0xd9, 0xc6, 0x62, 0x78, 0x47, 0xe2, 0x15, 0x05, 0x24, 0xce, 0x46,
+	0xc7, 0x1b, 0x2e, 0xe4, 0x07, 0xd8, 0xfc, 0x1c, 0x04, 0x6c, 0x24, 0x21, 0x3b, 0x78, 0x72, 0x17,
+	0x3e, 0x82, 0xcd, 0x5d, 0x40, 0xc0, 0x0e, 0x5e, 0xdf, 0xdf, 0x47, 0xaf, 0xef, 0xe2, 0x47, 0xb0,
+	0x79, 0xd7, 0x0e, 0xd8, 0x9c, 0x24, 0x5f, 0x80, 0xb8, 0x5e, 0x54, 0xce, 0xcd, 0x3d, 0x04, 0x58,
+	0xed, 0x49, 0xf0, 0x85, 0xa9, 0xd1, 0x44, 0x44, 0x7e, 0x1e, 0x2b, 0x45, 0x05, 0xae, 0x71, 0x84,
+	0x3b, 0x9c, 0xe3, 0x15, 0x67, 0x55, 0x56, 0x8e, 0x3d, 0xba, 0x5d, 0x21, 0x57, 0x25, 0xe4, 0xe4,
+	0x89, 0x86, 0x95, 0x31, 0x37, 0x4a, 0x31, 0x18, 0xdc, 0xd7, 0xd1, 0x34, 0xff, 0x5b, 0x80, 0xa2,
+	0xf1, 0x6e, 0xc1, 0xd0, 0x2c, 0x0c, 0x86, 0xa7, 0x1d, 0x4d, 0xbf, 0x18, 0x85, 0x6d, 0x56, 0x7c,
+	0x40, 0x08, 0x34, 0xa8, 0xfa, 0x4a, 0x55, 0x8c, 0x55, 0x4c, 0x20, 0x0f, 0x61, 0xaf, 0x3d, 0x1c,
+	0x74, 0x34, 0xa5, 0x65, 0xa8, 0xab, 0xf0, 0x16, 0xf2, 0xdb, 0x6a, 0x47, 0xbb, 0x54, 0xe9, 0x2a,
+	0x58, 0xc0, 0x6e, 0xdf, 0x6a, 0xb7, 0x47, 0x03, 0x55, 0xa5, 0x62, 0x11, 0x3b, 0x38, 0x55, 0xbb,
+	0xfd, 0x4b, 0x35, 0x08, 0x94, 0xf0, 0x6f, 0xaa, 0x2a, 0x97, 0x23, 0x3a, 0x50, 0xc4, 0x6d, 0x1c,
+	0xe9, 0x6a, 0xaf, 0xcd, 0x47, 0x65, 0x1c, 0xb5, 0x69, 0x7f, 0xc0, 0x47, 0x3b, 0xd8, 0x43, 0x5f,
+	0xf5, 0xb5, 0x9e, 0x58, 0x41, 0x47, 0xd0, 0x51, 0xd1, 0x32, 0x40, 0xec, 0x13, 0xaa, 0xb1, 0x4f,
+	0xa8, 0x11, 0x11, 0x6a, 0xda, 0x79, 0xaf, 0x4f, 0xd5, 0xc0, 0x08, 0x89, 0x75, 0xd2, 0x00, 0x08,
+	0xab, 0x40, 0xb1, 0x06, 0xda, 0x92, 0x2b, 0xaa, 0x19, 0xea, 0xc8, 0xd0, 0xba, 0x6a, 0x7f, 0x68,
+	0x88, 0xbb, 0x98, 0xbd, 0xa2, 0x51, 0x65, 0xa8, 0x19, 0xa3, 0x53, 0xaa, 0xb6, 0x7e, 0xaf, 0x52,
+	0x51, 0xe4, 0xf6, 0xc5, 0x68, 0x75, 0xe2, 0x2a, 0xf7, 0xc8, 0x01, 0x90, 0xa4, 0xa3, 0x19, 0x29,
+	0x17, 0x43, 0xda, 0x13, 0x09, 0x42, 0xbb, 0xad, 0xce, 0x59, 0x9f, 0x76, 0xd5, 0xa0, 0x80, 0xcf,
+	0xc8, 0x63, 0x90, 0xa8, 0xda, 0xea, 0xf5, 0xfa, 0xc3, 0x9e, 0xa2, 0x8e, 0xd2, 0x3e, 0x68, 0x9f,
+	0xc8, 0x70, 0xa0, 0xa3, 0x81, 0x53, 0xfa, 0x3d, 0x7d, 0xd8, 0x55, 0xe9, 0xc8, 0xb8, 0xa0, 0x7d,
+	0xc3, 0xe8, 0xa8, 0xe2, 0x43, 0xac, 0xc0, 0xe8, 0x0f, 0x34, 0x05, 0xff, 0x3c, 0xd3, 0xce, 0xc5,
+	0x03, 0xdc, 0x07, 0x5c, 0xb2, 0x48, 0xe5, 0x54, 0x15, 0x1f, 0x91, 0x7d, 0x10, 0x79, 0x6c, 0xd8,
+	0x8b, 0xa3, 0x12, 0xd6, 0xca, 0x6d, 0xd4, 0xa8, 0xab, 0xe9, 0xba, 0xf8, 0x79, 0x73, 0x02, 0xbb,
+	0xf1, 0x2d, 0x39, 0x35, 0xfd, 0xf1, 0x94, 0xfc, 0x0a, 0x4a, 0xd7, 0xf8, 0x23, 0xfc, 0x14, 0x3c,
+	0xcc, 0xbc, 0x50, 0x34, 0xc0, 0x90, 0xa7, 0x50, 0xf7, 0xc6, 0x53, 0x36, 0x37, 0x2f, 0x99, 0xeb,
+	0x59, 0xa1, 0xa1, 0xad, 0xd3, 0x74, 0xb0, 0x79, 0x09, 0x0d, 0x4e, 0xbd, 0x30, 0xed, 0x89, 0x37,
+	0x35, 0x7f, 0x60, 0x77, 0x79, 0x42, 0x06, 0x0f, 0xef, 0x0f, 0xc3, 0xd9, 0xf0, 0x04, 0x06, 0xcd,
+	0xb2, 0x48, 0x13, 0x91, 0xd3, 0xda, 0x8f, 0xef, 0x0f, 0x85, 0xff, 0xbc, 0x3f, 0x14, 0xfe, 0xf7,
+	0xfe, 0x50, 0xf8, 0x7f, 0x00, 0x00, 0x00, 0xff, 0xff, 0xe4, 0x66, 0xba, 0xf9, 0x17, 0x17, 0x00,
+	0x00,
+}
+
+func (m *TraceEvent) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalToSizedBuffer(dAtA[:size])
@@ -2495,12 +2811,12 @@ func (m *TraceEvent_ControlMeta) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *TraceEvent_ControlMeta) MarshalTo(dAtA []byte) (int, error) {
+func (m *TraceEvent) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *TraceEvent_ControlMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *TraceEvent) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
 	_ = i
 	var l int
@@ -2509,179 +2825,367 @@ func (m *TraceEvent_ControlMeta) MarshalToSizedBuffer(dAtA []byte) (int, error)
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	if len(m.Prune) > 0 {
-		for iNdEx := len(m.Prune) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size, err := m.Prune[iNdEx].MarshalToSizedBuffer(dAtA[:i])
-				if err != nil {
-					return 0, err
-				}
-				i -= size
-				i = encodeVarintTrace(dAtA, i, uint64(size))
+	if m.IwantMiss != nil {
+		{
+			size, err := m.IwantMiss.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
 			}
-			i--
-			dAtA[i] = 0x22
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
 		}
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0xea
 	}
-	if len(m.Graft) > 0 {
-		for iNdEx := len(m.Graft) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size, err := m.Graft[iNdEx].MarshalToSizedBuffer(dAtA[:i])
-				if err != nil {
-					return 0, err
-				}
-				i -= size
-				i = encodeVarintTrace(dAtA, i, uint64(size))
+	if m.PeerUnsubscribe != nil {
+		{
+			size, err := m.PeerUnsubscribe.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
 			}
-			i--
-			dAtA[i] = 0x1a
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
 		}
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0xe2
 	}
-	if len(m.Iwant) > 0 {
-		for iNdEx := len(m.Iwant) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size, err := m.Iwant[iNdEx].MarshalToSizedBuffer(dAtA[:i])
-				if err != nil {
-					return 0, err
-				}
-				i -= size
-				i = encodeVarintTrace(dAtA, i, uint64(size))
+	if m.PeerSubscribe != nil {
+		{
+			size, err := m.PeerSubscribe.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
 			}
-			i--
-			dAtA[i] = 0x12
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
 		}
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0xda
 	}
-	if len(m.Ihave) > 0 {
-		for iNdEx := len(m.Ihave) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size, err := m.Ihave[iNdEx].MarshalToSizedBuffer(dAtA[:i])
-				if err != nil {
-					return 0, err
-				}
-				i -= size
-				i = encodeVarintTrace(dAtA, i, uint64(size))
+	if m.TopicConfig != nil {
+		{
+			size, err := m.TopicConfig.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
 			}
-			i--
-			dAtA[i] = 0xa
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
 		}
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0xd2
 	}
-	return len(dAtA) - i, nil
-}
-
-func (m *TraceEvent_ControlIHaveMeta) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
-}
-
-func (m *TraceEvent_ControlIHaveMeta) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
-}
-
-func (m *TraceEvent_ControlIHaveMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
+	if m.SlowConsumerThrottle != nil {
+		{
+			size, err := m.SlowConsumerThrottle.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0xca
 	}
-	if len(m.MessageIDs) > 0 {
-		for iNdEx := len(m.MessageIDs) - 1; iNdEx >= 0; iNdEx-- {
-			i -= len(m.MessageIDs[iNdEx])
-			copy(dAtA[i:], m.MessageIDs[iNdEx])
-			i = encodeVarintTrace(dAtA, i, uint64(len(m.MessageIDs[iNdEx])))
-			i--
-			dAtA[i] = 0x12
+	if m.ReannounceSubscriptions != nil {
+		{
+			size, err := m.ReannounceSubscriptions.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
 		}
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0xc2
 	}
-	if m.Topic != nil {
-		i -= len(*m.Topic)
-		copy(dAtA[i:], *m.Topic)
-		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+	if m.MalformedRPC != nil {
+		{
+			size, err := m.MalformedRPC.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
 		i--
-		dAtA[i] = 0xa
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0xba
 	}
-	return len(dAtA) - i, nil
-}
-
-func (m *TraceEvent_ControlIWantMeta) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+	if m.SubscriptionChurn != nil {
+		{
+			size, err := m.SubscriptionChurn.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0xb2
 	}
-	return dAtA[:n], nil
-}
-
-func (m *TraceEvent_ControlIWantMeta) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
-}
-
-func (m *TraceEvent_ControlIWantMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
+	if m.StaleMessage != nil {
+		{
+			size, err := m.StaleMessage.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0xaa
 	}
-	if len(m.MessageIDs) > 0 {
-		for iNdEx := len(m.MessageIDs) - 1; iNdEx >= 0; iNdEx-- {
-			i -= len(m.MessageIDs[iNdEx])
-			copy(dAtA[i:], m.MessageIDs[iNdEx])
-			i = encodeVarintTrace(dAtA, i, uint64(len(m.MessageIDs[iNdEx])))
-			i--
-			dAtA[i] = 0xa
+	if m.CircuitBreaker != nil {
+		{
+			size, err := m.CircuitBreaker.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
 		}
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0xa2
 	}
-	return len(dAtA) - i, nil
-}
-
-func (m *TraceEvent_ControlGraftMeta) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+	if m.WriteTimeout != nil {
+		{
+			size, err := m.WriteTimeout.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x9a
 	}
-	return dAtA[:n], nil
-}
-
-func (m *TraceEvent_ControlGraftMeta) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
-}
-
-func (m *TraceEvent_ControlGraftMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.XXX_unrecognized != nil {
-		i -= len(m.XXX_unrecognized)
-		copy(dAtA[i:], m.XXX_unrecognized)
+	if m.RejectRPC != nil {
+		{
+			size, err := m.RejectRPC.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x92
 	}
-	if m.Topic != nil {
-		i -= len(*m.Topic)
-		copy(dAtA[i:], *m.Topic)
-		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+	if m.IgnoreIHave != nil {
+		{
+			size, err := m.IgnoreIHave.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
 		i--
-		dAtA[i] = 0xa
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x8a
 	}
-	return len(dAtA) - i, nil
-}
-
-func (m *TraceEvent_ControlPruneMeta) Marshal() (dAtA []byte, err error) {
+	if m.Prune != nil {
+		{
+			size, err := m.Prune.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x82
+	}
+	if m.Graft != nil {
+		{
+			size, err := m.Graft.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x7a
+	}
+	if m.Leave != nil {
+		{
+			size, err := m.Leave.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x72
+	}
+	if m.Join != nil {
+		{
+			size, err := m.Join.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x6a
+	}
+	if m.DropRPC != nil {
+		{
+			size, err := m.DropRPC.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x62
+	}
+	if m.SendRPC != nil {
+		{
+			size, err := m.SendRPC.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x5a
+	}
+	if m.RecvRPC != nil {
+		{
+			size, err := m.RecvRPC.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x52
+	}
+	if m.RemovePeer != nil {
+		{
+			size, err := m.RemovePeer.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x4a
+	}
+	if m.AddPeer != nil {
+		{
+			size, err := m.AddPeer.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x42
+	}
+	if m.DeliverMessage != nil {
+		{
+			size, err := m.DeliverMessage.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x3a
+	}
+	if m.DuplicateMessage != nil {
+		{
+			size, err := m.DuplicateMessage.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x32
+	}
+	if m.RejectMessage != nil {
+		{
+			size, err := m.RejectMessage.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x2a
+	}
+	if m.PublishMessage != nil {
+		{
+			size, err := m.PublishMessage.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x22
+	}
+	if m.Timestamp != nil {
+		i = encodeVarintTrace(dAtA, i, uint64(*m.Timestamp))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.PeerID != nil {
+		i -= len(m.PeerID)
+		copy(dAtA[i:], m.PeerID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.Type != nil {
+		i = encodeVarintTrace(dAtA, i, uint64(*m.Type))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceEvent_PublishMessage) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalToSizedBuffer(dAtA[:size])
@@ -2691,12 +3195,12 @@ func (m *TraceEvent_ControlPruneMeta) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *TraceEvent_ControlPruneMeta) MarshalTo(dAtA []byte) (int, error) {
+func (m *TraceEvent_PublishMessage) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *TraceEvent_ControlPruneMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *TraceEvent_PublishMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
 	_ = i
 	var l int
@@ -2705,26 +3209,24 @@ func (m *TraceEvent_ControlPruneMeta) MarshalToSizedBuffer(dAtA []byte) (int, er
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	if len(m.Peers) > 0 {
-		for iNdEx := len(m.Peers) - 1; iNdEx >= 0; iNdEx-- {
-			i -= len(m.Peers[iNdEx])
-			copy(dAtA[i:], m.Peers[iNdEx])
-			i = encodeVarintTrace(dAtA, i, uint64(len(m.Peers[iNdEx])))
-			i--
-			dAtA[i] = 0x12
-		}
-	}
 	if m.Topic != nil {
 		i -= len(*m.Topic)
 		copy(dAtA[i:], *m.Topic)
 		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
 		i--
+		dAtA[i] = 0x12
+	}
+	if m.MessageID != nil {
+		i -= len(m.MessageID)
+		copy(dAtA[i:], m.MessageID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.MessageID)))
+		i--
 		dAtA[i] = 0xa
 	}
 	return len(dAtA) - i, nil
 }
 
-func (m *TraceEventBatch) Marshal() (dAtA []byte, err error) {
+func (m *TraceEvent_RejectMessage) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalToSizedBuffer(dAtA[:size])
@@ -2734,12 +3236,12 @@ func (m *TraceEventBatch) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *TraceEventBatch) MarshalTo(dAtA []byte) (int, error) {
+func (m *TraceEvent_RejectMessage) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *TraceEventBatch) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *TraceEvent_RejectMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
 	_ = i
 	var l int
@@ -2748,578 +3250,3983 @@ func (m *TraceEventBatch) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	if len(m.Batch) > 0 {
-		for iNdEx := len(m.Batch) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size, err := m.Batch[iNdEx].MarshalToSizedBuffer(dAtA[:i])
-				if err != nil {
-					return 0, err
-				}
-				i -= size
-				i = encodeVarintTrace(dAtA, i, uint64(size))
-			}
-			i--
-			dAtA[i] = 0xa
-		}
+	if m.Topic != nil {
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if m.Reason != nil {
+		i -= len(*m.Reason)
+		copy(dAtA[i:], *m.Reason)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Reason)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if m.ReceivedFrom != nil {
+		i -= len(m.ReceivedFrom)
+		copy(dAtA[i:], m.ReceivedFrom)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.ReceivedFrom)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.MessageID != nil {
+		i -= len(m.MessageID)
+		copy(dAtA[i:], m.MessageID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.MessageID)))
+		i--
+		dAtA[i] = 0xa
 	}
 	return len(dAtA) - i, nil
 }
 
-func encodeVarintTrace(dAtA []byte, offset int, v uint64) int {
-	offset -= sovTrace(v)
-	base := offset
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
-		v >>= 7
-		offset++
-	}
-	dAtA[offset] = uint8(v)
-	return base
-}
-func (m *TraceEvent) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.Type != nil {
-		n += 1 + sovTrace(uint64(*m.Type))
-	}
-	if m.PeerID != nil {
-		l = len(m.PeerID)
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.Timestamp != nil {
-		n += 1 + sovTrace(uint64(*m.Timestamp))
-	}
-	if m.PublishMessage != nil {
-		l = m.PublishMessage.Size()
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.RejectMessage != nil {
-		l = m.RejectMessage.Size()
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.DuplicateMessage != nil {
-		l = m.DuplicateMessage.Size()
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.DeliverMessage != nil {
-		l = m.DeliverMessage.Size()
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.AddPeer != nil {
-		l = m.AddPeer.Size()
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.RemovePeer != nil {
-		l = m.RemovePeer.Size()
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.RecvRPC != nil {
-		l = m.RecvRPC.Size()
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.SendRPC != nil {
-		l = m.SendRPC.Size()
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.DropRPC != nil {
-		l = m.DropRPC.Size()
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.Join != nil {
-		l = m.Join.Size()
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.Leave != nil {
-		l = m.Leave.Size()
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.Graft != nil {
-		l = m.Graft.Size()
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.Prune != nil {
-		l = m.Prune.Size()
-		n += 2 + l + sovTrace(uint64(l))
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+func (m *TraceEvent_DuplicateMessage) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
-	return n
+	return dAtA[:n], nil
 }
 
-func (m *TraceEvent_PublishMessage) Size() (n int) {
-	if m == nil {
-		return 0
-	}
+func (m *TraceEvent_DuplicateMessage) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_DuplicateMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.MessageID != nil {
-		l = len(m.MessageID)
-		n += 1 + l + sovTrace(uint64(l))
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
 	if m.Topic != nil {
-		l = len(*m.Topic)
-		n += 1 + l + sovTrace(uint64(l))
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0x1a
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	if m.ReceivedFrom != nil {
+		i -= len(m.ReceivedFrom)
+		copy(dAtA[i:], m.ReceivedFrom)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.ReceivedFrom)))
+		i--
+		dAtA[i] = 0x12
 	}
-	return n
+	if m.MessageID != nil {
+		i -= len(m.MessageID)
+		copy(dAtA[i:], m.MessageID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.MessageID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_RejectMessage) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_DeliverMessage) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_DeliverMessage) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_DeliverMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.MessageID != nil {
-		l = len(m.MessageID)
-		n += 1 + l + sovTrace(uint64(l))
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
 	if m.ReceivedFrom != nil {
-		l = len(m.ReceivedFrom)
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.Reason != nil {
-		l = len(*m.Reason)
-		n += 1 + l + sovTrace(uint64(l))
+		i -= len(m.ReceivedFrom)
+		copy(dAtA[i:], m.ReceivedFrom)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.ReceivedFrom)))
+		i--
+		dAtA[i] = 0x1a
 	}
 	if m.Topic != nil {
-		l = len(*m.Topic)
-		n += 1 + l + sovTrace(uint64(l))
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0x12
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	if m.MessageID != nil {
+		i -= len(m.MessageID)
+		copy(dAtA[i:], m.MessageID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.MessageID)))
+		i--
+		dAtA[i] = 0xa
 	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_DuplicateMessage) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_AddPeer) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_AddPeer) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_AddPeer) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.MessageID != nil {
-		l = len(m.MessageID)
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.ReceivedFrom != nil {
-		l = len(m.ReceivedFrom)
-		n += 1 + l + sovTrace(uint64(l))
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	if m.Topic != nil {
-		l = len(*m.Topic)
-		n += 1 + l + sovTrace(uint64(l))
+	if m.Proto != nil {
+		i -= len(*m.Proto)
+		copy(dAtA[i:], *m.Proto)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Proto)))
+		i--
+		dAtA[i] = 0x12
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	if m.PeerID != nil {
+		i -= len(m.PeerID)
+		copy(dAtA[i:], m.PeerID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
+		i--
+		dAtA[i] = 0xa
 	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_DeliverMessage) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_RemovePeer) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_RemovePeer) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_RemovePeer) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.MessageID != nil {
-		l = len(m.MessageID)
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.Topic != nil {
-		l = len(*m.Topic)
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.ReceivedFrom != nil {
-		l = len(m.ReceivedFrom)
-		n += 1 + l + sovTrace(uint64(l))
-	}
 	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
-	}
-	return n
-}
-
-func (m *TraceEvent_AddPeer) Size() (n int) {
-	if m == nil {
-		return 0
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	var l int
-	_ = l
 	if m.PeerID != nil {
-		l = len(m.PeerID)
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.Proto != nil {
-		l = len(*m.Proto)
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+		i -= len(m.PeerID)
+		copy(dAtA[i:], m.PeerID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
+		i--
+		dAtA[i] = 0xa
 	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_RemovePeer) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.PeerID != nil {
-		l = len(m.PeerID)
-		n += 1 + l + sovTrace(uint64(l))
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+func (m *TraceEvent_RecvRPC) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
-	return n
+	return dAtA[:n], nil
 }
 
-func (m *TraceEvent_RecvRPC) Size() (n int) {
-	if m == nil {
-		return 0
-	}
+func (m *TraceEvent_RecvRPC) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_RecvRPC) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.ReceivedFrom != nil {
-		l = len(m.ReceivedFrom)
-		n += 1 + l + sovTrace(uint64(l))
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
 	if m.Meta != nil {
-		l = m.Meta.Size()
-		n += 1 + l + sovTrace(uint64(l))
+		{
+			size, err := m.Meta.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x12
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	if m.ReceivedFrom != nil {
+		i -= len(m.ReceivedFrom)
+		copy(dAtA[i:], m.ReceivedFrom)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.ReceivedFrom)))
+		i--
+		dAtA[i] = 0xa
 	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_SendRPC) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_SendRPC) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_SendRPC) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_SendRPC) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.SendTo != nil {
-		l = len(m.SendTo)
-		n += 1 + l + sovTrace(uint64(l))
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
 	if m.Meta != nil {
-		l = m.Meta.Size()
-		n += 1 + l + sovTrace(uint64(l))
+		{
+			size, err := m.Meta.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x12
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	if m.SendTo != nil {
+		i -= len(m.SendTo)
+		copy(dAtA[i:], m.SendTo)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.SendTo)))
+		i--
+		dAtA[i] = 0xa
 	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_DropRPC) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_DropRPC) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_DropRPC) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_DropRPC) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.SendTo != nil {
-		l = len(m.SendTo)
-		n += 1 + l + sovTrace(uint64(l))
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
 	if m.Meta != nil {
-		l = m.Meta.Size()
-		n += 1 + l + sovTrace(uint64(l))
+		{
+			size, err := m.Meta.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x12
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	if m.SendTo != nil {
+		i -= len(m.SendTo)
+		copy(dAtA[i:], m.SendTo)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.SendTo)))
+		i--
+		dAtA[i] = 0xa
 	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_Join) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_Join) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_Join) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_Join) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.Topic != nil {
-		l = len(*m.Topic)
-		n += 1 + l + sovTrace(uint64(l))
-	}
 	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	return n
+	if m.Topic != nil {
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_Leave) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_Leave) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_Leave) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_Leave) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.Topic != nil {
-		l = len(*m.Topic)
-		n += 1 + l + sovTrace(uint64(l))
-	}
 	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	return n
+	if m.Topic != nil {
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0x12
+	}
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_Graft) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_Graft) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_Graft) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_Graft) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.PeerID != nil {
-		l = len(m.PeerID)
-		n += 1 + l + sovTrace(uint64(l))
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
 	if m.Topic != nil {
-		l = len(*m.Topic)
-		n += 1 + l + sovTrace(uint64(l))
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0x12
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	if m.PeerID != nil {
+		i -= len(m.PeerID)
+		copy(dAtA[i:], m.PeerID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
+		i--
+		dAtA[i] = 0xa
 	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_Prune) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_Prune) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_Prune) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_Prune) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.PeerID != nil {
-		l = len(m.PeerID)
-		n += 1 + l + sovTrace(uint64(l))
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.Reason != nil {
+		i = encodeVarintTrace(dAtA, i, uint64(*m.Reason))
+		i--
+		dAtA[i] = 0x18
 	}
 	if m.Topic != nil {
-		l = len(*m.Topic)
-		n += 1 + l + sovTrace(uint64(l))
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0x12
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	if m.PeerID != nil {
+		i -= len(m.PeerID)
+		copy(dAtA[i:], m.PeerID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
+		i--
+		dAtA[i] = 0xa
 	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_RPCMeta) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_IgnoreIHave) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_IgnoreIHave) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_IgnoreIHave) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if len(m.Messages) > 0 {
-		for _, e := range m.Messages {
-			l = e.Size()
-			n += 1 + l + sovTrace(uint64(l))
-		}
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	if len(m.Subscription) > 0 {
-		for _, e := range m.Subscription {
-			l = e.Size()
-			n += 1 + l + sovTrace(uint64(l))
-		}
+	if m.Topic != nil {
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0x1a
 	}
-	if m.Control != nil {
-		l = m.Control.Size()
-		n += 1 + l + sovTrace(uint64(l))
+	if m.Reason != nil {
+		i = encodeVarintTrace(dAtA, i, uint64(*m.Reason))
+		i--
+		dAtA[i] = 0x10
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	if m.PeerID != nil {
+		i -= len(m.PeerID)
+		copy(dAtA[i:], m.PeerID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
+		i--
+		dAtA[i] = 0xa
 	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_MessageMeta) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_RejectRPC) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_RejectRPC) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_RejectRPC) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.MessageID != nil {
-		l = len(m.MessageID)
-		n += 1 + l + sovTrace(uint64(l))
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	if m.Topic != nil {
-		l = len(*m.Topic)
-		n += 1 + l + sovTrace(uint64(l))
+	if m.Limit != nil {
+		i = encodeVarintTrace(dAtA, i, uint64(*m.Limit))
+		i--
+		dAtA[i] = 0x10
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	if m.PeerID != nil {
+		i -= len(m.PeerID)
+		copy(dAtA[i:], m.PeerID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
+		i--
+		dAtA[i] = 0xa
 	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_SubMeta) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_WriteTimeout) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_WriteTimeout) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_WriteTimeout) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.Subscribe != nil {
-		n += 2
-	}
-	if m.Topic != nil {
-		l = len(*m.Topic)
-		n += 1 + l + sovTrace(uint64(l))
-	}
 	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	return n
+	if m.PeerID != nil {
+		i -= len(m.PeerID)
+		copy(dAtA[i:], m.PeerID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_ControlMeta) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_CircuitBreaker) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_CircuitBreaker) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_CircuitBreaker) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if len(m.Ihave) > 0 {
-		for _, e := range m.Ihave {
-			l = e.Size()
-			n += 1 + l + sovTrace(uint64(l))
-		}
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	if len(m.Iwant) > 0 {
-		for _, e := range m.Iwant {
-			l = e.Size()
-			n += 1 + l + sovTrace(uint64(l))
-		}
+	if m.State != nil {
+		i = encodeVarintTrace(dAtA, i, uint64(*m.State))
+		i--
+		dAtA[i] = 0x10
 	}
-	if len(m.Graft) > 0 {
-		for _, e := range m.Graft {
-			l = e.Size()
-			n += 1 + l + sovTrace(uint64(l))
-		}
+	if m.Topic != nil {
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0xa
 	}
-	if len(m.Prune) > 0 {
-		for _, e := range m.Prune {
-			l = e.Size()
-			n += 1 + l + sovTrace(uint64(l))
-		}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceEvent_StaleMessage) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_StaleMessage) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_StaleMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
 	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	return n
+	if m.PeerID != nil {
+		i -= len(m.PeerID)
+		copy(dAtA[i:], m.PeerID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if m.Topic != nil {
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.MessageID != nil {
+		i -= len(m.MessageID)
+		copy(dAtA[i:], m.MessageID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.MessageID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_ControlIHaveMeta) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_SubscriptionChurn) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_SubscriptionChurn) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_SubscriptionChurn) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.Cooldown != nil {
+		i = encodeVarintTrace(dAtA, i, uint64(*m.Cooldown))
+		i--
+		dAtA[i] = 0x18
+	}
 	if m.Topic != nil {
-		l = len(*m.Topic)
-		n += 1 + l + sovTrace(uint64(l))
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0x12
 	}
-	if len(m.MessageIDs) > 0 {
-		for _, b := range m.MessageIDs {
-			l = len(b)
-			n += 1 + l + sovTrace(uint64(l))
-		}
+	if m.PeerID != nil {
+		i -= len(m.PeerID)
+		copy(dAtA[i:], m.PeerID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceEvent_MalformedRPC) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_MalformedRPC) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_MalformedRPC) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
 	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	return n
+	if m.Entries != nil {
+		i = encodeVarintTrace(dAtA, i, uint64(*m.Entries))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.PeerID != nil {
+		i -= len(m.PeerID)
+		copy(dAtA[i:], m.PeerID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_ControlIWantMeta) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_ReannounceSubscriptions) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_ReannounceSubscriptions) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_ReannounceSubscriptions) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if len(m.MessageIDs) > 0 {
-		for _, b := range m.MessageIDs {
-			l = len(b)
-			n += 1 + l + sovTrace(uint64(l))
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if len(m.Topics) > 0 {
+		for iNdEx := len(m.Topics) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Topics[iNdEx])
+			copy(dAtA[i:], m.Topics[iNdEx])
+			i = encodeVarintTrace(dAtA, i, uint64(len(m.Topics[iNdEx])))
+			i--
+			dAtA[i] = 0x12
 		}
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	if m.PeerID != nil {
+		i -= len(m.PeerID)
+		copy(dAtA[i:], m.PeerID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
+		i--
+		dAtA[i] = 0xa
 	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_ControlGraftMeta) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_SlowConsumerThrottle) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_SlowConsumerThrottle) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_SlowConsumerThrottle) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.Topic != nil {
-		l = len(*m.Topic)
-		n += 1 + l + sovTrace(uint64(l))
-	}
 	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	return n
+	if m.Dropped != nil {
+		i = encodeVarintTrace(dAtA, i, uint64(*m.Dropped))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.Throttled != nil {
+		i--
+		if *m.Throttled {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.Topic != nil {
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceEvent_ControlPruneMeta) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceEvent_TopicConfig) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_TopicConfig) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_TopicConfig) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.FloodPublish != nil {
+		i--
+		if *m.FloodPublish {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x30
+	}
+	if m.Dhi != nil {
+		i = encodeVarintTrace(dAtA, i, uint64(*m.Dhi))
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.Dlo != nil {
+		i = encodeVarintTrace(dAtA, i, uint64(*m.Dlo))
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.D != nil {
+		i = encodeVarintTrace(dAtA, i, uint64(*m.D))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.ScoreParams != nil {
+		i--
+		if *m.ScoreParams {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x10
+	}
 	if m.Topic != nil {
-		l = len(*m.Topic)
-		n += 1 + l + sovTrace(uint64(l))
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0xa
 	}
-	if len(m.Peers) > 0 {
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceEvent_PeerSubscribe) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_PeerSubscribe) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_PeerSubscribe) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.Topic != nil {
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.PeerID != nil {
+		i -= len(m.PeerID)
+		copy(dAtA[i:], m.PeerID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceEvent_PeerUnsubscribe) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_PeerUnsubscribe) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_PeerUnsubscribe) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.Topic != nil {
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.PeerID != nil {
+		i -= len(m.PeerID)
+		copy(dAtA[i:], m.PeerID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceEvent_IWantMiss) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_IWantMiss) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_IWantMiss) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.MessageID != nil {
+		i -= len(m.MessageID)
+		copy(dAtA[i:], m.MessageID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.MessageID)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.PeerID != nil {
+		i -= len(m.PeerID)
+		copy(dAtA[i:], m.PeerID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.PeerID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceEvent_RPCMeta) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_RPCMeta) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_RPCMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.Control != nil {
+		{
+			size, err := m.Control.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTrace(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.Subscription) > 0 {
+		for iNdEx := len(m.Subscription) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Subscription[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTrace(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if len(m.Messages) > 0 {
+		for iNdEx := len(m.Messages) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Messages[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTrace(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceEvent_MessageMeta) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_MessageMeta) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_MessageMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.Topic != nil {
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.MessageID != nil {
+		i -= len(m.MessageID)
+		copy(dAtA[i:], m.MessageID)
+		i = encodeVarintTrace(dAtA, i, uint64(len(m.MessageID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceEvent_SubMeta) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_SubMeta) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_SubMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.Topic != nil {
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.Subscribe != nil {
+		i--
+		if *m.Subscribe {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceEvent_ControlMeta) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_ControlMeta) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_ControlMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if len(m.Prune) > 0 {
+		for iNdEx := len(m.Prune) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Prune[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTrace(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x22
+		}
+	}
+	if len(m.Graft) > 0 {
+		for iNdEx := len(m.Graft) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Graft[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTrace(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x1a
+		}
+	}
+	if len(m.Iwant) > 0 {
+		for iNdEx := len(m.Iwant) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Iwant[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTrace(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if len(m.Ihave) > 0 {
+		for iNdEx := len(m.Ihave) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Ihave[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTrace(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceEvent_ControlIHaveMeta) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_ControlIHaveMeta) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_ControlIHaveMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if len(m.MessageIDs) > 0 {
+		for iNdEx := len(m.MessageIDs) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.MessageIDs[iNdEx])
+			copy(dAtA[i:], m.MessageIDs[iNdEx])
+			i = encodeVarintTrace(dAtA, i, uint64(len(m.MessageIDs[iNdEx])))
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if m.Topic != nil {
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceEvent_ControlIWantMeta) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_ControlIWantMeta) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_ControlIWantMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if len(m.MessageIDs) > 0 {
+		for iNdEx := len(m.MessageIDs) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.MessageIDs[iNdEx])
+			copy(dAtA[i:], m.MessageIDs[iNdEx])
+			i = encodeVarintTrace(dAtA, i, uint64(len(m.MessageIDs[iNdEx])))
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceEvent_ControlGraftMeta) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_ControlGraftMeta) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_ControlGraftMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.Topic != nil {
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceEvent_ControlPruneMeta) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEvent_ControlPruneMeta) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEvent_ControlPruneMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.Reason != nil {
+		i = encodeVarintTrace(dAtA, i, uint64(*m.Reason))
+		i--
+		dAtA[i] = 0x18
+	}
+	if len(m.Peers) > 0 {
+		for iNdEx := len(m.Peers) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Peers[iNdEx])
+			copy(dAtA[i:], m.Peers[iNdEx])
+			i = encodeVarintTrace(dAtA, i, uint64(len(m.Peers[iNdEx])))
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if m.Topic != nil {
+		i -= len(*m.Topic)
+		copy(dAtA[i:], *m.Topic)
+		i = encodeVarintTrace(dAtA, i, uint64(len(*m.Topic)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceEventBatch) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceEventBatch) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceEventBatch) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.SchemaVersion != nil {
+		i = encodeVarintTrace(dAtA, i, uint64(*m.SchemaVersion))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.Batch) > 0 {
+		for iNdEx := len(m.Batch) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Batch[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTrace(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceHandshake) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceHandshake) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceHandshake) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.EventTypes != nil {
+		i = encodeVarintTrace(dAtA, i, uint64(*m.EventTypes))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.SchemaVersion != nil {
+		i = encodeVarintTrace(dAtA, i, uint64(*m.SchemaVersion))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintTrace(dAtA []byte, offset int, v uint64) int {
+	offset -= sovTrace(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *TraceEvent) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Type != nil {
+		n += 1 + sovTrace(uint64(*m.Type))
+	}
+	if m.PeerID != nil {
+		l = len(m.PeerID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Timestamp != nil {
+		n += 1 + sovTrace(uint64(*m.Timestamp))
+	}
+	if m.PublishMessage != nil {
+		l = m.PublishMessage.Size()
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.RejectMessage != nil {
+		l = m.RejectMessage.Size()
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.DuplicateMessage != nil {
+		l = m.DuplicateMessage.Size()
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.DeliverMessage != nil {
+		l = m.DeliverMessage.Size()
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.AddPeer != nil {
+		l = m.AddPeer.Size()
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.RemovePeer != nil {
+		l = m.RemovePeer.Size()
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.RecvRPC != nil {
+		l = m.RecvRPC.Size()
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.SendRPC != nil {
+		l = m.SendRPC.Size()
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.DropRPC != nil {
+		l = m.DropRPC.Size()
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Join != nil {
+		l = m.Join.Size()
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Leave != nil {
+		l = m.Leave.Size()
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Graft != nil {
+		l = m.Graft.Size()
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Prune != nil {
+		l = m.Prune.Size()
+		n += 2 + l + sovTrace(uint64(l))
+	}
+	if m.IgnoreIHave != nil {
+		l = m.IgnoreIHave.Size()
+		n += 2 + l + sovTrace(uint64(l))
+	}
+	if m.RejectRPC != nil {
+		l = m.RejectRPC.Size()
+		n += 2 + l + sovTrace(uint64(l))
+	}
+	if m.WriteTimeout != nil {
+		l = m.WriteTimeout.Size()
+		n += 2 + l + sovTrace(uint64(l))
+	}
+	if m.CircuitBreaker != nil {
+		l = m.CircuitBreaker.Size()
+		n += 2 + l + sovTrace(uint64(l))
+	}
+	if m.StaleMessage != nil {
+		l = m.StaleMessage.Size()
+		n += 2 + l + sovTrace(uint64(l))
+	}
+	if m.SubscriptionChurn != nil {
+		l = m.SubscriptionChurn.Size()
+		n += 2 + l + sovTrace(uint64(l))
+	}
+	if m.MalformedRPC != nil {
+		l = m.MalformedRPC.Size()
+		n += 2 + l + sovTrace(uint64(l))
+	}
+	if m.ReannounceSubscriptions != nil {
+		l = m.ReannounceSubscriptions.Size()
+		n += 2 + l + sovTrace(uint64(l))
+	}
+	if m.SlowConsumerThrottle != nil {
+		l = m.SlowConsumerThrottle.Size()
+		n += 2 + l + sovTrace(uint64(l))
+	}
+	if m.TopicConfig != nil {
+		l = m.TopicConfig.Size()
+		n += 2 + l + sovTrace(uint64(l))
+	}
+	if m.PeerSubscribe != nil {
+		l = m.PeerSubscribe.Size()
+		n += 2 + l + sovTrace(uint64(l))
+	}
+	if m.PeerUnsubscribe != nil {
+		l = m.PeerUnsubscribe.Size()
+		n += 2 + l + sovTrace(uint64(l))
+	}
+	if m.IwantMiss != nil {
+		l = m.IwantMiss.Size()
+		n += 2 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_PublishMessage) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.MessageID != nil {
+		l = len(m.MessageID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_RejectMessage) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.MessageID != nil {
+		l = len(m.MessageID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.ReceivedFrom != nil {
+		l = len(m.ReceivedFrom)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Reason != nil {
+		l = len(*m.Reason)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_DuplicateMessage) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.MessageID != nil {
+		l = len(m.MessageID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.ReceivedFrom != nil {
+		l = len(m.ReceivedFrom)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_DeliverMessage) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.MessageID != nil {
+		l = len(m.MessageID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.ReceivedFrom != nil {
+		l = len(m.ReceivedFrom)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_AddPeer) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.PeerID != nil {
+		l = len(m.PeerID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Proto != nil {
+		l = len(*m.Proto)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_RemovePeer) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.PeerID != nil {
+		l = len(m.PeerID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_RecvRPC) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.ReceivedFrom != nil {
+		l = len(m.ReceivedFrom)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Meta != nil {
+		l = m.Meta.Size()
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_SendRPC) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.SendTo != nil {
+		l = len(m.SendTo)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Meta != nil {
+		l = m.Meta.Size()
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_DropRPC) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.SendTo != nil {
+		l = len(m.SendTo)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Meta != nil {
+		l = m.Meta.Size()
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_Join) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_Leave) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_Graft) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.PeerID != nil {
+		l = len(m.PeerID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_Prune) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.PeerID != nil {
+		l = len(m.PeerID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Reason != nil {
+		n += 1 + sovTrace(uint64(*m.Reason))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_IgnoreIHave) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.PeerID != nil {
+		l = len(m.PeerID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Reason != nil {
+		n += 1 + sovTrace(uint64(*m.Reason))
+	}
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_RejectRPC) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.PeerID != nil {
+		l = len(m.PeerID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Limit != nil {
+		n += 1 + sovTrace(uint64(*m.Limit))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_WriteTimeout) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.PeerID != nil {
+		l = len(m.PeerID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_CircuitBreaker) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.State != nil {
+		n += 1 + sovTrace(uint64(*m.State))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_StaleMessage) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.MessageID != nil {
+		l = len(m.MessageID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.PeerID != nil {
+		l = len(m.PeerID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_SubscriptionChurn) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.PeerID != nil {
+		l = len(m.PeerID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Cooldown != nil {
+		n += 1 + sovTrace(uint64(*m.Cooldown))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_MalformedRPC) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.PeerID != nil {
+		l = len(m.PeerID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Entries != nil {
+		n += 1 + sovTrace(uint64(*m.Entries))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_ReannounceSubscriptions) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.PeerID != nil {
+		l = len(m.PeerID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if len(m.Topics) > 0 {
+		for _, s := range m.Topics {
+			l = len(s)
+			n += 1 + l + sovTrace(uint64(l))
+		}
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_SlowConsumerThrottle) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Throttled != nil {
+		n += 2
+	}
+	if m.Dropped != nil {
+		n += 1 + sovTrace(uint64(*m.Dropped))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_TopicConfig) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.ScoreParams != nil {
+		n += 2
+	}
+	if m.D != nil {
+		n += 1 + sovTrace(uint64(*m.D))
+	}
+	if m.Dlo != nil {
+		n += 1 + sovTrace(uint64(*m.Dlo))
+	}
+	if m.Dhi != nil {
+		n += 1 + sovTrace(uint64(*m.Dhi))
+	}
+	if m.FloodPublish != nil {
+		n += 2
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_PeerSubscribe) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.PeerID != nil {
+		l = len(m.PeerID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_PeerUnsubscribe) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.PeerID != nil {
+		l = len(m.PeerID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_IWantMiss) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.PeerID != nil {
+		l = len(m.PeerID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.MessageID != nil {
+		l = len(m.MessageID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_RPCMeta) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Messages) > 0 {
+		for _, e := range m.Messages {
+			l = e.Size()
+			n += 1 + l + sovTrace(uint64(l))
+		}
+	}
+	if len(m.Subscription) > 0 {
+		for _, e := range m.Subscription {
+			l = e.Size()
+			n += 1 + l + sovTrace(uint64(l))
+		}
+	}
+	if m.Control != nil {
+		l = m.Control.Size()
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_MessageMeta) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.MessageID != nil {
+		l = len(m.MessageID)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_SubMeta) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Subscribe != nil {
+		n += 2
+	}
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_ControlMeta) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Ihave) > 0 {
+		for _, e := range m.Ihave {
+			l = e.Size()
+			n += 1 + l + sovTrace(uint64(l))
+		}
+	}
+	if len(m.Iwant) > 0 {
+		for _, e := range m.Iwant {
+			l = e.Size()
+			n += 1 + l + sovTrace(uint64(l))
+		}
+	}
+	if len(m.Graft) > 0 {
+		for _, e := range m.Graft {
+			l = e.Size()
+			n += 1 + l + sovTrace(uint64(l))
+		}
+	}
+	if len(m.Prune) > 0 {
+		for _, e := range m.Prune {
+			l = e.Size()
+			n += 1 + l + sovTrace(uint64(l))
+		}
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_ControlIHaveMeta) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if len(m.MessageIDs) > 0 {
+		for _, b := range m.MessageIDs {
+			l = len(b)
+			n += 1 + l + sovTrace(uint64(l))
+		}
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_ControlIWantMeta) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.MessageIDs) > 0 {
+		for _, b := range m.MessageIDs {
+			l = len(b)
+			n += 1 + l + sovTrace(uint64(l))
+		}
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_ControlGraftMeta) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEvent_ControlPruneMeta) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Topic != nil {
+		l = len(*m.Topic)
+		n += 1 + l + sovTrace(uint64(l))
+	}
+	if len(m.Peers) > 0 {
 		for _, b := range m.Peers {
 			l = len(b)
 			n += 1 + l + sovTrace(uint64(l))
 		}
 	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
-	}
-	return n
-}
+	if m.Reason != nil {
+		n += 1 + sovTrace(uint64(*m.Reason))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceEventBatch) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Batch) > 0 {
+		for _, e := range m.Batch {
+			l = e.Size()
+			n += 1 + l + sovTrace(uint64(l))
+		}
+	}
+	if m.SchemaVersion != nil {
+		n += 1 + sovTrace(uint64(*m.SchemaVersion))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *TraceHandshake) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.SchemaVersion != nil {
+		n += 1 + sovTrace(uint64(*m.SchemaVersion))
+	}
+	if m.EventTypes != nil {
+		n += 1 + sovTrace(uint64(*m.EventTypes))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func sovTrace(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozTrace(x uint64) (n int) {
+	return sovTrace(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *TraceEvent) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTrace
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TraceEvent: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TraceEvent: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Type", wireType)
+			}
+			var v TraceEvent_Type
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= TraceEvent_Type(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Type = &v
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PeerID", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PeerID = append(m.PeerID[:0], dAtA[iNdEx:postIndex]...)
+			if m.PeerID == nil {
+				m.PeerID = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
+			}
+			var v int64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Timestamp = &v
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PublishMessage", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.PublishMessage == nil {
+				m.PublishMessage = &TraceEvent_PublishMessage{}
+			}
+			if err := m.PublishMessage.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RejectMessage", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.RejectMessage == nil {
+				m.RejectMessage = &TraceEvent_RejectMessage{}
+			}
+			if err := m.RejectMessage.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DuplicateMessage", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.DuplicateMessage == nil {
+				m.DuplicateMessage = &TraceEvent_DuplicateMessage{}
+			}
+			if err := m.DuplicateMessage.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DeliverMessage", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.DeliverMessage == nil {
+				m.DeliverMessage = &TraceEvent_DeliverMessage{}
+			}
+			if err := m.DeliverMessage.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AddPeer", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.AddPeer == nil {
+				m.AddPeer = &TraceEvent_AddPeer{}
+			}
+			if err := m.AddPeer.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RemovePeer", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.RemovePeer == nil {
+				m.RemovePeer = &TraceEvent_RemovePeer{}
+			}
+			if err := m.RemovePeer.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RecvRPC", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.RecvRPC == nil {
+				m.RecvRPC = &TraceEvent_RecvRPC{}
+			}
+			if err := m.RecvRPC.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SendRPC", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.SendRPC == nil {
+				m.SendRPC = &TraceEvent_SendRPC{}
+			}
+			if err := m.SendRPC.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 12:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DropRPC", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.DropRPC == nil {
+				m.DropRPC = &TraceEvent_DropRPC{}
+			}
+			if err := m.DropRPC.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 13:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Join", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Join == nil {
+				m.Join = &TraceEvent_Join{}
+			}
+			if err := m.Join.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 14:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Leave", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Leave == nil {
+				m.Leave = &TraceEvent_Leave{}
+			}
+			if err := m.Leave.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 15:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Graft", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Graft == nil {
+				m.Graft = &TraceEvent_Graft{}
+			}
+			if err := m.Graft.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 16:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Prune", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Prune == nil {
+				m.Prune = &TraceEvent_Prune{}
+			}
+			if err := m.Prune.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 17:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IgnoreIHave", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.IgnoreIHave == nil {
+				m.IgnoreIHave = &TraceEvent_IgnoreIHave{}
+			}
+			if err := m.IgnoreIHave.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 18:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RejectRPC", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.RejectRPC == nil {
+				m.RejectRPC = &TraceEvent_RejectRPC{}
+			}
+			if err := m.RejectRPC.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 19:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field WriteTimeout", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.WriteTimeout == nil {
+				m.WriteTimeout = &TraceEvent_WriteTimeout{}
+			}
+			if err := m.WriteTimeout.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 20:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CircuitBreaker", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.CircuitBreaker == nil {
+				m.CircuitBreaker = &TraceEvent_CircuitBreaker{}
+			}
+			if err := m.CircuitBreaker.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 21:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StaleMessage", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.StaleMessage == nil {
+				m.StaleMessage = &TraceEvent_StaleMessage{}
+			}
+			if err := m.StaleMessage.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 22:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SubscriptionChurn", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.SubscriptionChurn == nil {
+				m.SubscriptionChurn = &TraceEvent_SubscriptionChurn{}
+			}
+			if err := m.SubscriptionChurn.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 23:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MalformedRPC", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.MalformedRPC == nil {
+				m.MalformedRPC = &TraceEvent_MalformedRPC{}
+			}
+			if err := m.MalformedRPC.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 24:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReannounceSubscriptions", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.ReannounceSubscriptions == nil {
+				m.ReannounceSubscriptions = &TraceEvent_ReannounceSubscriptions{}
+			}
+			if err := m.ReannounceSubscriptions.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 25:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SlowConsumerThrottle", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.SlowConsumerThrottle == nil {
+				m.SlowConsumerThrottle = &TraceEvent_SlowConsumerThrottle{}
+			}
+			if err := m.SlowConsumerThrottle.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 26:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TopicConfig", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.TopicConfig == nil {
+				m.TopicConfig = &TraceEvent_TopicConfig{}
+			}
+			if err := m.TopicConfig.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 27:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PeerSubscribe", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.PeerSubscribe == nil {
+				m.PeerSubscribe = &TraceEvent_PeerSubscribe{}
+			}
+			if err := m.PeerSubscribe.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 28:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PeerUnsubscribe", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.PeerUnsubscribe == nil {
+				m.PeerUnsubscribe = &TraceEvent_PeerUnsubscribe{}
+			}
+			if err := m.PeerUnsubscribe.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 29:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IwantMiss", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.IwantMiss == nil {
+				m.IwantMiss = &TraceEvent_IWantMiss{}
+			}
+			if err := m.IwantMiss.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTrace(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceEvent_PublishMessage) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTrace
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: PublishMessage: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: PublishMessage: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MessageID", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.MessageID = append(m.MessageID[:0], dAtA[iNdEx:postIndex]...)
+			if m.MessageID == nil {
+				m.MessageID = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			s := string(dAtA[iNdEx:postIndex])
+			m.Topic = &s
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTrace(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceEvent_RejectMessage) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTrace
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RejectMessage: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RejectMessage: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MessageID", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.MessageID = append(m.MessageID[:0], dAtA[iNdEx:postIndex]...)
+			if m.MessageID == nil {
+				m.MessageID = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReceivedFrom", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ReceivedFrom = append(m.ReceivedFrom[:0], dAtA[iNdEx:postIndex]...)
+			if m.ReceivedFrom == nil {
+				m.ReceivedFrom = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			s := string(dAtA[iNdEx:postIndex])
+			m.Reason = &s
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			s := string(dAtA[iNdEx:postIndex])
+			m.Topic = &s
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTrace(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceEvent_DuplicateMessage) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTrace
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: DuplicateMessage: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: DuplicateMessage: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MessageID", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.MessageID = append(m.MessageID[:0], dAtA[iNdEx:postIndex]...)
+			if m.MessageID == nil {
+				m.MessageID = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReceivedFrom", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ReceivedFrom = append(m.ReceivedFrom[:0], dAtA[iNdEx:postIndex]...)
+			if m.ReceivedFrom == nil {
+				m.ReceivedFrom = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			s := string(dAtA[iNdEx:postIndex])
+			m.Topic = &s
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTrace(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
 
-func (m *TraceEventBatch) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if len(m.Batch) > 0 {
-		for _, e := range m.Batch {
-			l = e.Size()
-			n += 1 + l + sovTrace(uint64(l))
-		}
-	}
-	if m.XXX_unrecognized != nil {
-		n += len(m.XXX_unrecognized)
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
 	}
-	return n
-}
-
-func sovTrace(x uint64) (n int) {
-	return (math_bits.Len64(x|1) + 6) / 7
-}
-func sozTrace(x uint64) (n int) {
-	return sovTrace(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+	return nil
 }
-func (m *TraceEvent) Unmarshal(dAtA []byte) error {
+func (m *TraceEvent_DeliverMessage) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3342,17 +7249,17 @@ func (m *TraceEvent) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: TraceEvent: wiretype end group for non-group")
+			return fmt.Errorf("proto: DeliverMessage: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: TraceEvent: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: DeliverMessage: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Type", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MessageID", wireType)
 			}
-			var v TraceEvent_Type
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -3362,13 +7269,145 @@ func (m *TraceEvent) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				v |= TraceEvent_Type(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			m.Type = &v
+			if byteLen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.MessageID = append(m.MessageID[:0], dAtA[iNdEx:postIndex]...)
+			if m.MessageID == nil {
+				m.MessageID = []byte{}
+			}
+			iNdEx = postIndex
 		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			s := string(dAtA[iNdEx:postIndex])
+			m.Topic = &s
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReceivedFrom", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ReceivedFrom = append(m.ReceivedFrom[:0], dAtA[iNdEx:postIndex]...)
+			if m.ReceivedFrom == nil {
+				m.ReceivedFrom = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTrace(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceEvent_AddPeer) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTrace
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: AddPeer: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: AddPeer: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field PeerID", wireType)
 			}
@@ -3402,11 +7441,11 @@ func (m *TraceEvent) Unmarshal(dAtA []byte) error {
 				m.PeerID = []byte{}
 			}
 			iNdEx = postIndex
-		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Proto", wireType)
 			}
-			var v int64
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -3416,17 +7455,81 @@ func (m *TraceEvent) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				v |= int64(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			m.Timestamp = &v
-		case 4:
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			s := string(dAtA[iNdEx:postIndex])
+			m.Proto = &s
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTrace(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceEvent_RemovePeer) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTrace
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RemovePeer: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RemovePeer: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field PublishMessage", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field PeerID", wireType)
 			}
-			var msglen int
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -3436,33 +7539,82 @@ func (m *TraceEvent) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthTrace
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + byteLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTrace
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.PublishMessage == nil {
-				m.PublishMessage = &TraceEvent_PublishMessage{}
+			m.PeerID = append(m.PeerID[:0], dAtA[iNdEx:postIndex]...)
+			if m.PeerID == nil {
+				m.PeerID = []byte{}
 			}
-			if err := m.PublishMessage.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTrace(dAtA[iNdEx:])
+			if err != nil {
 				return err
 			}
-			iNdEx = postIndex
-		case 5:
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceEvent_RecvRPC) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTrace
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RecvRPC: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RecvRPC: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field RejectMessage", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ReceivedFrom", wireType)
 			}
-			var msglen int
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -3472,31 +7624,29 @@ func (m *TraceEvent) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthTrace
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + byteLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTrace
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.RejectMessage == nil {
-				m.RejectMessage = &TraceEvent_RejectMessage{}
-			}
-			if err := m.RejectMessage.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			m.ReceivedFrom = append(m.ReceivedFrom[:0], dAtA[iNdEx:postIndex]...)
+			if m.ReceivedFrom == nil {
+				m.ReceivedFrom = []byte{}
 			}
 			iNdEx = postIndex
-		case 6:
+		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field DuplicateMessage", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Meta", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -3523,18 +7673,69 @@ func (m *TraceEvent) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.DuplicateMessage == nil {
-				m.DuplicateMessage = &TraceEvent_DuplicateMessage{}
+			if m.Meta == nil {
+				m.Meta = &TraceEvent_RPCMeta{}
 			}
-			if err := m.DuplicateMessage.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if err := m.Meta.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
-		case 7:
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTrace(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceEvent_SendRPC) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTrace
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SendRPC: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SendRPC: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field DeliverMessage", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field SendTo", wireType)
 			}
-			var msglen int
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -3544,31 +7745,29 @@ func (m *TraceEvent) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthTrace
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + byteLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTrace
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.DeliverMessage == nil {
-				m.DeliverMessage = &TraceEvent_DeliverMessage{}
-			}
-			if err := m.DeliverMessage.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			m.SendTo = append(m.SendTo[:0], dAtA[iNdEx:postIndex]...)
+			if m.SendTo == nil {
+				m.SendTo = []byte{}
 			}
 			iNdEx = postIndex
-		case 8:
+		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field AddPeer", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Meta", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -3595,54 +7794,69 @@ func (m *TraceEvent) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.AddPeer == nil {
-				m.AddPeer = &TraceEvent_AddPeer{}
+			if m.Meta == nil {
+				m.Meta = &TraceEvent_RPCMeta{}
 			}
-			if err := m.AddPeer.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if err := m.Meta.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
-		case 9:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field RemovePeer", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowTrace
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthTrace
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTrace(dAtA[iNdEx:])
+			if err != nil {
+				return err
 			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthTrace
 			}
-			if postIndex > l {
+			if (iNdEx + skippy) > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.RemovePeer == nil {
-				m.RemovePeer = &TraceEvent_RemovePeer{}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceEvent_DropRPC) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTrace
 			}
-			if err := m.RemovePeer.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
 			}
-			iNdEx = postIndex
-		case 10:
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: DropRPC: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: DropRPC: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field RecvRPC", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field SendTo", wireType)
 			}
-			var msglen int
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -3652,31 +7866,29 @@ func (m *TraceEvent) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthTrace
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + byteLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTrace
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.RecvRPC == nil {
-				m.RecvRPC = &TraceEvent_RecvRPC{}
-			}
-			if err := m.RecvRPC.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			m.SendTo = append(m.SendTo[:0], dAtA[iNdEx:postIndex]...)
+			if m.SendTo == nil {
+				m.SendTo = []byte{}
 			}
 			iNdEx = postIndex
-		case 11:
+		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field SendRPC", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Meta", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -3703,54 +7915,69 @@ func (m *TraceEvent) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.SendRPC == nil {
-				m.SendRPC = &TraceEvent_SendRPC{}
+			if m.Meta == nil {
+				m.Meta = &TraceEvent_RPCMeta{}
 			}
-			if err := m.SendRPC.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if err := m.Meta.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
-		case 12:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field DropRPC", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowTrace
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthTrace
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTrace(dAtA[iNdEx:])
+			if err != nil {
+				return err
 			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthTrace
 			}
-			if postIndex > l {
+			if (iNdEx + skippy) > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.DropRPC == nil {
-				m.DropRPC = &TraceEvent_DropRPC{}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceEvent_Join) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTrace
 			}
-			if err := m.DropRPC.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
 			}
-			iNdEx = postIndex
-		case 13:
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Join: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Join: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Join", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -3760,33 +7987,81 @@ func (m *TraceEvent) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthTrace
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTrace
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Join == nil {
-				m.Join = &TraceEvent_Join{}
-			}
-			if err := m.Join.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			s := string(dAtA[iNdEx:postIndex])
+			m.Topic = &s
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTrace(dAtA[iNdEx:])
+			if err != nil {
 				return err
 			}
-			iNdEx = postIndex
-		case 14:
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceEvent_Leave) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTrace
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Leave: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Leave: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Leave", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -3796,33 +8071,81 @@ func (m *TraceEvent) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthTrace
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTrace
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Leave == nil {
-				m.Leave = &TraceEvent_Leave{}
-			}
-			if err := m.Leave.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			s := string(dAtA[iNdEx:postIndex])
+			m.Topic = &s
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTrace(dAtA[iNdEx:])
+			if err != nil {
 				return err
 			}
-			iNdEx = postIndex
-		case 15:
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceEvent_Graft) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTrace
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Graft: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Graft: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Graft", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field PeerID", wireType)
 			}
-			var msglen int
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -3832,33 +8155,31 @@ func (m *TraceEvent) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthTrace
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + byteLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTrace
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Graft == nil {
-				m.Graft = &TraceEvent_Graft{}
-			}
-			if err := m.Graft.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			m.PeerID = append(m.PeerID[:0], dAtA[iNdEx:postIndex]...)
+			if m.PeerID == nil {
+				m.PeerID = []byte{}
 			}
 			iNdEx = postIndex
-		case 16:
+		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Prune", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -3868,27 +8189,24 @@ func (m *TraceEvent) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthTrace
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTrace
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Prune == nil {
-				m.Prune = &TraceEvent_Prune{}
-			}
-			if err := m.Prune.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			s := string(dAtA[iNdEx:postIndex])
+			m.Topic = &s
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -3912,7 +8230,7 @@ func (m *TraceEvent) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TraceEvent_PublishMessage) Unmarshal(dAtA []byte) error {
+func (m *TraceEvent_Prune) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3935,15 +8253,15 @@ func (m *TraceEvent_PublishMessage) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: PublishMessage: wiretype end group for non-group")
+			return fmt.Errorf("proto: Prune: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: PublishMessage: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: Prune: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field MessageID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field PeerID", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -3970,9 +8288,9 @@ func (m *TraceEvent_PublishMessage) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.MessageID = append(m.MessageID[:0], dAtA[iNdEx:postIndex]...)
-			if m.MessageID == nil {
-				m.MessageID = []byte{}
+			m.PeerID = append(m.PeerID[:0], dAtA[iNdEx:postIndex]...)
+			if m.PeerID == nil {
+				m.PeerID = []byte{}
 			}
 			iNdEx = postIndex
 		case 2:
@@ -4008,6 +8326,26 @@ func (m *TraceEvent_PublishMessage) Unmarshal(dAtA []byte) error {
 			s := string(dAtA[iNdEx:postIndex])
 			m.Topic = &s
 			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
+			}
+			var v ControlPrune_Reason
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= ControlPrune_Reason(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Reason = &v
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTrace(dAtA[iNdEx:])
@@ -4030,7 +8368,7 @@ func (m *TraceEvent_PublishMessage) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TraceEvent_RejectMessage) Unmarshal(dAtA []byte) error {
+func (m *TraceEvent_IgnoreIHave) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -4053,15 +8391,15 @@ func (m *TraceEvent_RejectMessage) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: RejectMessage: wiretype end group for non-group")
+			return fmt.Errorf("proto: IgnoreIHave: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: RejectMessage: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: IgnoreIHave: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field MessageID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field PeerID", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -4088,16 +8426,16 @@ func (m *TraceEvent_RejectMessage) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.MessageID = append(m.MessageID[:0], dAtA[iNdEx:postIndex]...)
-			if m.MessageID == nil {
-				m.MessageID = []byte{}
+			m.PeerID = append(m.PeerID[:0], dAtA[iNdEx:postIndex]...)
+			if m.PeerID == nil {
+				m.PeerID = []byte{}
 			}
 			iNdEx = postIndex
 		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ReceivedFrom", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
 			}
-			var byteLen int
+			var v TraceEvent_IgnoreIHave_Reason
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -4107,29 +8445,15 @@ func (m *TraceEvent_RejectMessage) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= int(b&0x7F) << shift
+				v |= TraceEvent_IgnoreIHave_Reason(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
-				return ErrInvalidLengthTrace
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthTrace
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.ReceivedFrom = append(m.ReceivedFrom[:0], dAtA[iNdEx:postIndex]...)
-			if m.ReceivedFrom == nil {
-				m.ReceivedFrom = []byte{}
-			}
-			iNdEx = postIndex
+			m.Reason = &v
 		case 3:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -4158,13 +8482,64 @@ func (m *TraceEvent_RejectMessage) Unmarshal(dAtA []byte) error {
 				return io.ErrUnexpectedEOF
 			}
 			s := string(dAtA[iNdEx:postIndex])
-			m.Reason = &s
+			m.Topic = &s
 			iNdEx = postIndex
-		case 4:
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTrace(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceEvent_RejectRPC) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTrace
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RejectRPC: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RejectRPC: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field PeerID", wireType)
 			}
-			var stringLen uint64
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -4174,25 +8549,46 @@ func (m *TraceEvent_RejectMessage) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthTrace
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + byteLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTrace
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			s := string(dAtA[iNdEx:postIndex])
-			m.Topic = &s
+			m.PeerID = append(m.PeerID[:0], dAtA[iNdEx:postIndex]...)
+			if m.PeerID == nil {
+				m.PeerID = []byte{}
+			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Limit", wireType)
+			}
+			var v TraceEvent_RejectRPC_Limit
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= TraceEvent_RejectRPC_Limit(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Limit = &v
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTrace(dAtA[iNdEx:])
@@ -4215,7 +8611,7 @@ func (m *TraceEvent_RejectMessage) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TraceEvent_DuplicateMessage) Unmarshal(dAtA []byte) error {
+func (m *TraceEvent_WriteTimeout) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -4238,15 +8634,15 @@ func (m *TraceEvent_DuplicateMessage) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: DuplicateMessage: wiretype end group for non-group")
+			return fmt.Errorf("proto: WriteTimeout: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: DuplicateMessage: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: WriteTimeout: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field MessageID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field PeerID", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -4273,16 +8669,67 @@ func (m *TraceEvent_DuplicateMessage) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.MessageID = append(m.MessageID[:0], dAtA[iNdEx:postIndex]...)
-			if m.MessageID == nil {
-				m.MessageID = []byte{}
+			m.PeerID = append(m.PeerID[:0], dAtA[iNdEx:postIndex]...)
+			if m.PeerID == nil {
+				m.PeerID = []byte{}
 			}
 			iNdEx = postIndex
-		case 2:
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTrace(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceEvent_CircuitBreaker) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTrace
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: CircuitBreaker: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: CircuitBreaker: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ReceivedFrom", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
 			}
-			var byteLen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -4292,31 +8739,30 @@ func (m *TraceEvent_DuplicateMessage) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthTrace
 			}
-			postIndex := iNdEx + byteLen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTrace
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.ReceivedFrom = append(m.ReceivedFrom[:0], dAtA[iNdEx:postIndex]...)
-			if m.ReceivedFrom == nil {
-				m.ReceivedFrom = []byte{}
-			}
+			s := string(dAtA[iNdEx:postIndex])
+			m.Topic = &s
 			iNdEx = postIndex
-		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field State", wireType)
 			}
-			var stringLen uint64
+			var v TraceEvent_CircuitBreaker_State
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -4326,25 +8772,12 @@ func (m *TraceEvent_DuplicateMessage) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				v |= TraceEvent_CircuitBreaker_State(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthTrace
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
-				return ErrInvalidLengthTrace
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			s := string(dAtA[iNdEx:postIndex])
-			m.Topic = &s
-			iNdEx = postIndex
+			m.State = &v
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTrace(dAtA[iNdEx:])
@@ -4367,7 +8800,7 @@ func (m *TraceEvent_DuplicateMessage) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TraceEvent_DeliverMessage) Unmarshal(dAtA []byte) error {
+func (m *TraceEvent_StaleMessage) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -4390,10 +8823,10 @@ func (m *TraceEvent_DeliverMessage) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: DeliverMessage: wiretype end group for non-group")
+			return fmt.Errorf("proto: StaleMessage: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: DeliverMessage: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: StaleMessage: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
@@ -4465,7 +8898,7 @@ func (m *TraceEvent_DeliverMessage) Unmarshal(dAtA []byte) error {
 			iNdEx = postIndex
 		case 3:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ReceivedFrom", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field PeerID", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -4492,9 +8925,9 @@ func (m *TraceEvent_DeliverMessage) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.ReceivedFrom = append(m.ReceivedFrom[:0], dAtA[iNdEx:postIndex]...)
-			if m.ReceivedFrom == nil {
-				m.ReceivedFrom = []byte{}
+			m.PeerID = append(m.PeerID[:0], dAtA[iNdEx:postIndex]...)
+			if m.PeerID == nil {
+				m.PeerID = []byte{}
 			}
 			iNdEx = postIndex
 		default:
@@ -4519,7 +8952,7 @@ func (m *TraceEvent_DeliverMessage) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TraceEvent_AddPeer) Unmarshal(dAtA []byte) error {
+func (m *TraceEvent_SubscriptionChurn) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -4542,10 +8975,10 @@ func (m *TraceEvent_AddPeer) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: AddPeer: wiretype end group for non-group")
+			return fmt.Errorf("proto: SubscriptionChurn: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: AddPeer: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: SubscriptionChurn: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
@@ -4584,7 +9017,7 @@ func (m *TraceEvent_AddPeer) Unmarshal(dAtA []byte) error {
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Proto", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -4613,8 +9046,28 @@ func (m *TraceEvent_AddPeer) Unmarshal(dAtA []byte) error {
 				return io.ErrUnexpectedEOF
 			}
 			s := string(dAtA[iNdEx:postIndex])
-			m.Proto = &s
+			m.Topic = &s
 			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Cooldown", wireType)
+			}
+			var v int64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Cooldown = &v
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTrace(dAtA[iNdEx:])
@@ -4637,7 +9090,7 @@ func (m *TraceEvent_AddPeer) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TraceEvent_RemovePeer) Unmarshal(dAtA []byte) error {
+func (m *TraceEvent_MalformedRPC) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -4660,10 +9113,10 @@ func (m *TraceEvent_RemovePeer) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: RemovePeer: wiretype end group for non-group")
+			return fmt.Errorf("proto: MalformedRPC: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: RemovePeer: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: MalformedRPC: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
@@ -4700,6 +9153,26 @@ func (m *TraceEvent_RemovePeer) Unmarshal(dAtA []byte) error {
 				m.PeerID = []byte{}
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Entries", wireType)
+			}
+			var v uint32
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Entries = &v
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTrace(dAtA[iNdEx:])
@@ -4722,7 +9195,7 @@ func (m *TraceEvent_RemovePeer) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TraceEvent_RecvRPC) Unmarshal(dAtA []byte) error {
+func (m *TraceEvent_ReannounceSubscriptions) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -4745,15 +9218,15 @@ func (m *TraceEvent_RecvRPC) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: RecvRPC: wiretype end group for non-group")
+			return fmt.Errorf("proto: ReannounceSubscriptions: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: RecvRPC: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: ReannounceSubscriptions: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ReceivedFrom", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field PeerID", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -4780,16 +9253,16 @@ func (m *TraceEvent_RecvRPC) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.ReceivedFrom = append(m.ReceivedFrom[:0], dAtA[iNdEx:postIndex]...)
-			if m.ReceivedFrom == nil {
-				m.ReceivedFrom = []byte{}
+			m.PeerID = append(m.PeerID[:0], dAtA[iNdEx:postIndex]...)
+			if m.PeerID == nil {
+				m.PeerID = []byte{}
 			}
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Meta", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Topics", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -4799,27 +9272,23 @@ func (m *TraceEvent_RecvRPC) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthTrace
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTrace
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Meta == nil {
-				m.Meta = &TraceEvent_RPCMeta{}
-			}
-			if err := m.Meta.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.Topics = append(m.Topics, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -4843,7 +9312,7 @@ func (m *TraceEvent_RecvRPC) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TraceEvent_SendRPC) Unmarshal(dAtA []byte) error {
+func (m *TraceEvent_SlowConsumerThrottle) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -4866,17 +9335,17 @@ func (m *TraceEvent_SendRPC) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SendRPC: wiretype end group for non-group")
+			return fmt.Errorf("proto: SlowConsumerThrottle: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SendRPC: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: SlowConsumerThrottle: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field SendTo", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
 			}
-			var byteLen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -4886,31 +9355,30 @@ func (m *TraceEvent_SendRPC) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthTrace
 			}
-			postIndex := iNdEx + byteLen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTrace
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.SendTo = append(m.SendTo[:0], dAtA[iNdEx:postIndex]...)
-			if m.SendTo == nil {
-				m.SendTo = []byte{}
-			}
+			s := string(dAtA[iNdEx:postIndex])
+			m.Topic = &s
 			iNdEx = postIndex
 		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Meta", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Throttled", wireType)
 			}
-			var msglen int
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -4920,28 +9388,33 @@ func (m *TraceEvent_SendRPC) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				v |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
-				return ErrInvalidLengthTrace
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthTrace
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.Meta == nil {
-				m.Meta = &TraceEvent_RPCMeta{}
+			b := bool(v != 0)
+			m.Throttled = &b
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Dropped", wireType)
 			}
-			if err := m.Meta.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			var v uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			iNdEx = postIndex
+			m.Dropped = &v
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTrace(dAtA[iNdEx:])
@@ -4964,7 +9437,7 @@ func (m *TraceEvent_SendRPC) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TraceEvent_DropRPC) Unmarshal(dAtA []byte) error {
+func (m *TraceEvent_TopicConfig) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -4987,17 +9460,111 @@ func (m *TraceEvent_DropRPC) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: DropRPC: wiretype end group for non-group")
+			return fmt.Errorf("proto: TopicConfig: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: DropRPC: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: TopicConfig: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field SendTo", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTrace
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			s := string(dAtA[iNdEx:postIndex])
+			m.Topic = &s
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ScoreParams", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			b := bool(v != 0)
+			m.ScoreParams = &b
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field D", wireType)
+			}
+			var v uint32
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.D = &v
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Dlo", wireType)
+			}
+			var v uint32
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			var byteLen int
+			m.Dlo = &v
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Dhi", wireType)
+			}
+			var v uint32
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -5007,31 +9574,17 @@ func (m *TraceEvent_DropRPC) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= int(b&0x7F) << shift
+				v |= uint32(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
-				return ErrInvalidLengthTrace
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthTrace
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.SendTo = append(m.SendTo[:0], dAtA[iNdEx:postIndex]...)
-			if m.SendTo == nil {
-				m.SendTo = []byte{}
-			}
-			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Meta", wireType)
+			m.Dhi = &v
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FloodPublish", wireType)
 			}
-			var msglen int
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -5041,28 +9594,13 @@ func (m *TraceEvent_DropRPC) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				v |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
-				return ErrInvalidLengthTrace
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthTrace
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.Meta == nil {
-				m.Meta = &TraceEvent_RPCMeta{}
-			}
-			if err := m.Meta.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
+			b := bool(v != 0)
+			m.FloodPublish = &b
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTrace(dAtA[iNdEx:])
@@ -5085,7 +9623,7 @@ func (m *TraceEvent_DropRPC) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TraceEvent_Join) Unmarshal(dAtA []byte) error {
+func (m *TraceEvent_PeerSubscribe) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -5108,17 +9646,17 @@ func (m *TraceEvent_Join) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Join: wiretype end group for non-group")
+			return fmt.Errorf("proto: PeerSubscribe: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Join: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: PeerSubscribe: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field PeerID", wireType)
 			}
-			var stringLen uint64
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -5128,76 +9666,26 @@ func (m *TraceEvent_Join) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthTrace
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + byteLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTrace
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			s := string(dAtA[iNdEx:postIndex])
-			m.Topic = &s
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipTrace(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if (skippy < 0) || (iNdEx+skippy) < 0 {
-				return ErrInvalidLengthTrace
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *TraceEvent_Leave) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowTrace
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
+			m.PeerID = append(m.PeerID[:0], dAtA[iNdEx:postIndex]...)
+			if m.PeerID == nil {
+				m.PeerID = []byte{}
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: Leave: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Leave: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
+			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
@@ -5253,7 +9741,7 @@ func (m *TraceEvent_Leave) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TraceEvent_Graft) Unmarshal(dAtA []byte) error {
+func (m *TraceEvent_PeerUnsubscribe) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -5276,10 +9764,10 @@ func (m *TraceEvent_Graft) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Graft: wiretype end group for non-group")
+			return fmt.Errorf("proto: PeerUnsubscribe: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Graft: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: PeerUnsubscribe: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
@@ -5371,7 +9859,7 @@ func (m *TraceEvent_Graft) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TraceEvent_Prune) Unmarshal(dAtA []byte) error {
+func (m *TraceEvent_IWantMiss) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -5394,10 +9882,10 @@ func (m *TraceEvent_Prune) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Prune: wiretype end group for non-group")
+			return fmt.Errorf("proto: IWantMiss: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Prune: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: IWantMiss: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
@@ -5436,9 +9924,9 @@ func (m *TraceEvent_Prune) Unmarshal(dAtA []byte) error {
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Topic", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field MessageID", wireType)
 			}
-			var stringLen uint64
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTrace
@@ -5448,24 +9936,25 @@ func (m *TraceEvent_Prune) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthTrace
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + byteLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTrace
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			s := string(dAtA[iNdEx:postIndex])
-			m.Topic = &s
+			m.MessageID = append(m.MessageID[:0], dAtA[iNdEx:postIndex]...)
+			if m.MessageID == nil {
+				m.MessageID = []byte{}
+			}
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -6431,6 +10920,26 @@ func (m *TraceEvent_ControlPruneMeta) Unmarshal(dAtA []byte) error {
 			m.Peers = append(m.Peers, make([]byte, postIndex-iNdEx))
 			copy(m.Peers[len(m.Peers)-1], dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
+			}
+			var v ControlPrune_Reason
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= ControlPrune_Reason(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Reason = &v
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTrace(dAtA[iNdEx:])
@@ -6516,6 +11025,117 @@ func (m *TraceEventBatch) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SchemaVersion", wireType)
+			}
+			var v uint32
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.SchemaVersion = &v
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTrace(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTrace
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceHandshake) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTrace
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TraceHandshake: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TraceHandshake: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SchemaVersion", wireType)
+			}
+			var v uint32
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.SchemaVersion = &v
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EventTypes", wireType)
+			}
+			var v uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTrace
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.EventTypes = &v
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTrace(dAtA[iNdEx:])
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"net"
 	"sort"
 	"sync"
 	"time"
@@ -27,6 +28,32 @@ var (
 	DefaultPeerGaterSourceDecay     = ScoreParameterDecay(time.Hour)
 )
 
+// PeerGaterAccounting selects how the peer gater aggregates the statistics of peers: the peers
+// in the same accounting bucket share their statistics, and are throttled together.
+type PeerGaterAccounting int
+
+const (
+	// PeerGaterAccountByIP aggregates the statistics by IP address; this is the default.
+	PeerGaterAccountByIP PeerGaterAccounting = iota
+	// PeerGaterAccountByPeer keeps the statistics of each peer apart, for peers sharing their IP
+	// addresses legitimately, such as behind a load balancer.
+	PeerGaterAccountByPeer
+	// PeerGaterAccountBySubnet aggregates the statistics by /24 subnet for IPv4 addresses and by
+	// /64 subnet for IPv6 addresses.
+	PeerGaterAccountBySubnet
+	// PeerGaterAccountByBucket is the kind of the custom buckets returned by
+	// PeerGaterParams.BucketFn.
+	PeerGaterAccountByBucket
+)
+
+// PeerGaterBucketParams are the parameters of the statistics of a kind of accounting bucket.
+type PeerGaterBucketParams struct {
+	// decay of the bucket counters
+	SourceDecay float64
+	// how long to retain the stats of a bucket once its peers have disconnected
+	RetainStats time.Duration
+}
+
 // PeerGaterParams groups together parameters that control the operation of the peer gater
 type PeerGaterParams struct {
 	// when the ratio of throttled/validated messages exceeds this threshold, the gater turns on
@@ -52,6 +79,15 @@ type PeerGaterParams struct {
 
 	// priority topic delivery weights
 	TopicDeliveryWeights map[string]float64
+
+	// how the stats are aggregated; per IP by default
+	Accounting PeerGaterAccounting
+	// custom accounting bucket of a peer, such as its ASN; the peers it returns an empty bucket
+	// for are accounted according to Accounting. It is called with the gater locked, so it must be
+	// fast and must not call into pubsub.
+	BucketFn func(peer.ID) string
+	// decay and stats retention by kind of bucket, overriding SourceDecay and RetainStats
+	BucketParams map[PeerGaterAccounting]PeerGaterBucketParams
 }
 
 func (p *PeerGaterParams) validate() error {
@@ -83,6 +119,20 @@ func (p *PeerGaterParams) validate() error {
 	if p.RejectWeight < 1 {
 		return fmt.Errorf("invalud RejectWeight; must be >= 1")
 	}
+	if p.Accounting < PeerGaterAccountByIP || p.Accounting > PeerGaterAccountBySubnet {
+		return fmt.Errorf("invalid Accounting; must be by IP, peer or subnet")
+	}
+	for kind, bp := range p.BucketParams {
+		if kind < PeerGaterAccountByIP || kind > PeerGaterAccountByBucket {
+			return fmt.Errorf("invalid BucketParams; unknown kind of bucket %d", kind)
+		}
+		if bp.SourceDecay <= 0 || bp.SourceDecay >= 1 {
+			return fmt.Errorf("invalid BucketParams[%d].SourceDecay; must be between 0 and 1", kind)
+		}
+		if bp.RetainStats < 0 {
+			return fmt.Errorf("invalid BucketParams[%d].RetainStats; must not be negative", kind)
+		}
+	}
 
 	return nil
 }
@@ -93,6 +143,27 @@ func (p *PeerGaterParams) WithTopicDeliveryWeights(w map[string]float64) *PeerGa
 	return p
 }
 
+// WithAccounting is a fluid setter for the accounting of the peer stats
+func (p *PeerGaterParams) WithAccounting(a PeerGaterAccounting) *PeerGaterParams {
+	p.Accounting = a
+	return p
+}
+
+// WithBucketFn is a fluid setter for the custom accounting buckets of peers
+func (p *PeerGaterParams) WithBucketFn(f func(peer.ID) string) *PeerGaterParams {
+	p.BucketFn = f
+	return p
+}
+
+// WithBucketParams is a fluid setter for the decay and stats retention of a kind of bucket
+func (p *PeerGaterParams) WithBucketParams(kind PeerGaterAccounting, bp PeerGaterBucketParams) *PeerGaterParams {
+	if p.BucketParams == nil {
+		p.BucketParams = make(map[PeerGaterAccounting]PeerGaterBucketParams)
+	}
+	p.BucketParams[kind] = bp
+	return p
+}
+
 // NewPeerGaterParams creates a new PeerGaterParams struct, using the specified threshold and decay
 // parameters and default values for all other parameters.
 func NewPeerGaterParams(threshold, globalDecay, sourceDecay float64) *PeerGaterParams {
@@ -119,7 +190,8 @@ func DefaultPeerGaterParams() *PeerGaterParams {
 type peerGater struct {
 	sync.Mutex
 
-	host host.Host
+	host   host.Host
+	logger Logger
 
 	// gater parameters
 	params *PeerGaterParams
@@ -131,16 +203,19 @@ type peerGater struct {
 	lastThrottle time.Time
 
 	// stats per peer.ID -- multiple peer IDs may share the same stats object if they are
-	// colocated in the same IP
+	// in the same accounting bucket, eg colocated in the same IP
 	peerStats map[peer.ID]*peerGaterStats
-	// stats per IP
-	ipStats map[string]*peerGaterStats
+	// stats per accounting bucket
+	bucketStats map[string]*peerGaterStats
 
 	// for unit tests
 	getIP func(peer.ID) string
 }
 
 type peerGaterStats struct {
+	// kind of accounting bucket
+	kind PeerGaterAccounting
+
 	// number of connected peer IDs mapped to this stat object
 	connected int
 	// stats expiration time -- only valid if connected = 0
@@ -158,7 +233,8 @@ type peerGaterStats struct {
 // queue, performing Random Early Drop.
 // The throttle decision is randomized, with the probability of allowing messages to enter the
 // validation queue controlled by the statistical observations of the performance of all peers
-// in the IP address of the gated peer.
+// in the accounting bucket of the gated peer; by default, the peers in the same IP address.
+// See PeerGaterParams.Accounting.
 // The Gater deactivates if there is no validation throttlinc occurring for the specified quiet
 // interval.
 func WithPeerGater(params *PeerGaterParams) Option {
@@ -181,7 +257,7 @@ func WithPeerGater(params *PeerGaterParams) Option {
 		} else {
 			ps.tracer = &pubsubTracer{
 				raw:   []RawTracer{gs.gate},
-				pid:   ps.host.ID(),
+				pid:   ps.tr.ID(),
 				idGen: ps.idGen,
 			}
 		}
@@ -192,15 +268,25 @@ func WithPeerGater(params *PeerGaterParams) Option {
 
 func newPeerGater(ctx context.Context, host host.Host, params *PeerGaterParams) *peerGater {
 	pg := &peerGater{
-		params:    params,
-		peerStats: make(map[peer.ID]*peerGaterStats),
-		ipStats:   make(map[string]*peerGaterStats),
-		host:      host,
+		params:      params,
+		peerStats:   make(map[peer.ID]*peerGaterStats),
+		bucketStats: make(map[string]*peerGaterStats),
+		host:        host,
+		logger:      log,
 	}
 	go pg.background(ctx)
 	return pg
 }
 
+// Start attaches the gater to the router, using the logger of the pubsub instance.
+func (pg *peerGater) Start(gs *GossipSubRouter) {
+	if pg == nil {
+		return
+	}
+
+	pg.logger = gs.p.logger
+}
+
 func (pg *peerGater) background(ctx context.Context) {
 	tick := time.NewTicker(pg.params.DecayInterval)
 
@@ -231,52 +317,94 @@ func (pg *peerGater) decayStats() {
 	}
 
 	now := time.Now()
-	for ip, st := range pg.ipStats {
+	for bucket, st := range pg.bucketStats {
 		if st.connected > 0 {
-			st.deliver *= pg.params.SourceDecay
+			decay, _ := pg.bucketParams(st.kind)
+
+			st.deliver *= decay
 			if st.deliver < pg.params.DecayToZero {
 				st.deliver = 0
 			}
 
-			st.duplicate *= pg.params.SourceDecay
+			st.duplicate *= decay
 			if st.duplicate < pg.params.DecayToZero {
 				st.duplicate = 0
 			}
 
-			st.ignore *= pg.params.SourceDecay
+			st.ignore *= decay
 			if st.ignore < pg.params.DecayToZero {
 				st.ignore = 0
 			}
 
-			st.reject *= pg.params.SourceDecay
+			st.reject *= decay
 			if st.reject < pg.params.DecayToZero {
 				st.reject = 0
 			}
 		} else if st.expire.Before(now) {
-			delete(pg.ipStats, ip)
+			delete(pg.bucketStats, bucket)
 		}
 	}
 }
 
+// bucketParams returns the decay and stats retention of a kind of bucket.
+func (pg *peerGater) bucketParams(kind PeerGaterAccounting) (float64, time.Duration) {
+	if bp, ok := pg.params.BucketParams[kind]; ok {
+		return bp.SourceDecay, bp.RetainStats
+	}
+	return pg.params.SourceDecay, pg.params.RetainStats
+}
+
 func (pg *peerGater) getPeerStats(p peer.ID) *peerGaterStats {
 	st, ok := pg.peerStats[p]
 	if !ok {
-		st = pg.getIPStats(p)
+		st = pg.getBucketStats(p)
 		pg.peerStats[p] = st
 	}
 	return st
 }
 
-func (pg *peerGater) getIPStats(p peer.ID) *peerGaterStats {
-	ip := pg.getPeerIP(p)
-	st, ok := pg.ipStats[ip]
+func (pg *peerGater) getBucketStats(p peer.ID) *peerGaterStats {
+	bucket, kind := pg.getPeerBucket(p)
+	st, ok := pg.bucketStats[bucket]
 	if !ok {
-		st = &peerGaterStats{}
-		pg.ipStats[ip] = st
+		st = &peerGaterStats{kind: kind}
+		pg.bucketStats[bucket] = st
 	}
 	return st
 }
 
+// getPeerBucket returns the accounting bucket of a peer, and its kind; the buckets are keyed by
+// IP address, or prefixed by kind.
+func (pg *peerGater) getPeerBucket(p peer.ID) (string, PeerGaterAccounting) {
+	if pg.params.BucketFn != nil {
+		if bucket := pg.params.BucketFn(p); bucket != "" {
+			return "bucket:" + bucket, PeerGaterAccountByBucket
+		}
+	}
+
+	switch pg.params.Accounting {
+	case PeerGaterAccountByPeer:
+		return "peer:" + string(p), PeerGaterAccountByPeer
+	case PeerGaterAccountBySubnet:
+		return "subnet:" + ipSubnet(pg.getPeerIP(p)), PeerGaterAccountBySubnet
+	default:
+		return pg.getPeerIP(p), PeerGaterAccountByIP
+	}
+}
+
+// ipSubnet returns the /24 subnet of an IPv4 address or the /64 subnet of an IPv6 address; other
+// strings are returned as is.
+func ipSubnet(s string) string {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return s
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
+
 func (pg *peerGater) getPeerIP(p peer.ID) string {
 	if pg.getIP != nil {
 		return pg.getIP(p)
@@ -286,12 +414,16 @@ func (pg *peerGater) getPeerIP(p peer.ID) string {
 		remote := c.RemoteMultiaddr()
 		ip, err := manet.ToIP(remote)
 		if err != nil {
-			log.Warnf("error determining IP for remote peer in %s: %s", remote, err)
+			pg.logger.Warnw("error determining IP for remote peer", "peer", c.RemotePeer(), "addr", remote, "err", err)
 			return "<unknown>"
 		}
 		return ip.String()
 	}
 
+	if pg.host == nil {
+		return "<unknown>"
+	}
+
 	conns := pg.host.Network().ConnsToPeer(p)
 	switch len(conns) {
 	case 0:
@@ -358,7 +490,7 @@ func (pg *peerGater) AcceptFrom(p peer.ID) AcceptStatus {
 		return AcceptAll
 	}
 
-	log.Debugf("throttling peer %s with threshold %f", p, threshold)
+	pg.logger.Debugw("throttling peer", "peer", p, "threshold", threshold)
 	return AcceptControl
 }
 
@@ -380,7 +512,8 @@ func (pg *peerGater) RemovePeer(p peer.ID) {
 
 	st := pg.getPeerStats(p)
 	st.connected--
-	st.expire = time.Now().Add(pg.params.RetainStats)
+	_, retain := pg.bucketParams(st.kind)
+	st.expire = time.Now().Add(retain)
 
 	delete(pg.peerStats, p)
 }
@@ -424,10 +557,13 @@ func (pg *peerGater) RejectMessage(msg *Message, reason string) {
 		pg.lastThrottle = time.Now()
 		pg.throttle++
 
-	case RejectValidationIgnored:
+	case RejectValidationIgnored, RejectTransformFailed:
 		st := pg.getPeerStats(msg.ReceivedFrom)
 		st.ignore++
 
+	case RejectQuotaExceeded, RejectMemoryBudget, RejectTopicLeft:
+		// the message was dropped before validation; we don't know if it was valid
+
 	default:
 		st := pg.getPeerStats(msg.ReceivedFrom)
 		st.reject++
@@ -107,22 +107,169 @@ func TestPeerGater(t *testing.T) {
 	}
 
 	pg.Lock()
-	_, ok = pg.ipStats[peerAip]
+	_, ok = pg.bucketStats[peerAip]
 	pg.Unlock()
 	if !ok {
 		t.Fatal("expected to still have a stat record for peerA's ip")
 	}
 
 	pg.Lock()
-	pg.ipStats[peerAip].expire = time.Now()
+	pg.bucketStats[peerAip].expire = time.Now()
 	pg.Unlock()
 
 	time.Sleep(2 * time.Second)
 
 	pg.Lock()
-	_, ok = pg.ipStats["1.2.3.4"]
+	_, ok = pg.bucketStats["1.2.3.4"]
 	pg.Unlock()
 	if ok {
 		t.Fatal("still have a stat record for peerA's ip")
 	}
 }
+
+func TestPeerGaterAccounting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the good and bad peers share an IP address, eg behind a load balancer
+	good := peer.ID("good")
+	bad := peer.ID("bad")
+
+	// throttled counts the rejections of the good peer once the gater is active and the bad peer
+	// has misbehaved
+	throttled := func(params *PeerGaterParams) int {
+		if err := params.validate(); err != nil {
+			t.Fatal(err)
+		}
+
+		pg := newPeerGater(ctx, nil, params)
+		pg.getIP = func(p peer.ID) string { return "1.2.3.4" }
+		pg.AddPeer(good, "")
+		pg.AddPeer(bad, "")
+
+		goodMsg := &Message{ReceivedFrom: good}
+		badMsg := &Message{ReceivedFrom: bad}
+		for i := 0; i < 10; i++ {
+			pg.DeliverMessage(goodMsg)
+		}
+		pg.ValidateMessage(badMsg)
+		pg.RejectMessage(badMsg, RejectValidationThrottled)
+		for i := 0; i < 100; i++ {
+			pg.RejectMessage(badMsg, RejectValidationFailed)
+		}
+
+		badThrottled := false
+		for i := 0; !badThrottled && i < 1000; i++ {
+			badThrottled = pg.AcceptFrom(bad) == AcceptControl
+		}
+		if !badThrottled {
+			t.Fatal("expected the bad peer to be throttled")
+		}
+
+		count := 0
+		for i := 0; i < 1000; i++ {
+			if pg.AcceptFrom(good) == AcceptControl {
+				count++
+			}
+		}
+		return count
+	}
+
+	if throttled(NewPeerGaterParams(.1, .9, .999)) == 0 {
+		t.Fatal("expected the good peer to be throttled with the bad peer by IP")
+	}
+	if throttled(NewPeerGaterParams(.1, .9, .999).WithAccounting(PeerGaterAccountBySubnet)) == 0 {
+		t.Fatal("expected the good peer to be throttled with the bad peer by subnet")
+	}
+	if n := throttled(NewPeerGaterParams(.1, .9, .999).WithAccounting(PeerGaterAccountByPeer)); n != 0 {
+		t.Fatalf("expected the good peer not to be throttled by peer, got %d throttled", n)
+	}
+
+	// custom buckets override the accounting for the peers they map
+	bucketFn := func(p peer.ID) string {
+		if p == good {
+			return "AS64496"
+		}
+		return ""
+	}
+	if n := throttled(NewPeerGaterParams(.1, .9, .999).WithBucketFn(bucketFn)); n != 0 {
+		t.Fatalf("expected the good peer not to be throttled in its bucket, got %d throttled", n)
+	}
+}
+
+func TestPeerGaterBuckets(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	params := NewPeerGaterParams(.1, .9, .999).
+		WithAccounting(PeerGaterAccountBySubnet).
+		WithBucketFn(func(p peer.ID) string {
+			if p == "C" {
+				return "AS64496"
+			}
+			return ""
+		}).
+		WithBucketParams(PeerGaterAccountByBucket, PeerGaterBucketParams{SourceDecay: .5, RetainStats: time.Hour})
+	if err := params.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	pg := newPeerGater(ctx, nil, params)
+	pg.getIP = func(p peer.ID) string {
+		switch p {
+		case "A":
+			return "1.2.3.4"
+		case "B":
+			return "1.2.3.200"
+		default:
+			return "2001:db8::1"
+		}
+	}
+
+	for _, p := range []peer.ID{"A", "B", "C", "D"} {
+		pg.AddPeer(p, "")
+		pg.DeliverMessage(&Message{ReceivedFrom: p})
+	}
+
+	pg.Lock()
+	for bucket, deliver := range map[string]float64{
+		"subnet:1.2.3.0/24":    2,
+		"subnet:2001:db8::/64": 1,
+		"bucket:AS64496":       1,
+	} {
+		st, ok := pg.bucketStats[bucket]
+		if !ok || st.deliver != deliver {
+			t.Fatalf("unexpected stats for bucket %s: %+v", bucket, st)
+		}
+	}
+	if len(pg.bucketStats) != 3 {
+		t.Fatalf("unexpected buckets %v", pg.bucketStats)
+	}
+	pg.Unlock()
+
+	// the buckets decay with the parameters of their kind
+	pg.decayStats()
+	pg.Lock()
+	if st := pg.bucketStats["bucket:AS64496"]; st.deliver != .5 {
+		t.Fatalf("unexpected custom bucket counter %f", st.deliver)
+	}
+	if st := pg.bucketStats["subnet:1.2.3.0/24"]; st.deliver != 2*.999 {
+		t.Fatalf("unexpected subnet bucket counter %f", st.deliver)
+	}
+	pg.Unlock()
+
+	pg.RemovePeer("C")
+	pg.Lock()
+	if st := pg.bucketStats["bucket:AS64496"]; time.Until(st.expire) < 59*time.Minute {
+		t.Fatalf("unexpected custom bucket retention %s", time.Until(st.expire))
+	}
+	pg.Unlock()
+
+	invalid := NewPeerGaterParams(.1, .9, .999).WithBucketParams(PeerGaterAccountByPeer, PeerGaterBucketParams{SourceDecay: 1})
+	if err := invalid.validate(); err == nil {
+		t.Fatal("expected an error for an invalid bucket decay")
+	}
+	if err := NewPeerGaterParams(.1, .9, .999).WithAccounting(PeerGaterAccountByBucket).validate(); err == nil {
+		t.Fatal("expected an error for an invalid accounting")
+	}
+}
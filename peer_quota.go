@@ -0,0 +1,137 @@
+package pubsub
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// PeerQuotaOpt is an option for WithPeerMessageQuota.
+type PeerQuotaOpt func(*peerQuota) error
+
+// WithPeerQuotaPenalty applies a behavioural penalty to a peer for every message dropped for
+// exceeding the quota; this only has an effect with the gossipsub router and peer scoring.
+func WithPeerQuotaPenalty(penalty int) PeerQuotaOpt {
+	return func(q *peerQuota) error {
+		if penalty <= 0 {
+			return fmt.Errorf("quota penalty must be positive")
+		}
+		q.penalty = penalty
+		return nil
+	}
+}
+
+// WithPeerQuotaDirectPeers applies the quota to the direct peers of the gossipsub router too,
+// which are exempt by default.
+func WithPeerQuotaDirectPeers() PeerQuotaOpt {
+	return func(q *peerQuota) error {
+		q.direct = true
+		return nil
+	}
+}
+
+// WithPeerMessageQuota limits the rate of messages each peer may deliver to us in a topic, to
+// ratePerSec messages per second with bursts of up to burst messages, regardless of the validity
+// of the messages. Messages over the quota are dropped before validation and traced as rejected
+// with RejectQuotaExceeded; they are not counted as invalid deliveries by the peer score.
+// The quota doesn't apply to our own messages, nor by default to direct peers.
+func WithPeerMessageQuota(topic string, ratePerSec float64, burst int, opts ...PeerQuotaOpt) Option {
+	return func(ps *PubSub) error {
+		if ratePerSec <= 0 {
+			return fmt.Errorf("quota rate must be positive")
+		}
+		if burst <= 0 {
+			return fmt.Errorf("quota burst must be positive")
+		}
+
+		q := &peerQuota{
+			rate:    ratePerSec,
+			burst:   float64(burst),
+			buckets: make(map[peer.ID]*tokenBucket),
+		}
+		for _, opt := range opts {
+			if err := opt(q); err != nil {
+				return err
+			}
+		}
+
+		if ps.quotas == nil {
+			ps.quotas = make(map[string]*peerQuota)
+		}
+		ps.quotas[topic] = q
+		return nil
+	}
+}
+
+// peerQuota is the message quota of a topic, with the token bucket of each peer; it is only
+// accessed from the event loop.
+type peerQuota struct {
+	rate    float64
+	burst   float64
+	penalty int
+	direct  bool
+
+	buckets map[peer.ID]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the bucket of a peer, if there is one left.
+func (q *peerQuota) allow(p peer.ID, now time.Time) bool {
+	b, ok := q.buckets[p]
+	if !ok {
+		b = &tokenBucket{tokens: q.burst, last: now}
+		q.buckets[p] = b
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * q.rate
+		if b.tokens > q.burst {
+			b.tokens = q.burst
+		}
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// checkQuota returns false if a received message exceeds the quota of its peer in the topic, in
+// which case it has been dropped.
+func (p *PubSub) checkQuota(msg *Message) bool {
+	q, ok := p.quotas[msg.GetTopic()]
+	if !ok {
+		return true
+	}
+
+	src := msg.ReceivedFrom
+	gs, isGossipsub := p.rt.(*GossipSubRouter)
+	if !q.direct && isGossipsub {
+		if _, direct := gs.direct[src]; direct {
+			return true
+		}
+	}
+
+	if q.allow(src, time.Now()) {
+		return true
+	}
+
+	p.logger.Debugw("dropping message: quota exceeded", "peer", src, "topic", msg.GetTopic())
+	p.tracer.RejectMessage(msg, RejectQuotaExceeded)
+	if q.penalty > 0 && isGossipsub {
+		gs.score.AddPenalty(src, q.penalty, PenaltyQuotaExceeded)
+	}
+	return false
+}
+
+// removeQuotaPeer forgets the token buckets of a peer that is gone.
+func (p *PubSub) removeQuotaPeer(pid peer.ID) {
+	for _, q := range p.quotas {
+		delete(q.buckets, pid)
+	}
+}
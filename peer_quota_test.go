@@ -0,0 +1,212 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestPeerQuotaTokenBucket(t *testing.T) {
+	q := &peerQuota{rate: 10, burst: 3, buckets: make(map[peer.ID]*tokenBucket)}
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if !q.allow("A", now) {
+			t.Fatalf("expected message %d to be allowed in the burst", i)
+		}
+	}
+	if q.allow("A", now) {
+		t.Fatal("expected the burst to be exhausted")
+	}
+	// other peers have their own bucket
+	if !q.allow("B", now) {
+		t.Fatal("expected another peer to be unaffected")
+	}
+
+	// tokens refill at the rate, up to the burst
+	now = now.Add(100 * time.Millisecond)
+	if !q.allow("A", now) {
+		t.Fatal("expected a token to be refilled")
+	}
+	if q.allow("A", now) {
+		t.Fatal("expected a single token to be refilled")
+	}
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if !q.allow("A", now) {
+			t.Fatalf("expected message %d to be allowed after refilling", i)
+		}
+	}
+	if q.allow("A", now) {
+		t.Fatal("expected the refill to be capped at the burst")
+	}
+}
+
+func TestPeerMessageQuota(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 3)
+	tracer := &rejectReasonTracer{reasons: make(map[string]int)}
+	// the flooder publishes in a burst, so make room for it in the queues; and the quota barely
+	// refills, however long the burst takes
+	receiver := getPubsub(ctx, hosts[0],
+		WithEventTracer(tracer),
+		WithPeerMessageQuota("foobar", 0.01, 5),
+		WithValidateQueueSize(128),
+	)
+	flooder := getPubsub(ctx, hosts[1], WithPeerOutboundQueueSize(128))
+	polite := getPubsub(ctx, hosts[2])
+
+	sub, err := receiver.Subscribe("foobar", WithBufferSize(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the quota is per topic
+	otherSub, err := receiver.Subscribe("other", WithBufferSize(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[1], hosts[0])
+	connect(t, hosts[2], hosts[0])
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < 20; i++ {
+		if err := flooder.Publish("foobar", []byte(fmt.Sprintf("flood %d", i))); err != nil {
+			t.Fatal(err)
+		}
+		if err := flooder.Publish("other", []byte(fmt.Sprintf("other %d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if err := polite.Publish("foobar", []byte(fmt.Sprintf("polite %d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	received := make(map[peer.ID]int)
+	for {
+		mctx, mcancel := context.WithTimeout(ctx, time.Second)
+		msg, err := sub.Next(mctx)
+		mcancel()
+		if err != nil {
+			break
+		}
+		received[msg.GetFrom()]++
+	}
+
+	if n := received[hosts[1].ID()]; n != 5 {
+		t.Fatalf("expected 5 messages from the flooding peer, got %d", n)
+	}
+	if n := received[hosts[2].ID()]; n != 3 {
+		t.Fatalf("expected all 3 messages from the polite peer, got %d", n)
+	}
+	if n := tracer.count(RejectQuotaExceeded); n != 15 {
+		t.Fatalf("expected 15 messages rejected for exceeding the quota, got %d", n)
+	}
+
+	for i := 0; i < 20; i++ {
+		mctx, mcancel := context.WithTimeout(ctx, time.Second)
+		_, err := otherSub.Next(mctx)
+		mcancel()
+		if err != nil {
+			t.Fatalf("expected all the messages in the other topic, got %d: %s", i, err)
+		}
+	}
+}
+
+func TestPeerMessageQuotaDirectPeers(t *testing.T) {
+	for _, applyToDirect := range []bool{false, true} {
+		t.Run(fmt.Sprintf("applyToDirect=%v", applyToDirect), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			hosts := getNetHosts(t, ctx, 2)
+
+			var qopts []PeerQuotaOpt
+			if applyToDirect {
+				qopts = append(qopts, WithPeerQuotaDirectPeers())
+			}
+			ps := getGossipsub(ctx, hosts[0],
+				WithDirectPeers([]peer.AddrInfo{{ID: hosts[1].ID(), Addrs: hosts[1].Addrs()}}),
+				WithPeerMessageQuota("foobar", 1, 1, append(qopts, WithPeerQuotaPenalty(1))...),
+				WithPeerScore(
+					&PeerScoreParams{
+						AppSpecificScore:            func(peer.ID) float64 { return 0 },
+						BehaviourPenaltyWeight:      -1,
+						BehaviourPenaltyDecay:       0.999,
+						DecayInterval:               time.Second,
+						DecayToZero:                 0.01,
+						Topics:                      make(map[string]*TopicScoreParams),
+						IPColocationFactorThreshold: 1,
+					},
+					&PeerScoreThresholds{
+						GossipThreshold:   -10,
+						PublishThreshold:  -100,
+						GraylistThreshold: -1000,
+					}),
+			)
+
+			getGossipsub(ctx, hosts[1])
+			connect(t, hosts[0], hosts[1])
+			time.Sleep(100 * time.Millisecond)
+
+			topic := "foobar"
+			allowed := 0
+			done := make(chan struct{})
+			ps.eval <- func() {
+				defer close(done)
+				for i := 0; i < 3; i++ {
+					msg := &Message{Message: &pb.Message{Topic: &topic}, ReceivedFrom: hosts[1].ID()}
+					if ps.checkQuota(msg) {
+						allowed++
+					}
+				}
+			}
+			<-done
+
+			expected := 3
+			if applyToDirect {
+				expected = 1
+			}
+			if allowed != expected {
+				t.Fatalf("expected %d messages allowed, got %d", expected, allowed)
+			}
+
+			// over-quota messages are penalized
+			gs := ps.rt.(*GossipSubRouter)
+			gs.score.Lock()
+			penalty := 0.0
+			if pstats, ok := gs.score.peerStats[hosts[1].ID()]; ok {
+				penalty = pstats.behaviourPenalty
+			}
+			gs.score.Unlock()
+			if penalty != float64(3-expected) {
+				t.Fatalf("expected a behaviour penalty of %d, got %f", 3-expected, penalty)
+			}
+		})
+	}
+}
+
+func TestPeerMessageQuotaOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	for _, opt := range []Option{
+		WithPeerMessageQuota("foobar", 0, 1),
+		WithPeerMessageQuota("foobar", 1, 0),
+		WithPeerMessageQuota("foobar", 1, 1, WithPeerQuotaPenalty(0)),
+	} {
+		if _, err := NewFloodSub(ctx, hosts[0], opt); err == nil {
+			t.Fatal("expected an error for invalid quota options")
+		}
+	}
+}
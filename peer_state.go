@@ -0,0 +1,216 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// peerWriter is the outbound writer of a peer: it opens the outbound stream and writes the RPCs
+// queued for the peer, until it is torn down by cancelling its context.
+type peerWriter struct {
+	pid    peer.ID
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// the outbound stream, once it has been opened; only accessed from processLoop
+	stream TransportStream
+
+	// unix time in nanoseconds of the last successful write; accessed atomically
+	lastWrite int64
+	// set when the writer has returned; accessed atomically
+	exited int32
+
+	// the occupancy of the outbound queue
+	queue queueStats
+
+	// the stream on a better connection the writer switches to
+	upgrade chan TransportStream
+	// set while the stream is being moved to a better connection; accessed atomically
+	upgrading int32
+
+	// retiredMx protects retired
+	retiredMx sync.Mutex
+	// retired tracks the streams the writer has moved from, which are closed on purpose
+	retired map[TransportStream]struct{}
+}
+
+func newPeerWriter(ctx context.Context, pid peer.ID) *peerWriter {
+	ctx, cancel := context.WithCancel(ctx)
+	return &peerWriter{
+		pid:     pid,
+		ctx:     ctx,
+		cancel:  cancel,
+		upgrade: make(chan TransportStream),
+		retired: make(map[TransportStream]struct{}),
+	}
+}
+
+// retire records that the writer is moving from a stream.
+func (w *peerWriter) retire(s TransportStream) {
+	w.retiredMx.Lock()
+	defer w.retiredMx.Unlock()
+	w.retired[s] = struct{}{}
+}
+
+// isRetired returns whether the writer has moved from a stream, forgetting it.
+func (w *peerWriter) isRetired(s TransportStream) bool {
+	w.retiredMx.Lock()
+	defer w.retiredMx.Unlock()
+
+	_, ok := w.retired[s]
+	delete(w.retired, s)
+	return ok
+}
+
+// peerStream is the outbound stream opened by a peer writer.
+type peerStream struct {
+	w *peerWriter
+	s TransportStream
+}
+
+// PeerState describes the outbound side of our connection to a peer.
+type PeerState struct {
+	// Writer is true if we have a live writer with an open outbound stream to the peer.
+	Writer bool
+	// Protocol is the protocol negotiated on the outbound stream, if it is open.
+	Protocol protocol.ID
+	// QueueDepth is the number of RPCs waiting in the outbound queue of the peer.
+	QueueDepth int
+	// LastWrite is the time of the last successful write to the outbound stream of the peer; it is
+	// zero if nothing has been written to the current stream yet.
+	LastWrite time.Time
+}
+
+// PeerState returns the state of the outbound stream to a peer. It returns false if the peer is
+// not one of our peers.
+func (p *PubSub) PeerState(pid peer.ID) (PeerState, bool) {
+	type result struct {
+		state PeerState
+		ok    bool
+	}
+
+	out := make(chan result, 1)
+	select {
+	case p.eval <- func() {
+		ch, ok := p.peers[pid]
+		if !ok {
+			out <- result{}
+			return
+		}
+
+		var state PeerState
+		state.QueueDepth = len(ch)
+		if w, ok := p.writers[pid]; ok && w.stream != nil {
+			state.Writer = atomic.LoadInt32(&w.exited) == 0
+			state.Protocol = w.stream.Protocol()
+			if t := atomic.LoadInt64(&w.lastWrite); t != 0 {
+				state.LastWrite = time.Unix(0, t)
+			}
+		}
+		out <- result{state, true}
+	}:
+		res := <-out
+		return res.state, res.ok
+	case <-p.ctx.Done():
+		return PeerState{}, false
+	}
+}
+
+// ResetPeer tears down the streams to a peer, for recovering from a stream stuck in a bad state:
+// the peer is pruned from our meshes, its outbound writer is stopped and its queue is cleared, both
+// streams are reset and the peer is removed from the router. If we are still connected to the peer,
+// it is then added back like a new peer, with a new outbound stream; the PRUNEs are sent on it.
+func (p *PubSub) ResetPeer(pid peer.ID) error {
+	out := make(chan error, 1)
+	select {
+	case p.eval <- func() {
+		out <- p.resetPeer(pid)
+	}:
+		return <-out
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+// resetPeer implements ResetPeer.
+// Only called from processLoop.
+func (p *PubSub) resetPeer(pid peer.ID) error {
+	ch, ok := p.peers[pid]
+	if !ok {
+		return fmt.Errorf("unknown peer %s", pid)
+	}
+
+	// the PRUNEs are made while the router still knows the peer, but can only be sent on the new
+	// stream
+	var prune []*pb.ControlPrune
+	if gs, ok := p.rt.(*GossipSubRouter); ok {
+		prune = gs.pruneForReset(pid)
+	}
+
+	w := p.writers[pid]
+	p.removeWriter(pid)
+	if w != nil && w.stream != nil {
+		w.stream.Reset()
+	}
+
+	close(ch)
+	delete(p.peers, pid)
+	for rpc := range ch {
+		p.tracer.DropRPC(rpc, pid)
+	}
+
+	// the peer resends its subscriptions when its writer is respawned
+	p.inboundStreamsMx.Lock()
+	if in, ok := p.inboundStreams[pid]; ok {
+		in.s.Reset()
+	}
+	p.inboundStreamsMx.Unlock()
+
+	p.forgetPeer(pid)
+
+	if !p.tr.Connected(pid) || p.blacklist.Contains(pid) {
+		return nil
+	}
+
+	p.logger.Debugw("peer reset; respawning writer", "peer", pid)
+	w, messages := p.newWriter(pid)
+	if len(prune) > 0 {
+		out := rpcWithControl(nil, nil, nil, nil, prune)
+		select {
+		case messages <- out:
+			p.tracer.SendRPC(out, pid)
+			p.enqueued(pid, messages)
+		default:
+			p.tracer.DropRPC(out, pid)
+		}
+	}
+	go p.handleNewPeer(w, messages)
+	return nil
+}
+
+// pruneForReset removes a peer that is being reset from our meshes, and returns the PRUNEs to send
+// to it.
+func (gs *GossipSubRouter) pruneForReset(p peer.ID) []*pb.ControlPrune {
+	var prune []*pb.ControlPrune
+	for topic, peers := range gs.mesh {
+		if _, ok := peers[p]; !ok {
+			continue
+		}
+
+		gs.p.logger.Debugw("RESET: Remove mesh link", "peer", p, "topic", topic)
+		gs.tracer.Prune(p, topic, PruneReasonUnknown)
+		delete(peers, p)
+		gs.addBackoff(p, topic, false)
+		prune = append(prune, gs.makePrune(p, topic, gs.doPX, false, PruneReasonUnknown))
+	}
+
+	return prune
+}
@@ -0,0 +1,143 @@
+package pubsub
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// wedgingTransport hands out a wedged stream for the first outbound stream it opens.
+type wedgingTransport struct {
+	*hostTransport
+
+	mx     sync.Mutex
+	wedged *wedgedStream
+}
+
+func (t *wedgingTransport) NewStream(ctx context.Context, p peer.ID, protos ...protocol.ID) (TransportStream, error) {
+	s, err := t.hostTransport.NewStream(ctx, p, protos...)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if t.wedged == nil {
+		t.wedged = &wedgedStream{TransportStream: s, reset: make(chan struct{})}
+		return t.wedged, nil
+	}
+	return s, nil
+}
+
+// wedgedStream is a half-open stream: writes and reads block until it is reset.
+type wedgedStream struct {
+	TransportStream
+
+	once  sync.Once
+	reset chan struct{}
+}
+
+func (s *wedgedStream) Read(b []byte) (int, error) {
+	<-s.reset
+	return 0, io.ErrClosedPipe
+}
+
+func (s *wedgedStream) Write(b []byte) (int, error) {
+	<-s.reset
+	return 0, io.ErrClosedPipe
+}
+
+func (s *wedgedStream) Close() error {
+	return s.Reset()
+}
+
+func (s *wedgedStream) Reset() error {
+	s.once.Do(func() { close(s.reset) })
+	return s.TransportStream.Reset()
+}
+
+func TestResetPeer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+
+	tr := &wedgingTransport{hostTransport: newHostTransport(hosts[0])}
+	psA, err := NewGossipSubWithTransport(ctx, tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	psB := getGossipsub(ctx, hosts[1])
+
+	topicA, err := psA.Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	topicB, err := psB.Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := topicB.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	pid := hosts[1].ID()
+	state, ok := psA.PeerState(pid)
+	if !ok {
+		t.Fatal("expected the peer to be known")
+	}
+	if !state.Writer || !state.LastWrite.IsZero() {
+		t.Fatalf("expected a live writer that never wrote, got %+v", state)
+	}
+
+	// nothing gets through the wedged stream
+	if err := topicA.Publish(ctx, []byte("lost")); err != nil {
+		t.Fatal(err)
+	}
+	rctx, rcancel := context.WithTimeout(ctx, time.Second)
+	defer rcancel()
+	if _, err := sub.Next(rctx); err == nil {
+		t.Fatal("received a message through a wedged stream")
+	}
+
+	if err := psA.ResetPeer(pid); err != nil {
+		t.Fatal(err)
+	}
+	if err := psA.ResetPeer(peer.ID("unknown")); err == nil {
+		t.Fatal("expected an error resetting an unknown peer")
+	}
+	time.Sleep(2 * time.Second)
+
+	if err := topicA.Publish(ctx, []byte("found")); err != nil {
+		t.Fatal(err)
+	}
+	// the lost message may be recovered through gossip first
+	rctx, rcancel = context.WithTimeout(ctx, 5*time.Second)
+	defer rcancel()
+	for {
+		msg, err := sub.Next(rctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(msg.Data) == "found" {
+			break
+		}
+	}
+
+	state, ok = psA.PeerState(pid)
+	if !ok {
+		t.Fatal("expected the peer to be known")
+	}
+	if !state.Writer || state.LastWrite.IsZero() || state.QueueDepth != 0 {
+		t.Fatalf("expected a live writer that wrote everything, got %+v", state)
+	}
+}
@@ -0,0 +1,81 @@
+package pubsub
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// PromiseFollowupFn returns the time a peer is given to deliver a message it advertised with IHAVE
+// once we asked for it with IWANT, before its promise is broken; a zero duration keeps
+// GossipSubParams.IWantFollowupTime.
+type PromiseFollowupFn func(p peer.ID) time.Duration
+
+// WithPromiseFollowupOverride is a gossipsub router option that overrides the follow-up time of
+// the IWANT promises by peer, for the classes of peers that legitimately answer IWANT slowly, such
+// as archival peers fetching messages from disk. The function is called from the event loop for
+// every promise we track, so it must be fast and must not block.
+// Broken promises are only tracked, and penalized, with peer scoring; see also
+// SetPromiseExemption.
+func WithPromiseFollowupOverride(f PromiseFollowupFn) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+
+		gs.promiseFollowup = f
+
+		return nil
+	}
+}
+
+// SetPromiseExemption exempts a peer from the behaviour penalty for broken IWANT promises, or
+// removes its exemption. The broken promises of an exempt peer are still tracked, and expire as
+// usual, but are not penalized.
+func (p *PubSub) SetPromiseExemption(pid peer.ID, exempt bool) error {
+	gs, ok := p.rt.(*GossipSubRouter)
+	if !ok {
+		return fmt.Errorf("pubsub router is not gossipsub")
+	}
+
+	select {
+	case p.eval <- func() {
+		if !exempt {
+			delete(gs.promiseExempt, pid)
+			return
+		}
+		if gs.promiseExempt == nil {
+			gs.promiseExempt = make(map[peer.ID]struct{})
+		}
+		gs.promiseExempt[pid] = struct{}{}
+	}:
+		return nil
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+// PromiseExemptions returns the peers exempt from the broken promise penalty; see
+// SetPromiseExemption.
+func (p *PubSub) PromiseExemptions() []peer.ID {
+	gs, ok := p.rt.(*GossipSubRouter)
+	if !ok {
+		return nil
+	}
+
+	out := make(chan []peer.ID, 1)
+	select {
+	case p.eval <- func() {
+		pids := make([]peer.ID, 0, len(gs.promiseExempt))
+		for pid := range gs.promiseExempt {
+			pids = append(pids, pid)
+		}
+		out <- pids
+	}:
+		return <-out
+	case <-p.ctx.Done():
+		return nil
+	}
+}
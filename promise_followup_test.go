@@ -0,0 +1,143 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestPromiseFollowupOverride(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 4)
+	slow, dead, exempt := hosts[1], hosts[2], hosts[3]
+
+	params := DefaultGossipSubParams()
+	params.IWantFollowupTime = 200 * time.Millisecond
+
+	ps, err := NewGossipSub(ctx, hosts[0],
+		WithGossipSubParams(params),
+		WithPeerScore(
+			&PeerScoreParams{
+				AppSpecificScore:       func(peer.ID) float64 { return 0 },
+				BehaviourPenaltyWeight: -1,
+				BehaviourPenaltyDecay:  ScoreParameterDecay(time.Minute),
+				DecayInterval:          DefaultDecayInterval,
+				DecayToZero:            DefaultDecayToZero,
+			},
+			&PeerScoreThresholds{
+				GossipThreshold:   -100,
+				PublishThreshold:  -500,
+				GraylistThreshold: -1000,
+			}),
+		WithPromiseFollowupOverride(func(p peer.ID) time.Duration {
+			if p == slow.ID() {
+				return 3 * time.Second
+			}
+			return 0
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SetPromiseExemption(exempt.ID(), true); err != nil {
+		t.Fatal(err)
+	}
+	if pids := ps.PromiseExemptions(); len(pids) != 1 || pids[0] != exempt.ID() {
+		t.Fatalf("unexpected exemptions %v", pids)
+	}
+
+	mytopic := "mytopic"
+	if _, err := ps.Subscribe(mytopic); err != nil {
+		t.Fatal(err)
+	}
+
+	// the mock peers advertise a message with IHAVE; the slow peer delivers it after the default
+	// follow-up time and a heartbeat, and the others never do
+	var mx sync.Mutex
+	iwants := make(map[peer.ID]int)
+	mockPeer := func(h host.Host, delay time.Duration) {
+		msg := &pb.Message{
+			Data:  []byte("hello from " + h.ID().String()),
+			Topic: &mytopic,
+			From:  []byte(h.ID()),
+			Seqno: []byte{1},
+		}
+		if err := signMessage(h.ID(), h.Peerstore().PrivKey(h.ID()), msg); err != nil {
+			t.Fatal(err)
+		}
+
+		newMockGS(ctx, t, h, func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+			for _, sub := range irpc.GetSubscriptions() {
+				if !sub.GetSubscribe() {
+					continue
+				}
+				writeMsg(&pb.RPC{
+					Subscriptions: []*pb.RPC_SubOpts{{Subscribe: sub.Subscribe, Topicid: sub.Topicid}},
+				})
+				go func() {
+					time.Sleep(50 * time.Millisecond)
+					ihave := []*pb.ControlIHave{{TopicID: &mytopic, MessageIDs: []string{DefaultMsgIdFn(msg)}}}
+					orpc := rpcWithControl(nil, ihave, nil, nil, nil)
+					writeMsg(&orpc.RPC)
+				}()
+			}
+
+			if len(irpc.GetControl().GetIwant()) == 0 {
+				return
+			}
+			mx.Lock()
+			iwants[h.ID()]++
+			mx.Unlock()
+			if delay > 0 {
+				go func() {
+					time.Sleep(delay)
+					writeMsg(&pb.RPC{Publish: []*pb.Message{msg}})
+				}()
+			}
+		})
+	}
+	mockPeer(slow, 1500*time.Millisecond)
+	mockPeer(dead, 0)
+	mockPeer(exempt, 0)
+
+	for _, h := range hosts[1:] {
+		connect(t, hosts[0], h)
+	}
+
+	// wait for the promises to expire and the penalties to be applied
+	time.Sleep(4 * time.Second)
+
+	mx.Lock()
+	for _, h := range hosts[1:] {
+		if iwants[h.ID()] != 1 {
+			t.Fatalf("expected an IWANT to %s, got %d", h.ID(), iwants[h.ID()])
+		}
+	}
+	mx.Unlock()
+
+	score := ps.rt.(*GossipSubRouter).score
+	if s := score.Score(slow.ID()); s != 0 {
+		t.Fatalf("expected no penalty for the slow peer, got score %f", s)
+	}
+	if s := score.Score(exempt.ID()); s != 0 {
+		t.Fatalf("expected no penalty for the exempt peer, got score %f", s)
+	}
+	if s := score.Score(dead.ID()); s >= 0 {
+		t.Fatalf("expected a penalty for the non-responsive peer, got score %f", s)
+	}
+
+	// without the exemption, the broken promises are penalized again
+	if err := ps.SetPromiseExemption(exempt.ID(), false); err != nil {
+		t.Fatal(err)
+	}
+	if pids := ps.PromiseExemptions(); len(pids) != 0 {
+		t.Fatalf("unexpected exemptions %v", pids)
+	}
+}
@@ -0,0 +1,148 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrPublishRateLimited is returned by Publish when the publish rate limit of the topic is
+// exceeded, with WithPublishRateLimitError.
+var ErrPublishRateLimited = errors.New("publish rate limit exceeded")
+
+type publishRateAction int
+
+const (
+	publishRateBlock publishRateAction = iota
+	publishRateError
+	publishRateDrop
+)
+
+// PublishRateLimitOpt is an option for WithPublishRateLimit.
+type PublishRateLimitOpt func(*publishLimiter) error
+
+// WithPublishRateLimitError makes Publish return ErrPublishRateLimited when the rate limit is
+// exceeded, instead of blocking.
+func WithPublishRateLimitError() PublishRateLimitOpt {
+	return func(pl *publishLimiter) error {
+		pl.action = publishRateError
+		return nil
+	}
+}
+
+// WithPublishRateLimitDrop makes Publish silently drop the messages exceeding the rate limit,
+// instead of blocking; see PublishRateLimitDropped.
+func WithPublishRateLimitDrop() PublishRateLimitOpt {
+	return func(pl *publishLimiter) error {
+		pl.action = publishRateDrop
+		return nil
+	}
+}
+
+// WithPublishRateLimit is a topic option that limits the rate of the messages we publish in the
+// topic to rate messages per second, with bursts of up to burst messages, so that a misbehaving
+// application can't flood the network from our identity. By default, Publish blocks until the
+// message can be published or its context is done; see WithPublishRateLimitError and
+// WithPublishRateLimitDrop for the alternatives.
+// The limit only applies to the messages published with this topic handle and sent to the
+// network; the messages we forward and the local publications (see WithLocalPublication) are not
+// limited.
+func WithPublishRateLimit(rate float64, burst int, opts ...PublishRateLimitOpt) TopicOpt {
+	return func(t *Topic) error {
+		if rate <= 0 {
+			return fmt.Errorf("invalid publish rate; must be positive")
+		}
+		if burst < 1 {
+			return fmt.Errorf("invalid publish burst; must be at least 1")
+		}
+
+		pl := &publishLimiter{
+			rate:   rate,
+			burst:  float64(burst),
+			bucket: tokenBucket{tokens: float64(burst), last: time.Now()},
+		}
+		for _, opt := range opts {
+			if err := opt(pl); err != nil {
+				return err
+			}
+		}
+
+		t.publishLimiter = pl
+		return nil
+	}
+}
+
+// PublishRateLimitDropped returns the number of messages dropped by the publish rate limit of the
+// topic, with WithPublishRateLimitDrop.
+func (t *Topic) PublishRateLimitDropped() uint64 {
+	pl := t.publishLimiter
+	if pl == nil {
+		return 0
+	}
+
+	pl.mx.Lock()
+	defer pl.mx.Unlock()
+	return pl.dropped
+}
+
+// publishLimiter is the publish rate limit of a topic.
+type publishLimiter struct {
+	rate   float64
+	burst  float64
+	action publishRateAction
+
+	mx      sync.Mutex
+	bucket  tokenBucket
+	dropped uint64
+}
+
+// reserve takes a token from the bucket if there is one left; otherwise it returns how long to
+// wait for the next token.
+func (pl *publishLimiter) reserve(now time.Time) (bool, time.Duration) {
+	pl.mx.Lock()
+	defer pl.mx.Unlock()
+
+	b := &pl.bucket
+	b.tokens += now.Sub(b.last).Seconds() * pl.rate
+	if b.tokens > pl.burst {
+		b.tokens = pl.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / pl.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// wait admits a message we publish, blocking until a token is available with the default action.
+// It returns false if the message must be dropped silently.
+func (pl *publishLimiter) wait(ctx context.Context) (bool, error) {
+	for {
+		ok, delay := pl.reserve(time.Now())
+		if ok {
+			return true, nil
+		}
+
+		switch pl.action {
+		case publishRateError:
+			return false, ErrPublishRateLimited
+		case publishRateDrop:
+			pl.mx.Lock()
+			pl.dropped++
+			pl.mx.Unlock()
+			return false, nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return false, ctx.Err()
+		}
+	}
+}
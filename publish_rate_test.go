@@ -0,0 +1,189 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func getRateLimitedTopic(t *testing.T, ctx context.Context, opts ...TopicOpt) (*Topic, *Subscription) {
+	t.Helper()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getGossipsub(ctx, hosts[0])
+	topic, err := ps.Join("foobar", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := topic.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return topic, sub
+}
+
+func TestPublishRateLimitBlock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	topic, sub := getRateLimitedTopic(t, ctx, WithPublishRateLimit(10, 2))
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := topic.Publish(ctx, []byte(fmt.Sprintf("msg%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the burst is published right away, and the rest at the rate of the limit
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Fatalf("expected the publications to be spread over 300ms, took %s", elapsed)
+	}
+	for i := 0; i < 5; i++ {
+		expectMessage(t, ctx, sub, fmt.Sprintf("msg%d", i))
+	}
+	if n := topic.PublishRateLimitDropped(); n != 0 {
+		t.Fatalf("expected no dropped messages, got %d", n)
+	}
+}
+
+func TestPublishRateLimitBlockCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	topic, sub := getRateLimitedTopic(t, ctx, WithPublishRateLimit(1, 1))
+
+	if err := topic.Publish(ctx, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	pctx, pcancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer pcancel()
+	start := time.Now()
+	err := topic.Publish(pctx, []byte("second"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the publication to be cancelled with its context, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected the publication to be cancelled promptly, took %s", elapsed)
+	}
+
+	expectMessage(t, ctx, sub, "first")
+	expectNoMessage(t, ctx, sub)
+
+	// the limit still applies after the cancellation
+	if err := topic.Publish(ctx, []byte("third")); err != nil {
+		t.Fatal(err)
+	}
+	expectMessage(t, ctx, sub, "third")
+}
+
+func TestPublishRateLimitError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	topic, sub := getRateLimitedTopic(t, ctx, WithPublishRateLimit(10, 2, WithPublishRateLimitError()))
+
+	for i := 0; i < 2; i++ {
+		if err := topic.Publish(ctx, []byte(fmt.Sprintf("msg%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := topic.Publish(ctx, []byte("limited")); !errors.Is(err, ErrPublishRateLimited) {
+		t.Fatalf("expected the publication to be rate limited, got %v", err)
+	}
+
+	// local publications are not limited
+	if err := topic.Publish(ctx, []byte("local"), WithLocalPublication(true)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the bucket refills at the rate of the limit
+	time.Sleep(150 * time.Millisecond)
+	if err := topic.Publish(ctx, []byte("refilled")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []string{"msg0", "msg1", "local", "refilled"} {
+		expectMessage(t, ctx, sub, data)
+	}
+}
+
+func TestPublishRateLimitDrop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	topic, sub := getRateLimitedTopic(t, ctx, WithPublishRateLimit(1, 2, WithPublishRateLimitDrop()))
+
+	for i := 0; i < 5; i++ {
+		if err := topic.Publish(ctx, []byte(fmt.Sprintf("msg%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectMessage(t, ctx, sub, "msg0")
+	expectMessage(t, ctx, sub, "msg1")
+	expectNoMessage(t, ctx, sub)
+	if n := topic.PublishRateLimitDropped(); n != 3 {
+		t.Fatalf("expected 3 dropped messages, got %d", n)
+	}
+}
+
+// Test that the messages we forward are not limited by our publish rate limit.
+func TestPublishRateLimitForwarding(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 3)
+	psubs := getGossipsubs(ctx, hosts)
+
+	var topics []*Topic
+	var subs []*Subscription
+	for i, ps := range psubs {
+		var opts []TopicOpt
+		if i == 1 {
+			opts = append(opts, WithPublishRateLimit(1, 1, WithPublishRateLimitDrop()))
+		}
+		topic, err := ps.Join("foobar", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+		subs = append(subs, sub)
+	}
+
+	// the rate limited peer is the only path between the others
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+	time.Sleep(time.Second)
+
+	for i := 0; i < 5; i++ {
+		if err := topics[0].Publish(ctx, []byte(fmt.Sprintf("msg%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		expectMessage(t, ctx, subs[2], fmt.Sprintf("msg%d", i))
+	}
+	if n := topics[1].PublishRateLimitDropped(); n != 0 {
+		t.Fatalf("expected no dropped messages, got %d", n)
+	}
+}
+
+func TestPublishRateLimitInvalid(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getGossipsub(ctx, hosts[0])
+	if _, err := ps.Join("foobar", WithPublishRateLimit(0, 1)); err == nil {
+		t.Fatal("expected a zero rate to be invalid")
+	}
+	if _, err := ps.Join("foobar", WithPublishRateLimit(1, 0)); err == nil {
+		t.Fatal("expected a zero burst to be invalid")
+	}
+}
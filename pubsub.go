@@ -6,6 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,16 +19,20 @@ import (
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/discovery"
 	"github.com/libp2p/go-libp2p/core/host"
-	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 
+	"github.com/benbjohnson/clock"
 	logging "github.com/ipfs/go-log/v2"
 )
 
 // DefaultMaximumMessageSize is 1mb.
 const DefaultMaxMessageSize = 1 << 20
 
+// DefaultAnnounceDebounce is the default window collecting subscription changes into a single
+// announcement; see WithAnnounceDebounce.
+const DefaultAnnounceDebounce = 5 * time.Millisecond
+
 var (
 	// TimeCacheDuration specifies how long a message ID will be remembered as seen.
 	// Use WithSeenMessagesTTL to configure this per pubsub instance, instead of overriding the global default.
@@ -35,6 +42,16 @@ var (
 	// Use WithSeenMessagesStrategy to configure this per pubsub instance, instead of overriding the global default.
 	TimeCacheStrategy = timecache.Strategy_FirstSeen
 
+	// TimeCacheShards specifies the number of shards of the seen messages cache, which is looked up
+	// concurrently by the reader goroutines of the peers and the event loop; 1 disables the sharding.
+	// Use WithSeenMessagesShards to configure this per pubsub instance, instead of overriding the global default.
+	TimeCacheShards = 1
+
+	// LeftTopicWindow is how long after leaving a topic the messages still arriving in it, such
+	// as the responses to our IWANTs, are rejected with RejectTopicLeft rather than handled as
+	// messages in a topic we are not subscribed to.
+	LeftTopicWindow = time.Minute
+
 	// ErrSubscriptionCancelled may be returned when a subscription Next() is called after the
 	// subscription has been cancelled.
 	ErrSubscriptionCancelled = errors.New("subscription cancelled")
@@ -52,8 +69,14 @@ type PubSub struct {
 	//
 	// See: https://golang.org/pkg/sync/atomic/#pkg-note-BUG
 	counter uint64
+	// atomic gauge of the bytes held by the read buffers of the inbound streams
+	readBufferBytes int64
+	// atomic counter of the RPCs merged by RPC coalescing
+	rpcMerges uint64
 
+	// host is nil when PubSub runs on a custom transport
 	host host.Host
+	tr   Transport
 
 	rt PubSubRouter
 
@@ -63,15 +86,39 @@ type PubSub struct {
 
 	tracer *pubsubTracer
 
+	// the instance logger; see WithLogger
+	logger Logger
+
 	peerFilter PeerFilter
 
 	// maxMessageSize is the maximum message size; it applies globally to all
 	// topics.
 	maxMessageSize int
 
+	// caps on the number of entries in incoming RPCs
+	rpcLimits RPCLimits
+
 	// size of the outbound message channel that we maintain for each peer
 	peerOutboundQueueSize int
 
+	// deadline for writing an RPC to a peer stream; 0 disables the deadline
+	streamWriteTimeout time.Duration
+	// whether to merge the RPCs queued for a peer; see WithRPCCoalescing
+	coalesceRPCs bool
+	// behavioural penalty applied to peers whose streams time out on write
+	writeTimeoutPenalty int
+
+	// preference between the connections to a peer for its outbound stream; nil disables switching
+	connPref ConnPreference
+
+	// window collecting subscription changes into a single announcement; 0 disables the debouncing
+	announceDebounce time.Duration
+	// subscription changes waiting to be announced, and the timer ending their window
+	pendingAnnounce map[string]bool
+	announceTimer   *time.Timer
+	// peers greeted within the window, whose hello packet already carried the changes
+	announceGreeted map[peer.ID]struct{}
+
 	// incoming messages from other peers
 	incoming chan *RPC
 
@@ -106,10 +153,10 @@ type PubSub struct {
 	newPeersPend   map[peer.ID]struct{}
 
 	// a notification channel for new outoging peer streams
-	newPeerStream chan network.Stream
+	newPeerStream chan *peerStream
 
 	// a notification channel for errors opening new peer streams
-	newPeerError chan peer.ID
+	newPeerError chan *peerWriter
 
 	// a notification channel for when our peers die
 	peerDead       chan struct{}
@@ -125,6 +172,13 @@ type PubSub struct {
 	// The set of topics we are relaying for
 	myRelays map[string]int
 
+	// The set of topics we are subscribed to or relaying for, as a map[string]struct{} replaced on
+	// every change, read by the stream readers
+	accepting atomic.Value
+
+	// The topics we left within the LeftTopicWindow, with the time we left them
+	leftTopics map[string]time.Time
+
 	// The set of topics we are interested in
 	myTopics map[string]*Topic
 
@@ -148,17 +202,76 @@ type PubSub struct {
 	blacklistPeer chan peer.ID
 
 	peers map[peer.ID]chan *RPC
+	// the outbound writers of our peers
+	writers map[peer.ID]*peerWriter
+
+	// peerProtos tracks the protocol negotiated with each peer that has been added to the router
+	peerProtos map[peer.ID]protocol.ID
 
 	inboundStreamsMx sync.Mutex
-	inboundStreams   map[peer.ID]network.Stream
+	inboundStreams   map[peer.ID]*inboundStream
 
 	seenMessages    timecache.TimeCache
 	seenMsgTTL      time.Duration
 	seenMsgStrategy timecache.Strategy
+	seenMsgShards   int
+
+	// recently rejected messages; nil unless enabled
+	rejected *rejectedCache
+
+	// the feed of rejected messages for RejectedMessages
+	rejectFeed *rejectFeed
+
+	// highest sequence numbers seen per author; nil unless replay protection is enabled
+	seqnos *seqnoTracker
+
+	// persistence of the seqnos of the messages we publish, if enabled
+	seqnoPersist *seqnoPersistence
+
+	// fault injection for resilience testing; nil unless enabled
+	faults FaultInjector
+
+	// subscription churn of the peers; nil unless churn protection is enabled
+	subChurn *subChurn
+
+	// the clock of the ephemeral topic expiry, the timestamp validation, the disconnect grace period
+	// and the standby subscription window
+	clock clock.Clock
+
+	// the debouncing of the connection events, if enabled
+	connDebounce *connDebounce
+
+	// the observer of the forwarding decisions of the router; nil unless installed
+	fwdObserver *forwardObserver
+
+	// malformed RPC entries received from each peer, and the number tolerated before penalties
+	malformed          map[peer.ID]int
+	malformedThreshold int
+
+	// duplicate counts of the messages we receive; nil unless enabled
+	dupStats *dupStats
+
+	// per topic message queues; nil unless fair scheduling is enabled
+	sched *topicScheduler
+
+	// per peer message quotas, by topic
+	quotas map[string]*peerQuota
+
+	// memory budget of the messages we hold; nil if unlimited
+	memBudget *memoryBudget
+
+	// policy for messages in topics we are not subscribed to; nil to drop them
+	unsubscribed *unsubscribedTopics
+
+	// circuit breakers, by topic; not modified after construction
+	breakers map[string]*circuitBreaker
 
 	// generator used to compute the ID for a message
 	idGen *msgIDGenerator
 
+	// the signature verifiers of the topics joined with WithTopicSignatureVerifier
+	sigVerifiers *topicVerifiers
+
 	// key for signing messages; nil when signing is disabled
 	signKey crypto.PrivKey
 	// source ID for signed messages; corresponds to signKey, empty when signing is disabled.
@@ -169,10 +282,18 @@ type PubSub struct {
 
 	// filter for tracking subscriptions in topics of interest; if nil, then we track all subscriptions
 	subFilter SubscriptionFilter
+	// behavioural penalty applied to peers whose subscriptions are rejected by the filter
+	subFilterPenalty int
+
+	// whether the open references to the topic handles are recorded; see WithTopicUsageAudit
+	topicAudit bool
 
 	// protoMatchFunc is a matching function for protocol selection.
 	protoMatchFunc ProtocolMatchFn
 
+	// protoPrefix namespaces the protocols of the router on the wire; empty if not namespaced
+	protoPrefix string
+
 	ctx context.Context
 
 	// appSpecificRpcInspector is an auxiliary that may be set by the application to inspect incoming RPCs prior to
@@ -232,40 +353,139 @@ type Message struct {
 	ReceivedFrom  peer.ID
 	ValidatorData interface{}
 	Local         bool
+	// Duplicates is the number of duplicate copies of the message received before it was
+	// delivered, with WithDuplicateTracking; see DuplicateCount for the later duplicates.
+	Duplicates int
+
+	// true if Data refers to the buffer the message was read into
+	lazy bool
+	// true if the message should not be delivered to our own subscriptions
+	noLocalDelivery bool
+	// outcome of the verification of the signature on receipt, before the validation pipeline
+	sig uint8
+	// receives the routing of a message published with WithRetry or WithPublishResult
+	routed chan *PublishResult
+	// records the routing of a message published with WithRetry or WithPublishResult
+	result *PublishResult
+	// decoded envelope of the message, set on the first call to Envelope
+	envelope atomic.Value
+	// local arrival time of the message, for the forward deadline of its topic
+	arrived time.Time
+	// metadata attached by our publisher with WithLocalMetadata; it is never sent to peers
+	metadata map[string]interface{}
 }
 
 func (m *Message) GetFrom() peer.ID {
 	return peer.ID(m.Message.GetFrom())
 }
 
+// LocalMetadata returns the value attached under key to a message we published with
+// WithLocalMetadata, if any. Messages from remote peers have no metadata.
+func (m *Message) LocalMetadata(key string) (interface{}, bool) {
+	v, ok := m.metadata[key]
+	return v, ok
+}
+
+// reportRouted reports the routing of a message published with WithRetry or WithPublishResult, or
+// nil if it was not routed or the router doesn't tell.
+func (m *Message) reportRouted(res *PublishResult) {
+	if m.routed != nil {
+		m.routed <- res
+	}
+}
+
+// detachData copies the message payload out of the RPC read buffer, which is reused once the RPC
+// has been handled; it must be called before retaining the message past the handling of the RPC.
+func (m *Message) detachData() {
+	if !m.lazy {
+		return
+	}
+
+	m.Data = append([]byte(nil), m.Data...)
+	m.lazy = false
+}
+
 type RPC struct {
 	pb.RPC
 
 	// unexported on purpose, not sending this over the wire
 	from peer.ID
+
+	// the read buffer the payload of the published messages refers to, if any; it goes back to the
+	// pool once the RPC has been handled
+	buf []byte
+	// outcome of the verification of the signature of each published message on receipt
+	sigs []uint8
+	// ID of each published message, computed on receipt; empty if not computed
+	ids []string
+	// the copies of messages already seen, dropped from the published messages on receipt
+	dups []*Message
+	// time after which each published message is not worth writing, if any
+	expires []time.Time
+	// number of malformed entries dropped on receipt
+	malformed int
+	// whether the RPC is the first on a new inbound stream, which carries the hello packet of the
+	// peer with all its subscriptions, rather than on a stream the peer moved to
+	hello bool
+}
+
+// sig returns the outcome of the verification of the signature of the i-th published message on
+// receipt.
+func (rpc *RPC) sig(i int) uint8 {
+	if i < len(rpc.sigs) {
+		return rpc.sigs[i]
+	}
+	return sigUnverified
+}
+
+// id returns the ID of the i-th published message computed on receipt, if any.
+func (rpc *RPC) id(i int) string {
+	if i < len(rpc.ids) {
+		return rpc.ids[i]
+	}
+	return ""
 }
 
 type Option func(*PubSub) error
 
 // NewPubSub returns a new PubSub management object.
 func NewPubSub(ctx context.Context, h host.Host, rt PubSubRouter, opts ...Option) (*PubSub, error) {
+	return newPubSub(ctx, h, newHostTransport(h), rt, opts...)
+}
+
+// NewPubSubWithTransport returns a new PubSub management object running on a custom transport
+// instead of a libp2p host. This is mostly useful for simulations and tests.
+// Features that need a libp2p host are not available: peer exchange records, direct peer
+// addresses, connection manager tags, IP colocation scoring and discovery.
+func NewPubSubWithTransport(ctx context.Context, tr Transport, rt PubSubRouter, opts ...Option) (*PubSub, error) {
+	return newPubSub(ctx, nil, tr, rt, opts...)
+}
+
+func newPubSub(ctx context.Context, h host.Host, tr Transport, rt PubSubRouter, opts ...Option) (*PubSub, error) {
 	ps := &PubSub{
 		host:                  h,
+		tr:                    tr,
 		ctx:                   ctx,
 		rt:                    rt,
 		val:                   newValidation(),
 		peerFilter:            DefaultPeerFilter,
 		disc:                  &discover{},
+		logger:                log,
 		maxMessageSize:        DefaultMaxMessageSize,
+		rpcLimits:             DefaultRPCLimits(),
 		peerOutboundQueueSize: 32,
-		signID:                h.ID(),
+		connPref:              PreferDirectConns,
+		announceDebounce:      DefaultAnnounceDebounce,
+		pendingAnnounce:       make(map[string]bool),
+		announceGreeted:       make(map[peer.ID]struct{}),
+		signID:                tr.ID(),
 		signKey:               nil,
 		signPolicy:            StrictSign,
 		incoming:              make(chan *RPC, 32),
 		newPeers:              make(chan struct{}, 1),
 		newPeersPend:          make(map[peer.ID]struct{}),
-		newPeerStream:         make(chan network.Stream),
-		newPeerError:          make(chan peer.ID),
+		newPeerStream:         make(chan *peerStream),
+		newPeerError:          make(chan *peerWriter),
 		peerDead:              make(chan struct{}, 1),
 		peerDeadPend:          make(map[peer.ID]struct{}),
 		deadPeerBackoff:       newBackoff(ctx, 1000, BackoffCleanupInterval, MaxBackoffAttempts),
@@ -284,15 +504,23 @@ func NewPubSub(ctx context.Context, h host.Host, rt PubSubRouter, opts ...Option
 		myTopics:              make(map[string]*Topic),
 		mySubs:                make(map[string]map[*Subscription]struct{}),
 		myRelays:              make(map[string]int),
+		leftTopics:            make(map[string]time.Time),
 		topics:                make(map[string]map[peer.ID]struct{}),
 		peers:                 make(map[peer.ID]chan *RPC),
-		inboundStreams:        make(map[peer.ID]network.Stream),
+		writers:               make(map[peer.ID]*peerWriter),
+		peerProtos:            make(map[peer.ID]protocol.ID),
+		inboundStreams:        make(map[peer.ID]*inboundStream),
 		blacklist:             NewMapBlacklist(),
 		blacklistPeer:         make(chan peer.ID),
 		seenMsgTTL:            TimeCacheDuration,
 		seenMsgStrategy:       TimeCacheStrategy,
+		seenMsgShards:         TimeCacheShards,
 		idGen:                 newMsgIdGenerator(),
+		sigVerifiers:          newTopicVerifiers(),
 		counter:               uint64(time.Now().UnixNano()),
+		clock:                 clock.New(),
+		malformed:             make(map[peer.ID]int),
+		malformedThreshold:    DefaultMalformedRPCThreshold,
 	}
 
 	for _, opt := range opts {
@@ -302,17 +530,43 @@ func NewPubSub(ctx context.Context, h host.Host, rt PubSubRouter, opts ...Option
 		}
 	}
 
+	ps.logger = withFields(ps.logger, "host", tr.ID())
+
+	// hook the rejected message feed
+	ps.rejectFeed = newRejectFeed(ps.idGen)
+	if ps.tracer != nil {
+		ps.tracer.raw = append(ps.tracer.raw, ps.rejectFeed)
+	} else {
+		ps.tracer = &pubsubTracer{
+			raw:   []RawTracer{ps.rejectFeed},
+			pid:   ps.tr.ID(),
+			idGen: ps.idGen,
+		}
+	}
+
+	// and the duplicate counts, over the lifetime of the seen messages
+	if ps.dupStats != nil {
+		ps.dupStats.ttl = ps.seenMsgTTL
+		ps.tracer.raw = append(ps.tracer.raw, ps.dupStats)
+	}
+
 	if ps.signPolicy.mustSign() {
 		if ps.signID == "" {
 			return nil, fmt.Errorf("strict signature usage enabled but message author was disabled")
 		}
-		ps.signKey = ps.host.Peerstore().PrivKey(ps.signID)
+		ps.signKey = ps.tr.PrivKey(ps.signID)
 		if ps.signKey == nil {
 			return nil, fmt.Errorf("can't sign for peer %s: no private key", ps.signID)
 		}
 	}
 
-	ps.seenMessages = timecache.NewTimeCacheWithStrategy(ps.seenMsgStrategy, ps.seenMsgTTL)
+	ps.seenMessages = timecache.NewShardedTimeCache(ps.seenMsgStrategy, ps.seenMsgTTL, ps.seenMsgShards)
+
+	if ps.seqnoPersist != nil {
+		if err := ps.seqnoPersist.start(ps); err != nil {
+			return nil, err
+		}
+	}
 
 	if err := ps.disc.Start(ps); err != nil {
 		return nil, err
@@ -321,13 +575,20 @@ func NewPubSub(ctx context.Context, h host.Host, rt PubSubRouter, opts ...Option
 	rt.Attach(ps)
 
 	for _, id := range rt.Protocols() {
+		var match func(protocol.ID) bool
 		if ps.protoMatchFunc != nil {
-			h.SetStreamHandlerMatch(id, ps.protoMatchFunc(id), ps.handleNewStream)
-		} else {
-			h.SetStreamHandler(id, ps.handleNewStream)
+			match = ps.protoMatchFunc(id)
+			if ps.protoPrefix != "" {
+				routerMatch := match
+				match = func(proto protocol.ID) bool {
+					id, ok := ps.routerProtocol(proto)
+					return ok && routerMatch(id)
+				}
+			}
 		}
+		tr.SetStreamHandler(ps.wireProtocol(id), match, ps.handleNewStream)
 	}
-	h.Network().Notify((*PubSubNotif)(ps))
+	tr.Notify(ps.notifyNewPeer)
 
 	ps.val.Start(ps)
 
@@ -379,6 +640,49 @@ func WithPeerOutboundQueueSize(size int) Option {
 	}
 }
 
+// WithAnnounceDebounce sets the window collecting the subscription changes, made by joining or
+// leaving topics, into a single announcement to every peer; the default is DefaultAnnounceDebounce.
+// A topic subscribed to and unsubscribed from within the window is not announced at all.
+// A zero window announces every change immediately.
+func WithAnnounceDebounce(d time.Duration) Option {
+	return func(p *PubSub) error {
+		if d < 0 {
+			return errors.New("announce debounce must be non-negative")
+		}
+		p.announceDebounce = d
+		return nil
+	}
+}
+
+// WithStreamWriteTimeout sets a deadline for writing each RPC to a peer's stream.
+// A peer that stops reading from its stream would otherwise block our writer forever; when the
+// deadline expires, the stream is reset, the pending outbound RPCs for the peer are dropped and
+// the peer is handled as if the stream had died, reopening it if the peer is still connected.
+// By default there is no deadline. Only streams that support write deadlines are affected.
+func WithStreamWriteTimeout(timeout time.Duration) Option {
+	return func(p *PubSub) error {
+		if timeout < 0 {
+			return errors.New("stream write timeout must not be negative")
+		}
+		p.streamWriteTimeout = timeout
+		return nil
+	}
+}
+
+// WithStreamWriteTimeoutPenalty applies a behavioural penalty to peers every time a write to their
+// stream times out (see WithStreamWriteTimeout).
+// The penalty is counted in the P7 component of the gossipsub peer score, so it only has an effect
+// when using gossipsub with peer scoring enabled.
+func WithStreamWriteTimeoutPenalty(count int) Option {
+	return func(p *PubSub) error {
+		if count < 0 {
+			return errors.New("stream write timeout penalty must not be negative")
+		}
+		p.writeTimeoutPenalty = count
+		return nil
+	}
+}
+
 // WithMessageSignaturePolicy sets the mode of operation for producing and verifying message signatures.
 func WithMessageSignaturePolicy(policy MessageSignaturePolicy) Option {
 	return func(p *PubSub) error {
@@ -408,7 +712,7 @@ func WithMessageAuthor(author peer.ID) Option {
 	return func(p *PubSub) error {
 		author := author
 		if author == "" {
-			author = p.host.ID()
+			author = p.tr.ID()
 		}
 		p.signID = author
 		return nil
@@ -472,7 +776,7 @@ func WithEventTracer(tracer EventTracer) Option {
 		if p.tracer != nil {
 			p.tracer.tracer = tracer
 		} else {
-			p.tracer = &pubsubTracer{tracer: tracer, pid: p.host.ID(), idGen: p.idGen}
+			p.tracer = &pubsubTracer{tracer: tracer, pid: p.tr.ID(), idGen: p.idGen}
 		}
 		return nil
 	}
@@ -485,7 +789,7 @@ func WithRawTracer(tracer RawTracer) Option {
 		if p.tracer != nil {
 			p.tracer.raw = append(p.tracer.raw, tracer)
 		} else {
-			p.tracer = &pubsubTracer{raw: []RawTracer{tracer}, pid: p.host.ID(), idGen: p.idGen}
+			p.tracer = &pubsubTracer{raw: []RawTracer{tracer}, pid: p.tr.ID(), idGen: p.idGen}
 		}
 		return nil
 	}
@@ -530,6 +834,54 @@ func WithProtocolMatchFn(m ProtocolMatchFn) Option {
 	}
 }
 
+// WithProtocolPrefix namespaces the protocols of the router with a prefix, e.g. the gossipsub
+// protocol /meshsub/1.1.0 becomes /appA/meshsub/1.1.0 with the prefix /appA.
+// This allows multiple independent PubSub instances to run on the same host: each instance only
+// talks to peers running an instance with the same prefix.
+// The routers and the application keep seeing the protocols without the prefix.
+func WithProtocolPrefix(prefix string) Option {
+	return func(ps *PubSub) error {
+		if !strings.HasPrefix(prefix, "/") || strings.HasSuffix(prefix, "/") {
+			return fmt.Errorf("invalid protocol prefix %q; must start and not end with /", prefix)
+		}
+		ps.protoPrefix = prefix
+		return nil
+	}
+}
+
+// wireProtocol returns the protocol ID used on the wire for a router protocol.
+func (p *PubSub) wireProtocol(proto protocol.ID) protocol.ID {
+	return protocol.ID(p.protoPrefix) + proto
+}
+
+// wireProtocols returns the protocol IDs used on the wire for the router protocols.
+func (p *PubSub) wireProtocols() []protocol.ID {
+	protos := p.rt.Protocols()
+	if p.protoPrefix == "" {
+		return protos
+	}
+
+	wire := make([]protocol.ID, 0, len(protos))
+	for _, proto := range protos {
+		wire = append(wire, p.wireProtocol(proto))
+	}
+	return wire
+}
+
+// routerProtocol strips the namespace of a protocol ID used on the wire; it returns false if the
+// protocol is not in our namespace.
+func (p *PubSub) routerProtocol(proto protocol.ID) (protocol.ID, bool) {
+	if p.protoPrefix == "" {
+		return proto, true
+	}
+
+	id, ok := strings.CutPrefix(string(proto), p.protoPrefix)
+	if !ok || !strings.HasPrefix(id, "/") {
+		return proto, false
+	}
+	return protocol.ID(id), true
+}
+
 // WithSeenMessagesTTL configures when a previously seen message ID can be forgotten about
 func WithSeenMessagesTTL(ttl time.Duration) Option {
 	return func(ps *PubSub) error {
@@ -546,10 +898,29 @@ func WithSeenMessagesStrategy(strategy timecache.Strategy) Option {
 	}
 }
 
+// WithSeenMessagesShards configures the number of shards of the seen messages cache, each with its
+// own lock; 1 disables the sharding, which is the default. The reader goroutines of the peers look
+// up the messages they receive in the cache, so sharding it spares them contention on nodes with
+// many cores and peers: on a single core it only adds overhead.
+func WithSeenMessagesShards(n int) Option {
+	return func(ps *PubSub) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid number of seen messages shards; must be positive")
+		}
+		ps.seenMsgShards = n
+		return nil
+	}
+}
+
 // WithAppSpecificRpcInspector sets a hook that inspect incomings RPCs prior to
 // processing them.  The inspector is invoked on an accepted RPC just before it
 // is handled.  If inspector's error is nil, the RPC is handled. Otherwise, it
 // is dropped.
+// The copies of messages we have already seen are dropped from the RPC on
+// receipt, so the inspector does not see them.
+// The Data of the published messages is borrowed from the buffer the RPC was
+// read into, and is only valid for the duration of the call; inspectors
+// keeping it must copy it.
 func WithAppSpecificRpcInspector(inspector func(peer.ID, *RPC) error) Option {
 	return func(ps *PubSub) error {
 		ps.appSpecificRpcInspector = inspector
@@ -574,28 +945,49 @@ func (p *PubSub) processLoop(ctx context.Context) {
 		case <-p.newPeers:
 			p.handlePendingPeers()
 
-		case s := <-p.newPeerStream:
-			pid := s.Conn().RemotePeer()
+		case ps := <-p.newPeerStream:
+			s := ps.s
+			pid := s.RemotePeer()
 
 			ch, ok := p.peers[pid]
-			if !ok {
-				log.Warn("new stream for unknown peer: ", pid)
+			if !ok || p.writers[pid] != ps.w {
+				p.logger.Warnw("new stream for unknown peer", "peer", pid)
 				s.Reset()
 				continue
 			}
 
 			if p.blacklist.Contains(pid) {
-				log.Warn("closing stream for blacklisted peer: ", pid)
+				p.logger.Warnw("closing stream for blacklisted peer", "peer", pid)
 				close(ch)
 				delete(p.peers, pid)
+				p.removeWriter(pid)
 				s.Reset()
 				continue
 			}
 
-			p.rt.AddPeer(pid, s.Protocol())
-
-		case pid := <-p.newPeerError:
-			delete(p.peers, pid)
+			ps.w.stream = s
+			proto, _ := p.routerProtocol(s.Protocol())
+			if known, ok := p.peerProtos[pid]; ok {
+				// the peer reconnected within the disconnect grace period, and the router still
+				// knows it
+				if known == proto {
+					continue
+				}
+				p.rt.RemovePeer(pid)
+			}
+			p.peerProtos[pid] = proto
+			p.rt.AddPeer(pid, proto)
+
+		case w := <-p.newPeerError:
+			// the writer may have been replaced since, if the peer was reset
+			if p.writers[w.pid] == w {
+				delete(p.peers, w.pid)
+				p.removeWriter(w.pid)
+				// the router still knows a peer that reconnected within the disconnect grace period
+				if _, ok := p.peerProtos[w.pid]; ok {
+					p.forgetPeer(w.pid)
+				}
+			}
 
 		case <-p.peerDead:
 			p.handleDeadPeers()
@@ -639,7 +1031,17 @@ func (p *PubSub) processLoop(ctx context.Context) {
 			p.handleIncomingRPC(rpc)
 
 		case msg := <-p.sendMsg:
-			p.publishMessage(msg)
+			if p.sched != nil {
+				p.sched.push(msg, true)
+			} else {
+				p.publishMessage(msg)
+			}
+
+		case <-p.announceReady():
+			p.flushAnnouncements()
+
+		case <-p.sched.readyCh():
+			p.handleScheduled()
 
 		case req := <-p.addVal:
 			p.val.AddValidator(req)
@@ -651,24 +1053,27 @@ func (p *PubSub) processLoop(ctx context.Context) {
 			thunk()
 
 		case pid := <-p.blacklistPeer:
-			log.Infof("Blacklisting peer %s", pid)
+			p.logger.Infow("Blacklisting peer", "peer", pid)
 			p.blacklist.Add(pid)
 
 			ch, ok := p.peers[pid]
 			if ok {
 				close(ch)
 				delete(p.peers, pid)
+				p.removeWriter(pid)
 				for t, tmap := range p.topics {
 					if _, ok := tmap[pid]; ok {
 						delete(tmap, pid)
 						p.notifyLeave(t, pid)
 					}
 				}
+				delete(p.peerProtos, pid)
+				p.removeQuotaPeer(pid)
 				p.rt.RemovePeer(pid)
 			}
 
 		case <-ctx.Done():
-			log.Info("pubsub processloop shutting down")
+			p.logger.Infof("pubsub processloop shutting down")
 			return
 		}
 	}
@@ -687,24 +1092,32 @@ func (p *PubSub) handlePendingPeers() {
 	p.newPeersPrioLk.Unlock()
 
 	for pid := range newPeers {
-		if p.host.Network().Connectedness(pid) != network.Connected {
+		if !p.tr.Connected(pid) {
+			continue
+		}
+
+		if p.reconnectPeer(pid) {
 			continue
 		}
 
 		if _, ok := p.peers[pid]; ok {
-			log.Debug("already have connection to peer: ", pid)
+			p.logger.Debugw("already have connection to peer", "peer", pid)
+			// the new connection may be better than the one of our outbound stream
+			p.upgradeStream(pid)
+			// and the router may want to know about it
+			if cr, ok := p.rt.(connRouter); ok {
+				cr.connChanged(pid)
+			}
 			continue
 		}
 
 		if p.blacklist.Contains(pid) {
-			log.Warn("ignoring connection from blacklisted peer: ", pid)
+			p.logger.Warnw("ignoring connection from blacklisted peer", "peer", pid)
 			continue
 		}
 
-		messages := make(chan *RPC, p.peerOutboundQueueSize)
-		messages <- p.getHelloPacket()
-		go p.handleNewPeer(p.ctx, pid, messages)
-		p.peers[pid] = messages
+		w, messages := p.newWriter(pid)
+		go p.handleNewPeer(w, messages)
 	}
 }
 
@@ -728,32 +1141,77 @@ func (p *PubSub) handleDeadPeers() {
 
 		close(ch)
 		delete(p.peers, pid)
+		p.removeWriter(pid)
 
-		for t, tmap := range p.topics {
-			if _, ok := tmap[pid]; ok {
-				delete(tmap, pid)
-				p.notifyLeave(t, pid)
-			}
+		if p.debounceDeadPeer(pid) {
+			continue
 		}
+		p.forgetPeer(pid)
 
-		p.rt.RemovePeer(pid)
-
-		if p.host.Network().Connectedness(pid) == network.Connected {
+		if p.tr.Connected(pid) {
 			backoffDelay, err := p.deadPeerBackoff.updateAndGet(pid)
 			if err != nil {
-				log.Debug(err)
+				p.logger.Debugw("not respawning writer for dead peer", "peer", pid, "err", err)
 				continue
 			}
 
 			// still connected, must be a duplicate connection being closed.
 			// we respawn the writer as we need to ensure there is a stream active
-			log.Debugf("peer declared dead but still connected; respawning writer: %s", pid)
-			messages := make(chan *RPC, p.peerOutboundQueueSize)
-			messages <- p.getHelloPacket()
-			p.peers[pid] = messages
-			go p.handleNewPeerWithBackoff(p.ctx, pid, backoffDelay, messages)
+			p.logger.Debugw("peer declared dead but still connected; respawning writer", "peer", pid)
+			w, messages := p.newWriter(pid)
+			go p.handleNewPeerWithBackoff(w, backoffDelay, messages)
+		}
+	}
+}
+
+// newWriter creates the outbound queue and writer of a peer, queueing our hello packet.
+// Only called from processLoop.
+func (p *PubSub) newWriter(pid peer.ID) (*peerWriter, chan *RPC) {
+	messages := make(chan *RPC, p.peerOutboundQueueSize)
+	messages <- p.getHelloPacket()
+	p.peers[pid] = messages
+	if p.announceTimer != nil {
+		p.announceGreeted[pid] = struct{}{}
+	}
+
+	w := newPeerWriter(p.ctx, pid)
+	p.writers[pid] = w
+	p.enqueued(pid, messages)
+	return w, messages
+}
+
+// removeWriter stops the outbound writer of a peer, whose queue has been closed.
+// Only called from processLoop.
+func (p *PubSub) removeWriter(pid peer.ID) {
+	w, ok := p.writers[pid]
+	if !ok {
+		return
+	}
+
+	delete(p.writers, pid)
+	w.cancel()
+}
+
+// forgetPeer removes a peer whose writer has been torn down from the topics and the router.
+// Only called from processLoop.
+func (p *PubSub) forgetPeer(pid peer.ID) {
+	for t, tmap := range p.topics {
+		if _, ok := tmap[pid]; ok {
+			delete(tmap, pid)
+			p.notifyLeave(t, pid)
 		}
 	}
+
+	if f, ok := p.subFilter.(peerTrackingSubscriptionFilter); ok {
+		f.RemovePeer(pid)
+	}
+
+	delete(p.peerProtos, pid)
+	p.removeQuotaPeer(pid)
+	p.forgetSubChurn(pid)
+	p.connDebounce.forgetPeer(pid)
+	delete(p.malformed, pid)
+	p.rt.RemovePeer(pid)
 }
 
 // handleAddTopic adds a tracker for a particular topic.
@@ -768,6 +1226,16 @@ func (p *PubSub) handleAddTopic(req *addTopicReq) {
 		return
 	}
 
+	if topic.msgIdFn != nil {
+		p.idGen.Set(topicID, topic.msgIdFn)
+	}
+	if topic.sigVerifier != nil {
+		p.sigVerifiers.Set(topicID, topic.sigVerifier)
+	}
+	if len(topic.builtinVals) > 0 {
+		p.val.SetBuiltinValidators(topicID, topic.builtinVals)
+	}
+
 	p.myTopics[topicID] = topic
 	req.resp <- topic
 }
@@ -786,6 +1254,8 @@ func (p *PubSub) handleRemoveTopic(req *rmTopicReq) {
 		len(p.mySubs[req.topic.topic]) == 0 &&
 		p.myRelays[req.topic.topic] == 0 {
 		delete(p.myTopics, topic.topic)
+		p.sigVerifiers.Remove(topic.topic)
+		p.val.SetBuiltinValidators(topic.topic, nil)
 		req.resp <- nil
 		return
 	}
@@ -806,6 +1276,7 @@ func (p *PubSub) handleRemoveSubscription(sub *Subscription) {
 
 	sub.err = ErrSubscriptionCancelled
 	sub.close()
+	sub.standby = nil
 	delete(subs, sub)
 
 	if len(subs) == 0 {
@@ -815,6 +1286,7 @@ func (p *PubSub) handleRemoveSubscription(sub *Subscription) {
 		if p.myRelays[sub.topic] == 0 {
 			p.disc.StopAdvertise(sub.topic)
 			p.announce(sub.topic, false)
+			p.setAccepting(sub.topic, false)
 			p.rt.Leave(sub.topic)
 		}
 	}
@@ -832,6 +1304,7 @@ func (p *PubSub) handleAddSubscription(req *addSubReq) {
 	if len(subs) == 0 && p.myRelays[sub.topic] == 0 {
 		p.disc.Advertise(sub.topic)
 		p.announce(sub.topic, true)
+		p.setAccepting(sub.topic, true)
 		p.rt.Join(sub.topic)
 	}
 
@@ -860,6 +1333,7 @@ func (p *PubSub) handleAddRelay(req *addRelayReq) {
 	if p.myRelays[topic] == 1 && len(p.mySubs[topic]) == 0 {
 		p.disc.Advertise(topic)
 		p.announce(topic, true)
+		p.setAccepting(topic, true)
 		p.rt.Join(topic)
 	}
 
@@ -900,43 +1374,127 @@ func (p *PubSub) handleRemoveRelay(topic string) {
 		if len(p.mySubs[topic]) == 0 {
 			p.disc.StopAdvertise(topic)
 			p.announce(topic, false)
+			p.setAccepting(topic, false)
 			p.rt.Leave(topic)
 		}
 	}
 }
 
-// announce announces whether or not this node is interested in a given topic
+// announce announces whether or not this node is interested in a given topic. The announcements
+// are debounced, so that the changes made within the debounce window are sent in a single RPC,
+// and a change reverted within the window is not announced at all.
 // Only called from processLoop.
 func (p *PubSub) announce(topic string, sub bool) {
-	subopt := &pb.RPC_SubOpts{
-		Topicid:   &topic,
-		Subscribe: &sub,
+	subopts := []*pb.RPC_SubOpts{{Topicid: &topic, Subscribe: &sub}}
+	if p.announceDebounce == 0 {
+		p.sendAnnouncements(subopts)
+		return
+	}
+
+	// the peers greeted in the window know of the pending changes, but not of this one
+	if len(p.announceGreeted) > 0 {
+		out := rpcWithSubs(subopts...)
+		for pid := range p.announceGreeted {
+			if peer, ok := p.peers[pid]; ok {
+				p.sendAnnouncement(pid, peer, out, subopts)
+			}
+		}
+	}
+
+	if pending, ok := p.pendingAnnounce[topic]; ok {
+		if pending != sub {
+			delete(p.pendingAnnounce, topic)
+		}
+		return
+	}
+	p.pendingAnnounce[topic] = sub
+
+	if p.announceTimer == nil {
+		p.announceTimer = time.NewTimer(p.announceDebounce)
+	}
+}
+
+// announceReady returns the channel signaling the end of the debounce window of the pending
+// announcements; it is nil when there is none, so that the event loop never selects it.
+func (p *PubSub) announceReady() <-chan time.Time {
+	if p.announceTimer == nil {
+		return nil
+	}
+	return p.announceTimer.C
+}
+
+// flushAnnouncements sends the pending announcements.
+// Only called from processLoop.
+func (p *PubSub) flushAnnouncements() {
+	p.announceTimer = nil
+	defer func() {
+		p.announceGreeted = make(map[peer.ID]struct{})
+	}()
+
+	if len(p.pendingAnnounce) == 0 {
+		return
+	}
+
+	subopts := make([]*pb.RPC_SubOpts, 0, len(p.pendingAnnounce))
+	for topic, sub := range p.pendingAnnounce {
+		topic, sub := topic, sub
+		subopts = append(subopts, &pb.RPC_SubOpts{Topicid: &topic, Subscribe: &sub})
 	}
+	sort.Slice(subopts, func(i, j int) bool {
+		return subopts[i].GetTopicid() < subopts[j].GetTopicid()
+	})
+	p.pendingAnnounce = make(map[string]bool)
+
+	p.sendAnnouncements(subopts)
+}
 
-	out := rpcWithSubs(subopt)
+// sendAnnouncements sends subscription changes to all our peers, but those greeted since the
+// changes were made.
+// Only called from processLoop.
+func (p *PubSub) sendAnnouncements(subopts []*pb.RPC_SubOpts) {
+	out := rpcWithSubs(subopts...)
 	for pid, peer := range p.peers {
-		select {
-		case peer <- out:
-			p.tracer.SendRPC(out, pid)
-		default:
-			log.Infof("Can't send announce message to peer %s: queue full; scheduling retry", pid)
-			p.tracer.DropRPC(out, pid)
-			go p.announceRetry(pid, topic, sub)
+		if _, ok := p.announceGreeted[pid]; ok {
+			continue
 		}
+		p.sendAnnouncement(pid, peer, out, subopts)
+	}
+}
+
+// sendAnnouncement queues an announcement for a peer, retrying later if its queue is full.
+// Only called from processLoop.
+func (p *PubSub) sendAnnouncement(pid peer.ID, peer chan *RPC, out *RPC, subopts []*pb.RPC_SubOpts) {
+	select {
+	case peer <- out:
+		p.tracer.SendRPC(out, pid)
+		p.enqueued(pid, peer)
+	default:
+		p.logger.Infow("Can't send announce message to peer: queue full; scheduling retry", "peer", pid, "topics", len(subopts))
+		p.tracer.DropRPC(out, pid)
+		go p.announceRetry(pid, subopts)
 	}
 }
 
-func (p *PubSub) announceRetry(pid peer.ID, topic string, sub bool) {
+func (p *PubSub) announceRetry(pid peer.ID, subopts []*pb.RPC_SubOpts) {
 	time.Sleep(time.Duration(1+rand.Intn(1000)) * time.Millisecond)
 
 	retry := func() {
-		_, okSubs := p.mySubs[topic]
-		_, okRelays := p.myRelays[topic]
+		// only announce the changes that still hold
+		var current []*pb.RPC_SubOpts
+		for _, subopt := range subopts {
+			topic := subopt.GetTopicid()
+			_, okSubs := p.mySubs[topic]
+			_, okRelays := p.myRelays[topic]
 
-		ok := okSubs || okRelays
+			ok := okSubs || okRelays
+
+			if ok == subopt.GetSubscribe() {
+				current = append(current, subopt)
+			}
+		}
 
-		if (ok && sub) || (!ok && !sub) {
-			p.doAnnounceRetry(pid, topic, sub)
+		if len(current) > 0 {
+			p.doAnnounceRetry(pid, current)
 		}
 	}
 
@@ -946,39 +1504,57 @@ func (p *PubSub) announceRetry(pid peer.ID, topic string, sub bool) {
 	}
 }
 
-func (p *PubSub) doAnnounceRetry(pid peer.ID, topic string, sub bool) {
+func (p *PubSub) doAnnounceRetry(pid peer.ID, subopts []*pb.RPC_SubOpts) {
 	peer, ok := p.peers[pid]
 	if !ok {
 		return
 	}
 
-	subopt := &pb.RPC_SubOpts{
-		Topicid:   &topic,
-		Subscribe: &sub,
-	}
-
-	out := rpcWithSubs(subopt)
+	out := rpcWithSubs(subopts...)
 	select {
 	case peer <- out:
 		p.tracer.SendRPC(out, pid)
+		p.enqueued(pid, peer)
 	default:
-		log.Infof("Can't send announce message to peer %s: queue full; scheduling retry", pid)
+		p.logger.Infow("Can't send announce message to peer: queue full; scheduling retry", "peer", pid, "topics", len(subopts))
 		p.tracer.DropRPC(out, pid)
-		go p.announceRetry(pid, topic, sub)
+		go p.announceRetry(pid, subopts)
 	}
 }
 
 // notifySubs sends a given message to all corresponding subscribers.
 // Only called from processLoop.
 func (p *PubSub) notifySubs(msg *Message) {
+	if msg.noLocalDelivery {
+		return
+	}
+
+	// unsigned messages have no author, so we go by who sent the message to us
+	self := p.tr.ID()
+	fromSelf := msg.GetFrom() == self || (len(msg.Message.GetFrom()) == 0 && msg.ReceivedFrom == self)
+
 	topic := msg.GetTopic()
 	subs := p.mySubs[topic]
+	if t, ok := p.myTopics[topic]; ok && len(subs) > 0 {
+		t.ephemeral.touch()
+	}
 	for f := range subs {
+		if f.noSelf && fromSelf {
+			continue
+		}
+		if f.standby != nil {
+			f.standby.add(f, msg, p.clock.Now())
+			continue
+		}
+
+		f.acquire(msg)
 		select {
 		case f.ch <- msg:
 		default:
+			f.release(msg)
+			p.slowConsumer(topic)
 			p.tracer.UndeliverableMessage(msg)
-			log.Infof("Can't deliver message to subscription for topic %s; subscriber too slow", topic)
+			p.logger.Infow("Can't deliver message to subscription; subscriber too slow", "topic", topic)
 		}
 	}
 }
@@ -1020,18 +1596,117 @@ func (p *PubSub) canRelayMsg(msg *pb.Message) bool {
 	return relays > 0
 }
 
+// setAccepting adds or removes a topic from the topics we accept messages in.
+// Only called from processLoop.
+func (p *PubSub) setAccepting(topic string, accept bool) {
+	cur, _ := p.accepting.Load().(map[string]struct{})
+	next := make(map[string]struct{}, len(cur)+1)
+	for t := range cur {
+		next[t] = struct{}{}
+	}
+	if accept {
+		next[topic] = struct{}{}
+		delete(p.leftTopics, topic)
+	} else {
+		delete(next, topic)
+		p.leaveTopic(topic)
+	}
+	p.accepting.Store(next)
+}
+
+// leaveTopic records that we left a topic, forgetting the topics left before the window.
+// Only called from processLoop.
+func (p *PubSub) leaveTopic(topic string) {
+	now := time.Now()
+	for t, left := range p.leftTopics {
+		if now.Sub(left) >= LeftTopicWindow {
+			delete(p.leftTopics, t)
+		}
+	}
+	p.leftTopics[topic] = now
+}
+
+// leftTopic returns whether we left the topic of a message within the window.
+// Only called from processLoop.
+func (p *PubSub) leftTopic(msg *pb.Message) bool {
+	left, ok := p.leftTopics[msg.GetTopic()]
+	return ok && time.Since(left) < LeftTopicWindow
+}
+
+// accepts returns whether we are subscribed to or relaying for a topic; it is safe to call from
+// any goroutine, and may lag behind the event loop.
+func (p *PubSub) accepts(topic string) bool {
+	cur, _ := p.accepting.Load().(map[string]struct{})
+	_, ok := cur[topic]
+	return ok
+}
+
 func (p *PubSub) notifyLeave(topic string, pid peer.ID) {
 	if t, ok := p.myTopics[topic]; ok {
-		t.sendNotification(PeerEvent{PeerLeave, pid})
+		t.sendNotification(PeerEvent{Type: PeerLeave, Peer: pid})
+	}
+}
+
+func (p *PubSub) notifyPruned(topic string, pid peer.ID, reason PruneReason) {
+	if t, ok := p.myTopics[topic]; ok {
+		t.sendNotification(PeerEvent{Type: PeerPruned, Peer: pid, Reason: reason})
+	}
+}
+
+// handleSubscription applies a subscription announced by a peer.
+// Only called from processLoop.
+func (p *PubSub) handleSubscription(pid peer.ID, t string, subscribe bool) {
+	if subscribe {
+		tmap, ok := p.topics[t]
+		if !ok {
+			tmap = make(map[peer.ID]struct{})
+			p.topics[t] = tmap
+
+			if gs, ok := p.rt.(*GossipSubRouter); ok {
+				gs.resolveTopicScoreParams(t)
+			}
+		}
+
+		if _, ok = tmap[pid]; !ok {
+			tmap[pid] = struct{}{}
+			p.tracer.PeerSubscribe(pid, t)
+			if gs, ok := p.rt.(*GossipSubRouter); ok {
+				gs.topicActivity(t)
+			}
+			if topic, ok := p.myTopics[t]; ok {
+				topic.sendNotification(PeerEvent{Type: PeerJoin, Peer: pid})
+				if topic.storeForward != nil {
+					topic.storeForward.notify()
+				}
+			}
+		}
+	} else {
+		tmap, ok := p.topics[t]
+		if !ok {
+			return
+		}
+
+		if _, ok := tmap[pid]; ok {
+			delete(tmap, pid)
+			p.tracer.PeerUnsubscribe(pid, t)
+			p.notifyLeave(t, pid)
+			if gs, ok := p.rt.(*GossipSubRouter); ok {
+				gs.topicActivity(t)
+			}
+		}
 	}
 }
 
 func (p *PubSub) handleIncomingRPC(rpc *RPC) {
+	defer p.releaseReadBuffer(rpc)
+
+	p.countMalformed(rpc.from, rpc.malformed)
+
 	// pass the rpc through app specific validation (if any available).
 	if p.appSpecificRpcInspector != nil {
 		// check if the RPC is allowed by the external inspector
 		if err := p.appSpecificRpcInspector(rpc.from, rpc); err != nil {
-			log.Debugf("application-specific inspection failed, rejecting incoming rpc: %s", err)
+			p.logger.Debugw("application-specific inspection failed, rejecting incoming rpc", "peer", rpc.from, "err", err)
 			return // reject the RPC
 		}
 	}
@@ -1043,65 +1718,121 @@ func (p *PubSub) handleIncomingRPC(rpc *RPC) {
 		var err error
 		subs, err = p.subFilter.FilterIncomingSubscriptions(rpc.from, subs)
 		if err != nil {
-			log.Debugf("subscription filter error: %s; ignoring RPC", err)
+			p.logger.Debugw("subscription filter error; ignoring RPC", "peer", rpc.from, "err", err)
+			if p.subFilterPenalty > 0 {
+				if gs, ok := p.rt.(*GossipSubRouter); ok {
+					gs.score.AddPenalty(rpc.from, p.subFilterPenalty, PenaltySubscriptionFilter)
+				}
+			}
 			return
 		}
 	}
 
+	if rpc.hello {
+		p.resyncSubscriptions(rpc.from, subs)
+	}
+
 	for _, subopt := range subs {
 		t := subopt.GetTopicid()
+		if !p.checkSubChurn(rpc.from, t, subopt.GetSubscribe()) {
+			continue
+		}
+		p.handleSubscription(rpc.from, t, subopt.GetSubscribe())
+	}
 
-		if subopt.GetSubscribe() {
-			tmap, ok := p.topics[t]
-			if !ok {
-				tmap = make(map[peer.ID]struct{})
-				p.topics[t] = tmap
-			}
+	// ask the router to vet the peer before commiting any processing resources
+	switch p.rt.AcceptFrom(rpc.from) {
+	case AcceptNone:
+		p.logger.Debugw("received RPC from router graylisted peer; dropping RPC", "peer", rpc.from)
+		return
 
-			if _, ok = tmap[rpc.from]; !ok {
-				tmap[rpc.from] = struct{}{}
-				if topic, ok := p.myTopics[t]; ok {
-					peer := rpc.from
-					topic.sendNotification(PeerEvent{PeerJoin, peer})
-				}
-			}
-		} else {
-			tmap, ok := p.topics[t]
-			if !ok {
+	case AcceptControl:
+		if n := len(rpc.GetPublish()) + len(rpc.dups); n > 0 {
+			p.logger.Debugw("peer was throttled by router; ignoring payload messages", "peer", rpc.from, "messages", n)
+		}
+		p.tracer.ThrottlePeer(rpc.from)
+
+	case AcceptAll:
+		for _, msg := range rpc.dups {
+			if p.subscribedToMsg(msg.Message) || p.canRelayMsg(msg.Message) {
+				p.pushDuplicate(msg)
+			}
+		}
+
+		for i, pmsg := range rpc.GetPublish() {
+			if !(p.subscribedToMsg(pmsg) || p.canRelayMsg(pmsg)) {
+				if p.leftTopic(pmsg) {
+					p.logger.Debugw("received message in topic we left; dropping message", "peer", rpc.from, "topic", pmsg.GetTopic())
+					p.tracer.RejectMessage(&Message{Message: pmsg, ReceivedFrom: rpc.from}, RejectTopicLeft)
+					continue
+				}
+
+				p.logger.Debugw("received message in topic we didn't subscribe to; ignoring message", "peer", rpc.from, "topic", pmsg.GetTopic())
+				if p.unsubscribed != nil {
+					p.handleUnsubscribed(&Message{Message: pmsg, ReceivedFrom: rpc.from, lazy: rpc.buf != nil})
+				}
 				continue
 			}
 
-			if _, ok := tmap[rpc.from]; ok {
-				delete(tmap, rpc.from)
-				p.notifyLeave(t, rpc.from)
+			msg := &Message{Message: pmsg, ID: rpc.id(i), ReceivedFrom: rpc.from, sig: rpc.sig(i), arrived: time.Now(), lazy: rpc.buf != nil}
+			if !p.checkQuota(msg) {
+				continue
+			}
+			if !p.checkMemoryBudget(msg) {
+				continue
+			}
+			if p.sched != nil {
+				p.scheduleMsg(msg)
+			} else {
+				p.pushMsg(msg)
 			}
 		}
 	}
 
-	// ask the router to vet the peer before commiting any processing resources
-	switch p.rt.AcceptFrom(rpc.from) {
-	case AcceptNone:
-		log.Debugf("received RPC from router graylisted peer %s; dropping RPC", rpc.from)
+	p.rt.HandleRPC(rpc)
+}
+
+// identifyIncoming computes the IDs of the messages in an RPC received from a peer, in the reader
+// goroutine of the peer, so that the event loop does not have to: the custom message ID functions
+// may be costly, hashing the message for instance. The messages in topics we don't accept are left
+// unidentified, as they are dropped before their ID is needed.
+func (p *PubSub) identifyIncoming(rpc *RPC) {
+	pmsgs := rpc.GetPublish()
+	if len(pmsgs) == 0 {
 		return
+	}
 
-	case AcceptControl:
-		if len(rpc.GetPublish()) > 0 {
-			log.Debugf("peer %s was throttled by router; ignoring %d payload messages", rpc.from, len(rpc.GetPublish()))
+	rpc.ids = make([]string, len(pmsgs))
+	for i, pmsg := range pmsgs {
+		if p.accepts(pmsg.GetTopic()) {
+			rpc.ids[i] = p.idGen.RawID(pmsg)
 		}
-		p.tracer.ThrottlePeer(rpc.from)
+	}
+}
 
-	case AcceptAll:
-		for _, pmsg := range rpc.GetPublish() {
-			if !(p.subscribedToMsg(pmsg) || p.canRelayMsg(pmsg)) {
-				log.Debug("received message in topic we didn't subscribe to; ignoring message")
-				continue
-			}
+// dropSeenIncoming drops the messages of an RPC received from a peer that we have already seen, in
+// the reader goroutine of the peer, so that only the novel messages are pushed by the event loop.
+// The dropped copies are kept aside in the RPC: the event loop still traces them as duplicates,
+// which credits the peers forwarding a message in their score, and tracks them as its holders.
+func (p *PubSub) dropSeenIncoming(rpc *RPC) {
+	pmsgs := rpc.GetPublish()
+	if len(pmsgs) == 0 {
+		return
+	}
 
-			p.pushMsg(&Message{pmsg, "", rpc.from, nil, false})
+	n := 0
+	for i, pmsg := range pmsgs {
+		id := rpc.id(i)
+		if id != "" && p.seenMessage(id) {
+			rpc.dups = append(rpc.dups, &Message{Message: pmsg, ID: id, ReceivedFrom: rpc.from, lazy: rpc.buf != nil})
+			continue
 		}
+		pmsgs[n] = pmsg
+		rpc.ids[n] = id
+		n++
 	}
-
-	p.rt.HandleRPC(rpc)
+	rpc.Publish = pmsgs[:n]
+	rpc.ids = rpc.ids[:n]
 }
 
 // DefaultMsgIdFn returns a unique ID of the passed Message
@@ -1116,52 +1847,94 @@ func DefaultPeerFilter(pid peer.ID, topic string) bool {
 
 // pushMsg pushes a message performing validation as necessary
 func (p *PubSub) pushMsg(msg *Message) {
+	if !p.checkIncoming(msg) {
+		return
+	}
+
+	src := msg.ReceivedFrom
+
+	// have we already seen and validated this message?
+	id := p.idGen.ID(msg)
+	if p.seenMessage(id) {
+		p.tracer.DuplicateMessage(msg)
+		return
+	}
+
+	// was it rejected by the validators recently?
+	if p.rejected.has(id) {
+		p.logger.Debugw("dropping recently rejected message", "peer", src, "topic", msg.GetTopic())
+		p.tracer.RejectMessage(msg, RejectRecentlyRejected)
+		return
+	}
+
+	// the message outlives the RPC from here on
+	msg.detachData()
+	if !p.val.Push(src, msg) {
+		return
+	}
+
+	if p.markSeen(id) {
+		p.publishMessage(msg)
+	}
+}
+
+// pushDuplicate traces a copy of a message we have already seen, dropped on receipt. It goes through
+// the same checks as the messages pushed before being looked up in the seen messages cache.
+func (p *PubSub) pushDuplicate(msg *Message) {
+	if p.checkIncoming(msg) {
+		p.tracer.DuplicateMessage(msg)
+	}
+}
+
+// checkIncoming checks the source and the signing policy of a message received from a peer,
+// returning false if it is rejected.
+func (p *PubSub) checkIncoming(msg *Message) bool {
 	src := msg.ReceivedFrom
 	// reject messages from blacklisted peers
 	if p.blacklist.Contains(src) {
-		log.Debugf("dropping message from blacklisted peer %s", src)
+		p.logger.Debugw("dropping message from blacklisted peer", "peer", src, "topic", msg.GetTopic())
 		p.tracer.RejectMessage(msg, RejectBlacklstedPeer)
-		return
+		return false
 	}
 
 	// even if they are forwarded by good peers
 	if p.blacklist.Contains(msg.GetFrom()) {
-		log.Debugf("dropping message from blacklisted source %s", src)
+		p.logger.Debugw("dropping message from blacklisted source", "peer", src, "topic", msg.GetTopic(), "source", msg.GetFrom())
 		p.tracer.RejectMessage(msg, RejectBlacklistedSource)
-		return
+		return false
 	}
 
 	err := p.checkSigningPolicy(msg)
 	if err != nil {
-		log.Debugf("dropping message from %s: %s", src, err)
-		return
+		p.logger.Debugw("dropping message", "peer", src, "topic", msg.GetTopic(), "err", err)
+		return false
+	}
+
+	// the signature was found invalid on receipt
+	if msg.sig == sigInvalid {
+		p.logger.Debugw("message signature validation failed; dropping message", "peer", src, "topic", msg.GetTopic())
+		p.tracer.RejectMessage(msg, RejectInvalidSignature)
+		return false
 	}
 
 	// reject messages claiming to be from ourselves but not locally published
-	self := p.host.ID()
+	self := p.tr.ID()
 	if peer.ID(msg.GetFrom()) == self && src != self {
-		log.Debugf("dropping message claiming to be from self but forwarded from %s", src)
+		p.logger.Debugw("dropping message claiming to be from self but forwarded", "peer", src, "topic", msg.GetTopic())
 		p.tracer.RejectMessage(msg, RejectSelfOrigin)
-		return
-	}
-
-	// have we already seen and validated this message?
-	id := p.idGen.ID(msg)
-	if p.seenMessage(id) {
-		p.tracer.DuplicateMessage(msg)
-		return
-	}
-
-	if !p.val.Push(src, msg) {
-		return
+		return false
 	}
 
-	if p.markSeen(id) {
-		p.publishMessage(msg)
-	}
+	return true
 }
 
 func (p *PubSub) checkSigningPolicy(msg *Message) error {
+	// the signature verifier of the topic, if any, decides what it accepts in the validation
+	// pipeline
+	if p.sigVerifiers.Get(msg.GetTopic()) != nil {
+		return nil
+	}
+
 	// reject unsigned messages when strict before we even process the id
 	if p.signPolicy.mustVerify() {
 		if p.signPolicy.mustSign() {
@@ -1194,11 +1967,87 @@ func (p *PubSub) checkSigningPolicy(msg *Message) error {
 }
 
 func (p *PubSub) publishMessage(msg *Message) {
+	delivered := msg
+	if t, ok := p.myTopics[msg.GetTopic()]; ok && t.inboundTransform != nil {
+		delivered = p.transformInbound(t, msg)
+		if delivered == nil {
+			p.tracer.TransformFailed(msg)
+			// the message is not delivered, but it is still valid and forwarded unless configured
+			// otherwise; only then is it ignored
+			if t.dropOnTransformError {
+				p.tracer.RejectMessage(msg, RejectTransformFailed)
+				msg.reportRouted(nil)
+				return
+			}
+		}
+	}
+
+	stale, deliver := p.staleMessage(msg)
+
+	if delivered != nil && p.dupStats != nil {
+		delivered.Duplicates = p.dupStats.delivered(p.idGen.ID(msg))
+	}
+
 	p.tracer.DeliverMessage(msg)
-	p.notifySubs(msg)
-	if !msg.Local {
-		p.rt.Publish(msg)
+	if delivered != nil && deliver {
+		p.notifySubs(delivered)
 	}
+	if msg.Local {
+		return
+	}
+
+	if stale {
+		p.logger.Debugw("not forwarding message: older than the forward deadline", "topic", msg.GetTopic(), "msgid", msg.ID)
+		p.tracer.StaleMessage(msg.Message, "")
+		msg.reportRouted(nil)
+		return
+	}
+
+	if !p.forwarding(msg.GetTopic(), msg.ReceivedFrom == p.tr.ID()) {
+		p.logger.Debugw("not forwarding message: forwarding disabled", "topic", msg.GetTopic(), "msgid", msg.ID)
+		msg.reportRouted(nil)
+		return
+	}
+
+	if rr, ok := p.rt.(retryRouter); ok {
+		if saf := p.storeForward(msg); saf != nil || msg.routed != nil {
+			res := msg.result
+			if res == nil {
+				res = &PublishResult{}
+			}
+			rr.publish(msg, res)
+			if saf != nil && res.Recipients == res.QueueFull {
+				saf.add(msg)
+			}
+			msg.reportRouted(msg.result)
+			return
+		}
+	}
+
+	p.rt.Publish(msg)
+	msg.reportRouted(nil)
+}
+
+// transformInbound applies the inbound transform of the topic to a copy of the message, leaving
+// the wire form intact for forwarding; it returns nil if the transform failed.
+func (p *PubSub) transformInbound(t *Topic, msg *Message) *Message {
+	pmsg := *msg.Message
+	out := &Message{
+		Message:         &pmsg,
+		ID:              msg.ID,
+		ReceivedFrom:    msg.ReceivedFrom,
+		ValidatorData:   msg.ValidatorData,
+		Local:           msg.Local,
+		noLocalDelivery: msg.noLocalDelivery,
+		metadata:        msg.metadata,
+	}
+
+	if err := t.inboundTransform(out); err != nil {
+		p.logger.Debugw("inbound transform failed", "peer", msg.ReceivedFrom, "topic", t.topic, "msgid", msg.ID, "err", err)
+		return nil
+	}
+
+	return out
 }
 
 type addTopicReq struct {
@@ -1218,14 +2067,59 @@ type TopicOpt func(t *Topic) error
 // WithTopicMessageIdFn sets custom MsgIdFunction for a Topic, enabling topics to have own msg id generation rules.
 func WithTopicMessageIdFn(msgId MsgIdFunction) TopicOpt {
 	return func(t *Topic) error {
-		t.p.idGen.Set(t.topic, msgId)
+		t.msgIdFn = msgId
 		return nil
 	}
 }
 
-// Join joins the topic and returns a Topic handle. Only one Topic handle should exist per topic, and Join will error if
-// the Topic handle already exists.
+// OutboundTransform transforms the payload of a message we publish before it is signed.
+type OutboundTransform func([]byte) ([]byte, error)
+
+// InboundTransform transforms a validated message before it is delivered to our subscriptions.
+// The message is a copy of the one received, so that the transform can replace its Data without
+// affecting the message we forward.
+type InboundTransform func(*Message) error
+
+// WithOutboundTransform sets a transform applied to the payload of the messages published in the
+// topic, before they are signed. The message ID is computed on the transformed payload, which is
+// what is sent on the wire.
+func WithOutboundTransform(fn OutboundTransform) TopicOpt {
+	return func(t *Topic) error {
+		t.outboundTransform = fn
+		return nil
+	}
+}
+
+// WithInboundTransform sets a transform applied to the messages of the topic after validation,
+// before they are delivered to our subscriptions; messages are forwarded in their wire form.
+// Messages failing the transform are not delivered locally, but they are still valid: they are
+// forwarded and traced as delivered unless WithDropOnTransformError is used. The failures are
+// reported to the raw tracers implementing TransformFailureTracer.
+func WithInboundTransform(fn InboundTransform) TopicOpt {
+	return func(t *Topic) error {
+		t.inboundTransform = fn
+		return nil
+	}
+}
+
+// WithDropOnTransformError makes messages failing the inbound transform of the topic be ignored
+// altogether, rather than only locally: they are not forwarded either, and are traced as rejected
+// with RejectTransformFailed.
+func WithDropOnTransformError() TopicOpt {
+	return func(t *Topic) error {
+		t.dropOnTransformError = true
+		return nil
+	}
+}
+
+// Join joins the topic and returns a Topic handle. Join will error if the topic has already been joined;
+// use TryJoin to share the topic between components.
 func (p *PubSub) Join(topic string, opts ...TopicOpt) (*Topic, error) {
+	var site HandleInfo
+	if p.topicAudit {
+		site = callSite(true)
+	}
+
 	t, ok, err := p.tryJoin(topic, opts...)
 	if err != nil {
 		return nil, err
@@ -1235,7 +2129,178 @@ func (p *PubSub) Join(topic string, opts ...TopicOpt) (*Topic, error) {
 		return nil, fmt.Errorf("topic already exists")
 	}
 
-	return t, nil
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.newHandle(site), nil
+}
+
+// TryJoin joins the topic and returns a Topic handle, like Join, but also succeeds if the topic has
+// already been joined. The returned flag is true if the topic was newly joined.
+// Every call returns its own handle, so that the topic can be shared between components: closing a
+// handle only releases the reference of its caller, and the topic is closed down once every handle
+// returned by Join, TryJoin and GetTopic has been closed.
+// Topic options can only be applied when the topic is joined; passing options when the topic
+// has already been joined results in an error if they differ from the options the topic was
+// joined with.
+func (p *PubSub) TryJoin(topic string, opts ...TopicOpt) (*Topic, bool, error) {
+	var site HandleInfo
+	if p.topicAudit {
+		site = callSite(true)
+	}
+
+	for {
+		t, ok, err := p.tryJoin(topic, opts...)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if ok {
+			t.mux.Lock()
+			h := t.newHandle(site)
+			t.mux.Unlock()
+			return h, true, nil
+		}
+
+		if len(opts) > 0 {
+			req, err := p.newTopic(topic, opts...)
+			if err != nil {
+				return nil, false, err
+			}
+			if err := t.checkOptions(req.topicState); err != nil {
+				return nil, false, err
+			}
+		}
+
+		t.mux.Lock()
+		if t.closed {
+			// the handle was closed concurrently; try again with a new one
+			t.mux.Unlock()
+			continue
+		}
+		t.refs++
+		h := t.newHandle(site)
+		t.mux.Unlock()
+
+		return h, false, nil
+	}
+}
+
+// checkOptions returns an error if the settings of a topic requested with TryJoin, given by the
+// options applied to req, conflict with the settings the topic was joined with. Settings left
+// unset by the options don't conflict. Functions are compared by their code, as they have no
+// equality in Go; closures created by the same function literal are taken to be the same.
+func (t *topicState) checkOptions(req *topicState) error {
+	conflict := func(setting string) error {
+		return fmt.Errorf("topic already exists with a different %s; cannot apply options to an existing topic handle", setting)
+	}
+
+	if req.msgIdFn != nil && !sameFunc(req.msgIdFn, t.msgIdFn) {
+		return conflict("message ID function")
+	}
+	if req.outboundTransform != nil && !sameFunc(req.outboundTransform, t.outboundTransform) {
+		return conflict("outbound transform")
+	}
+	if req.inboundTransform != nil && !sameFunc(req.inboundTransform, t.inboundTransform) {
+		return conflict("inbound transform")
+	}
+	if req.dropOnTransformError && !t.dropOnTransformError {
+		return conflict("transform error policy")
+	}
+
+	if sf := req.storeForward; sf != nil {
+		cur := t.storeForward
+		if cur == nil || !sameValue(sf.store, cur.store) || sf.maxAge != cur.maxAge || sf.maxEntries != cur.maxEntries {
+			return conflict("store-and-forward")
+		}
+	}
+	if fd := req.forwardDeadline; fd != nil {
+		cur := t.forwardDeadline
+		if cur == nil || fd.deadline != cur.deadline || fd.dropStale != cur.dropStale || !sameFunc(fd.originTime, cur.originTime) {
+			return conflict("forward deadline")
+		}
+	}
+	if pl := req.publishLimiter; pl != nil {
+		cur := t.publishLimiter
+		if cur == nil || pl.rate != cur.rate || pl.burst != cur.burst || pl.action != cur.action {
+			return conflict("publish rate limit")
+		}
+	}
+
+	if req.signer != nil && !sameValue(req.signer, t.signer) {
+		return conflict("signer")
+	}
+	if req.sigVerifier != nil && !sameFunc(req.sigVerifier, t.sigVerifier) {
+		return conflict("signature verifier")
+	}
+
+	if len(req.timestampVals) > 0 {
+		if len(req.timestampVals) != len(t.timestampVals) {
+			return conflict("timestamp validation")
+		}
+		for i, tv := range req.timestampVals {
+			cur := t.timestampVals[i]
+			if !sameFunc(tv.extract, cur.extract) || tv.maxPast != cur.maxPast || tv.maxFuture != cur.maxFuture ||
+				tv.stale != cur.stale || tv.future != cur.future || tv.invalid != cur.invalid {
+				return conflict("timestamp validation")
+			}
+		}
+	}
+
+	return nil
+}
+
+// sameFunc returns whether two functions of the same type are both nil or have the same code.
+func sameFunc(a, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.IsNil() || vb.IsNil() {
+		return va.IsNil() && vb.IsNil()
+	}
+	return va.Pointer() == vb.Pointer()
+}
+
+// sameValue returns whether two values are equal; values that can't be compared are never equal.
+func sameValue(a, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() {
+		return va.IsValid() == vb.IsValid()
+	}
+	return va.Type() == vb.Type() && va.Comparable() && va.Equal(vb)
+}
+
+// GetTopic returns a new handle for a topic we have joined, if any. Like the handles returned by
+// TryJoin, it holds a reference to the topic that must be released with Close.
+func (p *PubSub) GetTopic(topic string) (*Topic, bool) {
+	var site HandleInfo
+	if p.topicAudit {
+		site = callSite(true)
+	}
+
+	t := p.joinedTopic(topic)
+	if t == nil {
+		return nil, false
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.closed {
+		return nil, false
+	}
+	t.refs++
+	return t.newHandle(site), true
+}
+
+// joinedTopic returns the handle kept for a topic we have joined, if any.
+func (p *PubSub) joinedTopic(topic string) *Topic {
+	out := make(chan *Topic, 1)
+	select {
+	case p.eval <- func() {
+		out <- p.myTopics[topic]
+	}:
+	case <-p.ctx.Done():
+		return nil
+	}
+
+	return <-out
 }
 
 // tryJoin is an internal function that tries to join a topic
@@ -1247,17 +2312,9 @@ func (p *PubSub) tryJoin(topic string, opts ...TopicOpt) (*Topic, bool, error) {
 		return nil, false, fmt.Errorf("topic is not allowed by the subscription filter")
 	}
 
-	t := &Topic{
-		p:           p,
-		topic:       topic,
-		evtHandlers: make(map[*TopicEventHandler]struct{}),
-	}
-
-	for _, opt := range opts {
-		err := opt(t)
-		if err != nil {
-			return nil, false, err
-		}
+	t, err := p.newTopic(topic, opts...)
+	if err != nil {
+		return nil, false, err
 	}
 
 	resp := make(chan *Topic, 1)
@@ -1275,9 +2332,32 @@ func (p *PubSub) tryJoin(topic string, opts ...TopicOpt) (*Topic, bool, error) {
 		return returnedTopic, false, nil
 	}
 
+	if t.storeForward != nil {
+		t.storeForward.start()
+	}
+
 	return t, true, nil
 }
 
+// newTopic creates the handle of a topic about to be joined, with its options applied.
+func (p *PubSub) newTopic(topic string, opts ...TopicOpt) (*Topic, error) {
+	t := &Topic{topicState: &topicState{
+		p:           p,
+		topic:       topic,
+		evtHandlers: make(map[*TopicEventHandler]struct{}),
+		refs:        1,
+	}}
+
+	for _, opt := range opts {
+		err := opt(t)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
 type addSubReq struct {
 	sub  *Subscription
 	resp chan *Subscription
@@ -1310,6 +2390,16 @@ func WithBufferSize(size int) SubOpt {
 	}
 }
 
+// WithoutSelfMessages is a Subscribe option that suppresses the delivery of messages published by
+// the local peer to the subscription. Messages are considered to be our own if we are their author or,
+// for unsigned messages without an author, if they were published locally.
+func WithoutSelfMessages() SubOpt {
+	return func(sub *Subscription) error {
+		sub.noSelf = true
+		return nil
+	}
+}
+
 type topicReq struct {
 	resp chan []string
 }
@@ -1325,6 +2415,35 @@ func (p *PubSub) GetTopics() []string {
 	return <-out
 }
 
+// LastHeartbeat returns the completion time and sequence number of the last router heartbeat.
+// It does not go through the event loop, so it can be used to detect a stalled router.
+// Only the gossipsub router runs a heartbeat; with other routers, the zero values are returned.
+func (p *PubSub) LastHeartbeat() (time.Time, uint64) {
+	gs, ok := p.rt.(*GossipSubRouter)
+	if !ok {
+		return time.Time{}, 0
+	}
+
+	return gs.lastHeartbeat()
+}
+
+// JoinedTopics returns the topics for which we currently have a Topic handle.
+func (p *PubSub) JoinedTopics() []string {
+	out := make(chan []string, 1)
+	select {
+	case p.eval <- func() {
+		topics := make([]string, 0, len(p.myTopics))
+		for t := range p.myTopics {
+			topics = append(topics, t)
+		}
+		out <- topics
+	}:
+	case <-p.ctx.Done():
+		return nil
+	}
+	return <-out
+}
+
 // Publish publishes data to the given topic.
 //
 // Deprecated: use pubsub.Join() and topic.Publish() instead
@@ -1338,11 +2457,28 @@ func (p *PubSub) Publish(topic string, data []byte, opts ...PubOpt) error {
 	return t.Publish(context.TODO(), data, opts...)
 }
 
-func (p *PubSub) nextSeqno() []byte {
+func (p *PubSub) nextSeqno(ctx context.Context) ([]byte, error) {
+	var counter uint64
+	if p.seqnoPersist != nil {
+		var err error
+		counter, err = p.seqnoPersist.next(ctx, &p.counter)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		counter = atomic.AddUint64(&p.counter, 1)
+	}
+
 	seqno := make([]byte, 8)
-	counter := atomic.AddUint64(&p.counter, 1)
 	binary.BigEndian.PutUint64(seqno, counter)
-	return seqno
+	return seqno, nil
+}
+
+// MessageID computes the ID of msg with the message ID function configured for its topic, or the
+// one configured with WithMessageIdFn; this is the ID of the messages delivered to subscriptions and
+// tracers.
+func (p *PubSub) MessageID(msg *pb.Message) string {
+	return p.idGen.RawID(msg)
 }
 
 type listPeerReq struct {
@@ -1364,6 +2500,92 @@ func (p *PubSub) ListPeers(topic string) []peer.ID {
 	return <-out
 }
 
+// PeerInfo describes a peer we are connected to in a topic.
+type PeerInfo struct {
+	// ID is the peer ID.
+	ID peer.ID
+	// Protocol is the pubsub protocol negotiated with the peer; it is empty if we have not
+	// yet opened a stream to the peer.
+	Protocol protocol.ID
+	// Mesh is true if the peer is in our mesh for the topic; it is always false with routers
+	// that don't maintain a mesh.
+	Mesh bool
+}
+
+// ListPeersDetailed is like ListPeers, but also reports the protocol negotiated with each peer
+// and whether the peer is in our mesh for the topic.
+func (p *PubSub) ListPeersDetailed(topic string) []PeerInfo {
+	out := make(chan []PeerInfo, 1)
+	select {
+	case p.eval <- func() {
+		tmap, ok := p.topics[topic]
+		if topic != "" && !ok {
+			out <- nil
+			return
+		}
+
+		var mesh map[peer.ID]struct{}
+		if gs, ok := p.rt.(*GossipSubRouter); ok {
+			mesh = gs.mesh[topic]
+		}
+
+		var peers []PeerInfo
+		for pid := range p.peers {
+			if topic != "" {
+				if _, ok := tmap[pid]; !ok {
+					continue
+				}
+			}
+			_, inMesh := mesh[pid]
+			peers = append(peers, PeerInfo{ID: pid, Protocol: p.peerProtos[pid], Mesh: inMesh})
+		}
+		out <- peers
+	}:
+	case <-p.ctx.Done():
+		return nil
+	}
+	return <-out
+}
+
+// PeerProtocol returns the pubsub protocol negotiated with a peer, if the peer has been
+// added to the router.
+func (p *PubSub) PeerProtocol(pid peer.ID) (protocol.ID, bool) {
+	type result struct {
+		proto protocol.ID
+		ok    bool
+	}
+
+	out := make(chan result, 1)
+	select {
+	case p.eval <- func() {
+		proto, ok := p.peerProtos[pid]
+		out <- result{proto, ok}
+	}:
+	case <-p.ctx.Done():
+		return "", false
+	}
+	res := <-out
+	return res.proto, res.ok
+}
+
+// PeersByProtocol returns the peers that have been added to the router, grouped by
+// the pubsub protocol negotiated with them.
+func (p *PubSub) PeersByProtocol() map[protocol.ID][]peer.ID {
+	out := make(chan map[protocol.ID][]peer.ID, 1)
+	select {
+	case p.eval <- func() {
+		res := make(map[protocol.ID][]peer.ID)
+		for pid, proto := range p.peerProtos {
+			res[proto] = append(res[proto], pid)
+		}
+		out <- res
+	}:
+	case <-p.ctx.Done():
+		return nil
+	}
+	return <-out
+}
+
 // BlacklistPeer blacklists a peer; all messages from this peer will be unconditionally dropped.
 func (p *PubSub) BlacklistPeer(pid peer.ID) {
 	select {
@@ -1376,6 +2598,7 @@ func (p *PubSub) BlacklistPeer(pid peer.ID) {
 // By default validators are asynchronous, which means they will run in a separate goroutine.
 // The number of active goroutines is controlled by global and per topic validator
 // throttles; if it exceeds the throttle threshold, messages will be dropped.
+// The validator is a Validator, a ValidatorEx, or a BatchValidator registered WithBatchValidation.
 func (p *PubSub) RegisterTopicValidator(topic string, val interface{}, opts ...ValidatorOpt) error {
 	addVal := &addValReq{
 		topic:    topic,
@@ -2,8 +2,14 @@ package pubsub
 
 import (
 	"context"
+	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 // See https://github.com/libp2p/go-libp2p-pubsub/issues/426
@@ -47,3 +53,326 @@ func TestPubSubRemovesBlacklistedPeer(t *testing.T) {
 	cancel()
 	time.Sleep(time.Millisecond * 100)
 }
+
+func TestPubSubPeerProtocols(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 3)
+
+	gsubs := getGossipsubs(ctx, hosts[:2])
+	fsub := getPubsub(ctx, hosts[2])
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[0], hosts[2])
+
+	for _, ps := range append(gsubs, fsub) {
+		if _, err := ps.Subscribe("test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// wait for the mesh to form
+	time.Sleep(2 * time.Second)
+
+	proto, ok := gsubs[0].PeerProtocol(hosts[1].ID())
+	if !ok || proto != GossipSubID_v11 {
+		t.Fatalf("expected gossipsub peer to speak %s, got %s (%v)", GossipSubID_v11, proto, ok)
+	}
+	proto, ok = gsubs[0].PeerProtocol(hosts[2].ID())
+	if !ok || proto != FloodSubID {
+		t.Fatalf("expected floodsub peer to speak %s, got %s (%v)", FloodSubID, proto, ok)
+	}
+
+	byProto := gsubs[0].PeersByProtocol()
+	if len(byProto) != 2 {
+		t.Fatalf("expected 2 protocols, got %v", byProto)
+	}
+	if peers := byProto[GossipSubID_v11]; len(peers) != 1 || peers[0] != hosts[1].ID() {
+		t.Fatalf("unexpected gossipsub peers: %v", peers)
+	}
+	if peers := byProto[FloodSubID]; len(peers) != 1 || peers[0] != hosts[2].ID() {
+		t.Fatalf("unexpected floodsub peers: %v", peers)
+	}
+
+	infos := gsubs[0].ListPeersDetailed("test")
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 peers in topic, got %d", len(infos))
+	}
+	byID := make(map[peer.ID]PeerInfo)
+	for _, pi := range infos {
+		byID[pi.ID] = pi
+	}
+	if pi := byID[hosts[1].ID()]; pi.Protocol != GossipSubID_v11 || !pi.Mesh {
+		t.Fatalf("unexpected info for gossipsub peer: %+v", pi)
+	}
+	if pi := byID[hosts[2].ID()]; pi.Protocol != FloodSubID || pi.Mesh {
+		t.Fatalf("unexpected info for floodsub peer: %+v", pi)
+	}
+
+	if infos := gsubs[0].ListPeersDetailed("nonexistent"); infos != nil {
+		t.Fatalf("expected no peers in unknown topic, got %v", infos)
+	}
+
+	// the floodsub peer leaves; it should no longer be classified
+	hosts[2].Close()
+	time.Sleep(time.Second)
+
+	if _, ok := gsubs[0].PeerProtocol(hosts[2].ID()); ok {
+		t.Fatal("expected disconnected peer to have no protocol")
+	}
+	byProto = gsubs[0].PeersByProtocol()
+	if _, ok := byProto[FloodSubID]; ok {
+		t.Fatalf("expected no floodsub peers, got %v", byProto)
+	}
+}
+
+func TestPubSubProtocolPrefix(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 3)
+
+	// hosts 0 and 1 run both namespaces, host 2 only runs namespace B
+	psA := []*PubSub{
+		getGossipsub(ctx, hosts[0], WithProtocolPrefix("/appA")),
+		getGossipsub(ctx, hosts[1], WithProtocolPrefix("/appA")),
+	}
+	psB := []*PubSub{
+		getPubsub(ctx, hosts[0], WithProtocolPrefix("/appB")),
+		getPubsub(ctx, hosts[1], WithProtocolPrefix("/appB")),
+		getPubsub(ctx, hosts[2], WithProtocolPrefix("/appB")),
+	}
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[0], hosts[2])
+
+	subA := make([]*Subscription, len(psA))
+	for i, ps := range psA {
+		sub, err := ps.Subscribe("shared")
+		if err != nil {
+			t.Fatal(err)
+		}
+		subA[i] = sub
+	}
+	subB := make([]*Subscription, len(psB))
+	for i, ps := range psB {
+		sub, err := ps.Subscribe("shared")
+		if err != nil {
+			t.Fatal(err)
+		}
+		subB[i] = sub
+	}
+
+	time.Sleep(2 * time.Second)
+
+	byProto := psA[0].PeersByProtocol()
+	if len(byProto) != 1 || len(byProto[GossipSubID_v11]) != 1 || byProto[GossipSubID_v11][0] != hosts[1].ID() {
+		t.Fatalf("expected only host 1 as a gossipsub peer in namespace A, got %v", byProto)
+	}
+	if peers := psA[0].ListPeers("shared"); len(peers) != 1 || peers[0] != hosts[1].ID() {
+		t.Fatalf("expected only host 1 in the topic in namespace A, got %v", peers)
+	}
+	if peers := psB[0].ListPeers("shared"); len(peers) != 2 {
+		t.Fatalf("expected 2 peers in the topic in namespace B, got %v", peers)
+	}
+
+	if err := psA[1].Publish("shared", []byte("A")); err != nil {
+		t.Fatal(err)
+	}
+	if err := psB[1].Publish("shared", []byte("B")); err != nil {
+		t.Fatal(err)
+	}
+
+	expectMessage := func(sub *Subscription, data string) {
+		t.Helper()
+
+		mctx, mcancel := context.WithTimeout(ctx, 5*time.Second)
+		defer mcancel()
+		msg, err := sub.Next(mctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(msg.Data) != data {
+			t.Fatalf("expected message %q, got %q", data, msg.Data)
+		}
+	}
+	expectNoMessage := func(sub *Subscription) {
+		t.Helper()
+
+		mctx, mcancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		defer mcancel()
+		if msg, err := sub.Next(mctx); err == nil {
+			t.Fatalf("unexpected message %q", msg.Data)
+		}
+	}
+
+	for _, sub := range subA {
+		expectMessage(sub, "A")
+		expectNoMessage(sub)
+	}
+	for _, sub := range subB {
+		expectMessage(sub, "B")
+		expectNoMessage(sub)
+	}
+}
+
+func TestPubSubProtocolPrefixInvalid(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	for _, prefix := range []string{"", "appA", "/appA/"} {
+		if _, err := NewGossipSub(ctx, hosts[0], WithProtocolPrefix(prefix)); err == nil {
+			t.Fatalf("expected an error for the prefix %q", prefix)
+		}
+	}
+}
+
+// Test the concurrent identification of the messages received from many peers, and their lookup in
+// the sharded seen messages cache, under the race detector.
+func TestConcurrentInbound(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const senders = 8
+	const count = 50
+	hosts := getNetHosts(t, ctx, senders+1)
+	// the outbound queues hold the whole burst, so nothing is dropped on the way
+	queue := WithPeerOutboundQueueSize(senders * count)
+	psubs := []*PubSub{getPubsub(ctx, hosts[0], WithSeenMessagesShards(16), WithValidateQueueSize(1024), queue)}
+	for _, h := range hosts[1:] {
+		psubs = append(psubs, getPubsub(ctx, h, queue))
+	}
+
+	sub, err := psubs[0].Subscribe("foobar", WithBufferSize(senders*count))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var topics []*Topic
+	for _, ps := range psubs[1:] {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+	// the senders forward each other's messages, so the receiver gets duplicates from all of them
+	connectAll(t, hosts)
+	time.Sleep(100 * time.Millisecond)
+
+	// the senders publish concurrently, so the readers of the receiver run concurrently
+	errs := make(chan error, senders)
+	for i, topic := range topics {
+		go func(i int, topic *Topic) {
+			for n := 0; n < count; n++ {
+				if err := topic.Publish(ctx, []byte(fmt.Sprintf("%d-%d", i, n))); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}(i, topic)
+	}
+	for range topics {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	received := make(map[string]struct{})
+	for len(received) < senders*count {
+		rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+		msg, err := sub.Next(rctx)
+		rcancel()
+		if err != nil {
+			t.Fatalf("received %d messages out of %d: %s", len(received), senders*count, err)
+		}
+		if _, ok := received[string(msg.Data)]; ok {
+			t.Fatalf("duplicate delivery of %q", msg.Data)
+		}
+		received[string(msg.Data)] = struct{}{}
+	}
+}
+
+func TestIdentifyIncoming(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	var calls int32
+	ps := getPubsub(ctx, hosts[0],
+		WithMessageSignaturePolicy(LaxNoSign),
+		WithMessageIdFn(func(pmsg *pb.Message) string {
+			atomic.AddInt32(&calls, 1)
+			return DefaultMsgIdFn(pmsg)
+		}),
+	)
+
+	topic, other := "foobar", "other"
+	sub, err := ps.Subscribe(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &pb.Message{From: []byte("author"), Seqno: []byte("1"), Topic: &topic, Data: []byte("hello")}
+	rpc := &RPC{RPC: pb.RPC{Publish: []*pb.Message{
+		msg,
+		{From: []byte("author"), Seqno: []byte("2"), Topic: &other, Data: []byte("other")},
+	}}, from: "peer"}
+
+	// the messages in topics we don't accept are not identified
+	ps.identifyIncoming(rpc)
+	if rpc.id(0) != DefaultMsgIdFn(msg) || rpc.id(1) != "" {
+		t.Fatalf("unexpected message IDs %q", rpc.ids)
+	}
+
+	// the event loop uses the IDs computed on receipt
+	ps.eval <- func() {
+		ps.handleIncomingRPC(rpc)
+	}
+	expectMessage(t, ctx, sub, "hello")
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("expected the message ID to be computed once, got %d", n)
+	}
+}
+
+func TestDropSeenIncoming(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	tracer := &duplicateTracer{}
+	ps := getPubsub(ctx, hosts[0], WithMessageSignaturePolicy(LaxNoSign), WithEventTracer(tracer))
+
+	topic := "foobar"
+	sub, err := ps.Subscribe(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := &pb.Message{From: []byte("author"), Seqno: []byte("1"), Topic: &topic, Data: []byte("hello")}
+	rpc := &RPC{RPC: pb.RPC{Publish: []*pb.Message{seen}}, from: "peer"}
+	ps.prepareIncoming(rpc)
+	ps.eval <- func() {
+		ps.handleIncomingRPC(rpc)
+	}
+	expectMessage(t, ctx, sub, "hello")
+
+	// the copy of the message we have seen is dropped on receipt, but still traced as a duplicate
+	fresh := &pb.Message{From: []byte("author"), Seqno: []byte("2"), Topic: &topic, Data: []byte("world")}
+	rpc = &RPC{RPC: pb.RPC{Publish: []*pb.Message{seen, fresh}}, from: "peer"}
+	ps.prepareIncoming(rpc)
+	if len(rpc.Publish) != 1 || rpc.Publish[0] != fresh || rpc.id(0) != DefaultMsgIdFn(fresh) {
+		t.Fatalf("expected only the fresh message to be left, got %v", rpc.Publish)
+	}
+	if len(rpc.dups) != 1 || rpc.dups[0].ID != DefaultMsgIdFn(seen) {
+		t.Fatalf("expected the copy of the seen message to be dropped, got %v", rpc.dups)
+	}
+
+	ps.eval <- func() {
+		ps.handleIncomingRPC(rpc)
+	}
+	expectMessage(t, ctx, sub, "world")
+	if dups, _ := tracer.counts(); dups != 1 {
+		t.Fatalf("expected 1 duplicate, got %d", dups)
+	}
+}
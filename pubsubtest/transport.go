@@ -0,0 +1,377 @@
+// Package pubsubtest provides an in-memory pubsub.Transport, which allows running PubSub nodes
+// without libp2p hosts in tests and simulations.
+package pubsubtest
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"sync"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// Network is an in-memory network connecting Transports.
+type Network struct {
+	mx    sync.Mutex
+	nodes map[peer.ID]*Transport
+	// the connections between two peers, oldest first
+	conns map[peer.ID]map[peer.ID][]*conn
+	// the number of connections made, for their ids
+	connCount int
+}
+
+// conn is a connection between two peers; both peers refer to the same conn.
+type conn struct {
+	id        string
+	relayed   bool
+	transient bool
+	dialer    peer.ID
+	streams   map[*stream]struct{}
+}
+
+// NewNetwork returns a new empty network.
+func NewNetwork() *Network {
+	return &Network{
+		nodes: make(map[peer.ID]*Transport),
+		conns: make(map[peer.ID]map[peer.ID][]*conn),
+	}
+}
+
+// NewTransport adds a new node with a fresh identity to the network and returns its transport.
+func (n *Network) NewTransport() (*Transport, error) {
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Transport{net: n, id: id, key: key}
+
+	n.mx.Lock()
+	n.nodes[id] = t
+	n.mx.Unlock()
+
+	return t, nil
+}
+
+// Connect connects two nodes directly, with a dialing b; it is a no-op if they are already
+// connected directly. If they are only connected through a relay, the direct connection is added
+// next to the relayed one.
+func (n *Network) Connect(a, b peer.ID) error {
+	return n.connect(a, b, false, false)
+}
+
+// ConnectRelayed connects two nodes through a relay, with a dialing b; it is a no-op if they are
+// already connected.
+func (n *Network) ConnectRelayed(a, b peer.ID) error {
+	return n.connect(a, b, true, false)
+}
+
+// ConnectTransient connects two nodes through a limited relay, with a dialing b; it is a no-op if
+// they are already connected. The connection is reported as transient, but unlike with libp2p
+// hosts, streams can be opened on it.
+func (n *Network) ConnectTransient(a, b peer.ID) error {
+	return n.connect(a, b, true, true)
+}
+
+func (n *Network) connect(a, b peer.ID, relayed, transient bool) error {
+	if a == b {
+		return fmt.Errorf("can't connect %s to itself", a)
+	}
+
+	n.mx.Lock()
+	ta, okA := n.nodes[a]
+	tb, okB := n.nodes[b]
+	if !okA || !okB {
+		n.mx.Unlock()
+		return fmt.Errorf("unknown peer")
+	}
+	for _, c := range n.conns[a][b] {
+		if relayed || !c.relayed {
+			n.mx.Unlock()
+			return nil
+		}
+	}
+
+	n.connCount++
+	c := &conn{
+		id:        fmt.Sprintf("conn-%d", n.connCount),
+		relayed:   relayed,
+		transient: transient,
+		dialer:    a,
+		streams:   make(map[*stream]struct{}),
+	}
+	n.addConn(a, b, c)
+	n.addConn(b, a, c)
+	n.mx.Unlock()
+
+	ta.notifyConnected(b)
+	tb.notifyConnected(a)
+
+	return nil
+}
+
+// Disconnect closes the connections between two nodes, together with all their streams.
+func (n *Network) Disconnect(a, b peer.ID) {
+	n.mx.Lock()
+	conns, ok := n.conns[a][b]
+	if !ok {
+		n.mx.Unlock()
+		return
+	}
+	delete(n.conns[a], b)
+	delete(n.conns[b], a)
+
+	var streams []*stream
+	for _, c := range conns {
+		for s := range c.streams {
+			streams = append(streams, s)
+		}
+	}
+	n.mx.Unlock()
+
+	for _, s := range streams {
+		s.Reset()
+	}
+}
+
+// addConn assumes the network lock is held.
+func (n *Network) addConn(a, b peer.ID, c *conn) {
+	conns, ok := n.conns[a]
+	if !ok {
+		conns = make(map[peer.ID][]*conn)
+		n.conns[a] = conns
+	}
+	conns[b] = append(conns[b], c)
+}
+
+// findConn returns the connection from a to b with the given id, or the oldest one if the id is
+// empty; it assumes the network lock is held.
+func (n *Network) findConn(a, b peer.ID, id string) *conn {
+	for _, c := range n.conns[a][b] {
+		if id == "" || c.id == id {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// Transport is the transport of a node in an in-memory Network.
+type Transport struct {
+	net *Network
+	id  peer.ID
+	key crypto.PrivKey
+
+	mx       sync.Mutex
+	handlers []handler
+	notify   []func(peer.ID)
+}
+
+type handler struct {
+	proto protocol.ID
+	match func(protocol.ID) bool
+	fn    func(pubsub.TransportStream)
+}
+
+var _ pubsub.ConnTransport = (*Transport)(nil)
+
+func (t *Transport) ID() peer.ID {
+	return t.id
+}
+
+func (t *Transport) PrivKey(p peer.ID) crypto.PrivKey {
+	if p != t.id {
+		return nil
+	}
+
+	return t.key
+}
+
+func (t *Transport) SetStreamHandler(proto protocol.ID, match func(protocol.ID) bool, fn func(pubsub.TransportStream)) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	t.handlers = append(t.handlers, handler{proto: proto, match: match, fn: fn})
+}
+
+// NewStream opens a stream on the oldest connection to the peer.
+func (t *Transport) NewStream(ctx context.Context, p peer.ID, protos ...protocol.ID) (pubsub.TransportStream, error) {
+	return t.NewStreamOnConn(ctx, p, "", protos...)
+}
+
+func (t *Transport) NewStreamOnConn(ctx context.Context, p peer.ID, connID string, protos ...protocol.ID) (pubsub.TransportStream, error) {
+	t.net.mx.Lock()
+	c := t.net.findConn(t.id, p, connID)
+	remote := t.net.nodes[p]
+	t.net.mx.Unlock()
+
+	if c == nil {
+		return nil, fmt.Errorf("not connected to %s", p)
+	}
+
+	for _, proto := range protos {
+		fn := remote.handlerFor(proto)
+		if fn == nil {
+			continue
+		}
+
+		a, b := net.Pipe()
+		local := &stream{Conn: a, net: t.net, conn: c, remote: p, proto: proto}
+		inbound := &stream{Conn: b, net: t.net, conn: c, remote: t.id, proto: proto}
+
+		t.net.mx.Lock()
+		if t.net.findConn(t.id, p, c.id) != c {
+			// disconnected in the meantime
+			t.net.mx.Unlock()
+			return nil, fmt.Errorf("not connected to %s", p)
+		}
+		c.streams[local] = struct{}{}
+		c.streams[inbound] = struct{}{}
+		t.net.mx.Unlock()
+
+		go fn(inbound)
+		return local, nil
+	}
+
+	return nil, fmt.Errorf("peer %s doesn't support any of the protocols %v", p, protos)
+}
+
+func (t *Transport) Notify(connected func(peer.ID)) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	t.notify = append(t.notify, connected)
+}
+
+func (t *Transport) Peers() []peer.ID {
+	t.net.mx.Lock()
+	defer t.net.mx.Unlock()
+
+	peers := make([]peer.ID, 0, len(t.net.conns[t.id]))
+	for p := range t.net.conns[t.id] {
+		peers = append(peers, p)
+	}
+
+	return peers
+}
+
+func (t *Transport) Connected(p peer.ID) bool {
+	t.net.mx.Lock()
+	defer t.net.mx.Unlock()
+
+	_, ok := t.net.conns[t.id][p]
+	return ok
+}
+
+func (t *Transport) Conns(p peer.ID) []pubsub.ConnInfo {
+	t.net.mx.Lock()
+	defer t.net.mx.Unlock()
+
+	var conns []pubsub.ConnInfo
+	for _, c := range t.net.conns[t.id][p] {
+		conns = append(conns, c.info())
+	}
+
+	return conns
+}
+
+func (t *Transport) StreamConn(s pubsub.TransportStream) (pubsub.ConnInfo, bool) {
+	ms, ok := s.(*stream)
+	if !ok {
+		return pubsub.ConnInfo{}, false
+	}
+
+	return ms.conn.info(), true
+}
+
+func (t *Transport) Outbound(p peer.ID, proto protocol.ID) bool {
+	t.net.mx.Lock()
+	defer t.net.mx.Unlock()
+
+	for _, c := range t.net.conns[t.id][p] {
+		if c.dialer != t.id {
+			continue
+		}
+
+		for s := range c.streams {
+			if s.proto == proto {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (t *Transport) Connect(ctx context.Context, pi peer.AddrInfo) error {
+	return t.net.Connect(t.id, pi.ID)
+}
+
+func (t *Transport) handlerFor(proto protocol.ID) func(pubsub.TransportStream) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	for _, h := range t.handlers {
+		if h.match != nil && h.match(proto) || h.match == nil && h.proto == proto {
+			return h.fn
+		}
+	}
+
+	return nil
+}
+
+func (t *Transport) notifyConnected(p peer.ID) {
+	t.mx.Lock()
+	notify := make([]func(peer.ID), len(t.notify))
+	copy(notify, t.notify)
+	t.mx.Unlock()
+
+	for _, fn := range notify {
+		fn(p)
+	}
+}
+
+func (c *conn) info() pubsub.ConnInfo {
+	return pubsub.ConnInfo{ID: c.id, Relayed: c.relayed, Transient: c.transient}
+}
+
+// stream is one end of an in-memory stream.
+type stream struct {
+	net.Conn
+
+	net    *Network
+	conn   *conn
+	remote peer.ID
+	proto  protocol.ID
+}
+
+func (s *stream) Close() error {
+	s.net.mx.Lock()
+	delete(s.conn.streams, s)
+	s.net.mx.Unlock()
+
+	return s.Conn.Close()
+}
+
+func (s *stream) Reset() error {
+	return s.Close()
+}
+
+func (s *stream) RemotePeer() peer.ID {
+	return s.remote
+}
+
+func (s *stream) Protocol() protocol.ID {
+	return s.proto
+}
@@ -0,0 +1,511 @@
+package pubsubtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-msgio/protoio"
+)
+
+func getTransports(t testing.TB, n int) (*Network, []*Transport) {
+	net := NewNetwork()
+
+	var trs []*Transport
+	for i := 0; i < n; i++ {
+		tr, err := net.NewTransport()
+		if err != nil {
+			t.Fatal(err)
+		}
+		trs = append(trs, tr)
+	}
+
+	return net, trs
+}
+
+func sparseConnect(t *testing.T, net *Network, trs []*Transport) {
+	for i, a := range trs {
+		for j := 0; j < 3; j++ {
+			n := rand.Intn(len(trs))
+			if n == i {
+				j--
+				continue
+			}
+
+			if err := net.Connect(a.ID(), trs[n].ID()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// TestBasicFloodsub is the floodsub test of the same name, running on the in-memory transport.
+func TestBasicFloodsub(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	net, trs := getTransports(t, 20)
+
+	var psubs []*pubsub.PubSub
+	for _, tr := range trs {
+		ps, err := pubsub.NewFloodSubWithTransport(ctx, tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		psubs = append(psubs, ps)
+	}
+
+	var msgs []*pubsub.Subscription
+	for _, ps := range psubs {
+		subch, err := ps.Subscribe("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msgs = append(msgs, subch)
+	}
+
+	sparseConnect(t, net, trs)
+
+	time.Sleep(time.Millisecond * 100)
+
+	for i := 0; i < 100; i++ {
+		msg := []byte(fmt.Sprintf("%d the flooooooood %d", i, i))
+
+		owner := rand.Intn(len(psubs))
+
+		psubs[owner].Publish("foobar", msg)
+
+		for _, sub := range msgs {
+			got, err := sub.Next(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(msg, got.Data) {
+				t.Fatal("got wrong message!")
+			}
+		}
+	}
+}
+
+// TestSparseGossipsub is the gossipsub test of the same name, running on the in-memory transport.
+func TestSparseGossipsub(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	net, trs := getTransports(t, 20)
+
+	var psubs []*pubsub.PubSub
+	for _, tr := range trs {
+		ps, err := pubsub.NewGossipSubWithTransport(ctx, tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		psubs = append(psubs, ps)
+	}
+
+	var msgs []*pubsub.Subscription
+	for _, ps := range psubs {
+		subch, err := ps.Subscribe("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msgs = append(msgs, subch)
+	}
+
+	sparseConnect(t, net, trs)
+
+	// wait for heartbeats to build mesh
+	time.Sleep(time.Second * 2)
+
+	for i := 0; i < 100; i++ {
+		msg := []byte(fmt.Sprintf("%d it's not a floooooood %d", i, i))
+
+		owner := rand.Intn(len(psubs))
+
+		psubs[owner].Publish("foobar", msg)
+
+		for _, sub := range msgs {
+			got, err := sub.Next(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(msg, got.Data) {
+				t.Fatal("got wrong message!")
+			}
+		}
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	net, trs := getTransports(t, 2)
+
+	var psubs []*pubsub.PubSub
+	for _, tr := range trs {
+		ps, err := pubsub.NewFloodSubWithTransport(ctx, tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ps.Subscribe("foobar"); err != nil {
+			t.Fatal(err)
+		}
+		psubs = append(psubs, ps)
+	}
+
+	if err := net.Connect(trs[0].ID(), trs[1].ID()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if peers := psubs[0].ListPeers("foobar"); len(peers) != 1 || peers[0] != trs[1].ID() {
+		t.Fatalf("expected the peer in the topic, but got %v", peers)
+	}
+
+	net.Disconnect(trs[0].ID(), trs[1].ID())
+	time.Sleep(100 * time.Millisecond)
+
+	if peers := psubs[0].ListPeers("foobar"); len(peers) != 0 {
+		t.Fatalf("expected no peers in the topic after disconnecting, but got %v", peers)
+	}
+	if trs[0].Connected(trs[1].ID()) {
+		t.Fatal("expected the transports to be disconnected")
+	}
+}
+
+// connStreams returns the number of stream ends on the relayed and direct connections between two
+// nodes.
+func connStreams(net *Network, a, b *Transport) (relayed, direct int) {
+	net.mx.Lock()
+	defer net.mx.Unlock()
+
+	for _, c := range net.conns[a.ID()][b.ID()] {
+		if c.relayed {
+			relayed += len(c.streams)
+		} else {
+			direct += len(c.streams)
+		}
+	}
+	return relayed, direct
+}
+
+func TestStreamUpgrade(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			testStreamUpgrade(t, enabled)
+		})
+	}
+}
+
+func testStreamUpgrade(t *testing.T, enabled bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	net, trs := getTransports(t, 2)
+
+	// unsigned messages skip the validation pipeline, which doesn't preserve ordering
+	const count = 1000
+	opts := []pubsub.Option{
+		pubsub.WithPeerOutboundQueueSize(count),
+		pubsub.WithNoAuthor(),
+		pubsub.WithMessageIdFn(func(m *pb.Message) string { return string(m.Data) }),
+	}
+	if !enabled {
+		opts = append(opts, pubsub.WithConnPreference(nil))
+	}
+
+	var psubs []*pubsub.PubSub
+	var subs []*pubsub.Subscription
+	for _, tr := range trs {
+		ps, err := pubsub.NewFloodSubWithTransport(ctx, tr, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := ps.Subscribe("foobar", pubsub.WithBufferSize(count))
+		if err != nil {
+			t.Fatal(err)
+		}
+		psubs = append(psubs, ps)
+		subs = append(subs, sub)
+	}
+
+	a, b := trs[0], trs[1]
+	if err := net.ConnectRelayed(a.ID(), b.ID()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if relayed, direct := connStreams(net, a, b); relayed != 4 || direct != 0 {
+		t.Fatalf("expected both streams on the relayed connection, got %d and %d stream ends", relayed, direct)
+	}
+
+	// the direct connection appears while messages flow
+	errs := make(chan error, 1)
+	go func() {
+		for i := 0; i < count; i++ {
+			if i == count/4 {
+				if err := net.Connect(a.ID(), b.ID()); err != nil {
+					errs <- err
+					return
+				}
+			}
+			if err := psubs[0].Publish("foobar", []byte(fmt.Sprintf("msg%d", i))); err != nil {
+				errs <- err
+				return
+			}
+		}
+		errs <- nil
+	}()
+
+	for i := 0; i < count; i++ {
+		rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+		msg, err := subs[1].Next(rctx)
+		rcancel()
+		if err != nil {
+			t.Fatalf("received %d of %d messages: %s", i, count, err)
+		}
+		if expected := fmt.Sprintf("msg%d", i); string(msg.Data) != expected {
+			t.Fatalf("expected %s, got %s", expected, msg.Data)
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [2]int{0, 4}
+	if !enabled {
+		expected = [2]int{4, 0}
+	}
+	for i := 0; ; i++ {
+		relayed, direct := connStreams(net, a, b)
+		if relayed == expected[0] && direct == expected[1] {
+			break
+		}
+		if i == 50 {
+			t.Fatalf("expected %d and %d stream ends on the relayed and direct connections, got %d and %d",
+				expected[0], expected[1], relayed, direct)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the peers are still connected through the new streams
+	if err := psubs[1].Publish("foobar", []byte("back")); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+		msg, err := subs[0].Next(rctx)
+		rcancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(msg.Data) == "back" {
+			break
+		}
+	}
+}
+
+func meshPeers(ps *pubsub.PubSub, topic string) map[peer.ID]bool {
+	mesh := make(map[peer.ID]bool)
+	for _, pi := range ps.ListPeersDetailed(topic) {
+		if pi.Mesh {
+			mesh[pi.ID] = true
+		}
+	}
+	return mesh
+}
+
+func TestGossipsubTransientPeers(t *testing.T) {
+	for _, allow := range []bool{false, true} {
+		t.Run(fmt.Sprintf("allow=%t", allow), func(t *testing.T) {
+			testGossipsubTransientPeers(t, allow)
+		})
+	}
+}
+
+func testGossipsubTransientPeers(t *testing.T, allow bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	net, trs := getTransports(t, 3)
+
+	var opts []pubsub.Option
+	if allow {
+		opts = append(opts, pubsub.WithTransientMeshPeers())
+	}
+
+	var psubs []*pubsub.PubSub
+	for _, tr := range trs {
+		ps, err := pubsub.NewGossipSubWithTransport(ctx, tr, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ps.Subscribe("foobar"); err != nil {
+			t.Fatal(err)
+		}
+		psubs = append(psubs, ps)
+	}
+
+	// a is connected directly to b, and only over a transient connection to c
+	a, b, c := trs[0], trs[1], trs[2]
+	if err := net.Connect(a.ID(), b.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if err := net.ConnectTransient(a.ID(), c.ID()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Second)
+
+	mesh := meshPeers(psubs[0], "foobar")
+	if !mesh[b.ID()] {
+		t.Fatal("expected the directly connected peer in the mesh")
+	}
+	if mesh[c.ID()] != allow {
+		t.Fatalf("expected the transient peer in the mesh: %t, got %t", allow, mesh[c.ID()])
+	}
+	if mesh := meshPeers(psubs[2], "foobar"); mesh[a.ID()] != allow {
+		t.Fatalf("expected the transient peer to have us in its mesh: %t, got %t", allow, mesh[a.ID()])
+	}
+
+	// the direct connection appears; the peer becomes eligible for the mesh
+	if err := net.Connect(a.ID(), c.ID()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Second)
+
+	if mesh := meshPeers(psubs[0], "foobar"); !mesh[c.ID()] {
+		t.Fatal("expected the peer in the mesh after the direct connection appeared")
+	}
+}
+
+// BenchmarkInboundPath floods a node with the messages of 8 peers publishing concurrently, in a
+// topic with a hashing message ID function, and reports the throughput of the messages delivered
+// and the 99th percentile of their latency, from the first write of the RPC to the delivery. With
+// Dups, every peer writes every message, as the mesh peers of a node forward it the same messages.
+func BenchmarkInboundPath(b *testing.B) {
+	for _, dups := range []bool{false, true} {
+		for _, shards := range []int{1, 16} {
+			b.Run(fmt.Sprintf("Dups=%t/Shards=%d", dups, shards), func(b *testing.B) {
+				benchmarkInboundPath(b, dups, pubsub.WithSeenMessagesShards(shards))
+			})
+		}
+	}
+}
+
+func benchmarkInboundPath(b *testing.B, dups bool, opts ...pubsub.Option) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "foobar"
+	const senders = 8
+
+	net, trs := getTransports(b, senders+1)
+	opts = append(opts,
+		pubsub.WithMessageSignaturePolicy(pubsub.StrictNoSign),
+		pubsub.WithMessageIdFn(func(pmsg *pb.Message) string {
+			h := sha256.Sum256(pmsg.Data)
+			return string(h[:])
+		}),
+		pubsub.WithValidateQueueSize(4096),
+	)
+	ps, err := pubsub.NewFloodSubWithTransport(ctx, trs[0], opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	sub, err := ps.Subscribe(topic, pubsub.WithBufferSize(4096))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var writers []protoio.WriteCloser
+	for _, tr := range trs[1:] {
+		// the senders drain the stream of the node, and write the messages on their own stream
+		tr.SetStreamHandler(pubsub.FloodSubID, nil, func(s pubsub.TransportStream) {
+			io.Copy(io.Discard, s)
+		})
+		if err := net.Connect(trs[0].ID(), tr.ID()); err != nil {
+			b.Fatal(err)
+		}
+		s, err := tr.NewStream(ctx, trs[0].ID(), pubsub.FloodSubID)
+		if err != nil {
+			b.Fatal(err)
+		}
+		writers = append(writers, protoio.NewDelimitedWriter(s))
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// the payload carries the time of the first write, followed by the sender and a sequence number;
+	// the copies of a message carry the same payload
+	stamps := make([]int64, b.N)
+	latencies := make([]time.Duration, 0, b.N)
+	var last time.Time
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for len(latencies) < b.N {
+			rctx, rcancel := context.WithTimeout(ctx, time.Second)
+			msg, err := sub.Next(rctx)
+			rcancel()
+			if err != nil {
+				return
+			}
+			last = time.Now()
+			sent := time.Unix(0, int64(binary.BigEndian.Uint64(msg.Data)))
+			latencies = append(latencies, last.Sub(sent))
+		}
+	}()
+
+	b.ResetTimer()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i, w := range writers {
+		wg.Add(1)
+		go func(i int, w protoio.WriteCloser) {
+			defer wg.Done()
+			tp := topic
+			first, step := i, senders
+			if dups {
+				first, step = 0, 1
+			}
+			for n := first; n < b.N; n += step {
+				stamp := time.Now().UnixNano()
+				if !atomic.CompareAndSwapInt64(&stamps[n], 0, stamp) {
+					stamp = atomic.LoadInt64(&stamps[n])
+				}
+				data := make([]byte, 24)
+				if !dups {
+					binary.BigEndian.PutUint64(data[8:], uint64(i))
+				}
+				binary.BigEndian.PutUint64(data[16:], uint64(n))
+				binary.BigEndian.PutUint64(data, uint64(stamp))
+				rpc := &pb.RPC{Publish: []*pb.Message{{Topic: &tp, Data: data}}}
+				if err := w.WriteMsg(rpc); err != nil {
+					return
+				}
+			}
+		}(i, w)
+	}
+	wg.Wait()
+	<-done
+	b.StopTimer()
+
+	if len(latencies) == 0 {
+		b.Fatal("no message delivered")
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	p99 := latencies[len(latencies)*99/100]
+	b.ReportMetric(float64(len(latencies))/last.Sub(start).Seconds(), "msgs/s")
+	b.ReportMetric(float64(p99)/float64(time.Millisecond), "p99-ms")
+	b.ReportMetric(100*float64(len(latencies))/float64(b.N), "%delivered")
+}
@@ -0,0 +1,113 @@
+package pubsubtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-msgio/protoio"
+)
+
+// BenchmarkBadSignatureFlood measures the share of the messages of an honest peer delivered while
+// another peer floods us with messages carrying invalid signatures; as the signatures are verified
+// before the validation queue, the flood doesn't displace the honest messages.
+func BenchmarkBadSignatureFlood(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "flood"
+	const floodSize = 64
+
+	net, trs := getTransports(b, 3)
+	victim, err := pubsub.NewFloodSubWithTransport(ctx, trs[0], pubsub.WithValidateQueueSize(16), pubsub.WithValidateWorkers(1))
+	if err != nil {
+		b.Fatal(err)
+	}
+	honest, err := pubsub.NewFloodSubWithTransport(ctx, trs[1])
+	if err != nil {
+		b.Fatal(err)
+	}
+	attacker := trs[2]
+
+	sub, err := victim.Subscribe(topic)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := honest.Subscribe(topic); err != nil {
+		b.Fatal(err)
+	}
+
+	// the attacker drains the stream of the victim, and floods it on its own stream
+	attacker.SetStreamHandler(pubsub.FloodSubID, nil, func(s pubsub.TransportStream) {
+		io.Copy(io.Discard, s)
+	})
+	if err := net.Connect(trs[0].ID(), trs[1].ID()); err != nil {
+		b.Fatal(err)
+	}
+	if err := net.Connect(trs[0].ID(), attacker.ID()); err != nil {
+		b.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	s, err := attacker.NewStream(ctx, trs[0].ID(), pubsub.FloodSubID)
+	if err != nil {
+		b.Fatal(err)
+	}
+	w := protoio.NewDelimitedWriter(s)
+
+	var mx sync.Mutex
+	delivered := 0
+	go func() {
+		for {
+			msg, err := sub.Next(ctx)
+			if err != nil {
+				return
+			}
+			if msg.ReceivedFrom == trs[1].ID() {
+				mx.Lock()
+				delivered++
+				mx.Unlock()
+			}
+		}
+	}()
+
+	tp := topic
+	signature := make([]byte, 64)
+	var seqno uint64
+	flood := func() {
+		rpc := &pb.RPC{}
+		for i := 0; i < floodSize; i++ {
+			seqno++
+			rpc.Publish = append(rpc.Publish, &pb.Message{
+				From:      []byte(attacker.ID()),
+				Seqno:     []byte(fmt.Sprint(seqno)),
+				Topic:     &tp,
+				Data:      []byte("garbage"),
+				Signature: signature,
+			})
+		}
+		if err := w.WriteMsg(rpc); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		flood()
+		if err := honest.Publish(topic, []byte(fmt.Sprint(i))); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	time.Sleep(time.Second)
+	mx.Lock()
+	defer mx.Unlock()
+	b.ReportMetric(100*float64(delivered)/float64(b.N), "%delivered")
+}
@@ -2,7 +2,10 @@ package pubsub
 
 import (
 	"context"
+	"fmt"
 	"math"
+	"math/rand"
+	"sort"
 
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -15,17 +18,76 @@ const (
 
 var (
 	RandomSubD = 6
+
+	// RandomSubRecentDeliverers is the number of recent deliverers of messages we track in each
+	// topic, with WithRandomSubRecencyWeighting.
+	RandomSubRecentDeliverers = 16
+	// RandomSubRecentDelivererWeight is the weight of the recent deliverers in the selection of
+	// the peers we forward messages to, relative to the other peers.
+	RandomSubRecentDelivererWeight = 4.0
 )
 
 // NewRandomSub returns a new PubSub object using RandomSubRouter as the router.
 func NewRandomSub(ctx context.Context, h host.Host, size int, opts ...Option) (*PubSub, error) {
 	rt := &RandomSubRouter{
 		size:  size,
+		d:     RandomSubD,
 		peers: make(map[peer.ID]protocol.ID),
+		rng:   newRand(),
 	}
 	return NewPubSub(ctx, h, rt, opts...)
 }
 
+// WithRandomSubDegree is a randomsub router option that sets the minimum number of peers we
+// forward each message to, instead of RandomSubD.
+func WithRandomSubDegree(d int) Option {
+	return func(ps *PubSub) error {
+		rs, ok := ps.rt.(*RandomSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not randomsub")
+		}
+		if d <= 0 {
+			return fmt.Errorf("invalid randomsub degree; must be positive")
+		}
+
+		rs.d = d
+		return nil
+	}
+}
+
+// WithRandomSubSource is a randomsub router option that sets the source of randomness for the
+// selection of peers; a seeded source makes the selection reproducible, which is useful in tests.
+func WithRandomSubSource(src rand.Source) Option {
+	return func(ps *PubSub) error {
+		rs, ok := ps.rt.(*RandomSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not randomsub")
+		}
+		if src == nil {
+			return fmt.Errorf("nil random source")
+		}
+
+		rs.rng = rand.New(src)
+		return nil
+	}
+}
+
+// WithRandomSubRecencyWeighting is a randomsub router option that weights the selection of the
+// peers we forward messages to toward the peers that have recently delivered messages to us in
+// the topic: the last RandomSubRecentDeliverers of them are RandomSubRecentDelivererWeight times
+// as likely to be selected as the other peers.
+func WithRandomSubRecencyWeighting() Option {
+	return func(ps *PubSub) error {
+		rs, ok := ps.rt.(*RandomSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not randomsub")
+		}
+
+		rs.recent = make(map[string][]peer.ID)
+		return nil
+	}
+}
+
 // RandomSubRouter is a router that implements a random propagation strategy.
 // For each message, it selects the square root of the network size peers, with a min of RandomSubD,
 // and forwards the message to them.
@@ -33,7 +95,13 @@ type RandomSubRouter struct {
 	p      *PubSub
 	peers  map[peer.ID]protocol.ID
 	size   int
+	d      int
 	tracer *pubsubTracer
+	rng    *rand.Rand
+
+	// the recent deliverers of messages in each topic, most recent first; nil unless recency
+	// weighting is enabled
+	recent map[string][]peer.ID
 }
 
 func (rs *RandomSubRouter) Protocols() []protocol.ID {
@@ -53,6 +121,9 @@ func (rs *RandomSubRouter) AddPeer(p peer.ID, proto protocol.ID) {
 func (rs *RandomSubRouter) RemovePeer(p peer.ID) {
 	rs.tracer.RemovePeer(p)
 	delete(rs.peers, p)
+	for topic, recent := range rs.recent {
+		rs.recent[topic] = removePeer(recent, p)
+	}
 }
 
 func (rs *RandomSubRouter) EnoughPeers(topic string, suggested int) bool {
@@ -76,14 +147,14 @@ func (rs *RandomSubRouter) EnoughPeers(topic string, suggested int) bool {
 	}
 
 	if suggested == 0 {
-		suggested = RandomSubD
+		suggested = rs.d
 	}
 
 	if fsPeers+rsPeers >= suggested {
 		return true
 	}
 
-	if rsPeers >= RandomSubD {
+	if rsPeers >= rs.d {
 		return true
 	}
 
@@ -97,6 +168,14 @@ func (rs *RandomSubRouter) AcceptFrom(peer.ID) AcceptStatus {
 func (rs *RandomSubRouter) HandleRPC(rpc *RPC) {}
 
 func (rs *RandomSubRouter) Publish(msg *Message) {
+	rs.publish(msg, nil)
+}
+
+func (rs *RandomSubRouter) publish(msg *Message, res *PublishResult) {
+	rs.route(msg, res)
+}
+
+func (rs *RandomSubRouter) route(msg *Message, res *PublishResult) {
 	from := msg.ReceivedFrom
 
 	tosend := make(map[peer.ID]struct{})
@@ -109,6 +188,10 @@ func (rs *RandomSubRouter) Publish(msg *Message) {
 		return
 	}
 
+	if from != rs.p.tr.ID() {
+		rs.delivered(topic, from)
+	}
+
 	for p := range tmap {
 		if p == from || p == src {
 			continue
@@ -121,28 +204,15 @@ func (rs *RandomSubRouter) Publish(msg *Message) {
 		}
 	}
 
-	if len(rspeers) > RandomSubD {
-		target := RandomSubD
-		sqrt := int(math.Ceil(math.Sqrt(float64(rs.size))))
-		if sqrt > target {
-			target = sqrt
-		}
-		if target > len(rspeers) {
-			target = len(rspeers)
-		}
-		xpeers := peerMapToList(rspeers)
-		shufflePeers(xpeers)
-		xpeers = xpeers[:target]
-		for _, p := range xpeers {
-			tosend[p] = struct{}{}
-		}
-	} else {
-		for p := range rspeers {
-			tosend[p] = struct{}{}
-		}
+	for _, p := range rs.selectPeers(topic, rspeers) {
+		tosend[p] = struct{}{}
 	}
 
+	var recipients []peer.ID
+	observe := rs.p.fwdObserver.observing()
+
 	out := rpcWithMessages(msg.Message)
+	out.setExpiry(0, rs.p.forwardExpiry(msg))
 	for p := range tosend {
 		mch, ok := rs.p.peers[p]
 		if !ok {
@@ -152,11 +222,86 @@ func (rs *RandomSubRouter) Publish(msg *Message) {
 		select {
 		case mch <- out:
 			rs.tracer.SendRPC(out, p)
+			rs.p.enqueued(p, mch)
+			res.addRecipient(p, true)
+			if observe {
+				recipients = append(recipients, p)
+			}
 		default:
-			log.Infof("dropping message to peer %s: queue full", p)
+			rs.p.logger.Infow("dropping message to peer: queue full", "peer", p, "topic", msg.GetTopic())
 			rs.tracer.DropRPC(out, p)
+			res.addRecipient(p, false)
+		}
+	}
+	rs.p.fwdObserver.observe(msg, recipients)
+}
+
+// selectPeers selects the randomsub peers we forward a message in a topic to.
+func (rs *RandomSubRouter) selectPeers(topic string, rspeers map[peer.ID]struct{}) []peer.ID {
+	xpeers := peerMapToList(rspeers)
+	if len(xpeers) <= rs.d {
+		return xpeers
+	}
+
+	target := rs.d
+	sqrt := int(math.Ceil(math.Sqrt(float64(rs.size))))
+	if sqrt > target {
+		target = sqrt
+	}
+	if target > len(xpeers) {
+		target = len(xpeers)
+	}
+
+	// sort the peers first, so that the selection only depends on the random source
+	sort.Slice(xpeers, func(i, j int) bool { return xpeers[i] < xpeers[j] })
+
+	recent := rs.recent[topic]
+	if len(recent) == 0 {
+		shufflePeers(rs.rng, xpeers)
+		return xpeers[:target]
+	}
+
+	// weighted sampling without replacement: each peer draws a key u^(1/w), and the peers with
+	// the largest keys are selected
+	isRecent := make(map[peer.ID]bool, len(recent))
+	for _, p := range recent {
+		isRecent[p] = true
+	}
+	keys := make(map[peer.ID]float64, len(xpeers))
+	for _, p := range xpeers {
+		w := 1.0
+		if isRecent[p] {
+			w = RandomSubRecentDelivererWeight
+		}
+		keys[p] = math.Pow(rs.rng.Float64(), 1/w)
+	}
+	sort.SliceStable(xpeers, func(i, j int) bool { return keys[xpeers[i]] > keys[xpeers[j]] })
+	return xpeers[:target]
+}
+
+// delivered records a peer that has delivered a message in a topic, if recency weighting is
+// enabled.
+func (rs *RandomSubRouter) delivered(topic string, p peer.ID) {
+	if rs.recent == nil {
+		return
+	}
+
+	recent := removePeer(rs.recent[topic], p)
+	recent = append([]peer.ID{p}, recent...)
+	if len(recent) > RandomSubRecentDeliverers {
+		recent = recent[:RandomSubRecentDeliverers]
+	}
+	rs.recent[topic] = recent
+}
+
+// removePeer removes a peer from a list of peers, in place.
+func removePeer(peers []peer.ID, p peer.ID) []peer.ID {
+	for i, xp := range peers {
+		if xp == p {
+			return append(peers[:i], peers[i+1:]...)
 		}
 	}
+	return peers
 }
 
 func (rs *RandomSubRouter) Join(topic string) {
@@ -165,4 +310,5 @@ func (rs *RandomSubRouter) Join(topic string) {
 
 func (rs *RandomSubRouter) Leave(topic string) {
 	rs.tracer.Join(topic)
+	delete(rs.recent, topic)
 }
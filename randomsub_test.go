@@ -3,10 +3,14 @@ package pubsub
 import (
 	"context"
 	"fmt"
+	"math/rand"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
 )
 
 func getRandomsub(ctx context.Context, h host.Host, size int, opts ...Option) *PubSub {
@@ -190,3 +194,158 @@ func TestRandomsubEnoughPeers(t *testing.T) {
 		t.Fatal("expected enough peers")
 	}
 }
+
+func TestRandomsubDegree(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 10)
+	psubs := []*PubSub{getRandomsub(ctx, hosts[0], 1, WithRandomSubDegree(3))}
+	psubs = append(psubs, getRandomsubs(ctx, hosts[1:], 1)...)
+
+	var topics []*Topic
+	for _, ps := range psubs {
+		topic, err := ps.Join("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := topic.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+	for _, h := range hosts[1:] {
+		connect(t, hosts[0], h)
+	}
+	time.Sleep(time.Second)
+
+	var res PublishResult
+	if err := topics[0].Publish(ctx, []byte("hello"), WithPublishResult(&res, false)); err != nil {
+		t.Fatal(err)
+	}
+	if res.Recipients != 3 {
+		t.Fatalf("expected the message to be sent to 3 peers, got %d", res.Recipients)
+	}
+
+	if _, err := NewRandomSub(ctx, hosts[0], 1, WithRandomSubDegree(0)); err == nil {
+		t.Fatal("expected a zero degree to be invalid")
+	}
+	if _, err := NewRandomSub(ctx, hosts[0], 1, WithRandomSubSource(nil)); err == nil {
+		t.Fatal("expected a nil random source to be invalid")
+	}
+	if _, err := NewFloodSub(ctx, hosts[0], WithRandomSubDegree(3)); err == nil {
+		t.Fatal("expected the option to require randomsub")
+	}
+}
+
+func newTestRandomSubRouter(seed int64, opts ...Option) *RandomSubRouter {
+	rs := &RandomSubRouter{
+		size:  30,
+		d:     RandomSubD,
+		peers: make(map[peer.ID]protocol.ID),
+	}
+	ps := &PubSub{rt: rs}
+	for _, opt := range append([]Option{WithRandomSubSource(rand.NewSource(seed))}, opts...) {
+		if err := opt(ps); err != nil {
+			panic(err)
+		}
+	}
+	return rs
+}
+
+func testRandomSubPeers(n int) map[peer.ID]struct{} {
+	peers := make(map[peer.ID]struct{})
+	for i := 0; i < n; i++ {
+		peers[peer.ID(fmt.Sprintf("peer%02d", i))] = struct{}{}
+	}
+	return peers
+}
+
+func TestRandomsubSelectionReproducible(t *testing.T) {
+	peers := testRandomSubPeers(30)
+
+	for _, opts := range [][]Option{nil, {WithRandomSubRecencyWeighting()}} {
+		rs1 := newTestRandomSubRouter(42, opts...)
+		rs2 := newTestRandomSubRouter(42, opts...)
+		for _, rs := range []*RandomSubRouter{rs1, rs2} {
+			rs.delivered("test", "peer03")
+			rs.delivered("test", "peer17")
+		}
+
+		for i := 0; i < 10; i++ {
+			sel1 := rs1.selectPeers("test", peers)
+			sel2 := rs2.selectPeers("test", peers)
+			if len(sel1) != RandomSubD {
+				t.Fatalf("expected %d selected peers, got %d", RandomSubD, len(sel1))
+			}
+			if !reflect.DeepEqual(sel1, sel2) {
+				t.Fatalf("expected the same selection with the same seed, got %v and %v", sel1, sel2)
+			}
+		}
+	}
+}
+
+func TestRandomsubRecencyWeighting(t *testing.T) {
+	peers := testRandomSubPeers(30)
+	recent := []peer.ID{"peer03", "peer11", "peer17", "peer25"}
+
+	// the rate at which the recent deliverers and the other peers are selected
+	selectionRates := func(rs *RandomSubRouter) (float64, float64) {
+		for _, p := range recent {
+			rs.delivered("test", p)
+		}
+
+		const rounds = 1000
+		var recentCount, otherCount int
+		for i := 0; i < rounds; i++ {
+			for _, p := range rs.selectPeers("test", peers) {
+				if p == "peer03" || p == "peer11" || p == "peer17" || p == "peer25" {
+					recentCount++
+				} else {
+					otherCount++
+				}
+			}
+		}
+		return float64(recentCount) / float64(rounds*len(recent)),
+			float64(otherCount) / float64(rounds*(len(peers)-len(recent)))
+	}
+
+	recentRate, otherRate := selectionRates(newTestRandomSubRouter(42, WithRandomSubRecencyWeighting()))
+	if recentRate < 2*otherRate {
+		t.Fatalf("expected the recent deliverers to be preferred, got selection rates %f and %f", recentRate, otherRate)
+	}
+
+	// without the toggle the selection is uniform
+	recentRate, otherRate = selectionRates(newTestRandomSubRouter(42))
+	if recentRate > 1.5*otherRate || otherRate > 1.5*recentRate {
+		t.Fatalf("expected a uniform selection, got selection rates %f and %f", recentRate, otherRate)
+	}
+}
+
+func TestRandomsubRecentDeliverers(t *testing.T) {
+	rs := newTestRandomSubRouter(42, WithRandomSubRecencyWeighting())
+
+	for i := 0; i < RandomSubRecentDeliverers+5; i++ {
+		rs.delivered("test", peer.ID(fmt.Sprintf("peer%02d", i)))
+	}
+	rs.delivered("test", "peer10")
+
+	recent := rs.recent["test"]
+	if len(recent) != RandomSubRecentDeliverers {
+		t.Fatalf("expected %d recent deliverers, got %d", RandomSubRecentDeliverers, len(recent))
+	}
+	if recent[0] != "peer10" || recent[1] != peer.ID(fmt.Sprintf("peer%02d", RandomSubRecentDeliverers+4)) {
+		t.Fatalf("expected the most recent deliverers first, got %v", recent)
+	}
+
+	rs.RemovePeer("peer10")
+	for _, p := range rs.recent["test"] {
+		if p == "peer10" {
+			t.Fatal("expected the removed peer to be forgotten")
+		}
+	}
+	rs.Leave("test")
+	if _, ok := rs.recent["test"]; ok {
+		t.Fatal("expected the topic to be forgotten on leave")
+	}
+}
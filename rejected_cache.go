@@ -0,0 +1,91 @@
+package pubsub
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WithRejectedMessageCache enables a cache of the IDs of recently rejected messages, holding up to
+// size IDs for the given TTL. Copies of a message rejected by the validators are dropped without
+// running the validators again, and the peers propagating them are penalized for an invalid
+// message delivery.
+// Messages are already marked as seen when they enter validation, so the cache matters for copies
+// arriving after the seen messages TTL; it should be configured with a longer TTL than that.
+// The cache is disabled by default, as it assumes that the validators reject a message regardless
+// of the peer propagating it.
+func WithRejectedMessageCache(size int, ttl time.Duration) Option {
+	return func(ps *PubSub) error {
+		if size <= 0 {
+			return fmt.Errorf("rejected message cache size must be positive")
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("rejected message cache TTL must be positive")
+		}
+
+		ps.rejected = newRejectedCache(size, ttl)
+		return nil
+	}
+}
+
+// rejectedCache is a bounded cache of the IDs of rejected messages; when full, the oldest ID is
+// evicted. A nil cache is empty.
+type rejectedCache struct {
+	mx  sync.Mutex
+	ttl time.Duration
+
+	// expiry of the cached IDs
+	expiry map[string]time.Time
+	// ring of the cached IDs in insertion order
+	ring []string
+	head int
+	len  int
+}
+
+func newRejectedCache(size int, ttl time.Duration) *rejectedCache {
+	return &rejectedCache{
+		ttl:    ttl,
+		expiry: make(map[string]time.Time),
+		ring:   make([]string, size),
+	}
+}
+
+// add caches a rejected message ID.
+func (rc *rejectedCache) add(id string) {
+	if rc == nil {
+		return
+	}
+
+	rc.mx.Lock()
+	defer rc.mx.Unlock()
+
+	now := time.Now()
+	if _, ok := rc.expiry[id]; ok {
+		rc.expiry[id] = now.Add(rc.ttl)
+		return
+	}
+
+	if rc.len == len(rc.ring) {
+		delete(rc.expiry, rc.ring[rc.head])
+		rc.ring[rc.head] = ""
+		rc.head = (rc.head + 1) % len(rc.ring)
+		rc.len--
+	}
+
+	rc.ring[(rc.head+rc.len)%len(rc.ring)] = id
+	rc.len++
+	rc.expiry[id] = now.Add(rc.ttl)
+}
+
+// has returns true if a message ID has been rejected within the TTL.
+func (rc *rejectedCache) has(id string) bool {
+	if rc == nil {
+		return false
+	}
+
+	rc.mx.Lock()
+	defer rc.mx.Unlock()
+
+	expiry, ok := rc.expiry[id]
+	return ok && time.Now().Before(expiry)
+}
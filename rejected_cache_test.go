@@ -0,0 +1,191 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p-pubsub/timecache"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestRejectedCache(t *testing.T) {
+	rc := newRejectedCache(3, 100*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		rc.add(fmt.Sprintf("msg%d", i))
+	}
+	for i := 0; i < 3; i++ {
+		if !rc.has(fmt.Sprintf("msg%d", i)) {
+			t.Fatalf("expected msg%d to be cached", i)
+		}
+	}
+
+	// re-adding doesn't take another slot
+	rc.add("msg0")
+	if len(rc.expiry) != 3 {
+		t.Fatalf("expected 3 cached IDs, got %d", len(rc.expiry))
+	}
+
+	// the oldest ID is evicted when full
+	rc.add("msg3")
+	if rc.has("msg0") {
+		t.Fatal("expected msg0 to be evicted")
+	}
+	for i := 1; i < 4; i++ {
+		if !rc.has(fmt.Sprintf("msg%d", i)) {
+			t.Fatalf("expected msg%d to be cached", i)
+		}
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	for i := 1; i < 4; i++ {
+		if rc.has(fmt.Sprintf("msg%d", i)) {
+			t.Fatalf("expected msg%d to have expired", i)
+		}
+	}
+
+	// a nil cache is empty
+	var nilCache *rejectedCache
+	nilCache.add("msg")
+	if nilCache.has("msg") {
+		t.Fatal("expected nil cache to be empty")
+	}
+}
+
+type rejectReasonTracer struct {
+	mx      sync.Mutex
+	reasons map[string]int
+}
+
+func (t *rejectReasonTracer) count(reason string) int {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.reasons[reason]
+}
+
+func (t *rejectReasonTracer) total() int {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	total := 0
+	for _, n := range t.reasons {
+		total += n
+	}
+	return total
+}
+
+func (t *rejectReasonTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_REJECT_MESSAGE {
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.reasons[evt.GetRejectMessage().GetReason()]++
+}
+
+// Test that a rejected message replayed by many peers only runs the validators once, even when the
+// copies arrive after the message is no longer in the seen messages cache.
+func TestRejectedMessageCacheReplay(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled=%v", enabled), func(t *testing.T) {
+			testRejectedMessageCacheReplay(t, enabled)
+		})
+	}
+}
+
+func testRejectedMessageCacheReplay(t *testing.T, enabled bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 11)
+
+	tracer := &rejectReasonTracer{reasons: make(map[string]int)}
+	opts := []Option{
+		WithMessageSignaturePolicy(StrictNoSign),
+		WithNoAuthor(),
+		WithMessageIdFn(func(pmsg *pb.Message) string {
+			return string(pmsg.GetData())
+		}),
+		WithEventTracer(tracer),
+	}
+	if enabled {
+		opts = append(opts, WithRejectedMessageCache(100, time.Hour))
+	}
+	ps := getPubsub(ctx, hosts[0], opts...)
+
+	var validations int32
+	err := ps.RegisterTopicValidator("test", func(context.Context, peer.ID, *Message) bool {
+		atomic.AddInt32(&validations, 1)
+		return false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topic := "test"
+	for i, h := range hosts[1:] {
+		msg := &Message{
+			Message:      &pb.Message{Data: []byte("invalid"), Topic: &topic},
+			ReceivedFrom: h.ID(),
+		}
+
+		done := make(chan struct{})
+		ps.eval <- func() {
+			ps.pushMsg(msg)
+			close(done)
+		}
+		<-done
+
+		// wait for the rejection, then forget that we have seen the message
+		for tracer.total() != i+1 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		done = make(chan struct{})
+		ps.eval <- func() {
+			ps.seenMessages.Done()
+			ps.seenMessages = timecache.NewTimeCacheWithStrategy(ps.seenMsgStrategy, ps.seenMsgTTL)
+			close(done)
+		}
+		<-done
+	}
+
+	n := int(atomic.LoadInt32(&validations))
+	if enabled {
+		if n != 1 {
+			t.Fatalf("expected the validator to run once, but it ran %d times", n)
+		}
+		if c := tracer.count(RejectRecentlyRejected); c != 9 {
+			t.Fatalf("expected 9 copies rejected as recently rejected, got %d", c)
+		}
+	} else {
+		if n != 10 {
+			t.Fatalf("expected the validator to run for every copy, but it ran %d times", n)
+		}
+		if c := tracer.count(RejectRecentlyRejected); c != 0 {
+			t.Fatalf("expected no copies rejected as recently rejected, got %d", c)
+		}
+	}
+	if c := tracer.count(RejectValidationFailed); c != n {
+		t.Fatalf("expected %d validation failures, got %d", n, c)
+	}
+}
+
+func TestRejectedMessageCacheOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	if _, err := NewFloodSub(ctx, hosts[0], WithRejectedMessageCache(0, time.Minute)); err == nil {
+		t.Fatal("expected an error for a zero cache size")
+	}
+	if _, err := NewFloodSub(ctx, hosts[0], WithRejectedMessageCache(10, 0)); err == nil {
+		t.Fatal("expected an error for a zero cache TTL")
+	}
+}
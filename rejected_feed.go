@@ -0,0 +1,130 @@
+package pubsub
+
+import (
+	"crypto/sha256"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// RejectedMessagesBufferSize is the buffer of the channels returned by RejectedMessages; records
+// are dropped when a consumer falls behind.
+var RejectedMessagesBufferSize = 256
+
+// RejectReason is the reason a message was rejected; it is one of the Reject* constants.
+type RejectReason string
+
+// RejectedMessage is a record of a message received from a peer and rejected, as streamed by
+// RejectedMessages.
+type RejectedMessage struct {
+	// ID is the message id.
+	ID string
+	// Reason is the reason the message was rejected.
+	Reason RejectReason
+	// ReceivedFrom is the peer that propagated the message to us.
+	ReceivedFrom peer.ID
+	// From is the author of the message; it is empty for anonymous messages.
+	From peer.ID
+	// Topic is the topic of the message.
+	Topic string
+	// DataHash is the SHA-256 hash of the message data.
+	DataHash [sha256.Size]byte
+	// Time is when the message was rejected.
+	Time time.Time
+}
+
+// rejectFeed is a raw tracer streaming the rejected messages to the registered consumers.
+type rejectFeed struct {
+	idGen *msgIDGenerator
+
+	mx   sync.Mutex
+	subs map[chan RejectedMessage]struct{}
+
+	dropped uint64
+}
+
+func newRejectFeed(idGen *msgIDGenerator) *rejectFeed {
+	return &rejectFeed{
+		idGen: idGen,
+		subs:  make(map[chan RejectedMessage]struct{}),
+	}
+}
+
+// RejectedMessages returns a channel streaming a record of every message received from a peer and
+// rejected, for any reason: blacklisted peers or authors, missing or invalid signatures, failed
+// validation, throttling, quotas and so on. Every call registers an independent consumer, which
+// must call cancel when done, closing the channel. The channel is buffered with
+// RejectedMessagesBufferSize records; records for consumers that fall behind are dropped, and
+// counted in RejectedMessagesDropped.
+// The rejections of locally published messages are not streamed, as they are reported by Publish.
+func (p *PubSub) RejectedMessages() (<-chan RejectedMessage, func()) {
+	ch := make(chan RejectedMessage, RejectedMessagesBufferSize)
+
+	f := p.rejectFeed
+	f.mx.Lock()
+	f.subs[ch] = struct{}{}
+	f.mx.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			f.mx.Lock()
+			defer f.mx.Unlock()
+			delete(f.subs, ch)
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
+
+// RejectedMessagesDropped returns the number of records dropped because a consumer of
+// RejectedMessages fell behind.
+func (p *PubSub) RejectedMessagesDropped() uint64 {
+	return atomic.LoadUint64(&p.rejectFeed.dropped)
+}
+
+func (f *rejectFeed) RejectMessage(msg *Message, reason string) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	if len(f.subs) == 0 {
+		return
+	}
+
+	rec := RejectedMessage{
+		ID:           f.idGen.ID(msg),
+		Reason:       RejectReason(reason),
+		ReceivedFrom: msg.ReceivedFrom,
+		From:         msg.GetFrom(),
+		Topic:        msg.GetTopic(),
+		DataHash:     sha256.Sum256(msg.GetData()),
+		Time:         time.Now(),
+	}
+	for ch := range f.subs {
+		select {
+		case ch <- rec:
+		default:
+			atomic.AddUint64(&f.dropped, 1)
+		}
+	}
+}
+
+var _ RawTracer = (*rejectFeed)(nil)
+
+func (f *rejectFeed) AddPeer(p peer.ID, proto protocol.ID) {}
+func (f *rejectFeed) RemovePeer(p peer.ID)                 {}
+func (f *rejectFeed) Join(topic string)                    {}
+func (f *rejectFeed) Leave(topic string)                   {}
+func (f *rejectFeed) Graft(p peer.ID, topic string)        {}
+func (f *rejectFeed) Prune(p peer.ID, topic string)        {}
+func (f *rejectFeed) ValidateMessage(msg *Message)         {}
+func (f *rejectFeed) DeliverMessage(msg *Message)          {}
+func (f *rejectFeed) DuplicateMessage(msg *Message)        {}
+func (f *rejectFeed) ThrottlePeer(p peer.ID)               {}
+func (f *rejectFeed) RecvRPC(rpc *RPC)                     {}
+func (f *rejectFeed) SendRPC(rpc *RPC, p peer.ID)          {}
+func (f *rejectFeed) DropRPC(rpc *RPC, p peer.ID)          {}
+func (f *rejectFeed) UndeliverableMessage(msg *Message)    {}
@@ -0,0 +1,169 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestRejectedMessages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "foobar"
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0], WithPeerMessageQuota("quota", 1, 1))
+
+	err := ps.RegisterTopicValidator(topic, func(ctx context.Context, p peer.ID, msg *Message) ValidationResult {
+		switch string(msg.Data) {
+		case "reject":
+			return ValidationReject
+		case "ignore":
+			return ValidationIgnore
+		default:
+			return ValidationAccept
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	author, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seqno uint64
+	makeMsg := func(topic, data string, from peer.ID, signed bool) *Message {
+		seqno++
+		tp := topic
+		m := &pb.Message{From: []byte(author), Seqno: make([]byte, 8), Topic: &tp, Data: []byte(data)}
+		binary.BigEndian.PutUint64(m.Seqno, seqno)
+		if signed {
+			if err := signMessage(author, priv, m); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return &Message{Message: m, ReceivedFrom: from}
+	}
+
+	ch1, cancel1 := ps.RejectedMessages()
+	defer cancel1()
+	ch2, cancel2 := ps.RejectedMessages()
+
+	expect := func(ch <-chan RejectedMessage, msg *Message, reason string) {
+		t.Helper()
+		select {
+		case rec := <-ch:
+			if rec.Reason != RejectReason(reason) {
+				t.Fatalf("expected a rejection for %q, got %q", reason, rec.Reason)
+			}
+			if rec.ID != ps.idGen.ID(msg) || rec.ReceivedFrom != msg.ReceivedFrom || rec.From != author ||
+				rec.Topic != msg.GetTopic() || rec.DataHash != sha256.Sum256(msg.Data) || rec.Time.IsZero() {
+				t.Fatalf("unexpected record %+v", rec)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected a rejection for %q", reason)
+		}
+	}
+	push := func(msg *Message, reason string) {
+		t.Helper()
+		ps.eval <- func() {
+			ps.pushMsg(msg)
+		}
+		expect(ch1, msg, reason)
+		expect(ch2, msg, reason)
+	}
+
+	blacklist := func(p peer.ID) {
+		ps.eval <- func() {
+			ps.blacklist.Add(p)
+		}
+	}
+
+	blacklist("blacklisted")
+	push(makeMsg(topic, "hello", "blacklisted", true), RejectBlacklstedPeer)
+
+	push(makeMsg(topic, "hello", "peer", false), RejectMissingSignature)
+
+	tampered := makeMsg(topic, "hello", "peer", true)
+	tampered.Data = []byte("tampered")
+	push(tampered, RejectInvalidSignature)
+
+	push(makeMsg(topic, "reject", "peer", true), RejectValidationFailed)
+	push(makeMsg(topic, "ignore", "peer", true), RejectValidationIgnored)
+
+	// the quota is checked when receiving the RPC
+	for i := 0; i < 2; i++ {
+		msg := makeMsg("quota", "hello", "peer", true)
+		ps.eval <- func() {
+			ps.checkQuota(msg)
+		}
+		if i == 1 {
+			expect(ch1, msg, RejectQuotaExceeded)
+			expect(ch2, msg, RejectQuotaExceeded)
+		}
+	}
+
+	blacklist(author)
+	push(makeMsg(topic, "hello", "peer", true), RejectBlacklistedSource)
+
+	// cancelling a registration closes its channel, leaving the others
+	cancel2()
+	cancel2()
+	if _, ok := <-ch2; ok {
+		t.Fatal("expected the channel to be closed")
+	}
+	msg := makeMsg(topic, "hello", "blacklisted", true)
+	ps.eval <- func() {
+		ps.pushMsg(msg)
+	}
+	expect(ch1, msg, RejectBlacklstedPeer)
+
+	if n := ps.RejectedMessagesDropped(); n != 0 {
+		t.Fatalf("expected no dropped records, got %d", n)
+	}
+}
+
+func TestRejectedMessagesDropped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bufSize := RejectedMessagesBufferSize
+	RejectedMessagesBufferSize = 1
+	defer func() { RejectedMessagesBufferSize = bufSize }()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0])
+	ch, cancelFeed := ps.RejectedMessages()
+	defer cancelFeed()
+
+	// unsigned messages are rejected
+	topic := "foobar"
+	done := make(chan struct{})
+	ps.eval <- func() {
+		for i := 0; i < 3; i++ {
+			ps.pushMsg(&Message{Message: &pb.Message{Topic: &topic, Data: []byte("hello")}, ReceivedFrom: "peer"})
+		}
+		close(done)
+	}
+	<-done
+
+	if rec := <-ch; rec.Reason != RejectMissingSignature {
+		t.Fatalf("unexpected rejection %q", rec.Reason)
+	}
+	if n := ps.RejectedMessagesDropped(); n != 2 {
+		t.Fatalf("expected 2 dropped records, got %d", n)
+	}
+}
@@ -0,0 +1,147 @@
+package pubsub
+
+import (
+	"sync/atomic"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+// WithRPCCoalescing is an option that coalesces the RPCs queued for a peer: when the peer writer
+// finds several RPCs in the queue, it merges them into a single RPC, up to the max message size,
+// saving a frame and a write for each merged RPC. This is typically the case for the gossip, the
+// control messages and the messages sent to a peer in the same heartbeat.
+// RPCs are only merged when the peer processes the contents of the merged RPC -- subscriptions,
+// then messages, then control messages -- in the order it would have processed them in the
+// separate RPCs. See also RPCMerges.
+func WithRPCCoalescing() Option {
+	return func(ps *PubSub) error {
+		ps.coalesceRPCs = true
+		return nil
+	}
+}
+
+// RPCMerges returns the number of RPCs merged into another RPC with WithRPCCoalescing.
+func (p *PubSub) RPCMerges() uint64 {
+	return atomic.LoadUint64(&p.rpcMerges)
+}
+
+// prepareRPC prepares an RPC dequeued for a peer for writing, returning nil if it must be dropped.
+func (p *PubSub) prepareRPC(rpc *RPC, pid peer.ID) *RPC {
+	// the messages may have gone stale in the queue
+	rpc = p.dropExpired(rpc, pid)
+	if rpc == nil {
+		return nil
+	}
+
+	if p.faults != nil && p.faults.DropOutboundRPC(pid, rpc) {
+		p.logger.Debugw("fault injection: dropping rpc to peer", "peer", pid)
+		return nil
+	}
+
+	return rpc
+}
+
+// coalesceRPC merges the RPCs queued for a peer into a prepared RPC, for as long as they can be
+// merged. It returns the merged RPC, the first prepared RPC that couldn't be merged, if any, and
+// whether the queue has been closed.
+func (p *PubSub) coalesceRPC(rpc *RPC, outgoing <-chan *RPC, w *peerWriter) (*RPC, *RPC, bool) {
+	size := rpc.Size()
+	// whether rpc is a merged RPC that we own, rather than a queued RPC that may be shared with
+	// other peers
+	owned := false
+
+	for {
+		var next *RPC
+		select {
+		case queued, ok := <-outgoing:
+			if !ok {
+				return rpc, nil, true
+			}
+			w.queue.dequeue()
+			next = p.prepareRPC(queued, w.pid)
+			if next == nil {
+				continue
+			}
+		default:
+			return rpc, nil, false
+		}
+
+		// the size of the merged RPC is at most the sum of the sizes
+		nsize := next.Size()
+		if size+nsize > p.maxMessageSize || !canMergeRPC(rpc, next) {
+			return rpc, next, false
+		}
+
+		rpc = mergeRPC(rpc, next, owned)
+		owned = true
+		size += nsize
+		atomic.AddUint64(&p.rpcMerges, 1)
+	}
+}
+
+// canMergeRPC returns whether next can be merged into rpc, preserving the order in which a peer
+// processes their contents: the subscriptions first, then the messages, then the control messages.
+func canMergeRPC(rpc, next *RPC) bool {
+	hasControl := !isEmptyControl(rpc.Control)
+
+	// the subscriptions of next would be processed before the messages and the control of rpc
+	if len(next.Subscriptions) > 0 && (len(rpc.Publish) > 0 || hasControl) {
+		return false
+	}
+	// and the messages of next before the control of rpc
+	if len(next.Publish) > 0 && hasControl {
+		return false
+	}
+	// there is a single probe per RPC
+	if rpc.Control.GetProbe() != nil && next.Control.GetProbe() != nil {
+		return false
+	}
+	return true
+}
+
+// mergeRPC merges next into rpc; the slices of rpc are appended to in place if it is owned.
+func mergeRPC(rpc, next *RPC, owned bool) *RPC {
+	out := rpc
+	if !owned {
+		out = &RPC{}
+		out.Subscriptions = append([]*pb.RPC_SubOpts(nil), rpc.Subscriptions...)
+		out.Publish = append([]*pb.Message(nil), rpc.Publish...)
+		if rpc.Control != nil {
+			ctl := *rpc.Control
+			ctl.Ihave = append([]*pb.ControlIHave(nil), ctl.Ihave...)
+			ctl.Iwant = append([]*pb.ControlIWant(nil), ctl.Iwant...)
+			ctl.Graft = append([]*pb.ControlGraft(nil), ctl.Graft...)
+			ctl.Prune = append([]*pb.ControlPrune(nil), ctl.Prune...)
+			ctl.TopicSize = append([]*pb.ControlTopicSize(nil), ctl.TopicSize...)
+			out.Control = &ctl
+		}
+	}
+
+	out.Subscriptions = append(out.Subscriptions, next.Subscriptions...)
+	out.Publish = append(out.Publish, next.Publish...)
+
+	if isEmptyControl(next.Control) {
+		return out
+	}
+	if out.Control == nil {
+		out.Control = &pb.ControlMessage{}
+	}
+	ctl := out.Control
+	ctl.Ihave = append(ctl.Ihave, next.Control.Ihave...)
+	ctl.Iwant = append(ctl.Iwant, next.Control.Iwant...)
+	ctl.Graft = append(ctl.Graft, next.Control.Graft...)
+	ctl.Prune = append(ctl.Prune, next.Control.Prune...)
+	ctl.TopicSize = append(ctl.TopicSize, next.Control.TopicSize...)
+	if next.Control.Probe != nil {
+		ctl.Probe = next.Control.Probe
+	}
+	return out
+}
+
+// isEmptyControl returns whether a control message is empty.
+func isEmptyControl(ctl *pb.ControlMessage) bool {
+	return ctl == nil || (len(ctl.Ihave) == 0 && len(ctl.Iwant) == 0 && len(ctl.Graft) == 0 &&
+		len(ctl.Prune) == 0 && len(ctl.TopicSize) == 0 && ctl.Probe == nil)
+}
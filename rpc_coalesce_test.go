@@ -0,0 +1,216 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func subRPC(topic string) *RPC {
+	return rpcWithSubs(&pb.RPC_SubOpts{Topicid: proto.String(topic), Subscribe: proto.Bool(true)})
+}
+
+func msgRPC(data string) *RPC {
+	return rpcWithMessages(&pb.Message{Data: []byte(data), Topic: proto.String("foobar")})
+}
+
+func pruneRPC(topic string) *RPC {
+	return rpcWithControl(nil, nil, nil, nil, []*pb.ControlPrune{{TopicID: proto.String(topic)}})
+}
+
+func TestCanMergeRPC(t *testing.T) {
+	probe := &RPC{RPC: pb.RPC{Control: &pb.ControlMessage{Probe: &pb.ControlProbe{}}}}
+
+	cases := []struct {
+		name      string
+		rpc, next *RPC
+		merge     bool
+	}{
+		{"subscriptions then messages", subRPC("a"), msgRPC("a"), true},
+		{"subscriptions then control", subRPC("a"), pruneRPC("a"), true},
+		{"messages then messages", msgRPC("a"), msgRPC("b"), true},
+		{"messages then control", msgRPC("a"), pruneRPC("a"), true},
+		{"control then control", pruneRPC("a"), pruneRPC("b"), true},
+		{"messages then subscriptions", msgRPC("a"), subRPC("a"), false},
+		{"control then subscriptions", pruneRPC("a"), subRPC("a"), false},
+		{"control then messages", pruneRPC("a"), msgRPC("a"), false},
+		{"empty control then messages", rpcWithControl(nil, nil, nil, nil, nil), msgRPC("a"), true},
+		{"probe then probe", probe, probe, false},
+	}
+	for _, c := range cases {
+		if merge := canMergeRPC(c.rpc, c.next); merge != c.merge {
+			t.Fatalf("%s: expected %t, got %t", c.name, c.merge, merge)
+		}
+	}
+}
+
+func TestMergeRPC(t *testing.T) {
+	a := subRPC("a")
+	b := msgRPC("b")
+	c := pruneRPC("c")
+	d := pruneRPC("d")
+
+	out := mergeRPC(a, b, false)
+	out = mergeRPC(out, c, true)
+	out = mergeRPC(out, d, true)
+
+	if len(out.Subscriptions) != 1 || out.Subscriptions[0].GetTopicid() != "a" {
+		t.Fatalf("unexpected subscriptions %v", out.Subscriptions)
+	}
+	if len(out.Publish) != 1 || string(out.Publish[0].GetData()) != "b" {
+		t.Fatalf("unexpected messages %v", out.Publish)
+	}
+	prune := out.Control.GetPrune()
+	if len(prune) != 2 || prune[0].GetTopicID() != "c" || prune[1].GetTopicID() != "d" {
+		t.Fatalf("expected the PRUNEs in order, got %v", prune)
+	}
+
+	// the queued RPCs may be shared with other peers, so they are left as is
+	if len(a.Publish) != 0 || a.Control != nil || len(c.Control.GetPrune()) != 1 {
+		t.Fatal("expected the merged RPCs not to be modified")
+	}
+}
+
+func TestCoalesceRPC(t *testing.T) {
+	msg := msgRPC("hello")
+	p := &PubSub{maxMessageSize: 3*msg.Size() + 1, logger: log}
+	w := &peerWriter{pid: "peer"}
+
+	queue := func(rpcs ...*RPC) <-chan *RPC {
+		outgoing := make(chan *RPC, len(rpcs))
+		for _, rpc := range rpcs {
+			outgoing <- rpc
+		}
+		return outgoing
+	}
+
+	// the RPCs are merged up to the max message size
+	outgoing := queue(msgRPC("hello"), msgRPC("hello"), msgRPC("hello"), msgRPC("hello"))
+	rpc, next, closed := p.coalesceRPC(<-outgoing, outgoing, w)
+	if len(rpc.Publish) != 3 || next == nil || len(next.Publish) != 1 || closed {
+		t.Fatalf("expected 3 merged messages, got %d and %v", len(rpc.Publish), next)
+	}
+	if rpc.Size() > p.maxMessageSize {
+		t.Fatalf("expected the merged RPC to fit in %d bytes, got %d", p.maxMessageSize, rpc.Size())
+	}
+	if n := p.RPCMerges(); n != 2 {
+		t.Fatalf("expected 2 merges, got %d", n)
+	}
+
+	// and not across a control message followed by messages
+	outgoing = queue(msgRPC("a"), pruneRPC("foobar"), msgRPC("b"))
+	rpc, next, _ = p.coalesceRPC(<-outgoing, outgoing, w)
+	if len(rpc.Publish) != 1 || len(rpc.Control.GetPrune()) != 1 || next == nil || string(next.Publish[0].GetData()) != "b" {
+		t.Fatalf("expected the messages not to be merged after the control message, got %v and %v", rpc, next)
+	}
+
+	// the queue is drained until it is empty or closed
+	c := make(chan *RPC, 2)
+	c <- msgRPC("a")
+	c <- msgRPC("b")
+	close(c)
+	rpc, next, closed = p.coalesceRPC(<-c, c, w)
+	if len(rpc.Publish) != 2 || next != nil || !closed {
+		t.Fatalf("expected the queue to be drained, got %v, %v and %t", rpc, next, closed)
+	}
+}
+
+func TestRPCCoalescing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := getGossipsubs(ctx, hosts, WithRPCCoalescing())
+
+	var subs []*Subscription
+	for _, ps := range psubs {
+		sub, err := ps.Subscribe("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	for i := 0; i < 20; i++ {
+		if err := psubs[0].Publish("foobar", []byte(fmt.Sprintf("msg%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, sub := range subs {
+		for i := 0; i < 20; i++ {
+			expectMessage(t, ctx, sub, fmt.Sprintf("msg%d", i))
+		}
+	}
+}
+
+// writeCountingStream counts the writes to a stream, discarding the data.
+type writeCountingStream struct {
+	TransportStream
+	writes int
+}
+
+func (s *writeCountingStream) Write(b []byte) (int, error) {
+	s.writes++
+	return len(b), nil
+}
+
+func (s *writeCountingStream) Close() error {
+	return nil
+}
+
+// BenchmarkRPCCoalescing writes the RPCs a node with 50 peers typically queues for each peer in a
+// heartbeat -- the PRUNEs, the gossip, and then the messages published in the heartbeat -- and
+// reports the RPCs queued and the stream writes per heartbeat.
+func BenchmarkRPCCoalescing(b *testing.B) {
+	const peers = 50
+
+	heartbeat := func() []*RPC {
+		ihave := []*pb.ControlIHave{{TopicID: proto.String("foobar"), MessageIDs: []string{"a", "b", "c"}}}
+		return []*RPC{
+			pruneRPC("barfoo"),
+			rpcWithControl(nil, ihave, nil, nil, nil),
+			msgRPC("hello"),
+			msgRPC("world"),
+		}
+	}
+
+	for _, coalesce := range []bool{false, true} {
+		b.Run(fmt.Sprintf("Coalescing=%t", coalesce), func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			p := &PubSub{maxMessageSize: DefaultMaxMessageSize, logger: log, coalesceRPCs: coalesce}
+
+			rpcs, writes := 0, 0
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < peers; j++ {
+					queued := heartbeat()
+					outgoing := make(chan *RPC, len(queued))
+					for _, rpc := range queued {
+						outgoing <- rpc
+					}
+					close(outgoing)
+
+					// the writer returns once it has drained the closed queue
+					s := &writeCountingStream{}
+					p.handleSendingMessages(newPeerWriter(ctx, peer.ID(fmt.Sprintf("peer%d", j))), s, outgoing)
+					rpcs += len(queued)
+					writes += s.writes
+				}
+			}
+
+			b.ReportMetric(float64(rpcs)/float64(b.N), "rpcs/heartbeat")
+			b.ReportMetric(float64(writes)/float64(b.N), "writes/heartbeat")
+		})
+	}
+}
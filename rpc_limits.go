@@ -0,0 +1,200 @@
+package pubsub
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// RPCLimits are hard caps on the number of entries in an incoming RPC. They are checked on the
+// raw RPC before it is decoded, so that a peer can't make us allocate for, say, a million empty
+// IHAVEs; RPCs exceeding any cap are dropped and, with gossipsub, the sending peer is penalized
+// with a behaviour penalty. A cap of 0 means no limit.
+type RPCLimits struct {
+	// MaxSubscriptions is the maximum number of subscription changes in an RPC.
+	MaxSubscriptions int
+	// MaxMessages is the maximum number of published messages in an RPC.
+	MaxMessages int
+	// MaxIHave, MaxIWant, MaxGraft and MaxPrune are the maximum number of control entries of
+	// each type in an RPC.
+	MaxIHave int
+	MaxIWant int
+	MaxGraft int
+	MaxPrune int
+	// MaxMessageIDs is the maximum number of message IDs across all the IHAVEs and IWANTs of an RPC.
+	MaxMessageIDs int
+}
+
+// DefaultRPCLimits returns the default RPC limits, which are well beyond what well behaved peers
+// ever send.
+func DefaultRPCLimits() RPCLimits {
+	return RPCLimits{
+		MaxSubscriptions: 10000,
+		MaxMessages:      5000,
+		MaxIHave:         5000,
+		MaxIWant:         5000,
+		MaxGraft:         5000,
+		MaxPrune:         5000,
+		MaxMessageIDs:    100000,
+	}
+}
+
+// WithRPCLimits sets the limits on the number of entries in incoming RPCs.
+func WithRPCLimits(limits RPCLimits) Option {
+	return func(ps *PubSub) error {
+		for _, v := range []int{limits.MaxSubscriptions, limits.MaxMessages, limits.MaxIHave, limits.MaxIWant, limits.MaxGraft, limits.MaxPrune, limits.MaxMessageIDs} {
+			if v < 0 {
+				return fmt.Errorf("negative RPC limit")
+			}
+		}
+
+		ps.rpcLimits = limits
+		return nil
+	}
+}
+
+// rpcLimitError is the violation of an RPC limit.
+type rpcLimitError struct {
+	limit pb.TraceEvent_RejectRPC_Limit
+	max   int
+}
+
+func (e *rpcLimitError) Error() string {
+	return fmt.Sprintf("too many %s entries in RPC (limit %d)", e.limit, e.max)
+}
+
+// rpcCounts tracks the entries counted against the limits while scanning an RPC.
+type rpcCounts struct {
+	limits *RPCLimits
+	counts [pb.TraceEvent_RejectRPC_MESSAGE_IDS + 1]int
+}
+
+func (c *rpcCounts) add(limit pb.TraceEvent_RejectRPC_Limit) error {
+	var max int
+	switch limit {
+	case pb.TraceEvent_RejectRPC_SUBSCRIPTIONS:
+		max = c.limits.MaxSubscriptions
+	case pb.TraceEvent_RejectRPC_MESSAGES:
+		max = c.limits.MaxMessages
+	case pb.TraceEvent_RejectRPC_IHAVE:
+		max = c.limits.MaxIHave
+	case pb.TraceEvent_RejectRPC_IWANT:
+		max = c.limits.MaxIWant
+	case pb.TraceEvent_RejectRPC_GRAFT:
+		max = c.limits.MaxGraft
+	case pb.TraceEvent_RejectRPC_PRUNE:
+		max = c.limits.MaxPrune
+	case pb.TraceEvent_RejectRPC_MESSAGE_IDS:
+		max = c.limits.MaxMessageIDs
+	}
+
+	c.counts[limit]++
+	if max > 0 && c.counts[limit] > max {
+		return &rpcLimitError{limit: limit, max: max}
+	}
+	return nil
+}
+
+// checkRPCLimits counts the entries of an encoded RPC against the limits, without decoding it and
+// in constant memory; it stops at the first violation, so it never does more than a pass over buf.
+// Malformed RPCs are left for the decoder to reject.
+func checkRPCLimits(buf []byte, limits *RPCLimits) error {
+	c := &rpcCounts{limits: limits}
+	return scanFields(buf, func(field uint64, val []byte) error {
+		switch field {
+		case 1:
+			return c.add(pb.TraceEvent_RejectRPC_SUBSCRIPTIONS)
+		case 2:
+			return c.add(pb.TraceEvent_RejectRPC_MESSAGES)
+		case 3:
+			return scanFields(val, c.addControl)
+		}
+		return nil
+	})
+}
+
+func (c *rpcCounts) addControl(field uint64, val []byte) error {
+	switch field {
+	case 1:
+		if err := c.add(pb.TraceEvent_RejectRPC_IHAVE); err != nil {
+			return err
+		}
+		return c.addMessageIDs(val, 2)
+	case 2:
+		if err := c.add(pb.TraceEvent_RejectRPC_IWANT); err != nil {
+			return err
+		}
+		return c.addMessageIDs(val, 1)
+	case 3:
+		return c.add(pb.TraceEvent_RejectRPC_GRAFT)
+	case 4:
+		return c.add(pb.TraceEvent_RejectRPC_PRUNE)
+	}
+	return nil
+}
+
+// addMessageIDs counts the message IDs of an IHAVE or IWANT, which are in the given field.
+func (c *rpcCounts) addMessageIDs(buf []byte, idField uint64) error {
+	return scanFields(buf, func(field uint64, val []byte) error {
+		if field == idField {
+			return c.add(pb.TraceEvent_RejectRPC_MESSAGE_IDS)
+		}
+		return nil
+	})
+}
+
+// scanFields calls fn for every length-delimited field of a protobuf encoded buffer, skipping the
+// fields of other wire types; it stops silently at the first malformed field.
+func scanFields(buf []byte, fn func(field uint64, val []byte) error) error {
+	for i := 0; i < len(buf); {
+		key, k := binary.Uvarint(buf[i:])
+		if k <= 0 {
+			return nil
+		}
+		i += k
+
+		switch key & 7 {
+		case 0: // varint
+			_, n := binary.Uvarint(buf[i:])
+			if n <= 0 {
+				return nil
+			}
+			i += n
+		case 1: // fixed64
+			i += 8
+		case 5: // fixed32
+			i += 4
+		case 2: // length-delimited
+			length, n := binary.Uvarint(buf[i:])
+			if n <= 0 || length > uint64(len(buf)-i-n) {
+				return nil
+			}
+			i += n
+			end := i + int(length)
+			if err := fn(key>>3, buf[i:end]); err != nil {
+				return err
+			}
+			i = end
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// rejectRPC traces an RPC dropped for exceeding the limits and penalizes the sender.
+func (p *PubSub) rejectRPC(pid peer.ID, err *rpcLimitError) {
+	select {
+	case p.eval <- func() {
+		p.tracer.RejectRPC(pid, err.limit)
+		if gs, ok := p.rt.(*GossipSubRouter); ok {
+			gs.score.AddPenalty(pid, 1, PenaltyRPCLimits)
+		}
+	}:
+	case <-p.ctx.Done():
+	}
+}
@@ -0,0 +1,189 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+var testRPCLimits = RPCLimits{
+	MaxSubscriptions: 10,
+	MaxMessages:      10,
+	MaxIHave:         10,
+	MaxIWant:         10,
+	MaxGraft:         10,
+	MaxPrune:         10,
+	MaxMessageIDs:    50,
+}
+
+// makeLimitsTestRPC makes an RPC with n entries of the given type.
+func makeLimitsTestRPC(limit pb.TraceEvent_RejectRPC_Limit, n int) *pb.RPC {
+	rpc := new(pb.RPC)
+	ctl := new(pb.ControlMessage)
+	topic := "foobar"
+	for i := 0; i < n; i++ {
+		switch limit {
+		case pb.TraceEvent_RejectRPC_SUBSCRIPTIONS:
+			rpc.Subscriptions = append(rpc.Subscriptions, &pb.RPC_SubOpts{Topicid: &topic})
+		case pb.TraceEvent_RejectRPC_MESSAGES:
+			rpc.Publish = append(rpc.Publish, &pb.Message{Topic: &topic, Data: []byte("message")})
+		case pb.TraceEvent_RejectRPC_IHAVE:
+			ctl.Ihave = append(ctl.Ihave, &pb.ControlIHave{TopicID: &topic})
+		case pb.TraceEvent_RejectRPC_IWANT:
+			ctl.Iwant = append(ctl.Iwant, &pb.ControlIWant{})
+		case pb.TraceEvent_RejectRPC_GRAFT:
+			ctl.Graft = append(ctl.Graft, &pb.ControlGraft{TopicID: &topic})
+		case pb.TraceEvent_RejectRPC_PRUNE:
+			ctl.Prune = append(ctl.Prune, &pb.ControlPrune{TopicID: &topic})
+		case pb.TraceEvent_RejectRPC_MESSAGE_IDS:
+			// split across an IHAVE and an IWANT
+			if len(ctl.Ihave) == 0 {
+				ctl.Ihave = []*pb.ControlIHave{{TopicID: &topic}}
+				ctl.Iwant = []*pb.ControlIWant{{}}
+			}
+			mid := fmt.Sprintf("msg %d", i)
+			if i%2 == 0 {
+				ctl.Ihave[0].MessageIDs = append(ctl.Ihave[0].MessageIDs, mid)
+			} else {
+				ctl.Iwant[0].MessageIDs = append(ctl.Iwant[0].MessageIDs, mid)
+			}
+		}
+	}
+	if len(ctl.Ihave)+len(ctl.Iwant)+len(ctl.Graft)+len(ctl.Prune) > 0 {
+		rpc.Control = ctl
+	}
+
+	return rpc
+}
+
+func TestCheckRPCLimits(t *testing.T) {
+	limits := map[pb.TraceEvent_RejectRPC_Limit]int{
+		pb.TraceEvent_RejectRPC_SUBSCRIPTIONS: testRPCLimits.MaxSubscriptions,
+		pb.TraceEvent_RejectRPC_MESSAGES:      testRPCLimits.MaxMessages,
+		pb.TraceEvent_RejectRPC_IHAVE:         testRPCLimits.MaxIHave,
+		pb.TraceEvent_RejectRPC_IWANT:         testRPCLimits.MaxIWant,
+		pb.TraceEvent_RejectRPC_GRAFT:         testRPCLimits.MaxGraft,
+		pb.TraceEvent_RejectRPC_PRUNE:         testRPCLimits.MaxPrune,
+		pb.TraceEvent_RejectRPC_MESSAGE_IDS:   testRPCLimits.MaxMessageIDs,
+	}
+
+	for limit, max := range limits {
+		t.Run(limit.String(), func(t *testing.T) {
+			buf, err := makeLimitsTestRPC(limit, max).Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := checkRPCLimits(buf, &testRPCLimits); err != nil {
+				t.Fatalf("expected an RPC at the limit to pass, but got %s", err)
+			}
+
+			buf, err = makeLimitsTestRPC(limit, max+1).Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var lerr *rpcLimitError
+			if err := checkRPCLimits(buf, &testRPCLimits); !errors.As(err, &lerr) || lerr.limit != limit {
+				t.Fatalf("expected the %s limit to be violated, but got %v", limit, err)
+			}
+
+			// 0 means no limit
+			if err := checkRPCLimits(buf, &RPCLimits{}); err != nil {
+				t.Fatalf("expected no limits, but got %s", err)
+			}
+		})
+	}
+}
+
+func TestCheckRPCLimitsMemory(t *testing.T) {
+	// a million empty IHAVEs in 2MB
+	ctl := make([]byte, 0, 2<<20)
+	for i := 0; i < 1<<20; i++ {
+		ctl = append(ctl, 0x0a, 0x00)
+	}
+	huge, err := (&pb.RPC{}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	huge = append(huge, 0x1a)
+	huge = appendUvarint(huge, uint64(len(ctl)))
+	huge = append(huge, ctl...)
+
+	small, err := makeLimitsTestRPC(pb.TraceEvent_RejectRPC_IHAVE, 1).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hugeErr error
+	hugeAllocs := testing.AllocsPerRun(10, func() {
+		hugeErr = checkRPCLimits(huge, &testRPCLimits)
+	})
+	smallAllocs := testing.AllocsPerRun(10, func() {
+		checkRPCLimits(small, &testRPCLimits)
+	})
+
+	if hugeErr == nil {
+		t.Fatal("expected the IHAVE limit to be violated")
+	}
+	// the violation itself allocates the error
+	if hugeAllocs > smallAllocs+1 {
+		t.Fatalf("expected the check to run in constant memory, but got %f allocations vs %f", hugeAllocs, smallAllocs)
+	}
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+func FuzzCheckRPCLimits(f *testing.F) {
+	for limit := pb.TraceEvent_RejectRPC_SUBSCRIPTIONS; limit <= pb.TraceEvent_RejectRPC_MESSAGE_IDS; limit++ {
+		for _, n := range []int{1, 10, 11, 51} {
+			buf, err := makeLimitsTestRPC(limit, n).Marshal()
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(buf)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		err := checkRPCLimits(buf, &testRPCLimits)
+		if err != nil {
+			var lerr *rpcLimitError
+			if !errors.As(err, &lerr) {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+
+		// for well formed RPCs, the check agrees with the decoded RPC
+		var rpc pb.RPC
+		if rpc.Unmarshal(buf) != nil {
+			return
+		}
+
+		ctl := rpc.GetControl()
+		var mids int
+		for _, ihave := range ctl.GetIhave() {
+			mids += len(ihave.GetMessageIDs())
+		}
+		for _, iwant := range ctl.GetIwant() {
+			mids += len(iwant.GetMessageIDs())
+		}
+		exceeds := len(rpc.GetSubscriptions()) > testRPCLimits.MaxSubscriptions ||
+			len(rpc.GetPublish()) > testRPCLimits.MaxMessages ||
+			len(ctl.GetIhave()) > testRPCLimits.MaxIHave ||
+			len(ctl.GetIwant()) > testRPCLimits.MaxIWant ||
+			len(ctl.GetGraft()) > testRPCLimits.MaxGraft ||
+			len(ctl.GetPrune()) > testRPCLimits.MaxPrune ||
+			mids > testRPCLimits.MaxMessageIDs
+
+		if exceeds != (err != nil) {
+			t.Fatalf("the check (%v) disagrees with the decoded RPC (exceeds limits: %t)", err, exceeds)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package pubsub
+
+import (
+	"io"
+	"sync/atomic"
+
+	pool "github.com/libp2p/go-buffer-pool"
+	"github.com/libp2p/go-msgio"
+	"github.com/multiformats/go-varint"
+)
+
+// minReadBufferSize is the initial size of the read buffer of an inbound stream.
+const minReadBufferSize = 4096
+
+// maxIdleReadBufferSize is the size of the largest read buffer an inbound stream keeps while
+// waiting for the next RPC; larger buffers go back to the pool, so that idle streams don't hold
+// buffers sized for the largest RPC they have received.
+const maxIdleReadBufferSize = 64 * 1024
+
+// rpcReader reads length-prefixed RPCs from an inbound stream into a single buffer, reused across
+// RPCs. The buffer is grown geometrically up to the maximum message size, taken from the
+// size-bucketed buffer pool and returned to it when the reader is released, or before waiting for
+// the next RPC if it is larger than maxIdleReadBufferSize. The RPCs whose messages refer to the
+// buffer take it over with detach, and the next RPC is read into a new buffer; the buffer goes back
+// to the pool once the RPC has been handled.
+type rpcReader struct {
+	r    io.Reader
+	b    [1]byte
+	buf  []byte
+	max  int
+	used *int64
+}
+
+// newRPCReader creates a reader for RPCs of up to max bytes; used accumulates the size of the
+// read buffer.
+func newRPCReader(r io.Reader, max int, used *int64) *rpcReader {
+	return &rpcReader{r: r, max: max, used: used}
+}
+
+func (r *rpcReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(r.r, r.b[:]); err != nil {
+		return 0, err
+	}
+	return r.b[0], nil
+}
+
+// next reads the next RPC; the returned slice is only valid until the following call, unless the
+// buffer is detached.
+func (r *rpcReader) next() ([]byte, error) {
+	if cap(r.buf) > maxIdleReadBufferSize {
+		r.release()
+	}
+
+	length, err := varint.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(r.max) {
+		return nil, msgio.ErrMsgTooLarge
+	}
+
+	size := int(length)
+	r.grow(size)
+	if _, err := io.ReadFull(r.r, r.buf[:size]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return r.buf[:size], nil
+}
+
+func (r *rpcReader) grow(size int) {
+	if size <= cap(r.buf) {
+		return
+	}
+
+	newSize := 2 * cap(r.buf)
+	if newSize < minReadBufferSize {
+		newSize = minReadBufferSize
+	}
+	if newSize < size {
+		newSize = size
+	}
+	if newSize > r.max {
+		newSize = r.max
+	}
+
+	r.release()
+	r.buf = pool.Get(newSize)
+	atomic.AddInt64(r.used, int64(cap(r.buf)))
+}
+
+// detach hands the buffer of the last RPC over to the messages decoded from it and returns it. The
+// buffer is still accounted as in use until it is returned to the pool with putReadBuffer.
+func (r *rpcReader) detach() []byte {
+	buf := r.buf
+	r.buf = nil
+	return buf
+}
+
+// release returns the read buffer to the pool.
+func (r *rpcReader) release() {
+	putReadBuffer(r.buf, r.used)
+	r.buf = nil
+}
+
+// putReadBuffer returns a read buffer to the pool, and deducts its size from used.
+func putReadBuffer(buf []byte, used *int64) {
+	if buf == nil {
+		return
+	}
+
+	atomic.AddInt64(used, -int64(cap(buf)))
+	pool.Put(buf)
+}
+
+// releaseReadBuffer returns the read buffer of an RPC to the pool once the RPC has been handled; the
+// messages retained past that point have their payload copied out of it.
+func (p *PubSub) releaseReadBuffer(rpc *RPC) {
+	putReadBuffer(rpc.buf, &p.readBufferBytes)
+	rpc.buf = nil
+}
+
+// ReadBufferBytes returns the number of bytes currently held by the read buffers of the inbound
+// streams.
+func (p *PubSub) ReadBufferBytes() int64 {
+	return atomic.LoadInt64(&p.readBufferBytes)
+}
@@ -0,0 +1,140 @@
+package pubsub
+
+import (
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// DefaultMalformedRPCThreshold is the default number of malformed entries a peer may send before
+// it is penalized; see WithMalformedRPCThreshold.
+const DefaultMalformedRPCThreshold = 10
+
+// WithMalformedRPCThreshold sets the number of malformed entries a peer may send in its RPCs
+// before it is penalized. The malformed entries of incoming RPCs, such as subscriptions and
+// messages without a topic, or IHAVEs without message IDs, are always dropped, while the rest of
+// the RPC is processed; once a peer has sent more than threshold malformed entries, every further
+// RPC with malformed entries is traced with a MALFORMED_RPC event and, with gossipsub, penalized
+// with a behaviour penalty.
+func WithMalformedRPCThreshold(threshold int) Option {
+	return func(ps *PubSub) error {
+		if threshold < 0 {
+			return fmt.Errorf("invalid malformed RPC threshold; must be non-negative")
+		}
+
+		ps.malformedThreshold = threshold
+		return nil
+	}
+}
+
+// sanitizeRPC drops the malformed entries of an incoming RPC, returning their number. Entries are
+// malformed if they lack the fields they are keyed by: subscriptions, messages, GRAFTs and PRUNEs
+// without a topic, IHAVEs without a topic or message IDs, and IWANTs without message IDs.
+func sanitizeRPC(rpc *RPC) int {
+	malformed := 0
+
+	if subs := rpc.GetSubscriptions(); len(subs) > 0 {
+		n := 0
+		for _, sub := range subs {
+			if sub.GetTopicid() == "" {
+				malformed++
+				continue
+			}
+			subs[n] = sub
+			n++
+		}
+		rpc.Subscriptions = subs[:n]
+	}
+
+	if msgs := rpc.GetPublish(); len(msgs) > 0 {
+		n := 0
+		for _, msg := range msgs {
+			if msg.GetTopic() == "" {
+				malformed++
+				continue
+			}
+			msgs[n] = msg
+			n++
+		}
+		rpc.Publish = msgs[:n]
+	}
+
+	ctl := rpc.GetControl()
+	if ctl == nil {
+		return malformed
+	}
+
+	if ihaves := ctl.GetIhave(); len(ihaves) > 0 {
+		n := 0
+		for _, ihave := range ihaves {
+			if ihave.GetTopicID() == "" || len(ihave.GetMessageIDs()) == 0 {
+				malformed++
+				continue
+			}
+			ihaves[n] = ihave
+			n++
+		}
+		ctl.Ihave = ihaves[:n]
+	}
+
+	if iwants := ctl.GetIwant(); len(iwants) > 0 {
+		n := 0
+		for _, iwant := range iwants {
+			if len(iwant.GetMessageIDs()) == 0 {
+				malformed++
+				continue
+			}
+			iwants[n] = iwant
+			n++
+		}
+		ctl.Iwant = iwants[:n]
+	}
+
+	if grafts := ctl.GetGraft(); len(grafts) > 0 {
+		n := 0
+		for _, graft := range grafts {
+			if graft.GetTopicID() == "" {
+				malformed++
+				continue
+			}
+			grafts[n] = graft
+			n++
+		}
+		ctl.Graft = grafts[:n]
+	}
+
+	if prunes := ctl.GetPrune(); len(prunes) > 0 {
+		n := 0
+		for _, prune := range prunes {
+			if prune.GetTopicID() == "" {
+				malformed++
+				continue
+			}
+			prunes[n] = prune
+			n++
+		}
+		ctl.Prune = prunes[:n]
+	}
+
+	return malformed
+}
+
+// countMalformed accounts the malformed entries dropped from an RPC to its sender, penalizing it
+// beyond the threshold.
+// Only called from processLoop.
+func (p *PubSub) countMalformed(pid peer.ID, malformed int) {
+	if malformed == 0 {
+		return
+	}
+
+	p.malformed[pid] += malformed
+	if p.malformed[pid] <= p.malformedThreshold {
+		return
+	}
+
+	p.logger.Debugw("dropped malformed RPC entries", "peer", pid, "entries", malformed, "total", p.malformed[pid])
+	p.tracer.MalformedRPC(pid, malformed)
+	if gs, ok := p.rt.(*GossipSubRouter); ok {
+		gs.score.AddPenalty(pid, 1, PenaltyMalformedRPC)
+	}
+}
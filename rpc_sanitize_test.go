@@ -0,0 +1,260 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// malformedRPCCorpus is a corpus of RPCs with malformed entries, along with their number.
+func malformedRPCCorpus() []struct {
+	name      string
+	rpc       *RPC
+	malformed int
+} {
+	empty := ""
+	topic := "foobar"
+	subscribe := true
+
+	return []struct {
+		name      string
+		rpc       *RPC
+		malformed int
+	}{
+		{
+			name:      "subscription without topic",
+			rpc:       &RPC{RPC: pb.RPC{Subscriptions: []*pb.RPC_SubOpts{{Subscribe: &subscribe}, {Subscribe: &subscribe, Topicid: &empty}}}},
+			malformed: 2,
+		},
+		{
+			name:      "message without topic",
+			rpc:       rpcWithMessages(&pb.Message{Data: []byte("data")}, &pb.Message{Topic: &empty}),
+			malformed: 2,
+		},
+		{
+			name:      "message without data and seqno",
+			rpc:       rpcWithMessages(&pb.Message{Topic: &topic}),
+			malformed: 0,
+		},
+		{
+			name:      "IHAVE without topic",
+			rpc:       rpcWithControl(nil, []*pb.ControlIHave{{MessageIDs: []string{"a"}}, {TopicID: &empty, MessageIDs: []string{"b"}}}, nil, nil, nil),
+			malformed: 2,
+		},
+		{
+			name:      "IHAVE without message IDs",
+			rpc:       rpcWithControl(nil, []*pb.ControlIHave{{TopicID: &topic}}, nil, nil, nil),
+			malformed: 1,
+		},
+		{
+			name:      "IWANT without message IDs",
+			rpc:       rpcWithControl(nil, nil, []*pb.ControlIWant{{}}, nil, nil),
+			malformed: 1,
+		},
+		{
+			name:      "GRAFT and PRUNE without topic",
+			rpc:       rpcWithControl(nil, nil, nil, []*pb.ControlGraft{{}, {TopicID: &empty}}, []*pb.ControlPrune{{}}),
+			malformed: 3,
+		},
+		{
+			name: "well formed entries among malformed ones",
+			rpc: &RPC{RPC: pb.RPC{
+				Subscriptions: []*pb.RPC_SubOpts{{Subscribe: &subscribe, Topicid: &topic}, {Subscribe: &subscribe}},
+				Control: &pb.ControlMessage{
+					Ihave: []*pb.ControlIHave{{TopicID: &topic, MessageIDs: []string{"a"}}, {TopicID: &topic}},
+				},
+			}},
+			malformed: 2,
+		},
+	}
+}
+
+// checkWellFormed fails if any malformed entry is left in an RPC.
+func checkWellFormed(t *testing.T, rpc *RPC) {
+	t.Helper()
+
+	for _, sub := range rpc.GetSubscriptions() {
+		if sub.GetTopicid() == "" {
+			t.Fatal("subscription without topic")
+		}
+	}
+	for _, msg := range rpc.GetPublish() {
+		if msg.GetTopic() == "" {
+			t.Fatal("message without topic")
+		}
+	}
+	ctl := rpc.GetControl()
+	for _, ihave := range ctl.GetIhave() {
+		if ihave.GetTopicID() == "" || len(ihave.GetMessageIDs()) == 0 {
+			t.Fatal("malformed IHAVE")
+		}
+	}
+	for _, iwant := range ctl.GetIwant() {
+		if len(iwant.GetMessageIDs()) == 0 {
+			t.Fatal("malformed IWANT")
+		}
+	}
+	for _, graft := range ctl.GetGraft() {
+		if graft.GetTopicID() == "" {
+			t.Fatal("GRAFT without topic")
+		}
+	}
+	for _, prune := range ctl.GetPrune() {
+		if prune.GetTopicID() == "" {
+			t.Fatal("PRUNE without topic")
+		}
+	}
+}
+
+func TestSanitizeRPC(t *testing.T) {
+	for _, tc := range malformedRPCCorpus() {
+		t.Run(tc.name, func(t *testing.T) {
+			entries := len(tc.rpc.GetSubscriptions()) + len(tc.rpc.GetPublish())
+			ctl := tc.rpc.GetControl()
+			entries += len(ctl.GetIhave()) + len(ctl.GetIwant()) + len(ctl.GetGraft()) + len(ctl.GetPrune())
+
+			if n := sanitizeRPC(tc.rpc); n != tc.malformed {
+				t.Fatalf("expected %d malformed entries, got %d", tc.malformed, n)
+			}
+			checkWellFormed(t, tc.rpc)
+
+			// the rest of the RPC is kept
+			left := len(tc.rpc.GetSubscriptions()) + len(tc.rpc.GetPublish())
+			left += len(ctl.GetIhave()) + len(ctl.GetIwant()) + len(ctl.GetGraft()) + len(ctl.GetPrune())
+			if left != entries-tc.malformed {
+				t.Fatalf("expected %d entries left, got %d", entries-tc.malformed, left)
+			}
+		})
+	}
+}
+
+// recvRPCTracer records the RPCs that reach the router.
+type recvRPCTracer struct {
+	mx   sync.Mutex
+	rpcs []*RPC
+}
+
+func (t *recvRPCTracer) RecvRPC(rpc *RPC) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.rpcs = append(t.rpcs, rpc)
+}
+
+func (t *recvRPCTracer) AddPeer(p peer.ID, proto protocol.ID)      {}
+func (t *recvRPCTracer) RemovePeer(p peer.ID)                      {}
+func (t *recvRPCTracer) Join(topic string)                         {}
+func (t *recvRPCTracer) Leave(topic string)                        {}
+func (t *recvRPCTracer) Graft(p peer.ID, topic string)             {}
+func (t *recvRPCTracer) Prune(p peer.ID, topic string)             {}
+func (t *recvRPCTracer) ValidateMessage(msg *Message)              {}
+func (t *recvRPCTracer) DeliverMessage(msg *Message)               {}
+func (t *recvRPCTracer) RejectMessage(msg *Message, reason string) {}
+func (t *recvRPCTracer) DuplicateMessage(msg *Message)             {}
+func (t *recvRPCTracer) ThrottlePeer(p peer.ID)                    {}
+func (t *recvRPCTracer) SendRPC(rpc *RPC, p peer.ID)               {}
+func (t *recvRPCTracer) DropRPC(rpc *RPC, p peer.ID)               {}
+func (t *recvRPCTracer) UndeliverableMessage(msg *Message)         {}
+
+type malformedRPCTracer struct {
+	mx      sync.Mutex
+	entries []uint32
+}
+
+func (t *malformedRPCTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_MALFORMED_RPC {
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.entries = append(t.entries, evt.GetMalformedRPC().GetEntries())
+}
+
+// Test that the malformed entries of the RPCs of a peer don't reach the router, and that the peer
+// is penalized beyond the threshold.
+func TestMalformedRPCs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	legit := hosts[0]
+	attacker := hosts[1]
+
+	recv := &recvRPCTracer{}
+	tracer := &malformedRPCTracer{}
+	ps, err := NewGossipSub(ctx, legit,
+		WithRawTracer(recv),
+		WithEventTracer(tracer),
+		WithMalformedRPCThreshold(10),
+		WithPeerScore(
+			&PeerScoreParams{
+				AppSpecificScore:            func(peer.ID) float64 { return 0 },
+				BehaviourPenaltyWeight:      -1,
+				BehaviourPenaltyDecay:       ScoreParameterDecay(time.Minute),
+				DecayInterval:               DefaultDecayInterval,
+				DecayToZero:                 DefaultDecayToZero,
+				IPColocationFactorThreshold: 10,
+			},
+			&PeerScoreThresholds{
+				GossipThreshold:   -100,
+				PublishThreshold:  -500,
+				GraylistThreshold: -1000,
+			}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.Subscribe("foobar"); err != nil {
+		t.Fatal(err)
+	}
+
+	corpus := malformedRPCCorpus()
+	var once sync.Once
+	newMockGS(ctx, t, attacker, func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+		once.Do(func() {
+			for _, tc := range corpus {
+				writeMsg(&tc.rpc.RPC)
+			}
+		})
+	})
+
+	connect(t, legit, attacker)
+	time.Sleep(time.Second)
+
+	recv.mx.Lock()
+	for _, rpc := range recv.rpcs {
+		checkWellFormed(t, rpc)
+	}
+	recv.mx.Unlock()
+
+	gs := ps.rt.(*GossipSubRouter)
+	res := make(chan bool, 1)
+	score := make(chan float64, 1)
+	ps.eval <- func() {
+		_, emptyTopic := ps.topics[""]
+		_, emptyMesh := gs.mesh[""]
+		_, subscribed := ps.topics["foobar"][attacker.ID()]
+		res <- !emptyTopic && !emptyMesh && subscribed
+		score <- gs.score.Score(attacker.ID())
+	}
+	if !<-res {
+		t.Fatal("expected only the well formed entries to reach the topics and the router")
+	}
+
+	// the corpus has 13 malformed entries, so the peer goes beyond the threshold with the last 2
+	// RPCs with malformed entries
+	tracer.mx.Lock()
+	entries := tracer.entries
+	tracer.mx.Unlock()
+	if len(entries) != 2 || entries[0] != 3 || entries[1] != 2 {
+		t.Fatalf("expected the last 2 RPCs with malformed entries to be traced, got %v", entries)
+	}
+	if s := <-score; s >= 0 {
+		t.Fatalf("expected the peer to be penalized, got score %f", s)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sort"
 	"sync"
 	"time"
 
@@ -59,8 +60,23 @@ type topicStats struct {
 
 	// invalid message counter
 	invalidMessageDeliveries float64
+
+	// ignored message counter
+	ignoredMessageDeliveries float64
+
+	// ring buffer of the most recent duplicate delivery latencies, relative to the first
+	// time we saw the message; valid only when in mesh
+	duplicateLateness    []time.Duration
+	duplicateLatenessIdx int
 }
 
+// maximum number of duplicate lateness samples tracked per peer and topic
+const duplicateLatenessSamples = 32
+
+// smoothing factor of the moving average of the duplicate delay in a topic, which drives the
+// adaptive mesh message delivery window
+const duplicateDelayAlpha = 0.1
+
 type peerScore struct {
 	sync.Mutex
 
@@ -72,17 +88,41 @@ type peerScore struct {
 
 	// IP colocation tracking; maps IP => set of peers.
 	peerIPs map[string]map[peer.ID]struct{}
+	// networks and peers exempt from the IP colocation penalty; the whitelist starts with
+	// IPColocationFactorWhitelist and is updated at runtime
+	ipWhitelist []*net.IPNet
+	ipExempt    map[peer.ID]struct{}
 
 	// message delivery tracking
 	deliveries *messageDeliveries
 
-	idGen *msgIDGenerator
-	host  host.Host
+	idGen  *msgIDGenerator
+	host   host.Host
+	logger Logger
 
 	// debugging inspection
 	inspect       PeerScoreInspectFn
 	inspectEx     ExtendedPeerScoreInspectFn
 	inspectPeriod time.Duration
+
+	// score distribution, updated with the extended snapshots
+	dist *scoreDistribution
+
+	// the shadow scorer, fed the same events but never consulted; see WithShadowScoreParams.
+	shadow   *peerScore
+	isShadow bool
+
+	// score-affecting events of the peers, if recorded
+	events *scoreEventLog
+
+	// topics in small network mode, where the mesh message delivery penalty is not activated, and
+	// the time topics left the mode, which restarts the activation window.
+	smallTopics   map[string]bool
+	smallReleased map[string]time.Time
+
+	// moving average of the delay between the first delivery of a message and its duplicates,
+	// for the topics with an adaptive mesh message delivery window.
+	duplicateDelay map[string]time.Duration
 }
 
 var _ RawTracer = (*peerScore)(nil)
@@ -130,6 +170,8 @@ type PeerScoreSnapshot struct {
 	AppSpecificScore   float64
 	IPColocationFactor float64
 	BehaviourPenalty   float64
+	// Shadow is true in the snapshots of the shadow scorer; see WithShadowScoreParams.
+	Shadow bool
 }
 
 type TopicScoreSnapshot struct {
@@ -137,6 +179,10 @@ type TopicScoreSnapshot struct {
 	FirstMessageDeliveries   float64
 	MeshMessageDeliveries    float64
 	InvalidMessageDeliveries float64
+	IgnoredMessageDeliveries float64
+	MedianDuplicateLateness  time.Duration
+	// the effective mesh message delivery window of the topic
+	MeshMessageDeliveriesWindow time.Duration
 }
 
 // WithPeerScoreInspect is a gossipsub router option that enables peer score debugging.
@@ -179,6 +225,43 @@ func WithPeerScoreInspect(inspect interface{}, period time.Duration) Option {
 	}
 }
 
+// WithShadowScoreParams is a gossipsub router option that runs a shadow scorer with different
+// score parameters, to simulate a change of parameters on live traffic before adopting it.
+// The shadow scorer is fed the same events as the peer scorer, but its scores are never consulted
+// by the router; they are reported to the extended score inspector set with WithPeerScoreInspect,
+// in separate snapshots with Shadow set. The shadow scorer shares the message delivery records of
+// the peer scorer, so that it only adds the peer score counters.
+// The shadow parameters are fixed: SetScoreParams and the topic score resolver only change the
+// parameters of the peer scorer.
+// This option must be passed _after_ the WithPeerScore option.
+func WithShadowScoreParams(params *PeerScoreParams) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+
+		if gs.score == nil {
+			return fmt.Errorf("peer scoring is not enabled")
+		}
+
+		if gs.score.shadow != nil {
+			return fmt.Errorf("duplicate shadow score parameters")
+		}
+
+		if err := params.Validate(); err != nil {
+			return err
+		}
+
+		shadow := newPeerScore(params)
+		shadow.deliveries = nil
+		shadow.isShadow = true
+		gs.score.shadow = shadow
+
+		return nil
+	}
+}
+
 // implementation
 func newPeerScore(params *PeerScoreParams) *peerScore {
 	seenMsgTTL := params.SeenMsgTTL
@@ -191,6 +274,13 @@ func newPeerScore(params *PeerScoreParams) *peerScore {
 		peerIPs:    make(map[string]map[peer.ID]struct{}),
 		deliveries: &messageDeliveries{seenMsgTTL: seenMsgTTL, records: make(map[string]*deliveryRecord)},
 		idGen:      newMsgIdGenerator(),
+		logger:     log,
+
+		ipWhitelist: append([]*net.IPNet(nil), params.IPColocationFactorWhitelist...),
+
+		smallTopics:    make(map[string]bool),
+		smallReleased:  make(map[string]time.Time),
+		duplicateDelay: make(map[string]time.Duration),
 	}
 }
 
@@ -202,6 +292,10 @@ func (ps *peerScore) SetTopicScoreParams(topic string, p *TopicScoreParams) erro
 	ps.Lock()
 	defer ps.Unlock()
 
+	if ps.params.Topics == nil {
+		ps.params.Topics = make(map[string]*TopicScoreParams)
+	}
+
 	old, exist := ps.params.Topics[topic]
 	ps.params.Topics[topic] = p
 
@@ -240,6 +334,27 @@ func (ps *peerScore) SetTopicScoreParams(topic string, p *TopicScoreParams) erro
 	return nil
 }
 
+// hasTopicScoreParams returns whether a topic is scored.
+func (ps *peerScore) hasTopicScoreParams(topic string) bool {
+	ps.Lock()
+	defer ps.Unlock()
+
+	_, ok := ps.params.Topics[topic]
+	return ok
+}
+
+// removeTopicScoreParams stops scoring a topic, discarding the topic counters of all peers.
+func (ps *peerScore) removeTopicScoreParams(topic string) {
+	ps.Lock()
+	defer ps.Unlock()
+
+	delete(ps.params.Topics, topic)
+	delete(ps.duplicateDelay, topic)
+	for _, pstats := range ps.peerStats {
+		delete(pstats.topics, topic)
+	}
+}
+
 // router interface
 func (ps *peerScore) Start(gs *GossipSubRouter) {
 	if ps == nil {
@@ -248,7 +363,15 @@ func (ps *peerScore) Start(gs *GossipSubRouter) {
 
 	ps.idGen = gs.p.idGen
 	ps.host = gs.p.host
+	ps.logger = gs.p.logger
 	go ps.background(gs.p.ctx)
+
+	if ps.shadow != nil {
+		// the shadow snapshots are reported to the extended inspector, at the same period
+		ps.shadow.inspectEx = ps.inspectEx
+		ps.shadow.inspectPeriod = ps.inspectPeriod
+		ps.shadow.Start(gs)
+	}
 }
 
 func (ps *peerScore) Score(p peer.ID) float64 {
@@ -309,11 +432,24 @@ func (ps *peerScore) score(p peer.ID) float64 {
 		p3b := tstats.meshFailurePenalty
 		topicScore += p3b * topicParams.MeshFailurePenaltyWeight
 
+		// P3c: late duplicate deliveries
+		// NOTE: the weight of P3c is negative (validated in TopicScoreParams.validate), so this detracts.
+		if tstats.inMesh && topicParams.DuplicateLatenessWeight != 0 &&
+			tstats.firstMessageDeliveries <= topicParams.DuplicateLatenessFirstDeliveriesThreshold &&
+			tstats.medianDuplicateLateness() > topicParams.DuplicateLatenessThreshold {
+			topicScore += topicParams.DuplicateLatenessWeight
+		}
+
 		// P4: invalid messages
 		// NOTE: the weight of P4 is negative (validated in TopicScoreParams.validate), so this detracts.
 		p4 := (tstats.invalidMessageDeliveries * tstats.invalidMessageDeliveries)
 		topicScore += p4 * topicParams.InvalidMessageDeliveriesWeight
 
+		// P4b: ignored messages
+		// NOTE: the weight of P4b is negative (validated in TopicScoreParams.validate), so this detracts.
+		p4b := (tstats.ignoredMessageDeliveries * tstats.ignoredMessageDeliveries)
+		topicScore += p4b * topicParams.IgnoredMessageDeliveriesWeight
+
 		// update score, mixing with topic weight
 		score += topicScore * topicParams.TopicWeight
 	}
@@ -346,11 +482,14 @@ func (ps *peerScore) ipColocationFactor(p peer.ID) float64 {
 	if !ok {
 		return 0
 	}
+	if _, ok := ps.ipExempt[p]; ok {
+		return 0
+	}
 
 	var result float64
 loop:
 	for _, ip := range pstats.ips {
-		if len(ps.params.IPColocationFactorWhitelist) > 0 {
+		if len(ps.ipWhitelist) > 0 {
 			if pstats.ipWhitelist == nil {
 				pstats.ipWhitelist = make(map[string]bool)
 			}
@@ -358,7 +497,7 @@ loop:
 			whitelisted, ok := pstats.ipWhitelist[ip]
 			if !ok {
 				ipObj := net.ParseIP(ip)
-				for _, ipNet := range ps.params.IPColocationFactorWhitelist {
+				for _, ipNet := range ps.ipWhitelist {
 					if ipNet.Contains(ipObj) {
 						pstats.ipWhitelist[ip] = true
 						continue loop
@@ -388,11 +527,13 @@ loop:
 }
 
 // behavioural pattern penalties
-func (ps *peerScore) AddPenalty(p peer.ID, count int) {
+func (ps *peerScore) AddPenalty(p peer.ID, count int, reason string) {
 	if ps == nil {
 		return
 	}
 
+	ps.shadow.AddPenalty(p, count, reason)
+
 	ps.Lock()
 	defer ps.Unlock()
 
@@ -402,6 +543,37 @@ func (ps *peerScore) AddPenalty(p peer.ID, count int) {
 	}
 
 	pstats.behaviourPenalty += float64(count)
+	ps.events.record(p, ScoreEvent{Type: ScoreEventBehaviourPenalty, Penalty: float64(count), Reason: reason})
+}
+
+// SetSmallNetwork sets whether a topic is in small network mode, in which the mesh message
+// delivery penalty is deactivated; see GossipSubParams.AdaptToSmallNetworks.
+func (ps *peerScore) SetSmallNetwork(topic string, small bool) {
+	if ps == nil {
+		return
+	}
+
+	ps.shadow.SetSmallNetwork(topic, small)
+
+	ps.Lock()
+	defer ps.Unlock()
+
+	if !small {
+		if ps.smallTopics[topic] {
+			delete(ps.smallTopics, topic)
+			ps.smallReleased[topic] = time.Now()
+		}
+		return
+	}
+
+	ps.smallTopics[topic] = true
+	delete(ps.smallReleased, topic)
+
+	for _, pstats := range ps.peerStats {
+		if tstats, ok := pstats.topics[topic]; ok {
+			tstats.meshMessageDeliveriesActive = false
+		}
+	}
 }
 
 // periodic maintenance
@@ -416,7 +588,7 @@ func (ps *peerScore) background(ctx context.Context) {
 	defer gcDeliveryRecords.Stop()
 
 	var inspectScores <-chan time.Time
-	if ps.inspect != nil || ps.inspectEx != nil {
+	if ps.inspect != nil || ps.inspectEx != nil || ps.dist != nil {
 		ticker := time.NewTicker(ps.inspectPeriod)
 		defer ticker.Stop()
 		// also dump at exit for one final sample
@@ -449,7 +621,7 @@ func (ps *peerScore) inspectScores() {
 	if ps.inspect != nil {
 		ps.inspectScoresSimple()
 	}
-	if ps.inspectEx != nil {
+	if ps.inspectEx != nil || ps.dist != nil {
 		ps.inspectScoresExtended()
 	}
 }
@@ -482,9 +654,14 @@ func (ps *peerScore) inspectScoresExtended() {
 					FirstMessageDeliveries:   ts.firstMessageDeliveries,
 					MeshMessageDeliveries:    ts.meshMessageDeliveries,
 					InvalidMessageDeliveries: ts.invalidMessageDeliveries,
+					IgnoredMessageDeliveries: ts.ignoredMessageDeliveries,
+				}
+				if tparams, ok := ps.params.Topics[t]; ok {
+					tss.MeshMessageDeliveriesWindow = ps.meshMessageDeliveriesWindow(t, tparams)
 				}
 				if ts.inMesh {
 					tss.TimeInMesh = ts.meshTime
+					tss.MedianDuplicateLateness = ts.medianDuplicateLateness()
 				}
 				pss.Topics[t] = tss
 			}
@@ -492,11 +669,17 @@ func (ps *peerScore) inspectScoresExtended() {
 		pss.AppSpecificScore = ps.params.AppSpecificScore(p)
 		pss.IPColocationFactor = ps.ipColocationFactor(p)
 		pss.BehaviourPenalty = pstats.behaviourPenalty
+		pss.Shadow = ps.isShadow
 		scores[p] = pss
 	}
 	ps.Unlock()
 
-	go ps.inspectEx(scores)
+	if ps.dist != nil {
+		ps.dist.update(scores, time.Now())
+	}
+	if ps.inspectEx != nil {
+		go ps.inspectEx(scores)
+	}
 }
 
 // refreshScores decays scores, and purges score records for disconnected peers,
@@ -513,6 +696,7 @@ func (ps *peerScore) refreshScores() {
 				// yes, throw it away (but clean up the IP tracking first)
 				ps.removeIPs(p, pstats.ips)
 				delete(ps.peerStats, p)
+				ps.events.forget(p)
 			}
 
 			// we don't decay retained scores, as the peer is not active.
@@ -547,10 +731,19 @@ func (ps *peerScore) refreshScores() {
 			if tstats.invalidMessageDeliveries < ps.params.DecayToZero {
 				tstats.invalidMessageDeliveries = 0
 			}
-			// update mesh time and activate mesh message delivery parameter if need be
+			tstats.ignoredMessageDeliveries *= topicParams.IgnoredMessageDeliveriesDecay
+			if tstats.ignoredMessageDeliveries < ps.params.DecayToZero {
+				tstats.ignoredMessageDeliveries = 0
+			}
+			// update mesh time and activate mesh message delivery parameter if need be; the
+			// activation is deferred in small network mode, and counts from the time we left it.
 			if tstats.inMesh {
 				tstats.meshTime = now.Sub(tstats.graftTime)
-				if tstats.meshTime > topicParams.MeshMessageDeliveriesActivation {
+				activeTime := tstats.meshTime
+				if released, ok := ps.smallReleased[topic]; ok && now.Sub(released) < activeTime {
+					activeTime = now.Sub(released)
+				}
+				if !ps.smallTopics[topic] && activeTime > topicParams.MeshMessageDeliveriesActivation {
 					tstats.meshMessageDeliveriesActive = true
 				}
 			}
@@ -585,6 +778,11 @@ func (ps *peerScore) refreshIPs() {
 }
 
 func (ps *peerScore) gcDeliveryRecords() {
+	if ps.isShadow {
+		// the delivery records are owned by the live scorer
+		return
+	}
+
 	ps.Lock()
 	defer ps.Unlock()
 
@@ -593,6 +791,10 @@ func (ps *peerScore) gcDeliveryRecords() {
 
 // tracer interface
 func (ps *peerScore) AddPeer(p peer.ID, proto protocol.ID) {
+	if ps.shadow != nil {
+		ps.shadow.AddPeer(p, proto)
+	}
+
 	ps.Lock()
 	defer ps.Unlock()
 
@@ -603,12 +805,17 @@ func (ps *peerScore) AddPeer(p peer.ID, proto protocol.ID) {
 	}
 
 	pstats.connected = true
+	ps.events.track(p)
 	ips := ps.getIPs(p)
 	ps.setIPs(p, ips, pstats.ips)
 	pstats.ips = ips
 }
 
 func (ps *peerScore) RemovePeer(p peer.ID) {
+	if ps.shadow != nil {
+		ps.shadow.RemovePeer(p)
+	}
+
 	ps.Lock()
 	defer ps.Unlock()
 
@@ -622,6 +829,7 @@ func (ps *peerScore) RemovePeer(p peer.ID) {
 	if ps.score(p) > 0 {
 		ps.removeIPs(p, pstats.ips)
 		delete(ps.peerStats, p)
+		ps.events.forget(p)
 		return
 	}
 
@@ -630,12 +838,19 @@ func (ps *peerScore) RemovePeer(p peer.ID) {
 	for topic, tstats := range pstats.topics {
 		tstats.firstMessageDeliveries = 0
 
+		if !tstats.inMesh {
+			continue
+		}
+
+		var penalty float64
 		threshold := ps.params.Topics[topic].MeshMessageDeliveriesThreshold
-		if tstats.inMesh && tstats.meshMessageDeliveriesActive && tstats.meshMessageDeliveries < threshold {
+		if tstats.meshMessageDeliveriesActive && tstats.meshMessageDeliveries < threshold {
 			deficit := threshold - tstats.meshMessageDeliveries
-			tstats.meshFailurePenalty += deficit * deficit
+			penalty = deficit * deficit
+			tstats.meshFailurePenalty += penalty
 		}
 
+		ps.events.record(p, ScoreEvent{Type: ScoreEventMeshLeave, Topic: topic, Penalty: penalty})
 		tstats.inMesh = false
 	}
 
@@ -647,6 +862,10 @@ func (ps *peerScore) Join(topic string)  {}
 func (ps *peerScore) Leave(topic string) {}
 
 func (ps *peerScore) Graft(p peer.ID, topic string) {
+	if ps.shadow != nil {
+		ps.shadow.Graft(p, topic)
+	}
+
 	ps.Lock()
 	defer ps.Unlock()
 
@@ -660,13 +879,20 @@ func (ps *peerScore) Graft(p peer.ID, topic string) {
 		return
 	}
 
+	ps.events.record(p, ScoreEvent{Type: ScoreEventMeshJoin, Topic: topic})
 	tstats.inMesh = true
 	tstats.graftTime = time.Now()
 	tstats.meshTime = 0
 	tstats.meshMessageDeliveriesActive = false
+	tstats.duplicateLateness = nil
+	tstats.duplicateLatenessIdx = 0
 }
 
 func (ps *peerScore) Prune(p peer.ID, topic string) {
+	if ps.shadow != nil {
+		ps.shadow.Prune(p, topic)
+	}
+
 	ps.Lock()
 	defer ps.Unlock()
 
@@ -681,13 +907,16 @@ func (ps *peerScore) Prune(p peer.ID, topic string) {
 	}
 
 	// sticky mesh delivery rate failure penalty
+	var penalty float64
 	threshold := ps.params.Topics[topic].MeshMessageDeliveriesThreshold
 	if tstats.meshMessageDeliveriesActive && tstats.meshMessageDeliveries < threshold {
 		deficit := threshold - tstats.meshMessageDeliveries
-		tstats.meshFailurePenalty += deficit * deficit
+		penalty = deficit * deficit
+		tstats.meshFailurePenalty += penalty
 	}
 
 	tstats.inMesh = false
+	ps.events.record(p, ScoreEvent{Type: ScoreEventMeshLeave, Topic: topic, Penalty: penalty})
 }
 
 func (ps *peerScore) ValidateMessage(msg *Message) {
@@ -700,8 +929,8 @@ func (ps *peerScore) ValidateMessage(msg *Message) {
 }
 
 func (ps *peerScore) DeliverMessage(msg *Message) {
-	ps.Lock()
-	defer ps.Unlock()
+	ps.lockDeliveries()
+	defer ps.unlockDeliveries()
 
 	ps.markFirstMessageDelivery(msg.ReceivedFrom, msg)
 
@@ -709,7 +938,7 @@ func (ps *peerScore) DeliverMessage(msg *Message) {
 
 	// defensive check that this is the first delivery trace -- delivery status should be unknown
 	if drec.status != deliveryUnknown {
-		log.Debugf("unexpected delivery trace: message from %s was first seen %s ago and has delivery status %d", msg.ReceivedFrom, time.Since(drec.firstSeen), drec.status)
+		ps.logger.Debugw("unexpected delivery trace", "peer", msg.ReceivedFrom, "topic", msg.GetTopic(), "firstSeen", time.Since(drec.firstSeen), "status", drec.status)
 		return
 	}
 
@@ -726,8 +955,8 @@ func (ps *peerScore) DeliverMessage(msg *Message) {
 }
 
 func (ps *peerScore) RejectMessage(msg *Message, reason string) {
-	ps.Lock()
-	defer ps.Unlock()
+	ps.lockDeliveries()
+	defer ps.unlockDeliveries()
 
 	switch reason {
 	// we don't track those messages, but we penalize the peer as they are clearly invalid
@@ -740,6 +969,10 @@ func (ps *peerScore) RejectMessage(msg *Message, reason string) {
 	case RejectUnexpectedAuthInfo:
 		fallthrough
 	case RejectSelfOrigin:
+		fallthrough
+	case RejectRecentlyRejected:
+		fallthrough
+	case RejectReplayedSeqno:
 		ps.markInvalidMessageDelivery(msg.ReceivedFrom, msg)
 		return
 
@@ -747,6 +980,12 @@ func (ps *peerScore) RejectMessage(msg *Message, reason string) {
 	case RejectBlacklstedPeer:
 		fallthrough
 	case RejectBlacklistedSource:
+		fallthrough
+	case RejectQuotaExceeded:
+		fallthrough
+	case RejectMemoryBudget:
+		fallthrough
+	case RejectTopicLeft:
 		return
 
 	case RejectValidationQueueFull:
@@ -760,7 +999,7 @@ func (ps *peerScore) RejectMessage(msg *Message, reason string) {
 
 	// defensive check that this is the first rejection trace -- delivery status should be unknown
 	if drec.status != deliveryUnknown {
-		log.Debugf("unexpected rejection trace: message from %s was first seen %s ago and has delivery status %d", msg.ReceivedFrom, time.Since(drec.firstSeen), drec.status)
+		ps.logger.Debugw("unexpected rejection trace", "peer", msg.ReceivedFrom, "topic", msg.GetTopic(), "firstSeen", time.Since(drec.firstSeen), "status", drec.status)
 		return
 	}
 
@@ -772,10 +1011,15 @@ func (ps *peerScore) RejectMessage(msg *Message, reason string) {
 		// release the delivery time tracking map to free some memory early
 		drec.peers = nil
 		return
-	case RejectValidationIgnored:
+	case RejectValidationIgnored, RejectTransformFailed:
 		// we were explicitly instructed by the validator to ignore the message but not penalize
-		// the peer
+		// the peer as invalid, or we failed to transform the valid message and it is not
+		// forwarded; the ignored message counter has no weight unless the topic opts in.
 		drec.status = deliveryIgnored
+		ps.markIgnoredMessageDelivery(msg.ReceivedFrom, msg)
+		for p := range drec.peers {
+			ps.markIgnoredMessageDelivery(p, msg)
+		}
 		drec.peers = nil
 		return
 	}
@@ -793,8 +1037,8 @@ func (ps *peerScore) RejectMessage(msg *Message, reason string) {
 }
 
 func (ps *peerScore) DuplicateMessage(msg *Message) {
-	ps.Lock()
-	defer ps.Unlock()
+	ps.lockDeliveries()
+	defer ps.unlockDeliveries()
 
 	drec := ps.deliveries.getRecord(ps.idGen.ID(msg))
 
@@ -809,11 +1053,15 @@ func (ps *peerScore) DuplicateMessage(msg *Message) {
 		// the message is being validated; track the peer delivery and wait for
 		// the Deliver/Reject notification.
 		drec.peers[msg.ReceivedFrom] = struct{}{}
+		ps.markDuplicateLateness(msg.ReceivedFrom, msg, time.Since(drec.firstSeen))
+		ps.observeDuplicateDelay(msg.GetTopic(), time.Since(drec.firstSeen))
 
 	case deliveryValid:
 		// mark the peer delivery time to only count a duplicate delivery once.
 		drec.peers[msg.ReceivedFrom] = struct{}{}
 		ps.markDuplicateMessageDelivery(msg.ReceivedFrom, msg, drec.validated)
+		ps.markDuplicateLateness(msg.ReceivedFrom, msg, time.Since(drec.firstSeen))
+		ps.observeDuplicateDelay(msg.GetTopic(), time.Since(drec.firstSeen))
 
 	case deliveryInvalid:
 		// we no longer track delivery time
@@ -822,7 +1070,8 @@ func (ps *peerScore) DuplicateMessage(msg *Message) {
 	case deliveryThrottled:
 		// the message was throttled; do nothing (we don't know if it was valid)
 	case deliveryIgnored:
-		// the message was ignored; do nothing
+		// the message was ignored; we no longer track delivery time
+		ps.markIgnoredMessageDelivery(msg.ReceivedFrom, msg)
 	}
 }
 
@@ -836,6 +1085,22 @@ func (ps *peerScore) DropRPC(rpc *RPC, p peer.ID) {}
 
 func (ps *peerScore) UndeliverableMessage(msg *Message) {}
 
+// lockDeliveries locks the scorer and its shadow, for the delivery events: the shadow shares the
+// delivery records, and is marked through the mark methods along with the scorer.
+func (ps *peerScore) lockDeliveries() {
+	ps.Lock()
+	if ps.shadow != nil {
+		ps.shadow.Lock()
+	}
+}
+
+func (ps *peerScore) unlockDeliveries() {
+	if ps.shadow != nil {
+		ps.shadow.Unlock()
+	}
+	ps.Unlock()
+}
+
 // message delivery records
 func (d *messageDeliveries) getRecord(id string) *deliveryRecord {
 	rec, ok := d.records[id]
@@ -899,6 +1164,10 @@ func (pstats *peerStats) getTopicStats(topic string, params *PeerScoreParams) (*
 // markInvalidMessageDelivery increments the "invalid message deliveries"
 // counter for all scored topics the message is published in.
 func (ps *peerScore) markInvalidMessageDelivery(p peer.ID, msg *Message) {
+	if ps.shadow != nil {
+		ps.shadow.markInvalidMessageDelivery(p, msg)
+	}
+
 	pstats, ok := ps.peerStats[p]
 	if !ok {
 		return
@@ -911,12 +1180,39 @@ func (ps *peerScore) markInvalidMessageDelivery(p peer.ID, msg *Message) {
 	}
 
 	tstats.invalidMessageDeliveries += 1
+	ps.events.record(p, ScoreEvent{Type: ScoreEventInvalidDelivery, Topic: topic})
+}
+
+// markIgnoredMessageDelivery increments the "ignored message deliveries"
+// counter for all scored topics the message is published in.
+func (ps *peerScore) markIgnoredMessageDelivery(p peer.ID, msg *Message) {
+	if ps.shadow != nil {
+		ps.shadow.markIgnoredMessageDelivery(p, msg)
+	}
+
+	pstats, ok := ps.peerStats[p]
+	if !ok {
+		return
+	}
+
+	topic := msg.GetTopic()
+	tstats, ok := pstats.getTopicStats(topic, ps.params)
+	if !ok {
+		return
+	}
+
+	tstats.ignoredMessageDeliveries += 1
+	ps.events.record(p, ScoreEvent{Type: ScoreEventIgnoredDelivery, Topic: topic})
 }
 
 // markFirstMessageDelivery increments the "first message deliveries" counter
 // for all scored topics the message is published in, as well as the "mesh
 // message deliveries" counter, if the peer is in the mesh for the topic.
 func (ps *peerScore) markFirstMessageDelivery(p peer.ID, msg *Message) {
+	if ps.shadow != nil {
+		ps.shadow.markFirstMessageDelivery(p, msg)
+	}
+
 	pstats, ok := ps.peerStats[p]
 	if !ok {
 		return
@@ -928,6 +1224,7 @@ func (ps *peerScore) markFirstMessageDelivery(p peer.ID, msg *Message) {
 		return
 	}
 
+	ps.events.record(p, ScoreEvent{Type: ScoreEventFirstDelivery, Topic: topic})
 	cap := ps.params.Topics[topic].FirstMessageDeliveriesCap
 	tstats.firstMessageDeliveries += 1
 	if tstats.firstMessageDeliveries > cap {
@@ -949,6 +1246,10 @@ func (ps *peerScore) markFirstMessageDelivery(p peer.ID, msg *Message) {
 // for messages we've seen before, as long the message was received within the
 // P3 window.
 func (ps *peerScore) markDuplicateMessageDelivery(p peer.ID, msg *Message, validated time.Time) {
+	if ps.shadow != nil {
+		ps.shadow.markDuplicateMessageDelivery(p, msg, validated)
+	}
+
 	pstats, ok := ps.peerStats[p]
 	if !ok {
 		return
@@ -969,10 +1270,11 @@ func (ps *peerScore) markDuplicateMessageDelivery(p peer.ID, msg *Message, valid
 	// check against the mesh delivery window -- if the validated time is passed as 0, then
 	// the message was received before we finished validation and thus falls within the mesh
 	// delivery window.
-	if !validated.IsZero() && time.Since(validated) > tparams.MeshMessageDeliveriesWindow {
+	if !validated.IsZero() && time.Since(validated) > ps.meshMessageDeliveriesWindow(topic, tparams) {
 		return
 	}
 
+	ps.events.record(p, ScoreEvent{Type: ScoreEventDuplicateDelivery, Topic: topic})
 	cap := tparams.MeshMessageDeliveriesCap
 	tstats.meshMessageDeliveries += 1
 	if tstats.meshMessageDeliveries > cap {
@@ -980,6 +1282,90 @@ func (ps *peerScore) markDuplicateMessageDelivery(p peer.ID, msg *Message, valid
 	}
 }
 
+// observeDuplicateDelay updates the moving average of the duplicate delay in topics with an
+// adaptive mesh message delivery window.
+func (ps *peerScore) observeDuplicateDelay(topic string, delay time.Duration) {
+	if ps.shadow != nil {
+		ps.shadow.observeDuplicateDelay(topic, delay)
+	}
+
+	tparams, ok := ps.params.Topics[topic]
+	if !ok || tparams.MeshMessageDeliveriesWindowFactor == 0 {
+		return
+	}
+
+	avg, ok := ps.duplicateDelay[topic]
+	if !ok {
+		ps.duplicateDelay[topic] = delay
+		return
+	}
+	ps.duplicateDelay[topic] = avg + time.Duration(duplicateDelayAlpha*float64(delay-avg))
+}
+
+// meshMessageDeliveriesWindow returns the effective mesh message delivery window of a topic.
+func (ps *peerScore) meshMessageDeliveriesWindow(topic string, tparams *TopicScoreParams) time.Duration {
+	if tparams.MeshMessageDeliveriesWindowFactor == 0 {
+		return tparams.MeshMessageDeliveriesWindow
+	}
+
+	avg, ok := ps.duplicateDelay[topic]
+	if !ok {
+		// no duplicates yet
+		return tparams.MeshMessageDeliveriesWindow
+	}
+
+	window := time.Duration(tparams.MeshMessageDeliveriesWindowFactor * float64(avg))
+	if window < tparams.MeshMessageDeliveriesWindowMin {
+		window = tparams.MeshMessageDeliveriesWindowMin
+	}
+	if window > tparams.MeshMessageDeliveriesWindowMax {
+		window = tparams.MeshMessageDeliveriesWindowMax
+	}
+	return window
+}
+
+func (ps *peerScore) markDuplicateLateness(p peer.ID, msg *Message, lateness time.Duration) {
+	if ps.shadow != nil {
+		ps.shadow.markDuplicateLateness(p, msg, lateness)
+	}
+
+	pstats, ok := ps.peerStats[p]
+	if !ok {
+		return
+	}
+
+	tstats, ok := pstats.getTopicStats(msg.GetTopic(), ps.params)
+	if !ok {
+		return
+	}
+
+	if !tstats.inMesh {
+		return
+	}
+
+	if len(tstats.duplicateLateness) < duplicateLatenessSamples {
+		tstats.duplicateLateness = append(tstats.duplicateLateness, lateness)
+		return
+	}
+
+	tstats.duplicateLateness[tstats.duplicateLatenessIdx] = lateness
+	tstats.duplicateLatenessIdx = (tstats.duplicateLatenessIdx + 1) % duplicateLatenessSamples
+}
+
+// medianDuplicateLateness returns the median of the tracked duplicate delivery latencies,
+// or 0 if there are none.
+func (ts *topicStats) medianDuplicateLateness() time.Duration {
+	if len(ts.duplicateLateness) == 0 {
+		return 0
+	}
+
+	samples := make([]time.Duration, len(ts.duplicateLateness))
+	copy(samples, ts.duplicateLateness)
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	return samples[len(samples)/2]
+}
+
 // getIPs gets the current IPs for a peer.
 func (ps *peerScore) getIPs(p peer.ID) []string {
 	// in unit tests this can be nil
@@ -1042,6 +1428,7 @@ addNewIPs:
 			ps.peerIPs[ip] = peers
 		}
 		peers[p] = struct{}{}
+		ps.recordColocation(ip)
 	}
 
 removeOldIPs:
@@ -1062,6 +1449,8 @@ removeOldIPs:
 		if len(peers) == 0 {
 			delete(ps.peerIPs, ip)
 		}
+		ps.events.record(p, ScoreEvent{Type: ScoreEventIPColocation, IP: ip})
+		ps.recordColocation(ip)
 	}
 }
 
@@ -1077,5 +1466,6 @@ func (ps *peerScore) removeIPs(p peer.ID, ips []string) {
 		if len(peers) == 0 {
 			delete(ps.peerIPs, ip)
 		}
+		ps.recordColocation(ip)
 	}
 }
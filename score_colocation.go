@@ -0,0 +1,156 @@
+package pubsub
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// AddIPColocationWhitelist exempts the IPs in a network from the IP colocation penalty, like the
+// networks of PeerScoreParams.IPColocationFactorWhitelist, for instance the ranges of a cloud NAT
+// that change over time. It applies from the next score computation.
+// It returns an error if the router is not gossipsub or peer scoring is not enabled.
+func (p *PubSub) AddIPColocationWhitelist(cidr *net.IPNet) error {
+	if cidr == nil {
+		return fmt.Errorf("nil IP colocation whitelist network")
+	}
+	return p.updateColocation(func(ps *peerScore) {
+		ps.addIPColocationWhitelist(cidr)
+	})
+}
+
+// RemoveIPColocationWhitelist removes a network from the IP colocation whitelist, whether it was
+// added with AddIPColocationWhitelist or in PeerScoreParams.IPColocationFactorWhitelist. It
+// applies from the next score computation.
+// It returns an error if the router is not gossipsub or peer scoring is not enabled.
+func (p *PubSub) RemoveIPColocationWhitelist(cidr *net.IPNet) error {
+	if cidr == nil {
+		return fmt.Errorf("nil IP colocation whitelist network")
+	}
+	return p.updateColocation(func(ps *peerScore) {
+		ps.removeIPColocationWhitelist(cidr)
+	})
+}
+
+// SetIPColocationExemption exempts a peer from the IP colocation penalty, whatever its IPs, or
+// removes its exemption. The peer still counts towards the colocation of the other peers sharing
+// its IPs. It applies from the next score computation.
+// It returns an error if the router is not gossipsub or peer scoring is not enabled.
+func (p *PubSub) SetIPColocationExemption(pid peer.ID, exempt bool) error {
+	return p.updateColocation(func(ps *peerScore) {
+		ps.setIPColocationExemption(pid, exempt)
+	})
+}
+
+// IPColocationExemptions returns the current IP colocation whitelist and the peers exempt from the
+// IP colocation penalty; see AddIPColocationWhitelist and SetIPColocationExemption.
+func (p *PubSub) IPColocationExemptions() ([]*net.IPNet, []peer.ID) {
+	gs, ok := p.rt.(*GossipSubRouter)
+	if !ok {
+		return nil, nil
+	}
+
+	type result struct {
+		cidrs []*net.IPNet
+		pids  []peer.ID
+	}
+
+	out := make(chan result, 1)
+	select {
+	case p.eval <- func() {
+		cidrs, pids := gs.score.ipColocationExemptions()
+		out <- result{cidrs, pids}
+	}:
+		res := <-out
+		return res.cidrs, res.pids
+	case <-p.ctx.Done():
+		return nil, nil
+	}
+}
+
+func (p *PubSub) updateColocation(update func(ps *peerScore)) error {
+	gs, ok := p.rt.(*GossipSubRouter)
+	if !ok {
+		return fmt.Errorf("pubsub router is not gossipsub")
+	}
+
+	res := make(chan error, 1)
+	select {
+	case p.eval <- func() {
+		if gs.score == nil {
+			res <- fmt.Errorf("peer scoring is not enabled")
+			return
+		}
+		update(gs.score)
+		res <- nil
+	}:
+		return <-res
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+func (ps *peerScore) addIPColocationWhitelist(cidr *net.IPNet) {
+	ps.Lock()
+	defer ps.Unlock()
+
+	for _, ipNet := range ps.ipWhitelist {
+		if ipNet.String() == cidr.String() {
+			return
+		}
+	}
+	ps.ipWhitelist = append(ps.ipWhitelist, cidr)
+	ps.resetIPWhitelist()
+}
+
+func (ps *peerScore) removeIPColocationWhitelist(cidr *net.IPNet) {
+	ps.Lock()
+	defer ps.Unlock()
+
+	for i, ipNet := range ps.ipWhitelist {
+		if ipNet.String() == cidr.String() {
+			ps.ipWhitelist = append(ps.ipWhitelist[:i:i], ps.ipWhitelist[i+1:]...)
+			ps.resetIPWhitelist()
+			return
+		}
+	}
+}
+
+// resetIPWhitelist forgets whether the IPs of the peers are whitelisted, after a change of the
+// whitelist.
+func (ps *peerScore) resetIPWhitelist() {
+	for _, pstats := range ps.peerStats {
+		pstats.ipWhitelist = nil
+	}
+}
+
+func (ps *peerScore) setIPColocationExemption(p peer.ID, exempt bool) {
+	ps.Lock()
+	defer ps.Unlock()
+
+	if !exempt {
+		delete(ps.ipExempt, p)
+		return
+	}
+	if ps.ipExempt == nil {
+		ps.ipExempt = make(map[peer.ID]struct{})
+	}
+	ps.ipExempt[p] = struct{}{}
+}
+
+func (ps *peerScore) ipColocationExemptions() ([]*net.IPNet, []peer.ID) {
+	if ps == nil {
+		return nil, nil
+	}
+
+	ps.Lock()
+	defer ps.Unlock()
+
+	cidrs := append([]*net.IPNet(nil), ps.ipWhitelist...)
+	pids := make([]peer.ID, 0, len(ps.ipExempt))
+	for p := range ps.ipExempt {
+		pids = append(pids, p)
+	}
+	return cidrs, pids
+}
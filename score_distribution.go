@@ -0,0 +1,292 @@
+package pubsub
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// DefaultScoreBuckets are the default upper bounds of the buckets of the score histograms; see
+// WithPeerScoreDistribution.
+var DefaultScoreBuckets = []float64{-1000, -100, -10, -1, 0, 1, 10, 100, 1000}
+
+// ScoreHistogram is the distribution of the scores of the peers in a topic, as returned by
+// ScoreDistribution.
+type ScoreHistogram struct {
+	// Buckets are the upper bounds of the buckets, in increasing order.
+	Buckets []float64
+	// Counts are the number of peers in each bucket, with a score above the bound of the previous
+	// bucket and at most the bound of the bucket; the last count is for the scores above all bounds.
+	Counts []int
+	// Count is the number of peers, and Sum the sum of their scores.
+	Count int
+	Sum   float64
+
+	// AboveZero is the number of peers with a positive score.
+	AboveZero int
+	// BelowGossip, BelowPublish and BelowGraylist are the number of peers with a score below the
+	// gossip, publish and graylist thresholds; as the thresholds are nested, so are the counts.
+	BelowGossip   int
+	BelowPublish  int
+	BelowGraylist int
+
+	// Updated is the time of the score snapshot the histogram was computed from.
+	Updated time.Time
+}
+
+// scoreDistribution maintains the score histograms from the extended score snapshots; it is
+// updated in the background goroutine of the scorer.
+type scoreDistribution struct {
+	buckets                                              []float64
+	gossipThreshold, publishThreshold, graylistThreshold float64
+
+	mx     sync.Mutex
+	all    *ScoreHistogram
+	topics map[string]*ScoreHistogram
+}
+
+// WithPeerScoreDistribution is a gossipsub router option that maintains histograms of the peer
+// scores, for every scored topic and for all peers, with the given bucket bounds, or
+// DefaultScoreBuckets if nil; see ScoreDistribution and ScoreMetricsHandler.
+// The histograms are computed from the extended score snapshots, every period; when a score
+// inspector is set with WithPeerScoreInspect, the histograms are computed before calling it, at its
+// period. The snapshots of the shadow scorer are not included; see WithShadowScoreParams.
+// This option must be passed _after_ the WithPeerScore and WithPeerScoreInspect options.
+func WithPeerScoreDistribution(buckets []float64, period time.Duration) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+
+		if gs.score == nil {
+			return fmt.Errorf("peer scoring is not enabled")
+		}
+
+		if gs.scoreDist != nil {
+			return fmt.Errorf("duplicate peer score distribution")
+		}
+
+		if period <= 0 {
+			return fmt.Errorf("invalid score distribution period; must be positive")
+		}
+
+		if buckets == nil {
+			buckets = DefaultScoreBuckets
+		}
+		if len(buckets) == 0 {
+			return fmt.Errorf("no score buckets")
+		}
+		for i := 1; i < len(buckets); i++ {
+			if buckets[i] <= buckets[i-1] {
+				return fmt.Errorf("score buckets must be in increasing order")
+			}
+		}
+
+		d := newScoreDistribution(buckets, gs.gossipThreshold, gs.publishThreshold, gs.graylistThreshold)
+		gs.scoreDist = d
+		gs.score.dist = d
+
+		if gs.score.inspect == nil && gs.score.inspectEx == nil {
+			gs.score.inspectPeriod = period
+		}
+
+		return nil
+	}
+}
+
+func newScoreDistribution(buckets []float64, gossipThreshold, publishThreshold, graylistThreshold float64) *scoreDistribution {
+	return &scoreDistribution{
+		buckets:           append([]float64(nil), buckets...),
+		gossipThreshold:   gossipThreshold,
+		publishThreshold:  publishThreshold,
+		graylistThreshold: graylistThreshold,
+	}
+}
+
+// ScoreDistribution returns the histogram of the scores of the peers in a topic, as of the last
+// score snapshot, or of all the peers if the topic is empty. The peers in a topic are the ones
+// with score counters in the topic, so only the topics with score parameters have a histogram.
+// It returns a zero histogram if there is none, or if the distribution is not enabled with
+// WithPeerScoreDistribution.
+func (p *PubSub) ScoreDistribution(topic string) ScoreHistogram {
+	gs, ok := p.rt.(*GossipSubRouter)
+	if !ok || gs.scoreDist == nil {
+		return ScoreHistogram{}
+	}
+	return gs.scoreDist.histogram(topic)
+}
+
+// ScoreMetricsHandler returns an HTTP handler serving the score histograms in the OpenMetrics text
+// format, as the gossipsub_peer_score histogram and the gossipsub_peer_score_band gauge, labeled
+// by topic; the histogram of all the peers has no topic label.
+// It serves no metrics if the distribution is not enabled with WithPeerScoreDistribution.
+func (p *PubSub) ScoreMetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var d *scoreDistribution
+		if gs, ok := p.rt.(*GossipSubRouter); ok {
+			d = gs.scoreDist
+		}
+
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+		if err := d.writeOpenMetrics(w); err != nil {
+			p.logger.Debugf("error writing score metrics: %s", err)
+		}
+	})
+}
+
+// update computes the histograms from a score snapshot.
+func (d *scoreDistribution) update(scores map[peer.ID]*PeerScoreSnapshot, now time.Time) {
+	all := d.newHistogram(now)
+	topics := make(map[string]*ScoreHistogram)
+	for _, s := range scores {
+		d.add(all, s.Score)
+		for topic := range s.Topics {
+			h, ok := topics[topic]
+			if !ok {
+				h = d.newHistogram(now)
+				topics[topic] = h
+			}
+			d.add(h, s.Score)
+		}
+	}
+
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	d.all = all
+	d.topics = topics
+}
+
+func (d *scoreDistribution) newHistogram(now time.Time) *ScoreHistogram {
+	return &ScoreHistogram{
+		Buckets: d.buckets,
+		Counts:  make([]int, len(d.buckets)+1),
+		Updated: now,
+	}
+}
+
+func (d *scoreDistribution) add(h *ScoreHistogram, score float64) {
+	h.Counts[sort.SearchFloat64s(d.buckets, score)]++
+	h.Count++
+	h.Sum += score
+
+	if score > 0 {
+		h.AboveZero++
+	}
+	if score < d.gossipThreshold {
+		h.BelowGossip++
+	}
+	if score < d.publishThreshold {
+		h.BelowPublish++
+	}
+	if score < d.graylistThreshold {
+		h.BelowGraylist++
+	}
+}
+
+// histogram returns a copy of the histogram of a topic, or of all the peers if the topic is empty.
+func (d *scoreDistribution) histogram(topic string) ScoreHistogram {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	h := d.all
+	if topic != "" {
+		h = d.topics[topic]
+	}
+	if h == nil {
+		return ScoreHistogram{}
+	}
+
+	out := *h
+	out.Buckets = append([]float64(nil), h.Buckets...)
+	out.Counts = append([]int(nil), h.Counts...)
+	return out
+}
+
+var openMetricsEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeOpenMetrics writes the histograms in the OpenMetrics text format; a nil distribution writes
+// no metrics.
+func (d *scoreDistribution) writeOpenMetrics(w io.Writer) error {
+	type entry struct {
+		labels string
+		h      ScoreHistogram
+	}
+
+	var entries []entry
+	if d != nil {
+		d.mx.Lock()
+		if d.all != nil {
+			entries = append(entries, entry{"", *d.all})
+		}
+		for topic, h := range d.topics {
+			entries = append(entries, entry{`topic="` + openMetricsEscaper.Replace(topic) + `"`, *h})
+		}
+		d.mx.Unlock()
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].labels < entries[j].labels
+	})
+
+	label := func(labels, extra string) string {
+		switch {
+		case labels == "" && extra == "":
+			return ""
+		case labels == "":
+			return "{" + extra + "}"
+		case extra == "":
+			return "{" + labels + "}"
+		default:
+			return "{" + labels + "," + extra + "}"
+		}
+	}
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# TYPE gossipsub_peer_score histogram")
+	fmt.Fprintln(bw, "# HELP gossipsub_peer_score Scores of the peers.")
+	for _, e := range entries {
+		count := 0
+		for i, bound := range e.h.Buckets {
+			count += e.h.Counts[i]
+			fmt.Fprintf(bw, "gossipsub_peer_score_bucket%s %d\n", label(e.labels, `le="`+format(bound)+`"`), count)
+		}
+		fmt.Fprintf(bw, "gossipsub_peer_score_bucket%s %d\n", label(e.labels, `le="+Inf"`), e.h.Count)
+		fmt.Fprintf(bw, "gossipsub_peer_score_sum%s %s\n", label(e.labels, ""), format(e.h.Sum))
+		fmt.Fprintf(bw, "gossipsub_peer_score_count%s %d\n", label(e.labels, ""), e.h.Count)
+	}
+
+	fmt.Fprintln(bw, "# TYPE gossipsub_peer_score_band gauge")
+	fmt.Fprintln(bw, "# HELP gossipsub_peer_score_band Number of peers in each score threshold band.")
+	for _, e := range entries {
+		for _, band := range []struct {
+			name  string
+			count int
+		}{
+			{"above_zero", e.h.AboveZero},
+			{"below_gossip", e.h.BelowGossip},
+			{"below_publish", e.h.BelowPublish},
+			{"below_graylist", e.h.BelowGraylist},
+		} {
+			fmt.Fprintf(bw, "gossipsub_peer_score_band%s %d\n", label(e.labels, `band="`+band.name+`"`), band.count)
+		}
+	}
+	fmt.Fprintln(bw, "# EOF")
+
+	return bw.Flush()
+}
@@ -0,0 +1,203 @@
+package pubsub
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestScoreDistributionBands(t *testing.T) {
+	d := newScoreDistribution([]float64{-100, -10, 0, 10}, -10, -50, -80)
+
+	snapshot := func(score float64, topics ...string) *PeerScoreSnapshot {
+		s := &PeerScoreSnapshot{Score: score, Topics: make(map[string]*TopicScoreSnapshot)}
+		for _, topic := range topics {
+			s.Topics[topic] = &TopicScoreSnapshot{}
+		}
+		return s
+	}
+
+	now := time.Now()
+	d.update(map[peer.ID]*PeerScoreSnapshot{
+		"A": snapshot(20, "foo", "bar"),
+		"B": snapshot(5, "foo"),
+		"C": snapshot(0, "foo"),
+		"D": snapshot(-10, "foo"),
+		"E": snapshot(-60, "foo", "bar"),
+		"F": snapshot(-200, "bar"),
+		"G": snapshot(1),
+	}, now)
+
+	expect := func(topic string, counts []int, aboveZero, belowGossip, belowPublish, belowGraylist int) {
+		t.Helper()
+
+		h := d.histogram(topic)
+		if len(h.Counts) != len(counts) {
+			t.Fatalf("expected %d buckets for %q, got %d", len(counts), topic, len(h.Counts))
+		}
+		total := 0
+		for i := range counts {
+			if h.Counts[i] != counts[i] {
+				t.Fatalf("expected counts %v for %q, got %v", counts, topic, h.Counts)
+			}
+			total += counts[i]
+		}
+		if h.Count != total {
+			t.Fatalf("expected %d peers for %q, got %d", total, topic, h.Count)
+		}
+		if h.AboveZero != aboveZero || h.BelowGossip != belowGossip || h.BelowPublish != belowPublish || h.BelowGraylist != belowGraylist {
+			t.Fatalf("unexpected bands for %q: %+v", topic, h)
+		}
+		if counts != nil && !h.Updated.Equal(now) {
+			t.Fatalf("unexpected update time for %q", topic)
+		}
+	}
+
+	// buckets: (-inf, -100], (-100, -10], (-10, 0], (0, 10], (10, +inf)
+	expect("foo", []int{0, 2, 1, 1, 1}, 2, 1, 1, 0)
+	expect("bar", []int{1, 1, 0, 0, 1}, 1, 2, 2, 1)
+	expect("", []int{1, 2, 1, 2, 1}, 3, 2, 2, 1)
+
+	if h := d.histogram("baz"); h.Count != 0 || h.Counts != nil {
+		t.Fatalf("expected no histogram for an unknown topic, got %+v", h)
+	}
+	if h := d.histogram("foo"); h.Sum != 20+5+0-10-60 {
+		t.Fatalf("unexpected sum %f", h.Sum)
+	}
+
+	// the histograms are replaced by the next snapshot
+	d.update(map[peer.ID]*PeerScoreSnapshot{
+		"A": snapshot(-90, "bar"),
+	}, now)
+	expect("foo", nil, 0, 0, 0, 0)
+	expect("bar", []int{0, 1, 0, 0, 0}, 0, 1, 1, 1)
+
+	// the returned histograms are copies
+	h := d.histogram("bar")
+	h.Counts[0] = 42
+	if d.histogram("bar").Counts[0] != 0 {
+		t.Fatal("expected the histogram to be copied")
+	}
+}
+
+func TestScoreDistributionOpenMetrics(t *testing.T) {
+	d := newScoreDistribution([]float64{-10, 0, 10}, -10, -50, -80)
+	d.update(map[peer.ID]*PeerScoreSnapshot{
+		"A": {Score: 20, Topics: map[string]*TopicScoreSnapshot{"foo": {}}},
+		"B": {Score: -20.5, Topics: map[string]*TopicScoreSnapshot{"foo": {}, `b"ar`: {}}},
+	}, time.Now())
+
+	var sb strings.Builder
+	if err := d.writeOpenMetrics(&sb); err != nil {
+		t.Fatal(err)
+	}
+	out := sb.String()
+
+	for _, line := range []string{
+		`# TYPE gossipsub_peer_score histogram`,
+		`gossipsub_peer_score_bucket{le="-10"} 1`,
+		`gossipsub_peer_score_bucket{le="10"} 1`,
+		`gossipsub_peer_score_bucket{le="+Inf"} 2`,
+		`gossipsub_peer_score_sum -0.5`,
+		`gossipsub_peer_score_count 2`,
+		`gossipsub_peer_score_bucket{topic="foo",le="0"} 1`,
+		`gossipsub_peer_score_count{topic="b\"ar"} 1`,
+		`gossipsub_peer_score_band{topic="foo",band="above_zero"} 1`,
+		`gossipsub_peer_score_band{topic="foo",band="below_gossip"} 1`,
+		`gossipsub_peer_score_band{band="below_graylist"} 0`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Fatalf("expected line %q in:\n%s", line, out)
+		}
+	}
+	if !strings.HasSuffix(out, "# EOF\n") {
+		t.Fatalf("expected the output to end with EOF:\n%s", out)
+	}
+
+	// a nil distribution serves no metrics
+	sb.Reset()
+	var nd *scoreDistribution
+	if err := nd.writeOpenMetrics(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sb.String(), "gossipsub_peer_score_bucket") {
+		t.Fatalf("expected no metrics:\n%s", sb.String())
+	}
+}
+
+func TestScoreDistribution(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+
+	var inspected int32
+	inspect := func(map[peer.ID]*PeerScoreSnapshot) {
+		atomic.AddInt32(&inspected, 1)
+	}
+
+	psub := getGossipsub(ctx, hosts[0],
+		WithPeerScore(
+			&PeerScoreParams{
+				Topics: map[string]*TopicScoreParams{
+					"test": {
+						TopicWeight:       1,
+						TimeInMeshWeight:  1,
+						TimeInMeshQuantum: time.Second,
+						TimeInMeshCap:     10,
+
+						InvalidMessageDeliveriesWeight: -1,
+						InvalidMessageDeliveriesDecay:  0.9999,
+					},
+				},
+				AppSpecificScore: func(peer.ID) float64 { return 0 },
+				DecayInterval:    time.Second,
+				DecayToZero:      0.01,
+			},
+			&PeerScoreThresholds{
+				GossipThreshold:   -1,
+				PublishThreshold:  -10,
+				GraylistThreshold: -1000,
+			}),
+		WithPeerScoreInspect(ExtendedPeerScoreInspectFn(inspect), 100*time.Millisecond),
+		WithPeerScoreDistribution(nil, time.Hour))
+	psub2 := getGossipsub(ctx, hosts[1])
+
+	connect(t, hosts[0], hosts[1])
+	for _, ps := range []*PubSub{psub, psub2} {
+		if _, err := ps.Subscribe("test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(2 * time.Second)
+
+	// the distribution is computed at the period of the inspector, which is still called
+	if atomic.LoadInt32(&inspected) == 0 {
+		t.Fatal("expected the inspector to be called")
+	}
+	h := psub.ScoreDistribution("test")
+	if h.Count != 1 || h.AboveZero != 1 || len(h.Counts) != len(DefaultScoreBuckets)+1 {
+		t.Fatalf("unexpected histogram %+v", h)
+	}
+	if h := psub.ScoreDistribution(""); h.Count != 1 {
+		t.Fatalf("unexpected histogram %+v", h)
+	}
+
+	rec := httptest.NewRecorder()
+	psub.ScoreMetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), `gossipsub_peer_score_count{topic="test"} 1`) {
+		t.Fatalf("unexpected metrics:\n%s", rec.Body.String())
+	}
+
+	// without the option, there is no distribution
+	if h := psub2.ScoreDistribution("test"); h.Count != 0 {
+		t.Fatalf("unexpected histogram %+v", h)
+	}
+}
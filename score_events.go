@@ -0,0 +1,204 @@
+package pubsub
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// reasons of the behaviour penalties, as recorded in the peer score event log
+const (
+	PenaltyGraftBackoff       = "graft during backoff"
+	PenaltyGraftFlood         = "graft flood"
+	PenaltyBrokenPromise      = "broken IWANT promise"
+	PenaltyWriteTimeout       = "write timeout"
+	PenaltyUnresponsive       = "unresponsive mesh peer"
+	PenaltyQuotaExceeded      = "message quota exceeded"
+	PenaltySubscriptionFilter = "subscription filter"
+	PenaltyRPCLimits          = "RPC limits exceeded"
+	PenaltyMalformedRPC       = "malformed RPC"
+	PenaltySubscriptionChurn  = "subscription churn"
+)
+
+// ScoreEventType is the type of a score-affecting event; see ScoreEvent.
+type ScoreEventType int
+
+const (
+	// ScoreEventFirstDelivery is the first delivery of a message in a scored topic.
+	ScoreEventFirstDelivery ScoreEventType = iota
+	// ScoreEventDuplicateDelivery is a duplicate delivery of a message from a mesh peer, counted
+	// as a mesh message delivery.
+	ScoreEventDuplicateDelivery
+	// ScoreEventInvalidDelivery is the delivery of an invalid message.
+	ScoreEventInvalidDelivery
+	// ScoreEventIgnoredDelivery is the delivery of a message ignored by the validators.
+	ScoreEventIgnoredDelivery
+	// ScoreEventMeshJoin is the peer joining our mesh for a topic.
+	ScoreEventMeshJoin
+	// ScoreEventMeshLeave is the peer leaving our mesh for a topic, along with the mesh failure
+	// penalty it incurred, if any.
+	ScoreEventMeshLeave
+	// ScoreEventBehaviourPenalty is a behaviour penalty, along with its reason.
+	ScoreEventBehaviourPenalty
+	// ScoreEventIPColocation is a change in the number of peers sharing an IP with the peer.
+	ScoreEventIPColocation
+)
+
+func (t ScoreEventType) String() string {
+	switch t {
+	case ScoreEventFirstDelivery:
+		return "first delivery"
+	case ScoreEventDuplicateDelivery:
+		return "duplicate delivery"
+	case ScoreEventInvalidDelivery:
+		return "invalid delivery"
+	case ScoreEventIgnoredDelivery:
+		return "ignored delivery"
+	case ScoreEventMeshJoin:
+		return "mesh join"
+	case ScoreEventMeshLeave:
+		return "mesh leave"
+	case ScoreEventBehaviourPenalty:
+		return "behaviour penalty"
+	case ScoreEventIPColocation:
+		return "IP colocation"
+	default:
+		return fmt.Sprintf("ScoreEventType(%d)", int(t))
+	}
+}
+
+// ScoreEvent is a score-affecting event of a peer, as recorded in the peer score event log.
+type ScoreEvent struct {
+	Time time.Time
+	Type ScoreEventType
+	// Topic is the topic of deliveries and mesh changes.
+	Topic string
+	// Penalty is the count of a behaviour penalty, or the mesh failure penalty incurred when
+	// leaving the mesh.
+	Penalty float64
+	// Reason is the reason of a behaviour penalty.
+	Reason string
+	// IP is the IP of a colocation change, and Colocated the number of peers sharing it, including
+	// the peer.
+	IP        string
+	Colocated int
+}
+
+// WithPeerScoreEventLog is a gossipsub router option that records the last size score-affecting
+// events of each peer, for analyzing the sequence of events that led to a peer being penalized or
+// graylisted; see PeerScoreEvents. The events are recorded for the peers whose score is tracked,
+// connected or retained, up to maxPeers peers.
+// This option must be passed _after_ the WithPeerScore option.
+func WithPeerScoreEventLog(size, maxPeers int) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+
+		if gs.score == nil {
+			return fmt.Errorf("peer scoring is not enabled")
+		}
+
+		if size <= 0 || maxPeers <= 0 {
+			return fmt.Errorf("invalid peer score event log size; must be positive")
+		}
+
+		gs.score.events = &scoreEventLog{
+			size:     size,
+			maxPeers: maxPeers,
+			peers:    make(map[peer.ID]*scoreEventRing),
+		}
+		return nil
+	}
+}
+
+// PeerScoreEvents returns the score-affecting events of a peer recorded in the peer score event
+// log, oldest first. It returns nil if the events of the peer are not recorded.
+func (p *PubSub) PeerScoreEvents(pid peer.ID) []ScoreEvent {
+	gs, ok := p.rt.(*GossipSubRouter)
+	if !ok || gs.score == nil {
+		return nil
+	}
+
+	gs.score.Lock()
+	defer gs.score.Unlock()
+	return gs.score.events.get(pid)
+}
+
+// scoreEventLog records the score-affecting events of the tracked peers; it is protected by the
+// lock of the scorer. A nil log records nothing.
+type scoreEventLog struct {
+	size     int
+	maxPeers int
+	peers    map[peer.ID]*scoreEventRing
+}
+
+type scoreEventRing struct {
+	events []ScoreEvent
+	next   int
+}
+
+// track starts recording the events of a peer, unless there are too many tracked peers.
+func (sl *scoreEventLog) track(p peer.ID) {
+	if sl == nil {
+		return
+	}
+	if _, ok := sl.peers[p]; ok || len(sl.peers) >= sl.maxPeers {
+		return
+	}
+	sl.peers[p] = &scoreEventRing{events: make([]ScoreEvent, 0, sl.size)}
+}
+
+// forget stops recording the events of a peer whose score is no longer tracked.
+func (sl *scoreEventLog) forget(p peer.ID) {
+	if sl == nil {
+		return
+	}
+	delete(sl.peers, p)
+}
+
+// record records an event of a tracked peer.
+func (sl *scoreEventLog) record(p peer.ID, evt ScoreEvent) {
+	if sl == nil {
+		return
+	}
+	ring, ok := sl.peers[p]
+	if !ok {
+		return
+	}
+
+	evt.Time = time.Now()
+	if len(ring.events) < sl.size {
+		ring.events = append(ring.events, evt)
+		return
+	}
+	ring.events[ring.next] = evt
+	ring.next = (ring.next + 1) % sl.size
+}
+
+func (sl *scoreEventLog) get(p peer.ID) []ScoreEvent {
+	if sl == nil {
+		return nil
+	}
+	ring, ok := sl.peers[p]
+	if !ok {
+		return nil
+	}
+
+	events := make([]ScoreEvent, 0, len(ring.events))
+	events = append(events, ring.events[ring.next:]...)
+	return append(events, ring.events[:ring.next]...)
+}
+
+// recordColocation records the number of peers sharing an IP for each of them.
+func (ps *peerScore) recordColocation(ip string) {
+	if ps.events == nil {
+		return
+	}
+	peers := ps.peerIPs[ip]
+	for p := range peers {
+		ps.events.record(p, ScoreEvent{Type: ScoreEventIPColocation, IP: ip, Colocated: len(peers)})
+	}
+}
@@ -0,0 +1,204 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestScoreEventLog(t *testing.T) {
+	mytopic := "mytopic"
+	params := &PeerScoreParams{
+		AppSpecificScore:            func(peer.ID) float64 { return 0 },
+		IPColocationFactorThreshold: 1,
+		IPColocationFactorWeight:    -1,
+		BehaviourPenaltyWeight:      -1,
+		BehaviourPenaltyDecay:       0.99,
+		Topics:                      make(map[string]*TopicScoreParams),
+	}
+	params.Topics[mytopic] = &TopicScoreParams{
+		TopicWeight:                    1,
+		TimeInMeshQuantum:              time.Second,
+		FirstMessageDeliveriesWeight:   1,
+		FirstMessageDeliveriesCap:      10,
+		InvalidMessageDeliveriesWeight: -1,
+	}
+
+	peerA := peer.ID("A")
+	peerB := peer.ID("B")
+	peerC := peer.ID("C")
+
+	ps := newPeerScore(params)
+	ps.events = &scoreEventLog{size: 4, maxPeers: 2, peers: make(map[peer.ID]*scoreEventRing)}
+	for _, p := range []peer.ID{peerA, peerB, peerC} {
+		ps.AddPeer(p, "myproto")
+	}
+
+	// the third peer is beyond the cap on tracked peers
+	if events := ps.events.get(peerC); events != nil {
+		t.Fatalf("expected the events of peer C not to be recorded, got %v", events)
+	}
+
+	ps.Graft(peerA, mytopic)
+	msg := makeTestMessage(0)
+	msg.Topic = &mytopic
+	ps.markInvalidMessageDelivery(peerA, &Message{Message: msg})
+	ps.AddPenalty(peerA, 2, PenaltyGraftBackoff)
+	events := ps.events.get(peerA)
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %v", events)
+	}
+	for i, typ := range []ScoreEventType{ScoreEventMeshJoin, ScoreEventInvalidDelivery, ScoreEventBehaviourPenalty} {
+		if events[i].Type != typ {
+			t.Fatalf("expected a %s event, got %s", typ, events[i].Type)
+		}
+	}
+	if events[2].Penalty != 2 || events[2].Reason != PenaltyGraftBackoff {
+		t.Fatalf("expected the penalty with its reason, got %v", events[2])
+	}
+
+	// colocated peers are both told of the change
+	setIPsForPeer(t, ps, peerA, "1.2.3.4")
+	setIPsForPeer(t, ps, peerB, "1.2.3.4")
+	events = ps.events.get(peerB)
+	if len(events) != 1 || events[0].Type != ScoreEventIPColocation || events[0].Colocated != 2 {
+		t.Fatalf("expected peer B to be colocated with peer A, got %v", events)
+	}
+
+	// the ring keeps the most recent events, oldest first
+	events = ps.events.get(peerA)
+	if len(events) != 4 || events[0].Type != ScoreEventInvalidDelivery {
+		t.Fatalf("expected the oldest event to be dropped, got %v", events)
+	}
+	last := events[3]
+	if last.Type != ScoreEventIPColocation || last.IP != "1.2.3.4" || last.Colocated != 2 {
+		t.Fatalf("expected the colocation with peer B last, got %v", last)
+	}
+
+	// the events of a peer are kept as long as its score is retained
+	ps.RemovePeer(peerA)
+	if events := ps.events.get(peerA); len(events) == 0 || events[len(events)-1].Type != ScoreEventMeshLeave {
+		t.Fatalf("expected the events of the retained peer, ending with its mesh leave, got %v", events)
+	}
+	ps.peerStats[peerA].expire = time.Now().Add(-time.Second)
+	ps.refreshScores()
+	if events := ps.events.get(peerA); events != nil {
+		t.Fatalf("expected the events of peer A to be forgotten with its score, got %v", events)
+	}
+
+	// making room for peer C
+	ps.AddPeer(peerC, "myproto")
+	ps.AddPenalty(peerC, 1, PenaltyBrokenPromise)
+	if events := ps.events.get(peerC); len(events) != 1 {
+		t.Fatalf("expected the events of peer C to be recorded, got %v", events)
+	}
+}
+
+// Test that the event log of a peer driven to the graylist explains its score.
+func TestScoreEventLogGraylist(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	legit := hosts[0]
+	attacker := hosts[1]
+
+	mytopic := "mytopic"
+	params := &PeerScoreParams{
+		AppSpecificScore:            func(peer.ID) float64 { return 0 },
+		BehaviourPenaltyWeight:      -1,
+		BehaviourPenaltyDecay:       ScoreParameterDecay(time.Minute),
+		DecayInterval:               time.Hour,
+		DecayToZero:                 DefaultDecayToZero,
+		IPColocationFactorThreshold: 10,
+		Topics:                      make(map[string]*TopicScoreParams),
+	}
+	params.Topics[mytopic] = &TopicScoreParams{
+		TopicWeight:                    1,
+		TimeInMeshQuantum:              time.Second,
+		InvalidMessageDeliveriesWeight: -1,
+		InvalidMessageDeliveriesDecay:  0.9,
+	}
+	thresholds := &PeerScoreThresholds{
+		GossipThreshold:   -10,
+		PublishThreshold:  -20,
+		GraylistThreshold: -30,
+	}
+
+	ps, err := NewGossipSub(ctx, legit,
+		WithPeerScore(params, thresholds),
+		WithPeerScoreEventLog(100, 10),
+		WithMalformedRPCThreshold(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.Subscribe(mytopic); err != nil {
+		t.Fatal(err)
+	}
+
+	newMockGS(ctx, t, attacker, func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+		for _, sub := range irpc.GetSubscriptions() {
+			if !sub.GetSubscribe() {
+				continue
+			}
+
+			writeMsg(&pb.RPC{
+				Subscriptions: []*pb.RPC_SubOpts{{Subscribe: sub.Subscribe, Topicid: sub.Topicid}},
+				Control:       &pb.ControlMessage{Graft: []*pb.ControlGraft{{TopicID: sub.Topicid}}},
+			})
+			time.Sleep(100 * time.Millisecond)
+
+			// unsigned messages are invalid
+			for i := 0; i < 5; i++ {
+				writeMsg(&pb.RPC{Publish: []*pb.Message{{Data: []byte(fmt.Sprintf("invalid %d", i)), Topic: sub.Topicid}}})
+			}
+			// and subscriptions without a topic are malformed
+			for i := 0; i < 3; i++ {
+				writeMsg(&pb.RPC{Subscriptions: []*pb.RPC_SubOpts{{Subscribe: sub.Subscribe}}})
+			}
+		}
+	})
+
+	connect(t, legit, attacker)
+	time.Sleep(time.Second)
+
+	score := ps.rt.(*GossipSubRouter).score.Score(attacker.ID())
+	if score >= thresholds.GraylistThreshold {
+		t.Fatalf("expected the attacker to be graylisted, got score %f", score)
+	}
+
+	var joined bool
+	var invalid, penalty float64
+	for _, evt := range ps.PeerScoreEvents(attacker.ID()) {
+		switch evt.Type {
+		case ScoreEventMeshJoin:
+			joined = evt.Topic == mytopic
+		case ScoreEventInvalidDelivery:
+			invalid++
+		case ScoreEventBehaviourPenalty:
+			if evt.Reason != PenaltyMalformedRPC {
+				t.Fatalf("unexpected behaviour penalty %q", evt.Reason)
+			}
+			penalty += evt.Penalty
+		}
+	}
+	if !joined {
+		t.Fatal("expected the attacker to join the mesh")
+	}
+
+	// the invalid deliveries and the behaviour penalties add up to the score
+	expected := params.Topics[mytopic].InvalidMessageDeliveriesWeight*invalid*invalid + params.BehaviourPenaltyWeight*penalty*penalty
+	if invalid != 5 || penalty != 3 || math.Abs(score-expected) > 1e-9 {
+		t.Fatalf("expected the events to explain the score %f, got %f from %f invalid deliveries and %f penalties", score, expected, invalid, penalty)
+	}
+
+	if events := ps.PeerScoreEvents(legit.ID()); events != nil {
+		t.Fatalf("expected no events for an unknown peer, got %v", events)
+	}
+}
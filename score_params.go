@@ -1,9 +1,11 @@
 package pubsub
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"net"
+	"sort"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -34,33 +36,35 @@ type PeerScoreThresholds struct {
 	OpportunisticGraftThreshold float64
 }
 
-func (p *PeerScoreThresholds) validate() error {
+// Validate checks the thresholds, returning all the violations found.
+func (p *PeerScoreThresholds) Validate() error {
+	var errs scoreParamErrors
 
 	if !p.SkipAtomicValidation || p.PublishThreshold != 0 || p.GossipThreshold != 0 || p.GraylistThreshold != 0 {
 		if p.GossipThreshold > 0 || isInvalidNumber(p.GossipThreshold) {
-			return fmt.Errorf("invalid gossip threshold; it must be <= 0 and a valid number")
+			errs.add("GossipThreshold", "must be <= 0 and a valid number")
 		}
 		if p.PublishThreshold > 0 || p.PublishThreshold > p.GossipThreshold || isInvalidNumber(p.PublishThreshold) {
-			return fmt.Errorf("invalid publish threshold; it must be <= 0 and <= gossip threshold and a valid number")
+			errs.add("PublishThreshold", "must be <= 0 and <= GossipThreshold and a valid number")
 		}
 		if p.GraylistThreshold > 0 || p.GraylistThreshold > p.PublishThreshold || isInvalidNumber(p.GraylistThreshold) {
-			return fmt.Errorf("invalid graylist threshold; it must be <= 0 and <= publish threshold and a valid number")
+			errs.add("GraylistThreshold", "must be <= 0 and <= PublishThreshold and a valid number")
 		}
 	}
 
 	if !p.SkipAtomicValidation || p.AcceptPXThreshold != 0 {
 		if p.AcceptPXThreshold < 0 || isInvalidNumber(p.AcceptPXThreshold) {
-			return fmt.Errorf("invalid accept PX threshold; it must be >= 0 and a valid number")
+			errs.add("AcceptPXThreshold", "must be >= 0 and a valid number")
 		}
 	}
 
 	if !p.SkipAtomicValidation || p.OpportunisticGraftThreshold != 0 {
 		if p.OpportunisticGraftThreshold < 0 || isInvalidNumber(p.OpportunisticGraftThreshold) {
-			return fmt.Errorf("invalid opportunistic grafting threshold; it must be >= 0 and a valid number")
+			errs.add("OpportunisticGraftThreshold", "must be >= 0 and a valid number")
 		}
 	}
 
-	return nil
+	return errs.err()
 }
 
 type PeerScoreParams struct {
@@ -155,33 +159,102 @@ type TopicScoreParams struct {
 	MeshMessageDeliveriesCap, MeshMessageDeliveriesThreshold     float64
 	MeshMessageDeliveriesWindow, MeshMessageDeliveriesActivation time.Duration
 
+	// The mesh message delivery window can optionally adapt to the propagation latency observed in
+	// the topic: when MeshMessageDeliveriesWindowFactor is positive, the effective window is that
+	// multiple of a moving average of the delay between the first delivery of a message and its
+	// duplicates, clamped to [MeshMessageDeliveriesWindowMin, MeshMessageDeliveriesWindowMax].
+	// MeshMessageDeliveriesWindow applies until a duplicate has been observed.
+	MeshMessageDeliveriesWindowFactor                              float64
+	MeshMessageDeliveriesWindowMin, MeshMessageDeliveriesWindowMax time.Duration
+
 	// P3b: sticky mesh propagation failures
 	// This is a sticky penalty that applies when a peer gets pruned from the mesh with an active
 	// mesh message delivery penalty.
 	// The weight of the parameter MUST be negative (or zero to disable)
 	MeshFailurePenaltyWeight, MeshFailurePenaltyDecay float64
 
+	// P3c: late duplicate deliveries
+	// This is an optional penalty for mesh peers that consistently forward messages late.
+	// For each duplicate delivered by a mesh peer, we track the delay since we first saw the
+	// message; the penalty applies when the median of the recent delays exceeds
+	// DuplicateLatenessThreshold while the peer's first message deliveries counter is at or
+	// below DuplicateLatenessFirstDeliveriesThreshold.
+	// The value of the parameter is 1 when the penalty applies and 0 otherwise.
+	// The weight of the parameter MUST be negative (or zero to disable).
+	DuplicateLatenessWeight                   float64
+	DuplicateLatenessThreshold                time.Duration
+	DuplicateLatenessFirstDeliveriesThreshold float64
+
 	// P4: invalid messages
 	// This is the number of invalid messages in the topic.
 	// The value of the parameter is the square of the counter, decaying with
 	// InvalidMessageDeliveriesDecay.
 	// The weight of the parameter MUST be negative (or zero to disable).
 	InvalidMessageDeliveriesWeight, InvalidMessageDeliveriesDecay float64
+
+	// P4b: ignored messages
+	// This is the number of messages in the topic that a validator returned ValidationIgnore for;
+	// it allows penalizing peers that propagate spammy-but-not-invalid messages.
+	// The value of the parameter is the square of the counter, decaying with
+	// IgnoredMessageDeliveriesDecay.
+	// The weight of the parameter MUST be negative (or zero to disable, which is the default).
+	IgnoredMessageDeliveriesWeight, IgnoredMessageDeliveriesDecay float64
+}
+
+// ScoreParamError is a violation of the constraints on a score parameter or threshold.
+type ScoreParamError struct {
+	// Field is the name of the offending field; the fields of topic parameters validated as part of
+	// PeerScoreParams are qualified with the topic, as in Topics[foo].TopicWeight.
+	Field string
+	// Reason describes the violated constraint.
+	Reason string
+}
+
+func (e *ScoreParamError) Error() string {
+	return fmt.Sprintf("invalid %s; %s", e.Field, e.Reason)
+}
+
+// scoreParamErrors accumulates the violations found during validation.
+type scoreParamErrors struct {
+	prefix string
+	errs   []error
+}
+
+func (e *scoreParamErrors) add(field, reason string) {
+	e.errs = append(e.errs, &ScoreParamError{Field: e.prefix + field, Reason: reason})
+}
+
+// err returns all the violations joined in a single error, or nil if there are none.
+func (e *scoreParamErrors) err() error {
+	return errors.Join(e.errs...)
 }
 
-// peer score parameter validation
-func (p *PeerScoreParams) validate() error {
-	for topic, params := range p.Topics {
-		err := params.validate()
-		if err != nil {
-			return fmt.Errorf("invalid score parameters for topic %s: %w", topic, err)
+// Validate checks the score parameters, including the parameters of all topics, returning all the
+// violations found. The errors are ScoreParamErrors, joined with errors.Join.
+// Note that in non-atomic mode a missing AppSpecificScore is set to a function returning 0.
+func (p *PeerScoreParams) Validate() error {
+	var errs scoreParamErrors
+
+	topics := make([]string, 0, len(p.Topics))
+	for topic := range p.Topics {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	for _, topic := range topics {
+		errs.prefix = fmt.Sprintf("Topics[%s].", topic)
+		if params := p.Topics[topic]; params != nil {
+			params.validate(&errs)
+		} else {
+			errs.add("", "missing topic score parameters")
 		}
 	}
+	errs.prefix = ""
 
 	if !p.SkipAtomicValidation || p.TopicScoreCap != 0 {
 		// check that the topic score is 0 or something positive
 		if p.TopicScoreCap < 0 || isInvalidNumber(p.TopicScoreCap) {
-			return fmt.Errorf("invalid topic score cap; must be positive (or 0 for no cap) and a valid number")
+			errs.add("TopicScoreCap", "must be positive (or 0 for no cap) and a valid number")
 		}
 	}
 
@@ -192,85 +265,82 @@ func (p *PeerScoreParams) validate() error {
 				return 0
 			}
 		} else {
-			return fmt.Errorf("missing application specific score function")
+			errs.add("AppSpecificScore", "missing application specific score function")
 		}
 	}
 
 	if !p.SkipAtomicValidation || p.IPColocationFactorWeight != 0 {
 		// check the IP collocation factor
 		if p.IPColocationFactorWeight > 0 || isInvalidNumber(p.IPColocationFactorWeight) {
-			return fmt.Errorf("invalid IPColocationFactorWeight; must be negative (or 0 to disable) and a valid number")
+			errs.add("IPColocationFactorWeight", "must be negative (or 0 to disable) and a valid number")
 		}
 		if p.IPColocationFactorWeight != 0 && p.IPColocationFactorThreshold < 1 {
-			return fmt.Errorf("invalid IPColocationFactorThreshold; must be at least 1")
+			errs.add("IPColocationFactorThreshold", "must be at least 1")
 		}
 	}
 
 	// check the behaviour penalty
 	if !p.SkipAtomicValidation || p.BehaviourPenaltyWeight != 0 || p.BehaviourPenaltyThreshold != 0 {
 		if p.BehaviourPenaltyWeight > 0 || isInvalidNumber(p.BehaviourPenaltyWeight) {
-			return fmt.Errorf("invalid BehaviourPenaltyWeight; must be negative (or 0 to disable) and a valid number")
+			errs.add("BehaviourPenaltyWeight", "must be negative (or 0 to disable) and a valid number")
 		}
 		if p.BehaviourPenaltyWeight != 0 && (p.BehaviourPenaltyDecay <= 0 || p.BehaviourPenaltyDecay >= 1 || isInvalidNumber(p.BehaviourPenaltyDecay)) {
-			return fmt.Errorf("invalid BehaviourPenaltyDecay; must be between 0 and 1")
+			errs.add("BehaviourPenaltyDecay", "must be between 0 and 1")
 		}
 		if p.BehaviourPenaltyThreshold < 0 || isInvalidNumber(p.BehaviourPenaltyThreshold) {
-			return fmt.Errorf("invalid BehaviourPenaltyThreshold; must be >= 0 and a valid number")
+			errs.add("BehaviourPenaltyThreshold", "must be >= 0 and a valid number")
 		}
 	}
 
 	// check the decay parameters
 	if !p.SkipAtomicValidation || p.DecayInterval != 0 || p.DecayToZero != 0 {
 		if p.DecayInterval < time.Second {
-			return fmt.Errorf("invalid DecayInterval; must be at least 1s")
+			errs.add("DecayInterval", "must be at least 1s")
 		}
 		if p.DecayToZero <= 0 || p.DecayToZero >= 1 || isInvalidNumber(p.DecayToZero) {
-			return fmt.Errorf("invalid DecayToZero; must be between 0 and 1")
+			errs.add("DecayToZero", "must be between 0 and 1")
 		}
 	}
 
 	// no need to check the score retention; a value of 0 means that we don't retain scores
-	return nil
+	return errs.err()
+}
+
+// Validate checks the topic score parameters, returning all the violations found. The errors are
+// ScoreParamErrors, joined with errors.Join.
+func (p *TopicScoreParams) Validate() error {
+	var errs scoreParamErrors
+	p.validate(&errs)
+	return errs.err()
 }
 
-func (p *TopicScoreParams) validate() error {
+func (p *TopicScoreParams) validate(errs *scoreParamErrors) {
 	// make sure we have a sane topic weight
 	if p.TopicWeight < 0 || isInvalidNumber(p.TopicWeight) {
-		return fmt.Errorf("invalid topic weight; must be >= 0 and a valid number")
+		errs.add("TopicWeight", "must be >= 0 and a valid number")
 	}
 
 	// check P1
-	if err := p.validateTimeInMeshParams(); err != nil {
-		return err
-	}
-
+	p.validateTimeInMeshParams(errs)
 	// check P2
-	if err := p.validateMessageDeliveryParams(); err != nil {
-		return err
-	}
+	p.validateMessageDeliveryParams(errs)
 	// check P3
-	if err := p.validateMeshMessageDeliveryParams(); err != nil {
-		return err
-	}
-
+	p.validateMeshMessageDeliveryParams(errs)
 	// check P3b
-	if err := p.validateMessageFailurePenaltyParams(); err != nil {
-		return err
-	}
-
+	p.validateMessageFailurePenaltyParams(errs)
+	// check P3c
+	p.validateDuplicateLatenessParams(errs)
 	// check P4
-	if err := p.validateInvalidMessageDeliveryParams(); err != nil {
-		return err
-	}
-
-	return nil
+	p.validateInvalidMessageDeliveryParams(errs)
+	// check P4b
+	p.validateIgnoredMessageDeliveryParams(errs)
 }
 
-func (p *TopicScoreParams) validateTimeInMeshParams() error {
+func (p *TopicScoreParams) validateTimeInMeshParams(errs *scoreParamErrors) {
 	if p.SkipAtomicValidation {
 		// in non-atomic mode, parameters at their zero values are dismissed from validation.
 		if p.TimeInMeshWeight == 0 && p.TimeInMeshQuantum == 0 && p.TimeInMeshCap == 0 {
-			return nil
+			return
 		}
 	}
 
@@ -278,26 +348,23 @@ func (p *TopicScoreParams) validateTimeInMeshParams() error {
 	// hence, proceed with normal validation of all related parameters in this context.
 
 	if p.TimeInMeshQuantum == 0 {
-		return fmt.Errorf("invalid TimeInMeshQuantum; must be non zero")
+		errs.add("TimeInMeshQuantum", "must be non zero")
+	} else if p.TimeInMeshWeight != 0 && p.TimeInMeshQuantum < 0 {
+		errs.add("TimeInMeshQuantum", "must be positive")
 	}
 	if p.TimeInMeshWeight < 0 || isInvalidNumber(p.TimeInMeshWeight) {
-		return fmt.Errorf("invalid TimeInMeshWeight; must be positive (or 0 to disable) and a valid number")
-	}
-	if p.TimeInMeshWeight != 0 && p.TimeInMeshQuantum <= 0 {
-		return fmt.Errorf("invalid TimeInMeshQuantum; must be positive")
+		errs.add("TimeInMeshWeight", "must be positive (or 0 to disable) and a valid number")
 	}
 	if p.TimeInMeshWeight != 0 && (p.TimeInMeshCap <= 0 || isInvalidNumber(p.TimeInMeshCap)) {
-		return fmt.Errorf("invalid TimeInMeshCap; must be positive and a valid number")
+		errs.add("TimeInMeshCap", "must be positive and a valid number")
 	}
-
-	return nil
 }
 
-func (p *TopicScoreParams) validateMessageDeliveryParams() error {
+func (p *TopicScoreParams) validateMessageDeliveryParams(errs *scoreParamErrors) {
 	if p.SkipAtomicValidation {
 		// in non-atomic mode, parameters at their zero values are dismissed from validation.
 		if p.FirstMessageDeliveriesWeight == 0 && p.FirstMessageDeliveriesCap == 0 && p.FirstMessageDeliveriesDecay == 0 {
-			return nil
+			return
 		}
 	}
 
@@ -305,19 +372,17 @@ func (p *TopicScoreParams) validateMessageDeliveryParams() error {
 	// hence, proceed with normal validation of all related parameters in this context.
 
 	if p.FirstMessageDeliveriesWeight < 0 || isInvalidNumber(p.FirstMessageDeliveriesWeight) {
-		return fmt.Errorf("invallid FirstMessageDeliveriesWeight; must be positive (or 0 to disable) and a valid number")
+		errs.add("FirstMessageDeliveriesWeight", "must be positive (or 0 to disable) and a valid number")
 	}
 	if p.FirstMessageDeliveriesWeight != 0 && (p.FirstMessageDeliveriesDecay <= 0 || p.FirstMessageDeliveriesDecay >= 1 || isInvalidNumber(p.FirstMessageDeliveriesDecay)) {
-		return fmt.Errorf("invalid FirstMessageDeliveriesDecay; must be between 0 and 1")
+		errs.add("FirstMessageDeliveriesDecay", "must be between 0 and 1")
 	}
 	if p.FirstMessageDeliveriesWeight != 0 && (p.FirstMessageDeliveriesCap <= 0 || isInvalidNumber(p.FirstMessageDeliveriesCap)) {
-		return fmt.Errorf("invalid FirstMessageDeliveriesCap; must be positive and a valid number")
+		errs.add("FirstMessageDeliveriesCap", "must be positive and a valid number")
 	}
-
-	return nil
 }
 
-func (p *TopicScoreParams) validateMeshMessageDeliveryParams() error {
+func (p *TopicScoreParams) validateMeshMessageDeliveryParams(errs *scoreParamErrors) {
 	if p.SkipAtomicValidation {
 		// in non-atomic mode, parameters at their zero values are dismissed from validation.
 		if p.MeshMessageDeliveriesWeight == 0 &&
@@ -325,8 +390,9 @@ func (p *TopicScoreParams) validateMeshMessageDeliveryParams() error {
 			p.MeshMessageDeliveriesDecay == 0 &&
 			p.MeshMessageDeliveriesThreshold == 0 &&
 			p.MeshMessageDeliveriesWindow == 0 &&
-			p.MeshMessageDeliveriesActivation == 0 {
-			return nil
+			p.MeshMessageDeliveriesActivation == 0 &&
+			p.MeshMessageDeliveriesWindowFactor == 0 {
+			return
 		}
 	}
 
@@ -334,32 +400,39 @@ func (p *TopicScoreParams) validateMeshMessageDeliveryParams() error {
 	// hence, proceed with normal validation of all related parameters in this context.
 
 	if p.MeshMessageDeliveriesWeight > 0 || isInvalidNumber(p.MeshMessageDeliveriesWeight) {
-		return fmt.Errorf("invalid MeshMessageDeliveriesWeight; must be negative (or 0 to disable) and a valid number")
+		errs.add("MeshMessageDeliveriesWeight", "must be negative (or 0 to disable) and a valid number")
 	}
 	if p.MeshMessageDeliveriesWeight != 0 && (p.MeshMessageDeliveriesDecay <= 0 || p.MeshMessageDeliveriesDecay >= 1 || isInvalidNumber(p.MeshMessageDeliveriesDecay)) {
-		return fmt.Errorf("invalid MeshMessageDeliveriesDecay; must be between 0 and 1")
+		errs.add("MeshMessageDeliveriesDecay", "must be between 0 and 1")
 	}
 	if p.MeshMessageDeliveriesWeight != 0 && (p.MeshMessageDeliveriesCap <= 0 || isInvalidNumber(p.MeshMessageDeliveriesCap)) {
-		return fmt.Errorf("invalid MeshMessageDeliveriesCap; must be positive and a valid number")
+		errs.add("MeshMessageDeliveriesCap", "must be positive and a valid number")
 	}
 	if p.MeshMessageDeliveriesWeight != 0 && (p.MeshMessageDeliveriesThreshold <= 0 || isInvalidNumber(p.MeshMessageDeliveriesThreshold)) {
-		return fmt.Errorf("invalid MeshMessageDeliveriesThreshold; must be positive and a valid number")
+		errs.add("MeshMessageDeliveriesThreshold", "must be positive and a valid number")
 	}
 	if p.MeshMessageDeliveriesWindow < 0 {
-		return fmt.Errorf("invalid MeshMessageDeliveriesWindow; must be non-negative")
+		errs.add("MeshMessageDeliveriesWindow", "must be non-negative")
 	}
 	if p.MeshMessageDeliveriesWeight != 0 && p.MeshMessageDeliveriesActivation < time.Second {
-		return fmt.Errorf("invalid MeshMessageDeliveriesActivation; must be at least 1s")
+		errs.add("MeshMessageDeliveriesActivation", "must be at least 1s")
+	}
+	if p.MeshMessageDeliveriesWindowFactor < 0 || isInvalidNumber(p.MeshMessageDeliveriesWindowFactor) {
+		errs.add("MeshMessageDeliveriesWindowFactor", "must be non-negative (or 0 to disable) and a valid number")
+	}
+	if p.MeshMessageDeliveriesWindowFactor > 0 && p.MeshMessageDeliveriesWindowMin < 0 {
+		errs.add("MeshMessageDeliveriesWindowMin", "must be non-negative")
+	}
+	if p.MeshMessageDeliveriesWindowFactor > 0 && (p.MeshMessageDeliveriesWindowMax <= 0 || p.MeshMessageDeliveriesWindowMax < p.MeshMessageDeliveriesWindowMin) {
+		errs.add("MeshMessageDeliveriesWindowMax", "must be positive and at least MeshMessageDeliveriesWindowMin")
 	}
-
-	return nil
 }
 
-func (p *TopicScoreParams) validateMessageFailurePenaltyParams() error {
+func (p *TopicScoreParams) validateMessageFailurePenaltyParams(errs *scoreParamErrors) {
 	if p.SkipAtomicValidation {
 		// in selective mode, parameters at their zero values are dismissed from validation.
 		if p.MeshFailurePenaltyDecay == 0 && p.MeshFailurePenaltyWeight == 0 {
-			return nil
+			return
 		}
 	}
 
@@ -367,20 +440,36 @@ func (p *TopicScoreParams) validateMessageFailurePenaltyParams() error {
 	// hence, proceed with normal validation of all related parameters in this context.
 
 	if p.MeshFailurePenaltyWeight > 0 || isInvalidNumber(p.MeshFailurePenaltyWeight) {
-		return fmt.Errorf("invalid MeshFailurePenaltyWeight; must be negative (or 0 to disable) and a valid number")
+		errs.add("MeshFailurePenaltyWeight", "must be negative (or 0 to disable) and a valid number")
 	}
 	if p.MeshFailurePenaltyWeight != 0 && (isInvalidNumber(p.MeshFailurePenaltyDecay) || p.MeshFailurePenaltyDecay <= 0 || p.MeshFailurePenaltyDecay >= 1) {
-		return fmt.Errorf("invalid MeshFailurePenaltyDecay; must be between 0 and 1")
+		errs.add("MeshFailurePenaltyDecay", "must be between 0 and 1")
+	}
+}
+
+func (p *TopicScoreParams) validateDuplicateLatenessParams(errs *scoreParamErrors) {
+	// the late duplicate penalty is optional, so its parameters are dismissed from validation
+	// when the component is disabled, regardless of the validation mode.
+	if p.DuplicateLatenessWeight == 0 {
+		return
 	}
 
-	return nil
+	if p.DuplicateLatenessWeight > 0 || isInvalidNumber(p.DuplicateLatenessWeight) {
+		errs.add("DuplicateLatenessWeight", "must be negative (or 0 to disable) and a valid number")
+	}
+	if p.DuplicateLatenessThreshold <= 0 {
+		errs.add("DuplicateLatenessThreshold", "must be positive")
+	}
+	if p.DuplicateLatenessFirstDeliveriesThreshold < 0 || isInvalidNumber(p.DuplicateLatenessFirstDeliveriesThreshold) {
+		errs.add("DuplicateLatenessFirstDeliveriesThreshold", "must be >= 0 and a valid number")
+	}
 }
 
-func (p *TopicScoreParams) validateInvalidMessageDeliveryParams() error {
+func (p *TopicScoreParams) validateInvalidMessageDeliveryParams(errs *scoreParamErrors) {
 	if p.SkipAtomicValidation {
 		// in selective mode, parameters at their zero values are dismissed from validation.
 		if p.InvalidMessageDeliveriesDecay == 0 && p.InvalidMessageDeliveriesWeight == 0 {
-			return nil
+			return
 		}
 	}
 
@@ -388,13 +477,26 @@ func (p *TopicScoreParams) validateInvalidMessageDeliveryParams() error {
 	// hence, proceed with normal validation of all related parameters in this context.
 
 	if p.InvalidMessageDeliveriesWeight > 0 || isInvalidNumber(p.InvalidMessageDeliveriesWeight) {
-		return fmt.Errorf("invalid InvalidMessageDeliveriesWeight; must be negative (or 0 to disable) and a valid number")
+		errs.add("InvalidMessageDeliveriesWeight", "must be negative (or 0 to disable) and a valid number")
 	}
 	if p.InvalidMessageDeliveriesDecay <= 0 || p.InvalidMessageDeliveriesDecay >= 1 || isInvalidNumber(p.InvalidMessageDeliveriesDecay) {
-		return fmt.Errorf("invalid InvalidMessageDeliveriesDecay; must be between 0 and 1")
+		errs.add("InvalidMessageDeliveriesDecay", "must be between 0 and 1")
+	}
+}
+
+func (p *TopicScoreParams) validateIgnoredMessageDeliveryParams(errs *scoreParamErrors) {
+	// the parameter is opt-in, so it is dismissed from validation at its zero values even in
+	// atomic validation mode.
+	if p.IgnoredMessageDeliveriesDecay == 0 && p.IgnoredMessageDeliveriesWeight == 0 {
+		return
 	}
 
-	return nil
+	if p.IgnoredMessageDeliveriesWeight > 0 || isInvalidNumber(p.IgnoredMessageDeliveriesWeight) {
+		errs.add("IgnoredMessageDeliveriesWeight", "must be negative (or 0 to disable) and a valid number")
+	}
+	if p.IgnoredMessageDeliveriesDecay <= 0 || p.IgnoredMessageDeliveriesDecay >= 1 || isInvalidNumber(p.IgnoredMessageDeliveriesDecay) {
+		errs.add("IgnoredMessageDeliveriesDecay", "must be between 0 and 1")
+	}
 }
 
 const (
@@ -0,0 +1,174 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+// The score parameters and thresholds are serialized as JSON objects keyed by the Go field names,
+// with durations written as strings in time.Duration format (e.g. "1m30s") and the IP colocation
+// whitelist as a list of CIDR strings. The AppSpecificScore function cannot be serialized; it is
+// omitted when marshalling, and must be set after unmarshalling (or SkipAtomicValidation must be
+// used for it to default to 0).
+// Unmarshalling rejects unknown fields, to catch misspelled parameters.
+
+// jsonDuration is a time.Duration that is serialized as a string; for convenience, it can also be
+// unmarshalled from a number of nanoseconds.
+type jsonDuration time.Duration
+
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case string:
+		dur, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = jsonDuration(dur)
+	case float64:
+		*d = jsonDuration(v)
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+
+	return nil
+}
+
+// unmarshalStrict decodes data into v, rejecting unknown fields.
+func unmarshalStrict(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+type peerScoreThresholdsAlias PeerScoreThresholds
+
+func (p PeerScoreThresholds) MarshalJSON() ([]byte, error) {
+	return json.Marshal(peerScoreThresholdsAlias(p))
+}
+
+func (p *PeerScoreThresholds) UnmarshalJSON(data []byte) error {
+	return unmarshalStrict(data, (*peerScoreThresholdsAlias)(p))
+}
+
+type peerScoreParamsAlias PeerScoreParams
+
+// peerScoreParamsJSON shadows the fields of PeerScoreParams that need a different representation.
+type peerScoreParamsJSON struct {
+	*peerScoreParamsAlias
+
+	// AppSpecificScore hides the function, which is never serialized.
+	AppSpecificScore *struct{} `json:",omitempty"`
+
+	IPColocationFactorWhitelist []string `json:",omitempty"`
+	DecayInterval               jsonDuration
+	RetainScore                 jsonDuration
+	SeenMsgTTL                  jsonDuration
+}
+
+func (p PeerScoreParams) MarshalJSON() ([]byte, error) {
+	v := peerScoreParamsJSON{
+		peerScoreParamsAlias: (*peerScoreParamsAlias)(&p),
+		DecayInterval:        jsonDuration(p.DecayInterval),
+		RetainScore:          jsonDuration(p.RetainScore),
+		SeenMsgTTL:           jsonDuration(p.SeenMsgTTL),
+	}
+	for _, ipnet := range p.IPColocationFactorWhitelist {
+		v.IPColocationFactorWhitelist = append(v.IPColocationFactorWhitelist, ipnet.String())
+	}
+
+	return json.Marshal(v)
+}
+
+func (p *PeerScoreParams) UnmarshalJSON(data []byte) error {
+	// fields missing from the input keep their values
+	v := peerScoreParamsJSON{
+		peerScoreParamsAlias: (*peerScoreParamsAlias)(p),
+		DecayInterval:        jsonDuration(p.DecayInterval),
+		RetainScore:          jsonDuration(p.RetainScore),
+		SeenMsgTTL:           jsonDuration(p.SeenMsgTTL),
+	}
+	for _, ipnet := range p.IPColocationFactorWhitelist {
+		v.IPColocationFactorWhitelist = append(v.IPColocationFactorWhitelist, ipnet.String())
+	}
+	if err := unmarshalStrict(data, &v); err != nil {
+		return err
+	}
+
+	p.DecayInterval = time.Duration(v.DecayInterval)
+	p.RetainScore = time.Duration(v.RetainScore)
+	p.SeenMsgTTL = time.Duration(v.SeenMsgTTL)
+
+	p.IPColocationFactorWhitelist = nil
+	for _, cidr := range v.IPColocationFactorWhitelist {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid IPColocationFactorWhitelist entry: %w", err)
+		}
+		p.IPColocationFactorWhitelist = append(p.IPColocationFactorWhitelist, ipnet)
+	}
+
+	return nil
+}
+
+type topicScoreParamsAlias TopicScoreParams
+
+// topicScoreParamsJSON shadows the durations of TopicScoreParams.
+type topicScoreParamsJSON struct {
+	*topicScoreParamsAlias
+
+	TimeInMeshQuantum               jsonDuration
+	MeshMessageDeliveriesWindow     jsonDuration
+	MeshMessageDeliveriesActivation jsonDuration
+	MeshMessageDeliveriesWindowMin  jsonDuration
+	MeshMessageDeliveriesWindowMax  jsonDuration
+	DuplicateLatenessThreshold      jsonDuration
+}
+
+func (p TopicScoreParams) MarshalJSON() ([]byte, error) {
+	return json.Marshal(topicScoreParamsJSON{
+		topicScoreParamsAlias:           (*topicScoreParamsAlias)(&p),
+		TimeInMeshQuantum:               jsonDuration(p.TimeInMeshQuantum),
+		MeshMessageDeliveriesWindow:     jsonDuration(p.MeshMessageDeliveriesWindow),
+		MeshMessageDeliveriesActivation: jsonDuration(p.MeshMessageDeliveriesActivation),
+		MeshMessageDeliveriesWindowMin:  jsonDuration(p.MeshMessageDeliveriesWindowMin),
+		MeshMessageDeliveriesWindowMax:  jsonDuration(p.MeshMessageDeliveriesWindowMax),
+		DuplicateLatenessThreshold:      jsonDuration(p.DuplicateLatenessThreshold),
+	})
+}
+
+func (p *TopicScoreParams) UnmarshalJSON(data []byte) error {
+	// fields missing from the input keep their values
+	v := topicScoreParamsJSON{
+		topicScoreParamsAlias:           (*topicScoreParamsAlias)(p),
+		TimeInMeshQuantum:               jsonDuration(p.TimeInMeshQuantum),
+		MeshMessageDeliveriesWindow:     jsonDuration(p.MeshMessageDeliveriesWindow),
+		MeshMessageDeliveriesActivation: jsonDuration(p.MeshMessageDeliveriesActivation),
+		MeshMessageDeliveriesWindowMin:  jsonDuration(p.MeshMessageDeliveriesWindowMin),
+		MeshMessageDeliveriesWindowMax:  jsonDuration(p.MeshMessageDeliveriesWindowMax),
+		DuplicateLatenessThreshold:      jsonDuration(p.DuplicateLatenessThreshold),
+	}
+	if err := unmarshalStrict(data, &v); err != nil {
+		return err
+	}
+
+	p.TimeInMeshQuantum = time.Duration(v.TimeInMeshQuantum)
+	p.MeshMessageDeliveriesWindow = time.Duration(v.MeshMessageDeliveriesWindow)
+	p.MeshMessageDeliveriesActivation = time.Duration(v.MeshMessageDeliveriesActivation)
+	p.MeshMessageDeliveriesWindowMin = time.Duration(v.MeshMessageDeliveriesWindowMin)
+	p.MeshMessageDeliveriesWindowMax = time.Duration(v.MeshMessageDeliveriesWindowMax)
+	p.DuplicateLatenessThreshold = time.Duration(v.DuplicateLatenessThreshold)
+
+	return nil
+}
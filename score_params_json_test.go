@@ -0,0 +1,132 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestScoreParamsJSONRoundTrip(t *testing.T) {
+	_, ipnet, err := net.ParseCIDR("192.168.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := &PeerScoreParams{
+		Topics: map[string]*TopicScoreParams{
+			"foobar": {
+				TopicWeight:                     0.5,
+				TimeInMeshWeight:                0.01,
+				TimeInMeshQuantum:               time.Second,
+				TimeInMeshCap:                   3600,
+				FirstMessageDeliveriesWeight:    1,
+				FirstMessageDeliveriesDecay:     0.5,
+				FirstMessageDeliveriesCap:       100,
+				MeshMessageDeliveriesWeight:     -1,
+				MeshMessageDeliveriesDecay:      0.5,
+				MeshMessageDeliveriesCap:        100,
+				MeshMessageDeliveriesThreshold:  10,
+				MeshMessageDeliveriesWindow:     10 * time.Millisecond,
+				MeshMessageDeliveriesActivation: 90 * time.Second,
+				MeshFailurePenaltyWeight:        -1,
+				MeshFailurePenaltyDecay:         0.5,
+				DuplicateLatenessWeight:         -1,
+				DuplicateLatenessThreshold:      500 * time.Millisecond,
+				InvalidMessageDeliveriesWeight:  -1,
+				InvalidMessageDeliveriesDecay:   0.3,
+			},
+		},
+		TopicScoreCap:               100,
+		AppSpecificWeight:           1,
+		IPColocationFactorWeight:    -1,
+		IPColocationFactorThreshold: 1,
+		IPColocationFactorWhitelist: []*net.IPNet{ipnet},
+		BehaviourPenaltyWeight:      -1,
+		BehaviourPenaltyThreshold:   6,
+		BehaviourPenaltyDecay:       0.99,
+		DecayInterval:               time.Second,
+		DecayToZero:                 0.01,
+		RetainScore:                 time.Hour,
+		SeenMsgTTL:                  2 * time.Minute,
+		AppSpecificScore:            func(peer.ID) float64 { return 0 },
+	}
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{`"RetainScore":"1h0m0s"`, `"MeshMessageDeliveriesWindow":"10ms"`, `"IPColocationFactorWhitelist":["192.168.0.0/16"]`} {
+		if !strings.Contains(string(data), s) {
+			t.Fatalf("expected %s in the serialized params: %s", s, data)
+		}
+	}
+	if strings.Contains(string(data), "AppSpecificScore") {
+		t.Fatalf("expected the app specific score to be omitted: %s", data)
+	}
+
+	var decoded PeerScoreParams
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.Validate(); err == nil {
+		t.Fatal("expected validation to fail without an app specific score")
+	}
+
+	params.AppSpecificScore = nil
+	if !reflect.DeepEqual(params, &decoded) {
+		t.Fatalf("expected round trip to preserve the params: %+v != %+v", params, &decoded)
+	}
+
+	thresholds := &PeerScoreThresholds{
+		GossipThreshold:             -10,
+		PublishThreshold:            -100,
+		GraylistThreshold:           -1000,
+		AcceptPXThreshold:           10,
+		OpportunisticGraftThreshold: 1,
+	}
+	data, err = json.Marshal(thresholds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decodedThresholds PeerScoreThresholds
+	if err := json.Unmarshal(data, &decodedThresholds); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(thresholds, &decodedThresholds) {
+		t.Fatalf("expected round trip to preserve the thresholds: %+v != %+v", thresholds, &decodedThresholds)
+	}
+}
+
+func TestScoreParamsJSONUnmarshal(t *testing.T) {
+	var params TopicScoreParams
+	err := json.Unmarshal([]byte(`{"TopicWeight": 1, "TimeInMeshQuantum": "1s", "MeshMessageDeliveriesActivation": 1000000000}`), &params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.TopicWeight != 1 || params.TimeInMeshQuantum != time.Second || params.MeshMessageDeliveriesActivation != time.Second {
+		t.Fatalf("unexpected params %+v", params)
+	}
+
+	if err := json.Unmarshal([]byte(`{"TimeInMeshQuantum": "1 second"}`), &params); err == nil {
+		t.Fatal("expected an invalid duration to fail")
+	}
+	if err := json.Unmarshal([]byte(`{"TopicWieght": 1}`), &params); err == nil {
+		t.Fatal("expected an unknown field to fail")
+	}
+	if err := json.Unmarshal([]byte(`{"Topics": {"foobar": {"TopicWieght": 1}}}`), &PeerScoreParams{}); err == nil {
+		t.Fatal("expected an unknown topic field to fail")
+	}
+	if err := json.Unmarshal([]byte(`{"IPColocationFactorWhitelist": ["192.168.0.0"]}`), &PeerScoreParams{}); err == nil {
+		t.Fatal("expected an invalid CIDR to fail")
+	}
+	if err := json.Unmarshal([]byte(`{"GossipThresold": -1}`), &PeerScoreThresholds{}); err == nil {
+		t.Fatal("expected an unknown threshold to fail")
+	}
+}
@@ -1,7 +1,10 @@
 package pubsub
 
 import (
+	"errors"
 	"math"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -20,13 +23,13 @@ func testPeerScoreThresholdsValidation(t *testing.T, skipAtomicValidation bool)
 	if (&PeerScoreThresholds{
 		SkipAtomicValidation: skipAtomicValidation,
 		GossipThreshold:      1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&PeerScoreThresholds{
 		SkipAtomicValidation: skipAtomicValidation,
 		PublishThreshold:     1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 
@@ -34,7 +37,7 @@ func testPeerScoreThresholdsValidation(t *testing.T, skipAtomicValidation bool)
 		SkipAtomicValidation: skipAtomicValidation,
 		GossipThreshold:      -1,
 		PublishThreshold:     0,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&PeerScoreThresholds{
@@ -42,19 +45,19 @@ func testPeerScoreThresholdsValidation(t *testing.T, skipAtomicValidation bool)
 		GossipThreshold:      -1,
 		PublishThreshold:     -2,
 		GraylistThreshold:    0,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&PeerScoreThresholds{
 		SkipAtomicValidation: skipAtomicValidation,
 		AcceptPXThreshold:    -1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&PeerScoreThresholds{
 		SkipAtomicValidation:        skipAtomicValidation,
 		OpportunisticGraftThreshold: -1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&PeerScoreThresholds{
@@ -63,7 +66,7 @@ func testPeerScoreThresholdsValidation(t *testing.T, skipAtomicValidation bool)
 		PublishThreshold:            -2,
 		GraylistThreshold:           -3,
 		AcceptPXThreshold:           1,
-		OpportunisticGraftThreshold: 2}).validate() != nil {
+		OpportunisticGraftThreshold: 2}).Validate() != nil {
 		t.Fatal("expected validation success")
 	}
 	if (&PeerScoreThresholds{
@@ -73,7 +76,7 @@ func testPeerScoreThresholdsValidation(t *testing.T, skipAtomicValidation bool)
 		GraylistThreshold:           -3,
 		AcceptPXThreshold:           1,
 		OpportunisticGraftThreshold: 2,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&PeerScoreThresholds{
@@ -83,7 +86,7 @@ func testPeerScoreThresholdsValidation(t *testing.T, skipAtomicValidation bool)
 		GraylistThreshold:           -3,
 		AcceptPXThreshold:           1,
 		OpportunisticGraftThreshold: 2,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&PeerScoreThresholds{
@@ -93,7 +96,7 @@ func testPeerScoreThresholdsValidation(t *testing.T, skipAtomicValidation bool)
 		GraylistThreshold:           math.Inf(-1),
 		AcceptPXThreshold:           1,
 		OpportunisticGraftThreshold: 2,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&PeerScoreThresholds{
@@ -103,7 +106,7 @@ func testPeerScoreThresholdsValidation(t *testing.T, skipAtomicValidation bool)
 		GraylistThreshold:           -3,
 		AcceptPXThreshold:           math.NaN(),
 		OpportunisticGraftThreshold: 2,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&PeerScoreThresholds{
@@ -113,7 +116,7 @@ func testPeerScoreThresholdsValidation(t *testing.T, skipAtomicValidation bool)
 		GraylistThreshold:           -3,
 		AcceptPXThreshold:           1,
 		OpportunisticGraftThreshold: math.Inf(0),
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 }
@@ -130,11 +133,11 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 
 	if skipAtomicValidation {
 		if (&TopicScoreParams{
-			SkipAtomicValidation: true}).validate() != nil {
+			SkipAtomicValidation: true}).Validate() != nil {
 			t.Fatal("expected validation success")
 		}
 	} else {
-		if (&TopicScoreParams{}).validate() == nil {
+		if (&TopicScoreParams{}).Validate() == nil {
 			t.Fatal("expected validation failure")
 		}
 	}
@@ -142,7 +145,7 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 	if (&TopicScoreParams{
 		SkipAtomicValidation: skipAtomicValidation,
 		TopicWeight:          -1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 
@@ -150,14 +153,14 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		SkipAtomicValidation: skipAtomicValidation,
 		TimeInMeshWeight:     -1,
 		TimeInMeshQuantum:    time.Second,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&TopicScoreParams{
 		SkipAtomicValidation: skipAtomicValidation,
 		TimeInMeshWeight:     1,
 		TimeInMeshQuantum:    -1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&TopicScoreParams{
@@ -165,7 +168,7 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		TimeInMeshWeight:     1,
 		TimeInMeshQuantum:    time.Second,
 		TimeInMeshCap:        -1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 
@@ -173,7 +176,7 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		SkipAtomicValidation:         skipAtomicValidation,
 		TimeInMeshQuantum:            time.Second,
 		FirstMessageDeliveriesWeight: -1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&TopicScoreParams{
@@ -181,7 +184,7 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		TimeInMeshQuantum:            time.Second,
 		FirstMessageDeliveriesWeight: 1,
 		FirstMessageDeliveriesDecay:  -1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&TopicScoreParams{
@@ -189,7 +192,7 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		TimeInMeshQuantum:            time.Second,
 		FirstMessageDeliveriesWeight: 1,
 		FirstMessageDeliveriesDecay:  2,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&TopicScoreParams{
@@ -198,7 +201,7 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		FirstMessageDeliveriesWeight: 1,
 		FirstMessageDeliveriesDecay:  .5,
 		FirstMessageDeliveriesCap:    -1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 
@@ -206,7 +209,7 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		SkipAtomicValidation:        skipAtomicValidation,
 		TimeInMeshQuantum:           time.Second,
 		MeshMessageDeliveriesWeight: 1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&TopicScoreParams{
@@ -214,14 +217,14 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		TimeInMeshQuantum:           time.Second,
 		MeshMessageDeliveriesWeight: -1,
 		MeshMessageDeliveriesDecay:  -1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&TopicScoreParams{
 		SkipAtomicValidation:        skipAtomicValidation,
 		TimeInMeshQuantum:           time.Second,
 		MeshMessageDeliveriesWeight: -1,
-		MeshMessageDeliveriesDecay:  2}).validate() == nil {
+		MeshMessageDeliveriesDecay:  2}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&TopicScoreParams{
@@ -230,7 +233,7 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		MeshMessageDeliveriesWeight: -1,
 		MeshMessageDeliveriesDecay:  .5,
 		MeshMessageDeliveriesCap:    -1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&TopicScoreParams{
@@ -240,7 +243,7 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		MeshMessageDeliveriesDecay:     .5,
 		MeshMessageDeliveriesCap:       5,
 		MeshMessageDeliveriesThreshold: -3,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&TopicScoreParams{
@@ -251,7 +254,7 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		MeshMessageDeliveriesCap:       5,
 		MeshMessageDeliveriesThreshold: 3,
 		MeshMessageDeliveriesWindow:    -1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&TopicScoreParams{
@@ -262,7 +265,23 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		MeshMessageDeliveriesCap:        5,
 		MeshMessageDeliveriesThreshold:  3,
 		MeshMessageDeliveriesWindow:     time.Millisecond,
-		MeshMessageDeliveriesActivation: time.Millisecond}).validate() == nil {
+		MeshMessageDeliveriesActivation: time.Millisecond}).Validate() == nil {
+		t.Fatal("expected validation error")
+	}
+	if (&TopicScoreParams{
+		SkipAtomicValidation:              skipAtomicValidation,
+		TimeInMeshQuantum:                 time.Second,
+		MeshMessageDeliveriesWindowFactor: -1,
+	}).Validate() == nil {
+		t.Fatal("expected validation error")
+	}
+	if (&TopicScoreParams{
+		SkipAtomicValidation:              skipAtomicValidation,
+		TimeInMeshQuantum:                 time.Second,
+		MeshMessageDeliveriesWindowFactor: 2,
+		MeshMessageDeliveriesWindowMin:    time.Second,
+		MeshMessageDeliveriesWindowMax:    time.Millisecond,
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 
@@ -270,7 +289,7 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		SkipAtomicValidation:     skipAtomicValidation,
 		TimeInMeshQuantum:        time.Second,
 		MeshFailurePenaltyWeight: 1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&TopicScoreParams{
@@ -278,7 +297,7 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		TimeInMeshQuantum:        time.Second,
 		MeshFailurePenaltyWeight: -1,
 		MeshFailurePenaltyDecay:  -1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&TopicScoreParams{
@@ -286,7 +305,32 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		TimeInMeshQuantum:        time.Second,
 		MeshFailurePenaltyWeight: -1,
 		MeshFailurePenaltyDecay:  2,
-	}).validate() == nil {
+	}).Validate() == nil {
+		t.Fatal("expected validation error")
+	}
+
+	if (&TopicScoreParams{
+		SkipAtomicValidation:       skipAtomicValidation,
+		TimeInMeshQuantum:          time.Second,
+		DuplicateLatenessWeight:    1,
+		DuplicateLatenessThreshold: time.Second,
+	}).Validate() == nil {
+		t.Fatal("expected validation error")
+	}
+	if (&TopicScoreParams{
+		SkipAtomicValidation:    skipAtomicValidation,
+		TimeInMeshQuantum:       time.Second,
+		DuplicateLatenessWeight: -1,
+	}).Validate() == nil {
+		t.Fatal("expected validation error")
+	}
+	if (&TopicScoreParams{
+		SkipAtomicValidation:                      skipAtomicValidation,
+		TimeInMeshQuantum:                         time.Second,
+		DuplicateLatenessWeight:                   -1,
+		DuplicateLatenessThreshold:                time.Second,
+		DuplicateLatenessFirstDeliveriesThreshold: -1,
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 
@@ -294,7 +338,7 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		SkipAtomicValidation:           skipAtomicValidation,
 		TimeInMeshQuantum:              time.Second,
 		InvalidMessageDeliveriesWeight: 1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&TopicScoreParams{
@@ -302,7 +346,7 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		TimeInMeshQuantum:              time.Second,
 		InvalidMessageDeliveriesWeight: -1,
 		InvalidMessageDeliveriesDecay:  -1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&TopicScoreParams{
@@ -310,7 +354,22 @@ func testTopicScoreParamsValidationWithInvalidParameters(t *testing.T, skipAtomi
 		TimeInMeshQuantum:              time.Second,
 		InvalidMessageDeliveriesWeight: -1,
 		InvalidMessageDeliveriesDecay:  2,
-	}).validate() == nil {
+	}).Validate() == nil {
+		t.Fatal("expected validation error")
+	}
+	if (&TopicScoreParams{
+		SkipAtomicValidation:           skipAtomicValidation,
+		TimeInMeshQuantum:              time.Second,
+		IgnoredMessageDeliveriesWeight: 1,
+	}).Validate() == nil {
+		t.Fatal("expected validation error")
+	}
+	if (&TopicScoreParams{
+		SkipAtomicValidation:           skipAtomicValidation,
+		TimeInMeshQuantum:              time.Second,
+		IgnoredMessageDeliveriesWeight: -1,
+		IgnoredMessageDeliveriesDecay:  2,
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 }
@@ -336,7 +395,7 @@ func TestTopicScoreParamsValidation_ValidParams_AtomicValidation(t *testing.T) {
 		MeshFailurePenaltyDecay:         0.5,
 		InvalidMessageDeliveriesWeight:  -1,
 		InvalidMessageDeliveriesDecay:   0.5,
-	}).validate() != nil {
+	}).Validate() != nil {
 		t.Fatal("expected validation success")
 	}
 }
@@ -403,7 +462,7 @@ func testPeerScoreParamsValidationWithInvalidParams(t *testing.T, skipAtomicVali
 		AppSpecificScore:     appScore,
 		DecayInterval:        time.Second,
 		DecayToZero:          0.01,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 
@@ -413,7 +472,7 @@ func testPeerScoreParamsValidationWithInvalidParams(t *testing.T, skipAtomicVali
 			TopicScoreCap:        1,
 			DecayInterval:        time.Second,
 			DecayToZero:          0.01,
-		}).validate() != nil {
+		}).Validate() != nil {
 			t.Fatal("expected validation success")
 		}
 	} else {
@@ -422,7 +481,7 @@ func testPeerScoreParamsValidationWithInvalidParams(t *testing.T, skipAtomicVali
 			TopicScoreCap:        1,
 			DecayInterval:        time.Second,
 			DecayToZero:          0.01,
-		}).validate() == nil {
+		}).Validate() == nil {
 			t.Fatal("expected validation error")
 		}
 	}
@@ -434,7 +493,7 @@ func testPeerScoreParamsValidationWithInvalidParams(t *testing.T, skipAtomicVali
 		DecayInterval:            time.Second,
 		DecayToZero:              0.01,
 		IPColocationFactorWeight: 1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&PeerScoreParams{
@@ -444,7 +503,7 @@ func testPeerScoreParamsValidationWithInvalidParams(t *testing.T, skipAtomicVali
 		DecayInterval:               time.Second,
 		DecayToZero:                 0.01,
 		IPColocationFactorWeight:    -1,
-		IPColocationFactorThreshold: -1}).validate() == nil {
+		IPColocationFactorThreshold: -1}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&PeerScoreParams{
@@ -455,7 +514,7 @@ func testPeerScoreParamsValidationWithInvalidParams(t *testing.T, skipAtomicVali
 		DecayToZero:                 0.01,
 		IPColocationFactorWeight:    -1,
 		IPColocationFactorThreshold: 1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&PeerScoreParams{
@@ -466,7 +525,7 @@ func testPeerScoreParamsValidationWithInvalidParams(t *testing.T, skipAtomicVali
 		DecayToZero:                 -1,
 		IPColocationFactorWeight:    -1,
 		IPColocationFactorThreshold: 1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&PeerScoreParams{
@@ -477,7 +536,7 @@ func testPeerScoreParamsValidationWithInvalidParams(t *testing.T, skipAtomicVali
 		DecayToZero:                 2,
 		IPColocationFactorWeight:    -1,
 		IPColocationFactorThreshold: 1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&PeerScoreParams{
@@ -485,7 +544,7 @@ func testPeerScoreParamsValidationWithInvalidParams(t *testing.T, skipAtomicVali
 		AppSpecificScore:       appScore,
 		DecayInterval:          time.Second,
 		DecayToZero:            0.01,
-		BehaviourPenaltyWeight: 1}).validate() == nil {
+		BehaviourPenaltyWeight: 1}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&PeerScoreParams{
@@ -494,7 +553,7 @@ func testPeerScoreParamsValidationWithInvalidParams(t *testing.T, skipAtomicVali
 		DecayInterval:          time.Second,
 		DecayToZero:            0.01,
 		BehaviourPenaltyWeight: -1,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 	if (&PeerScoreParams{
@@ -504,7 +563,7 @@ func testPeerScoreParamsValidationWithInvalidParams(t *testing.T, skipAtomicVali
 		DecayToZero:            0.01,
 		BehaviourPenaltyWeight: -1,
 		BehaviourPenaltyDecay:  2,
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation error")
 	}
 
@@ -539,7 +598,7 @@ func testPeerScoreParamsValidationWithInvalidParams(t *testing.T, skipAtomicVali
 				InvalidMessageDeliveriesDecay:   math.NaN(),
 			},
 		},
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation failure")
 	}
 
@@ -552,7 +611,7 @@ func testPeerScoreParamsValidationWithInvalidParams(t *testing.T, skipAtomicVali
 		IPColocationFactorThreshold: 1,
 		BehaviourPenaltyWeight:      math.Inf(0),
 		BehaviourPenaltyDecay:       math.NaN(),
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation failure")
 	}
 
@@ -585,7 +644,7 @@ func testPeerScoreParamsValidationWithInvalidParams(t *testing.T, skipAtomicVali
 				InvalidMessageDeliveriesDecay:   0.5,
 			},
 		},
-	}).validate() == nil {
+	}).Validate() == nil {
 		t.Fatal("expected validation failure")
 	}
 }
@@ -602,7 +661,7 @@ func TestPeerScoreParamsValidation_ValidParams_AtomicValidation(t *testing.T) {
 		IPColocationFactorThreshold: 1,
 		BehaviourPenaltyWeight:      -1,
 		BehaviourPenaltyDecay:       0.999,
-	}).validate() != nil {
+	}).Validate() != nil {
 		t.Fatal("expected validation success")
 	}
 
@@ -615,7 +674,7 @@ func TestPeerScoreParamsValidation_ValidParams_AtomicValidation(t *testing.T) {
 		IPColocationFactorThreshold: 1,
 		BehaviourPenaltyWeight:      -1,
 		BehaviourPenaltyDecay:       0.999,
-	}).validate() != nil {
+	}).Validate() != nil {
 		t.Fatal("expected validation success")
 	}
 
@@ -647,7 +706,7 @@ func TestPeerScoreParamsValidation_ValidParams_AtomicValidation(t *testing.T) {
 				InvalidMessageDeliveriesDecay:   0.5,
 			},
 		},
-	}).validate() != nil {
+	}).Validate() != nil {
 		t.Fatal("expected validation success")
 	}
 }
@@ -726,14 +785,59 @@ func TestScoreParameterDecay(t *testing.T) {
 
 func setParamAndValidate(t *testing.T, params *PeerScoreParams, set func(*PeerScoreParams)) {
 	set(params)
-	if err := params.validate(); err != nil {
+	if err := params.Validate(); err != nil {
 		t.Fatalf("expected validation success, got: %s", err)
 	}
 }
 
 func setTopicParamAndValidate(t *testing.T, params *TopicScoreParams, set func(topic *TopicScoreParams)) {
 	set(params)
-	if err := params.validate(); err != nil {
+	if err := params.Validate(); err != nil {
 		t.Fatalf("expected validation success, got: %s", err)
 	}
 }
+
+func TestScoreParamsValidateAllViolations(t *testing.T) {
+	params := &PeerScoreParams{
+		Topics: map[string]*TopicScoreParams{
+			"foo": {TopicWeight: -1, InvalidMessageDeliveriesDecay: 0.5, TimeInMeshQuantum: time.Second},
+			"bar": {TopicWeight: 1, InvalidMessageDeliveriesDecay: 2, TimeInMeshQuantum: time.Second},
+		},
+		AppSpecificScore: func(peer.ID) float64 { return 0 },
+		TopicScoreCap:    -1,
+		DecayInterval:    time.Millisecond,
+		DecayToZero:      0.01,
+	}
+
+	err := params.Validate()
+	if err == nil {
+		t.Fatal("expected validation to fail")
+	}
+
+	var fields []string
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var perr *ScoreParamError
+		if !errors.As(err, &perr) {
+			t.Fatalf("unexpected error type %T", err)
+		}
+		fields = append(fields, perr.Field)
+	}
+
+	expected := []string{
+		"Topics[bar].InvalidMessageDeliveriesDecay",
+		"Topics[foo].TopicWeight",
+		"TopicScoreCap",
+		"DecayInterval",
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("expected violations %v, but got %v", expected, fields)
+	}
+
+	thresholds := &PeerScoreThresholds{GossipThreshold: 1, PublishThreshold: 1, GraylistThreshold: -1}
+	err = errors.Join(params.Validate(), thresholds.Validate())
+	for _, field := range append(expected, "GossipThreshold", "PublishThreshold") {
+		if !strings.Contains(err.Error(), "invalid "+field+";") {
+			t.Fatalf("expected %s to be reported in %q", field, err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package pubsub
+
+import (
+	"fmt"
+)
+
+// TopicScoreParamsResolver returns the score parameters for a topic that is not configured in
+// PeerScoreParams.Topics, or nil if the topic should not be scored.
+type TopicScoreParamsResolver func(topic string) *TopicScoreParams
+
+// WithTopicScoreParamsResolver is a gossipsub router option that resolves the score parameters of
+// topics that are not known in advance, such as topics following a naming pattern. The resolver
+// is consulted the first time a topic is observed, when we join it or a peer subscribes to it,
+// and the result is cached until InvalidateTopicScoreParams is called.
+//
+// The resolver is invoked from the event loop, outside the score lock, so it should be cheap;
+// it is called for every distinct topic our peers subscribe to, and must not call back into
+// PubSub. Resolved parameters are validated, and they can be shared between topics, each of which
+// still has its own counters. Topics configured in PeerScoreParams.Topics or through
+// Topic.SetScoreParams take precedence over the resolver.
+//
+// This option has no effect unless peer scoring is enabled with WithPeerScore.
+func WithTopicScoreParamsResolver(resolver TopicScoreParamsResolver) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			return fmt.Errorf("pubsub router is not gossipsub")
+		}
+
+		gs.scoreResolver = resolver
+		return nil
+	}
+}
+
+// InvalidateTopicScoreParams discards the cached results of the topic score parameters resolver,
+// for instance after the patterns it matches have changed; the topics we know of are resolved
+// again right away, and stop being scored if the resolver no longer returns parameters for them.
+func (p *PubSub) InvalidateTopicScoreParams() error {
+	result := make(chan error, 1)
+	select {
+	case p.eval <- func() {
+		gs, ok := p.rt.(*GossipSubRouter)
+		if !ok {
+			result <- fmt.Errorf("pubsub router is not gossipsub")
+			return
+		}
+
+		if gs.score == nil || gs.scoreResolver == nil {
+			result <- fmt.Errorf("no topic score parameters resolver")
+			return
+		}
+
+		resolved := gs.resolvedTopics
+		gs.resolvedTopics = make(map[string]bool)
+
+		for topic, scored := range resolved {
+			if !gs.applyTopicScoreParamsResolver(topic) && scored {
+				gs.score.removeTopicScoreParams(topic)
+			}
+		}
+		result <- nil
+	}:
+		return <-result
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+// resolveTopicScoreParams resolves the score parameters of a newly observed topic, returning
+// whether the topic is scored with resolved parameters.
+func (gs *GossipSubRouter) resolveTopicScoreParams(topic string) bool {
+	if gs.score == nil || gs.scoreResolver == nil {
+		return false
+	}
+
+	if scored, ok := gs.resolvedTopics[topic]; ok {
+		return scored
+	}
+	if gs.score.hasTopicScoreParams(topic) {
+		// explicitly configured
+		return false
+	}
+
+	return gs.applyTopicScoreParamsResolver(topic)
+}
+
+// applyTopicScoreParamsResolver invokes the resolver for a topic and caches the result.
+func (gs *GossipSubRouter) applyTopicScoreParamsResolver(topic string) bool {
+	params := gs.scoreResolver(topic)
+	if params != nil {
+		if err := params.Validate(); err != nil {
+			gs.p.logger.Warnw("invalid resolved score parameters", "topic", topic, "err", err)
+			params = nil
+		}
+	}
+
+	gs.resolvedTopics[topic] = params != nil
+	if params == nil {
+		return false
+	}
+
+	gs.score.SetTopicScoreParams(topic, params)
+	return true
+}
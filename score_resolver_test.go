@@ -0,0 +1,158 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestTopicScoreParamsResolver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tparams := &TopicScoreParams{
+		TopicWeight:                    1,
+		TimeInMeshQuantum:              time.Second,
+		FirstMessageDeliveriesWeight:   1,
+		FirstMessageDeliveriesDecay:    0.999,
+		FirstMessageDeliveriesCap:      100,
+		InvalidMessageDeliveriesWeight: -1,
+		InvalidMessageDeliveriesDecay:  0.9999,
+	}
+
+	// resolves shard/*/blocks, or only the shards in the set after the patterns change
+	var mx sync.Mutex
+	calls := make(map[string]int)
+	var shards map[string]bool
+	resolver := func(topic string) *TopicScoreParams {
+		mx.Lock()
+		defer mx.Unlock()
+
+		calls[topic]++
+		parts := strings.Split(topic, "/")
+		if len(parts) != 3 || parts[0] != "shard" || parts[2] != "blocks" {
+			return nil
+		}
+		if shards != nil && !shards[parts[1]] {
+			return nil
+		}
+		return tparams
+	}
+
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0],
+			WithPeerScore(
+				&PeerScoreParams{
+					AppSpecificScore: func(peer.ID) float64 { return 0 },
+					// no decay during the test
+					DecayInterval: time.Hour,
+					DecayToZero:   0.01,
+				},
+				&PeerScoreThresholds{
+					GossipThreshold:   -1,
+					PublishThreshold:  -10,
+					GraylistThreshold: -1000,
+				}),
+			WithTopicScoreParamsResolver(resolver)),
+		getGossipsub(ctx, hosts[1]),
+	}
+
+	topics := []string{"shard/1/blocks", "shard/2/blocks", "other"}
+	for _, ps := range psubs {
+		for _, topic := range topics {
+			if _, err := ps.Subscribe(topic); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	// 3 messages in the first shard and 1 in the second
+	for i, topic := range []string{"shard/1/blocks", "shard/1/blocks", "shard/1/blocks", "shard/2/blocks"} {
+		if err := psubs[1].Publish(topic, []byte(fmt.Sprintf("message %d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	check := func(fn func(gs *GossipSubRouter)) {
+		done := make(chan struct{})
+		psubs[0].eval <- func() {
+			defer close(done)
+			fn(psubs[0].rt.(*GossipSubRouter))
+		}
+		<-done
+	}
+
+	check(func(gs *GossipSubRouter) {
+		gs.score.Lock()
+		defer gs.score.Unlock()
+
+		for _, topic := range topics[:2] {
+			if gs.score.params.Topics[topic] != tparams {
+				t.Fatalf("expected the resolved parameters for %s", topic)
+			}
+		}
+		if _, ok := gs.score.params.Topics["other"]; ok {
+			t.Fatal("expected no parameters for an unmatched topic")
+		}
+
+		tstats := gs.score.peerStats[hosts[1].ID()].topics
+		if n := tstats["shard/1/blocks"].firstMessageDeliveries; n != 3 {
+			t.Fatalf("expected 3 first message deliveries in shard 1, but got %f", n)
+		}
+		if n := tstats["shard/2/blocks"].firstMessageDeliveries; n != 1 {
+			t.Fatalf("expected 1 first message delivery in shard 2, but got %f", n)
+		}
+	})
+
+	// the resolver is only consulted once per topic, despite both the join and the subscription
+	mx.Lock()
+	for _, topic := range topics {
+		if calls[topic] != 1 {
+			t.Fatalf("expected the resolver to be called once for %s, but got %d calls", topic, calls[topic])
+		}
+	}
+	shards = map[string]bool{"1": true}
+	mx.Unlock()
+
+	if err := psubs[0].InvalidateTopicScoreParams(); err != nil {
+		t.Fatal(err)
+	}
+
+	check(func(gs *GossipSubRouter) {
+		gs.score.Lock()
+		defer gs.score.Unlock()
+
+		if gs.score.params.Topics["shard/1/blocks"] != tparams {
+			t.Fatal("expected the first shard to still be scored")
+		}
+		if _, ok := gs.score.params.Topics["shard/2/blocks"]; ok {
+			t.Fatal("expected the second shard to no longer be scored")
+		}
+
+		tstats := gs.score.peerStats[hosts[1].ID()].topics
+		if n := tstats["shard/1/blocks"].firstMessageDeliveries; n != 3 {
+			t.Fatalf("expected the counters of shard 1 to be kept, but got %f first message deliveries", n)
+		}
+		if _, ok := tstats["shard/2/blocks"]; ok {
+			t.Fatal("expected the counters of shard 2 to be discarded")
+		}
+	})
+
+	mx.Lock()
+	defer mx.Unlock()
+	for _, topic := range topics {
+		if calls[topic] != 2 {
+			t.Fatalf("expected the resolver to be called again for %s, but got %d calls", topic, calls[topic])
+		}
+	}
+}
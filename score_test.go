@@ -1,7 +1,9 @@
 package pubsub
 
 import (
+	"context"
 	"math"
+	"math/rand"
 	"net"
 	"sync"
 	"testing"
@@ -307,6 +309,52 @@ func TestScoreMeshMessageDeliveries(t *testing.T) {
 	}
 }
 
+func TestScoreMeshMessageDeliveriesSmallNetwork(t *testing.T) {
+	mytopic := "mytopic"
+	params := &PeerScoreParams{
+		AppSpecificScore: func(peer.ID) float64 { return 0 },
+		Topics:           make(map[string]*TopicScoreParams),
+	}
+	topicScoreParams := &TopicScoreParams{
+		TopicWeight:                     1,
+		MeshMessageDeliveriesWeight:     -1,
+		MeshMessageDeliveriesActivation: 100 * time.Millisecond,
+		MeshMessageDeliveriesWindow:     10 * time.Millisecond,
+		MeshMessageDeliveriesThreshold:  20,
+		MeshMessageDeliveriesCap:        100,
+		MeshMessageDeliveriesDecay:      1.0, // no decay for this test
+		TimeInMeshQuantum:               time.Second,
+	}
+	params.Topics[mytopic] = topicScoreParams
+
+	peerA := peer.ID("A")
+
+	ps := newPeerScore(params)
+	ps.AddPeer(peerA, "myproto")
+	ps.Graft(peerA, mytopic)
+	ps.SetSmallNetwork(mytopic, true)
+
+	// the penalty is not activated in small network mode
+	time.Sleep(2 * topicScoreParams.MeshMessageDeliveriesActivation)
+	ps.refreshScores()
+	if score := ps.Score(peerA); score != 0 {
+		t.Fatalf("expected no mesh delivery penalty in small network mode, got score %f", score)
+	}
+
+	// leaving the mode restarts the activation window
+	ps.SetSmallNetwork(mytopic, false)
+	ps.refreshScores()
+	if score := ps.Score(peerA); score != 0 {
+		t.Fatalf("expected no mesh delivery penalty right after leaving small network mode, got score %f", score)
+	}
+
+	time.Sleep(2 * topicScoreParams.MeshMessageDeliveriesActivation)
+	ps.refreshScores()
+	if score := ps.Score(peerA); score >= 0 {
+		t.Fatalf("expected a mesh delivery penalty after the activation window, got score %f", score)
+	}
+}
+
 func TestScoreMeshMessageDeliveriesDecay(t *testing.T) {
 	// Create parameters with reasonable default values
 	mytopic := "mytopic"
@@ -368,6 +416,132 @@ func TestScoreMeshMessageDeliveriesDecay(t *testing.T) {
 	}
 }
 
+func TestScoreAdaptiveMeshMessageDeliveriesWindow(t *testing.T) {
+	mytopic := "mytopic"
+	params := &PeerScoreParams{
+		AppSpecificScore: func(peer.ID) float64 { return 0 },
+		Topics:           make(map[string]*TopicScoreParams),
+	}
+	topicScoreParams := &TopicScoreParams{
+		TopicWeight:                       1,
+		MeshMessageDeliveriesWeight:       -1,
+		MeshMessageDeliveriesActivation:   time.Second,
+		MeshMessageDeliveriesWindow:       10 * time.Millisecond,
+		MeshMessageDeliveriesThreshold:    20,
+		MeshMessageDeliveriesCap:          1000,
+		MeshMessageDeliveriesDecay:        1.0, // no decay for this test
+		MeshMessageDeliveriesWindowFactor: 2,
+		MeshMessageDeliveriesWindowMin:    5 * time.Millisecond,
+		MeshMessageDeliveriesWindowMax:    time.Second,
+		TimeInMeshQuantum:                 time.Second,
+	}
+
+	params.Topics[mytopic] = topicScoreParams
+
+	// peer A always delivers the message first, and peer B delivers the duplicates.
+	peerA := peer.ID("A")
+	peerB := peer.ID("B")
+	peerC := peer.ID("C")
+
+	ps := newPeerScore(params)
+	for _, p := range []peer.ID{peerA, peerB, peerC} {
+		ps.AddPeer(p, "myproto")
+		ps.Graft(p, mytopic)
+	}
+
+	if window := ps.meshMessageDeliveriesWindow(mytopic, topicScoreParams); window != topicScoreParams.MeshMessageDeliveriesWindow {
+		t.Fatalf("expected the static window before any duplicate, but got %s", window)
+	}
+
+	// the clock is virtual: the delivery records are moved back in time by the latency of the
+	// duplicate, instead of waiting for it.
+	nextMsg := 0
+	deliver := func(latency time.Duration, from peer.ID) {
+		pbMsg := makeTestMessage(nextMsg)
+		pbMsg.Topic = &mytopic
+		nextMsg++
+
+		msg := Message{ReceivedFrom: peerA, Message: pbMsg}
+		ps.ValidateMessage(&msg)
+		ps.DeliverMessage(&msg)
+
+		drec := ps.deliveries.getRecord(ps.idGen.ID(&msg))
+		drec.firstSeen = drec.firstSeen.Add(-latency)
+		drec.validated = drec.validated.Add(-latency)
+
+		msg.ReceivedFrom = from
+		ps.DuplicateMessage(&msg)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	// latencies uniformly distributed in [mean/2, 3*mean/2)
+	sample := func(mean time.Duration) time.Duration {
+		return mean/2 + time.Duration(rng.Int63n(int64(mean)))
+	}
+	checkWindow := func(expected time.Duration) {
+		t.Helper()
+		window := ps.meshMessageDeliveriesWindow(mytopic, topicScoreParams)
+		if window < expected*8/10 || window > expected*12/10 {
+			t.Fatalf("expected an effective window of about %s, but got %s", expected, window)
+		}
+	}
+
+	// the window tracks the latency
+	for i := 0; i < 100; i++ {
+		deliver(sample(50*time.Millisecond), peerB)
+	}
+	checkWindow(100 * time.Millisecond)
+
+	// all the duplicates from peer B but the first, which was checked against the static window,
+	// fell within the window
+	if n := ps.peerStats[peerB].topics[mytopic].meshMessageDeliveries; n != 99 {
+		t.Fatalf("expected 99 mesh message deliveries for peer B, but got %f", n)
+	}
+
+	// but a duplicate well past the window is not a mesh delivery
+	deliver(300*time.Millisecond, peerC)
+	if n := ps.peerStats[peerC].topics[mytopic].meshMessageDeliveries; n != 0 {
+		t.Fatalf("expected no mesh message deliveries for peer C, but got %f", n)
+	}
+
+	// the window adapts when the latency increases
+	for i := 0; i < 100; i++ {
+		deliver(sample(200*time.Millisecond), peerB)
+	}
+	checkWindow(400 * time.Millisecond)
+
+	// and the duplicate from peer C now counts
+	deliver(300*time.Millisecond, peerC)
+	if n := ps.peerStats[peerC].topics[mytopic].meshMessageDeliveries; n != 1 {
+		t.Fatalf("expected 1 mesh message delivery for peer C, but got %f", n)
+	}
+
+	// the window is clamped to the bounds
+	for i := 0; i < 100; i++ {
+		deliver(sample(2*time.Second), peerB)
+	}
+	if window := ps.meshMessageDeliveriesWindow(mytopic, topicScoreParams); window != topicScoreParams.MeshMessageDeliveriesWindowMax {
+		t.Fatalf("expected the window to be clamped to %s, but got %s", topicScoreParams.MeshMessageDeliveriesWindowMax, window)
+	}
+	for i := 0; i < 100; i++ {
+		deliver(sample(time.Millisecond), peerB)
+	}
+	if window := ps.meshMessageDeliveriesWindow(mytopic, topicScoreParams); window != topicScoreParams.MeshMessageDeliveriesWindowMin {
+		t.Fatalf("expected the window to be clamped to %s, but got %s", topicScoreParams.MeshMessageDeliveriesWindowMin, window)
+	}
+
+	// the effective window is exposed in the score snapshots
+	snapshots := make(chan map[peer.ID]*PeerScoreSnapshot, 1)
+	ps.inspectEx = func(scores map[peer.ID]*PeerScoreSnapshot) {
+		snapshots <- scores
+	}
+	ps.inspectScoresExtended()
+	snapshot := <-snapshots
+	if window := snapshot[peerB].Topics[mytopic].MeshMessageDeliveriesWindow; window != topicScoreParams.MeshMessageDeliveriesWindowMin {
+		t.Fatalf("expected an effective window of %s in the snapshot, but got %s", topicScoreParams.MeshMessageDeliveriesWindowMin, window)
+	}
+}
+
 func TestScoreMeshFailurePenalty(t *testing.T) {
 	// Create parameters with reasonable default values
 	mytopic := "mytopic"
@@ -449,6 +623,88 @@ func TestScoreMeshFailurePenalty(t *testing.T) {
 	}
 }
 
+func TestScoreDuplicateLateness(t *testing.T) {
+	// Create parameters with reasonable default values
+	mytopic := "mytopic"
+	params := &PeerScoreParams{
+		AppSpecificScore: func(peer.ID) float64 { return 0 },
+		Topics:           make(map[string]*TopicScoreParams),
+	}
+	topicScoreParams := &TopicScoreParams{
+		TopicWeight:                               1,
+		DuplicateLatenessWeight:                   -10,
+		DuplicateLatenessThreshold:                10 * time.Millisecond,
+		DuplicateLatenessFirstDeliveriesThreshold: 1,
+		TimeInMeshQuantum:                         time.Second,
+	}
+
+	params.Topics[mytopic] = topicScoreParams
+
+	// peer A always delivers the message first.
+	// peer B delivers duplicates right after peer A.
+	// peer C consistently delivers duplicates late.
+	// we expect peers A and B to have a score of zero, while peer C should be penalized.
+	peerA := peer.ID("A")
+	peerB := peer.ID("B")
+	peerC := peer.ID("C")
+	peers := []peer.ID{peerA, peerB, peerC}
+
+	ps := newPeerScore(params)
+	for _, p := range peers {
+		ps.AddPeer(p, "myproto")
+		ps.Graft(p, mytopic)
+	}
+
+	nMessages := 50
+	msgs := make([]*Message, 0, nMessages)
+	for i := 0; i < nMessages; i++ {
+		pbMsg := makeTestMessage(i)
+		pbMsg.Topic = &mytopic
+		msg := Message{ReceivedFrom: peerA, Message: pbMsg}
+		ps.ValidateMessage(&msg)
+		ps.DeliverMessage(&msg)
+
+		msg.ReceivedFrom = peerB
+		ps.DuplicateMessage(&msg)
+		msgs = append(msgs, &msg)
+	}
+
+	time.Sleep(3 * topicScoreParams.DuplicateLatenessThreshold)
+
+	for _, msg := range msgs {
+		msg.ReceivedFrom = peerC
+		ps.DuplicateMessage(msg)
+	}
+
+	ps.refreshScores()
+	aScore := ps.Score(peerA)
+	bScore := ps.Score(peerB)
+	cScore := ps.Score(peerC)
+	if aScore != 0 {
+		t.Fatalf("expected a score of 0 for peer A, but got %f", aScore)
+	}
+	if bScore != 0 {
+		t.Fatalf("expected a score of 0 for peer B, but got %f", bScore)
+	}
+	expected := topicScoreParams.TopicWeight * topicScoreParams.DuplicateLatenessWeight
+	if cScore != expected {
+		t.Fatalf("Score: %f. Expected %f", cScore, expected)
+	}
+
+	lateness := ps.peerStats[peerC].topics[mytopic].medianDuplicateLateness()
+	if lateness < 3*topicScoreParams.DuplicateLatenessThreshold {
+		t.Fatalf("expected median duplicate lateness for peer C of at least %s, but got %s", 3*topicScoreParams.DuplicateLatenessThreshold, lateness)
+	}
+
+	// the penalty is lifted when the peer is grafted anew
+	ps.Prune(peerC, mytopic)
+	ps.Graft(peerC, mytopic)
+	cScore = ps.Score(peerC)
+	if cScore != 0 {
+		t.Fatalf("expected a score of 0 for peer C after re-grafting, but got %f", cScore)
+	}
+}
+
 func TestScoreInvalidMessageDeliveries(t *testing.T) {
 	// Create parameters with reasonable default values
 	mytopic := "mytopic"
@@ -533,6 +789,77 @@ func TestScoreInvalidMessageDeliveriesDecay(t *testing.T) {
 	}
 }
 
+func TestScoreIgnoredMessageDeliveries(t *testing.T) {
+	// Create parameters with reasonable default values
+	mytopic := "mytopic"
+	params := &PeerScoreParams{
+		AppSpecificScore: func(peer.ID) float64 { return 0 },
+		Topics:           make(map[string]*TopicScoreParams),
+	}
+	topicScoreParams := &TopicScoreParams{
+		TopicWeight:                    1,
+		TimeInMeshQuantum:              time.Second,
+		InvalidMessageDeliveriesWeight: -1,
+		InvalidMessageDeliveriesDecay:  1.0,
+		IgnoredMessageDeliveriesWeight: -0.5,
+		IgnoredMessageDeliveriesDecay:  1.0,
+	}
+	params.Topics[mytopic] = topicScoreParams
+
+	peerA := peer.ID("A")
+	peerB := peer.ID("B")
+	peerC := peer.ID("C")
+
+	ps := newPeerScore(params)
+	ps.AddPeer(peerA, "myproto")
+	ps.AddPeer(peerB, "myproto")
+	ps.AddPeer(peerC, "myproto")
+
+	// A floods ignorable messages; B forwards them while they are being validated, and C after
+	// they have been ignored.
+	nMessages := 100
+	for i := 0; i < nMessages; i++ {
+		pbMsg := makeTestMessage(i)
+		pbMsg.Topic = &mytopic
+		msgA := Message{ReceivedFrom: peerA, Message: pbMsg}
+		msgB := Message{ReceivedFrom: peerB, Message: pbMsg}
+		msgC := Message{ReceivedFrom: peerC, Message: pbMsg}
+
+		ps.ValidateMessage(&msgA)
+		ps.DuplicateMessage(&msgB)
+		ps.RejectMessage(&msgA, RejectValidationIgnored)
+		ps.DuplicateMessage(&msgC)
+	}
+
+	ps.refreshScores()
+	expected := topicScoreParams.TopicWeight * topicScoreParams.IgnoredMessageDeliveriesWeight * float64(nMessages*nMessages)
+	for _, p := range []peer.ID{peerA, peerB, peerC} {
+		if score := ps.Score(p); score != expected {
+			t.Fatalf("Score of %s: %f. Expected %f", p, score, expected)
+		}
+	}
+
+	// the counter is surfaced in the score snapshot, separately from invalid messages
+	snapshots := make(chan map[peer.ID]*PeerScoreSnapshot, 1)
+	ps.inspectEx = func(scores map[peer.ID]*PeerScoreSnapshot) {
+		snapshots <- scores
+	}
+	ps.inspectScoresExtended()
+	tss := (<-snapshots)[peerA].Topics[mytopic]
+	if tss.IgnoredMessageDeliveries != float64(nMessages) {
+		t.Fatalf("expected %d ignored message deliveries in the snapshot, but got %f", nMessages, tss.IgnoredMessageDeliveries)
+	}
+	if tss.InvalidMessageDeliveries != 0 {
+		t.Fatalf("expected no invalid message deliveries in the snapshot, but got %f", tss.InvalidMessageDeliveries)
+	}
+
+	// without a weight, ignored messages don't affect the score
+	topicScoreParams.IgnoredMessageDeliveriesWeight = 0
+	if score := ps.Score(peerA); score != 0 {
+		t.Fatalf("Score: %f. Expected 0", score)
+	}
+}
+
 func TestScoreRejectMessageDeliveries(t *testing.T) {
 	// this tests adds coverage for the dark corners of rejection tracing
 	mytopic := "mytopic"
@@ -814,7 +1141,7 @@ func TestScoreBehaviourPenalty(t *testing.T) {
 	var ps *peerScore
 
 	// first check AddPenalty on a nil peerScore
-	ps.AddPenalty(peerA, 1)
+	ps.AddPenalty(peerA, 1, "")
 	aScore := ps.Score(peerA)
 	if aScore != 0 {
 		t.Errorf("expected peer score to be 0, got %f", aScore)
@@ -824,7 +1151,7 @@ func TestScoreBehaviourPenalty(t *testing.T) {
 	ps = newPeerScore(params)
 
 	// next AddPenalty on a non-existent peer
-	ps.AddPenalty(peerA, 1)
+	ps.AddPenalty(peerA, 1, "")
 	aScore = ps.Score(peerA)
 	if aScore != 0 {
 		t.Errorf("expected peer score to be 0, got %f", aScore)
@@ -838,13 +1165,13 @@ func TestScoreBehaviourPenalty(t *testing.T) {
 		t.Errorf("expected peer score to be 0, got %f", aScore)
 	}
 
-	ps.AddPenalty(peerA, 1)
+	ps.AddPenalty(peerA, 1, "")
 	aScore = ps.Score(peerA)
 	if aScore != -1 {
 		t.Errorf("expected peer score to be -1, got %f", aScore)
 	}
 
-	ps.AddPenalty(peerA, 1)
+	ps.AddPenalty(peerA, 1, "")
 	aScore = ps.Score(peerA)
 	if aScore != -4 {
 		t.Errorf("expected peer score to be -4, got %f", aScore)
@@ -1061,6 +1388,129 @@ func TestScoreResetTopicParams(t *testing.T) {
 	}
 }
 
+func TestScoreShadow(t *testing.T) {
+	mytopic := "mytopic"
+	makeParams := func(firstWeight, meshWeight, invalidWeight, penaltyWeight float64) *PeerScoreParams {
+		return &PeerScoreParams{
+			AppSpecificScore:       func(peer.ID) float64 { return 0 },
+			BehaviourPenaltyWeight: penaltyWeight,
+			BehaviourPenaltyDecay:  1,
+			Topics: map[string]*TopicScoreParams{
+				mytopic: {
+					TopicWeight:                     1,
+					TimeInMeshQuantum:               time.Second,
+					FirstMessageDeliveriesWeight:    firstWeight,
+					FirstMessageDeliveriesDecay:     1,
+					FirstMessageDeliveriesCap:       100,
+					MeshMessageDeliveriesWeight:     meshWeight,
+					MeshMessageDeliveriesDecay:      1,
+					MeshMessageDeliveriesCap:        100,
+					MeshMessageDeliveriesThreshold:  20,
+					MeshMessageDeliveriesWindow:     time.Second,
+					MeshMessageDeliveriesActivation: time.Millisecond,
+					InvalidMessageDeliveriesWeight:  invalidWeight,
+					InvalidMessageDeliveriesDecay:   1,
+				},
+			},
+		}
+	}
+
+	// the live scorer runs with a shadow; the reference scorers run alone with the live and the
+	// shadow parameters, and must reach the same scores
+	ps := newPeerScore(makeParams(1, -1, -1, -1))
+	ps.shadow = newPeerScore(makeParams(2, -3, -10, -5))
+	ps.shadow.deliveries = nil
+	ps.shadow.isShadow = true
+	live := newPeerScore(makeParams(1, -1, -1, -1))
+	shadow := newPeerScore(makeParams(2, -3, -10, -5))
+
+	peerA := peer.ID("A")
+	peerB := peer.ID("B")
+	peerC := peer.ID("C")
+	for _, s := range []*peerScore{ps, live, shadow} {
+		for _, p := range []peer.ID{peerA, peerB, peerC} {
+			s.AddPeer(p, "myproto")
+			s.Graft(p, mytopic)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	for i := 0; i < 30; i++ {
+		pbMsg := makeTestMessage(i)
+		pbMsg.Topic = &mytopic
+		msgA := Message{ReceivedFrom: peerA, Message: pbMsg}
+		msgB := Message{ReceivedFrom: peerB, Message: pbMsg}
+		msgC := Message{ReceivedFrom: peerC, Message: pbMsg}
+
+		for _, s := range []*peerScore{ps, live, shadow} {
+			s.ValidateMessage(&msgA)
+			s.DuplicateMessage(&msgB)
+			if i%3 == 0 {
+				s.RejectMessage(&msgA, RejectValidationFailed)
+			} else {
+				s.DeliverMessage(&msgA)
+			}
+			s.DuplicateMessage(&msgC)
+		}
+	}
+	for _, s := range []*peerScore{ps, live, shadow} {
+		s.AddPenalty(peerC, 2, "")
+		s.refreshScores()
+		s.Prune(peerB, mytopic)
+		s.RemovePeer(peerC)
+	}
+
+	for _, p := range []peer.ID{peerA, peerB, peerC} {
+		// the shadow doesn't affect the live scores
+		if ps.Score(p) != live.Score(p) {
+			t.Fatalf("expected live score %f for %s, got %f", live.Score(p), p, ps.Score(p))
+		}
+		// the shadow reaches the scores of its parameters, with the shared delivery records
+		if ps.shadow.Score(p) != shadow.Score(p) {
+			t.Fatalf("expected shadow score %f for %s, got %f", shadow.Score(p), p, ps.shadow.Score(p))
+		}
+		if ps.Score(p) == ps.shadow.Score(p) {
+			t.Fatalf("expected divergent scores for %s, got %f", p, ps.Score(p))
+		}
+	}
+
+	// the shadow snapshots are tagged
+	var snapshots []map[peer.ID]*PeerScoreSnapshot
+	var mx sync.Mutex
+	done := make(chan struct{}, 2)
+	inspect := func(scores map[peer.ID]*PeerScoreSnapshot) {
+		mx.Lock()
+		snapshots = append(snapshots, scores)
+		mx.Unlock()
+		done <- struct{}{}
+	}
+	ps.inspectEx = inspect
+	ps.shadow.inspectEx = inspect
+	ps.inspectScores()
+	ps.shadow.inspectScores()
+	<-done
+	<-done
+
+	mx.Lock()
+	defer mx.Unlock()
+	tagged := 0
+	for _, scores := range snapshots {
+		for p, snapshot := range scores {
+			expected := ps.Score(p)
+			if snapshot.Shadow {
+				tagged++
+				expected = ps.shadow.Score(p)
+			}
+			if snapshot.Score != expected {
+				t.Fatalf("expected score %f for %s, got %f", expected, p, snapshot.Score)
+			}
+		}
+	}
+	if tagged != 3 {
+		t.Fatalf("expected 3 shadow snapshots, got %d", tagged)
+	}
+}
+
 func withinVariance(score float64, expected float64, variance float64) bool {
 	if expected >= 0 {
 		return score > expected*(1-variance) && score < expected*(1+variance)
@@ -1078,3 +1528,149 @@ func setIPsForPeer(t *testing.T, ps *peerScore, p peer.ID, ips ...string) {
 	}
 	pstats.ips = ips
 }
+
+func TestScoreIPColocationWhitelistUpdate(t *testing.T) {
+	_, ipNet, err := net.ParseCIDR("2.3.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := &PeerScoreParams{
+		AppSpecificScore:            func(peer.ID) float64 { return 0 },
+		IPColocationFactorThreshold: 1,
+		IPColocationFactorWeight:    -1,
+		Topics:                      make(map[string]*TopicScoreParams),
+	}
+
+	peerA := peer.ID("A")
+	peerB := peer.ID("B")
+	peerC := peer.ID("C")
+	peerD := peer.ID("D")
+
+	ps := newPeerScore(params)
+	for _, p := range []peer.ID{peerA, peerB, peerC, peerD} {
+		ps.AddPeer(p, "myproto")
+	}
+	setIPsForPeer(t, ps, peerA, "2.3.4.5")
+	setIPsForPeer(t, ps, peerB, "2.3.4.5")
+	setIPsForPeer(t, ps, peerC, "2.3.4.5")
+	setIPsForPeer(t, ps, peerD, "5.6.7.8")
+
+	checkScores := func(expected map[peer.ID]float64) {
+		t.Helper()
+		ps.refreshScores()
+		for p, score := range expected {
+			if s := ps.Score(p); s != score {
+				t.Fatalf("expected peer %s to have score %f, got %f", p, score, s)
+			}
+		}
+	}
+
+	// A, B and C share an IP: the surplus is 2
+	checkScores(map[peer.ID]float64{peerA: -4, peerB: -4, peerC: -4, peerD: 0})
+
+	// the network is whitelisted at runtime
+	ps.addIPColocationWhitelist(ipNet)
+	checkScores(map[peer.ID]float64{peerA: 0, peerB: 0, peerC: 0, peerD: 0})
+
+	ps.removeIPColocationWhitelist(ipNet)
+	checkScores(map[peer.ID]float64{peerA: -4, peerB: -4, peerC: -4, peerD: 0})
+
+	// an exempt peer still counts towards the colocation of the others
+	ps.setIPColocationExemption(peerA, true)
+	checkScores(map[peer.ID]float64{peerA: 0, peerB: -4, peerC: -4, peerD: 0})
+
+	cidrs, pids := ps.ipColocationExemptions()
+	if len(cidrs) != 0 || len(pids) != 1 || pids[0] != peerA {
+		t.Fatalf("unexpected exemptions %v %v", cidrs, pids)
+	}
+
+	ps.setIPColocationExemption(peerA, false)
+	checkScores(map[peer.ID]float64{peerA: -4, peerB: -4, peerC: -4, peerD: 0})
+
+	// the whitelist can be updated concurrently with the refreshes
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			ps.refreshScores()
+			ps.Score(peerA)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		ps.addIPColocationWhitelist(ipNet)
+		ps.removeIPColocationWhitelist(ipNet)
+	}
+	<-done
+}
+
+func TestIPColocationWhitelistUpdate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+
+	_, configured, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, added, err := net.ParseCIDR("2.3.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ps := getGossipsub(ctx, hosts[0],
+		WithPeerScore(
+			&PeerScoreParams{
+				AppSpecificScore:            func(peer.ID) float64 { return 0 },
+				IPColocationFactorThreshold: 1,
+				IPColocationFactorWeight:    -1,
+				IPColocationFactorWhitelist: []*net.IPNet{configured},
+				DecayInterval:               DefaultDecayInterval,
+				DecayToZero:                 DefaultDecayToZero,
+			},
+			&PeerScoreThresholds{
+				GossipThreshold:   -100,
+				PublishThreshold:  -500,
+				GraylistThreshold: -1000,
+			}))
+
+	if err := ps.AddIPColocationWhitelist(added); err != nil {
+		t.Fatal(err)
+	}
+	// adding a network twice is a no-op
+	if err := ps.AddIPColocationWhitelist(added); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SetIPColocationExemption(hosts[1].ID(), true); err != nil {
+		t.Fatal(err)
+	}
+	cidrs, pids := ps.IPColocationExemptions()
+	if len(cidrs) != 2 || cidrs[0].String() != "10.0.0.0/8" || cidrs[1].String() != "2.3.0.0/16" {
+		t.Fatalf("unexpected whitelist %v", cidrs)
+	}
+	if len(pids) != 1 || pids[0] != hosts[1].ID() {
+		t.Fatalf("unexpected exempt peers %v", pids)
+	}
+
+	// the configured networks can be removed too
+	if err := ps.RemoveIPColocationWhitelist(configured); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SetIPColocationExemption(hosts[1].ID(), false); err != nil {
+		t.Fatal(err)
+	}
+	cidrs, pids = ps.IPColocationExemptions()
+	if len(cidrs) != 1 || cidrs[0].String() != "2.3.0.0/16" || len(pids) != 0 {
+		t.Fatalf("unexpected exemptions %v %v", cidrs, pids)
+	}
+
+	// without peer scoring there is nothing to update
+	noscore := getGossipsub(ctx, hosts[1])
+	if err := noscore.AddIPColocationWhitelist(added); err == nil {
+		t.Fatal("expected an error without peer scoring")
+	}
+	if err := ps.AddIPColocationWhitelist(nil); err == nil {
+		t.Fatal("expected an error for a nil network")
+	}
+}
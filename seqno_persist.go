@@ -0,0 +1,254 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+)
+
+// SeqnoPersistenceInterval is the interval at which the seqno high-water mark is persisted with
+// WithSeqnoPersistence, if it was not already persisted ahead of need.
+var SeqnoPersistenceInterval = 10 * time.Second
+
+// DefaultSeqnoMargin is the default margin of the seqno high-water mark persisted with
+// WithSeqnoPersistence.
+const DefaultSeqnoMargin = 1 << 16
+
+// SeqnoMarginLowWater is the fraction of the seqno margin left before the persisted high-water
+// mark below which the mark is rewritten; the seqnos left then cover the publishing while the
+// mark is written.
+var SeqnoMarginLowWater = 0.75
+
+// ErrSeqnoNotPersisted is returned when publishing with WithSeqnoPersistence if the seqnos up to
+// the persisted high-water mark are all used and the mark could not be rewritten; the next seqno
+// could be reused after a restart, so the message is not published.
+var ErrSeqnoNotPersisted = errors.New("no persisted seqno available; the seqno datastore is failing")
+
+// SeqnoPersistenceOpt is an option for WithSeqnoPersistence.
+type SeqnoPersistenceOpt func(sp *seqnoPersistence) error
+
+// WithSeqnoMargin sets the margin by which the persisted seqno high-water mark runs ahead of the
+// seqnos we use; it bounds the jump of the seqnos after an unclean shutdown, and the number of
+// messages we can publish while the mark is written.
+func WithSeqnoMargin(margin uint64) SeqnoPersistenceOpt {
+	return func(sp *seqnoPersistence) error {
+		if margin < 2 {
+			return fmt.Errorf("invalid seqno margin; must be at least 2")
+		}
+		sp.margin = margin
+		return nil
+	}
+}
+
+// WithSeqnoPersistence persists the seqnos of the messages we publish, so that they keep
+// increasing across restarts over the same datastore, instead of restarting from the clock.
+// We persist a high-water mark that runs ahead of the seqnos we use by a margin (see
+// WithSeqnoMargin), in the background, and start from the mark after a restart; the mark is
+// rewritten every SeqnoPersistenceInterval, and as soon as the seqnos left before it fall below
+// SeqnoMarginLowWater of the margin, so publishing doesn't wait for the datastore.
+// A seqno past the mark is never used though: in the worst case, when we publish the seqnos left
+// before a write of the mark completes, or while the writes fail, publishing waits for the next
+// write, and fails with ErrSeqnoNotPersisted if it fails. On clean shutdown, when the context of
+// the PubSub is cancelled, the last used seqno is persisted instead, so that the seqnos continue
+// without a jump.
+// The mark is stored under a key specific to the message author, so the datastore can be shared.
+func WithSeqnoPersistence(ds datastore.Datastore, opts ...SeqnoPersistenceOpt) Option {
+	return func(p *PubSub) error {
+		if ds == nil {
+			return fmt.Errorf("nil seqno datastore")
+		}
+
+		sp := &seqnoPersistence{
+			ds:      ds,
+			margin:  DefaultSeqnoMargin,
+			wake:    make(chan struct{}, 1),
+			attempt: make(chan struct{}),
+		}
+		for _, opt := range opts {
+			if err := opt(sp); err != nil {
+				return err
+			}
+		}
+
+		p.seqnoPersist = sp
+		return nil
+	}
+}
+
+// seqnoPersistence persists the seqno high-water mark in its own goroutine.
+type seqnoPersistence struct {
+	p      *PubSub
+	ds     datastore.Datastore
+	key    datastore.Key
+	margin uint64
+
+	// the persisted high-water mark; accessed atomically
+	reserved uint64
+	// the number of seqnos left before the mark below which it is rewritten
+	lowWater uint64
+
+	wake chan struct{}
+	// whether publishing is waiting for the mark to be rewritten; accessed atomically
+	stalled int32
+
+	mx sync.Mutex
+	// closed when the loop completes its next attempt to rewrite the mark
+	attempt chan struct{}
+	// whether the last attempt failed
+	failed bool
+}
+
+// start loads the high-water mark persisted by a previous instance, and starts the loop
+// persisting it.
+func (sp *seqnoPersistence) start(p *PubSub) error {
+	sp.p = p
+	sp.lowWater = uint64(SeqnoMarginLowWater * float64(sp.margin))
+	author := p.signID
+	if author == "" {
+		author = p.tr.ID()
+	}
+	sp.key = datastore.NewKey("/pubsub/seqno").ChildString(author.String())
+
+	data, err := sp.ds.Get(p.ctx, sp.key)
+	switch {
+	case errors.Is(err, datastore.ErrNotFound):
+	case err != nil:
+		return fmt.Errorf("error loading seqno: %w", err)
+	case len(data) != 8:
+		return fmt.Errorf("error loading seqno: bad length %d", len(data))
+	default:
+		if mark := binary.BigEndian.Uint64(data); mark > p.counter {
+			p.counter = mark
+		}
+	}
+
+	// reserve the first seqnos before publishing
+	if err := sp.persist(p.ctx, p.counter+sp.margin); err != nil {
+		return fmt.Errorf("error persisting seqno: %w", err)
+	}
+	atomic.StoreUint64(&sp.reserved, p.counter+sp.margin)
+
+	go sp.loop()
+	return nil
+}
+
+// next takes the next seqno from counter, waking up the loop once the seqnos left before the mark
+// fall below the low-water mark. It never uses a seqno past the mark: if the mark is reached, it
+// waits for the loop to rewrite it, and returns ErrSeqnoNotPersisted if the loop fails to.
+func (sp *seqnoPersistence) next(ctx context.Context, counter *uint64) (uint64, error) {
+	for {
+		last := atomic.LoadUint64(counter)
+		seqno := last + 1
+		reserved := atomic.LoadUint64(&sp.reserved)
+		if seqno > reserved {
+			if err := sp.await(ctx); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if !atomic.CompareAndSwapUint64(counter, last, seqno) {
+			continue
+		}
+
+		if reserved-seqno < sp.lowWater {
+			sp.wakeUp()
+		}
+		return seqno, nil
+	}
+}
+
+// await wakes up the loop and waits for its next attempt to rewrite the mark; it returns
+// ErrSeqnoNotPersisted if the attempt failed.
+func (sp *seqnoPersistence) await(ctx context.Context) error {
+	sp.mx.Lock()
+	attempt := sp.attempt
+	sp.mx.Unlock()
+
+	if atomic.CompareAndSwapInt32(&sp.stalled, 0, 1) {
+		sp.p.logger.Warnw("seqno high-water mark reached; publishing waits for the seqno datastore", "margin", sp.margin)
+	}
+	sp.wakeUp()
+	select {
+	case <-attempt:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-sp.p.ctx.Done():
+		return sp.p.ctx.Err()
+	}
+
+	sp.mx.Lock()
+	defer sp.mx.Unlock()
+	if sp.failed {
+		return ErrSeqnoNotPersisted
+	}
+	return nil
+}
+
+// attempted signals the completion of an attempt to rewrite the mark to the publishers waiting
+// for it.
+func (sp *seqnoPersistence) attempted(err error) {
+	sp.mx.Lock()
+	defer sp.mx.Unlock()
+	sp.failed = err != nil
+	close(sp.attempt)
+	sp.attempt = make(chan struct{})
+}
+
+func (sp *seqnoPersistence) wakeUp() {
+	select {
+	case sp.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (sp *seqnoPersistence) loop() {
+	ticker := time.NewTicker(SeqnoPersistenceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-sp.wake:
+		case <-sp.p.ctx.Done():
+			// the PubSub is closed; persist the last used seqno, so that we continue from it
+			last := atomic.LoadUint64(&sp.p.counter)
+			if err := sp.persist(context.Background(), last); err != nil {
+				sp.p.logger.Warnf("error persisting seqno: %s", err)
+			}
+			return
+		}
+
+		err := sp.rewrite()
+		if err == nil {
+			atomic.StoreInt32(&sp.stalled, 0)
+		}
+		sp.attempted(err)
+	}
+}
+
+// rewrite persists the mark ahead of the last used seqno by the margin, unless it already is.
+func (sp *seqnoPersistence) rewrite() error {
+	mark := atomic.LoadUint64(&sp.p.counter) + sp.margin
+	if mark <= atomic.LoadUint64(&sp.reserved) {
+		return nil
+	}
+	if err := sp.persist(sp.p.ctx, mark); err != nil {
+		sp.p.logger.Warnf("error persisting seqno: %s", err)
+		return err
+	}
+	atomic.StoreUint64(&sp.reserved, mark)
+	return nil
+}
+
+// persist writes the high-water mark.
+func (sp *seqnoPersistence) persist(ctx context.Context, mark uint64) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, mark)
+	return sp.ds.Put(ctx, sp.key, data)
+}
@@ -0,0 +1,265 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+
+	"github.com/libp2p/go-libp2p/core/host"
+)
+
+func TestSeqnoPersistence(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	// publish returns the seqnos of n messages published by a new instance over the datastore
+	publish := func(ctx context.Context, h host.Host, n int, setup func(ps *PubSub)) []uint64 {
+		t.Helper()
+		ps, err := NewGossipSub(ctx, h, WithSeqnoPersistence(ds, WithSeqnoMargin(100)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if setup != nil {
+			setup(ps)
+		}
+
+		sub, err := ps.Subscribe("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tp, _ := ps.GetTopic("foobar")
+
+		var seqnos []uint64
+		for i := 0; i < n; i++ {
+			if err := tp.Publish(ctx, []byte("hello")); err != nil {
+				t.Fatal(err)
+			}
+			msg, err := sub.Next(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seqnos = append(seqnos, binary.BigEndian.Uint64(msg.GetSeqno()))
+		}
+		return seqnos
+	}
+
+	// the first instance runs ahead of the clock, as if the clock was set back before the restarts
+	ahead := uint64(time.Now().Add(24 * time.Hour).UnixNano())
+	ctx1, cancel1 := context.WithCancel(ctx)
+	defer cancel1()
+	first := publish(ctx1, hosts[0], 150, func(ps *PubSub) {
+		atomic.StoreUint64(&ps.counter, ahead)
+		ps.seqnoPersist.wakeUp()
+		waitFor(t, "the seqnos to be reserved", func() bool {
+			return atomic.LoadUint64(&ps.seqnoPersist.reserved) > ahead
+		})
+	})
+	if first[0] != ahead+1 {
+		t.Fatalf("unexpected first seqno %d", first[0])
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// the first instance crashed: the second starts from the mark persisted ahead of need
+	ctx2, cancel2 := context.WithCancel(ctx)
+	second := publish(ctx2, hosts[0], 10, nil)
+	if second[0] <= first[len(first)-1] {
+		t.Fatalf("seqno reused after an unclean shutdown: %d after %d", second[0], first[len(first)-1])
+	}
+	if second[0] > first[len(first)-1]+101 {
+		t.Fatalf("seqno jumped by more than the margin after an unclean shutdown: %d after %d", second[0], first[len(first)-1])
+	}
+
+	// the second instance shuts down cleanly: the third continues from its last seqno
+	cancel2()
+	time.Sleep(100 * time.Millisecond)
+	third := publish(ctx, hosts[0], 1, nil)
+	if third[0] != second[len(second)-1]+1 {
+		t.Fatalf("expected seqno %d after a clean shutdown, got %d", second[len(second)-1]+1, third[0])
+	}
+
+	if _, err := NewGossipSub(ctx, hosts[0], WithSeqnoPersistence(ds, WithSeqnoMargin(1))); err == nil {
+		t.Fatal("expected an error for an invalid seqno margin")
+	}
+}
+
+func TestSeqnoPersistenceFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ds := &failingDatastore{Datastore: dssync.MutexWrap(datastore.NewMapDatastore())}
+	ps, err := NewGossipSub(ctx, hosts[0], WithSeqnoPersistence(ds, WithSeqnoMargin(10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp, err := ps.Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// while the mark can't be rewritten, we only publish with the seqnos reserved already
+	ds.setFailing(true)
+	published := 0
+	for i := 0; i < 20; i++ {
+		err := tp.Publish(ctx, []byte("hello"))
+		if errors.Is(err, ErrSeqnoNotPersisted) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		published++
+	}
+	if published != 10 {
+		t.Fatalf("expected to publish the 10 reserved seqnos, published %d", published)
+	}
+
+	// so that none of the seqnos we used would be reused after a crash
+	data, err := ds.Get(ctx, ps.seqnoPersist.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mark, last := binary.BigEndian.Uint64(data), atomic.LoadUint64(&ps.counter); last > mark {
+		t.Fatalf("used seqno %d past the persisted mark %d", last, mark)
+	}
+
+	// publishing resumes once the mark is rewritten
+	ds.setFailing(false)
+	waitFor(t, "publishing to resume", func() bool {
+		return tp.Publish(ctx, []byte("hello")) == nil
+	})
+}
+
+func TestSeqnoPersistenceAhead(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ds := &blockingDatastore{Datastore: dssync.MutexWrap(datastore.NewMapDatastore())}
+	ps, err := NewGossipSub(ctx, hosts[0], WithSeqnoPersistence(ds, WithSeqnoMargin(100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp, err := ps.Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reserved := atomic.LoadUint64(&ps.seqnoPersist.reserved)
+	unblock := ds.block()
+	publish := func(n int) {
+		published := make(chan error, 1)
+		go func() {
+			for i := 0; i < n; i++ {
+				if err := tp.Publish(ctx, []byte("hello")); err != nil {
+					published <- err
+					return
+				}
+			}
+			published <- nil
+		}()
+		select {
+		case err := <-published:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("publishing waited for the datastore")
+		}
+	}
+
+	// the mark is rewritten once fewer than 75 seqnos are left before it
+	publish(30)
+	waitFor(t, "a write of the mark to be in progress", func() bool {
+		return ds.blocked() == 1
+	})
+
+	// and publishing the seqnos left doesn't wait for the write
+	publish(69)
+	if atomic.LoadUint64(&ps.seqnoPersist.reserved) != reserved {
+		t.Fatal("expected the mark not to be rewritten while the write is held")
+	}
+
+	unblock()
+	waitFor(t, "the mark to be rewritten", func() bool {
+		return atomic.LoadUint64(&ps.seqnoPersist.reserved) > reserved
+	})
+}
+
+// blockingDatastore holds the writes while it is blocked.
+type blockingDatastore struct {
+	datastore.Datastore
+
+	mx      sync.Mutex
+	gate    chan struct{}
+	waiting int
+}
+
+// block holds the writes until the returned function is called.
+func (d *blockingDatastore) block() func() {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	gate := make(chan struct{})
+	d.gate = gate
+	return func() {
+		d.mx.Lock()
+		defer d.mx.Unlock()
+		d.gate = nil
+		close(gate)
+	}
+}
+
+// blocked returns the number of writes held.
+func (d *blockingDatastore) blocked() int {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	return d.waiting
+}
+
+func (d *blockingDatastore) Put(ctx context.Context, key datastore.Key, value []byte) error {
+	d.mx.Lock()
+	gate := d.gate
+	if gate != nil {
+		d.waiting++
+	}
+	d.mx.Unlock()
+
+	if gate != nil {
+		<-gate
+		d.mx.Lock()
+		d.waiting--
+		d.mx.Unlock()
+	}
+	return d.Datastore.Put(ctx, key, value)
+}
+
+// failingDatastore fails the writes while it is set failing.
+type failingDatastore struct {
+	datastore.Datastore
+	failing int32
+}
+
+func (d *failingDatastore) setFailing(failing bool) {
+	var v int32
+	if failing {
+		v = 1
+	}
+	atomic.StoreInt32(&d.failing, v)
+}
+
+func (d *failingDatastore) Put(ctx context.Context, key datastore.Key, value []byte) error {
+	if atomic.LoadInt32(&d.failing) != 0 {
+		return errors.New("write failed")
+	}
+	return d.Datastore.Put(ctx, key, value)
+}
@@ -0,0 +1,95 @@
+package pubsub
+
+import (
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// SeqnoReplayMaxAuthors is the maximum number of authors whose highest sequence number is
+// remembered with WithSeqnoReplayProtection; the least recently seen authors are forgotten first.
+var SeqnoReplayMaxAuthors = 10000
+
+// WithSeqnoReplayProtection rejects messages whose sequence number is more than window below the
+// highest sequence number seen from their author, so that old messages can't be replayed once
+// they have expired from the seen messages cache. The rejection is traced with the
+// RejectReplayedSeqno reason, and the propagating peer is penalized for an invalid message
+// delivery.
+// The window accounts for the messages of an author arriving out of order; it should be larger
+// than the number of messages an author may publish while an earlier one is still propagating.
+// Only signed messages are checked, as the author and sequence number of unsigned messages can
+// be forged by any peer; thus the protection has no effect on topics whose messages carry no
+// sequence number, as with StrictNoSign.
+func WithSeqnoReplayProtection(window int) Option {
+	return func(ps *PubSub) error {
+		if window <= 0 {
+			return fmt.Errorf("invalid seqno replay window; must be positive")
+		}
+
+		ps.seqnos = newSeqnoTracker(uint64(window), SeqnoReplayMaxAuthors)
+		return nil
+	}
+}
+
+// seqnoTracker tracks the highest sequence number seen per author, in a bounded LRU of authors.
+// A nil tracker accepts all messages.
+type seqnoTracker struct {
+	mx         sync.Mutex
+	window     uint64
+	maxAuthors int
+
+	authors map[peer.ID]*list.Element
+	// the watermarks of the authors, most recently seen first
+	lru *list.List
+}
+
+type seqnoWatermark struct {
+	author peer.ID
+	seqno  uint64
+}
+
+func newSeqnoTracker(window uint64, maxAuthors int) *seqnoTracker {
+	return &seqnoTracker{
+		window:     window,
+		maxAuthors: maxAuthors,
+		authors:    make(map[peer.ID]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// check records the sequence number of a message, and returns false if it is more than the window
+// below the highest sequence number seen from its author.
+func (st *seqnoTracker) check(msg *Message) bool {
+	if st == nil || msg.Signature == nil || len(msg.Seqno) != 8 {
+		return true
+	}
+
+	author := msg.GetFrom()
+	seqno := binary.BigEndian.Uint64(msg.Seqno)
+
+	st.mx.Lock()
+	defer st.mx.Unlock()
+
+	if e, ok := st.authors[author]; ok {
+		w := e.Value.(*seqnoWatermark)
+		if w.seqno > st.window && seqno < w.seqno-st.window {
+			return false
+		}
+		if seqno > w.seqno {
+			w.seqno = seqno
+		}
+		st.lru.MoveToFront(e)
+		return true
+	}
+
+	st.authors[author] = st.lru.PushFront(&seqnoWatermark{author: author, seqno: seqno})
+	if st.lru.Len() > st.maxAuthors {
+		e := st.lru.Back()
+		st.lru.Remove(e)
+		delete(st.authors, e.Value.(*seqnoWatermark).author)
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p-pubsub/timecache"
+)
+
+func TestSeqnoTracker(t *testing.T) {
+	st := newSeqnoTracker(2, 2)
+
+	msg := func(author string, seqno uint64, signed bool) *Message {
+		m := &pb.Message{From: []byte(author), Seqno: make([]byte, 8)}
+		binary.BigEndian.PutUint64(m.Seqno, seqno)
+		if signed {
+			m.Signature = []byte("sig")
+		}
+		return &Message{Message: m}
+	}
+
+	for _, tc := range []struct {
+		author string
+		seqno  uint64
+		signed bool
+		ok     bool
+	}{
+		{"a", 10, true, true},
+		// within the window
+		{"a", 8, true, true},
+		{"a", 7, true, false},
+		{"a", 20, true, true},
+		{"a", 17, true, false},
+		// unsigned messages are not checked
+		{"a", 1, false, true},
+		// other authors have their own watermark
+		{"b", 1, true, true},
+		{"c", 1, true, true},
+		// the least recently seen author is forgotten
+		{"a", 1, true, true},
+	} {
+		if ok := st.check(msg(tc.author, tc.seqno, tc.signed)); ok != tc.ok {
+			t.Fatalf("expected check of seqno %d from %s to be %t", tc.seqno, tc.author, tc.ok)
+		}
+	}
+	if len(st.authors) != 2 || st.lru.Len() != 2 {
+		t.Fatalf("expected 2 tracked authors, got %d", len(st.authors))
+	}
+
+	// messages without a sequence number are not checked
+	if !st.check(&Message{Message: &pb.Message{From: []byte("a"), Signature: []byte("sig")}}) {
+		t.Fatal("expected a message without seqno to be accepted")
+	}
+
+	var nilTracker *seqnoTracker
+	if !nilTracker.check(msg("a", 0, true)) {
+		t.Fatal("expected a nil tracker to accept all messages")
+	}
+}
+
+func TestSeqnoReplayProtection(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled=%v", enabled), func(t *testing.T) {
+			testSeqnoReplayProtection(t, enabled)
+		})
+	}
+}
+
+func testSeqnoReplayProtection(t *testing.T, enabled bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "foobar"
+	hosts := getNetHosts(t, ctx, 2)
+
+	tracer := &rejectReasonTracer{reasons: make(map[string]int)}
+	opts := []Option{WithEventTracer(tracer)}
+	if enabled {
+		opts = append(opts, WithSeqnoReplayProtection(2))
+	}
+	author := getPubsub(ctx, hosts[0])
+	ps := getPubsub(ctx, hosts[1], opts...)
+
+	sub, err := ps.Subscribe(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	next := func(timeout time.Duration) (*Message, error) {
+		rctx, rcancel := context.WithTimeout(ctx, timeout)
+		defer rcancel()
+		return sub.Next(rctx)
+	}
+
+	var first *Message
+	for i := 0; i < 4; i++ {
+		if err := author.Publish(topic, []byte(fmt.Sprintf("msg%d", i))); err != nil {
+			t.Fatal(err)
+		}
+		msg, err := next(5 * time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == nil {
+			first = msg
+		}
+	}
+
+	// forget that we have seen the first message, and replay it
+	done := make(chan struct{})
+	ps.eval <- func() {
+		ps.seenMessages.Done()
+		ps.seenMessages = timecache.NewTimeCacheWithStrategy(ps.seenMsgStrategy, ps.seenMsgTTL)
+		ps.pushMsg(&Message{Message: first.Message, ReceivedFrom: hosts[0].ID()})
+		close(done)
+	}
+	<-done
+
+	msg, err := next(500 * time.Millisecond)
+	if enabled {
+		if err == nil {
+			t.Fatalf("expected the replayed message to be rejected, got %q", msg.Data)
+		}
+		if c := tracer.count(RejectReplayedSeqno); c != 1 {
+			t.Fatalf("expected 1 message rejected as replayed, got %d", c)
+		}
+	} else {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(msg.Data) != "msg0" {
+			t.Fatalf("expected the replayed message, got %q", msg.Data)
+		}
+	}
+}
+
+func TestSeqnoReplayProtectionOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	if _, err := NewFloodSub(ctx, hosts[0], WithSeqnoReplayProtection(0)); err == nil {
+		t.Fatal("expected an error for a zero window")
+	}
+}
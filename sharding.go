@@ -0,0 +1,224 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// ShardedTopic is the handle for a logical topic which is sharded into a number of sub-topics,
+// named <prefix>-<shard>. Messages are routed to a shard according to their key.
+type ShardedTopic struct {
+	prefix  string
+	shardFn func(key []byte) int
+	topics  []*Topic
+}
+
+// JoinSharded joins all the shards of a sharded topic and returns a handle for it.
+// The shard function maps a message key to a shard in [0, shards); if it is nil, keys are mapped
+// with an FNV-1a hash, so that all peers agree on the shard of a key.
+// The shard Topic handles are shared with other components that join them with TryJoin.
+func (p *PubSub) JoinSharded(prefix string, shards int, shardFn func(key []byte) int, opts ...TopicOpt) (*ShardedTopic, error) {
+	if shards <= 0 {
+		return nil, fmt.Errorf("invalid number of shards; must be positive")
+	}
+
+	if shardFn == nil {
+		shardFn = func(key []byte) int {
+			h := fnv.New32a()
+			h.Write(key)
+			return int(h.Sum32() % uint32(shards))
+		}
+	}
+
+	st := &ShardedTopic{
+		prefix:  prefix,
+		shardFn: shardFn,
+		topics:  make([]*Topic, 0, shards),
+	}
+
+	for i := 0; i < shards; i++ {
+		t, _, err := p.TryJoin(ShardTopicName(prefix, i), opts...)
+		if err != nil {
+			st.Close()
+			return nil, err
+		}
+		st.topics = append(st.topics, t)
+	}
+
+	return st, nil
+}
+
+// ShardTopicName returns the name of a shard of a sharded topic.
+func ShardTopicName(prefix string, shard int) string {
+	return fmt.Sprintf("%s-%d", prefix, shard)
+}
+
+// String returns the prefix of the sharded topic.
+func (st *ShardedTopic) String() string {
+	return st.prefix
+}
+
+// Shards returns the number of shards.
+func (st *ShardedTopic) Shards() int {
+	return len(st.topics)
+}
+
+// ShardFor returns the shard a key is routed to, or an error if the shard function maps it out of range.
+func (st *ShardedTopic) ShardFor(key []byte) (int, error) {
+	shard := st.shardFn(key)
+	if shard < 0 || shard >= len(st.topics) {
+		return 0, fmt.Errorf("shard function mapped key to shard %d; must be in [0, %d)", shard, len(st.topics))
+	}
+
+	return shard, nil
+}
+
+// Topic returns the Topic handle for a shard.
+func (st *ShardedTopic) Topic(shard int) *Topic {
+	return st.topics[shard]
+}
+
+// Topics returns the Topic handles for all shards.
+func (st *ShardedTopic) Topics() []*Topic {
+	topics := make([]*Topic, len(st.topics))
+	copy(topics, st.topics)
+	return topics
+}
+
+// Publish publishes data to the shard the key is routed to.
+func (st *ShardedTopic) Publish(ctx context.Context, key []byte, data []byte, opts ...PubOpt) error {
+	shard, err := st.ShardFor(key)
+	if err != nil {
+		return err
+	}
+
+	return st.topics[shard].Publish(ctx, data, opts...)
+}
+
+// Subscribe subscribes to all shards and returns a subscription merging their messages.
+// Messages from the same shard are delivered in order, but there is no ordering across shards.
+// The options apply to the subscriptions of the individual shards.
+func (st *ShardedTopic) Subscribe(opts ...SubOpt) (*ShardedSubscription, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ss := &ShardedSubscription{
+		prefix: st.prefix,
+		ch:     make(chan *Message, 32),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for _, t := range st.topics {
+		sub, err := t.Subscribe(opts...)
+		if err != nil {
+			ss.Cancel()
+			return nil, err
+		}
+		ss.subs = append(ss.subs, sub)
+	}
+
+	ss.wg.Add(len(ss.subs))
+	for _, sub := range ss.subs {
+		go ss.forward(sub)
+	}
+
+	go func() {
+		ss.wg.Wait()
+		close(ss.ch)
+	}()
+
+	return ss, nil
+}
+
+// Close closes down the shard topics; see Topic.Close.
+func (st *ShardedTopic) Close() error {
+	var errs []error
+	for _, t := range st.topics {
+		if err := t.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("error closing %s: %w", t, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("error closing sharded topic %s: %v", st.prefix, errs)
+	}
+
+	return nil
+}
+
+// ShardedSubscription is a subscription to all the shards of a sharded topic.
+type ShardedSubscription struct {
+	prefix string
+	subs   []*Subscription
+	ch     chan *Message
+
+	ctx    context.Context
+	cancel func()
+	wg     sync.WaitGroup
+
+	errMx sync.Mutex
+	err   error
+}
+
+// Topic returns the prefix of the sharded topic.
+func (ss *ShardedSubscription) Topic() string {
+	return ss.prefix
+}
+
+// Next returns the next message from any of the shards. Once the subscription is cancelled, it
+// returns ErrSubscriptionCancelled.
+func (ss *ShardedSubscription) Next(ctx context.Context) (*Message, error) {
+	select {
+	case msg, ok := <-ss.ch:
+		if !ok {
+			ss.errMx.Lock()
+			defer ss.errMx.Unlock()
+			return msg, ss.err
+		}
+
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Cancel closes the subscriptions to all shards.
+func (ss *ShardedSubscription) Cancel() {
+	ss.cancel()
+	for _, sub := range ss.subs {
+		sub.Cancel()
+	}
+}
+
+func (ss *ShardedSubscription) forward(sub *Subscription) {
+	defer ss.wg.Done()
+
+	for {
+		msg, err := sub.Next(ss.ctx)
+		if err != nil || msg == nil {
+			if err == nil || ss.ctx.Err() != nil {
+				err = ErrSubscriptionCancelled
+			}
+			ss.fail(err)
+			return
+		}
+
+		select {
+		case ss.ch <- msg:
+		case <-ss.ctx.Done():
+			ss.fail(ErrSubscriptionCancelled)
+			return
+		}
+	}
+}
+
+// fail records the error returned by Next once the messages of the shards are drained, unless an
+// error was recorded already.
+func (ss *ShardedSubscription) fail(err error) {
+	ss.errMx.Lock()
+	defer ss.errMx.Unlock()
+	if ss.err == nil {
+		ss.err = err
+	}
+}
@@ -0,0 +1,122 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestShardedTopic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const shards = 4
+	hosts := getNetHosts(t, ctx, 2)
+	// we publish in a burst, so make room for it in the queues
+	psubs := getPubsubs(ctx, hosts, WithPeerOutboundQueueSize(128), WithValidateQueueSize(128))
+
+	sender, err := psubs[0].JoinSharded("blocks", shards, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver, err := psubs[1].JoinSharded("blocks", shards, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sender.Shards() != shards {
+		t.Fatalf("expected %d shards, got %d", shards, sender.Shards())
+	}
+	for i, topic := range sender.Topics() {
+		if topic.String() != fmt.Sprintf("blocks-%d", i) {
+			t.Fatalf("unexpected shard topic %s", topic)
+		}
+	}
+
+	sub, err := receiver.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Cancel()
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	const nMessages = 40
+	for i := 0; i < nMessages; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		if err := sender.Publish(ctx, key, []byte(fmt.Sprintf("%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	last := make(map[string]int)
+	used := make(map[string]struct{})
+	for i := 0; i < nMessages; i++ {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var n int
+		fmt.Sscanf(string(msg.Data), "%d", &n)
+
+		// the message must have been routed to the shard of its key
+		shard, err := receiver.ShardFor([]byte(fmt.Sprintf("key-%d", n)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.GetTopic() != ShardTopicName("blocks", shard) {
+			t.Fatalf("expected message %d on shard %d, but got it on %s", n, shard, msg.GetTopic())
+		}
+
+		// and the order within the shard must be preserved
+		if prev, ok := last[msg.GetTopic()]; ok && prev > n {
+			t.Fatalf("message %d delivered after message %d on %s", n, prev, msg.GetTopic())
+		}
+		last[msg.GetTopic()] = n
+		used[msg.GetTopic()] = struct{}{}
+	}
+
+	if len(used) < 2 {
+		t.Fatal("expected the messages to be spread across shards")
+	}
+
+	sub.Cancel()
+	if msg, err := sub.Next(ctx); msg != nil || err != ErrSubscriptionCancelled {
+		t.Fatalf("expected the subscription to be cancelled, but got %v, %v", msg, err)
+	}
+}
+
+func TestShardedTopicShardFn(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0])
+
+	st, err := ps.JoinSharded("blocks", 2, func(key []byte) int { return int(key[0]) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shard, err := st.ShardFor([]byte{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shard != 1 {
+		t.Fatalf("expected shard 1, got %d", shard)
+	}
+
+	if err := st.Publish(ctx, []byte{2}, []byte("data")); err == nil {
+		t.Fatal("expected an error publishing to a shard out of range")
+	}
+
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if joined := ps.JoinedTopics(); len(joined) != 0 {
+		t.Fatalf("expected no joined topics, but got %v", joined)
+	}
+}
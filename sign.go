@@ -2,6 +2,7 @@ package pubsub
 
 import (
 	"fmt"
+	"sync"
 
 	pb "github.com/libp2p/go-libp2p-pubsub/pb"
 
@@ -46,21 +47,107 @@ func (policy MessageSignaturePolicy) mustSign() bool {
 
 const SignPrefix = "libp2p-pubsub:"
 
-func verifyMessageSignature(m *pb.Message) error {
-	pubk, err := messagePubKey(m)
-	if err != nil {
-		return err
+// MessageSigner signs the messages published in a topic with an identity other than the libp2p
+// key of the host, such as an application key; see WithTopicSigner.
+type MessageSigner interface {
+	// Author returns the author of the messages, set as their From field.
+	Author() []byte
+	// Sign signs the bytes returned by MessageSignatureData for a message.
+	Sign(data []byte) ([]byte, error)
+}
+
+// TopicSignatureVerifier verifies the signature of a message in a topic; see
+// WithTopicSignatureVerifier.
+type TopicSignatureVerifier func(msg *Message) error
+
+// WithTopicSigner signs the messages we publish in the topic with signer, instead of the libp2p
+// key of the host, regardless of the signature policy; the messages carry the author of the
+// signer in their From field and no Key. The key given to Publish with WithSecretKeyAndPeerId
+// takes precedence.
+// As the author of the messages is not a libp2p peer ID, the peers in the topic, and we ourselves,
+// verify them with a signature verifier installed with WithTopicSignatureVerifier.
+func WithTopicSigner(signer MessageSigner) TopicOpt {
+	return func(t *Topic) error {
+		if signer == nil {
+			return fmt.Errorf("nil topic signer")
+		}
+
+		t.signer = signer
+		return nil
+	}
+}
+
+// WithTopicSignatureVerifier replaces the verification of the signatures of the messages in the
+// topic with verify, for messages whose author is not a libp2p peer ID; verify computes the
+// signed bytes with MessageSignatureData. Messages failing the verifier are rejected as having an
+// invalid signature.
+// In the topic, the signature policy defers to the verifier: the messages are accepted with or
+// without a signature, and from or without an author, as long as the verifier accepts them.
+func WithTopicSignatureVerifier(verify TopicSignatureVerifier) TopicOpt {
+	return func(t *Topic) error {
+		if verify == nil {
+			return fmt.Errorf("nil topic signature verifier")
+		}
+
+		t.sigVerifier = verify
+		return nil
 	}
+}
 
+// MessageSignatureData returns the bytes signed in a message: the message without its signature
+// and key, with the signing prefix.
+func MessageSignatureData(m *pb.Message) ([]byte, error) {
 	xm := *m
 	xm.Signature = nil
 	xm.Key = nil
 	bytes, err := xm.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	return withSignPrefix(bytes), nil
+}
+
+// topicVerifiers tracks the signature verifiers of the topics, as they are read off the event loop
+// when verifying the messages received from a peer.
+type topicVerifiers struct {
+	mx        sync.RWMutex
+	verifiers map[string]TopicSignatureVerifier
+}
+
+func newTopicVerifiers() *topicVerifiers {
+	return &topicVerifiers{verifiers: make(map[string]TopicSignatureVerifier)}
+}
+
+func (tv *topicVerifiers) Set(topic string, verify TopicSignatureVerifier) {
+	tv.mx.Lock()
+	tv.verifiers[topic] = verify
+	tv.mx.Unlock()
+}
+
+func (tv *topicVerifiers) Remove(topic string) {
+	tv.mx.Lock()
+	delete(tv.verifiers, topic)
+	tv.mx.Unlock()
+}
+
+// Get returns the signature verifier of a topic, or nil if the topic uses the default verification.
+func (tv *topicVerifiers) Get(topic string) TopicSignatureVerifier {
+	tv.mx.RLock()
+	defer tv.mx.RUnlock()
+	return tv.verifiers[topic]
+}
+
+func verifyMessageSignature(m *pb.Message) error {
+	pubk, err := messagePubKey(m)
 	if err != nil {
 		return err
 	}
 
-	bytes = withSignPrefix(bytes)
+	bytes, err := MessageSignatureData(m)
+	if err != nil {
+		return err
+	}
 
 	valid, err := pubk.Verify(bytes, m.Signature)
 	if err != nil {
@@ -133,6 +220,22 @@ func signMessage(pid peer.ID, key crypto.PrivKey, m *pb.Message) error {
 	return nil
 }
 
+// signMessageWith signs a message with a topic signer.
+func signMessageWith(signer MessageSigner, m *pb.Message) error {
+	bytes, err := MessageSignatureData(m)
+	if err != nil {
+		return err
+	}
+
+	sig, err := signer.Sign(bytes)
+	if err != nil {
+		return err
+	}
+
+	m.Signature = sig
+	return nil
+}
+
 func withSignPrefix(bytes []byte) []byte {
 	return append([]byte(SignPrefix), bytes...)
 }
@@ -1,7 +1,12 @@
 package pubsub
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	pb "github.com/libp2p/go-libp2p-pubsub/pb"
 
@@ -41,3 +46,174 @@ func testSignVerify(t *testing.T, privk crypto.PrivKey) {
 		t.Fatal(err)
 	}
 }
+
+// appSigner signs messages with a secp256k1 application key, authoring them with the raw public
+// key rather than a peer ID.
+type appSigner struct {
+	key crypto.PrivKey
+}
+
+func (s *appSigner) Author() []byte {
+	author, _ := s.key.GetPublic().Raw()
+	return author
+}
+
+func (s *appSigner) Sign(data []byte) ([]byte, error) {
+	return s.key.Sign(data)
+}
+
+func verifyAppSignature(msg *Message) error {
+	if msg.Signature == nil {
+		return errors.New("missing signature")
+	}
+	pubk, err := crypto.UnmarshalSecp256k1PublicKey(msg.From)
+	if err != nil {
+		return err
+	}
+	data, err := MessageSignatureData(msg.Message)
+	if err != nil {
+		return err
+	}
+	valid, err := pubk.Verify(data, msg.Signature)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func TestTopicSigner(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	appKey, _, err := crypto.GenerateSecp256k1Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &appSigner{key: appKey}
+
+	hosts := getNetHosts(t, ctx, 3)
+	psubs := getGossipsubs(ctx, hosts[:2])
+
+	// the app topic is signed with the app key, the other one with the host key
+	var mx sync.Mutex
+	var rejected []string
+	verify := func(msg *Message) error {
+		err := verifyAppSignature(msg)
+		if err != nil {
+			mx.Lock()
+			rejected = append(rejected, string(msg.GetData()))
+			mx.Unlock()
+		}
+		return err
+	}
+
+	var appSubs, defaultSubs []*Subscription
+	var appTopics, defaultTopics []*Topic
+	for _, ps := range psubs {
+		appTopic, err := ps.Join("app", WithTopicSigner(signer), WithTopicSignatureVerifier(verify))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := appTopic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		appTopics = append(appTopics, appTopic)
+		appSubs = append(appSubs, sub)
+
+		defaultTopic, err := ps.Join("default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err = defaultTopic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defaultTopics = append(defaultTopics, defaultTopic)
+		defaultSubs = append(defaultSubs, sub)
+	}
+
+	// the attacker sends forged and unsigned messages in the app topic, which the strict signing
+	// policy leaves to the verifier of the topic
+	var once sync.Once
+	newMockGS(ctx, t, hosts[2], func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+		once.Do(func() {
+			topic := "app"
+			writeMsg(&pb.RPC{Publish: []*pb.Message{
+				{Data: []byte("forged"), Topic: &topic, From: signer.Author(), Seqno: []byte("forged"), Signature: []byte("forged")},
+				{Data: []byte("unsigned"), Topic: &topic, From: signer.Author(), Seqno: []byte("unsigned")},
+			}})
+		})
+	})
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+	time.Sleep(time.Second)
+
+	if err := appTopics[0].Publish(ctx, []byte("app message")); err != nil {
+		t.Fatal(err)
+	}
+	if err := defaultTopics[0].Publish(ctx, []byte("default message")); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := appSubs[1].Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.GetData()) != "app message" || !bytes.Equal(msg.From, signer.Author()) || msg.Key != nil {
+		t.Fatalf("expected the app message authored by the app key, got %q from %x", msg.GetData(), msg.GetFrom())
+	}
+
+	msg, err = defaultSubs[1].Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.GetData()) != "default message" || msg.GetFrom() != hosts[0].ID() {
+		t.Fatalf("expected the default message authored by the host, got %q from %s", msg.GetData(), msg.GetFrom())
+	}
+	if err := verifyMessageSignature(msg.Message); err != nil {
+		t.Fatal(err)
+	}
+
+	// the messages of the attacker are rejected
+	nctx, ncancel := context.WithTimeout(ctx, time.Second)
+	defer ncancel()
+	if msg, err := appSubs[1].Next(nctx); err == nil {
+		t.Fatalf("expected the messages of the attacker to be rejected, got %q", msg.GetData())
+	}
+	mx.Lock()
+	defer mx.Unlock()
+	if len(rejected) != 2 {
+		t.Fatalf("expected the verifier to reject the 2 messages of the attacker, got %v", rejected)
+	}
+}
+
+func TestTopicSignatureVerifierRemoved(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getGossipsub(ctx, hosts[0])
+
+	topic, err := ps.Join("app", WithTopicSignatureVerifier(verifyAppSignature))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps.sigVerifiers.Get("app") == nil {
+		t.Fatal("expected the topic to have a signature verifier")
+	}
+	if err := topic.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ps.sigVerifiers.Get("app") != nil {
+		t.Fatal("expected the signature verifier to be removed with the topic")
+	}
+
+	if _, err := ps.Join("other", WithTopicSigner(nil)); err == nil {
+		t.Fatal("expected a nil signer to be invalid")
+	}
+}
@@ -0,0 +1,345 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const defaultStandbyMaxMessages = 1024
+
+// ErrStandbyOverflow is returned when activating a standby subscription with StandbyFail, if its
+// buffer overflowed.
+var ErrStandbyOverflow = errors.New("standby subscription buffer overflowed")
+
+// StandbyGapError is returned once by Next on a subscription made with SubscribeWithSnapshot whose
+// buffer overflowed with StandbyDropOldest or StandbyDropNewest: before the buffered messages with
+// the former, after them with the latter. It matches ErrStandbyOverflow with errors.Is; the
+// subscription goes on with the next messages.
+type StandbyGapError struct {
+	Topic string
+	// Dropped is the number of messages dropped.
+	Dropped int
+}
+
+func (e *StandbyGapError) Error() string {
+	return fmt.Sprintf("subscription to %s dropped %d messages while loading the snapshot", e.Topic, e.Dropped)
+}
+
+func (e *StandbyGapError) Is(target error) bool {
+	return target == ErrStandbyOverflow
+}
+
+// StandbyOverflowPolicy determines which messages a standby subscription drops when its buffer is
+// full.
+type StandbyOverflowPolicy int
+
+const (
+	// StandbyDropOldest drops the oldest buffered messages to make room for the new ones. This is
+	// the default for SubscribeStandby.
+	StandbyDropOldest StandbyOverflowPolicy = iota
+	// StandbyDropNewest drops the new messages, keeping the buffered ones.
+	StandbyDropNewest
+	// StandbyFail drops the new messages, like StandbyDropNewest, and fails the activation with
+	// ErrStandbyOverflow, for the consumers that cannot tolerate a gap in the messages. This is the
+	// default for SubscribeWithSnapshot.
+	StandbyFail
+)
+
+// StandbyOpt is an option for Topic.SubscribeStandby.
+type StandbyOpt func(sb *standbyBuffer) error
+
+// WithStandbyMaxMessages bounds the number of messages buffered by a standby subscription. The
+// default is 1024.
+func WithStandbyMaxMessages(n int) StandbyOpt {
+	return func(sb *standbyBuffer) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid standby buffer size; must be positive")
+		}
+		sb.maxMessages = n
+		return nil
+	}
+}
+
+// WithStandbyMaxBytes bounds the memory held by the messages buffered by a standby subscription, in
+// bytes of message Size. By default only the number of messages is bounded.
+func WithStandbyMaxBytes(n int64) StandbyOpt {
+	return func(sb *standbyBuffer) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid standby buffer memory; must be positive")
+		}
+		sb.maxBytes = n
+		return nil
+	}
+}
+
+// WithStandbyOverflowPolicy sets which messages a standby subscription drops when its buffer is
+// full.
+func WithStandbyOverflowPolicy(policy StandbyOverflowPolicy) StandbyOpt {
+	return func(sb *standbyBuffer) error {
+		switch policy {
+		case StandbyDropOldest, StandbyDropNewest, StandbyFail:
+			sb.policy = policy
+			return nil
+		default:
+			return fmt.Errorf("unknown standby overflow policy %d", policy)
+		}
+	}
+}
+
+// WithStandbySubOpts sets the options of the subscription a standby subscription turns into when
+// activated.
+func WithStandbySubOpts(subOpts ...SubOpt) StandbyOpt {
+	return func(sb *standbyBuffer) error {
+		sb.subOpts = append(sb.subOpts, subOpts...)
+		return nil
+	}
+}
+
+// StandbySubscription is a subscription that keeps the validated messages of the last buffer
+// window, instead of delivering them, until it is activated; see Topic.SubscribeStandby.
+type StandbySubscription struct {
+	sub *Subscription
+	p   *PubSub
+}
+
+// SubscribeStandby returns a standby subscription to the topic, for instance for a failover replica
+// that must stay current in the topic without processing its messages until it is promoted. Like
+// with Subscribe, we join the topic: we take part in the mesh, validate and forward the messages,
+// but the validated messages are kept in a buffer, for bufferWindow after their delivery, instead
+// of being delivered. Activate turns the standby subscription into a Subscription, delivering the
+// buffered messages first, in order, then the new ones.
+// The buffer is bounded in number of messages, and optionally in memory; when it is full, messages
+// are dropped according to the overflow policy, by default the oldest first. The buffered messages
+// are accounted against the memory budget, if any, like the ones in a subscription buffer.
+func (t *Topic) SubscribeStandby(bufferWindow time.Duration, opts ...StandbyOpt) (*StandbySubscription, error) {
+	if bufferWindow <= 0 {
+		return nil, fmt.Errorf("invalid standby buffer window; must be positive")
+	}
+
+	return t.subscribeStandby(bufferWindow, opts)
+}
+
+// SubscribeWithSnapshot subscribes to the topic for a consumer that loads a snapshot of its state
+// before applying the messages, such as the updates of a CRDT. It subscribes first, buffering the
+// messages like a standby subscription without a buffer window, then calls fetchSnapshot; once it
+// returns successfully, it returns the subscription, which delivers the buffered messages, in
+// order, then the new ones. No message validated after the subscription is missed, and none is
+// delivered before the snapshot is loaded; the messages validated while fetching the snapshot may
+// already be reflected in it, so the consumer must apply them idempotently.
+// The buffer is bounded as with SubscribeStandby, to 1024 messages by default. When it overflows
+// during a slow snapshot, the subscription is cancelled and ErrStandbyOverflow returned, unless
+// another overflow policy is set; the messages dropped by the other policies are then reported by
+// Next with a StandbyGapError.
+// If fetchSnapshot fails, the subscription is cancelled and its error returned.
+func (t *Topic) SubscribeWithSnapshot(ctx context.Context, fetchSnapshot func(ctx context.Context) error, opts ...StandbyOpt) (*Subscription, error) {
+	snapshot := func(sb *standbyBuffer) error {
+		sb.policy = StandbyFail
+		sb.reportGaps = true
+		return nil
+	}
+	standby, err := t.subscribeStandby(0, append([]StandbyOpt{snapshot}, opts...))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fetchSnapshot(ctx); err != nil {
+		standby.Cancel()
+		return nil, err
+	}
+
+	sub, err := standby.Activate()
+	if err != nil {
+		standby.Cancel()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// subscribeStandby returns a standby subscription to the topic, buffering the messages of the last
+// window, or all of them without a window.
+func (t *Topic) subscribeStandby(window time.Duration, opts []StandbyOpt) (*StandbySubscription, error) {
+	sb := &standbyBuffer{
+		window:      window,
+		maxMessages: defaultStandbyMaxMessages,
+	}
+	for _, opt := range opts {
+		if err := opt(sb); err != nil {
+			return nil, err
+		}
+	}
+
+	subOpts := append(sb.subOpts, func(sub *Subscription) error {
+		sub.standby = sb
+		return nil
+	})
+	sb.subOpts = nil
+
+	sub, err := t.Subscribe(subOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StandbySubscription{sub: sub, p: t.p}, nil
+}
+
+// Topic returns the topic string associated with the standby subscription.
+func (s *StandbySubscription) Topic() string {
+	return s.sub.topic
+}
+
+// Activate turns the standby subscription into a Subscription, which first delivers the messages
+// buffered in the window, in the order they were validated, then the new messages. The
+// subscription buffer is grown to hold the buffered messages. It returns an error if the standby
+// subscription was already activated or was cancelled, or ErrStandbyOverflow if its buffer
+// overflowed with StandbyFail, in which case it stays on standby.
+func (s *StandbySubscription) Activate() (*Subscription, error) {
+	res := make(chan error, 1)
+	select {
+	case s.p.eval <- func() {
+		res <- s.p.activateStandby(s.sub)
+	}:
+	case <-s.p.ctx.Done():
+		return nil, s.p.ctx.Err()
+	}
+
+	if err := <-res; err != nil {
+		return nil, err
+	}
+	return s.sub, nil
+}
+
+// Cancel cancels the standby subscription, dropping its buffered messages; once activated, it
+// cancels the Subscription as well.
+func (s *StandbySubscription) Cancel() {
+	s.sub.Cancel()
+}
+
+// activateStandby delivers the messages buffered by a standby subscription and has it deliver the
+// new ones.
+// Only called from processLoop.
+func (p *PubSub) activateStandby(sub *Subscription) error {
+	if _, ok := p.mySubs[sub.topic][sub]; !ok {
+		return ErrSubscriptionCancelled
+	}
+	sb := sub.standby
+	if sb == nil {
+		return fmt.Errorf("standby subscription already activated")
+	}
+	if sb.overflowed {
+		return ErrStandbyOverflow
+	}
+
+	sb.expire(sub, p.clock.Now())
+	pending := sb.msgs[sb.head:]
+
+	// the subscription is not handed out before activation, so its buffer is still empty and
+	// can be replaced; the gap, if reported, is marked where the messages were dropped
+	var gap *Message
+	if sb.reportGaps && sb.dropped > 0 {
+		gap = &Message{}
+		sub.gap = gap
+		sub.gapErr = &StandbyGapError{Topic: sub.topic, Dropped: sb.dropped}
+	}
+	sub.ch = make(chan *Message, cap(sub.ch)+len(pending)+1)
+	if gap != nil && sb.policy == StandbyDropOldest {
+		sub.ch <- gap
+	}
+	for _, bm := range pending {
+		sub.ch <- bm.msg
+	}
+	if gap != nil && sb.policy == StandbyDropNewest {
+		sub.ch <- gap
+	}
+	sub.standby = nil
+
+	return nil
+}
+
+// standbyBuffer holds the messages of a standby subscription, in the order they were validated.
+// Only accessed from processLoop, once the subscription is added.
+type standbyBuffer struct {
+	window      time.Duration
+	maxMessages int
+	maxBytes    int64
+	policy      StandbyOverflowPolicy
+	subOpts     []SubOpt
+
+	msgs  []standbyMessage
+	head  int
+	bytes int64
+	// whether a message was dropped with StandbyFail
+	overflowed bool
+	// the number of messages dropped on overflow, reported as a gap by Next if reportGaps
+	dropped    int
+	reportGaps bool
+}
+
+type standbyMessage struct {
+	msg  *Message
+	size int64
+	at   time.Time
+}
+
+// add buffers a message delivered to the standby subscription at now, dropping the messages that
+// left the window and, if the buffer is full, the ones the overflow policy selects.
+func (sb *standbyBuffer) add(sub *Subscription, msg *Message, now time.Time) {
+	sb.expire(sub, now)
+
+	size := int64(msg.Size())
+	for sb.full(size) {
+		sb.dropped++
+		if sb.policy == StandbyFail {
+			sb.overflowed = true
+			return
+		}
+		if sb.policy == StandbyDropNewest || sb.head == len(sb.msgs) {
+			return
+		}
+		sb.pop(sub)
+	}
+
+	sub.acquire(msg)
+	sb.msgs = append(sb.msgs, standbyMessage{msg: msg, size: size, at: now})
+	sb.bytes += size
+}
+
+// full returns whether a message of size does not fit in the buffer.
+func (sb *standbyBuffer) full(size int64) bool {
+	if len(sb.msgs)-sb.head >= sb.maxMessages {
+		return true
+	}
+	return sb.maxBytes > 0 && sb.bytes+size > sb.maxBytes
+}
+
+// expire drops the messages buffered before the window ending at now, if any.
+func (sb *standbyBuffer) expire(sub *Subscription, now time.Time) {
+	if sb.window == 0 {
+		return
+	}
+
+	cutoff := now.Add(-sb.window)
+	for sb.head < len(sb.msgs) && sb.msgs[sb.head].at.Before(cutoff) {
+		sb.pop(sub)
+	}
+}
+
+// pop drops the oldest buffered message.
+func (sb *standbyBuffer) pop(sub *Subscription) {
+	bm := sb.msgs[sb.head]
+	sb.msgs[sb.head] = standbyMessage{}
+	sb.head++
+	sb.bytes -= bm.size
+	sub.release(bm.msg)
+
+	// reclaim the space of the dropped messages once they are the majority
+	if sb.head > len(sb.msgs)/2 {
+		n := copy(sb.msgs, sb.msgs[sb.head:])
+		for i := n; i < len(sb.msgs); i++ {
+			sb.msgs[i] = standbyMessage{}
+		}
+		sb.msgs = sb.msgs[:n]
+		sb.head = 0
+	}
+}
@@ -0,0 +1,384 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+func TestStandbySubscriptionReplay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := getPubsubs(ctx, hosts)
+
+	topics := getTopics(psubs, "foobar")
+	active, err := topics[1].Subscribe(WithBufferSize(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	standby, err := topics[1].SubscribeStandby(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	const count = 50
+	go func() {
+		for i := 0; i < count; i++ {
+			if err := topics[0].Publish(ctx, []byte(fmt.Sprintf("message %d", i))); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	// promote the standby subscription while the messages are flowing
+	var seen []string
+	for len(seen) < count/5 {
+		msg, err := active.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen = append(seen, msg.ID)
+	}
+	promoted, err := standby.Activate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the publisher may drop messages when its outbound queue is full, which affects both
+	// subscriptions alike
+	for len(seen) < count {
+		rctx, rcancel := context.WithTimeout(ctx, time.Second)
+		msg, err := active.Next(rctx)
+		rcancel()
+		if err != nil {
+			break
+		}
+		seen = append(seen, msg.ID)
+	}
+
+	// the promoted subscription gets the same messages, in the same order
+	for i, id := range seen {
+		rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+		msg, err := promoted.Next(rctx)
+		rcancel()
+		if err != nil {
+			t.Fatalf("expected message %d: %s", i, err)
+		}
+		if msg.ID != id {
+			t.Fatalf("expected message %d to be %q, got %q", i, id, msg.Data)
+		}
+	}
+	expectNoMessage(t, ctx, promoted)
+}
+
+func TestStandbySubscriptionWindow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0])
+	clk := clock.NewMock()
+	ps.clock = clk
+
+	topic, err := ps.Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	standby, err := topic.SubscribeStandby(30 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publishStandby(t, ctx, ps, topic, "old", 3)
+	clk.Add(20 * time.Second)
+	publishStandby(t, ctx, ps, topic, "recent", 3)
+	// the old messages leave the window, the recent ones are still in it
+	clk.Add(15 * time.Second)
+
+	sub, err := standby.Activate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		expectMessage(t, ctx, sub, fmt.Sprintf("recent %d", i))
+	}
+	expectNoMessage(t, ctx, sub)
+
+	// then it is a normal subscription
+	publishStandby(t, ctx, ps, topic, "live", 1)
+	expectMessage(t, ctx, sub, "live 0")
+}
+
+func TestStandbySubscriptionOverflow(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		opts   []StandbyOpt
+		expect []string
+	}{
+		{"drop oldest", []StandbyOpt{WithStandbyMaxMessages(3)}, []string{"msg 2", "msg 3", "msg 4"}},
+		{
+			"drop newest",
+			[]StandbyOpt{WithStandbyMaxMessages(3), WithStandbyOverflowPolicy(StandbyDropNewest)},
+			[]string{"msg 0", "msg 1", "msg 2"},
+		},
+		{"max bytes", []StandbyOpt{WithStandbyMaxBytes(1)}, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			hosts := getNetHosts(t, ctx, 1)
+			ps := getPubsub(ctx, hosts[0])
+			topic, err := ps.Join("foobar")
+			if err != nil {
+				t.Fatal(err)
+			}
+			standby, err := topic.SubscribeStandby(time.Minute, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			publishStandby(t, ctx, ps, topic, "msg", 5)
+
+			sub, err := standby.Activate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, data := range tc.expect {
+				expectMessage(t, ctx, sub, data)
+			}
+			expectNoMessage(t, ctx, sub)
+		})
+	}
+}
+
+func TestStandbySubscriptionErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0])
+	topic, err := ps.Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		window time.Duration
+		opts   []StandbyOpt
+	}{
+		{0, nil},
+		{time.Minute, []StandbyOpt{WithStandbyMaxMessages(0)}},
+		{time.Minute, []StandbyOpt{WithStandbyMaxBytes(-1)}},
+		{time.Minute, []StandbyOpt{WithStandbyOverflowPolicy(StandbyOverflowPolicy(42))}},
+	} {
+		if _, err := topic.SubscribeStandby(tc.window, tc.opts...); err == nil {
+			t.Fatalf("expected an error for window %s", tc.window)
+		}
+	}
+
+	standby, err := topic.SubscribeStandby(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := standby.Activate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := standby.Activate(); err == nil {
+		t.Fatal("expected an error activating twice")
+	}
+
+	standby, err = topic.SubscribeStandby(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	standby.Cancel()
+	if _, err := standby.Activate(); err != ErrSubscriptionCancelled {
+		t.Fatalf("expected ErrSubscriptionCancelled, got %v", err)
+	}
+}
+
+// publishStandby publishes count messages, and waits for the event loop to deliver them.
+func publishStandby(t *testing.T, ctx context.Context, ps *PubSub, topic *Topic, prefix string, count int) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		if err := topic.Publish(ctx, []byte(fmt.Sprintf("%s %d", prefix, i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the messages are validated asynchronously
+	time.Sleep(100 * time.Millisecond)
+	done := make(chan struct{})
+	ps.eval <- func() { close(done) }
+	<-done
+}
+
+func TestSubscribeWithSnapshot(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0])
+	topic, err := ps.Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a publisher races the snapshot
+	const count = 200
+	var published int32
+	go func() {
+		for i := 0; i < count; i++ {
+			if err := topic.Publish(ctx, []byte(fmt.Sprint(i))); err != nil {
+				t.Error(err)
+				return
+			}
+			atomic.StoreInt32(&published, int32(i+1))
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	for atomic.LoadInt32(&published) < 20 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the messages published after the subscription are delivered; the message being published
+	// when fetching the snapshot starts may have been validated before
+	var start, during int
+	sub, err := topic.SubscribeWithSnapshot(ctx, func(ctx context.Context) error {
+		start = int(atomic.LoadInt32(&published))
+		time.Sleep(50 * time.Millisecond)
+		during = int(atomic.LoadInt32(&published)) - start
+		return nil
+	}, WithStandbySubOpts(WithBufferSize(count)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if during == 0 {
+		t.Fatal("expected messages to be published while fetching the snapshot")
+	}
+
+	// no gap from the subscription on: the messages published since then all come, in order
+	next := -1
+	for next != count {
+		rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+		msg, err := sub.Next(rctx)
+		rcancel()
+		if err != nil {
+			t.Fatalf("expected message %d: %s", next, err)
+		}
+		var i int
+		if _, err := fmt.Sscan(string(msg.Data), &i); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case next == -1 && i > start+1:
+			t.Fatalf("missed the messages from %d to %d", start+1, i-1)
+		case next != -1 && i != next:
+			t.Fatalf("expected message %d, got %d", next, i)
+		}
+		next = i + 1
+	}
+}
+
+func TestSubscribeWithSnapshotOverflow(t *testing.T) {
+	// the gap is reported by Next before the message at gapAt
+	for _, tc := range []struct {
+		name   string
+		opts   []StandbyOpt
+		expect []string
+		gapAt  int
+		err    error
+	}{
+		{"default", nil, nil, -1, ErrStandbyOverflow},
+		{"fail", []StandbyOpt{WithStandbyOverflowPolicy(StandbyFail)}, nil, -1, ErrStandbyOverflow},
+		{
+			"drop oldest",
+			[]StandbyOpt{WithStandbyOverflowPolicy(StandbyDropOldest)},
+			[]string{"msg 2", "msg 3", "msg 4"},
+			0,
+			nil,
+		},
+		{
+			"drop newest",
+			[]StandbyOpt{WithStandbyOverflowPolicy(StandbyDropNewest)},
+			[]string{"msg 0", "msg 1", "msg 2"},
+			3,
+			nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			hosts := getNetHosts(t, ctx, 1)
+			ps := getPubsub(ctx, hosts[0])
+			topic, err := ps.Join("foobar")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// a slow snapshot, during which the buffer overflows
+			sub, err := topic.SubscribeWithSnapshot(ctx, func(ctx context.Context) error {
+				publishStandby(t, ctx, ps, topic, "msg", 5)
+				return nil
+			}, append([]StandbyOpt{WithStandbyMaxMessages(3)}, tc.opts...)...)
+			if err != tc.err {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if err != nil {
+				return
+			}
+			for i := 0; i <= len(tc.expect); i++ {
+				if i == tc.gapAt {
+					expectStandbyGap(t, ctx, sub, 2)
+				}
+				if i < len(tc.expect) {
+					expectMessage(t, ctx, sub, tc.expect[i])
+				}
+			}
+			expectNoMessage(t, ctx, sub)
+		})
+	}
+}
+
+func expectStandbyGap(t *testing.T, ctx context.Context, sub *Subscription, dropped int) {
+	t.Helper()
+
+	rctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	msg, err := sub.Next(rctx)
+	var gap *StandbyGapError
+	if !errors.As(err, &gap) || gap.Dropped != dropped || !errors.Is(err, ErrStandbyOverflow) {
+		t.Fatalf("expected a gap of %d messages, got %v, %v", dropped, msg, err)
+	}
+}
+
+func TestSubscribeWithSnapshotError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0])
+	topic, err := ps.Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errSnapshot := fmt.Errorf("snapshot unavailable")
+	if _, err := topic.SubscribeWithSnapshot(ctx, func(context.Context) error { return errSnapshot }); err != errSnapshot {
+		t.Fatalf("expected the snapshot error, got %v", err)
+	}
+
+	// the subscription was cancelled, so the topic can be closed
+	waitFor(t, "the subscription to be cancelled", func() bool { return topic.Close() == nil })
+}
@@ -0,0 +1,328 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// StoreAndForwardInterval is the interval at which the messages stored with WithStoreAndForward
+// are routed again; they are also routed again when a peer joins the topic.
+var StoreAndForwardInterval = time.Second
+
+// WithStoreAndForward is a topic option that persists the messages we publish in the topic while
+// there are no peers to send them to, and routes them again once there are, until they have been
+// sent to some peer or are older than maxAge; at most maxEntries messages are stored, dropping the
+// oldest ones. The messages are stored under a prefix specific to the topic, so the store can be
+// shared, and they are loaded again when the topic is joined with the same store, so that they
+// survive restarts.
+// Stored messages are sent again as published, with their original sequence number and signature,
+// regardless of our seen messages cache.
+// The option has no effect with WithLocalPublication, or with routers other than the ones provided
+// by this package.
+func WithStoreAndForward(store datastore.Batching, maxAge time.Duration, maxEntries int) TopicOpt {
+	return func(t *Topic) error {
+		if store == nil {
+			return fmt.Errorf("nil store-and-forward datastore")
+		}
+		if maxAge <= 0 {
+			return fmt.Errorf("invalid store-and-forward maximum age; must be positive")
+		}
+		if maxEntries < 1 {
+			return fmt.Errorf("invalid store-and-forward maximum entries; must be at least 1")
+		}
+
+		t.storeForward = &storeAndForward{
+			p:          t.p,
+			topic:      t.topic,
+			logger:     withFields(t.p.logger, "topic", t.topic),
+			store:      store,
+			prefix:     datastore.NewKey("/pubsub/store-and-forward").ChildString(hex.EncodeToString([]byte(t.topic))),
+			maxAge:     maxAge,
+			maxEntries: maxEntries,
+			wake:       make(chan struct{}, 1),
+		}
+		return nil
+	}
+}
+
+// storeAndForward stores the messages of a topic that could not be sent to any peer, and routes
+// them again in its own goroutine.
+type storeAndForward struct {
+	p      *PubSub
+	topic  string
+	logger Logger
+
+	store      datastore.Batching
+	prefix     datastore.Key
+	maxAge     time.Duration
+	maxEntries int
+
+	ctx    context.Context
+	cancel func()
+	wake   chan struct{}
+
+	// messages waiting to be stored
+	mx      sync.Mutex
+	pending []storedMessage
+
+	// stored messages, oldest first; only accessed from the loop
+	entries []storedMessage
+}
+
+type storedMessage struct {
+	key    datastore.Key
+	msg    *pb.Message
+	stored time.Time
+}
+
+// storeForward returns the store-and-forward of the topic of a message we published, if any.
+// Only called from processLoop.
+func (p *PubSub) storeForward(msg *Message) *storeAndForward {
+	if msg.ReceivedFrom != p.tr.ID() {
+		return nil
+	}
+	t, ok := p.myTopics[msg.GetTopic()]
+	if !ok {
+		return nil
+	}
+	return t.storeForward
+}
+
+// start starts the loop routing the stored messages again.
+func (s *storeAndForward) start() {
+	s.ctx, s.cancel = context.WithCancel(s.p.ctx)
+	go s.loop()
+}
+
+// stop stops the loop; stored messages stay in the store.
+func (s *storeAndForward) stop() {
+	s.cancel()
+}
+
+// add queues a message that could not be sent to any peer for storage.
+func (s *storeAndForward) add(msg *Message) {
+	s.mx.Lock()
+	s.pending = append(s.pending, storedMessage{msg: msg.Message, stored: time.Now()})
+	s.mx.Unlock()
+	s.notify()
+}
+
+// notify wakes up the loop.
+func (s *storeAndForward) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *storeAndForward) loop() {
+	s.load()
+
+	ticker := time.NewTicker(StoreAndForwardInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.wake:
+		case <-s.ctx.Done():
+			return
+		}
+
+		s.persist()
+		s.expire(time.Now())
+		s.resend()
+	}
+}
+
+// load loads the messages stored by a previous instance.
+func (s *storeAndForward) load() {
+	res, err := s.store.Query(s.ctx, query.Query{Prefix: s.prefix.String()})
+	if err != nil {
+		s.logger.Warnf("error loading stored messages: %s", err)
+		return
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		s.logger.Warnf("error loading stored messages: %s", err)
+		return
+	}
+
+	for _, e := range entries {
+		m, err := decodeStoredMessage(datastore.NewKey(e.Key), e.Value)
+		if err != nil {
+			s.logger.Warnf("error decoding stored message %s: %s", e.Key, err)
+			continue
+		}
+		s.entries = append(s.entries, m)
+	}
+	sort.Slice(s.entries, func(i, j int) bool { return s.entries[i].stored.Before(s.entries[j].stored) })
+	s.drop(len(s.entries) - s.maxEntries)
+}
+
+// persist stores the pending messages, dropping the oldest messages over the limit.
+func (s *storeAndForward) persist() {
+	s.mx.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mx.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	b, err := s.store.Batch(s.ctx)
+	if err != nil {
+		s.logger.Warnf("error storing messages: %s", err)
+		return
+	}
+
+	for _, m := range pending {
+		m.key = s.prefix.ChildString(hex.EncodeToString([]byte(s.p.idGen.RawID(m.msg))))
+		data, err := encodeStoredMessage(m)
+		if err != nil {
+			s.logger.Warnf("error encoding message: %s", err)
+			continue
+		}
+		if err := b.Put(s.ctx, m.key, data); err != nil {
+			s.logger.Warnf("error storing message: %s", err)
+			continue
+		}
+		s.entries = append(s.entries, m)
+	}
+
+	if err := b.Commit(s.ctx); err != nil {
+		s.logger.Warnf("error storing messages: %s", err)
+	}
+
+	if n := len(s.entries) - s.maxEntries; n > 0 {
+		s.logger.Warnf("dropping %d stored messages: too many messages", n)
+		s.drop(n)
+	}
+}
+
+// expire drops the messages older than the maximum age.
+func (s *storeAndForward) expire(now time.Time) {
+	n := 0
+	for n < len(s.entries) && now.Sub(s.entries[n].stored) > s.maxAge {
+		n++
+	}
+	if n > 0 {
+		s.logger.Debugf("dropping %d expired stored messages", n)
+		s.drop(n)
+	}
+}
+
+// drop deletes the n oldest messages.
+func (s *storeAndForward) drop(n int) {
+	if n <= 0 {
+		return
+	}
+
+	s.delete(s.entries[:n])
+	s.entries = s.entries[n:]
+}
+
+// delete deletes messages from the store.
+func (s *storeAndForward) delete(entries []storedMessage) {
+	b, err := s.store.Batch(s.ctx)
+	if err != nil {
+		s.logger.Warnf("error deleting stored messages: %s", err)
+		return
+	}
+	for _, m := range entries {
+		if err := b.Delete(s.ctx, m.key); err != nil {
+			s.logger.Warnf("error deleting stored message: %s", err)
+		}
+	}
+	if err := b.Commit(s.ctx); err != nil {
+		s.logger.Warnf("error deleting stored messages: %s", err)
+	}
+}
+
+// resend routes the stored messages again if there are peers in the topic, deleting the messages
+// that could be sent to some peer.
+func (s *storeAndForward) resend() {
+	if len(s.entries) == 0 {
+		return
+	}
+
+	sent := make(chan []bool, 1)
+	select {
+	case s.p.eval <- func() {
+		rr, ok := s.p.rt.(retryRouter)
+		if !ok || len(s.p.topics[s.topic]) == 0 {
+			sent <- nil
+			return
+		}
+
+		res := make([]bool, len(s.entries))
+		for i, m := range s.entries {
+			msg := &Message{Message: m.msg, ReceivedFrom: s.p.tr.ID()}
+			var r PublishResult
+			rr.route(msg, &r)
+			res[i] = r.Recipients > r.QueueFull
+		}
+		sent <- res
+	}:
+	case <-s.ctx.Done():
+		return
+	}
+
+	res := <-sent
+	if res == nil {
+		return
+	}
+
+	var done, left []storedMessage
+	for i, m := range s.entries {
+		if res[i] {
+			done = append(done, m)
+		} else {
+			left = append(left, m)
+		}
+	}
+	if len(done) > 0 {
+		s.logger.Debugf("forwarded %d stored messages", len(done))
+		s.delete(done)
+	}
+	s.entries = left
+}
+
+// encodeStoredMessage encodes a stored message as its storage time followed by the message.
+func encodeStoredMessage(m storedMessage) ([]byte, error) {
+	data := make([]byte, 8, 8+m.msg.Size())
+	binary.BigEndian.PutUint64(data, uint64(m.stored.UnixNano()))
+	n, err := m.msg.MarshalTo(data[8:cap(data)])
+	if err != nil {
+		return nil, err
+	}
+	return data[:8+n], nil
+}
+
+func decodeStoredMessage(key datastore.Key, data []byte) (storedMessage, error) {
+	if len(data) < 8 {
+		return storedMessage{}, fmt.Errorf("stored message too short")
+	}
+
+	msg := new(pb.Message)
+	if err := msg.Unmarshal(data[8:]); err != nil {
+		return storedMessage{}, err
+	}
+
+	return storedMessage{
+		key:    key,
+		msg:    msg,
+		stored: time.Unix(0, int64(binary.BigEndian.Uint64(data))),
+	}, nil
+}
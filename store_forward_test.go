@@ -0,0 +1,171 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+func storedMessages(t *testing.T, store datastore.Batching) int {
+	t.Helper()
+	res, err := store.Query(context.Background(), query.Query{KeysOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func waitStoredMessages(t *testing.T, store datastore.Batching, n int) {
+	t.Helper()
+	for i := 0; storedMessages(t, store) != n; i++ {
+		if i == 50 {
+			t.Fatalf("expected %d stored messages, got %d", n, storedMessages(t, store))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestStoreAndForward(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "alerts"
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := getGossipsubs(ctx, hosts)
+	store := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	tp, err := psubs[0].Join(topic, WithStoreAndForward(store, time.Minute, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// our own subscription gets the message once
+	own, err := tp.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// no peers, the message is stored
+	if err := tp.Publish(ctx, []byte("alert")); err != nil {
+		t.Fatal(err)
+	}
+	waitStoredMessages(t, store, 1)
+
+	sub, err := psubs[1].Subscribe(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, hosts[0], hosts[1])
+
+	rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+	defer rcancel()
+	msg, err := sub.Next(rctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Data) != "alert" || msg.GetFrom() != hosts[0].ID() {
+		t.Fatalf("unexpected message %q from %s", msg.Data, msg.GetFrom())
+	}
+	waitStoredMessages(t, store, 0)
+
+	if _, err := own.Next(ctx); err != nil {
+		t.Fatal(err)
+	}
+	octx, ocancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer ocancel()
+	if _, err := own.Next(octx); err == nil {
+		t.Fatal("the stored message was delivered twice")
+	}
+}
+
+func TestStoreAndForwardLimits(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "alerts"
+	hosts := getNetHosts(t, ctx, 4)
+	store := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	// the oldest messages over the limit are dropped
+	pctx, pcancel := context.WithCancel(ctx)
+	ps := getGossipsub(pctx, hosts[0])
+	tp, err := ps.Join(topic, WithStoreAndForward(store, time.Minute, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []string{"1", "2", "3"} {
+		if err := tp.Publish(ctx, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitStoredMessages(t, store, 2)
+	pcancel()
+
+	// stored messages survive restarts, and are forwarded with their original signature
+	ps = getGossipsub(ctx, hosts[1])
+	if _, err := ps.Join(topic, WithStoreAndForward(store, time.Minute, 2)); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := getGossipsub(ctx, hosts[2]).Subscribe(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, hosts[1], hosts[2])
+
+	for _, data := range []string{"2", "3"} {
+		rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+		msg, err := sub.Next(rctx)
+		rcancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(msg.Data) != data || msg.GetFrom() != hosts[0].ID() {
+			t.Fatalf("expected message %q from %s, got %q from %s", data, hosts[0].ID(), msg.Data, msg.GetFrom())
+		}
+	}
+	waitStoredMessages(t, store, 0)
+
+	// over-age messages expire
+	tp, err = getGossipsub(ctx, hosts[3]).Join(topic, WithStoreAndForward(store, 100*time.Millisecond, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tp.Publish(ctx, []byte("expired")); err != nil {
+		t.Fatal(err)
+	}
+	waitStoredMessages(t, store, 1)
+	waitStoredMessages(t, store, 0)
+
+	connect(t, hosts[2], hosts[3])
+	rctx, rcancel := context.WithTimeout(ctx, 2*StoreAndForwardInterval)
+	defer rcancel()
+	if msg, err := sub.Next(rctx); err == nil {
+		t.Fatalf("unexpected message %q", msg.Data)
+	}
+}
+
+func TestStoreAndForwardParams(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getGossipsub(ctx, hosts[0])
+	store := datastore.NewMapDatastore()
+
+	if _, err := ps.Join("a", WithStoreAndForward(nil, time.Minute, 1)); err == nil {
+		t.Fatal("expected an error for a nil store")
+	}
+	if _, err := ps.Join("b", WithStoreAndForward(store, 0, 1)); err == nil {
+		t.Fatal("expected an error for a zero maximum age")
+	}
+	if _, err := ps.Join("c", WithStoreAndForward(store, time.Minute, 0)); err == nil {
+		t.Fatal("expected an error for zero maximum entries")
+	}
+}
@@ -0,0 +1,113 @@
+package pubsub
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// StreamHandoverTimeout is how long a new inbound stream from a peer waits for its previous stream
+// to be drained before resetting it; see WithConnPreference.
+var StreamHandoverTimeout = time.Second
+
+// inboundStream is the inbound stream of a peer, which is done when its reader has returned.
+type inboundStream struct {
+	s    TransportStream
+	done chan struct{}
+}
+
+// ConnPreference returns true if the connection a is preferred to b for the outbound stream to a
+// peer.
+type ConnPreference func(a, b ConnInfo) bool
+
+// PreferDirectConns is the default connection preference: direct connections are preferred to
+// relayed ones, then connections with a lower latency, when it is known.
+func PreferDirectConns(a, b ConnInfo) bool {
+	if a.Relayed != b.Relayed {
+		return !a.Relayed
+	}
+	return a.Latency > 0 && b.Latency > 0 && a.Latency < b.Latency
+}
+
+// WithConnPreference sets the preference between the connections to a peer; the default is
+// PreferDirectConns. When a new connection to a peer is preferred to the one carrying our outbound
+// stream, e.g. when a direct connection appears next to a relayed one, a new stream is opened on it
+// and the writer switches to it between two RPCs, closing the old stream; the peer drains the old
+// stream before reading the new one, so no RPC is lost or reordered.
+// It only applies to transports implementing ConnTransport, such as the default libp2p transport;
+// a nil preference keeps the outbound streams on their connection.
+func WithConnPreference(pref ConnPreference) Option {
+	return func(p *PubSub) error {
+		p.connPref = pref
+		return nil
+	}
+}
+
+// upgradeStream moves the outbound stream of a peer to the preferred connection, if it is not on it.
+// Only called from processLoop.
+func (p *PubSub) upgradeStream(pid peer.ID) {
+	ct, ok := p.tr.(ConnTransport)
+	if !ok || p.connPref == nil {
+		return
+	}
+
+	w, ok := p.writers[pid]
+	if !ok || w.stream == nil || atomic.LoadInt32(&w.exited) != 0 {
+		return
+	}
+
+	cur, ok := ct.StreamConn(w.stream)
+	if !ok {
+		return
+	}
+
+	best := cur
+	for _, c := range ct.Conns(pid) {
+		if c.Transient {
+			continue
+		}
+		if p.connPref(c, best) {
+			best = c
+		}
+	}
+	if best.ID == cur.ID {
+		return
+	}
+
+	if !atomic.CompareAndSwapInt32(&w.upgrading, 0, 1) {
+		return
+	}
+
+	p.logger.Debugw("moving outbound stream to a better connection", "peer", pid, "from", cur.ID, "to", best.ID, "relayed", best.Relayed)
+	go p.handleStreamUpgrade(w, ct, best)
+}
+
+// handleStreamUpgrade opens the new stream of a writer, and hands it over to the writer.
+func (p *PubSub) handleStreamUpgrade(w *peerWriter, ct ConnTransport, c ConnInfo) {
+	s, err := ct.NewStreamOnConn(w.ctx, w.pid, c.ID, p.wireProtocols()...)
+	if err != nil {
+		p.logger.Debugw("error opening stream on better connection; keeping stream", "peer", w.pid, "conn", c.ID, "err", err)
+		atomic.StoreInt32(&w.upgrading, 0)
+		return
+	}
+
+	select {
+	case w.upgrade <- s:
+	case <-w.ctx.Done():
+		s.Reset()
+	}
+}
+
+// streamUpgraded records the new stream of a writer, once the writer has switched to it.
+func (p *PubSub) streamUpgraded(w *peerWriter, s TransportStream) {
+	select {
+	case p.eval <- func() {
+		if p.writers[w.pid] == w {
+			w.stream = s
+		}
+		atomic.StoreInt32(&w.upgrading, 0)
+	}:
+	case <-w.ctx.Done():
+	}
+}
@@ -0,0 +1,183 @@
+package pubsub
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// SubscriptionChurnParams are the thresholds of the subscription churn protection; see
+// WithSubscriptionChurnProtection.
+type SubscriptionChurnParams struct {
+	// MaxChanges is the number of changes to the subscription of a peer to a topic tolerated
+	// within Window; further changes put the peer in cool-down for the topic.
+	MaxChanges int
+	Window     time.Duration
+	// Cooldown is how long the changes of a peer in cool-down are ignored.
+	Cooldown time.Duration
+	// Penalty is the behaviour penalty applied to a peer entering cool-down; 0 applies none.
+	Penalty int
+}
+
+// DefaultSubscriptionChurnParams returns the default subscription churn thresholds: a peer
+// changing its subscription to a topic more than 10 times in a minute is ignored for 5 minutes.
+func DefaultSubscriptionChurnParams() SubscriptionChurnParams {
+	return SubscriptionChurnParams{
+		MaxChanges: 10,
+		Window:     time.Minute,
+		Cooldown:   5 * time.Minute,
+		Penalty:    1,
+	}
+}
+
+func (p *SubscriptionChurnParams) validate() error {
+	if p.MaxChanges < 1 {
+		return fmt.Errorf("invalid churn MaxChanges; must be at least 1")
+	}
+	if p.Window <= 0 {
+		return fmt.Errorf("invalid churn Window; must be positive")
+	}
+	if p.Cooldown <= 0 {
+		return fmt.Errorf("invalid churn Cooldown; must be positive")
+	}
+	if p.Penalty < 0 {
+		return fmt.Errorf("invalid churn Penalty; must not be negative")
+	}
+	return nil
+}
+
+// WithSubscriptionChurnProtection protects us from peers that flap their subscriptions, which
+// would otherwise have us repeatedly add and remove them from the topic, graft and prune them,
+// and tag and untag them in the connection manager. A peer that changes its subscription to a
+// topic more than MaxChanges times within the Window is put in cool-down for the topic: its
+// subscription changes and GRAFTs for the topic are ignored for the Cooldown, after which its last
+// announced subscription is applied. Entering cool-down is traced with a
+// SUBSCRIPTION_CHURN event, and penalized with the churn Penalty, if any; the penalty is counted
+// in the P7 component of the gossipsub peer score.
+func WithSubscriptionChurnProtection(params SubscriptionChurnParams) Option {
+	return func(ps *PubSub) error {
+		if err := params.validate(); err != nil {
+			return err
+		}
+
+		ps.subChurn = &subChurn{
+			params: params,
+			peers:  make(map[peer.ID]map[string]*churnState),
+		}
+		return nil
+	}
+}
+
+// subChurn tracks the subscription changes of peers; it is only accessed from processLoop.
+type subChurn struct {
+	params SubscriptionChurnParams
+	peers  map[peer.ID]map[string]*churnState
+}
+
+// churnState is the subscription churn of a peer in a topic.
+type churnState struct {
+	// the recent changes to the subscription
+	changes []time.Time
+	// the end of the cool-down, if in cool-down
+	cooldown time.Time
+	// the subscription last announced during the cool-down, applied when it ends
+	pending *bool
+}
+
+// checkSubChurn records a subscription announced by a peer, and returns whether it must be
+// applied.
+// Only called from processLoop.
+func (p *PubSub) checkSubChurn(pid peer.ID, topic string, subscribe bool) bool {
+	sc := p.subChurn
+	if sc == nil {
+		return true
+	}
+
+	now := time.Now()
+	st, ok := sc.peers[pid][topic]
+	if ok && now.Before(st.cooldown) {
+		st.pending = &subscribe
+		return false
+	}
+
+	if _, subscribed := p.topics[topic][pid]; subscribed == subscribe {
+		return true
+	}
+
+	if !ok {
+		topics, ok := sc.peers[pid]
+		if !ok {
+			topics = make(map[string]*churnState)
+			sc.peers[pid] = topics
+		}
+		st = &churnState{}
+		topics[topic] = st
+	}
+
+	n := 0
+	for n < len(st.changes) && now.Sub(st.changes[n]) > sc.params.Window {
+		n++
+	}
+	st.changes = append(st.changes[n:], now)
+	if len(st.changes) <= sc.params.MaxChanges {
+		return true
+	}
+
+	p.logger.Debugw("peer subscription churn; ignoring its subscription changes", "peer", pid, "topic", topic, "cooldown", sc.params.Cooldown)
+	st.changes = nil
+	st.cooldown = now.Add(sc.params.Cooldown)
+	st.pending = &subscribe
+	p.tracer.SubscriptionChurn(pid, topic, sc.params.Cooldown)
+	if sc.params.Penalty > 0 {
+		if gs, ok := p.rt.(*GossipSubRouter); ok {
+			gs.score.AddPenalty(pid, sc.params.Penalty, PenaltySubscriptionChurn)
+		}
+	}
+
+	time.AfterFunc(sc.params.Cooldown, func() {
+		select {
+		case p.eval <- func() { p.endSubChurnCooldown(pid, topic) }:
+		case <-p.ctx.Done():
+		}
+	})
+	return false
+}
+
+// endSubChurnCooldown applies the subscription last announced by a peer during its cool-down.
+// Only called from processLoop.
+func (p *PubSub) endSubChurnCooldown(pid peer.ID, topic string) {
+	st, ok := p.subChurn.peers[pid][topic]
+	if !ok {
+		return
+	}
+	delete(p.subChurn.peers[pid], topic)
+	if len(p.subChurn.peers[pid]) == 0 {
+		delete(p.subChurn.peers, pid)
+	}
+
+	if _, ok := p.peers[pid]; !ok || st.pending == nil {
+		return
+	}
+	p.handleSubscription(pid, topic, *st.pending)
+}
+
+// subChurnCooldown returns whether a peer is in cool-down for a topic, in which case its GRAFTs
+// for the topic are ignored.
+// Only called from processLoop.
+func (p *PubSub) subChurnCooldown(pid peer.ID, topic string) bool {
+	if p.subChurn == nil {
+		return false
+	}
+
+	st, ok := p.subChurn.peers[pid][topic]
+	return ok && time.Now().Before(st.cooldown)
+}
+
+// forgetSubChurn drops the subscription churn of a peer that is gone.
+// Only called from processLoop.
+func (p *PubSub) forgetSubChurn(pid peer.ID) {
+	if p.subChurn != nil {
+		delete(p.subChurn.peers, pid)
+	}
+}
@@ -0,0 +1,127 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+type subChurnTracer struct {
+	mx    sync.Mutex
+	churn int
+}
+
+func (t *subChurnTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_SUBSCRIPTION_CHURN {
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.churn++
+}
+
+func (t *subChurnTracer) count() int {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.churn
+}
+
+// Test that a peer flapping its subscription is put in cool-down, and its last subscription is
+// applied once the cool-down ends.
+func TestSubscriptionChurnProtection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	legit := hosts[0]
+	attacker := hosts[1]
+
+	tracer := &subChurnTracer{}
+	params := SubscriptionChurnParams{
+		MaxChanges: 4,
+		Window:     time.Minute,
+		Cooldown:   time.Second,
+	}
+	ps, err := NewGossipSub(ctx, legit,
+		WithEventTracer(tracer),
+		WithSubscriptionChurnProtection(params))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topic, err := ps.Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := topic.Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+
+	var once sync.Once
+	newMockGS(ctx, t, attacker, func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+		if len(irpc.GetSubscriptions()) == 0 {
+			return
+		}
+
+		// flap the subscription 10 times, ending subscribed, and GRAFT during the cool-down
+		once.Do(func() {
+			name := "foobar"
+			for i := 0; i < 10; i++ {
+				subscribe := i%2 == 0
+				writeMsg(&pb.RPC{
+					Subscriptions: []*pb.RPC_SubOpts{{Subscribe: &subscribe, Topicid: &name}},
+				})
+				if i == 6 {
+					graft := []*pb.ControlGraft{{TopicID: &name}}
+					writeMsg(&rpcWithControl(nil, nil, nil, graft, nil).RPC)
+				}
+			}
+			subscribe := true
+			writeMsg(&pb.RPC{
+				Subscriptions: []*pb.RPC_SubOpts{{Subscribe: &subscribe, Topicid: &name}},
+			})
+		})
+	})
+
+	connect(t, legit, attacker)
+	time.Sleep(500 * time.Millisecond)
+
+	// the first 4 changes are applied, leaving the peer unsubscribed
+	if peers := topic.ListPeers(); len(peers) != 0 {
+		t.Fatalf("expected the flapping peer to be unsubscribed during the cool-down, got %v", peers)
+	}
+	if n := tracer.count(); n != 1 {
+		t.Fatalf("expected 1 traced subscription churn, got %d", n)
+	}
+	for _, info := range topic.ListPeersDetailed() {
+		if info.Mesh {
+			t.Fatal("expected the GRAFT during the cool-down to be ignored")
+		}
+	}
+
+	// the last subscription is applied once the cool-down ends
+	time.Sleep(time.Second)
+	peers := topic.ListPeers()
+	if len(peers) != 1 || peers[0] != attacker.ID() {
+		t.Fatalf("expected the flapping peer to be subscribed after the cool-down, got %v", peers)
+	}
+}
+
+func TestSubscriptionChurnParams(t *testing.T) {
+	var params SubscriptionChurnParams
+	if err := params.validate(); err == nil {
+		t.Fatal("expected the zero params to be invalid")
+	}
+	params = DefaultSubscriptionChurnParams()
+	if err := params.validate(); err != nil {
+		t.Fatal(err)
+	}
+	params.Penalty = -1
+	if err := params.validate(); err == nil {
+		t.Fatal("expected a negative penalty to be invalid")
+	}
+}
@@ -14,6 +14,23 @@ type Subscription struct {
 	ctx      context.Context
 	err      error
 	once     sync.Once
+
+	// whether to skip messages published by the local peer
+	noSelf bool
+
+	// the buffer of the messages until activation, if this is a standby subscription; only
+	// accessed from processLoop
+	standby *standbyBuffer
+	// the marker delivered in place of the messages dropped by the standby buffer, if any, and the
+	// error Next returns for it
+	gap    *Message
+	gapErr error
+
+	// accounts the messages in the buffer until they are read or the subscription is closed
+	budget   *memoryBudget
+	budgetMx sync.Mutex
+	buffered int64
+	closed   bool
 }
 
 // Topic returns the topic string associated with the Subscription
@@ -28,7 +45,11 @@ func (sub *Subscription) Next(ctx context.Context) (*Message, error) {
 		if !ok {
 			return msg, sub.err
 		}
+		if msg == sub.gap {
+			return nil, sub.gapErr
+		}
 
+		sub.release(msg)
 		return msg, nil
 	case <-ctx.Done():
 		return nil, ctx.Err()
@@ -47,5 +68,46 @@ func (sub *Subscription) Cancel() {
 func (sub *Subscription) close() {
 	sub.once.Do(func() {
 		close(sub.ch)
+
+		// the messages left in the buffer are no longer accounted
+		if sub.budget != nil {
+			sub.budgetMx.Lock()
+			sub.budget.add(sub.topic, -sub.buffered)
+			sub.buffered = 0
+			sub.closed = true
+			sub.budgetMx.Unlock()
+		}
 	})
 }
+
+// acquire accounts a message put in the buffer against the memory budget.
+func (sub *Subscription) acquire(msg *Message) {
+	if sub.budget == nil {
+		return
+	}
+
+	sub.budgetMx.Lock()
+	defer sub.budgetMx.Unlock()
+	if sub.closed {
+		return
+	}
+	size := int64(msg.Size())
+	sub.buffered += size
+	sub.budget.add(sub.topic, size)
+}
+
+// release accounts a message taken from the buffer.
+func (sub *Subscription) release(msg *Message) {
+	if sub.budget == nil {
+		return
+	}
+
+	sub.budgetMx.Lock()
+	defer sub.budgetMx.Unlock()
+	if sub.closed {
+		return
+	}
+	size := int64(msg.Size())
+	sub.buffered -= size
+	sub.budget.add(sub.topic, -size)
+}
@@ -2,7 +2,9 @@ package pubsub
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
+	"sync"
 
 	pb "github.com/libp2p/go-libp2p-pubsub/pb"
 
@@ -28,9 +30,20 @@ type SubscriptionFilter interface {
 	// FilterIncomingSubscriptions is invoked for all RPCs containing subscription notifications.
 	// It should filter only the subscriptions of interest and my return an error if (for instance)
 	// there are too many subscriptions.
+	// The peer that sent the RPC is passed along, so that filters can accept some topics only
+	// from specific peers; if an error is returned, the whole RPC is dropped.
 	FilterIncomingSubscriptions(peer.ID, []*pb.RPC_SubOpts) ([]*pb.RPC_SubOpts, error)
 }
 
+// peerTrackingSubscriptionFilter is implemented by subscription filters that keep per peer state;
+// the filter is notified when a peer goes away so that its state can be released.
+type peerTrackingSubscriptionFilter interface {
+	SubscriptionFilter
+
+	// RemovePeer is invoked when a peer is removed, after its subscriptions have been forgotten.
+	RemovePeer(peer.ID)
+}
+
 // WithSubscriptionFilter is a pubsub option that specifies a filter for subscriptions
 // in topics of interest.
 func WithSubscriptionFilter(subFilter SubscriptionFilter) Option {
@@ -40,6 +53,20 @@ func WithSubscriptionFilter(subFilter SubscriptionFilter) Option {
 	}
 }
 
+// WithSubscriptionFilterPenalty is a pubsub option that applies a behavioural penalty to peers
+// whose subscription notifications are rejected with an error by the subscription filter.
+// The penalty is counted in the P7 component of the gossipsub peer score, so it only has an effect
+// when using gossipsub with peer scoring enabled.
+func WithSubscriptionFilterPenalty(count int) Option {
+	return func(ps *PubSub) error {
+		if count < 0 {
+			return fmt.Errorf("invalid subscription filter penalty; must be >= 0")
+		}
+		ps.subFilterPenalty = count
+		return nil
+	}
+}
+
 // NewAllowlistSubscriptionFilter creates a subscription filter that only allows explicitly
 // specified topics for local subscriptions and incoming peer subscriptions.
 func NewAllowlistSubscriptionFilter(topics ...string) SubscriptionFilter {
@@ -147,3 +174,69 @@ func (f *limitSubscriptionFilter) FilterIncomingSubscriptions(from peer.ID, subs
 
 	return f.filter.FilterIncomingSubscriptions(from, subs)
 }
+
+// WrapLimitSubscriptionFilterPerPeer wraps a subscription filter with a hard limit in the number of
+// topics each peer may be subscribed to. RPCs with subscriptions that would take a peer above the
+// limit are rejected with ErrTooManySubscriptions.
+func WrapLimitSubscriptionFilterPerPeer(filter SubscriptionFilter, limit int) SubscriptionFilter {
+	return &perPeerLimitSubscriptionFilter{
+		filter: filter,
+		limit:  limit,
+		peers:  make(map[peer.ID]map[string]struct{}),
+	}
+}
+
+type perPeerLimitSubscriptionFilter struct {
+	filter SubscriptionFilter
+	limit  int
+
+	mx    sync.Mutex
+	peers map[peer.ID]map[string]struct{}
+}
+
+var _ peerTrackingSubscriptionFilter = (*perPeerLimitSubscriptionFilter)(nil)
+
+func (f *perPeerLimitSubscriptionFilter) CanSubscribe(topic string) bool {
+	return f.filter.CanSubscribe(topic)
+}
+
+func (f *perPeerLimitSubscriptionFilter) FilterIncomingSubscriptions(from peer.ID, subs []*pb.RPC_SubOpts) ([]*pb.RPC_SubOpts, error) {
+	subs, err := f.filter.FilterIncomingSubscriptions(from, subs)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	topics := make(map[string]struct{}, len(f.peers[from])+len(subs))
+	for topic := range f.peers[from] {
+		topics[topic] = struct{}{}
+	}
+	for _, sub := range subs {
+		if sub.GetSubscribe() {
+			topics[sub.GetTopicid()] = struct{}{}
+		} else {
+			delete(topics, sub.GetTopicid())
+		}
+	}
+
+	if len(topics) > f.limit {
+		return nil, ErrTooManySubscriptions
+	}
+
+	if len(topics) == 0 {
+		delete(f.peers, from)
+	} else {
+		f.peers[from] = topics
+	}
+
+	return subs, nil
+}
+
+func (f *perPeerLimitSubscriptionFilter) RemovePeer(p peer.ID) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	delete(f.peers, p)
+}
@@ -208,3 +208,127 @@ func TestSubscriptionFilterRPC(t *testing.T) {
 		t.Fatal("expected no subscription for test1")
 	}
 }
+
+func TestSubscriptionFilterLimitPerPeer(t *testing.T) {
+	peerA := peer.ID("A")
+	peerB := peer.ID("B")
+
+	topic1 := "test1"
+	topic2 := "test2"
+	topic3 := "test3"
+	yes := true
+	no := false
+	subs := []*pb.RPC_SubOpts{
+		&pb.RPC_SubOpts{
+			Topicid:   &topic1,
+			Subscribe: &yes,
+		},
+		&pb.RPC_SubOpts{
+			Topicid:   &topic2,
+			Subscribe: &yes,
+		},
+	}
+	more := []*pb.RPC_SubOpts{
+		&pb.RPC_SubOpts{
+			Topicid:   &topic3,
+			Subscribe: &yes,
+		},
+	}
+	unsub := []*pb.RPC_SubOpts{
+		&pb.RPC_SubOpts{
+			Topicid:   &topic1,
+			Subscribe: &no,
+		},
+	}
+
+	filter := WrapLimitSubscriptionFilterPerPeer(NewAllowlistSubscriptionFilter(topic1, topic2, topic3), 2)
+
+	_, err := filter.FilterIncomingSubscriptions(peerA, subs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = filter.FilterIncomingSubscriptions(peerB, more)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// peer A is at the limit, so a further subscription should be rejected
+	_, err = filter.FilterIncomingSubscriptions(peerA, more)
+	if err != ErrTooManySubscriptions {
+		t.Fatal("expected rejection because of too many subscriptions")
+	}
+
+	// once it unsubscribes, the subscription is accepted
+	_, err = filter.FilterIncomingSubscriptions(peerA, unsub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = filter.FilterIncomingSubscriptions(peerA, more)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// and the peer state is forgotten when the peer is removed
+	filter.(peerTrackingSubscriptionFilter).RemovePeer(peerA)
+	_, err = filter.FilterIncomingSubscriptions(peerA, subs)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type peerAllowlistSubscriptionFilter struct {
+	topic string
+	allow peer.ID
+}
+
+func (f *peerAllowlistSubscriptionFilter) CanSubscribe(topic string) bool {
+	return true
+}
+
+func (f *peerAllowlistSubscriptionFilter) FilterIncomingSubscriptions(from peer.ID, subs []*pb.RPC_SubOpts) ([]*pb.RPC_SubOpts, error) {
+	return FilterSubscriptions(subs, func(topic string) bool {
+		return topic != f.topic || from == f.allow
+	}), nil
+}
+
+func TestSubscriptionFilterPeerAware(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 3)
+	ps1 := getPubsub(ctx, hosts[0], WithSubscriptionFilter(&peerAllowlistSubscriptionFilter{topic: "test2", allow: hosts[1].ID()}))
+	ps2 := getPubsub(ctx, hosts[1])
+	ps3 := getPubsub(ctx, hosts[2])
+
+	_ = mustSubscribe(t, ps2, "test1")
+	_ = mustSubscribe(t, ps2, "test2")
+	_ = mustSubscribe(t, ps3, "test1")
+	_ = mustSubscribe(t, ps3, "test2")
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[0], hosts[2])
+
+	time.Sleep(time.Second)
+
+	var sub21, sub22, sub31, sub32 bool
+	ready := make(chan struct{})
+
+	ps1.eval <- func() {
+		_, sub21 = ps1.topics["test1"][hosts[1].ID()]
+		_, sub22 = ps1.topics["test2"][hosts[1].ID()]
+		_, sub31 = ps1.topics["test1"][hosts[2].ID()]
+		_, sub32 = ps1.topics["test2"][hosts[2].ID()]
+		ready <- struct{}{}
+	}
+	<-ready
+
+	if !sub21 || !sub31 {
+		t.Fatal("expected subscriptions for test1 from both peers")
+	}
+	if !sub22 {
+		t.Fatal("expected subscription for test2 from the allowed peer")
+	}
+	if sub32 {
+		t.Fatal("expected no subscription for test2 from the filtered peer")
+	}
+}
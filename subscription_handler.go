@@ -0,0 +1,223 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+const (
+	defaultHandlerQueueSize = 32
+	defaultHandlerWorkers   = 4
+)
+
+// HandlerCancelPolicy determines what happens to the messages that are queued for a subscription
+// handler when it is cancelled.
+type HandlerCancelPolicy int
+
+const (
+	// HandlerCancelDrop drops the queued messages; only handler invocations already in progress
+	// are completed. This is the default.
+	HandlerCancelDrop HandlerCancelPolicy = iota
+	// HandlerCancelDrain delivers the queued messages, including those still buffered in the
+	// subscription, to the handler before stopping.
+	HandlerCancelDrain
+)
+
+type handlerOptions struct {
+	queueSize  int
+	workers    int
+	concurrent bool
+	policy     HandlerCancelPolicy
+	subOpts    []SubOpt
+}
+
+// HandlerOpt is an option for Topic.SubscribeHandler.
+type HandlerOpt func(opts *handlerOptions) error
+
+// WithHandlerQueueSize sets the number of messages that can be queued for the handler; once the
+// queue is full, messages accumulate in the subscription buffer and are dropped when it overflows,
+// just like with a Subscription that is not read fast enough. The default is 32.
+func WithHandlerQueueSize(size int) HandlerOpt {
+	return func(opts *handlerOptions) error {
+		if size <= 0 {
+			return fmt.Errorf("handler queue size must be positive")
+		}
+		opts.queueSize = size
+		return nil
+	}
+}
+
+// WithConcurrentHandler invokes the handler concurrently from a pool of worker goroutines, giving
+// up the ordering of deliveries. If workers is 0, the default pool size of 4 is used.
+func WithConcurrentHandler(workers int) HandlerOpt {
+	return func(opts *handlerOptions) error {
+		if workers < 0 {
+			return fmt.Errorf("negative handler worker count")
+		}
+		opts.concurrent = true
+		if workers > 0 {
+			opts.workers = workers
+		}
+		return nil
+	}
+}
+
+// WithHandlerCancelPolicy sets what happens to queued messages when the handler is cancelled.
+func WithHandlerCancelPolicy(policy HandlerCancelPolicy) HandlerOpt {
+	return func(opts *handlerOptions) error {
+		switch policy {
+		case HandlerCancelDrop, HandlerCancelDrain:
+			opts.policy = policy
+			return nil
+		default:
+			return fmt.Errorf("unknown handler cancel policy %d", policy)
+		}
+	}
+}
+
+// WithHandlerSubOpts sets the options of the underlying subscription.
+func WithHandlerSubOpts(subOpts ...SubOpt) HandlerOpt {
+	return func(opts *handlerOptions) error {
+		opts.subOpts = append(opts.subOpts, subOpts...)
+		return nil
+	}
+}
+
+// SubscribeHandler subscribes to the topic and delivers its messages to handler, as an alternative
+// to reading the messages from a Subscription.
+//
+// By default the handler is invoked from a single goroutine, one message at a time and in the order
+// the messages were received; use WithConcurrentHandler to dispatch to a pool of goroutines instead.
+// A handler that panics is logged and keeps receiving messages.
+//
+// The handler runs until the returned cancel function is called or the context is done, at which
+// point the queued messages are dropped or drained according to the cancel policy. The cancel
+// function returns once the handler has stopped; thus it must not be called from the handler itself.
+func (t *Topic) SubscribeHandler(ctx context.Context, handler func(*Message), opts ...HandlerOpt) (cancel func(), err error) {
+	hopts := &handlerOptions{
+		queueSize: defaultHandlerQueueSize,
+	}
+	for _, opt := range opts {
+		if err := opt(hopts); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case !hopts.concurrent:
+		hopts.workers = 1
+	case hopts.workers == 0:
+		hopts.workers = defaultHandlerWorkers
+	}
+
+	sub, err := t.Subscribe(hopts.subOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	hctx, hcancel := context.WithCancel(ctx)
+	h := &subHandler{
+		sub:     sub,
+		ctx:     hctx,
+		pctx:    t.p.ctx,
+		handler: handler,
+		policy:  hopts.policy,
+		queue:   make(chan *Message, hopts.queueSize),
+		logger:  t.p.logger,
+	}
+
+	h.wg.Add(1 + hopts.workers)
+	go h.read()
+	for i := 0; i < hopts.workers; i++ {
+		go h.work()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(hcancel)
+		h.wg.Wait()
+	}, nil
+}
+
+type subHandler struct {
+	sub     *Subscription
+	ctx     context.Context
+	pctx    context.Context
+	handler func(*Message)
+	policy  HandlerCancelPolicy
+	queue   chan *Message
+	wg      sync.WaitGroup
+	logger  Logger
+}
+
+// read moves messages from the subscription to the handler queue.
+func (h *subHandler) read() {
+	defer h.wg.Done()
+	defer close(h.queue)
+
+	for {
+		select {
+		case msg, ok := <-h.sub.ch:
+			if !ok {
+				return
+			}
+			h.sub.release(msg)
+
+			select {
+			case h.queue <- msg:
+			case <-h.ctx.Done():
+				if h.policy == HandlerCancelDrain {
+					h.queue <- msg
+				}
+			}
+		case <-h.ctx.Done():
+			h.stop()
+			return
+		}
+	}
+}
+
+// stop cancels the subscription, draining its buffer into the handler queue if requested.
+func (h *subHandler) stop() {
+	h.sub.Cancel()
+
+	if h.policy != HandlerCancelDrain {
+		return
+	}
+
+	// the subscription channel is closed once the cancellation has been processed
+	for {
+		select {
+		case msg, ok := <-h.sub.ch:
+			if !ok {
+				return
+			}
+			h.sub.release(msg)
+			h.queue <- msg
+		case <-h.pctx.Done():
+			return
+		}
+	}
+}
+
+func (h *subHandler) work() {
+	defer h.wg.Done()
+
+	for msg := range h.queue {
+		if h.policy == HandlerCancelDrop && h.ctx.Err() != nil {
+			continue
+		}
+		h.invoke(msg)
+	}
+}
+
+func (h *subHandler) invoke(msg *Message) {
+	defer func() {
+		if r := recover(); r != nil {
+			h.logger.Errorw("panic in subscription handler", "topic", h.sub.topic, "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+
+	h.handler(msg)
+}
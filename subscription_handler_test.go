@@ -0,0 +1,203 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSubscribeHandlerOrdering(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := getPubsubs(ctx, hosts, WithPeerOutboundQueueSize(128), WithValidateQueueSize(128))
+	topics := getTopics(psubs, "foobar")
+
+	const count = 100
+	var mx sync.Mutex
+	var got []string
+	done := make(chan struct{})
+
+	hcancel, err := topics[1].SubscribeHandler(ctx, func(msg *Message) {
+		mx.Lock()
+		defer mx.Unlock()
+		got = append(got, string(msg.Data))
+		if len(got) == count {
+			close(done)
+		}
+	}, WithHandlerSubOpts(WithBufferSize(count)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hcancel()
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < count; i++ {
+		if err := topics[0].Publish(ctx, []byte(fmt.Sprintf("msg %d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for messages")
+	}
+
+	mx.Lock()
+	defer mx.Unlock()
+	for i, data := range got {
+		if expected := fmt.Sprintf("msg %d", i); data != expected {
+			t.Fatalf("expected message %q at position %d, but got %q", expected, i, data)
+		}
+	}
+}
+
+func TestSubscribeHandlerPanic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	psubs := getPubsubs(ctx, hosts)
+	topics := getTopics(psubs, "foobar")
+
+	var delivered int32
+	done := make(chan struct{})
+
+	hcancel, err := topics[0].SubscribeHandler(ctx, func(msg *Message) {
+		n := atomic.AddInt32(&delivered, 1)
+		if n == 10 {
+			close(done)
+		}
+		if n%2 == 1 {
+			panic("boom")
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hcancel()
+
+	for i := 0; i < 10; i++ {
+		if err := topics[0].Publish(ctx, []byte(fmt.Sprintf("msg %d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected 10 deliveries despite the panics, but got %d", atomic.LoadInt32(&delivered))
+	}
+}
+
+func TestSubscribeHandlerConcurrent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	psubs := getPubsubs(ctx, hosts)
+	topics := getTopics(psubs, "foobar")
+
+	// the handler only returns once all the workers are in it at the same time
+	const workers = 4
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	var delivered int32
+
+	hcancel, err := topics[0].SubscribeHandler(ctx, func(msg *Message) {
+		atomic.AddInt32(&delivered, 1)
+		wg.Done()
+		wg.Wait()
+	}, WithConcurrentHandler(workers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hcancel()
+
+	for i := 0; i < workers; i++ {
+		if err := topics[0].Publish(ctx, []byte(fmt.Sprintf("msg %d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected %d concurrent deliveries, but got %d", workers, atomic.LoadInt32(&delivered))
+	}
+}
+
+func TestSubscribeHandlerCancelPolicy(t *testing.T) {
+	testCancelPolicy := func(t *testing.T, policy HandlerCancelPolicy, expected int32) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		hosts := getNetHosts(t, ctx, 1)
+		psubs := getPubsubs(ctx, hosts)
+		topics := getTopics(psubs, "foobar")
+
+		// the handler blocks on the first message, so that the rest of them are queued
+		release := make(chan struct{})
+		var delivered int32
+
+		hcancel, err := topics[0].SubscribeHandler(ctx, func(msg *Message) {
+			if atomic.AddInt32(&delivered, 1) == 1 {
+				<-release
+			}
+		}, WithHandlerQueueSize(4), WithHandlerCancelPolicy(policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 10; i++ {
+			if err := topics[0].Publish(ctx, []byte(fmt.Sprintf("msg %d", i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+
+		cancelled := make(chan struct{})
+		go func() {
+			hcancel()
+			close(cancelled)
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-cancelled:
+			t.Fatal("cancel returned while the handler was still running")
+		default:
+		}
+
+		close(release)
+		select {
+		case <-cancelled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the handler to stop")
+		}
+
+		if n := atomic.LoadInt32(&delivered); n != expected {
+			t.Fatalf("expected %d deliveries, but got %d", expected, n)
+		}
+	}
+
+	t.Run("drop", func(t *testing.T) {
+		testCancelPolicy(t, HandlerCancelDrop, 1)
+	})
+	t.Run("drain", func(t *testing.T) {
+		testCancelPolicy(t, HandlerCancelDrain, 10)
+	})
+}
@@ -49,6 +49,7 @@ type tagTracer struct {
 	decayer  connmgr.Decayer
 	decaying map[string]connmgr.DecayingTag
 	direct   map[peer.ID]struct{}
+	logger   Logger
 
 	// a map of message ids to the set of peers who delivered the message after the first delivery,
 	// but before the message was finished validating
@@ -56,13 +57,11 @@ type tagTracer struct {
 }
 
 func newTagTracer(cmgr connmgr.ConnManager) *tagTracer {
-	decayer, ok := connmgr.SupportsDecay(cmgr)
-	if !ok {
-		log.Debugf("connection manager does not support decaying tags, delivery tags will not be applied")
-	}
+	decayer, _ := connmgr.SupportsDecay(cmgr)
 	return &tagTracer{
 		cmgr:      cmgr,
 		idGen:     newMsgIdGenerator(),
+		logger:    log,
 		decayer:   decayer,
 		decaying:  make(map[string]connmgr.DecayingTag),
 		nearFirst: make(map[string]map[peer.ID]struct{}),
@@ -76,6 +75,11 @@ func (t *tagTracer) Start(gs *GossipSubRouter) {
 
 	t.idGen = gs.p.idGen
 	t.direct = gs.direct
+	t.logger = gs.p.logger
+
+	if t.decayer == nil {
+		t.logger.Debugf("connection manager does not support decaying tags, delivery tags will not be applied")
+	}
 }
 
 func (t *tagTracer) tagPeerIfDirect(p peer.ID) {
@@ -100,6 +104,26 @@ func (t *tagTracer) untagMeshPeer(p peer.ID, topic string) {
 	t.cmgr.Unprotect(p, tag)
 }
 
+// protectPXPeer protects the connection to a peer obtained through px while we wait for it to
+// confirm that it speaks pubsub.
+func (t *tagTracer) protectPXPeer(p peer.ID) {
+	if t == nil {
+		return
+	}
+
+	t.cmgr.Protect(p, pxTag)
+}
+
+func (t *tagTracer) unprotectPXPeer(p peer.ID) {
+	if t == nil {
+		return
+	}
+
+	t.cmgr.Unprotect(p, pxTag)
+}
+
+const pxTag = "pubsub:<px>"
+
 func topicTag(topic string) string {
 	return fmt.Sprintf("pubsub:%s", topic)
 }
@@ -119,7 +143,7 @@ func (t *tagTracer) addDeliveryTag(topic string) {
 		connmgr.BumpSumBounded(0, GossipSubConnTagMessageDeliveryCap))
 
 	if err != nil {
-		log.Warnf("unable to create decaying delivery tag: %s", err)
+		t.logger.Warnw("unable to create decaying delivery tag", "topic", topic, "err", err)
 		return
 	}
 	t.decaying[topic] = tag
@@ -134,7 +158,7 @@ func (t *tagTracer) removeDeliveryTag(topic string) {
 	}
 	err := tag.Close()
 	if err != nil {
-		log.Warnf("error closing decaying connmgr tag: %s", err)
+		t.logger.Warnw("error closing decaying connmgr tag", "topic", topic, "err", err)
 	}
 	delete(t.decaying, topic)
 }
@@ -154,7 +178,7 @@ func (t *tagTracer) bumpTagsForMessage(p peer.ID, msg *Message) {
 	topic := msg.GetTopic()
 	err := t.bumpDeliveryTag(p, topic)
 	if err != nil {
-		log.Warnf("error bumping delivery tag: %s", err)
+		t.logger.Warnw("error bumping delivery tag", "peer", p, "topic", topic, "err", err)
 	}
 }
 
@@ -246,6 +270,8 @@ func (t *tagTracer) RejectMessage(msg *Message, reason string) {
 		fallthrough
 	case RejectValidationIgnored:
 		fallthrough
+	case RejectTransformFailed:
+		fallthrough
 	case RejectValidationFailed:
 		delete(t.nearFirst, t.idGen.ID(msg))
 	}
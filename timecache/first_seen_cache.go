@@ -25,7 +25,7 @@ func newFirstSeenCache(ttl time.Duration) *FirstSeenCache {
 
 	ctx, done := context.WithCancel(context.Background())
 	tc.done = done
-	go background(ctx, &tc.lk, tc.m)
+	go background(ctx, &tc.lk, tc.m, backgroundSweepInterval)
 
 	return tc
 }
@@ -54,3 +54,15 @@ func (tc *FirstSeenCache) Add(s string) bool {
 	tc.m[s] = time.Now().Add(tc.ttl)
 	return true
 }
+
+// Len returns the number of ids in the cache, including expired ids that haven't been swept yet.
+func (tc *FirstSeenCache) Len() int {
+	tc.lk.RLock()
+	defer tc.lk.RUnlock()
+
+	return len(tc.m)
+}
+
+func (tc *FirstSeenCache) sweep(now time.Time) {
+	sweep(&tc.lk, tc.m, now)
+}
@@ -26,7 +26,7 @@ func newLastSeenCache(ttl time.Duration) *LastSeenCache {
 
 	ctx, done := context.WithCancel(context.Background())
 	tc.done = done
-	go background(ctx, &tc.lk, tc.m)
+	go background(ctx, &tc.lk, tc.m, backgroundSweepInterval)
 
 	return tc
 }
@@ -56,3 +56,15 @@ func (tc *LastSeenCache) Has(s string) bool {
 
 	return ok
 }
+
+// Len returns the number of ids in the cache, including expired ids that haven't been swept yet.
+func (tc *LastSeenCache) Len() int {
+	tc.lk.Lock()
+	defer tc.lk.Unlock()
+
+	return len(tc.m)
+}
+
+func (tc *LastSeenCache) sweep(now time.Time) {
+	sweep(&tc.lk, tc.m, now)
+}
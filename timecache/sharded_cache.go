@@ -0,0 +1,97 @@
+package timecache
+
+import (
+	"context"
+	"time"
+)
+
+// ShardedCache is a time cache split into shards with their own lock, to reduce contention
+// between the goroutines looking up ids concurrently; an id always maps to the same shard.
+type ShardedCache struct {
+	shards []shard
+
+	done func()
+}
+
+var _ TimeCache = (*ShardedCache)(nil)
+
+// shard is a time cache swept by the ShardedCache, rather than by a goroutine of its own.
+type shard interface {
+	TimeCache
+	Len() int
+	sweep(now time.Time)
+}
+
+// NewShardedTimeCache returns a time cache with the given strategy, split into shards; with a
+// single shard, it returns the cache of NewTimeCacheWithStrategy.
+func NewShardedTimeCache(strategy Strategy, ttl time.Duration, shards int) TimeCache {
+	if shards <= 1 {
+		return NewTimeCacheWithStrategy(strategy, ttl)
+	}
+
+	tc := &ShardedCache{shards: make([]shard, shards)}
+	for i := range tc.shards {
+		m := make(map[string]time.Time)
+		switch strategy {
+		case Strategy_LastSeen:
+			tc.shards[i] = &LastSeenCache{m: m, ttl: ttl, done: func() {}}
+		default:
+			tc.shards[i] = &FirstSeenCache{m: m, ttl: ttl, done: func() {}}
+		}
+	}
+
+	ctx, done := context.WithCancel(context.Background())
+	tc.done = done
+	go tc.background(ctx, backgroundSweepInterval)
+
+	return tc
+}
+
+// background sweeps the expired entries of all the shards every interval.
+func (tc *ShardedCache) background(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, shard := range tc.shards {
+				shard.sweep(now)
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (tc *ShardedCache) shard(s string) shard {
+	// FNV-1a, without allocating
+	h := uint32(2166136261)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= 16777619
+	}
+	return tc.shards[h%uint32(len(tc.shards))]
+}
+
+func (tc *ShardedCache) Add(s string) bool {
+	return tc.shard(s).Add(s)
+}
+
+func (tc *ShardedCache) Has(s string) bool {
+	return tc.shard(s).Has(s)
+}
+
+func (tc *ShardedCache) Done() {
+	tc.done()
+}
+
+// Len returns the number of ids in the cache, including expired ids that haven't been swept yet.
+func (tc *ShardedCache) Len() int {
+	n := 0
+	for _, shard := range tc.shards {
+		n += shard.Len()
+	}
+	return n
+}
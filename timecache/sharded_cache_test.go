@@ -0,0 +1,102 @@
+package timecache
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShardedCache(t *testing.T) {
+	for _, strategy := range []Strategy{Strategy_FirstSeen, Strategy_LastSeen} {
+		tc := NewShardedTimeCache(strategy, time.Minute, 8)
+		for i := 0; i < 100; i++ {
+			if !tc.Add(fmt.Sprint(i)) {
+				t.Fatalf("should have added key %d", i)
+			}
+		}
+		for i := 0; i < 100; i++ {
+			if tc.Add(fmt.Sprint(i)) {
+				t.Fatalf("should already have key %d", i)
+			}
+			if !tc.Has(fmt.Sprint(i)) {
+				t.Fatalf("should have key %d", i)
+			}
+		}
+		if tc.Has("missing") {
+			t.Fatal("should not have this key")
+		}
+		if n := tc.(*ShardedCache).Len(); n != 100 {
+			t.Fatalf("expected 100 keys, got %d", n)
+		}
+		tc.Done()
+	}
+
+	if _, ok := NewShardedTimeCache(Strategy_FirstSeen, time.Minute, 1).(*FirstSeenCache); !ok {
+		t.Fatal("expected a single shard to be a plain cache")
+	}
+}
+
+func TestShardedCacheExpire(t *testing.T) {
+	backgroundSweepInterval = time.Second
+
+	tc := NewShardedTimeCache(Strategy_FirstSeen, time.Second, 4)
+	for i := 0; i < 10; i++ {
+		tc.Add(fmt.Sprint(i))
+	}
+
+	time.Sleep(2 * time.Second)
+	for i := 0; i < 10; i++ {
+		if tc.Has(fmt.Sprint(i)) {
+			t.Fatalf("should have dropped this key: %d from the cache already", i)
+		}
+	}
+}
+
+// BenchmarkShardedCacheParallel looks up ids concurrently, as the reader goroutines of the peers
+// and the event loop of pubsub do with the seen messages cache, mostly with Has and with an Add
+// for every 8 lookups; it reports the p99 latency of the operations besides their throughput.
+func BenchmarkShardedCacheParallel(b *testing.B) {
+	for _, shards := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("Shards=%d", shards), func(b *testing.B) {
+			tc := NewShardedTimeCache(Strategy_FirstSeen, time.Minute, shards)
+			defer tc.Done()
+
+			ids := make([]string, 1<<16)
+			for i := range ids {
+				ids[i] = fmt.Sprintf("QmPeer%d/%d", i%64, i)
+			}
+
+			var worker int64
+			results := make(chan []time.Duration, 1024)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				n := int(atomic.AddInt64(&worker, 1))
+				var lat []time.Duration
+				for i := n * 7919; pb.Next(); i++ {
+					id := ids[i%len(ids)]
+					start := time.Now()
+					if i%8 == 0 {
+						tc.Add(id)
+					} else {
+						tc.Has(id)
+					}
+					lat = append(lat, time.Since(start))
+				}
+				results <- lat
+			})
+			b.StopTimer()
+			close(results)
+
+			var all []time.Duration
+			for lat := range results {
+				all = append(all, lat...)
+			}
+			if len(all) > 0 {
+				sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+				b.ReportMetric(float64(all[len(all)*99/100].Nanoseconds()), "p99-ns/op")
+			}
+		})
+	}
+}
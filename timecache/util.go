@@ -8,8 +8,9 @@ import (
 
 var backgroundSweepInterval = time.Minute
 
-func background(ctx context.Context, lk sync.Locker, m map[string]time.Time) {
-	ticker := time.NewTicker(backgroundSweepInterval)
+// background sweeps the expired entries of m every interval.
+func background(ctx context.Context, lk sync.Locker, m map[string]time.Time, interval time.Duration) {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
@@ -0,0 +1,104 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// TimestampFn extracts the timestamp embedded in a message by its publisher.
+type TimestampFn func(msg *Message) (time.Time, error)
+
+// TimestampValidationOpt is an option for WithTimestampValidation.
+type TimestampValidationOpt func(tv *timestampValidator) error
+
+// WithTimestampResults sets the validation results of the messages whose timestamp is too far in
+// the past, too far in the future, or can't be extracted; they are ValidationIgnore,
+// ValidationReject and ValidationReject respectively by default.
+func WithTimestampResults(stale, future, invalid ValidationResult) TimestampValidationOpt {
+	return func(tv *timestampValidator) error {
+		for _, r := range []ValidationResult{stale, future, invalid} {
+			if r != ValidationIgnore && r != ValidationReject {
+				return fmt.Errorf("invalid timestamp validation result %d; must be ValidationIgnore or ValidationReject", r)
+			}
+		}
+
+		tv.stale = stale
+		tv.future = future
+		tv.invalid = invalid
+		return nil
+	}
+}
+
+// WithTimestampValidation is a topic option that validates the timestamp embedded in the messages
+// of the topic by their publisher, as extracted by extract, against the local clock: messages
+// older than maxPast are ignored, as they may just have been delayed, and messages more than
+// maxFuture ahead are rejected, as honest peers don't produce them; see WithTimestampResults.
+// Messages exactly at the limits are accepted.
+// The validation is a built-in inline validator of the topic, which runs before the validator
+// registered with RegisterTopicValidator and the default validators.
+func WithTimestampValidation(extract TimestampFn, maxPast, maxFuture time.Duration, opts ...TimestampValidationOpt) TopicOpt {
+	return func(t *Topic) error {
+		if extract == nil {
+			return fmt.Errorf("nil timestamp extractor")
+		}
+		if maxPast < 0 || maxFuture < 0 {
+			return fmt.Errorf("invalid clock skew; must not be negative")
+		}
+
+		tv := &timestampValidator{
+			p:         t.p,
+			extract:   extract,
+			maxPast:   maxPast,
+			maxFuture: maxFuture,
+			stale:     ValidationIgnore,
+			future:    ValidationReject,
+			invalid:   ValidationReject,
+		}
+		for _, opt := range opts {
+			if err := opt(tv); err != nil {
+				return err
+			}
+		}
+
+		t.builtinVals = append(t.builtinVals, &validatorImpl{
+			topic:          t.topic,
+			validate:       tv.validate,
+			validateInline: true,
+		})
+		t.timestampVals = append(t.timestampVals, tv)
+		return nil
+	}
+}
+
+// timestampValidator validates the timestamp of the messages of a topic.
+type timestampValidator struct {
+	p *PubSub
+
+	extract            TimestampFn
+	maxPast, maxFuture time.Duration
+
+	stale, future, invalid ValidationResult
+}
+
+func (tv *timestampValidator) validate(ctx context.Context, src peer.ID, msg *Message) ValidationResult {
+	ts, err := tv.extract(msg)
+	if err != nil {
+		tv.p.logger.Debugw("error extracting message timestamp", "peer", src, "topic", msg.GetTopic(), "err", err)
+		return tv.invalid
+	}
+
+	now := tv.p.clock.Now()
+	switch {
+	case ts.Before(now.Add(-tv.maxPast)):
+		tv.p.logger.Debugw("stale message timestamp", "peer", src, "topic", msg.GetTopic(), "timestamp", ts)
+		return tv.stale
+	case ts.After(now.Add(tv.maxFuture)):
+		tv.p.logger.Debugw("message timestamp in the future", "peer", src, "topic", msg.GetTopic(), "timestamp", ts)
+		return tv.future
+	default:
+		return ValidationAccept
+	}
+}
@@ -0,0 +1,191 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+// unixNanoTimestamp extracts a timestamp carried as unix nanoseconds in the message data.
+func unixNanoTimestamp(msg *Message) (time.Time, error) {
+	ns, err := strconv.ParseInt(string(msg.Data), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, ns), nil
+}
+
+func timestampData(t time.Time) []byte {
+	return []byte(strconv.FormatInt(t.UnixNano(), 10))
+}
+
+func TestTimestampValidationLimits(t *testing.T) {
+	clk := clock.NewMock()
+	clk.Set(time.Unix(1700000000, 0))
+	p := &PubSub{clock: clk, logger: log}
+
+	const maxPast = time.Minute
+	const maxFuture = 5 * time.Second
+
+	newValidator := func(opts ...TimestampValidationOpt) ValidatorEx {
+		topic := &Topic{topicState: &topicState{p: p, topic: "foobar"}}
+		if err := WithTimestampValidation(unixNanoTimestamp, maxPast, maxFuture, opts...)(topic); err != nil {
+			t.Fatal(err)
+		}
+		if len(topic.builtinVals) != 1 || !topic.builtinVals[0].validateInline {
+			t.Fatalf("expected an inline built-in validator, got %v", topic.builtinVals)
+		}
+		return topic.builtinVals[0].validate
+	}
+
+	now := clk.Now()
+	cases := []struct {
+		name   string
+		data   []byte
+		result ValidationResult
+	}{
+		{"now", timestampData(now), ValidationAccept},
+		{"exactly max past", timestampData(now.Add(-maxPast)), ValidationAccept},
+		{"past max past", timestampData(now.Add(-maxPast - time.Nanosecond)), ValidationIgnore},
+		{"exactly max future", timestampData(now.Add(maxFuture)), ValidationAccept},
+		{"past max future", timestampData(now.Add(maxFuture + time.Nanosecond)), ValidationReject},
+		{"no timestamp", []byte("garbage"), ValidationReject},
+	}
+
+	validate := newValidator()
+	for _, c := range cases {
+		msg := &Message{Message: &pb.Message{Data: c.data}}
+		if r := validate(context.Background(), "peer", msg); r != c.result {
+			t.Fatalf("%s: expected %d, got %d", c.name, c.result, r)
+		}
+	}
+
+	// the results are configurable
+	validate = newValidator(WithTimestampResults(ValidationReject, ValidationIgnore, ValidationIgnore))
+	for _, c := range []struct {
+		data   []byte
+		result ValidationResult
+	}{
+		{timestampData(now.Add(-maxPast - time.Nanosecond)), ValidationReject},
+		{timestampData(now.Add(maxFuture + time.Nanosecond)), ValidationIgnore},
+		{[]byte("garbage"), ValidationIgnore},
+	} {
+		msg := &Message{Message: &pb.Message{Data: c.data}}
+		if r := validate(context.Background(), "peer", msg); r != c.result {
+			t.Fatalf("expected %d, got %d", c.result, r)
+		}
+	}
+
+	// the limits follow the clock
+	clk.Add(maxPast)
+	msg := &Message{Message: &pb.Message{Data: timestampData(now)}}
+	if r := newValidator()(context.Background(), "peer", msg); r != ValidationAccept {
+		t.Fatalf("expected a message exactly at the limit to be accepted, got %d", r)
+	}
+	clk.Add(time.Nanosecond)
+	if r := newValidator()(context.Background(), "peer", msg); r != ValidationIgnore {
+		t.Fatalf("expected a stale message to be ignored, got %d", r)
+	}
+}
+
+func TestTimestampValidationOptions(t *testing.T) {
+	topic := &Topic{topicState: &topicState{topic: "foobar"}}
+	if err := WithTimestampValidation(nil, time.Minute, time.Minute)(topic); err == nil {
+		t.Fatal("expected a nil extractor to be invalid")
+	}
+	if err := WithTimestampValidation(unixNanoTimestamp, -time.Minute, time.Minute)(topic); err == nil {
+		t.Fatal("expected a negative skew to be invalid")
+	}
+	opt := WithTimestampResults(ValidationAccept, ValidationReject, ValidationReject)
+	if err := WithTimestampValidation(unixNanoTimestamp, time.Minute, time.Minute, opt)(topic); err == nil {
+		t.Fatal("expected accepting out of bounds timestamps to be invalid")
+	}
+}
+
+func TestTimestampValidation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := getGossipsubs(ctx, hosts)
+
+	// the clock of the receiver is virtual
+	clk := clock.NewMock()
+	clk.Set(time.Now())
+	psubs[1].clock = clk
+
+	// the built-in validator runs before the topic validator
+	var validated int32
+	err := psubs[1].RegisterTopicValidator("foobar", func(ctx context.Context, p peer.ID, msg *Message) bool {
+		atomic.AddInt32(&validated, 1)
+		return true
+	}, WithValidatorInline(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topicA, err := psubs[0].Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	topicB, err := psubs[1].Join("foobar", WithTimestampValidation(unixNanoTimestamp, time.Minute, time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := topicB.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	now := clk.Now()
+	for _, ts := range []time.Time{now.Add(time.Second + time.Millisecond), now.Add(-time.Minute - time.Millisecond)} {
+		if err := topicA.Publish(ctx, timestampData(ts)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	valid := timestampData(now.Add(-time.Minute))
+	if err := topicA.Publish(ctx, valid); err != nil {
+		t.Fatal(err)
+	}
+
+	expectMessage(t, ctx, sub, string(valid))
+	expectNoMessage(t, ctx, sub)
+
+	// the future message is rejected before the topic validator; the stale message is ignored,
+	// which doesn't stop the validation
+	if n := atomic.LoadInt32(&validated); n != 2 {
+		t.Fatalf("expected the topic validator to validate 2 messages, got %d", n)
+	}
+}
+
+func TestTimestampValidationErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getGossipsub(ctx, hosts[0])
+
+	extract := func(msg *Message) (time.Time, error) {
+		return time.Time{}, errors.New("no timestamp")
+	}
+	topic, err := ps.Join("foobar", WithTimestampValidation(extract, time.Minute, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// our own messages are validated too
+	if err := topic.Publish(ctx, []byte("hello")); err == nil {
+		t.Fatal("expected publishing a message without a timestamp to fail")
+	}
+}
@@ -2,6 +2,7 @@ package pubsub
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"sync"
@@ -22,16 +23,78 @@ var ErrNilSignKey = errors.New("nil sign key")
 // ErrEmptyPeerID is returned if an empty peer ID was provided
 var ErrEmptyPeerID = errors.New("empty peer ID")
 
-// Topic is the handle for a pubsub topic
+// ErrSeqnoRequired is returned by MessageIDForData if the message would carry a sequence number, but
+// none was provided with WithSeqno
+var ErrSeqnoRequired = errors.New("seqno required; see WithSeqno")
+
+// ErrNoPeersInTopic is returned when publishing with WithRetry if the message could not be sent to
+// any peer
+var ErrNoPeersInTopic = errors.New("no peers to publish to in topic")
+
+// Topic is the handle for a pubsub topic. Every Join, TryJoin or GetTopic call returns its own
+// handle, holding a reference to the topic until it is closed.
 type Topic struct {
+	*topicState
+
+	// whether the reference of the handle has been released by Close, and the error returned
+	// when using the handle afterwards; guarded by the topic lock
+	released   bool
+	releaseErr error
+
+	// the open reference of the handle, if the topic usage is audited
+	audit HandleInfo
+}
+
+// topicState is the state of a topic, shared by its handles.
+type topicState struct {
 	p     *PubSub
 	topic string
 
 	evtHandlerMux sync.RWMutex
 	evtHandlers   map[*TopicEventHandler]struct{}
 
+	// custom message ID function for the topic, installed when the handle is created
+	msgIdFn MsgIdFunction
+
+	// payload transforms for the topic, installed when the handle is created
+	outboundTransform OutboundTransform
+	inboundTransform  InboundTransform
+	// whether messages that fail the inbound transform are still forwarded
+	dropOnTransformError bool
+
+	// forwarding disabled with SetForwarding; only accessed from the event loop
+	noForwarding bool
+
+	// store-and-forward for the messages we publish, if enabled
+	storeForward *storeAndForward
+
+	// maximum age of the messages we forward, if any
+	forwardDeadline *forwardDeadline
+
+	// the expiry of the topic, if joined with JoinEphemeral
+	ephemeral *ephemeralTopic
+
+	// the rate limit of the messages we publish, if any
+	publishLimiter *publishLimiter
+
+	// the signer of the messages we publish and the verifier of the signatures in the topic, if
+	// the topic uses an identity other than the libp2p key of the host
+	signer      MessageSigner
+	sigVerifier TopicSignatureVerifier
+
+	// the built-in validators of the topic, installed when the handle is created, and the
+	// timestamp validators among them
+	builtinVals   []*validatorImpl
+	timestampVals []*timestampValidator
+
 	mux    sync.RWMutex
 	closed bool
+	// number of handles returned by Join, TryJoin and GetTopic that have not been closed yet
+	refs int
+	// the open handles, if the topic usage is audited, and the error returned when using a handle
+	// once the topic is closed
+	handles  []*Topic
+	closeErr error
 }
 
 // String returns the topic associated with t
@@ -42,7 +105,7 @@ func (t *Topic) String() string {
 // SetScoreParams sets the topic score parameters if the pubsub router supports peer
 // scoring
 func (t *Topic) SetScoreParams(p *TopicScoreParams) error {
-	err := p.validate()
+	err := p.Validate()
 	if err != nil {
 		return fmt.Errorf("invalid topic score parameters: %w", err)
 	}
@@ -50,8 +113,8 @@ func (t *Topic) SetScoreParams(p *TopicScoreParams) error {
 	t.mux.Lock()
 	defer t.mux.Unlock()
 
-	if t.closed {
-		return ErrTopicClosed
+	if err := t.checkOpen(); err != nil {
+		return err
 	}
 
 	result := make(chan error, 1)
@@ -67,6 +130,8 @@ func (t *Topic) SetScoreParams(p *TopicScoreParams) error {
 			return
 		}
 
+		// explicitly set parameters take precedence over the topic score parameters resolver
+		delete(gs.resolvedTopics, t.topic)
 		err := gs.score.SetTopicScoreParams(t.topic, p)
 		result <- err
 	}
@@ -86,8 +151,8 @@ func (t *Topic) SetScoreParams(p *TopicScoreParams) error {
 func (t *Topic) EventHandler(opts ...TopicEventHandlerOpt) (*TopicEventHandler, error) {
 	t.mux.RLock()
 	defer t.mux.RUnlock()
-	if t.closed {
-		return nil, ErrTopicClosed
+	if err := t.checkOpen(); err != nil {
+		return nil, err
 	}
 
 	h := &TopicEventHandler{
@@ -143,13 +208,14 @@ func (t *Topic) sendNotification(evt PeerEvent) {
 func (t *Topic) Subscribe(opts ...SubOpt) (*Subscription, error) {
 	t.mux.RLock()
 	defer t.mux.RUnlock()
-	if t.closed {
-		return nil, ErrTopicClosed
+	if err := t.checkOpen(); err != nil {
+		return nil, err
 	}
 
 	sub := &Subscription{
-		topic: t.topic,
-		ctx:   t.p.ctx,
+		topic:  t.topic,
+		ctx:    t.p.ctx,
+		budget: t.p.memBudget,
 	}
 
 	for _, opt := range opts {
@@ -186,8 +252,8 @@ func (t *Topic) Subscribe(opts ...SubOpt) (*Subscription, error) {
 func (t *Topic) Relay() (RelayCancelFunc, error) {
 	t.mux.RLock()
 	defer t.mux.RUnlock()
-	if t.closed {
-		return nil, ErrTopicClosed
+	if err := t.checkOpen(); err != nil {
+		return nil, err
 	}
 
 	out := make(chan RelayCancelFunc, 1)
@@ -213,9 +279,54 @@ type RouterReady func(rt PubSubRouter, topic string) (bool, error)
 type ProvideKey func() (crypto.PrivKey, peer.ID)
 
 type PublishOptions struct {
-	ready     RouterReady
-	customKey ProvideKey
-	local     bool
+	ready           RouterReady
+	customKey       ProvideKey
+	local           bool
+	noLocalDelivery bool
+	retryAttempts   int
+	retryBackoff    time.Duration
+	result          *PublishResult
+	metadata        map[string]interface{}
+	seqno           []byte
+}
+
+// PublishResult reports how a message we published was routed, when requested with
+// WithPublishResult.
+type PublishResult struct {
+	// Recipients is the number of peers selected to receive the message.
+	Recipients int
+	// QueueFull is the number of recipients the message was dropped for, because their outbound
+	// queue was full.
+	QueueFull int
+	// BelowPublishThreshold is the number of peers in the topic that were not selected because
+	// their score is below the publish threshold (gossipsub).
+	BelowPublishThreshold int
+	// Backoff is the number of peers in the topic that were not selected because we are backing
+	// off grafting them (gossipsub).
+	Backoff int
+	// Peers are the recipients, if requested.
+	Peers []peer.ID
+
+	listPeers bool
+}
+
+func (res *PublishResult) addRecipient(p peer.ID, queued bool) {
+	if res == nil {
+		return
+	}
+
+	res.Recipients++
+	if !queued {
+		res.QueueFull++
+	}
+	if res.listPeers {
+		res.Peers = append(res.Peers, p)
+	}
+}
+
+// reset clears the result for another routing attempt.
+func (res *PublishResult) reset() {
+	*res = PublishResult{listPeers: res.listPeers}
 }
 
 type PubOpt func(pub *PublishOptions) error
@@ -224,12 +335,10 @@ type PubOpt func(pub *PublishOptions) error
 func (t *Topic) Publish(ctx context.Context, data []byte, opts ...PubOpt) error {
 	t.mux.RLock()
 	defer t.mux.RUnlock()
-	if t.closed {
-		return ErrTopicClosed
+	if err := t.checkOpen(); err != nil {
+		return err
 	}
-
-	pid := t.p.signID
-	key := t.p.signKey
+	t.ephemeral.touch()
 
 	pub := &PublishOptions{}
 	for _, opt := range opts {
@@ -239,34 +348,27 @@ func (t *Topic) Publish(ctx context.Context, data []byte, opts ...PubOpt) error
 		}
 	}
 
-	if pub.customKey != nil && !pub.local {
-		key, pid = pub.customKey()
-		if key == nil {
-			return ErrNilSignKey
-		}
-		if len(pid) == 0 {
-			return ErrEmptyPeerID
-		}
+	if pub.local && pub.noLocalDelivery {
+		return fmt.Errorf("local publication without local delivery would not deliver the message anywhere")
 	}
 
-	m := &pb.Message{
-		Data:  data,
-		Topic: &t.topic,
-		From:  nil,
-		Seqno: nil,
-	}
-	if pid != "" {
-		m.From = []byte(pid)
-		m.Seqno = t.p.nextSeqno()
-	}
-	if key != nil {
-		m.From = []byte(pid)
-		err := signMessage(pid, key, m)
-		if err != nil {
+	if t.publishLimiter != nil && !pub.local {
+		ok, err := t.publishLimiter.wait(ctx)
+		if !ok {
 			return err
 		}
 	}
 
+	m, err := t.newMessage(data, pub, func() ([]byte, error) {
+		if pub.seqno != nil {
+			return pub.seqno, nil
+		}
+		return t.p.nextSeqno(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
 	if pub.ready != nil {
 		if t.p.disc.discovery != nil {
 			t.p.disc.Bootstrap(ctx, t.topic, pub.ready)
@@ -308,7 +410,219 @@ func (t *Topic) Publish(ctx context.Context, data []byte, opts ...PubOpt) error
 		}
 	}
 
-	return t.p.val.PushLocal(&Message{m, "", t.p.host.ID(), nil, pub.local})
+	msg := &Message{Message: m, ReceivedFrom: t.p.tr.ID(), Local: pub.local, noLocalDelivery: pub.noLocalDelivery, metadata: pub.metadata, arrived: time.Now()}
+	if (pub.retryAttempts == 0 && pub.result == nil) || pub.local {
+		return t.p.val.PushLocal(msg)
+	}
+
+	msg.routed = make(chan *PublishResult, 1)
+	msg.result = pub.result
+	if msg.result == nil {
+		msg.result = &PublishResult{}
+	}
+	msg.result.reset()
+	if err := t.p.val.PushLocal(msg); err != nil {
+		return err
+	}
+
+	if pub.retryAttempts == 0 {
+		select {
+		case <-msg.routed:
+			return nil
+		case <-t.p.ctx.Done():
+			return t.p.ctx.Err()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return t.retryRouting(ctx, msg, pub)
+}
+
+// MessageIDForData computes the ID of the message Publish would send for data with opts, with the
+// message ID function of the topic; for instance, to let a system outside pubsub expect the
+// message before it is published. Unless the message has no author, it carries a sequence number
+// that must be provided with WithSeqno, and the same option passed to Publish; otherwise
+// MessageIDForData returns ErrSeqnoRequired. The outbound transform of the topic, if any, is
+// applied to data, so it must be deterministic for the ID to match.
+func (t *Topic) MessageIDForData(data []byte, opts ...PubOpt) (string, error) {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if err := t.checkOpen(); err != nil {
+		return "", err
+	}
+
+	pub := &PublishOptions{}
+	for _, opt := range opts {
+		err := opt(pub)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	m, err := t.newMessage(data, pub, func() ([]byte, error) {
+		if pub.seqno == nil {
+			return nil, ErrSeqnoRequired
+		}
+		return pub.seqno, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return t.p.MessageID(m), nil
+}
+
+// newMessage constructs the message we publish for data, signed according to the signature policy
+// and the signer of the topic, taking its sequence number from seqno if it has an author.
+func (t *Topic) newMessage(data []byte, pub *PublishOptions, seqno func() ([]byte, error)) (*pb.Message, error) {
+	pid := t.p.signID
+	key := t.p.signKey
+
+	if pub.customKey != nil && !pub.local {
+		key, pid = pub.customKey()
+		if key == nil {
+			return nil, ErrNilSignKey
+		}
+		if len(pid) == 0 {
+			return nil, ErrEmptyPeerID
+		}
+	}
+
+	useSigner := t.signer != nil && (pub.customKey == nil || pub.local)
+	if pid == "" && !useSigner && pub.seqno != nil {
+		return nil, fmt.Errorf("messages without an author carry no seqno")
+	}
+
+	if t.outboundTransform != nil {
+		var err error
+		data, err = t.outboundTransform(data)
+		if err != nil {
+			return nil, fmt.Errorf("outbound transform failed: %w", err)
+		}
+	}
+
+	m := &pb.Message{
+		Data:  data,
+		Topic: &t.topic,
+		From:  nil,
+		Seqno: nil,
+	}
+	if pid != "" || useSigner {
+		var err error
+		m.Seqno, err = seqno()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if useSigner {
+		m.From = t.signer.Author()
+		err := signMessageWith(t.signer, m)
+		if err != nil {
+			return nil, err
+		}
+	} else if pid != "" {
+		m.From = []byte(pid)
+		if key != nil {
+			err := signMessage(pid, key, m)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// retryRouting waits for the routing of a message published with WithRetry, and routes it again
+// while it could not be sent to any peer.
+func (t *Topic) retryRouting(ctx context.Context, msg *Message, pub *PublishOptions) error {
+	for attempt := 1; ; attempt++ {
+		var res *PublishResult
+		select {
+		case res = <-msg.routed:
+		case <-t.p.ctx.Done():
+			return t.p.ctx.Err()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if res == nil || res.Recipients != 0 {
+			return nil
+		}
+		if attempt == pub.retryAttempts {
+			return fmt.Errorf("%w after %d attempts", ErrNoPeersInTopic, attempt)
+		}
+
+		timer := time.NewTimer(pub.retryBackoff)
+		select {
+		case <-timer.C:
+		case <-t.p.ctx.Done():
+			timer.Stop()
+			return t.p.ctx.Err()
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+
+		select {
+		case t.p.eval <- func() {
+			msg.result.reset()
+			t.p.rt.(retryRouter).route(msg, msg.result)
+			msg.reportRouted(msg.result)
+		}:
+		case <-t.p.ctx.Done():
+			return t.p.ctx.Err()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// retryRouter is implemented by the routers that support WithRetry and WithPublishResult.
+type retryRouter interface {
+	// publish is Publish, recording how the message was routed in res.
+	publish(msg *Message, res *PublishResult)
+	// route sends a message we published to the peers again, without the other effects of
+	// publishing it, recording how the message was routed in res.
+	route(msg *Message, res *PublishResult)
+}
+
+// WithRetry returns a publishing option for retrying to send the message to the network while
+// there are no peers to send it to, for instance before discovery has found any. The message is
+// validated, marked as seen and delivered to our own subscriptions only once; then up to
+// attempts-1 more attempts at routing it are made, backoff apart, each with the peers available at
+// the time. If the message could not be sent to any peer, Publish returns ErrNoPeersInTopic.
+// The option has no effect with WithLocalPublication, or with routers other than the ones provided
+// by this package.
+func WithRetry(attempts int, backoff time.Duration) PubOpt {
+	return func(pub *PublishOptions) error {
+		if attempts < 1 {
+			return fmt.Errorf("retry attempts must be at least 1")
+		}
+		if backoff < 0 {
+			return fmt.Errorf("negative retry backoff")
+		}
+		pub.retryAttempts = attempts
+		pub.retryBackoff = backoff
+		return nil
+	}
+}
+
+// WithPublishResult returns a publishing option for finding out how the message was routed: Publish
+// waits for the message to be routed and fills res, listing the recipients if peers is true.
+// With WithRetry, res reports the last routing attempt.
+// The option has no effect with WithLocalPublication, or with routers other than the ones provided
+// by this package.
+func WithPublishResult(res *PublishResult, peers bool) PubOpt {
+	return func(pub *PublishOptions) error {
+		if res == nil {
+			return fmt.Errorf("nil publish result")
+		}
+		pub.result = res
+		res.listPeers = peers
+		return nil
+	}
 }
 
 // WithReadiness returns a publishing option for only publishing when the router is ready.
@@ -332,6 +646,43 @@ func WithLocalPublication(local bool) PubOpt {
 	}
 }
 
+// WithLocalDelivery returns a publishing option that controls whether the message is delivered to our own
+// subscriptions to the topic; by default it is.
+// This is useful for applications that already have the data they publish.
+func WithLocalDelivery(deliver bool) PubOpt {
+	return func(pub *PublishOptions) error {
+		pub.noLocalDelivery = !deliver
+		return nil
+	}
+}
+
+// WithLocalMetadata returns a publishing option that attaches a value to the message under key,
+// for our own validators and subscriptions to read with LocalMetadata; for instance, to pass them
+// facts the publisher knows and they would otherwise derive from the message. The metadata is only
+// kept in memory: it is never sent to peers, so the messages of remote peers have none.
+func WithLocalMetadata(key string, value interface{}) PubOpt {
+	return func(pub *PublishOptions) error {
+		if pub.metadata == nil {
+			pub.metadata = make(map[string]interface{})
+		}
+		pub.metadata[key] = value
+		return nil
+	}
+}
+
+// WithSeqno returns a publishing option that sets the sequence number of the message, instead of
+// taking the next one of our counter; for instance, to compute the ID of the message with
+// MessageIDForData before publishing it. The caller must ensure the sequence numbers it provides
+// are not reused by the author, or peers drop the messages as duplicates with the default message
+// ID function. Messages without an author carry no sequence number, and fail to publish with one.
+func WithSeqno(seqno uint64) PubOpt {
+	return func(pub *PublishOptions) error {
+		pub.seqno = make([]byte, 8)
+		binary.BigEndian.PutUint64(pub.seqno, seqno)
+		return nil
+	}
+}
+
 // WithSecretKeyAndPeerId returns a publishing option for providing a custom private key and its corresponding peer ID
 // This option is useful when we want to send messages from "virtual", never-connectable peers in the network
 func WithSecretKeyAndPeerId(key crypto.PrivKey, pid peer.ID) PubOpt {
@@ -344,12 +695,27 @@ func WithSecretKeyAndPeerId(key crypto.PrivKey, pid peer.ID) PubOpt {
 	}
 }
 
-// Close closes down the topic. Will return an error unless there are no active event handlers or subscriptions.
-// Does not error if the topic is already closed.
+// Close releases the reference of the handle, and closes down the topic if it was the last one. Closing
+// down the topic will return an error unless there are no active event handlers or subscriptions.
+// Does not error if the handle or the topic is already closed.
+// Once closed, the handle can no longer be used; its methods return ErrTopicClosed, or a TopicClosedError
+// naming the caller of Close if the topic usage is audited with WithTopicUsageAudit.
 func (t *Topic) Close() error {
+	var site HandleInfo
+	if t.p.topicAudit {
+		site = callSite(true)
+	}
+
 	t.mux.Lock()
 	defer t.mux.Unlock()
-	if t.closed {
+	if t.released || t.closed {
+		return nil
+	}
+
+	if t.refs > 1 {
+		// the topic is still in use by other handles
+		t.refs--
+		t.release(site)
 		return nil
 	}
 
@@ -364,17 +730,49 @@ func (t *Topic) Close() error {
 	err := <-req.resp
 
 	if err == nil {
+		t.release(site)
 		t.closed = true
+		t.closeErr = t.releaseErr
+		if t.storeForward != nil {
+			t.storeForward.stop()
+		}
+		t.ephemeral.stop()
 	}
 
 	return err
 }
 
+// release releases the reference of the handle, recording the caller of Close for the error returned
+// when using the handle afterwards.
+// Must be called with the topic lock held.
+func (t *Topic) release(site HandleInfo) {
+	t.released = true
+	t.releaseErr = ErrTopicClosed
+	if t.p.topicAudit {
+		t.releaseErr = &TopicClosedError{Topic: t.topic, ClosedBy: site.Caller, Stack: site.Stack}
+		t.releaseHandle(t)
+	}
+}
+
+// checkOpen returns the error for using the handle once it or the topic has been closed, or nil if
+// the handle is still open.
+// Must be called with the topic lock held.
+func (t *Topic) checkOpen() error {
+	switch {
+	case t.released:
+		return t.releaseErr
+	case t.closed:
+		return t.closeErr
+	default:
+		return nil
+	}
+}
+
 // ListPeers returns a list of peers we are connected to in the given topic.
 func (t *Topic) ListPeers() []peer.ID {
 	t.mux.RLock()
 	defer t.mux.RUnlock()
-	if t.closed {
+	if t.checkOpen() != nil {
 		return []peer.ID{}
 	}
 
@@ -386,8 +784,15 @@ type EventType int
 const (
 	PeerJoin EventType = iota
 	PeerLeave
+	// PeerPruned is emitted when a peer prunes us from its mesh for the topic;
+	// it is only delivered to handlers created with WithPruneEvents.
+	PeerPruned
 )
 
+// maxPruneEvents is the number of undelivered prune events a handler retains; older
+// events are dropped first.
+const maxPruneEvents = 64
+
 // TopicEventHandler is used to manage topic specific events. No Subscription is required to receive events.
 type TopicEventHandler struct {
 	topic *Topic
@@ -396,13 +801,28 @@ type TopicEventHandler struct {
 	evtLogMx sync.Mutex
 	evtLog   map[peer.ID]EventType
 	evtLogCh chan struct{}
+
+	pruneEvents bool
+	pruneLog    []PeerEvent
 }
 
 type TopicEventHandlerOpt func(t *TopicEventHandler) error
 
+// WithPruneEvents is a topic event handler option that enables PeerPruned events.
+// Unlike join and leave events, prune events are not coalesced, but only the most recent
+// undelivered ones are retained.
+func WithPruneEvents() TopicEventHandlerOpt {
+	return func(t *TopicEventHandler) error {
+		t.pruneEvents = true
+		return nil
+	}
+}
+
 type PeerEvent struct {
 	Type EventType
 	Peer peer.ID
+	// Reason is the reason given by the peer for PeerPruned events.
+	Reason PruneReason
 }
 
 // Cancel closes the topic event handler
@@ -416,11 +836,32 @@ func (t *TopicEventHandler) Cancel() {
 }
 
 func (t *TopicEventHandler) sendNotification(evt PeerEvent) {
+	if evt.Type == PeerPruned && !t.pruneEvents {
+		return
+	}
+
 	t.evtLogMx.Lock()
-	t.addToEventLog(evt)
+	if evt.Type == PeerPruned {
+		t.addToPruneLog(evt)
+	} else {
+		t.addToEventLog(evt)
+	}
 	t.evtLogMx.Unlock()
 }
 
+// addToPruneLog assumes a lock has been taken to protect the event log
+func (t *TopicEventHandler) addToPruneLog(evt PeerEvent) {
+	if len(t.pruneLog) >= maxPruneEvents {
+		t.pruneLog = t.pruneLog[1:]
+	}
+	t.pruneLog = append(t.pruneLog, evt)
+	// send signal that an event has been added to the event log
+	select {
+	case t.evtLogCh <- struct{}{}:
+	default:
+	}
+}
+
 // addToEventLog assumes a lock has been taken to protect the event log
 func (t *TopicEventHandler) addToEventLog(evt PeerEvent) {
 	e, ok := t.evtLog[evt.Peer]
@@ -443,6 +884,11 @@ func (t *TopicEventHandler) pullFromEventLog() (PeerEvent, bool) {
 		delete(t.evtLog, k)
 		return evt, true
 	}
+	if len(t.pruneLog) > 0 {
+		evt := t.pruneLog[0]
+		t.pruneLog = t.pruneLog[1:]
+		return evt, true
+	}
 	return PeerEvent{}, false
 }
 
@@ -456,7 +902,7 @@ func (t *TopicEventHandler) NextPeerEvent(ctx context.Context) (PeerEvent, error
 		evt, ok := t.pullFromEventLog()
 		if ok {
 			// make sure an event log signal is available if there are events in the event log
-			if len(t.evtLog) > 0 {
+			if len(t.evtLog) > 0 || len(t.pruneLog) > 0 {
 				select {
 				case t.evtLogCh <- struct{}{}:
 				default:
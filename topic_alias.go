@@ -0,0 +1,220 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/libp2p/go-libp2p-pubsub/timecache"
+)
+
+// AliasedTopic is a handle for a set of topics carrying the same messages, for migrating
+// applications from one topic to another: messages are published to all the topics and
+// subscriptions receive the messages of all the topics, deduplicated.
+type AliasedTopic struct {
+	p      *PubSub
+	topics []*Topic
+}
+
+// JoinAliased joins a primary topic and its aliases, sharing the handles of the topics that have
+// already been joined (see TryJoin).
+func (p *PubSub) JoinAliased(primary string, aliases ...string) (*AliasedTopic, error) {
+	names := append([]string{primary}, aliases...)
+	seen := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("duplicate topic %s in aliases", name)
+		}
+		seen[name] = struct{}{}
+	}
+
+	at := &AliasedTopic{p: p}
+	for _, name := range names {
+		t, _, err := p.TryJoin(name)
+		if err != nil {
+			at.Close()
+			return nil, err
+		}
+		at.topics = append(at.topics, t)
+	}
+
+	return at, nil
+}
+
+// String returns the primary topic.
+func (at *AliasedTopic) String() string {
+	return at.topics[0].String()
+}
+
+// Topics returns the handles of the primary topic and its aliases, in that order, for accessing
+// the peers and other per-topic state.
+func (at *AliasedTopic) Topics() []*Topic {
+	return append([]*Topic(nil), at.topics...)
+}
+
+// Publish publishes data to the primary topic and all the aliases, as one message per topic.
+// It returns the errors of the topics it failed to publish to.
+func (at *AliasedTopic) Publish(ctx context.Context, data []byte, opts ...PubOpt) error {
+	var errs []error
+	for _, t := range at.topics {
+		if err := t.Publish(ctx, data, opts...); err != nil {
+			errs = append(errs, fmt.Errorf("publishing to %s: %w", t, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close closes the handles of all the topics.
+func (at *AliasedTopic) Close() error {
+	var errs []error
+	for _, t := range at.topics {
+		if err := t.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing %s: %w", t, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// AliasedTopicStats are the delivery statistics of a topic in an AliasedSubscription.
+type AliasedTopicStats struct {
+	// Received is the number of messages received in the topic.
+	Received uint64
+	// Duplicates is the number of messages received in the topic that had already been received
+	// in another topic, and were thus dropped.
+	Duplicates uint64
+}
+
+// AliasedSubscription is a subscription to all the topics of an AliasedTopic.
+// A message received in several topics is only returned once: messages are considered the same
+// if they have the same author and data, within the seen messages TTL of the PubSub instance.
+type AliasedSubscription struct {
+	subs []*Subscription
+	ch   chan *Message
+
+	ctx    context.Context
+	cancel func()
+	wg     sync.WaitGroup
+
+	seen timecache.TimeCache
+
+	mx    sync.Mutex
+	stats map[string]*AliasedTopicStats
+}
+
+// Subscribe subscribes to the primary topic and all the aliases; the options are applied to
+// each subscription.
+func (at *AliasedTopic) Subscribe(opts ...SubOpt) (*AliasedSubscription, error) {
+	var subs []*Subscription
+	for _, t := range at.topics {
+		sub, err := t.Subscribe(opts...)
+		if err != nil {
+			for _, sub := range subs {
+				sub.Cancel()
+			}
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+
+	ctx, cancel := context.WithCancel(at.p.ctx)
+	as := &AliasedSubscription{
+		subs:   subs,
+		ch:     make(chan *Message, 32),
+		ctx:    ctx,
+		cancel: cancel,
+		seen:   timecache.NewTimeCacheWithStrategy(at.p.seenMsgStrategy, at.p.seenMsgTTL),
+		stats:  make(map[string]*AliasedTopicStats, len(subs)),
+	}
+	for _, sub := range subs {
+		as.stats[sub.Topic()] = &AliasedTopicStats{}
+	}
+
+	as.wg.Add(len(subs))
+	for _, sub := range subs {
+		go as.forward(sub)
+	}
+	go func() {
+		as.wg.Wait()
+		as.seen.Done()
+		close(as.ch)
+	}()
+
+	return as, nil
+}
+
+func (as *AliasedSubscription) forward(sub *Subscription) {
+	defer as.wg.Done()
+
+	for {
+		msg, err := sub.Next(as.ctx)
+		if err != nil {
+			return
+		}
+
+		select {
+		case as.ch <- msg:
+		case <-as.ctx.Done():
+			return
+		}
+	}
+}
+
+// Next returns the next message received in any of the topics.
+func (as *AliasedSubscription) Next(ctx context.Context) (*Message, error) {
+	for {
+		select {
+		case msg, ok := <-as.ch:
+			if !ok {
+				return nil, ErrSubscriptionCancelled
+			}
+
+			if as.isDuplicate(msg) {
+				continue
+			}
+			return msg, nil
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (as *AliasedSubscription) isDuplicate(msg *Message) bool {
+	h := sha256.Sum256(msg.GetData())
+	dup := !as.seen.Add(string(msg.GetFrom()) + string(h[:]))
+
+	as.mx.Lock()
+	defer as.mx.Unlock()
+
+	stats := as.stats[msg.GetTopic()]
+	stats.Received++
+	if dup {
+		stats.Duplicates++
+	}
+
+	return dup
+}
+
+// Stats returns the delivery statistics of each topic.
+func (as *AliasedSubscription) Stats() map[string]AliasedTopicStats {
+	as.mx.Lock()
+	defer as.mx.Unlock()
+
+	stats := make(map[string]AliasedTopicStats, len(as.stats))
+	for topic, s := range as.stats {
+		stats[topic] = *s
+	}
+	return stats
+}
+
+// Cancel cancels the subscriptions to all the topics.
+func (as *AliasedSubscription) Cancel() {
+	as.cancel()
+	for _, sub := range as.subs {
+		sub.Cancel()
+	}
+}
@@ -0,0 +1,114 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAliasedTopic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 4)
+	psubs := getGossipsubs(ctx, hosts)
+	connectAll(t, hosts)
+
+	// the publisher and a migrated consumer use both topics; the other consumers are on a single topic
+	pub, err := psubs[0].JoinAliased("v2/blocks", "v1/blocks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+
+	oldSub, err := psubs[1].Subscribe("v1/blocks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSub, err := psubs[2].Subscribe("v2/blocks")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	both, err := psubs[3].JoinAliased("v2/blocks", "v1/blocks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bothSub, err := both.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bothSub.Cancel()
+
+	time.Sleep(2 * time.Second)
+
+	const count = 5
+	for i := 0; i < count; i++ {
+		if err := pub.Publish(ctx, []byte(fmt.Sprintf("block %d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type nexter interface {
+		Next(context.Context) (*Message, error)
+	}
+	expectExactlyOnce := func(name string, sub nexter) {
+		t.Helper()
+
+		received := make(map[string]struct{})
+		for i := 0; i < count; i++ {
+			mctx, mcancel := context.WithTimeout(ctx, 5*time.Second)
+			msg, err := sub.Next(mctx)
+			mcancel()
+			if err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+			if _, ok := received[string(msg.Data)]; ok {
+				t.Fatalf("%s: received %q twice", name, msg.Data)
+			}
+			received[string(msg.Data)] = struct{}{}
+		}
+
+		mctx, mcancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		defer mcancel()
+		if msg, err := sub.Next(mctx); err == nil {
+			t.Fatalf("%s: unexpected message %q", name, msg.Data)
+		}
+	}
+
+	expectExactlyOnce("old topic consumer", oldSub)
+	expectExactlyOnce("new topic consumer", newSub)
+	expectExactlyOnce("aliased consumer", bothSub)
+
+	stats := bothSub.Stats()
+	for _, topic := range []string{"v1/blocks", "v2/blocks"} {
+		if stats[topic].Received != count {
+			t.Fatalf("expected %d messages received in %s, got %d", count, topic, stats[topic].Received)
+		}
+	}
+	if dups := stats["v1/blocks"].Duplicates + stats["v2/blocks"].Duplicates; dups != count {
+		t.Fatalf("expected %d duplicates, got %d", count, dups)
+	}
+
+	if topics := both.Topics(); len(topics) != 2 || topics[0].String() != "v2/blocks" || topics[1].String() != "v1/blocks" {
+		t.Fatalf("unexpected topics %v", topics)
+	}
+
+	bothSub.Cancel()
+	if _, err := bothSub.Next(ctx); err != ErrSubscriptionCancelled {
+		t.Fatalf("expected the subscription to be cancelled, got %v", err)
+	}
+}
+
+func TestAliasedTopicDuplicateAlias(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0])
+
+	if _, err := ps.JoinAliased("v2/blocks", "v1/blocks", "v2/blocks"); err == nil {
+		t.Fatal("expected an error for a duplicate alias")
+	}
+}
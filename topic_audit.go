@@ -0,0 +1,120 @@
+package pubsub
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// WithTopicUsageAudit records the open Topic handles, with the stack of the caller of Join,
+// TryJoin or GetTopic, for debugging topics shared between components; see TopicHandles.
+// The closing of a handle is also recorded with its stack, in the TopicClosedError returned when
+// using the closed handle. Capturing the stacks is costly, so this is meant for debugging.
+func WithTopicUsageAudit() Option {
+	return func(p *PubSub) error {
+		p.topicAudit = true
+		return nil
+	}
+}
+
+// HandleInfo describes an open Topic handle, as recorded with WithTopicUsageAudit.
+type HandleInfo struct {
+	// Caller is the function, file and line that called Join, TryJoin or GetTopic.
+	Caller string
+	// Stack is the stack of the caller of Join, TryJoin or GetTopic.
+	Stack string
+	// Opened is the time of the Join, TryJoin or GetTopic call.
+	Opened time.Time
+}
+
+// TopicClosedError is returned instead of ErrTopicClosed by the methods of a closed Topic handle if
+// the topic usage is audited with WithTopicUsageAudit, naming the caller that closed it; it matches
+// ErrTopicClosed with errors.Is.
+type TopicClosedError struct {
+	Topic string
+	// ClosedBy is the function, file and line of the Close call that closed the handle.
+	ClosedBy string
+	// Stack is the stack of the Close call.
+	Stack string
+}
+
+func (e *TopicClosedError) Error() string {
+	return fmt.Sprintf("topic %s was closed by %s, try opening a new one", e.Topic, e.ClosedBy)
+}
+
+func (e *TopicClosedError) Is(target error) bool {
+	return target == ErrTopicClosed
+}
+
+// TopicHandles returns the open handles of a topic, in the order they were opened. It returns nil
+// if the topic is not joined or its usage is not audited with WithTopicUsageAudit.
+func (p *PubSub) TopicHandles(topic string) []HandleInfo {
+	t := p.joinedTopic(topic)
+	if t == nil {
+		return nil
+	}
+
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+
+	if len(t.handles) == 0 {
+		return nil
+	}
+	handles := make([]HandleInfo, 0, len(t.handles))
+	for _, h := range t.handles {
+		handles = append(handles, h.audit)
+	}
+	return handles
+}
+
+// callSite returns the call site of the caller of the function calling it, with its stack if
+// stack is set.
+func callSite(stack bool) HandleInfo {
+	// skip runtime.Callers, callSite and its caller
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var info HandleInfo
+	var sb strings.Builder
+	for {
+		frame, more := frames.Next()
+		if info.Caller == "" {
+			info.Caller = fmt.Sprintf("%s (%s:%d)", frame.Function, frame.File, frame.Line)
+		}
+		if !stack {
+			break
+		}
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	info.Stack = sb.String()
+	return info
+}
+
+// newHandle returns a new handle for the topic, for a caller that has taken a reference to it,
+// recording it if the topic usage is audited.
+// Must be called with the topic lock held.
+func (t *Topic) newHandle(site HandleInfo) *Topic {
+	h := &Topic{topicState: t.topicState}
+	if t.p.topicAudit {
+		site.Opened = time.Now()
+		h.audit = site
+		t.handles = append(t.handles, h)
+	}
+	return h
+}
+
+// releaseHandle forgets a closed handle.
+// Must be called with the topic lock held.
+func (t *topicState) releaseHandle(h *Topic) {
+	for i, o := range t.handles {
+		if o == h {
+			t.handles = append(t.handles[:i], t.handles[i+1:]...)
+			return
+		}
+	}
+}
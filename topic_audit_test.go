@@ -0,0 +1,127 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTopicUsageAudit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	topicID := "foobar"
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0], WithTopicUsageAudit())
+
+	// the components join and close the topic concurrently, publishing in between; the topic is
+	// only closed once all the components have closed it
+	const components = 10
+	const rounds = 20
+	topic, err := ps.Join(topicID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < components; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				tp, ok, err := ps.TryJoin(topicID)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if ok || tp == topic {
+					t.Error("expected a new handle for the joined topic")
+					return
+				}
+				if err := tp.Publish(ctx, []byte("hello")); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := tp.Close(); err != nil {
+					t.Error(err)
+					return
+				}
+				// closing the handle again must not release the reference of another one
+				if err := tp.Close(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	handles := ps.TopicHandles(topicID)
+	if len(handles) != 1 {
+		t.Fatalf("expected 1 open handle, got %d", len(handles))
+	}
+	if !strings.Contains(handles[0].Caller, "TestTopicUsageAudit") || !strings.Contains(handles[0].Stack, "TestTopicUsageAudit") {
+		t.Fatalf("expected the handle to record its caller, got %+v", handles[0])
+	}
+	if handles[0].Opened.IsZero() {
+		t.Fatal("expected the handle to record its opening time")
+	}
+
+	// a component holding a handle keeps the topic open, while the closed handle can't be used
+	shared, ok := ps.GetTopic(topicID)
+	if !ok {
+		t.Fatal("expected to find the joined topic")
+	}
+	if len(ps.TopicHandles(topicID)) != 2 {
+		t.Fatal("expected 2 open handles")
+	}
+	closeTopic(t, topic)
+	if handles := ps.TopicHandles(topicID); len(handles) != 1 || !strings.Contains(handles[0].Caller, "TestTopicUsageAudit") {
+		t.Fatalf("expected the remaining handle to stay open, got %+v", handles)
+	}
+	if err := shared.Publish(ctx, []byte("still open")); err != nil {
+		t.Fatal(err)
+	}
+
+	// using the closed handle names its closer, whatever the method
+	errs := make([]error, 0, 3)
+	errs = append(errs, topic.Publish(ctx, []byte("closed")))
+	_, err = topic.Subscribe()
+	errs = append(errs, err)
+	_, err = topic.Relay()
+	errs = append(errs, err)
+	for _, err := range errs {
+		var closedErr *TopicClosedError
+		if !errors.As(err, &closedErr) || !errors.Is(err, ErrTopicClosed) {
+			t.Fatalf("expected a topic closed error, got %v", err)
+		}
+		if closedErr.Topic != topicID || !strings.Contains(closedErr.ClosedBy, "closeTopic") || !strings.Contains(closedErr.Stack, "TestTopicUsageAudit") {
+			t.Fatalf("expected the error to name the closer, got %+v", closedErr)
+		}
+	}
+
+	closeTopic(t, shared)
+	if handles := ps.TopicHandles(topicID); handles != nil {
+		t.Fatalf("expected no open handles, got %+v", handles)
+	}
+	if joined := ps.JoinedTopics(); len(joined) != 0 {
+		t.Fatalf("expected the topic to be closed, got %v", joined)
+	}
+
+	// without the audit, the handles are not recorded
+	ps2 := getPubsub(ctx, getNetHosts(t, ctx, 1)[0])
+	if _, err := ps2.Join(topicID); err != nil {
+		t.Fatal(err)
+	}
+	if handles := ps2.TopicHandles(topicID); handles != nil {
+		t.Fatalf("expected no recorded handles, got %+v", handles)
+	}
+}
+
+func closeTopic(t *testing.T, topic *Topic) {
+	if err := topic.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,176 @@
+package pubsub
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+// JoinEphemeral joins a short-lived topic, such as the reply topic of a request, like Join, but the
+// returned Topic handle is closed down automatically after ttl of inactivity: no message published
+// to the topic or delivered to its subscriptions. Touch extends the lifetime of the topic.
+// On expiry, the subscriptions and event handlers of the topic are cancelled, the topic is left as
+// if they were cancelled by the application, and the handle is closed regardless of its references;
+// the expiry is postponed while the topic has active relays. Closing the handle before it expires
+// stops the expiry.
+func (p *PubSub) JoinEphemeral(topic string, ttl time.Duration, opts ...TopicOpt) (*Topic, error) {
+	var site HandleInfo
+	if p.topicAudit {
+		site = callSite(true)
+	}
+
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid ephemeral topic ttl; must be positive")
+	}
+
+	// the expiry is installed with the handle, as deliveries touch the topic once it is joined
+	e := &ephemeralTopic{ttl: ttl, clock: p.clock, lastActive: p.clock.Now()}
+	opts = append(opts[:len(opts):len(opts)], func(t *Topic) error {
+		t.ephemeral = e
+		return nil
+	})
+
+	t, ok, err := p.tryJoin(topic, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("topic already exists")
+	}
+
+	e.mx.Lock()
+	e.timer = p.clock.AfterFunc(ttl, t.expire)
+	e.mx.Unlock()
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.newHandle(site), nil
+}
+
+// Touch records activity in an ephemeral topic, extending its lifetime by the ttl it was joined
+// with. It does nothing on topics joined with Join or TryJoin.
+func (t *Topic) Touch() {
+	t.ephemeral.touch()
+}
+
+// ephemeralTopic is the expiry of a topic joined with JoinEphemeral.
+type ephemeralTopic struct {
+	ttl   time.Duration
+	clock clock.Clock
+
+	mx         sync.Mutex
+	lastActive time.Time
+	timer      *clock.Timer
+}
+
+// touch records activity in the topic. A nil expiry records nothing.
+func (e *ephemeralTopic) touch() {
+	if e == nil {
+		return
+	}
+
+	e.mx.Lock()
+	e.lastActive = e.clock.Now()
+	e.mx.Unlock()
+}
+
+// remaining returns the time left before the topic expires, rescheduling the expiry if there is
+// any.
+func (e *ephemeralTopic) remaining() time.Duration {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	left := e.ttl - e.clock.Since(e.lastActive)
+	if left > 0 {
+		e.timer.Reset(left)
+	}
+	return left
+}
+
+// postpone reschedules the expiry by the ttl.
+func (e *ephemeralTopic) postpone() {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	e.timer.Reset(e.ttl)
+}
+
+// stop stops the expiry of a topic closed before it expires. A nil expiry has nothing to stop.
+func (e *ephemeralTopic) stop() {
+	if e == nil {
+		return
+	}
+
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+}
+
+// expire closes down an ephemeral topic that has been inactive for its ttl; it runs on the timer of
+// the expiry.
+func (t *Topic) expire() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.closed || t.ephemeral.remaining() > 0 {
+		return
+	}
+
+	closed := make(chan bool, 1)
+	select {
+	case t.p.eval <- func() {
+		closed <- t.p.closeEphemeral(t)
+	}:
+	case <-t.p.ctx.Done():
+		return
+	}
+
+	if !<-closed {
+		t.ephemeral.postpone()
+		return
+	}
+
+	t.p.logger.Debugw("ephemeral topic expired", "topic", t.topic, "ttl", t.ephemeral.ttl)
+	t.closed = true
+	t.handles = nil
+	t.closeErr = ErrTopicClosed
+	if t.p.topicAudit {
+		t.closeErr = &TopicClosedError{Topic: t.topic, ClosedBy: "ephemeral topic expiry"}
+	}
+	if t.storeForward != nil {
+		t.storeForward.stop()
+	}
+}
+
+// closeEphemeral cancels the subscriptions and event handlers of an expired ephemeral topic and
+// removes it, returning false if it is still relayed.
+// Only called from processLoop.
+func (p *PubSub) closeEphemeral(t *Topic) bool {
+	if p.myTopics[t.topic].topicState != t.topicState {
+		return true
+	}
+	if p.myRelays[t.topic] > 0 {
+		return false
+	}
+
+	for sub := range p.mySubs[t.topic] {
+		p.handleRemoveSubscription(sub)
+	}
+
+	t.evtHandlerMux.Lock()
+	for h := range t.evtHandlers {
+		h.err = fmt.Errorf("topic event handler cancelled by the expiry of the ephemeral topic")
+		delete(t.evtHandlers, h)
+	}
+	t.evtHandlerMux.Unlock()
+
+	delete(p.myTopics, t.topic)
+	p.sigVerifiers.Remove(t.topic)
+	p.val.SetBuiltinValidators(t.topic, nil)
+	return true
+}
@@ -0,0 +1,188 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+func TestEphemeralTopicExpiry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	tracer := &joinLeaveTracer{}
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0], WithEventTracer(tracer)),
+		getGossipsub(ctx, hosts[1]),
+	}
+
+	// the clock is virtual
+	clk := clock.NewMock()
+	psubs[0].clock = clk
+
+	const topic = "reply"
+	eph, err := psubs[0].JoinEphemeral(topic, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := eph.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	evts, err := eph.EventHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote, err := psubs[1].Join(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	// a delivery extends the lifetime of the topic
+	clk.Add(50 * time.Second)
+	if err := remote.Publish(ctx, []byte("reply")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.Next(ctx); err != nil {
+		t.Fatal(err)
+	}
+	clk.Add(50 * time.Second)
+	time.Sleep(100 * time.Millisecond)
+	if joined := psubs[0].JoinedTopics(); len(joined) != 1 {
+		t.Fatal("expected the ephemeral topic to be extended by the delivery")
+	}
+
+	// so does a touch
+	eph.Touch()
+	clk.Add(50 * time.Second)
+	time.Sleep(100 * time.Millisecond)
+	if joined := psubs[0].JoinedTopics(); len(joined) != 1 {
+		t.Fatal("expected the ephemeral topic to be extended by the touch")
+	}
+
+	clk.Add(10 * time.Second)
+	time.Sleep(100 * time.Millisecond)
+	if joined := psubs[0].JoinedTopics(); len(joined) != 0 {
+		t.Fatalf("expected the ephemeral topic to expire, got %v", joined)
+	}
+
+	if _, err := sub.Next(ctx); err != ErrSubscriptionCancelled {
+		t.Fatalf("expected the subscription to be cancelled, got %v", err)
+	}
+	eph.evtHandlerMux.RLock()
+	handlers := len(eph.evtHandlers)
+	eph.evtHandlerMux.RUnlock()
+	if handlers != 0 || evts.err == nil {
+		t.Fatal("expected the event handler to be cancelled")
+	}
+	if err := eph.Publish(ctx, []byte("late")); err != ErrTopicClosed {
+		t.Fatalf("expected the topic to be closed, got %v", err)
+	}
+	if err := eph.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the topic is left like a manual one, for the router and the peers
+	if events := tracer.get(); len(events) != 2 || events[1] != pb.TraceEvent_LEAVE {
+		t.Fatalf("expected the topic to be left, got %v", events)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if peers := remote.ListPeers(); len(peers) != 0 {
+		t.Fatalf("expected the remote peer to see us leave the topic, got %v", peers)
+	}
+
+	// the topic can be joined again
+	if _, err := psubs[0].JoinEphemeral(topic, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEphemeralTopicClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getGossipsub(ctx, hosts[0])
+	clk := clock.NewMock()
+	ps.clock = clk
+
+	eph, err := ps.JoinEphemeral("reply", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := eph.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the expiry is cancelled with the topic, so a new handle is not closed by it
+	eph, err = ps.Join("reply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk.Add(time.Hour)
+	time.Sleep(100 * time.Millisecond)
+	if err := eph.Publish(ctx, []byte("hello")); err != nil {
+		t.Fatalf("expected the topic to be open, got %v", err)
+	}
+
+	if _, err := ps.JoinEphemeral("other", 0); err == nil {
+		t.Fatal("expected a zero ttl to be invalid")
+	}
+}
+
+func TestEphemeralTopicNoLeak(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getGossipsub(ctx, hosts[0])
+	clk := clock.NewMock()
+	ps.clock = clk
+	time.Sleep(100 * time.Millisecond)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		eph, err := ps.JoinEphemeral(fmt.Sprintf("reply-%d", i), time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := eph.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+		// half of the topics are touched, and so expire later
+		if i%2 == 0 {
+			clk.Add(time.Second)
+			eph.Touch()
+		}
+	}
+
+	clk.Add(2 * time.Minute)
+	for i := 0; ; i++ {
+		if len(ps.JoinedTopics()) == 0 {
+			break
+		}
+		if i == 50 {
+			t.Fatalf("expected the ephemeral topics to expire, got %d", len(ps.JoinedTopics()))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if after := runtime.NumGoroutine(); after > before+5 {
+		t.Fatalf("expected no goroutine per ephemeral topic, got %d goroutines from %d", after, before)
+	}
+}
@@ -0,0 +1,99 @@
+package pubsub
+
+import (
+	"sort"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// TopicPeerInfo describes a peer in a topic, as reported by Topic.ListPeersDetailed.
+// The router state is only reported with gossipsub; with other routers, it is left zero.
+type TopicPeerInfo struct {
+	// ID is the peer ID.
+	ID peer.ID
+	// Protocol is the pubsub protocol negotiated with the peer; it is empty if we have not
+	// yet opened a stream to the peer.
+	Protocol protocol.ID
+	// Mesh is true if the peer is in our mesh for the topic.
+	Mesh bool
+	// Fanout is true if the peer is in our fanout for the topic.
+	Fanout bool
+	// Score is the current score of the peer; it is 0 if peer scoring is not enabled.
+	Score float64
+	// Direct is true if the peer is a direct peer.
+	Direct bool
+	// Outbound is true if we have an outbound connection to the peer, i.e. one we dialed.
+	Outbound bool
+	// Backoff is the time until which we back off from grafting the peer in the topic; it is
+	// the zero time if we are not backing off.
+	Backoff time.Time
+}
+
+// ListPeersDetailed is like ListPeers, but also reports the router state of each peer. The
+// peers are reported from a single snapshot of the state, so they are consistent with each
+// other.
+func (t *Topic) ListPeersDetailed() []TopicPeerInfo {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if t.checkOpen() != nil {
+		return []TopicPeerInfo{}
+	}
+
+	out := make(chan []TopicPeerInfo, 1)
+	select {
+	case t.p.eval <- func() {
+		gs, _ := t.p.rt.(*GossipSubRouter)
+
+		var peers []TopicPeerInfo
+		for pid := range t.p.topics[t.topic] {
+			info := TopicPeerInfo{ID: pid, Protocol: t.p.peerProtos[pid]}
+			if gs != nil {
+				_, info.Mesh = gs.mesh[t.topic][pid]
+				_, info.Fanout = gs.fanout[t.topic][pid]
+				_, info.Direct = gs.direct[pid]
+				info.Score = gs.score.Score(pid)
+				info.Outbound = gs.outbound[pid]
+				if expire, ok := gs.backoff[t.topic][pid]; ok && time.Now().Before(expire) {
+					info.Backoff = expire
+				}
+			}
+			peers = append(peers, info)
+		}
+		out <- peers
+	}:
+	case <-t.p.ctx.Done():
+		return nil
+	}
+	return <-out
+}
+
+// SortPeersByScore sorts peers by decreasing score.
+func SortPeersByScore(peers []TopicPeerInfo) {
+	sort.SliceStable(peers, func(i, j int) bool {
+		return peers[i].Score > peers[j].Score
+	})
+}
+
+// SortPeersByMesh sorts the peers in our mesh first, then the peers in our fanout, then the rest,
+// each by decreasing score.
+func SortPeersByMesh(peers []TopicPeerInfo) {
+	rank := func(pi TopicPeerInfo) int {
+		switch {
+		case pi.Mesh:
+			return 0
+		case pi.Fanout:
+			return 1
+		default:
+			return 2
+		}
+	}
+
+	sort.SliceStable(peers, func(i, j int) bool {
+		if ri, rj := rank(peers[i]), rank(peers[j]); ri != rj {
+			return ri < rj
+		}
+		return peers[i].Score > peers[j].Score
+	})
+}
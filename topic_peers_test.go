@@ -0,0 +1,141 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestTopicListPeersDetailed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 4)
+	appScores := map[peer.ID]float64{
+		hosts[1].ID(): 3,
+		hosts[2].ID(): 2,
+		hosts[3].ID(): 1,
+	}
+
+	var mx sync.Mutex
+	var inspected map[peer.ID]float64
+	inspect := func(scores map[peer.ID]float64) {
+		mx.Lock()
+		defer mx.Unlock()
+		inspected = scores
+	}
+
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0],
+			WithPeerScore(
+				&PeerScoreParams{
+					AppSpecificScore:  func(p peer.ID) float64 { return appScores[p] },
+					AppSpecificWeight: 1,
+					DecayInterval:     time.Second,
+					DecayToZero:       0.01,
+				},
+				&PeerScoreThresholds{
+					GossipThreshold:   -1,
+					PublishThreshold:  -10,
+					GraylistThreshold: -1000,
+				}),
+			WithPeerScoreInspect(inspect, 100*time.Millisecond),
+			WithDirectPeers([]peer.AddrInfo{{ID: hosts[3].ID(), Addrs: hosts[3].Addrs()}})),
+		getGossipsub(ctx, hosts[1]),
+		getGossipsub(ctx, hosts[2]),
+		getGossipsub(ctx, hosts[3], WithDirectPeers([]peer.AddrInfo{{ID: hosts[0].ID(), Addrs: hosts[0].Addrs()}})),
+	}
+
+	var topics []*Topic
+	for i, ps := range psubs {
+		tp, err := ps.Join("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tp.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, tp)
+
+		// the other peers are subscribed to a topic we only publish to
+		if i > 0 {
+			if _, err := ps.Subscribe("other"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, h := range hosts[1:] {
+		connect(t, hosts[0], h)
+	}
+	time.Sleep(2 * time.Second)
+
+	// we back off from a peer
+	psubs[0].eval <- func() {
+		psubs[0].rt.(*GossipSubRouter).addBackoff(hosts[2].ID(), "test", false)
+	}
+
+	infos := topics[0].ListPeersDetailed()
+	if len(infos) != 3 {
+		t.Fatalf("expected 3 peers in topic, got %d", len(infos))
+	}
+
+	mx.Lock()
+	scores := inspected
+	mx.Unlock()
+	byID := make(map[peer.ID]PeerInfo)
+	for _, pi := range psubs[0].ListPeersDetailed("test") {
+		byID[pi.ID] = pi
+	}
+	for _, pi := range infos {
+		if pi.Mesh != byID[pi.ID].Mesh || pi.Protocol != byID[pi.ID].Protocol {
+			t.Fatalf("unexpected mesh or protocol for %s: %+v, expected %+v", pi.ID, pi, byID[pi.ID])
+		}
+		if pi.Score != scores[pi.ID] {
+			t.Fatalf("unexpected score for %s: %f, expected %f", pi.ID, pi.Score, scores[pi.ID])
+		}
+		if pi.Fanout {
+			t.Fatalf("unexpected info for %s: %+v", pi.ID, pi)
+		}
+
+		direct := pi.ID == hosts[3].ID()
+		if pi.Direct != direct || pi.Mesh == direct {
+			t.Fatalf("unexpected direct or mesh for %s: %+v", pi.ID, pi)
+		}
+		if backoff := pi.ID == hosts[2].ID(); backoff == pi.Backoff.IsZero() {
+			t.Fatalf("unexpected backoff for %s: %+v", pi.ID, pi)
+		}
+	}
+
+	SortPeersByScore(infos)
+	for i, h := range hosts[1:] {
+		if infos[i].ID != h.ID() {
+			t.Fatalf("expected %s at position %d when sorted by score, got %s", h.ID(), i, infos[i].ID)
+		}
+	}
+	SortPeersByMesh(infos)
+	if infos[2].ID != hosts[3].ID() {
+		t.Fatalf("expected the direct peer last when sorted by mesh, got %s", infos[2].ID)
+	}
+
+	// the peers in the topic we publish to are in our fanout
+	other, err := psubs[0].Join("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Publish(ctx, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	for _, pi := range other.ListPeersDetailed() {
+		if pi.Mesh || pi.Fanout == pi.Direct {
+			t.Fatalf("unexpected info for %s in fanout topic: %+v", pi.ID, pi)
+		}
+	}
+
+	other.Close()
+	if infos := other.ListPeersDetailed(); len(infos) != 0 {
+		t.Fatalf("expected no peers for a closed topic, got %v", infos)
+	}
+}
@@ -0,0 +1,214 @@
+package pubsub
+
+import (
+	"fmt"
+)
+
+// WithFairTopicScheduling enables fair scheduling of the message processing across topics in the
+// event loop. Without it, received and validated messages are processed in arrival order, so a
+// burst of messages in a busy topic delays the delivery of the messages in all the other topics.
+// With it, the messages are queued per topic and the event loop processes them in round robin,
+// taking up to weights[topic] messages (default 1) from each topic in turn and interleaving them
+// with the other events, so subscription changes and gossip control messages are not delayed by
+// the message backlog.
+// Up to queueSize received messages are queued per topic; further messages in the topic are
+// dropped until the queue drains, as with a full validation queue. Validated messages are never
+// dropped. Note that the validation queue is still shared by all topics, so messages that need
+// validation may also wait behind the messages of other topics there.
+func WithFairTopicScheduling(queueSize int, weights map[string]int) Option {
+	return func(ps *PubSub) error {
+		if queueSize <= 0 {
+			return fmt.Errorf("topic queue size must be positive")
+		}
+		for topic, w := range weights {
+			if w <= 0 {
+				return fmt.Errorf("invalid weight %d for topic %s; must be positive", w, topic)
+			}
+		}
+
+		ps.sched = newTopicScheduler(queueSize, weights)
+		return nil
+	}
+}
+
+// topicScheduler holds the per topic message queues, drained in weighted round robin; it is only
+// accessed from the event loop.
+type topicScheduler struct {
+	size    int
+	weights map[string]int
+
+	queues map[string]*topicQueue
+	// queues with pending messages, in round robin order; the head is being drained
+	active []*topicQueue
+	// messages left to take from the head queue in this round
+	credit int
+
+	// signals the event loop that there are pending messages
+	ready chan struct{}
+}
+
+type topicQueue struct {
+	topic string
+	items []schedItem
+	// number of queued messages pending validation
+	received int
+}
+
+type schedItem struct {
+	msg       *Message
+	validated bool
+}
+
+func newTopicScheduler(size int, weights map[string]int) *topicScheduler {
+	w := make(map[string]int, len(weights))
+	for topic, weight := range weights {
+		w[topic] = weight
+	}
+
+	return &topicScheduler{
+		size:    size,
+		weights: w,
+		queues:  make(map[string]*topicQueue),
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+func (s *topicScheduler) weight(topic string) int {
+	if w, ok := s.weights[topic]; ok {
+		return w
+	}
+	return 1
+}
+
+// push queues a message, either received and pending validation or validated and pending
+// delivery; it returns false if a received message was dropped because the queue is full.
+func (s *topicScheduler) push(msg *Message, validated bool) bool {
+	topic := msg.GetTopic()
+	q, ok := s.queues[topic]
+	if !ok {
+		q = &topicQueue{topic: topic}
+		s.queues[topic] = q
+		s.active = append(s.active, q)
+		if len(s.active) == 1 {
+			s.credit = s.weight(topic)
+		}
+	}
+
+	if !validated {
+		if q.received >= s.size {
+			return false
+		}
+		q.received++
+	}
+	q.items = append(q.items, schedItem{msg: msg, validated: validated})
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// pop takes the next message in round robin order.
+func (s *topicScheduler) pop() (schedItem, bool) {
+	if len(s.active) == 0 {
+		return schedItem{}, false
+	}
+
+	q := s.active[0]
+	item := q.items[0]
+	q.items[0] = schedItem{}
+	q.items = q.items[1:]
+	if !item.validated {
+		q.received--
+	}
+	s.credit--
+
+	switch {
+	case len(q.items) == 0:
+		// drop the queue, so that idle topics don't hold memory
+		delete(s.queues, q.topic)
+		copy(s.active, s.active[1:])
+		s.active[len(s.active)-1] = nil
+		s.active = s.active[:len(s.active)-1]
+		if len(s.active) > 0 {
+			s.credit = s.weight(s.active[0].topic)
+		}
+
+	case s.credit == 0:
+		// next topic's turn
+		copy(s.active, s.active[1:])
+		s.active[len(s.active)-1] = q
+		s.credit = s.weight(s.active[0].topic)
+	}
+
+	if len(s.active) > 0 {
+		select {
+		case s.ready <- struct{}{}:
+		default:
+		}
+	}
+
+	return item, true
+}
+
+// readyCh returns the channel signaling pending messages; it is nil when scheduling is disabled,
+// so that the event loop never selects it.
+func (s *topicScheduler) readyCh() <-chan struct{} {
+	if s == nil {
+		return nil
+	}
+	return s.ready
+}
+
+// scheduleMsg queues a received message for processing in its topic's turn.
+func (p *PubSub) scheduleMsg(msg *Message) {
+	// spare the queue slot for copies of messages we have already seen
+	if p.seenMessage(p.idGen.ID(msg)) {
+		p.tracer.DuplicateMessage(msg)
+		return
+	}
+
+	// the message outlives the RPC in the queue
+	msg.detachData()
+	if !p.sched.push(msg, false) {
+		p.logger.Debugw("topic queue full; dropping message", "peer", msg.ReceivedFrom, "topic", msg.GetTopic())
+		p.tracer.RejectMessage(msg, RejectValidationQueueFull)
+	}
+}
+
+// handleScheduled processes the next queued message.
+func (p *PubSub) handleScheduled() {
+	// incoming RPCs and validated messages are cheap to handle with scheduling, as their messages are
+	// only queued, so take the pending ones first: this keeps control messages ahead of the message
+	// backlog, and lets the messages of other topics join the round robin without waiting in the
+	// channels behind the messages of the busy topic. The drain is bounded, so that a steady stream
+	// of RPCs can't stall the message processing.
+	for i := cap(p.incoming) + cap(p.sendMsg); i > 0; i-- {
+		select {
+		case rpc := <-p.incoming:
+			p.handleIncomingRPC(rpc)
+			continue
+		case msg := <-p.sendMsg:
+			p.sched.push(msg, true)
+			continue
+		default:
+		}
+		break
+	}
+
+	item, ok := p.sched.pop()
+	if !ok {
+		return
+	}
+
+	switch {
+	case item.validated:
+		p.publishMessage(item.msg)
+	case p.leftTopic(item.msg.Message):
+		p.logger.Debugw("left topic of scheduled message; dropping message", "peer", item.msg.ReceivedFrom, "topic", item.msg.GetTopic())
+		p.tracer.RejectMessage(item.msg, RejectTopicLeft)
+	default:
+		p.pushMsg(item.msg)
+	}
+}
@@ -0,0 +1,178 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+func TestTopicScheduler(t *testing.T) {
+	s := newTopicScheduler(3, map[string]int{"heavy": 2})
+
+	msg := func(topic string, i int) *Message {
+		return &Message{Message: &pb.Message{Topic: &topic, Data: []byte(fmt.Sprintf("%s%d", topic, i))}}
+	}
+	for i := 0; i < 4; i++ {
+		ok := s.push(msg("heavy", i), false)
+		if ok != (i < 3) {
+			t.Fatalf("unexpected push result %v for message %d", ok, i)
+		}
+	}
+	// validated messages are never dropped
+	if !s.push(msg("heavy", 3), true) {
+		t.Fatal("expected validated message to be queued")
+	}
+	for i := 0; i < 2; i++ {
+		s.push(msg("light", i), false)
+	}
+	s.push(msg("other", 0), false)
+
+	expected := []string{"heavy0", "heavy1", "light0", "other0", "heavy2", "heavy3", "light1"}
+	for _, data := range expected {
+		select {
+		case <-s.readyCh():
+		default:
+			t.Fatal("expected the scheduler to be ready")
+		}
+
+		item, ok := s.pop()
+		if !ok {
+			t.Fatal("expected a message")
+		}
+		if string(item.msg.Data) != data {
+			t.Fatalf("expected %s, got %s", data, item.msg.Data)
+		}
+		if item.validated != (data == "heavy3") {
+			t.Fatalf("unexpected validated flag for %s", data)
+		}
+	}
+
+	if _, ok := s.pop(); ok {
+		t.Fatal("expected no more messages")
+	}
+	select {
+	case <-s.readyCh():
+		t.Fatal("expected the scheduler not to be ready")
+	default:
+	}
+	if len(s.queues) != 0 {
+		t.Fatal("expected the empty queues to be dropped")
+	}
+
+	// the queue bound applies again once drained
+	for i := 0; i < 3; i++ {
+		if !s.push(msg("heavy", i), false) {
+			t.Fatalf("expected message %d to be queued", i)
+		}
+	}
+
+	var nilSched *topicScheduler
+	if nilSched.readyCh() != nil {
+		t.Fatal("expected a nil ready channel when scheduling is disabled")
+	}
+}
+
+// slowDeliveryTracer slows down the delivery of the messages in a topic, to emulate an event loop
+// saturated by a busy topic.
+type slowDeliveryTracer struct {
+	topic     string
+	delay     time.Duration
+	delivered int32
+}
+
+func (t *slowDeliveryTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_DELIVER_MESSAGE || evt.GetDeliverMessage().GetTopic() != t.topic {
+		return
+	}
+	atomic.AddInt32(&t.delivered, 1)
+	time.Sleep(t.delay)
+}
+
+func TestFairTopicSchedulingLatency(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 3)
+
+	// unsigned messages without validators are processed entirely in the event loop
+	opts := []Option{
+		WithMessageSignaturePolicy(StrictNoSign),
+		WithNoAuthor(),
+		WithMessageIdFn(func(pmsg *pb.Message) string {
+			return string(pmsg.GetData())
+		}),
+	}
+	flooder := getPubsub(ctx, hosts[0], append(opts, WithPeerOutboundQueueSize(1024))...)
+	trickler := getPubsub(ctx, hosts[1], opts...)
+
+	tracer := &slowDeliveryTracer{topic: "flood", delay: 5 * time.Millisecond}
+	receiver := getPubsub(ctx, hosts[2], append(opts,
+		WithEventTracer(tracer),
+		WithFairTopicScheduling(32, nil),
+	)...)
+
+	floodSub, err := receiver.Subscribe("flood", WithBufferSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer floodSub.Cancel()
+	trickleSub, err := receiver.Subscribe("trickle")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[2])
+	connect(t, hosts[1], hosts[2])
+	time.Sleep(100 * time.Millisecond)
+
+	go func() {
+		for i := 0; i < 1000; i++ {
+			if err := flooder.Publish("flood", []byte(fmt.Sprintf("flood %d", i))); err != nil {
+				return
+			}
+		}
+	}()
+
+	// wait for the backlog to build up
+	for atomic.LoadInt32(&tracer.delivered) < 10 {
+		time.Sleep(time.Millisecond)
+	}
+
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		if err := trickler.Publish("trickle", []byte(fmt.Sprintf("trickle %d", i))); err != nil {
+			t.Fatal(err)
+		}
+
+		mctx, mcancel := context.WithTimeout(ctx, 5*time.Second)
+		_, err := trickleSub.Next(mctx)
+		mcancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// without fair scheduling, the message waits behind the flood RPCs queued for the event
+		// loop, which take well over a hundred milliseconds to process
+		if lat := time.Since(start); lat > 50*time.Millisecond {
+			t.Fatalf("trickle message %d delivered after %s", i, lat)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestFairTopicSchedulingOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	if _, err := NewFloodSub(ctx, hosts[0], WithFairTopicScheduling(0, nil)); err == nil {
+		t.Fatal("expected an error for a zero queue size")
+	}
+	if _, err := NewFloodSub(ctx, hosts[0], WithFairTopicScheduling(10, map[string]int{"foo": 0})); err == nil {
+		t.Fatal("expected an error for a zero weight")
+	}
+}
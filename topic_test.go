@@ -5,16 +5,19 @@ import (
 	"context"
 	"crypto/sha1"
 	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	pb "github.com/libp2p/go-libp2p-pubsub/pb"
 	tnet "github.com/libp2p/go-libp2p-testing/net"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
 )
 
 func getTopics(psubs []*PubSub, topicID string, opts ...TopicOpt) []*Topic {
@@ -133,6 +136,148 @@ func testTopicCloseWithOpenResource(t *testing.T, openResource func(topic *Topic
 	}
 }
 
+func TestTopicTryJoin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	topicID := "foobar"
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0])
+
+	const numJoins = 10
+	var wg sync.WaitGroup
+	var created int32
+	topics := make([]*Topic, numJoins)
+	for i := 0; i < numJoins; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			topic, ok, err := ps.TryJoin(topicID)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if ok {
+				atomic.AddInt32(&created, 1)
+			}
+			topics[i] = topic
+		}(i)
+	}
+	wg.Wait()
+
+	if created != 1 {
+		t.Fatalf("expected the topic handle to be created once, but it was created %d times", created)
+	}
+	for i, topic := range topics[1:] {
+		if topic == topics[i] {
+			t.Fatal("expected every component to get its own topic handle")
+		}
+	}
+
+	found, ok := ps.GetTopic(topicID)
+	if !ok || found.String() != topicID {
+		t.Fatal("expected to find the joined topic")
+	}
+	if joined := ps.JoinedTopics(); len(joined) != 1 || joined[0] != topicID {
+		t.Fatalf("expected to have joined only %s, but got %v", topicID, joined)
+	}
+
+	// options the topic wasn't joined with can't be applied to an existing handle
+	_, _, err := ps.TryJoin(topicID, WithTopicMessageIdFn(func(pmsg *pb.Message) string { return "" }))
+	if err == nil {
+		t.Fatal("expected an error applying topic options to an existing handle")
+	}
+
+	// closing the handles of all but one component, even more than once, must not break the
+	// remaining one
+	if err := found.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < numJoins; i++ {
+		for j := 0; j < 2; j++ {
+			if err := topics[i].Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := topics[i].Subscribe(); err != ErrTopicClosed {
+			t.Fatal("expected the handle to be closed")
+		}
+		if err := topics[i].Publish(ctx, []byte("closed")); err != ErrTopicClosed {
+			t.Fatal("expected the handle to be closed")
+		}
+	}
+
+	sub, err := topics[0].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.Cancel()
+	time.Sleep(time.Millisecond * 100)
+
+	if err := topics[0].Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := topics[0].Subscribe(); err != ErrTopicClosed {
+		t.Fatal("expected the topic to be closed")
+	}
+	if _, ok := ps.GetTopic(topicID); ok {
+		t.Fatal("expected no topic handle after closing")
+	}
+	if joined := ps.JoinedTopics(); len(joined) != 0 {
+		t.Fatalf("expected no joined topics, but got %v", joined)
+	}
+}
+
+func TestTopicTryJoinOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	topicID := "foobar"
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0])
+
+	msgID := func(pmsg *pb.Message) string { return string(pmsg.GetData()) }
+	otherMsgID := func(pmsg *pb.Message) string { return string(pmsg.GetSeqno()) }
+
+	topic, ok, err := ps.TryJoin(topicID, WithTopicMessageIdFn(msgID), WithPublishRateLimit(10, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected the topic to be newly joined")
+	}
+	defer topic.Close()
+
+	// the same options, or a subset of them, are compatible with the existing topic
+	for _, opts := range [][]TopicOpt{
+		nil,
+		{WithTopicMessageIdFn(msgID), WithPublishRateLimit(10, 5)},
+		{WithPublishRateLimit(10, 5)},
+		{WithTopicMessageIdFn(msgID)},
+	} {
+		h, ok, err := ps.TryJoin(topicID, opts...)
+		if err != nil {
+			t.Fatalf("expected %d compatible options to be accepted, but got %s", len(opts), err)
+		}
+		if ok {
+			t.Fatal("expected the topic to be joined already")
+		}
+		h.Close()
+	}
+
+	// options differing from the ones the topic was joined with conflict
+	for _, opts := range [][]TopicOpt{
+		{WithTopicMessageIdFn(otherMsgID)},
+		{WithPublishRateLimit(20, 5)},
+		{WithTopicMessageIdFn(msgID), WithPublishRateLimit(10, 1)},
+		{WithForwardDeadline(time.Minute)},
+	} {
+		if _, _, err := ps.TryJoin(topicID, opts...); err == nil {
+			t.Fatal("expected an error applying conflicting options to an existing topic")
+		}
+	}
+}
 func TestTopicReuse(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -1064,3 +1209,632 @@ func TestWithLocalPublication(t *testing.T) {
 		t.Fatal("wrong message")
 	}
 }
+
+func TestSelfDelivery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "test"
+
+	hosts := getNetHosts(t, ctx, 2)
+	pubsubs := getPubsubs(ctx, hosts)
+	topics := getTopics(pubsubs, topic)
+
+	all, err := topics[0].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	others, err := topics[0].Subscribe(WithoutSelfMessages())
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := topics[1].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connectAll(t, hosts)
+	time.Sleep(100 * time.Millisecond)
+
+	// our own messages are only delivered to the subscription that didn't opt out
+	own := []byte("own message")
+	if err := topics[0].Publish(ctx, own); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, all, own)
+	assertReceive(t, remote, own)
+	assertNeverReceives(t, others, 100*time.Millisecond)
+
+	// while messages from other peers are delivered to both
+	theirs := []byte("their message")
+	if err := topics[1].Publish(ctx, theirs); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, all, theirs)
+	assertReceive(t, others, theirs)
+	assertReceive(t, remote, theirs)
+
+	// without local delivery, the message only goes to the network
+	quiet := []byte("not for us")
+	if err := topics[0].Publish(ctx, quiet, WithLocalDelivery(false)); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, remote, quiet)
+	assertNeverReceives(t, all, 100*time.Millisecond)
+	assertNeverReceives(t, others, 100*time.Millisecond)
+
+	if err := topics[0].Publish(ctx, quiet, WithLocalDelivery(false), WithLocalPublication(true)); err == nil {
+		t.Fatal("expected an error publishing a message that goes nowhere")
+	}
+}
+
+func TestPublishWithRetry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "test"
+
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := getPubsubs(ctx, hosts)
+	topics := getTopics(psubs, topic)
+
+	local, err := topics[0].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := topics[1].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the peers only connect after a while
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		connectAll(t, hosts)
+	}()
+
+	payload := []byte("eventually")
+	if err := topics[0].Publish(ctx, payload, WithRetry(50, 50*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the message is delivered to our own subscription once, while it reaches the remote peer
+	assertReceive(t, local, payload)
+	assertReceive(t, remote, payload)
+	assertNeverReceives(t, local, 200*time.Millisecond)
+	assertNeverReceives(t, remote, 200*time.Millisecond)
+}
+
+func TestPublishWithRetryNoPeers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	psubs := getPubsubs(ctx, hosts)
+	topics := getTopics(psubs, "test")
+
+	local, err := topics[0].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte("nobody listens")
+	err = topics[0].Publish(ctx, payload, WithRetry(3, 10*time.Millisecond))
+	if !errors.Is(err, ErrNoPeersInTopic) {
+		t.Fatalf("expected ErrNoPeersInTopic, but got %v", err)
+	}
+
+	assertReceive(t, local, payload)
+	assertNeverReceives(t, local, 100*time.Millisecond)
+
+	// the retries stop when the context is done
+	pctx, pcancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer pcancel()
+	if err := topics[0].Publish(pctx, payload, WithRetry(100, 50*time.Millisecond)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to be exceeded, but got %v", err)
+	}
+}
+
+func TestPublishResult(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "test"
+
+	hosts := getNetHosts(t, ctx, 4)
+
+	// the last peer has a score below the publish threshold, but above the graylist threshold
+	psubs := []*PubSub{getGossipsub(ctx, hosts[0], WithPeerScore(
+		&PeerScoreParams{
+			AppSpecificScore: func(p peer.ID) float64 {
+				if p == hosts[3].ID() {
+					return -10
+				}
+				return 0
+			},
+			AppSpecificWeight: 1,
+			DecayInterval:     time.Second,
+			DecayToZero:       0.01,
+		},
+		&PeerScoreThresholds{
+			GossipThreshold:   -1,
+			PublishThreshold:  -1,
+			GraylistThreshold: -100,
+		}))}
+	psubs = append(psubs, getGossipsubs(ctx, hosts[1:])...)
+	topics := getTopics(psubs, topic)
+	for _, tp := range topics[1:] {
+		if _, err := tp.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	connectAll(t, hosts)
+	time.Sleep(time.Second)
+
+	var res PublishResult
+	if err := topics[0].Publish(ctx, []byte("hello"), WithPublishResult(&res, true)); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Recipients != 2 {
+		t.Fatalf("expected 2 recipients, got %d", res.Recipients)
+	}
+	if res.BelowPublishThreshold != 1 {
+		t.Fatalf("expected 1 peer below the publish threshold, got %d", res.BelowPublishThreshold)
+	}
+	if res.QueueFull != 0 || res.Backoff != 0 {
+		t.Fatalf("unexpected exclusions: %+v", res)
+	}
+	recipients := make(map[peer.ID]struct{})
+	for _, p := range res.Peers {
+		recipients[p] = struct{}{}
+	}
+	if len(recipients) != 2 {
+		t.Fatalf("expected 2 recipient peers, got %v", res.Peers)
+	}
+	for _, h := range hosts[1:3] {
+		if _, ok := recipients[h.ID()]; !ok {
+			t.Fatalf("expected %s to be a recipient, got %v", h.ID(), res.Peers)
+		}
+	}
+
+	// the result is reset for every publish, and only lists the peers if requested
+	if err := topics[0].Publish(ctx, []byte("again"), WithPublishResult(&res, false)); err != nil {
+		t.Fatal(err)
+	}
+	if res.Recipients != 2 || res.BelowPublishThreshold != 1 || res.Peers != nil {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestPublishResultFloodsub(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 3)
+	psubs := getPubsubs(ctx, hosts)
+	topics := getTopics(psubs, "test")
+	for _, tp := range topics[1:] {
+		if _, err := tp.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	connectAll(t, hosts)
+	time.Sleep(100 * time.Millisecond)
+
+	var res PublishResult
+	if err := topics[0].Publish(ctx, []byte("hello"), WithPublishResult(&res, true)); err != nil {
+		t.Fatal(err)
+	}
+	if res.Recipients != 2 || len(res.Peers) != 2 {
+		t.Fatalf("expected 2 recipients, got %+v", res)
+	}
+}
+
+func xorTransform(data []byte) []byte {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[i] = b ^ 0x5a
+	}
+	return out
+}
+
+func TestTopicTransforms(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "foobar"
+	hosts := getNetHosts(t, ctx, 3)
+	psubs := getPubsubs(ctx, hosts)
+
+	outbound := WithOutboundTransform(func(data []byte) ([]byte, error) {
+		return xorTransform(data), nil
+	})
+	inbound := WithInboundTransform(func(msg *Message) error {
+		msg.Data = xorTransform(msg.Data)
+		return nil
+	})
+
+	// the middle node doesn't know about the transforms, and must forward the messages as received
+	tA, err := psubs[0].Join(topic, outbound, inbound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tB, err := psubs[1].Join(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tC, err := psubs[2].Join(topic, outbound, inbound)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subA, err := tA.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	subB, err := tB.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	subC, err := tC.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+	time.Sleep(time.Millisecond * 100)
+
+	payload := []byte("pubsub rocks")
+	if err := tA.Publish(ctx, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	msgA, err := subA.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgB, err := subB.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgC, err := subC.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(msgA.Data, payload) {
+		t.Fatalf("expected self delivery of %q, got %q", payload, msgA.Data)
+	}
+	if !bytes.Equal(msgB.Data, xorTransform(payload)) {
+		t.Fatalf("expected the wire form at the middle node, got %q", msgB.Data)
+	}
+	if !bytes.Equal(msgC.Data, payload) {
+		t.Fatalf("expected %q at the receiver, got %q", payload, msgC.Data)
+	}
+
+	// the message ID is computed on the wire form
+	if id := DefaultMsgIdFn(msgB.Message); msgA.ID != id || msgC.ID != id {
+		t.Fatalf("expected message ID %q everywhere, got %q and %q", id, msgA.ID, msgC.ID)
+	}
+}
+
+func TestTopicInboundTransformError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topicA, topicB = "foobarA", "foobarB"
+	hosts := getNetHosts(t, ctx, 3)
+	tracer := &messageOutcomeTracer{outcomes: make(map[string][]string)}
+	failures := &transformFailureTracer{}
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0]),
+		getPubsub(ctx, hosts[1], WithEventTracer(tracer), WithRawTracer(failures)),
+		getPubsub(ctx, hosts[2]),
+	}
+
+	failing := WithInboundTransform(func(msg *Message) error {
+		return errors.New("cannot decrypt")
+	})
+
+	// messages failing the transform in the middle are forwarded, unless configured otherwise
+	var subs []*Subscription
+	var pubTopics []*Topic
+	for _, tc := range []struct {
+		topic string
+		opts  []TopicOpt
+	}{
+		{topicA, []TopicOpt{failing}},
+		{topicB, []TopicOpt{failing, WithDropOnTransformError()}},
+	} {
+		var topics []*Topic
+		for i, ps := range psubs {
+			var opts []TopicOpt
+			if i == 1 {
+				opts = tc.opts
+			}
+			topic, err := ps.Join(tc.topic, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			topics = append(topics, topic)
+		}
+		for _, topic := range topics[1:] {
+			sub, err := topic.Subscribe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			subs = append(subs, sub)
+		}
+		pubTopics = append(pubTopics, topics[0])
+	}
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+	time.Sleep(time.Millisecond * 100)
+
+	for _, topic := range pubTopics {
+		if err := topic.Publish(ctx, []byte("pubsub rocks")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []bool{false, true, false, false}
+	for i, sub := range subs {
+		rctx, rcancel := context.WithTimeout(ctx, time.Second)
+		_, err := sub.Next(rctx)
+		rcancel()
+		if received := err == nil; received != expected[i] {
+			t.Fatalf("subscription %d: expected delivery %t, got %t", i, expected[i], received)
+		}
+	}
+
+	// the forwarded message is valid, so it is traced as delivered in the middle, while the dropped
+	// one is ignored
+	if outcomes := tracer.get(topicA); len(outcomes) != 1 || outcomes[0] != "delivered" {
+		t.Fatalf("expected the message in %s to be delivered, got %v", topicA, outcomes)
+	}
+	if outcomes := tracer.get(topicB); len(outcomes) != 1 || outcomes[0] != RejectTransformFailed {
+		t.Fatalf("expected the message in %s to be rejected for the transform, got %v", topicB, outcomes)
+	}
+	if topics := failures.get(); len(topics) != 2 || topics[0] != topicA || topics[1] != topicB {
+		t.Fatalf("expected the transform failures to be traced in both topics, got %v", topics)
+	}
+}
+
+// transformFailureTracer records the topics of the messages failing the inbound transform.
+type transformFailureTracer struct {
+	mx     sync.Mutex
+	topics []string
+}
+
+func (t *transformFailureTracer) TransformFailed(msg *Message) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.topics = append(t.topics, msg.GetTopic())
+}
+
+func (t *transformFailureTracer) get() []string {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return append([]string(nil), t.topics...)
+}
+
+func (t *transformFailureTracer) AddPeer(p peer.ID, proto protocol.ID)      {}
+func (t *transformFailureTracer) RemovePeer(p peer.ID)                      {}
+func (t *transformFailureTracer) Join(topic string)                         {}
+func (t *transformFailureTracer) Leave(topic string)                        {}
+func (t *transformFailureTracer) Graft(p peer.ID, topic string)             {}
+func (t *transformFailureTracer) Prune(p peer.ID, topic string)             {}
+func (t *transformFailureTracer) ValidateMessage(msg *Message)              {}
+func (t *transformFailureTracer) DeliverMessage(msg *Message)               {}
+func (t *transformFailureTracer) RejectMessage(msg *Message, reason string) {}
+func (t *transformFailureTracer) DuplicateMessage(msg *Message)             {}
+func (t *transformFailureTracer) ThrottlePeer(p peer.ID)                    {}
+func (t *transformFailureTracer) RecvRPC(rpc *RPC)                          {}
+func (t *transformFailureTracer) SendRPC(rpc *RPC, p peer.ID)               {}
+func (t *transformFailureTracer) DropRPC(rpc *RPC, p peer.ID)               {}
+func (t *transformFailureTracer) UndeliverableMessage(msg *Message)         {}
+
+// messageOutcomeTracer records the deliveries and the rejection reasons traced in each topic.
+type messageOutcomeTracer struct {
+	mx       sync.Mutex
+	outcomes map[string][]string
+}
+
+func (t *messageOutcomeTracer) Trace(evt *pb.TraceEvent) {
+	var topic, outcome string
+	switch evt.GetType() {
+	case pb.TraceEvent_DELIVER_MESSAGE:
+		topic, outcome = evt.GetDeliverMessage().GetTopic(), "delivered"
+	case pb.TraceEvent_REJECT_MESSAGE:
+		topic, outcome = evt.GetRejectMessage().GetTopic(), evt.GetRejectMessage().GetReason()
+	default:
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.outcomes[topic] = append(t.outcomes[topic], outcome)
+}
+
+func (t *messageOutcomeTracer) get(topic string) []string {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return append([]string(nil), t.outcomes[topic]...)
+}
+
+func TestPublishLocalMetadata(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topicID = "foobar"
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := getPubsubs(ctx, hosts)
+
+	// the validators record the height they are given, or -1
+	heights := make([]chan int, len(psubs))
+	for i, ps := range psubs {
+		ch := make(chan int, 1)
+		heights[i] = ch
+		err := ps.RegisterTopicValidator(topicID, func(_ context.Context, _ peer.ID, msg *Message) bool {
+			height, ok := msg.LocalMetadata("height")
+			if !ok {
+				ch <- -1
+				return true
+			}
+			ch <- height.(int)
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	topics := getTopics(psubs, topicID)
+	var subs []*Subscription
+	for _, tp := range topics {
+		sub, err := tp.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	if err := topics[0].Publish(ctx, []byte("block"), WithLocalMetadata("height", 42)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []int{42, -1}
+	for i, sub := range subs {
+		if height := <-heights[i]; height != expected[i] {
+			t.Fatalf("validator %d: expected height %d, got %d", i, expected[i], height)
+		}
+
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		height, ok := msg.LocalMetadata("height")
+		if i == 0 && (!ok || height.(int) != 42) {
+			t.Fatalf("expected the local subscriber to see the height, got %v", height)
+		}
+		if i == 1 && ok {
+			t.Fatalf("expected the remote subscriber to see no metadata, got %v", height)
+		}
+		if _, ok := msg.LocalMetadata("other"); ok {
+			t.Fatal("unexpected metadata")
+		}
+	}
+}
+
+func TestMessageIDForData(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := getPubsubs(ctx, hosts)
+	connect(t, hosts[0], hosts[1])
+
+	contentID := func(pmsg *pb.Message) string {
+		hash := sha1.Sum(pmsg.Data)
+		return string(hash[:])
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts []TopicOpt
+	}{
+		{"default", nil},
+		{"topic", []TopicOpt{WithTopicMessageIdFn(contentID)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pub, err := psubs[0].Join(tc.name, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recv, err := psubs[1].Join(tc.name, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sub, err := recv.Subscribe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sub.Cancel()
+
+			data := []byte("pubsub rocks")
+			if _, err := pub.MessageIDForData(data); err != ErrSeqnoRequired {
+				t.Fatalf("expected ErrSeqnoRequired without a seqno, got %v", err)
+			}
+
+			id, err := pub.MessageIDForData(data, WithSeqno(42))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := pub.Publish(ctx, data, WithSeqno(42), WithReadiness(MinTopicSize(1))); err != nil {
+				t.Fatal(err)
+			}
+
+			msg, err := sub.Next(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.ID != id {
+				t.Fatalf("expected ID %x, got %x", id, msg.ID)
+			}
+			if seqno := binary.BigEndian.Uint64(msg.Seqno); seqno != 42 {
+				t.Fatalf("expected seqno 42, got %d", seqno)
+			}
+			if got := psubs[1].MessageID(msg.Message); got != id {
+				t.Fatalf("expected MessageID %x, got %x", id, got)
+			}
+		})
+	}
+}
+
+func TestMessageIDForDataNoSign(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	contentID := func(pmsg *pb.Message) string {
+		hash := sha256.Sum256(pmsg.Data)
+		return string(hash[:])
+	}
+
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := getPubsubs(ctx, hosts, WithMessageSignaturePolicy(StrictNoSign), WithNoAuthor(), WithMessageIdFn(contentID))
+	connect(t, hosts[0], hosts[1])
+
+	topics := getTopics(psubs, "foobar")
+	sub, err := topics[1].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("pubsub rocks")
+	// the messages carry no seqno, so none is needed
+	id, err := topics[0].MessageIDForData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := topics[0].MessageIDForData(data, WithSeqno(1)); err == nil {
+		t.Fatal("expected an error for a seqno without an author")
+	}
+
+	if err := topics[0].Publish(ctx, data, WithReadiness(MinTopicSize(1))); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := sub.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID != id {
+		t.Fatalf("expected ID %x, got %x", id, msg.ID)
+	}
+}
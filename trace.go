@@ -22,6 +22,12 @@ type EventTracer interface {
 // Note that the tracers are invoked synchronously, which means that application tracers must
 // take care to not block or modify arguments.
 //
+// The payloads of the messages received from peers are borrowed from the buffer the RPC was read
+// into, which is reused once the RPC has been handled: the Data of the messages passed to
+// DuplicateMessage and RejectMessage, and of the messages of the RPC passed to RecvRPC, is only
+// valid for the duration of the call. Tracers keeping it must copy it. The messages passed to the
+// other methods own their payload.
+//
 // Warning: this interface is not fixed, we may be adding new methods as necessitated by the system
 // in the future.
 type RawTracer interface {
@@ -59,6 +65,27 @@ type RawTracer interface {
 	UndeliverableMessage(msg *Message)
 }
 
+// PeerSubscriptionTracer is implemented by the raw tracers that follow the subscriptions of the
+// peers, for instance to reconstruct the membership of the topics over time; it is optional, so
+// that the raw tracers that don't need it are unaffected. The peers that disconnect leave their
+// topics without unsubscribing; see RemovePeer.
+type PeerSubscriptionTracer interface {
+	// PeerSubscribe is invoked when a peer subscribes to a topic.
+	PeerSubscribe(p peer.ID, topic string)
+	// PeerUnsubscribe is invoked when a peer unsubscribes from a topic.
+	PeerUnsubscribe(p peer.ID, topic string)
+}
+
+// TransformFailureTracer is implemented by the raw tracers that follow the messages failing the
+// inbound transform of their topic; it is optional, so that the raw tracers that don't need it are
+// unaffected. The messages failing the transform are still valid, and unless the topic is set
+// with WithDropOnTransformError they are forwarded and traced as delivered, though they are not
+// delivered to our subscriptions; otherwise they are traced as rejected with RejectTransformFailed.
+type TransformFailureTracer interface {
+	// TransformFailed is invoked when a message fails the inbound transform of its topic.
+	TransformFailed(msg *Message)
+}
+
 // pubsub tracer details
 type pubsubTracer struct {
 	tracer EventTracer
@@ -247,6 +274,76 @@ func (t *pubsubTracer) RemovePeer(p peer.ID) {
 	t.tracer.Trace(evt)
 }
 
+func (t *pubsubTracer) TransformFailed(msg *Message) {
+	if t == nil {
+		return
+	}
+
+	for _, tr := range t.raw {
+		if ft, ok := tr.(TransformFailureTracer); ok {
+			ft.TransformFailed(msg)
+		}
+	}
+}
+
+func (t *pubsubTracer) PeerSubscribe(p peer.ID, topic string) {
+	if t == nil {
+		return
+	}
+
+	for _, tr := range t.raw {
+		if st, ok := tr.(PeerSubscriptionTracer); ok {
+			st.PeerSubscribe(p, topic)
+		}
+	}
+
+	if t.tracer == nil {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	evt := &pb.TraceEvent{
+		Type:      pb.TraceEvent_PEER_SUBSCRIBE.Enum(),
+		PeerID:    []byte(t.pid),
+		Timestamp: &now,
+		PeerSubscribe: &pb.TraceEvent_PeerSubscribe{
+			PeerID: []byte(p),
+			Topic:  &topic,
+		},
+	}
+
+	t.tracer.Trace(evt)
+}
+
+func (t *pubsubTracer) PeerUnsubscribe(p peer.ID, topic string) {
+	if t == nil {
+		return
+	}
+
+	for _, tr := range t.raw {
+		if st, ok := tr.(PeerSubscriptionTracer); ok {
+			st.PeerUnsubscribe(p, topic)
+		}
+	}
+
+	if t.tracer == nil {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	evt := &pb.TraceEvent{
+		Type:      pb.TraceEvent_PEER_UNSUBSCRIBE.Enum(),
+		PeerID:    []byte(t.pid),
+		Timestamp: &now,
+		PeerUnsubscribe: &pb.TraceEvent_PeerUnsubscribe{
+			PeerID: []byte(p),
+			Topic:  &topic,
+		},
+	}
+
+	t.tracer.Trace(evt)
+}
+
 func (t *pubsubTracer) RecvRPC(rpc *RPC) {
 	if t == nil {
 		return
@@ -348,6 +445,13 @@ func (t *pubsubTracer) traceRPCMeta(rpc *RPC) *pb.TraceEvent_RPCMeta {
 			Topic:     m.Topic,
 		})
 	}
+	// the copies of messages already seen, dropped on receipt
+	for _, m := range rpc.dups {
+		msgs = append(msgs, &pb.TraceEvent_MessageMeta{
+			MessageID: []byte(m.ID),
+			Topic:     m.Topic,
+		})
+	}
 	rpcMeta.Messages = msgs
 
 	var subs []*pb.TraceEvent_SubMeta
@@ -397,8 +501,9 @@ func (t *pubsubTracer) traceRPCMeta(rpc *RPC) *pb.TraceEvent_RPCMeta {
 				peers = append(peers, pi.PeerID)
 			}
 			prune = append(prune, &pb.TraceEvent_ControlPruneMeta{
-				Topic: ctl.TopicID,
-				Peers: peers,
+				Topic:  ctl.TopicID,
+				Peers:  peers,
+				Reason: ctl.Reason,
 			})
 		}
 
@@ -492,7 +597,7 @@ func (t *pubsubTracer) Graft(p peer.ID, topic string) {
 	t.tracer.Trace(evt)
 }
 
-func (t *pubsubTracer) Prune(p peer.ID, topic string) {
+func (t *pubsubTracer) Prune(p peer.ID, topic string, reason PruneReason) {
 	if t == nil {
 		return
 	}
@@ -513,6 +618,7 @@ func (t *pubsubTracer) Prune(p peer.ID, topic string) {
 		Prune: &pb.TraceEvent_Prune{
 			PeerID: []byte(p),
 			Topic:  &topic,
+			Reason: reason.pb(),
 		},
 	}
 
@@ -528,3 +634,230 @@ func (t *pubsubTracer) ThrottlePeer(p peer.ID) {
 		tr.ThrottlePeer(p)
 	}
 }
+
+// RejectRPC is only traced with the event tracer.
+func (t *pubsubTracer) RejectRPC(p peer.ID, limit pb.TraceEvent_RejectRPC_Limit) {
+	if t == nil || t.tracer == nil {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	t.tracer.Trace(&pb.TraceEvent{
+		Type:      pb.TraceEvent_REJECT_RPC.Enum(),
+		PeerID:    []byte(t.pid),
+		Timestamp: &now,
+		RejectRPC: &pb.TraceEvent_RejectRPC{
+			PeerID: []byte(p),
+			Limit:  limit.Enum(),
+		},
+	})
+}
+
+// WriteTimeout is only traced with the event tracer.
+func (t *pubsubTracer) WriteTimeout(p peer.ID) {
+	if t == nil || t.tracer == nil {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	t.tracer.Trace(&pb.TraceEvent{
+		Type:      pb.TraceEvent_WRITE_TIMEOUT.Enum(),
+		PeerID:    []byte(t.pid),
+		Timestamp: &now,
+		WriteTimeout: &pb.TraceEvent_WriteTimeout{
+			PeerID: []byte(p),
+		},
+	})
+}
+
+// IgnoreIHave is only traced with the event tracer; the topic is empty for IHAVEs ignored
+// altogether.
+func (t *pubsubTracer) IgnoreIHave(p peer.ID, reason pb.TraceEvent_IgnoreIHave_Reason, topic string) {
+	if t == nil || t.tracer == nil {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	evt := &pb.TraceEvent{
+		Type:      pb.TraceEvent_IGNORE_IHAVE.Enum(),
+		PeerID:    []byte(t.pid),
+		Timestamp: &now,
+		IgnoreIHave: &pb.TraceEvent_IgnoreIHave{
+			PeerID: []byte(p),
+			Reason: reason.Enum(),
+		},
+	}
+	if topic != "" {
+		evt.IgnoreIHave.Topic = &topic
+	}
+
+	t.tracer.Trace(evt)
+}
+
+// IWantMiss is only traced with the event tracer.
+func (t *pubsubTracer) IWantMiss(p peer.ID, mid string) {
+	if t == nil || t.tracer == nil {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	t.tracer.Trace(&pb.TraceEvent{
+		Type:      pb.TraceEvent_IWANT_MISS.Enum(),
+		PeerID:    []byte(t.pid),
+		Timestamp: &now,
+		IwantMiss: &pb.TraceEvent_IWantMiss{
+			PeerID:    []byte(p),
+			MessageID: []byte(mid),
+		},
+	})
+}
+
+// CircuitBreaker is only traced with the event tracer.
+func (t *pubsubTracer) CircuitBreaker(topic string, state CircuitBreakerState) {
+	if t == nil || t.tracer == nil {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	t.tracer.Trace(&pb.TraceEvent{
+		Type:      pb.TraceEvent_CIRCUIT_BREAKER.Enum(),
+		PeerID:    []byte(t.pid),
+		Timestamp: &now,
+		CircuitBreaker: &pb.TraceEvent_CircuitBreaker{
+			Topic: &topic,
+			State: state.pb(),
+		},
+	})
+}
+
+// StaleMessage is only traced with the event tracer; the peer is empty for messages that were not
+// forwarded at all.
+func (t *pubsubTracer) StaleMessage(msg *pb.Message, p peer.ID) {
+	if t == nil || t.tracer == nil {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	evt := &pb.TraceEvent{
+		Type:      pb.TraceEvent_STALE_MESSAGE.Enum(),
+		PeerID:    []byte(t.pid),
+		Timestamp: &now,
+		StaleMessage: &pb.TraceEvent_StaleMessage{
+			MessageID: []byte(t.idGen.RawID(msg)),
+			Topic:     msg.Topic,
+		},
+	}
+	if p != "" {
+		evt.StaleMessage.PeerID = []byte(p)
+	}
+
+	t.tracer.Trace(evt)
+}
+
+// SubscriptionChurn is only traced with the event tracer.
+func (t *pubsubTracer) SubscriptionChurn(p peer.ID, topic string, cooldown time.Duration) {
+	if t == nil || t.tracer == nil {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	cd := int64(cooldown)
+	evt := &pb.TraceEvent{
+		Type:      pb.TraceEvent_SUBSCRIPTION_CHURN.Enum(),
+		PeerID:    []byte(t.pid),
+		Timestamp: &now,
+		SubscriptionChurn: &pb.TraceEvent_SubscriptionChurn{
+			PeerID:   []byte(p),
+			Topic:    &topic,
+			Cooldown: &cd,
+		},
+	}
+
+	t.tracer.Trace(evt)
+}
+
+// MalformedRPC is only traced with the event tracer.
+func (t *pubsubTracer) MalformedRPC(p peer.ID, entries int) {
+	if t == nil || t.tracer == nil {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	n := uint32(entries)
+	evt := &pb.TraceEvent{
+		Type:      pb.TraceEvent_MALFORMED_RPC.Enum(),
+		PeerID:    []byte(t.pid),
+		Timestamp: &now,
+		MalformedRPC: &pb.TraceEvent_MalformedRPC{
+			PeerID:  []byte(p),
+			Entries: &n,
+		},
+	}
+
+	t.tracer.Trace(evt)
+}
+
+func (t *pubsubTracer) ReannounceSubscriptions(p peer.ID, topics []string) {
+	if t == nil || t.tracer == nil {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	evt := &pb.TraceEvent{
+		Type:      pb.TraceEvent_REANNOUNCE_SUBSCRIPTIONS.Enum(),
+		PeerID:    []byte(t.pid),
+		Timestamp: &now,
+		ReannounceSubscriptions: &pb.TraceEvent_ReannounceSubscriptions{
+			PeerID: []byte(p),
+			Topics: topics,
+		},
+	}
+
+	t.tracer.Trace(evt)
+}
+
+func (t *pubsubTracer) SlowConsumerThrottle(topic string, throttled bool, dropped uint64) {
+	if t == nil || t.tracer == nil {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	evt := &pb.TraceEvent{
+		Type:      pb.TraceEvent_SLOW_CONSUMER_THROTTLE.Enum(),
+		PeerID:    []byte(t.pid),
+		Timestamp: &now,
+		SlowConsumerThrottle: &pb.TraceEvent_SlowConsumerThrottle{
+			Topic:     &topic,
+			Throttled: &throttled,
+			Dropped:   &dropped,
+		},
+	}
+
+	t.tracer.Trace(evt)
+}
+
+func (t *pubsubTracer) TopicConfig(topic string, cfg *TopicRuntimeConfig, scoreParams bool) {
+	if t == nil || t.tracer == nil {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	evt := &pb.TraceEvent{
+		Type:      pb.TraceEvent_TOPIC_CONFIG.Enum(),
+		PeerID:    []byte(t.pid),
+		Timestamp: &now,
+		TopicConfig: &pb.TraceEvent_TopicConfig{
+			Topic:        &topic,
+			ScoreParams:  &scoreParams,
+			FloodPublish: cfg.FloodPublish,
+		},
+	}
+	if cfg.D != 0 {
+		d, dlo, dhi := uint32(cfg.D), uint32(cfg.Dlo), uint32(cfg.Dhi)
+		evt.TopicConfig.D = &d
+		evt.TopicConfig.Dlo = &dlo
+		evt.TopicConfig.Dhi = &dhi
+	}
+
+	t.tracer.Trace(evt)
+}
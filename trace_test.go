@@ -1,21 +1,27 @@
 package pubsub
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p-pubsub/traceutil"
 
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
 
 	bhost "github.com/libp2p/go-libp2p/p2p/host/blank"
 	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
@@ -121,7 +127,7 @@ func testWithTracer(t *testing.T, tracer EventTracer) {
 }
 
 type traceStats struct {
-	publish, reject, duplicate, deliver, add, remove, recv, send, drop, join, leave, graft, prune int
+	publish, reject, duplicate, deliver, add, remove, recv, send, drop, join, leave, graft, prune, subscribe, unsubscribe int
 }
 
 func (t *traceStats) process(evt *pb.TraceEvent) {
@@ -153,6 +159,10 @@ func (t *traceStats) process(evt *pb.TraceEvent) {
 		t.graft++
 	case pb.TraceEvent_PRUNE:
 		t.prune++
+	case pb.TraceEvent_PEER_SUBSCRIBE:
+		t.subscribe++
+	case pb.TraceEvent_PEER_UNSUBSCRIBE:
+		t.unsubscribe++
 	}
 }
 
@@ -190,6 +200,9 @@ func (ts *traceStats) check(t *testing.T) {
 	if ts.prune == 0 {
 		t.Fatal("expected non-zero count")
 	}
+	if ts.subscribe == 0 {
+		t.Fatal("expected non-zero count")
+	}
 }
 
 func TestJSONTracer(t *testing.T) {
@@ -258,14 +271,70 @@ func TestPBTracer(t *testing.T) {
 	stats.check(t)
 }
 
+// Test that the file tracers write the golden traces, written before their framing moved to
+// traceutil, byte for byte.
+func TestFileTracersGolden(t *testing.T) {
+	dir := t.TempDir()
+	jsonTracer, err := NewJSONTracer(dir + "/trace.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbTracer, err := NewPBTracer(dir + "/trace.pb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := traceutil.OpenTraceFile("traceutil/testdata/trace.pb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	events, err := traceutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, evt := range events {
+		jsonTracer.Trace(evt)
+		pbTracer.Trace(evt)
+	}
+	jsonTracer.Close()
+	pbTracer.Close()
+	time.Sleep(time.Second)
+
+	for _, name := range []string{"trace.json", "trace.pb"} {
+		golden, err := os.ReadFile("traceutil/testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		traced, err := os.ReadFile(dir + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(traced, golden) {
+			t.Fatalf("expected %s to match the golden trace", name)
+		}
+	}
+}
+
 type mockRemoteTracer struct {
 	mx sync.Mutex
 	ts traceStats
+
+	// sent to the tracer when it opens the stream, if set
+	handshake *pb.TraceHandshake
+	types     map[pb.TraceEvent_Type]int
+	versions  map[uint32]int
 }
 
 func (mrt *mockRemoteTracer) handleStream(s network.Stream) {
 	defer s.Close()
 
+	if mrt.handshake != nil {
+		if err := protoio.NewDelimitedWriter(s).WriteMsg(mrt.handshake); err != nil {
+			panic(err)
+		}
+	}
+
 	gzr, err := gzip.NewReader(s)
 	if err != nil {
 		panic(err)
@@ -285,8 +354,14 @@ func (mrt *mockRemoteTracer) handleStream(s network.Stream) {
 		}
 
 		mrt.mx.Lock()
+		if mrt.types == nil {
+			mrt.types = make(map[pb.TraceEvent_Type]int)
+			mrt.versions = make(map[uint32]int)
+		}
+		mrt.versions[batch.GetSchemaVersion()]++
 		for _, evt := range batch.GetBatch() {
 			mrt.ts.process(evt)
+			mrt.types[evt.GetType()]++
 		}
 		mrt.mx.Unlock()
 	}
@@ -320,4 +395,440 @@ func TestRemoteTracer(t *testing.T) {
 	tracer.Close()
 
 	mrt.check(t)
+
+	// legacy collectors get batches without a schema version
+	mrt.mx.Lock()
+	defer mrt.mx.Unlock()
+	if len(mrt.versions) != 1 || mrt.versions[0] == 0 {
+		t.Fatalf("expected no schema version in the batches, got %v", mrt.versions)
+	}
+}
+
+func TestRemoteTracerHandshake(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h1 := bhost.NewBlankHost(swarmt.GenSwarm(t))
+	h2 := bhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h1.Close()
+	defer h2.Close()
+
+	version := uint32(TraceSchemaVersion + 1)
+	mask := TraceEventMask(pb.TraceEvent_GRAFT, pb.TraceEvent_PRUNE)
+	mrt := &mockRemoteTracer{handshake: &pb.TraceHandshake{SchemaVersion: &version, EventTypes: &mask}}
+	h1.SetStreamHandler(RemoteTracerProtoID, mrt.handleStream)
+
+	tracer, err := NewRemoteTracer(ctx, h2, peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}, WithRemoteTracerHandshakeWait(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testWithTracer(t, tracer)
+	time.Sleep(time.Second)
+	tracer.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	mrt.mx.Lock()
+	defer mrt.mx.Unlock()
+	for typ, n := range mrt.types {
+		if typ != pb.TraceEvent_GRAFT && typ != pb.TraceEvent_PRUNE {
+			t.Fatalf("received %d unrequested %s events", n, typ)
+		}
+	}
+	if mrt.types[pb.TraceEvent_GRAFT] == 0 || mrt.types[pb.TraceEvent_PRUNE] == 0 {
+		t.Fatalf("expected GRAFT and PRUNE events, got %v", mrt.types)
+	}
+	// the version is capped to the supported one
+	if len(mrt.versions) != 1 || mrt.versions[TraceSchemaVersion] == 0 {
+		t.Fatalf("expected schema version %d in all the batches, got %v", TraceSchemaVersion, mrt.versions)
+	}
+}
+
+func TestRemoteTracerNoHandshakeWait(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h1 := bhost.NewBlankHost(swarmt.GenSwarm(t))
+	h2 := bhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h1.Close()
+	defer h2.Close()
+
+	mrt := &mockRemoteTracer{}
+	h1.SetStreamHandler(RemoteTracerProtoID, mrt.handleStream)
+
+	tracer, err := NewRemoteTracer(ctx, h2, peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tracer.Close()
+
+	// a full batch is sent right away to a legacy collector, without waiting for a handshake
+	start := time.Now()
+	for i := 0; i < MinTraceBatchSize; i++ {
+		tracer.Trace(&pb.TraceEvent{Type: pb.TraceEvent_GRAFT.Enum()})
+	}
+	for {
+		mrt.mx.Lock()
+		n := mrt.types[pb.TraceEvent_GRAFT]
+		mrt.mx.Unlock()
+		if n == MinTraceBatchSize {
+			break
+		}
+		if time.Since(start) > 500*time.Millisecond {
+			t.Fatalf("expected the batch without waiting for a handshake, got %d events", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRemoteTracerProtocolPrefix(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h1 := bhost.NewBlankHost(swarmt.GenSwarm(t))
+	h2 := bhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h1.Close()
+	defer h2.Close()
+
+	mrt := &mockRemoteTracer{}
+	h1.SetStreamHandler("/appA"+RemoteTracerProtoID, mrt.handleStream)
+
+	tracer, err := NewRemoteTracer(ctx, h2, peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()},
+		WithRemoteTracerProtocolPrefix("/appA"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testWithTracer(t, tracer)
+	time.Sleep(time.Second)
+	tracer.Close()
+
+	mrt.check(t)
+}
+
+type mockHTTPTracer struct {
+	mx       sync.Mutex
+	ts       traceStats
+	batches  []int
+	attempts int
+	failures int
+	auth     string
+}
+
+func (mht *mockHTTPTracer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mht.mx.Lock()
+	defer mht.mx.Unlock()
+
+	mht.attempts++
+	if mht.failures > 0 {
+		mht.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	mht.auth = r.Header.Get("Authorization")
+
+	gzr, err := gzip.NewReader(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(gzr)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var batch pb.TraceEventBatch
+	err = batch.Unmarshal(data)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	mht.batches = append(mht.batches, len(batch.GetBatch()))
+	for _, evt := range batch.GetBatch() {
+		mht.ts.process(evt)
+	}
+}
+
+func (mht *mockHTTPTracer) check(t *testing.T) {
+	mht.mx.Lock()
+	defer mht.mx.Unlock()
+	mht.ts.check(t)
+}
+
+func TestHTTPTracer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mht := &mockHTTPTracer{}
+	srv := httptest.NewServer(mht)
+	defer srv.Close()
+
+	tracer, err := NewHTTPTracer(ctx, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testWithTracer(t, tracer)
+	time.Sleep(time.Second)
+	tracer.Close()
+
+	mht.check(t)
+}
+
+func TestHTTPTracerBatches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mht := &mockHTTPTracer{}
+	srv := httptest.NewServer(mht)
+	defer srv.Close()
+
+	tracer, err := NewHTTPTracer(ctx, srv.URL,
+		WithHTTPTracerAuth(func(req *http.Request) error {
+			req.Header.Set("Authorization", "Bearer secret")
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	burst := func() {
+		for i := 0; i < MinTraceBatchSize; i++ {
+			tracer.Trace(&pb.TraceEvent{Type: pb.TraceEvent_JOIN.Enum()})
+		}
+	}
+
+	batches := func() []int {
+		mht.mx.Lock()
+		defer mht.mx.Unlock()
+		return append([]int(nil), mht.batches...)
+	}
+
+	waitBatches := func(n int) {
+		for i := 0; i < 50 && len(batches()) < n; i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	burst()
+	waitBatches(1)
+	burst()
+	waitBatches(2)
+	tracer.Close()
+
+	got := batches()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 batches, got %v", got)
+	}
+	for _, n := range got {
+		if n != MinTraceBatchSize {
+			t.Fatalf("expected batches of %d events, got %v", MinTraceBatchSize, got)
+		}
+	}
+
+	mht.mx.Lock()
+	auth := mht.auth
+	mht.mx.Unlock()
+	if auth != "Bearer secret" {
+		t.Fatalf("expected auth header to be set by the hook, got %q", auth)
+	}
+}
+
+func TestHTTPTracerRetry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mht := &mockHTTPTracer{failures: 2}
+	srv := httptest.NewServer(mht)
+	defer srv.Close()
+
+	tracer, err := NewHTTPTracer(ctx, srv.URL, WithHTTPTracerRetry(3, 10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < MinTraceBatchSize; i++ {
+		tracer.Trace(&pb.TraceEvent{Type: pb.TraceEvent_JOIN.Enum()})
+	}
+
+	time.Sleep(time.Second)
+	tracer.Close()
+
+	mht.mx.Lock()
+	defer mht.mx.Unlock()
+
+	if mht.attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", mht.attempts)
+	}
+	if len(mht.batches) != 1 || mht.batches[0] != MinTraceBatchSize {
+		t.Fatalf("expected a single batch of %d events, got %v", MinTraceBatchSize, mht.batches)
+	}
+}
+
+// Test that the file tracers write the peer subscription events as in their golden traces.
+func TestPeerSubscriptionTraceGolden(t *testing.T) {
+	collector := &traceCollector{}
+	tracer := &pubsubTracer{tracer: collector, pid: peer.ID("alice")}
+	tracer.PeerSubscribe(peer.ID("bob"), "blocks")
+	tracer.PeerSubscribe(peer.ID("carol"), "txs")
+	tracer.PeerUnsubscribe(peer.ID("bob"), "blocks")
+
+	dir := t.TempDir()
+	jsonTracer, err := NewJSONTracer(dir + "/peer_subscription.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbTracer, err := NewPBTracer(dir + "/peer_subscription.pb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, evt := range collector.events {
+		// fixed timestamps, for the golden traces
+		ts := int64(1700000000000000000 + i*int(time.Millisecond))
+		evt.Timestamp = &ts
+		jsonTracer.Trace(evt)
+		pbTracer.Trace(evt)
+	}
+	jsonTracer.Close()
+	pbTracer.Close()
+	time.Sleep(time.Second)
+
+	for _, name := range []string{"peer_subscription.json", "peer_subscription.pb"} {
+		golden, err := os.ReadFile("traceutil/testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		traced, err := os.ReadFile(dir + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(traced, golden) {
+			t.Fatalf("expected %s to match the golden trace", name)
+		}
+
+		// and the events read back as traced
+		r, err := traceutil.OpenTraceFile("traceutil/testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events, err := traceutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 3 {
+			t.Fatalf("expected 3 events in %s, got %d", name, len(events))
+		}
+		sub := events[1].GetPeerSubscribe()
+		if events[1].GetType() != pb.TraceEvent_PEER_SUBSCRIBE || string(sub.GetPeerID()) != "carol" || sub.GetTopic() != "txs" {
+			t.Fatalf("unexpected subscribe event in %s: %v", name, events[1])
+		}
+		unsub := events[2].GetPeerUnsubscribe()
+		if events[2].GetType() != pb.TraceEvent_PEER_UNSUBSCRIBE || string(unsub.GetPeerID()) != "bob" || unsub.GetTopic() != "blocks" {
+			t.Fatalf("unexpected unsubscribe event in %s: %v", name, events[2])
+		}
+	}
+}
+
+type traceCollector struct {
+	mx     sync.Mutex
+	events []*pb.TraceEvent
+}
+
+func (t *traceCollector) Trace(evt *pb.TraceEvent) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.events = append(t.events, evt)
+}
+
+type peerSubscriptionTracer struct {
+	mx      sync.Mutex
+	changes []string
+}
+
+func (t *peerSubscriptionTracer) PeerSubscribe(p peer.ID, topic string) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.changes = append(t.changes, "+"+topic)
+}
+
+func (t *peerSubscriptionTracer) PeerUnsubscribe(p peer.ID, topic string) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.changes = append(t.changes, "-"+topic)
+}
+
+func (t *peerSubscriptionTracer) AddPeer(p peer.ID, proto protocol.ID)      {}
+func (t *peerSubscriptionTracer) RemovePeer(p peer.ID)                      {}
+func (t *peerSubscriptionTracer) Join(topic string)                         {}
+func (t *peerSubscriptionTracer) Leave(topic string)                        {}
+func (t *peerSubscriptionTracer) Graft(p peer.ID, topic string)             {}
+func (t *peerSubscriptionTracer) Prune(p peer.ID, topic string)             {}
+func (t *peerSubscriptionTracer) ValidateMessage(msg *Message)              {}
+func (t *peerSubscriptionTracer) DeliverMessage(msg *Message)               {}
+func (t *peerSubscriptionTracer) RejectMessage(msg *Message, reason string) {}
+func (t *peerSubscriptionTracer) DuplicateMessage(msg *Message)             {}
+func (t *peerSubscriptionTracer) ThrottlePeer(p peer.ID)                    {}
+func (t *peerSubscriptionTracer) RecvRPC(rpc *RPC)                          {}
+func (t *peerSubscriptionTracer) SendRPC(rpc *RPC, p peer.ID)               {}
+func (t *peerSubscriptionTracer) DropRPC(rpc *RPC, p peer.ID)               {}
+func (t *peerSubscriptionTracer) UndeliverableMessage(msg *Message)         {}
+
+func TestPeerSubscriptionTracer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	raw := &peerSubscriptionTracer{}
+	collector := &traceCollector{}
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0], WithRawTracer(raw), WithEventTracer(collector)),
+		getPubsub(ctx, hosts[1]),
+	}
+
+	// a subscription announced when connecting, then changes of a connected peer
+	if _, err := psubs[1].Subscribe("bar"); err != nil {
+		t.Fatal(err)
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	sub, err := psubs[1].Subscribe("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	sub.Cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	raw.mx.Lock()
+	changes := strings.Join(raw.changes, " ")
+	raw.mx.Unlock()
+	if changes != "+bar +foo -foo" {
+		t.Fatalf("expected the raw tracer to see \"+bar +foo -foo\", got %q", changes)
+	}
+
+	collector.mx.Lock()
+	defer collector.mx.Unlock()
+	var traced []string
+	for _, evt := range collector.events {
+		switch evt.GetType() {
+		case pb.TraceEvent_PEER_SUBSCRIBE:
+			if peer.ID(evt.GetPeerSubscribe().GetPeerID()) != hosts[1].ID() {
+				t.Fatalf("expected a subscription of %s, got %v", hosts[1].ID(), evt)
+			}
+			traced = append(traced, "+"+evt.GetPeerSubscribe().GetTopic())
+		case pb.TraceEvent_PEER_UNSUBSCRIBE:
+			if peer.ID(evt.GetPeerUnsubscribe().GetPeerID()) != hosts[1].ID() {
+				t.Fatalf("expected an unsubscription of %s, got %v", hosts[1].ID(), evt)
+			}
+			traced = append(traced, "-"+evt.GetPeerUnsubscribe().GetTopic())
+		}
+	}
+	if got := strings.Join(traced, " "); got != changes {
+		t.Fatalf("expected the traced events %q, got %q", changes, got)
+	}
 }
@@ -1,15 +1,20 @@
 package pubsub
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
-	"encoding/json"
+	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p-pubsub/traceutil"
 
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
@@ -23,6 +28,9 @@ import (
 var TraceBufferSize = 1 << 16 // 64K ought to be enough for everyone; famous last words.
 var MinTraceBatchSize = 16
 
+// TraceSchemaVersion is the latest trace schema version supported by the RemoteTracer.
+const TraceSchemaVersion = 1
+
 // rejection reasons
 const (
 	RejectBlacklstedPeer      = "blacklisted peer"
@@ -36,6 +44,12 @@ const (
 	RejectValidationFailed    = "validation failed"
 	RejectValidationIgnored   = "validation ignored"
 	RejectSelfOrigin          = "self originated message"
+	RejectRecentlyRejected    = "recently rejected"
+	RejectQuotaExceeded       = "quota exceeded"
+	RejectMemoryBudget        = "memory budget exceeded"
+	RejectReplayedSeqno       = "replayed seqno"
+	RejectTopicLeft           = "topic left"
+	RejectTransformFailed     = "transform failed"
 )
 
 type basicTracer struct {
@@ -44,17 +58,40 @@ type basicTracer struct {
 	buf    []*pb.TraceEvent
 	lossy  bool
 	closed bool
+	// maximum number of buffered events for lossy tracers; TraceBufferSize if 0
+	bufSize int
+	// bitmask of the traced event types (see TraceEventMask); all events are traced if 0
+	mask uint64
+}
+
+// TraceEventMask returns the bitmask selecting the given event types, for the trace handshake.
+func TraceEventMask(types ...pb.TraceEvent_Type) uint64 {
+	var mask uint64
+	for _, t := range types {
+		mask |= 1 << uint(t)
+	}
+	return mask
+}
+
+// traces returns true if the event type is selected by the mask; it must be called with the lock held.
+func (t *basicTracer) traces(evt *pb.TraceEvent) bool {
+	return t.mask == 0 || t.mask&(1<<uint(evt.GetType())) != 0
 }
 
 func (t *basicTracer) Trace(evt *pb.TraceEvent) {
 	t.mx.Lock()
 	defer t.mx.Unlock()
 
-	if t.closed {
+	if t.closed || !t.traces(evt) {
 		return
 	}
 
-	if t.lossy && len(t.buf) > TraceBufferSize {
+	bufSize := t.bufSize
+	if bufSize == 0 {
+		bufSize = TraceBufferSize
+	}
+
+	if t.lossy && len(t.buf) > bufSize {
 		log.Debug("trace buffer overflow; dropping trace event")
 	} else {
 		t.buf = append(t.buf, evt)
@@ -75,10 +112,37 @@ func (t *basicTracer) Close() {
 	}
 }
 
+// nextBatch waits until events are available for writing and then accumulates a batch for
+// up to a second, or until at least MinTraceBatchSize events are buffered.
+// The accumulated events are swapped with buf, which the caller must have consumed.
+// The second return value is false if the tracer has been closed, in which case the returned
+// batch is the last one.
+func (t *basicTracer) nextBatch(buf []*pb.TraceEvent) ([]*pb.TraceEvent, bool) {
+	_, ok := <-t.ch
+
+	// deadline for batch accumulation
+	deadline := time.Now().Add(time.Second)
+
+	t.mx.Lock()
+	for len(t.buf) < MinTraceBatchSize && time.Now().Before(deadline) {
+		t.mx.Unlock()
+		time.Sleep(100 * time.Millisecond)
+		t.mx.Lock()
+	}
+
+	tmp := t.buf
+	t.buf = buf[:0]
+	t.mx.Unlock()
+
+	return tmp, ok
+}
+
 // JSONTracer is a tracer that writes events to a file, encoded in ndjson.
+// The trace can be read with a traceutil.TraceReader.
 type JSONTracer struct {
 	basicTracer
-	w io.WriteCloser
+	w  io.WriteCloser
+	tw *traceutil.TraceWriter
 }
 
 // NewJsonTracer creates a new JSONTracer writing traces to file.
@@ -93,7 +157,13 @@ func OpenJSONTracer(file string, flags int, perm os.FileMode) (*JSONTracer, erro
 		return nil, err
 	}
 
-	tr := &JSONTracer{w: f, basicTracer: basicTracer{ch: make(chan struct{}, 1)}}
+	tw, err := traceutil.NewTraceWriter(f, traceutil.FormatJSON)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	tr := &JSONTracer{w: f, tw: tw, basicTracer: basicTracer{ch: make(chan struct{}, 1)}}
 	go tr.doWrite()
 
 	return tr, nil
@@ -101,7 +171,6 @@ func OpenJSONTracer(file string, flags int, perm os.FileMode) (*JSONTracer, erro
 
 func (t *JSONTracer) doWrite() {
 	var buf []*pb.TraceEvent
-	enc := json.NewEncoder(t.w)
 	for {
 		_, ok := <-t.ch
 
@@ -112,7 +181,7 @@ func (t *JSONTracer) doWrite() {
 		t.mx.Unlock()
 
 		for i, evt := range buf {
-			err := enc.Encode(evt)
+			err := t.tw.Write(evt)
 			if err != nil {
 				log.Warnf("error writing event trace: %s", err.Error())
 			}
@@ -129,9 +198,11 @@ func (t *JSONTracer) doWrite() {
 var _ EventTracer = (*JSONTracer)(nil)
 
 // PBTracer is a tracer that writes events to a file, as delimited protobufs.
+// The trace can be read with a traceutil.TraceReader.
 type PBTracer struct {
 	basicTracer
-	w io.WriteCloser
+	w  io.WriteCloser
+	tw *traceutil.TraceWriter
 }
 
 func NewPBTracer(file string) (*PBTracer, error) {
@@ -145,7 +216,13 @@ func OpenPBTracer(file string, flags int, perm os.FileMode) (*PBTracer, error) {
 		return nil, err
 	}
 
-	tr := &PBTracer{w: f, basicTracer: basicTracer{ch: make(chan struct{}, 1)}}
+	tw, err := traceutil.NewTraceWriter(f, traceutil.FormatPB)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	tr := &PBTracer{w: f, tw: tw, basicTracer: basicTracer{ch: make(chan struct{}, 1)}}
 	go tr.doWrite()
 
 	return tr, nil
@@ -153,7 +230,6 @@ func OpenPBTracer(file string, flags int, perm os.FileMode) (*PBTracer, error) {
 
 func (t *PBTracer) doWrite() {
 	var buf []*pb.TraceEvent
-	w := protoio.NewDelimitedWriter(t.w)
 	for {
 		_, ok := <-t.ch
 
@@ -164,7 +240,7 @@ func (t *PBTracer) doWrite() {
 		t.mx.Unlock()
 
 		for i, evt := range buf {
-			err := w.WriteMsg(evt)
+			err := t.tw.Write(evt)
 			if err != nil {
 				log.Warnf("error writing event trace: %s", err.Error())
 			}
@@ -182,17 +258,63 @@ var _ EventTracer = (*PBTracer)(nil)
 
 const RemoteTracerProtoID = protocol.ID("/libp2p/pubsub/tracer/1.0.0")
 
-// RemoteTracer is a tracer that sends trace events to a remote peer
+// RemoteTracer is a tracer that sends trace events to a remote peer.
+// The collector may send a pb.TraceHandshake when the stream is opened, selecting the event types
+// to trace and the schema version, which is then included in each batch. The batches sent before
+// the handshake arrives contain all events, without a schema version, as for collectors that don't
+// send a handshake; see WithRemoteTracerHandshakeWait to hold them back instead.
 type RemoteTracer struct {
 	basicTracer
-	ctx  context.Context
-	host host.Host
-	peer peer.ID
+	ctx   context.Context
+	host  host.Host
+	peer  peer.ID
+	proto protocol.ID
+
+	// how long to wait for the handshake before sending the first batch of a stream
+	hsWait time.Duration
+
+	// the schema version negotiated with the collector, 0 if it didn't send a handshake;
+	// protected by the lock, with the mask
+	schema uint32
+}
+
+// RemoteTracerOpt is an option for the RemoteTracer.
+type RemoteTracerOpt func(*RemoteTracer) error
+
+// WithRemoteTracerProtocolPrefix namespaces the remote tracer protocol with a prefix, for tracing
+// a PubSub instance that uses the same prefix (see WithProtocolPrefix).
+func WithRemoteTracerProtocolPrefix(prefix string) RemoteTracerOpt {
+	return func(t *RemoteTracer) error {
+		if !strings.HasPrefix(prefix, "/") || strings.HasSuffix(prefix, "/") {
+			return fmt.Errorf("invalid protocol prefix %q; must start and not end with /", prefix)
+		}
+		t.proto = protocol.ID(prefix) + RemoteTracerProtoID
+		return nil
+	}
+}
+
+// WithRemoteTracerHandshakeWait holds the first batch of each stream until the collector sends its
+// handshake, for up to timeout, so that only the selected events are ever sent. It is meant for
+// collectors known to send the handshake: the others get their first batch late by timeout, on
+// every connection.
+func WithRemoteTracerHandshakeWait(timeout time.Duration) RemoteTracerOpt {
+	return func(t *RemoteTracer) error {
+		if timeout <= 0 {
+			return fmt.Errorf("invalid handshake wait; must be positive")
+		}
+		t.hsWait = timeout
+		return nil
+	}
 }
 
 // NewRemoteTracer constructs a RemoteTracer, tracing to the peer identified by pi
-func NewRemoteTracer(ctx context.Context, host host.Host, pi peer.AddrInfo) (*RemoteTracer, error) {
-	tr := &RemoteTracer{ctx: ctx, host: host, peer: pi.ID, basicTracer: basicTracer{ch: make(chan struct{}, 1), lossy: true}}
+func NewRemoteTracer(ctx context.Context, host host.Host, pi peer.AddrInfo, opts ...RemoteTracerOpt) (*RemoteTracer, error) {
+	tr := &RemoteTracer{ctx: ctx, host: host, peer: pi.ID, proto: RemoteTracerProtoID, basicTracer: basicTracer{ch: make(chan struct{}, 1), lossy: true}}
+	for _, opt := range opts {
+		if err := opt(tr); err != nil {
+			return nil, err
+		}
+	}
 	host.Peerstore().AddAddrs(pi.ID, pi.Addrs, peerstore.PermanentAddrTTL)
 	go tr.doWrite()
 	return tr, nil
@@ -206,6 +328,7 @@ func (t *RemoteTracer) doWrite() {
 		log.Debugf("error opening remote tracer stream: %s", err.Error())
 		return
 	}
+	handshake, hsDeadline := t.readHandshake(s), time.Now().Add(t.hsWait)
 
 	var batch pb.TraceEventBatch
 
@@ -213,29 +336,25 @@ func (t *RemoteTracer) doWrite() {
 	w := protoio.NewDelimitedWriter(gzipW)
 
 	for {
-		_, ok := <-t.ch
-
-		// deadline for batch accumulation
-		deadline := time.Now().Add(time.Second)
+		var ok bool
+		buf, ok = t.nextBatch(buf)
 
-		t.mx.Lock()
-		for len(t.buf) < MinTraceBatchSize && time.Now().Before(deadline) {
-			t.mx.Unlock()
-			time.Sleep(100 * time.Millisecond)
-			t.mx.Lock()
+		if handshake != nil && t.hsWait > 0 {
+			// wait for the collector to select the events before sending any
+			timer := time.NewTimer(time.Until(hsDeadline))
+			select {
+			case <-handshake:
+			case <-timer.C:
+			}
+			timer.Stop()
+			handshake = nil
 		}
 
-		tmp := t.buf
-		t.buf = buf[:0]
-		buf = tmp
-		t.mx.Unlock()
-
-		if len(buf) == 0 {
+		batch.Batch, batch.SchemaVersion = t.filterBatch(buf)
+		if len(batch.Batch) == 0 {
 			goto end
 		}
 
-		batch.Batch = buf
-
 		err = w.WriteMsg(&batch)
 		if err != nil {
 			log.Debugf("error writing trace event batch: %s", err)
@@ -273,14 +392,72 @@ func (t *RemoteTracer) doWrite() {
 			}
 
 			gzipW.Reset(s)
+			handshake, hsDeadline = t.readHandshake(s), time.Now().Add(t.hsWait)
+		}
+	}
+}
+
+// readHandshake resets the negotiated parameters for a new stream and reads the handshake of the
+// collector in the background; the returned channel is closed when the handshake has been handled,
+// or the collector closed the stream without sending one.
+func (t *RemoteTracer) readHandshake(s network.Stream) <-chan struct{} {
+	t.mx.Lock()
+	t.mask = 0
+	t.schema = 0
+	t.mx.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		var hs pb.TraceHandshake
+		r := protoio.NewDelimitedReader(s, 1024)
+		if err := r.ReadMsg(&hs); err != nil {
+			// legacy collector, or the stream is gone
+			return
+		}
+
+		schema := hs.GetSchemaVersion()
+		if schema == 0 || schema > TraceSchemaVersion {
+			schema = TraceSchemaVersion
+		}
+
+		t.mx.Lock()
+		t.mask = hs.GetEventTypes()
+		t.schema = schema
+		t.mx.Unlock()
+	}()
+	return done
+}
+
+// filterBatch drops the events the collector didn't select from a batch, which may
+// have been buffered before the handshake, and returns the schema version to send the batch with.
+func (t *RemoteTracer) filterBatch(buf []*pb.TraceEvent) ([]*pb.TraceEvent, *uint32) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	var schema *uint32
+	if t.schema != 0 {
+		v := t.schema
+		schema = &v
+	}
+
+	if t.mask == 0 {
+		return buf, schema
+	}
+	out := buf[:0]
+	for _, evt := range buf {
+		if t.traces(evt) {
+			out = append(out, evt)
 		}
 	}
+	return out, schema
 }
 
 func (t *RemoteTracer) openStream() (network.Stream, error) {
 	for {
 		ctx, cancel := context.WithTimeout(t.ctx, time.Minute)
-		s, err := t.host.NewStream(ctx, t.peer, RemoteTracerProtoID)
+		s, err := t.host.NewStream(ctx, t.peer, t.proto)
 		cancel()
 		if err != nil {
 			if t.ctx.Err() != nil {
@@ -301,3 +478,269 @@ func (t *RemoteTracer) openStream() (network.Stream, error) {
 }
 
 var _ EventTracer = (*RemoteTracer)(nil)
+
+// HTTPTraceEncoding selects the wire encoding of trace batches posted by the HTTPTracer.
+type HTTPTraceEncoding int
+
+const (
+	// HTTPTraceProtobuf encodes each batch as a single pb.TraceEventBatch protobuf.
+	HTTPTraceProtobuf HTTPTraceEncoding = iota
+	// HTTPTraceJSON encodes each batch as ndjson, one trace event per line.
+	HTTPTraceJSON
+)
+
+var (
+	// HTTPTracerMaxRetries is the default number of times a batch is retried after a transport
+	// error or a 5xx response before it is dropped.
+	HTTPTracerMaxRetries = 5
+	// HTTPTracerRetryBackoff is the default initial backoff between retries; it doubles with
+	// every attempt.
+	HTTPTracerRetryBackoff = time.Second
+	// HTTPTracerMaxRetryBackoff caps the backoff between retries.
+	HTTPTracerMaxRetryBackoff = time.Minute
+)
+
+// HTTPTracerOpt is an option for NewHTTPTracer.
+type HTTPTracerOpt func(*HTTPTracer) error
+
+// HTTPTracer is a tracer that POSTs gzipped batches of trace events to an HTTP endpoint.
+type HTTPTracer struct {
+	basicTracer
+	ctx      context.Context
+	endpoint string
+	client   *http.Client
+	encoding HTTPTraceEncoding
+	auth     func(*http.Request) error
+
+	maxRetries      int
+	retryBackoff    time.Duration
+	maxRetryBackoff time.Duration
+}
+
+// NewHTTPTracer constructs an HTTPTracer, tracing to the given endpoint URL.
+// By default batches are encoded as protobuf; use WithHTTPTracerEncoding to post ndjson instead.
+func NewHTTPTracer(ctx context.Context, endpoint string, opts ...HTTPTracerOpt) (*HTTPTracer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trace endpoint: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid trace endpoint: unsupported scheme %q", u.Scheme)
+	}
+
+	tr := &HTTPTracer{
+		ctx:             ctx,
+		endpoint:        endpoint,
+		client:          http.DefaultClient,
+		encoding:        HTTPTraceProtobuf,
+		maxRetries:      HTTPTracerMaxRetries,
+		retryBackoff:    HTTPTracerRetryBackoff,
+		maxRetryBackoff: HTTPTracerMaxRetryBackoff,
+		basicTracer:     basicTracer{ch: make(chan struct{}, 1), lossy: true},
+	}
+
+	for _, opt := range opts {
+		err := opt(tr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	go tr.doWrite()
+
+	return tr, nil
+}
+
+// WithHTTPTracerEncoding sets the encoding of posted trace batches.
+func WithHTTPTracerEncoding(enc HTTPTraceEncoding) HTTPTracerOpt {
+	return func(t *HTTPTracer) error {
+		switch enc {
+		case HTTPTraceProtobuf, HTTPTraceJSON:
+			t.encoding = enc
+			return nil
+		default:
+			return fmt.Errorf("unknown trace encoding: %d", enc)
+		}
+	}
+}
+
+// WithHTTPTracerClient sets the http client used to post trace batches; the default is
+// http.DefaultClient.
+func WithHTTPTracerClient(client *http.Client) HTTPTracerOpt {
+	return func(t *HTTPTracer) error {
+		if client == nil {
+			return fmt.Errorf("nil http client")
+		}
+		t.client = client
+		return nil
+	}
+}
+
+// WithHTTPTracerAuth sets a hook that is invoked on every request before it is sent; it can
+// be used to set authorization headers. If the hook returns an error, the batch is dropped.
+func WithHTTPTracerAuth(auth func(*http.Request) error) HTTPTracerOpt {
+	return func(t *HTTPTracer) error {
+		t.auth = auth
+		return nil
+	}
+}
+
+// WithHTTPTracerRetry sets the number of retries for a batch after a transport error or a 5xx
+// response, and the initial backoff between retries.
+func WithHTTPTracerRetry(maxRetries int, backoff time.Duration) HTTPTracerOpt {
+	return func(t *HTTPTracer) error {
+		if maxRetries < 0 {
+			return fmt.Errorf("number of retries must be >= 0")
+		}
+		if backoff <= 0 {
+			return fmt.Errorf("retry backoff must be positive")
+		}
+		t.maxRetries = maxRetries
+		t.retryBackoff = backoff
+		return nil
+	}
+}
+
+// WithHTTPTracerBufferSize bounds the number of trace events buffered while batches are
+// being posted; events are dropped once the buffer is full. The default is TraceBufferSize.
+func WithHTTPTracerBufferSize(n int) HTTPTracerOpt {
+	return func(t *HTTPTracer) error {
+		if n <= 0 {
+			return fmt.Errorf("trace buffer size must be positive")
+		}
+		t.bufSize = n
+		return nil
+	}
+}
+
+func (t *HTTPTracer) doWrite() {
+	var buf []*pb.TraceEvent
+
+	for {
+		var ok bool
+		buf, ok = t.nextBatch(buf)
+
+		if len(buf) > 0 {
+			body, err := t.encodeBatch(buf)
+			if err != nil {
+				log.Debugf("error encoding trace event batch: %s", err)
+			} else {
+				t.postBatch(body)
+			}
+		}
+
+		// nil out the buffer to gc consumed events
+		for i := range buf {
+			buf[i] = nil
+		}
+
+		if !ok {
+			return
+		}
+	}
+}
+
+func (t *HTTPTracer) encodeBatch(batch []*pb.TraceEvent) ([]byte, error) {
+	var out bytes.Buffer
+	gzipW := gzip.NewWriter(&out)
+
+	switch t.encoding {
+	case HTTPTraceJSON:
+		tw, err := traceutil.NewTraceWriter(gzipW, traceutil.FormatJSON)
+		if err != nil {
+			return nil, err
+		}
+		for _, evt := range batch {
+			err := tw.Write(evt)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+	default:
+		data, err := (&pb.TraceEventBatch{Batch: batch}).Marshal()
+		if err != nil {
+			return nil, err
+		}
+		_, err = gzipW.Write(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := gzipW.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+func (t *HTTPTracer) postBatch(body []byte) {
+	backoff := t.retryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := t.doPost(body)
+		if err == nil {
+			return
+		}
+
+		if !retry || attempt >= t.maxRetries {
+			log.Debugf("error posting trace event batch; dropping batch: %s", err)
+			return
+		}
+
+		log.Debugf("error posting trace event batch; retrying in %s: %s", backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-t.ctx.Done():
+			return
+		}
+
+		backoff *= 2
+		if backoff > t.maxRetryBackoff {
+			backoff = t.maxRetryBackoff
+		}
+	}
+}
+
+// doPost posts a single batch; it returns whether the request should be retried on error.
+func (t *HTTPTracer) doPost(body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(t.ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	switch t.encoding {
+	case HTTPTraceJSON:
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	default:
+		req.Header.Set("Content-Type", "application/x-protobuf")
+	}
+	req.Header.Set("Content-Encoding", "gzip")
+
+	if t.auth != nil {
+		err = t.auth(req)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return t.ctx.Err() == nil, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		return true, fmt.Errorf("trace endpoint returned %s", resp.Status)
+	case resp.StatusCode >= 300:
+		return false, fmt.Errorf("trace endpoint returned %s", resp.Status)
+	}
+
+	return false, nil
+}
+
+var _ EventTracer = (*HTTPTracer)(nil)
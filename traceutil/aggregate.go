@@ -0,0 +1,63 @@
+package traceutil
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+// ReadAll reads the remaining events of a trace.
+func ReadAll(r *TraceReader) ([]*pb.TraceEvent, error) {
+	var events []*pb.TraceEvent
+	for {
+		evt, err := r.Next()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, evt)
+	}
+}
+
+// EventsByType groups events by type, in trace order.
+func EventsByType(events []*pb.TraceEvent) map[pb.TraceEvent_Type][]*pb.TraceEvent {
+	byType := make(map[pb.TraceEvent_Type][]*pb.TraceEvent)
+	for _, evt := range events {
+		byType[evt.GetType()] = append(byType[evt.GetType()], evt)
+	}
+	return byType
+}
+
+// DeliveryLatencies returns the delivery latencies of the messages in each topic, in trace order:
+// the time from the publication of a message to each of its deliveries to another peer. The
+// events are typically merged from the traces of several peers; the deliveries of messages whose
+// publication is not traced are ignored.
+func DeliveryLatencies(events []*pb.TraceEvent) map[string][]time.Duration {
+	published := make(map[string]*pb.TraceEvent)
+	for _, evt := range events {
+		if evt.GetType() == pb.TraceEvent_PUBLISH_MESSAGE {
+			published[string(evt.GetPublishMessage().GetMessageID())] = evt
+		}
+	}
+
+	latencies := make(map[string][]time.Duration)
+	for _, evt := range events {
+		if evt.GetType() != pb.TraceEvent_DELIVER_MESSAGE {
+			continue
+		}
+		deliver := evt.GetDeliverMessage()
+		pub, ok := published[string(deliver.GetMessageID())]
+		if !ok || bytes.Equal(pub.GetPeerID(), evt.GetPeerID()) {
+			continue
+		}
+
+		topic := deliver.GetTopic()
+		latency := time.Duration(evt.GetTimestamp() - pub.GetTimestamp())
+		latencies[topic] = append(latencies[topic], latency)
+	}
+	return latencies
+}
@@ -0,0 +1,98 @@
+package traceutil
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-msgio/protoio"
+)
+
+// MaxTraceEventSize is the maximum size of an event read from a protobuf trace.
+var MaxTraceEventSize = 1 << 20
+
+// TraceReader iterates over the events of a trace. The format of the trace is detected when the
+// reader is created, and the trace may be gzipped.
+type TraceReader struct {
+	format Format
+	dec    *json.Decoder
+	pbr    protoio.Reader
+	closer io.Closer
+}
+
+// NewTraceReader returns a TraceReader reading the events of the trace in r.
+func NewTraceReader(r io.Reader) (*TraceReader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(gzr)
+		magic, err = br.Peek(2)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+
+	// a JSON event starts with its first field, while a protobuf event starts with its length;
+	// the type of the event is always its first field, so the length is followed by its tag.
+	if len(magic) == 2 && magic[0] == '{' && magic[1] == '"' {
+		return &TraceReader{format: FormatJSON, dec: json.NewDecoder(br)}, nil
+	}
+	return &TraceReader{format: FormatPB, pbr: protoio.NewDelimitedReader(br, MaxTraceEventSize)}, nil
+}
+
+// OpenTraceFile returns a TraceReader reading the events of a trace file; the reader must be
+// closed to close the file.
+func OpenTraceFile(path string) (*TraceReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := NewTraceReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.closer = f
+	return r, nil
+}
+
+// Format returns the format of the trace.
+func (r *TraceReader) Format() Format {
+	return r.format
+}
+
+// Next returns the next event of the trace, or io.EOF at the end of the trace.
+func (r *TraceReader) Next() (*pb.TraceEvent, error) {
+	evt := new(pb.TraceEvent)
+	var err error
+	if r.dec != nil {
+		err = r.dec.Decode(evt)
+	} else {
+		err = r.pbr.ReadMsg(evt)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return evt, nil
+}
+
+// Close closes the trace file, if the reader was opened with OpenTraceFile.
+func (r *TraceReader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
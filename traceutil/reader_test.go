@@ -0,0 +1,184 @@
+package traceutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// the golden traces were written by the JSONTracer and the PBTracer
+var goldenTraces = map[Format]string{
+	FormatJSON: "testdata/trace.json",
+	FormatPB:   "testdata/trace.pb",
+}
+
+func readGolden(t *testing.T, format Format) []*pb.TraceEvent {
+	t.Helper()
+
+	r, err := OpenTraceFile(goldenTraces[format])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if r.Format() != format {
+		t.Fatalf("expected a %s trace, got %s", format, r.Format())
+	}
+	events, err := ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestTraceReaderGolden(t *testing.T) {
+	jsonEvents := readGolden(t, FormatJSON)
+	pbEvents := readGolden(t, FormatPB)
+
+	if len(jsonEvents) != 10 || len(pbEvents) != len(jsonEvents) {
+		t.Fatalf("expected 10 events in both traces, got %d and %d", len(jsonEvents), len(pbEvents))
+	}
+	for i := range jsonEvents {
+		if !proto.Equal(jsonEvents[i], pbEvents[i]) {
+			t.Fatalf("expected the same events in both traces, got %v and %v", jsonEvents[i], pbEvents[i])
+		}
+	}
+
+	first := jsonEvents[0]
+	if first.GetType() != pb.TraceEvent_JOIN || string(first.GetPeerID()) != "alice" || first.GetJoin().GetTopic() != "blocks" {
+		t.Fatalf("unexpected first event %v", first)
+	}
+}
+
+func TestTraceWriterGolden(t *testing.T) {
+	for format, path := range goldenTraces {
+		golden, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		w, err := NewTraceWriter(&buf, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, evt := range readGolden(t, format) {
+			if err := w.Write(evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(buf.Bytes(), golden) {
+			t.Fatalf("expected the %s trace to be rewritten as is", format)
+		}
+	}
+
+	if _, err := NewTraceWriter(&bytes.Buffer{}, Format(42)); err == nil {
+		t.Fatal("expected an unknown format to be rejected")
+	}
+}
+
+func TestTraceReaderGzip(t *testing.T) {
+	for format, path := range goldenTraces {
+		golden, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		gzw := gzip.NewWriter(&buf)
+		gzw.Write(golden)
+		gzw.Close()
+
+		r, err := NewTraceReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Format() != format {
+			t.Fatalf("expected a gzipped %s trace, got %s", format, r.Format())
+		}
+		events, err := ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 10 {
+			t.Fatalf("expected 10 events, got %d", len(events))
+		}
+	}
+}
+
+func TestTraceReaderTruncated(t *testing.T) {
+	golden, err := os.ReadFile(goldenTraces[FormatPB])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewTraceReader(bytes.NewReader(golden[:len(golden)-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := ReadAll(r)
+	if err == nil {
+		t.Fatal("expected an error for the truncated event")
+	}
+	if len(events) != 9 {
+		t.Fatalf("expected the 9 complete events, got %d", len(events))
+	}
+
+	// an empty trace has no events
+	r, err = NewTraceReader(bytes.NewReader(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events, err := ReadAll(r); err != nil || len(events) != 0 {
+		t.Fatalf("expected no events, got %v: %v", events, err)
+	}
+}
+
+func TestAggregation(t *testing.T) {
+	events := readGolden(t, FormatPB)
+
+	byType := EventsByType(events)
+	counts := map[pb.TraceEvent_Type]int{
+		pb.TraceEvent_JOIN:              1,
+		pb.TraceEvent_GRAFT:             1,
+		pb.TraceEvent_PRUNE:             1,
+		pb.TraceEvent_PUBLISH_MESSAGE:   2,
+		pb.TraceEvent_DELIVER_MESSAGE:   3,
+		pb.TraceEvent_DUPLICATE_MESSAGE: 1,
+		pb.TraceEvent_REJECT_MESSAGE:    1,
+	}
+	if len(byType) != len(counts) {
+		t.Fatalf("expected %d event types, got %d", len(counts), len(byType))
+	}
+	for typ, count := range counts {
+		if len(byType[typ]) != count {
+			t.Fatalf("expected %d %s events, got %d", count, typ, len(byType[typ]))
+		}
+	}
+
+	latencies := DeliveryLatencies(events)
+	expected := map[string][]time.Duration{
+		"blocks": {15 * time.Millisecond, 30 * time.Millisecond},
+		"txs":    {5 * time.Millisecond},
+	}
+	if len(latencies) != len(expected) {
+		t.Fatalf("expected latencies in %d topics, got %v", len(expected), latencies)
+	}
+	for topic, want := range expected {
+		got := latencies[topic]
+		if len(got) != len(want) {
+			t.Fatalf("expected %v in %s, got %v", want, topic, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("expected %v in %s, got %v", want, topic, got)
+			}
+		}
+	}
+}
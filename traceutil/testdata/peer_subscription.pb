@@ -0,0 +1,4 @@
+#alice
+bobblocks"alice
+caroltxs#alice
+bobblocks
\ No newline at end of file
@@ -0,0 +1,62 @@
+// Package traceutil reads and writes the event traces of the file tracers of pubsub, and
+// aggregates the traced events for analysis.
+package traceutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-msgio/protoio"
+)
+
+// Format is the encoding of a trace.
+type Format int
+
+const (
+	// FormatPB encodes a trace as varint-delimited pb.TraceEvent protobufs, as written by the
+	// PBTracer.
+	FormatPB Format = iota
+	// FormatJSON encodes a trace as ndjson, one pb.TraceEvent per line, as written by the
+	// JSONTracer.
+	FormatJSON
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatPB:
+		return "pb"
+	case FormatJSON:
+		return "json"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// TraceWriter writes trace events to an underlying writer in a trace format.
+type TraceWriter struct {
+	enc *json.Encoder
+	pbw protoio.Writer
+}
+
+// NewTraceWriter returns a TraceWriter writing events to w in the given format.
+func NewTraceWriter(w io.Writer, format Format) (*TraceWriter, error) {
+	switch format {
+	case FormatPB:
+		return &TraceWriter{pbw: protoio.NewDelimitedWriter(w)}, nil
+	case FormatJSON:
+		return &TraceWriter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown trace format: %d", format)
+	}
+}
+
+// Write writes an event to the trace.
+func (w *TraceWriter) Write(evt *pb.TraceEvent) error {
+	if w.enc != nil {
+		return w.enc.Encode(evt)
+	}
+	return w.pbw.WriteMsg(evt)
+}
@@ -0,0 +1,260 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	ma "github.com/multiformats/go-multiaddr"
+	msmux "github.com/multiformats/go-multistream"
+)
+
+// Transport is the network layer PubSub runs on: it carries the RPC streams between peers and
+// tells us about new connections.
+// The default transport is backed by a libp2p host; other implementations allow PubSub to be driven
+// without one, e.g. in simulations and tests (see the pubsubtest package).
+type Transport interface {
+	// ID returns the ID of the local peer.
+	ID() peer.ID
+	// PrivKey returns the private key of a local identity, or nil if there is none.
+	PrivKey(p peer.ID) crypto.PrivKey
+
+	// SetStreamHandler registers the handler for inbound streams of a protocol; if match is not
+	// nil, it is used to match the protocols of inbound streams instead.
+	SetStreamHandler(proto protocol.ID, match func(protocol.ID) bool, handler func(TransportStream))
+	// NewStream opens an outbound stream to a peer, using the first protocol it supports.
+	NewStream(ctx context.Context, p peer.ID, protos ...protocol.ID) (TransportStream, error)
+
+	// Notify registers a function to be called when a connection to a peer is established.
+	Notify(connected func(peer.ID))
+	// Peers returns the peers we are currently connected to.
+	Peers() []peer.ID
+	// Connected returns true if we are connected to a peer.
+	Connected(p peer.ID) bool
+	// Outbound returns true if we have an outbound connection to a peer carrying a stream of
+	// the given protocol.
+	Outbound(p peer.ID, proto protocol.ID) bool
+	// Connect connects to a peer.
+	Connect(ctx context.Context, pi peer.AddrInfo) error
+}
+
+// TransportStream is a bidirectional stream carrying length-prefixed RPCs to or from a peer.
+type TransportStream interface {
+	io.ReadWriteCloser
+
+	// Reset closes both ends of the stream, signalling an error.
+	Reset() error
+	// RemotePeer returns the peer at the other end of the stream.
+	RemotePeer() peer.ID
+	// Protocol returns the protocol negotiated for the stream.
+	Protocol() protocol.ID
+}
+
+// ConnInfo describes one of the connections to a peer.
+type ConnInfo struct {
+	// ID identifies the connection among the connections to the peer.
+	ID string
+	// Relayed is true if the connection goes through a relay.
+	Relayed bool
+	// Transient is true if the connection is limited, e.g. a relayed connection with a data or
+	// time limit; we don't open streams on transient connections.
+	Transient bool
+	// Latency is the round trip time of the connection, or 0 if it is unknown.
+	Latency time.Duration
+}
+
+// ConnTransport is implemented by transports that may be connected to a peer over several
+// connections, e.g. a relayed one and a direct one. It allows PubSub to move the outbound stream
+// of a peer to a better connection when one appears; see WithConnPreference.
+type ConnTransport interface {
+	Transport
+
+	// Conns returns the connections to a peer, including the transient ones.
+	Conns(p peer.ID) []ConnInfo
+	// StreamConn returns the connection carrying a stream.
+	StreamConn(s TransportStream) (ConnInfo, bool)
+	// NewStreamOnConn opens an outbound stream to a peer on one of its connections, using the first
+	// protocol it supports.
+	NewStreamOnConn(ctx context.Context, p peer.ID, conn string, protos ...protocol.ID) (TransportStream, error)
+}
+
+// hostTransport is the Transport backed by a libp2p host.
+type hostTransport struct {
+	h host.Host
+}
+
+var _ ConnTransport = (*hostTransport)(nil)
+
+func newHostTransport(h host.Host) *hostTransport {
+	return &hostTransport{h: h}
+}
+
+func (t *hostTransport) ID() peer.ID {
+	return t.h.ID()
+}
+
+func (t *hostTransport) PrivKey(p peer.ID) crypto.PrivKey {
+	return t.h.Peerstore().PrivKey(p)
+}
+
+func (t *hostTransport) SetStreamHandler(proto protocol.ID, match func(protocol.ID) bool, handler func(TransportStream)) {
+	h := func(s network.Stream) {
+		handler(hostStream{s})
+	}
+
+	if match != nil {
+		t.h.SetStreamHandlerMatch(proto, match, h)
+	} else {
+		t.h.SetStreamHandler(proto, h)
+	}
+}
+
+func (t *hostTransport) NewStream(ctx context.Context, p peer.ID, protos ...protocol.ID) (TransportStream, error) {
+	s, err := t.h.NewStream(ctx, p, protos...)
+	if err != nil {
+		return nil, err
+	}
+
+	return hostStream{s}, nil
+}
+
+func (t *hostTransport) Notify(connected func(peer.ID)) {
+	t.h.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(_ network.Network, c network.Conn) {
+			// ignore transient connections
+			if c.Stat().Transient {
+				return
+			}
+
+			connected(c.RemotePeer())
+		},
+	})
+}
+
+func (t *hostTransport) Peers() []peer.ID {
+	isTransient := func(pid peer.ID) bool {
+		for _, c := range t.h.Network().ConnsToPeer(pid) {
+			if !c.Stat().Transient {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	var peers []peer.ID
+	for _, pid := range t.h.Network().Peers() {
+		if isTransient(pid) {
+			continue
+		}
+
+		peers = append(peers, pid)
+	}
+
+	return peers
+}
+
+func (t *hostTransport) Connected(p peer.ID) bool {
+	return t.h.Network().Connectedness(p) == network.Connected
+}
+
+func (t *hostTransport) Outbound(p peer.ID, proto protocol.ID) bool {
+	for _, c := range t.h.Network().ConnsToPeer(p) {
+		stat := c.Stat()
+
+		if stat.Transient {
+			continue
+		}
+
+		if stat.Direction == network.DirOutbound {
+			// only count the connection if it has a pubsub stream
+			for _, s := range c.GetStreams() {
+				if s.Protocol() == proto {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func (t *hostTransport) Connect(ctx context.Context, pi peer.AddrInfo) error {
+	return t.h.Connect(ctx, pi)
+}
+
+func (t *hostTransport) Conns(p peer.ID) []ConnInfo {
+	var conns []ConnInfo
+	for _, c := range t.h.Network().ConnsToPeer(p) {
+		conns = append(conns, connInfo(c))
+	}
+
+	return conns
+}
+
+func (t *hostTransport) StreamConn(s TransportStream) (ConnInfo, bool) {
+	hs, ok := s.(hostStream)
+	if !ok {
+		return ConnInfo{}, false
+	}
+
+	return connInfo(hs.Conn()), true
+}
+
+func (t *hostTransport) NewStreamOnConn(ctx context.Context, p peer.ID, conn string, protos ...protocol.ID) (TransportStream, error) {
+	var c network.Conn
+	for _, pc := range t.h.Network().ConnsToPeer(p) {
+		if pc.ID() == conn {
+			c = pc
+			break
+		}
+	}
+	if c == nil {
+		return nil, fmt.Errorf("no connection %s to %s", conn, p)
+	}
+
+	s, err := c.NewStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the negotiation is done here, as the host only opens streams on the connection of its choice
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+	proto, err := msmux.SelectOneOf(protos, s)
+	if err != nil {
+		s.Reset()
+		return nil, err
+	}
+	s.SetDeadline(time.Time{})
+
+	if err := s.SetProtocol(proto); err != nil {
+		s.Reset()
+		return nil, err
+	}
+
+	return hostStream{s}, nil
+}
+
+// connInfo describes a libp2p connection; libp2p doesn't track the latency of connections.
+func connInfo(c network.Conn) ConnInfo {
+	_, err := c.RemoteMultiaddr().ValueForProtocol(ma.P_CIRCUIT)
+	return ConnInfo{ID: c.ID(), Relayed: err == nil, Transient: c.Stat().Transient}
+}
+
+// hostStream adapts a libp2p stream to a TransportStream.
+type hostStream struct {
+	network.Stream
+}
+
+func (s hostStream) RemotePeer() peer.ID {
+	return s.Conn().RemotePeer()
+}
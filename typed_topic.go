@@ -0,0 +1,214 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// Codec encodes the values published in a typed topic into message payloads, and decodes them
+// back; see NewTypedTopic.
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// JSONCodec encodes values as JSON.
+type JSONCodec[T any] struct{}
+
+var _ Codec[struct{}] = JSONCodec[struct{}]{}
+
+func (JSONCodec[T]) Encode(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// ProtoCodec encodes protobuf messages in their wire format; T is the message type, and PT the
+// pointer to it. It is created with NewProtoCodec.
+type ProtoCodec[T any, PT interface {
+	*T
+	proto.Message
+}] struct{}
+
+// NewProtoCodec returns a codec for the protobuf messages of type T, eg NewProtoCodec[pb.Message]()
+// for a Codec[*pb.Message].
+func NewProtoCodec[T any, PT interface {
+	*T
+	proto.Message
+}]() ProtoCodec[T, PT] {
+	return ProtoCodec[T, PT]{}
+}
+
+func (ProtoCodec[T, PT]) Encode(v PT) ([]byte, error) {
+	return proto.Marshal(v)
+}
+
+func (ProtoCodec[T, PT]) Decode(data []byte) (PT, error) {
+	v := PT(new(T))
+	if err := proto.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// DecodeError is returned by TypedSubscription.Next for a message whose payload could not be
+// decoded.
+type DecodeError struct {
+	Msg *Message
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("cannot decode message %s from %s in topic %s: %s", e.Msg.ID, e.Msg.ReceivedFrom, e.Msg.GetTopic(), e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// TypedTopic wraps a Topic to publish and receive values of type T, encoded in the payload of the
+// messages with a codec. The topic remains usable as is, so raw publishers and subscribers can
+// share it with typed ones, as long as they agree on the encoding.
+type TypedTopic[T any] struct {
+	topic *Topic
+	codec Codec[T]
+}
+
+// NewTypedTopic wraps a topic handle with a codec for values of type T.
+func NewTypedTopic[T any](t *Topic, codec Codec[T]) *TypedTopic[T] {
+	return &TypedTopic[T]{topic: t, codec: codec}
+}
+
+// Topic returns the wrapped topic handle.
+func (tt *TypedTopic[T]) Topic() *Topic {
+	return tt.topic
+}
+
+// Publish encodes v and publishes it in the topic.
+func (tt *TypedTopic[T]) Publish(ctx context.Context, v T, opts ...PubOpt) error {
+	data, err := tt.codec.Encode(v)
+	if err != nil {
+		return fmt.Errorf("cannot encode value: %w", err)
+	}
+
+	return tt.topic.Publish(ctx, data, opts...)
+}
+
+type typedSubOptions struct {
+	subOpts  []SubOpt
+	onDecode func(msg *Message, err error)
+}
+
+// TypedSubOpt is an option of TypedTopic.Subscribe.
+type TypedSubOpt func(*typedSubOptions) error
+
+// WithSubOpts passes options to the subscription to the wrapped topic.
+func WithSubOpts(opts ...SubOpt) TypedSubOpt {
+	return func(o *typedSubOptions) error {
+		o.subOpts = append(o.subOpts, opts...)
+		return nil
+	}
+}
+
+// WithSkipDecodeErrors skips the messages that fail to decode, invoking handler with each of them
+// and the decoding error, instead of returning a DecodeError from Next. The handler is invoked
+// from Next, and may be nil.
+func WithSkipDecodeErrors(handler func(msg *Message, err error)) TypedSubOpt {
+	return func(o *typedSubOptions) error {
+		if handler == nil {
+			handler = func(*Message, error) {}
+		}
+		o.onDecode = handler
+		return nil
+	}
+}
+
+// TypedSubscription is a subscription to a typed topic, decoding the payload of the messages.
+type TypedSubscription[T any] struct {
+	sub      *Subscription
+	codec    Codec[T]
+	onDecode func(msg *Message, err error)
+
+	once sync.Once
+	done chan struct{}
+}
+
+// Subscribe subscribes to the topic, until the subscription is cancelled or ctx is done. By
+// default, Next returns a DecodeError for the messages that fail to decode; see
+// WithSkipDecodeErrors.
+func (tt *TypedTopic[T]) Subscribe(ctx context.Context, opts ...TypedSubOpt) (*TypedSubscription[T], error) {
+	var o typedSubOptions
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	sub, err := tt.topic.Subscribe(o.subOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := &TypedSubscription[T]{
+		sub:      sub,
+		codec:    tt.codec,
+		onDecode: o.onDecode,
+		done:     make(chan struct{}),
+	}
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				ts.Cancel()
+			case <-ts.done:
+			case <-sub.ctx.Done():
+			}
+		}()
+	}
+
+	return ts, nil
+}
+
+// Subscription returns the wrapped subscription.
+func (ts *TypedSubscription[T]) Subscription() *Subscription {
+	return ts.sub
+}
+
+// Next returns the next value in the subscription, along with the message carrying it. If the
+// payload of the message could not be decoded, it returns the message with a DecodeError, unless
+// decoding errors are skipped; the subscription carries on with the next call.
+func (ts *TypedSubscription[T]) Next(ctx context.Context) (T, *Message, error) {
+	var zero T
+	for {
+		msg, err := ts.sub.Next(ctx)
+		if err != nil {
+			return zero, msg, err
+		}
+
+		v, err := ts.codec.Decode(msg.GetData())
+		if err == nil {
+			return v, msg, nil
+		}
+
+		if ts.onDecode == nil {
+			return zero, msg, &DecodeError{Msg: msg, Err: err}
+		}
+		ts.onDecode(msg, err)
+	}
+}
+
+// Cancel cancels the wrapped subscription.
+func (ts *TypedSubscription[T]) Cancel() {
+	ts.once.Do(func() {
+		close(ts.done)
+		ts.sub.Cancel()
+	})
+}
@@ -0,0 +1,214 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+type chatMessage struct {
+	Author string `json:"author"`
+	Text   string `json:"text"`
+}
+
+func getTypedTopics(t *testing.T, ctx context.Context, topic string) ([]*PubSub, []*Topic) {
+	t.Helper()
+
+	hosts := getNetHosts(t, ctx, 2)
+	psubs := getGossipsubs(ctx, hosts)
+	var topics []*Topic
+	for _, ps := range psubs {
+		tp, err := ps.Join(topic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, tp)
+	}
+	return psubs, topics
+}
+
+func TestTypedTopicJSON(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	psubs, topics := getTypedTopics(t, ctx, "chat")
+	typed := []*TypedTopic[chatMessage]{
+		NewTypedTopic[chatMessage](topics[0], JSONCodec[chatMessage]{}),
+		NewTypedTopic[chatMessage](topics[1], JSONCodec[chatMessage]{}),
+	}
+
+	tsub, err := typed[1].Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a raw subscriber shares the topic with the typed one
+	rsub, err := topics[1].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, psubs[0].host, psubs[1].host)
+	time.Sleep(time.Second)
+
+	// typed publication, received by both subscribers
+	sent := chatMessage{Author: "alice", Text: "hello"}
+	if err := typed[0].Publish(ctx, sent); err != nil {
+		t.Fatal(err)
+	}
+	got, msg, err := tsub.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != sent || msg.GetFrom() != psubs[0].host.ID() {
+		t.Fatalf("expected %v from %s, got %v from %s", sent, psubs[0].host.ID(), got, msg.GetFrom())
+	}
+	raw, err := rsub.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := json.Marshal(sent)
+	if !bytes.Equal(raw.GetData(), encoded) {
+		t.Fatalf("expected the raw subscriber to receive %s, got %s", encoded, raw.GetData())
+	}
+
+	// raw publication, decoded by the typed subscriber
+	sent = chatMessage{Author: "bob", Text: "hi"}
+	encoded, _ = json.Marshal(sent)
+	if err := topics[0].Publish(ctx, encoded); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err = tsub.Next(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got != sent {
+		t.Fatalf("expected %v, got %v", sent, got)
+	}
+
+	// values that can't be encoded are not published
+	ftopic := NewTypedTopic[float64](topics[0], JSONCodec[float64]{})
+	if err := ftopic.Publish(ctx, math.NaN()); err == nil {
+		t.Fatal("expected an encoding error")
+	}
+}
+
+func TestTypedTopicProto(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	psubs, topics := getTypedTopics(t, ctx, "proto")
+	codec := NewProtoCodec[pb.ControlIHave]()
+	pubTopic := NewTypedTopic[*pb.ControlIHave](topics[0], codec)
+	subTopic := NewTypedTopic[*pb.ControlIHave](topics[1], codec)
+
+	tsub, err := subTopic.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, psubs[0].host, psubs[1].host)
+	time.Sleep(time.Second)
+
+	name := "foobar"
+	sent := &pb.ControlIHave{TopicID: &name, MessageIDs: []string{"a", "b"}}
+	if err := pubTopic.Publish(ctx, sent); err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := tsub.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetTopicID() != name || len(got.GetMessageIDs()) != 2 || got.GetMessageIDs()[1] != "b" {
+		t.Fatalf("expected %v, got %v", sent, got)
+	}
+}
+
+func TestTypedTopicDecodeErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	psubs, topics := getTypedTopics(t, ctx, "chat")
+	typed := NewTypedTopic[chatMessage](topics[1], JSONCodec[chatMessage]{})
+
+	// the decoding errors are returned by default
+	tsub, err := typed.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// or skipped, with a callback
+	var skipped []*Message
+	ssub, err := typed.Subscribe(ctx, WithSkipDecodeErrors(func(msg *Message, err error) {
+		skipped = append(skipped, msg)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, psubs[0].host, psubs[1].host)
+	time.Sleep(time.Second)
+
+	if err := topics[0].Publish(ctx, []byte("garbage")); err != nil {
+		t.Fatal(err)
+	}
+	sent := chatMessage{Author: "alice", Text: "hello"}
+	encoded, _ := json.Marshal(sent)
+	if err := topics[0].Publish(ctx, encoded); err != nil {
+		t.Fatal(err)
+	}
+
+	_, msg, err := tsub.Next(ctx)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Msg != msg || string(msg.GetData()) != "garbage" {
+		t.Fatalf("expected a decoding error for the garbage, got %v", err)
+	}
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("expected the decoding error to wrap the JSON error, got %v", err)
+	}
+	// the subscription carries on after the error
+	got, _, err := tsub.Next(ctx)
+	if err != nil || got != sent {
+		t.Fatalf("expected %v after the decoding error, got %v: %v", sent, got, err)
+	}
+
+	got, _, err = ssub.Next(ctx)
+	if err != nil || got != sent {
+		t.Fatalf("expected %v after skipping the garbage, got %v: %v", sent, got, err)
+	}
+	if len(skipped) != 1 || string(skipped[0].GetData()) != "garbage" {
+		t.Fatalf("expected the garbage to be skipped, got %v", skipped)
+	}
+}
+
+func TestTypedSubscriptionContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getGossipsub(ctx, hosts[0])
+	topic, err := ps.Join("chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	typed := NewTypedTopic[chatMessage](topic, JSONCodec[chatMessage]{})
+
+	sctx, scancel := context.WithCancel(ctx)
+	tsub, err := typed.Subscribe(sctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scancel()
+
+	nctx, ncancel := context.WithTimeout(ctx, time.Second)
+	defer ncancel()
+	if _, _, err := tsub.Next(nctx); err != ErrSubscriptionCancelled {
+		t.Fatalf("expected the subscription to be cancelled with its context, got %v", err)
+	}
+	// cancelling again is harmless
+	tsub.Cancel()
+	if err := topic.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
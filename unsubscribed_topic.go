@@ -0,0 +1,172 @@
+package pubsub
+
+import (
+	"fmt"
+	"time"
+)
+
+// UnsubscribedTopicMaxCounters is the maximum number of topics for which messages in topics we are
+// not subscribed to are counted individually; messages in other topics are counted together.
+var UnsubscribedTopicMaxCounters = 1024
+
+// UnsubscribedTopicCallbackRate and UnsubscribedTopicCallbackBurst limit the rate at which messages
+// in topics we are not subscribed to are handed to the callback of UnsubscribedTopicCallback, in
+// messages per second; messages over the limit are dropped.
+var (
+	UnsubscribedTopicCallbackRate  = 10.0
+	UnsubscribedTopicCallbackBurst = 10
+)
+
+type unsubscribedTopicAction int
+
+const (
+	unsubscribedTopicDrop unsubscribedTopicAction = iota
+	unsubscribedTopicCount
+	unsubscribedTopicCallback
+)
+
+// UnsubscribedTopicPolicy is the policy for the messages we receive in topics we are neither
+// subscribed to nor relaying. Whatever the policy, such messages are never validated, forwarded,
+// marked as seen or cached by the router.
+type UnsubscribedTopicPolicy struct {
+	action unsubscribedTopicAction
+	fn     func(*Message)
+}
+
+var (
+	// UnsubscribedTopicDrop silently drops the messages; this is the default policy.
+	UnsubscribedTopicDrop = UnsubscribedTopicPolicy{action: unsubscribedTopicDrop}
+	// UnsubscribedTopicCount drops the messages, counting them by topic; see
+	// UnsubscribedTopicStats.
+	UnsubscribedTopicCount = UnsubscribedTopicPolicy{action: unsubscribedTopicCount}
+)
+
+// UnsubscribedTopicCallback counts the messages like UnsubscribedTopicCount, and hands them to fn
+// as received, without validation, at a rate limited by UnsubscribedTopicCallbackRate and
+// UnsubscribedTopicCallbackBurst. The callback is invoked in its own goroutine and must not
+// modify the message.
+func UnsubscribedTopicCallback(fn func(*Message)) UnsubscribedTopicPolicy {
+	return UnsubscribedTopicPolicy{action: unsubscribedTopicCallback, fn: fn}
+}
+
+// WithUnsubscribedTopicPolicy sets the policy for the messages we receive in topics we are neither
+// subscribed to nor relaying.
+func WithUnsubscribedTopicPolicy(policy UnsubscribedTopicPolicy) Option {
+	return func(ps *PubSub) error {
+		if policy.action == unsubscribedTopicCallback && policy.fn == nil {
+			return fmt.Errorf("unsubscribed topic callback must not be nil")
+		}
+
+		if policy.action == unsubscribedTopicDrop {
+			ps.unsubscribed = nil
+			return nil
+		}
+
+		ps.unsubscribed = &unsubscribedTopics{
+			policy: policy,
+			counts: make(map[string]uint64),
+			bucket: tokenBucket{tokens: float64(UnsubscribedTopicCallbackBurst), last: time.Now()},
+		}
+		return nil
+	}
+}
+
+// UnsubscribedTopicStats are the counts of the messages received in topics we are not subscribed
+// to, with the UnsubscribedTopicCount or UnsubscribedTopicCallback policies.
+type UnsubscribedTopicStats struct {
+	// Topics are the counts by topic.
+	Topics map[string]uint64
+	// Other is the count of the messages in topics that are not counted individually, once
+	// UnsubscribedTopicMaxCounters topics are.
+	Other uint64
+	// Dropped is the number of messages that were not handed to the callback, because of the
+	// rate limit.
+	Dropped uint64
+}
+
+// UnsubscribedTopicStats returns the counts of the messages received in topics we are not
+// subscribed to. It returns false if the messages are not counted, as with the default policy.
+func (p *PubSub) UnsubscribedTopicStats() (UnsubscribedTopicStats, bool) {
+	type result struct {
+		stats UnsubscribedTopicStats
+		ok    bool
+	}
+
+	out := make(chan result, 1)
+	select {
+	case p.eval <- func() {
+		u := p.unsubscribed
+		if u == nil {
+			out <- result{}
+			return
+		}
+
+		stats := UnsubscribedTopicStats{
+			Topics:  make(map[string]uint64, len(u.counts)),
+			Other:   u.other,
+			Dropped: u.dropped,
+		}
+		for topic, n := range u.counts {
+			stats.Topics[topic] = n
+		}
+		out <- result{stats, true}
+	}:
+		res := <-out
+		return res.stats, res.ok
+	case <-p.ctx.Done():
+		return UnsubscribedTopicStats{}, false
+	}
+}
+
+// unsubscribedTopics applies the policy for messages in topics we are not subscribed to; it is
+// only accessed from the event loop.
+type unsubscribedTopics struct {
+	policy UnsubscribedTopicPolicy
+
+	counts  map[string]uint64
+	other   uint64
+	dropped uint64
+
+	// rate limit for the callback
+	bucket tokenBucket
+}
+
+// handleUnsubscribed applies the policy to a message received in a topic we are not subscribed to;
+// it is only called if a policy other than UnsubscribedTopicDrop is set.
+func (p *PubSub) handleUnsubscribed(msg *Message) {
+	u := p.unsubscribed
+	topic := msg.GetTopic()
+	if _, ok := u.counts[topic]; ok || len(u.counts) < UnsubscribedTopicMaxCounters {
+		u.counts[topic]++
+	} else {
+		u.other++
+	}
+
+	if u.policy.action != unsubscribedTopicCallback {
+		return
+	}
+
+	if !u.allow(time.Now()) {
+		u.dropped++
+		return
+	}
+	// the message is handed to the application, which may retain it
+	msg.detachData()
+	go u.policy.fn(msg)
+}
+
+// allow takes a token from the bucket of the callback, if there is one left.
+func (u *unsubscribedTopics) allow(now time.Time) bool {
+	b := &u.bucket
+	b.tokens += now.Sub(b.last).Seconds() * UnsubscribedTopicCallbackRate
+	if burst := float64(UnsubscribedTopicCallbackBurst); b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
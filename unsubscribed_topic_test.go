@@ -0,0 +1,123 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+)
+
+func TestUnsubscribedTopicPolicy(t *testing.T) {
+	const numMsgs = 20
+
+	var mx sync.Mutex
+	var received []*Message
+	callback := UnsubscribedTopicCallback(func(msg *Message) {
+		mx.Lock()
+		defer mx.Unlock()
+		received = append(received, msg)
+	})
+
+	for _, tc := range []struct {
+		name   string
+		policy UnsubscribedTopicPolicy
+	}{
+		{"drop", UnsubscribedTopicDrop},
+		{"count", UnsubscribedTopicCount},
+		{"callback", callback},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			hosts := getNetHosts(t, ctx, 2)
+			ps := getGossipsub(ctx, hosts[0], WithUnsubscribedTopicPolicy(tc.policy))
+
+			// we subscribe to a topic, but the attacker sends messages in another one
+			sub, err := ps.Subscribe("foobar")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var msgs []*pb.Message
+			for i := 0; i < numMsgs; i++ {
+				topic := "unjoined"
+				msgs = append(msgs, &pb.Message{
+					From:  []byte(hosts[1].ID()),
+					Seqno: []byte(fmt.Sprintf("%d", i)),
+					Data:  []byte("hello"),
+					Topic: &topic,
+				})
+			}
+
+			var once sync.Once
+			newMockGS(ctx, t, hosts[1], func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+				once.Do(func() {
+					writeMsg(&pb.RPC{Publish: msgs})
+				})
+			})
+
+			connect(t, hosts[0], hosts[1])
+			time.Sleep(time.Second)
+
+			rctx, rcancel := context.WithTimeout(ctx, 100*time.Millisecond)
+			defer rcancel()
+			if _, err := sub.Next(rctx); err == nil {
+				t.Fatal("received a message in another topic")
+			}
+
+			// the messages must not be seen or cached
+			res := make(chan bool, 1)
+			ps.eval <- func() {
+				gs := ps.rt.(*GossipSubRouter)
+				for _, msg := range msgs {
+					id := DefaultMsgIdFn(msg)
+					if _, ok := gs.mcache.Get(id); ok || ps.seenMessage(id) {
+						res <- false
+						return
+					}
+				}
+				res <- true
+			}
+			if !<-res {
+				t.Fatal("a message in a topic we are not subscribed to was seen or cached")
+			}
+
+			stats, ok := ps.UnsubscribedTopicStats()
+			switch tc.name {
+			case "drop":
+				if ok {
+					t.Fatalf("expected no stats for the drop policy, got %+v", stats)
+				}
+				return
+			default:
+				if !ok || stats.Topics["unjoined"] != numMsgs || stats.Other != 0 {
+					t.Fatalf("expected %d messages counted, got %+v", numMsgs, stats)
+				}
+			}
+
+			if tc.name != "callback" {
+				return
+			}
+
+			// the callback is rate limited
+			mx.Lock()
+			defer mx.Unlock()
+			n := len(received)
+			if n < UnsubscribedTopicCallbackBurst || n >= numMsgs {
+				t.Fatalf("expected the callback to be rate limited, got %d messages", n)
+			}
+			if stats.Dropped != uint64(numMsgs-n) {
+				t.Fatalf("expected %d dropped messages, got %d", numMsgs-n, stats.Dropped)
+			}
+			for _, msg := range received {
+				if msg.ReceivedFrom != hosts[1].ID() || msg.GetTopic() != "unjoined" {
+					t.Fatalf("unexpected message %+v", msg)
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package pubsub
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"runtime"
 	"sync"
@@ -26,6 +27,10 @@ func (e ValidationError) Error() string {
 	return e.Reason
 }
 
+// errValidationPending is returned by validate when the message has been handed to the async or
+// batch validators, which complete its validation.
+var errValidationPending = errors.New("validation pending")
+
 // Validator is a function that validates a message with a binary decision: accept or reject.
 type Validator func(context.Context, peer.ID, *Message) bool
 
@@ -71,6 +76,8 @@ type validation struct {
 	mx sync.Mutex
 	// topicVals tracks per topic validators
 	topicVals map[string]*validatorImpl
+	// builtinVals tracks the built-in validators of the topics, which run before the others
+	builtinVals map[string][]*validatorImpl
 
 	// defaultVals tracks default validators applicable to all topics
 	defaultVals []*validatorImpl
@@ -83,13 +90,38 @@ type validation struct {
 
 	// this is the number of synchronous validation workers
 	validateWorkers int
+
+	// sigWorkers is the number of signature verification workers; with none, the signatures are
+	// verified by the reader goroutine of the peer
+	sigWorkers int
+	// sigQ is the front-end to the signature verification workers
+	sigQ chan *sigCheck
+
+	// inflightMx protects inflight
+	inflightMx sync.Mutex
+	// inflight tracks the messages being validated, with copies received in the meantime;
+	// messages are only marked as seen once their validation has an outcome.
+	inflight map[string][]*Message
 }
 
+// maxCoalescedCopies is the number of copies of a message being validated that are kept, to be
+// validated in turn if the validation is throttled.
+const maxCoalescedCopies = 4
+
 // validation requests
 type validateReq struct {
 	vals []*validatorImpl
 	src  peer.ID
 	msg  *Message
+	// whether we accepted messages in the topic when the message was queued
+	accepted bool
+}
+
+// signature verification requests
+type sigCheck struct {
+	msg    *Message
+	result *uint8
+	done   chan struct{}
 }
 
 // representation of topic validators
@@ -99,6 +131,8 @@ type validatorImpl struct {
 	validateTimeout  time.Duration
 	validateThrottle chan struct{}
 	validateInline   bool
+	// batch accumulates the messages for batch validators
+	batch *batchValidator
 }
 
 // async request to add a topic validators
@@ -109,6 +143,9 @@ type addValReq struct {
 	throttle int
 	inline   bool
 	resp     chan error
+
+	batchSize int
+	batchWait time.Duration
 }
 
 // async request to remove a topic validator
@@ -121,9 +158,11 @@ type rmValReq struct {
 func newValidation() *validation {
 	return &validation{
 		topicVals:        make(map[string]*validatorImpl),
+		builtinVals:      make(map[string][]*validatorImpl),
 		validateQ:        make(chan *validateReq, defaultValidateQueueSize),
 		validateThrottle: make(chan struct{}, defaultValidateThrottle),
 		validateWorkers:  runtime.NumCPU(),
+		inflight:         make(map[string][]*Message),
 	}
 }
 
@@ -135,6 +174,12 @@ func (v *validation) Start(p *PubSub) {
 	for i := 0; i < v.validateWorkers; i++ {
 		go v.validateWorker()
 	}
+	if v.sigWorkers > 0 {
+		v.sigQ = make(chan *sigCheck, v.sigWorkers)
+		for i := 0; i < v.sigWorkers; i++ {
+			go v.sigWorker()
+		}
+	}
 }
 
 // AddValidator adds a new validator
@@ -171,7 +216,13 @@ func (v *validation) makeValidator(req *addValReq) (*validatorImpl, error) {
 		}
 	}
 
+	topic := req.topic
+	if req.topic == "" {
+		topic = "(default)"
+	}
+
 	var validator ValidatorEx
+	var batch BatchValidator
 	switch v := req.validate.(type) {
 	case func(ctx context.Context, p peer.ID, msg *Message) bool:
 		validator = makeValidatorEx(Validator(v))
@@ -183,12 +234,25 @@ func (v *validation) makeValidator(req *addValReq) (*validatorImpl, error) {
 	case ValidatorEx:
 		validator = v
 
+	case func(ctx context.Context, msgs []*Message) []ValidationResult:
+		batch = BatchValidator(v)
+	case BatchValidator:
+		batch = v
+
 	default:
-		topic := req.topic
-		if req.topic == "" {
-			topic = "(default)"
+		return nil, fmt.Errorf("unknown validator type for topic %s; must be an instance of Validator, ValidatorEx or BatchValidator", topic)
+	}
+
+	if batch != nil {
+		if req.batchSize == 0 {
+			return nil, fmt.Errorf("batch validator for topic %s must be registered WithBatchValidation", topic)
 		}
-		return nil, fmt.Errorf("unknown validator type for topic %s; must be an instance of Validator or ValidatorEx", topic)
+		if req.inline {
+			return nil, fmt.Errorf("batch validator for topic %s can't be inline", topic)
+		}
+		validator = batch.single()
+	} else if req.batchSize > 0 {
+		return nil, fmt.Errorf("WithBatchValidation requires a batch validator for topic %s", topic)
 	}
 
 	val := &validatorImpl{
@@ -207,9 +271,30 @@ func (v *validation) makeValidator(req *addValReq) (*validatorImpl, error) {
 		val.validateThrottle = make(chan struct{}, req.throttle)
 	}
 
+	if batch != nil {
+		val.batch = &batchValidator{
+			validate: batch,
+			maxBatch: req.batchSize,
+			maxWait:  req.batchWait,
+		}
+	}
+
 	return val, nil
 }
 
+// SetBuiltinValidators sets the built-in validators of a topic, installed with the topic options;
+// nil removes them.
+func (v *validation) SetBuiltinValidators(topic string, vals []*validatorImpl) {
+	v.mx.Lock()
+	defer v.mx.Unlock()
+
+	if len(vals) == 0 {
+		delete(v.builtinVals, topic)
+		return
+	}
+	v.builtinVals[topic] = vals
+}
+
 // RemoveValidator removes an existing validator
 func (v *validation) RemoveValidator(req *rmValReq) {
 	v.mx.Lock()
@@ -246,29 +331,90 @@ func (v *validation) PushLocal(msg *Message) error {
 func (v *validation) Push(src peer.ID, msg *Message) bool {
 	vals := v.getValidators(msg)
 
-	if len(vals) > 0 || msg.Signature != nil {
-		select {
-		case v.validateQ <- &validateReq{vals, src, msg}:
-		default:
-			log.Debugf("message validation throttled: queue full; dropping message from %s", src)
-			v.tracer.RejectMessage(msg, RejectValidationQueueFull)
-		}
+	if len(vals) > 0 || v.needsSignatureCheck(msg) {
+		v.enqueue(vals, src, msg)
 		return false
 	}
 
 	return true
 }
 
+func (v *validation) enqueue(vals []*validatorImpl, src peer.ID, msg *Message) {
+	v.p.memBudget.acquire(msg)
+	select {
+	case v.validateQ <- &validateReq{vals, src, msg, v.p.accepts(msg.GetTopic())}:
+	default:
+		v.p.memBudget.release(msg)
+		v.p.logger.Debugw("message validation throttled: queue full; dropping message", "peer", src, "topic", msg.GetTopic())
+		v.tracer.RejectMessage(msg, RejectValidationQueueFull)
+	}
+}
+
+// begin starts the validation of a message, unless it has already been seen or is already being
+// validated, in which case the copy is coalesced with the pending validation; it returns whether
+// the message must be validated.
+func (v *validation) begin(id string, msg *Message) bool {
+	v.inflightMx.Lock()
+	defer v.inflightMx.Unlock()
+
+	if v.p.seenMessage(id) {
+		return false
+	}
+
+	copies, ok := v.inflight[id]
+	if !ok {
+		v.inflight[id] = nil
+		return true
+	}
+
+	// the publisher of a local copy is answered right away, so it can't be validated in turn
+	if len(copies) < maxCoalescedCopies && msg.routed == nil {
+		v.inflight[id] = append(copies, msg)
+	}
+	return false
+}
+
+// finish ends the validation of a message. If it has an outcome, the message is marked as seen and
+// the copies received in the meantime share it; otherwise, as when validation is throttled, the
+// copies are validated in turn, so that the message is not lost.
+func (v *validation) finish(id string, outcome bool) {
+	v.inflightMx.Lock()
+	copies := v.inflight[id]
+	delete(v.inflight, id)
+	if outcome {
+		v.p.markSeen(id)
+	}
+	v.inflightMx.Unlock()
+
+	if outcome {
+		return
+	}
+	for _, msg := range copies {
+		v.enqueue(v.getValidators(msg), msg.ReceivedFrom, msg)
+	}
+}
+
+// validating returns whether a message is being validated.
+func (v *validation) validating(id string) bool {
+	v.inflightMx.Lock()
+	defer v.inflightMx.Unlock()
+
+	_, ok := v.inflight[id]
+	return ok
+}
+
 // getValidators returns all validators that apply to a given message
 func (v *validation) getValidators(msg *Message) []*validatorImpl {
 	v.mx.Lock()
 	defer v.mx.Unlock()
 
+	topic := msg.GetTopic()
+
+	// the built-in validators run first
 	var vals []*validatorImpl
+	vals = append(vals, v.builtinVals[topic]...)
 	vals = append(vals, v.defaultVals...)
 
-	topic := msg.GetTopic()
-
 	val, ok := v.topicVals[topic]
 	if !ok {
 		return vals
@@ -282,7 +428,31 @@ func (v *validation) validateWorker() {
 	for {
 		select {
 		case req := <-v.validateQ:
-			v.validate(req.vals, req.src, req.msg, false)
+			if req.accepted && !v.p.accepts(req.msg.GetTopic()) {
+				v.p.logger.Debugw("left topic of queued message; dropping message", "peer", req.src, "topic", req.msg.GetTopic())
+				v.tracer.RejectMessage(req.msg, RejectTopicLeft)
+			} else if v.validate(req.vals, req.src, req.msg, false) == errValidationPending {
+				// the message holds its budget until its validation completes
+				continue
+			}
+			v.p.memBudget.release(req.msg)
+		case <-v.p.ctx.Done():
+			return
+		}
+	}
+}
+
+// sigWorker is an active goroutine verifying message signatures
+func (v *validation) sigWorker() {
+	for {
+		select {
+		case req := <-v.sigQ:
+			if v.validateSignature(req.msg) {
+				*req.result = sigValid
+			} else {
+				*req.result = sigInvalid
+			}
+			req.done <- struct{}{}
 		case <-v.p.ctx.Done():
 			return
 		}
@@ -293,29 +463,40 @@ func (v *validation) validateWorker() {
 func (v *validation) validate(vals []*validatorImpl, src peer.ID, msg *Message, synchronous bool) error {
 	// If signature verification is enabled, but signing is disabled,
 	// the Signature is required to be nil upon receiving the message in PubSub.pushMsg.
-	if msg.Signature != nil {
+	if v.needsSignatureCheck(msg) && msg.sig != sigValid {
 		if !v.validateSignature(msg) {
-			log.Debugf("message signature validation failed; dropping message from %s", src)
+			v.p.logger.Debugw("message signature validation failed; dropping message", "peer", src, "topic", msg.GetTopic())
 			v.tracer.RejectMessage(msg, RejectInvalidSignature)
 			return ValidationError{Reason: RejectInvalidSignature}
 		}
 	}
 
-	// we can mark the message as seen now that we have verified the signature
-	// and avoid invoking user validators more than once
+	// reject replays of old messages, now that we know the author is genuine
+	if !v.p.seqnos.check(msg) {
+		v.p.logger.Debugw("message sequence number replayed; dropping message", "peer", src, "topic", msg.GetTopic(), "author", msg.GetFrom())
+		v.tracer.RejectMessage(msg, RejectReplayedSeqno)
+		return ValidationError{Reason: RejectReplayedSeqno}
+	}
+
+	// now that we have verified the signature, avoid invoking user validators more than once
 	id := v.p.idGen.ID(msg)
-	if !v.p.markSeen(id) {
+	if !v.begin(id, msg) {
 		v.tracer.DuplicateMessage(msg)
+		// a duplicate of a message we publish is not routed
+		msg.reportRouted(nil)
 		return nil
 	} else {
 		v.tracer.ValidateMessage(msg)
 	}
 
-	var inline, async []*validatorImpl
+	var inline, async, batch []*validatorImpl
 	for _, val := range vals {
-		if val.validateInline || synchronous {
+		switch {
+		case val.validateInline || synchronous:
 			inline = append(inline, val)
-		} else {
+		case val.batch != nil:
+			batch = append(batch, val)
+		default:
 			async = append(async, val)
 		}
 	}
@@ -324,7 +505,7 @@ func (v *validation) validate(vals []*validatorImpl, src peer.ID, msg *Message,
 	result := ValidationAccept
 loop:
 	for _, val := range inline {
-		switch val.validateMsg(v.p.ctx, src, msg) {
+		switch v.validateMsg(v.p.ctx, val, src, msg) {
 		case ValidationAccept:
 		case ValidationReject:
 			result = ValidationReject
@@ -335,25 +516,35 @@ loop:
 	}
 
 	if result == ValidationReject {
-		log.Debugf("message validation failed; dropping message from %s", src)
+		v.p.logger.Debugw("message validation failed; dropping message", "peer", src, "topic", msg.GetTopic())
+		v.p.rejected.add(id)
+		v.finish(id, true)
 		v.tracer.RejectMessage(msg, RejectValidationFailed)
 		return ValidationError{Reason: RejectValidationFailed}
 	}
 
-	// apply async validators
+	// apply async validators, and then batch validators
 	if len(async) > 0 {
 		select {
 		case v.validateThrottle <- struct{}{}:
 			go func() {
-				v.doValidateTopic(async, src, msg, result)
+				v.doValidateTopic(async, batch, src, msg, result)
 				<-v.validateThrottle
 			}()
 		default:
-			log.Debugf("message validation throttled; dropping message from %s", src)
+			v.p.logger.Debugw("message validation throttled; dropping message", "peer", src, "topic", msg.GetTopic())
 			v.tracer.RejectMessage(msg, RejectValidationThrottled)
+			v.finish(id, false)
+			return nil
 		}
-		return nil
+		return errValidationPending
 	}
+	if len(batch) > 0 {
+		v.validateBatch(batch, src, msg, result)
+		return errValidationPending
+	}
+
+	v.finish(id, true)
 
 	if result == ValidationIgnore {
 		v.tracer.RejectMessage(msg, RejectValidationIgnored)
@@ -369,37 +560,141 @@ loop:
 	}
 }
 
+// outcomes of the verification of the signature of a message on receipt; see verifyIncoming
+const (
+	sigUnverified uint8 = iota
+	sigValid
+	sigInvalid
+)
+
+// verifyIncoming verifies the signatures of the messages in an RPC received from a peer, with
+// StrictSign, before they reach the validation queue. It runs in the reader goroutine of the peer,
+// so a peer sending invalid signatures only slows down its own stream; the messages with an
+// invalid signature are then rejected by pushMsg, in order with the rest of the RPCs of the peer,
+// without ever taking a slot in the queue and crowding out the messages of other peers.
+// The messages in topics we don't accept and the copies of messages already seen or being
+// validated are left unverified, as they are dropped before verification.
+func (v *validation) verifyIncoming(rpc *RPC) {
+	pmsgs := rpc.GetPublish()
+	if len(pmsgs) == 0 || !v.p.signPolicy.mustVerify() || !v.p.signPolicy.mustSign() {
+		return
+	}
+
+	rpc.sigs = make([]uint8, len(pmsgs))
+	var msgs []*Message
+	var results []*uint8
+	for i, pmsg := range pmsgs {
+		msg := &Message{Message: pmsg, ID: rpc.id(i), ReceivedFrom: rpc.from}
+		if !v.needsSignatureCheck(msg) || !v.p.accepts(pmsg.GetTopic()) {
+			continue
+		}
+
+		id := v.p.idGen.ID(msg)
+		if v.p.seenMessage(id) || v.validating(id) {
+			continue
+		}
+
+		msgs = append(msgs, msg)
+		results = append(results, &rpc.sigs[i])
+	}
+
+	v.verifySignatures(msgs, results)
+}
+
+// verifySignatures verifies the signatures of messages, storing the outcomes in results. With
+// signature verification workers, the verifications are spread over the workers, and it waits for
+// all of them; the verifications not done when pubsub shuts down are left unverified.
+func (v *validation) verifySignatures(msgs []*Message, results []*uint8) {
+	if v.sigQ == nil || len(msgs) == 1 {
+		for i, msg := range msgs {
+			if v.validateSignature(msg) {
+				*results[i] = sigValid
+			} else {
+				*results[i] = sigInvalid
+			}
+		}
+		return
+	}
+
+	done := make(chan struct{}, len(msgs))
+	pending := 0
+	for i, msg := range msgs {
+		select {
+		case v.sigQ <- &sigCheck{msg: msg, result: results[i], done: done}:
+			pending++
+		case <-v.p.ctx.Done():
+			return
+		}
+	}
+	for ; pending > 0; pending-- {
+		select {
+		case <-done:
+		case <-v.p.ctx.Done():
+			return
+		}
+	}
+}
+
+// needsSignatureCheck returns whether a message has its signature verified: either it carries a
+// signature, or its topic has a signature verifier, which decides about unsigned messages too.
+func (v *validation) needsSignatureCheck(msg *Message) bool {
+	return msg.Signature != nil || v.p.sigVerifiers.Get(msg.GetTopic()) != nil
+}
+
 func (v *validation) validateSignature(msg *Message) bool {
-	err := verifyMessageSignature(msg.Message)
+	var err error
+	if verify := v.p.sigVerifiers.Get(msg.GetTopic()); verify != nil {
+		err = verify(msg)
+	} else {
+		err = verifyMessageSignature(msg.Message)
+	}
 	if err != nil {
-		log.Debugf("signature verification error: %s", err.Error())
+		v.p.logger.Debugw("signature verification error", "peer", msg.ReceivedFrom, "topic", msg.GetTopic(), "err", err)
 		return false
 	}
 
 	return true
 }
 
-func (v *validation) doValidateTopic(vals []*validatorImpl, src peer.ID, msg *Message, r ValidationResult) {
+func (v *validation) doValidateTopic(vals, batch []*validatorImpl, src peer.ID, msg *Message, r ValidationResult) {
 	result := v.validateTopic(vals, src, msg)
 
 	if result == ValidationAccept && r != ValidationAccept {
 		result = r
 	}
 
+	if len(batch) > 0 && (result == ValidationAccept || result == ValidationIgnore) {
+		v.validateBatch(batch, src, msg, result)
+		return
+	}
+
+	v.complete(src, msg, result)
+}
+
+// complete applies the result of the validation of a message, and releases its memory budget.
+func (v *validation) complete(src peer.ID, msg *Message, result ValidationResult) {
+	defer v.p.memBudget.release(msg)
+
+	id := v.p.idGen.ID(msg)
 	switch result {
 	case ValidationAccept:
+		v.finish(id, true)
 		v.p.sendMsg <- msg
 	case ValidationReject:
-		log.Debugf("message validation failed; dropping message from %s", src)
+		v.p.logger.Debugw("message validation failed; dropping message", "peer", src, "topic", msg.GetTopic())
+		v.p.rejected.add(id)
+		v.finish(id, true)
 		v.tracer.RejectMessage(msg, RejectValidationFailed)
 		return
 	case ValidationIgnore:
-		log.Debugf("message validation punted; ignoring message from %s", src)
+		v.p.logger.Debugw("message validation punted; ignoring message", "peer", src, "topic", msg.GetTopic())
+		v.finish(id, true)
 		v.tracer.RejectMessage(msg, RejectValidationIgnored)
 		return
 	case validationThrottled:
-		log.Debugf("message validation throttled; ignoring message from %s", src)
+		v.p.logger.Debugw("message validation throttled; ignoring message", "peer", src, "topic", msg.GetTopic())
 		v.tracer.RejectMessage(msg, RejectValidationThrottled)
+		v.finish(id, false)
 
 	default:
 		// BUG: this would be an internal programming error, so a panic seems appropiate.
@@ -424,12 +719,12 @@ func (v *validation) validateTopic(vals []*validatorImpl, src peer.ID, msg *Mess
 		select {
 		case val.validateThrottle <- struct{}{}:
 			go func(val *validatorImpl) {
-				rch <- val.validateMsg(ctx, src, msg)
+				rch <- v.validateMsg(ctx, val, src, msg)
 				<-val.validateThrottle
 			}(val)
 
 		default:
-			log.Debugf("validation throttled for topic %s", val.topic)
+			v.p.logger.Debugw("validation throttled", "peer", src, "topic", msg.GetTopic())
 			rch <- validationThrottled
 		}
 	}
@@ -460,20 +755,20 @@ loop:
 func (v *validation) validateSingleTopic(val *validatorImpl, src peer.ID, msg *Message) ValidationResult {
 	select {
 	case val.validateThrottle <- struct{}{}:
-		res := val.validateMsg(v.p.ctx, src, msg)
+		res := v.validateMsg(v.p.ctx, val, src, msg)
 		<-val.validateThrottle
 		return res
 
 	default:
-		log.Debugf("validation throttled for topic %s", val.topic)
+		v.p.logger.Debugw("validation throttled", "peer", src, "topic", msg.GetTopic())
 		return validationThrottled
 	}
 }
 
-func (val *validatorImpl) validateMsg(ctx context.Context, src peer.ID, msg *Message) ValidationResult {
+func (v *validation) validateMsg(ctx context.Context, val *validatorImpl, src peer.ID, msg *Message) ValidationResult {
 	start := time.Now()
 	defer func() {
-		log.Debugf("validation done; took %s", time.Since(start))
+		v.p.logger.Debugw("validation done", "peer", src, "topic", msg.GetTopic(), "took", time.Since(start))
 	}()
 
 	if val.validateTimeout > 0 {
@@ -492,7 +787,7 @@ func (val *validatorImpl) validateMsg(ctx context.Context, src peer.ID, msg *Mes
 		return r
 
 	default:
-		log.Warnf("Unexpected result from validator: %d; ignoring message", r)
+		v.p.logger.Warnw(fmt.Sprintf("Unexpected result from validator: %d; ignoring message", r), "peer", src, "topic", msg.GetTopic())
 		return ValidationIgnore
 	}
 }
@@ -561,6 +856,27 @@ func WithValidateWorkers(n int) Option {
 	}
 }
 
+// WithSignatureVerificationWorkers sets the number of worker goroutines verifying the signatures
+// of the messages received with StrictSign. By default, the signatures of the messages in an RPC
+// are verified one after the other by the reader goroutine of the peer, so that a peer sending
+// large RPCs keeps a single core busy. With the workers, the verifications of the messages in an
+// RPC are spread over the workers, which are shared by all the peers and bound the cpu time spent
+// verifying signatures.
+//
+// The reader waits for all the verifications of an RPC before handing it to the event loop, so the
+// messages of a peer are still processed in the order they were received; the messages of
+// different peers are not ordered with respect to each other anyway. The messages with an invalid
+// signature are rejected, traced and penalized as without the workers.
+func WithSignatureVerificationWorkers(n int) Option {
+	return func(ps *PubSub) error {
+		if n > 0 {
+			ps.val.sigWorkers = n
+			return nil
+		}
+		return fmt.Errorf("number of signature verification workers must be > 0")
+	}
+}
+
 // WithValidatorTimeout is an option that sets a timeout for an (asynchronous) topic validator.
 // By default there is no timeout in asynchronous validators.
 func WithValidatorTimeout(timeout time.Duration) ValidatorOpt {
@@ -0,0 +1,194 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// BatchValidator is a validation function that validates a batch of messages at once, for
+// validators that are much cheaper per message when given batches, e.g. verifying aggregate
+// signatures. It returns the decisions for the messages, in the order of the batch; the peer
+// each message was received from is its ReceivedFrom. It must be registered WithBatchValidation.
+type BatchValidator func(context.Context, []*Message) []ValidationResult
+
+// WithBatchValidation is a validator option for BatchValidators, that accumulates the messages of
+// the topic in batches of up to maxBatch messages, waiting at most maxWait for a batch to fill.
+// The results are applied to each message of the batch as with other validators, and the
+// accepted messages are delivered in the order they joined the batch; messages join the batch
+// once the other validators of the topic have accepted them.
+// The validator timeout applies to each invocation of the validator, and the validator
+// concurrency bounds the number of batches validated at once; a batch that is throttled is
+// dropped as a whole. Locally published messages are validated right away, in a batch of their
+// own.
+func WithBatchValidation(maxBatch int, maxWait time.Duration) ValidatorOpt {
+	return func(addVal *addValReq) error {
+		if maxBatch <= 0 {
+			return fmt.Errorf("invalid batch size; must be positive")
+		}
+		if maxWait <= 0 {
+			return fmt.Errorf("invalid batch wait; must be positive")
+		}
+
+		addVal.batchSize = maxBatch
+		addVal.batchWait = maxWait
+		return nil
+	}
+}
+
+// batchValidator accumulates the messages for a BatchValidator.
+type batchValidator struct {
+	validate BatchValidator
+	maxBatch int
+	maxWait  time.Duration
+
+	mx      sync.Mutex
+	pending []*batchEntry
+	// the generation of the pending batch, so that the timer of a batch that was already taken
+	// doesn't flush the next one
+	gen   uint64
+	timer *time.Timer
+}
+
+// batchEntry is a message waiting in a batch, with the remaining batch validators and the
+// result of the validators applied so far.
+type batchEntry struct {
+	vals   []*validatorImpl
+	src    peer.ID
+	msg    *Message
+	result ValidationResult
+}
+
+// single adapts a batch validator to validate a single message.
+func (bv BatchValidator) single() ValidatorEx {
+	return func(ctx context.Context, _ peer.ID, msg *Message) ValidationResult {
+		res := bv(ctx, []*Message{msg})
+		if len(res) != 1 {
+			return ValidationIgnore
+		}
+		return res[0]
+	}
+}
+
+// take takes the pending batch; it assumes the lock is held.
+func (b *batchValidator) take() []*batchEntry {
+	batch := b.pending
+	b.pending = nil
+	b.gen++
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return batch
+}
+
+// validateBatch adds a message to the pending batch of the first of the batch validators, and
+// validates the batch once it is full.
+func (v *validation) validateBatch(vals []*validatorImpl, src peer.ID, msg *Message, r ValidationResult) {
+	val := vals[0]
+	b := val.batch
+
+	b.mx.Lock()
+	b.pending = append(b.pending, &batchEntry{vals: vals[1:], src: src, msg: msg, result: r})
+	if len(b.pending) < b.maxBatch {
+		if len(b.pending) == 1 {
+			gen := b.gen
+			b.timer = time.AfterFunc(b.maxWait, func() {
+				v.flushBatch(val, gen)
+			})
+		}
+		b.mx.Unlock()
+		return
+	}
+	batch := b.take()
+	b.mx.Unlock()
+
+	go v.runBatch(val, batch)
+}
+
+// flushBatch validates the pending batch once it has waited long enough.
+func (v *validation) flushBatch(val *validatorImpl, gen uint64) {
+	b := val.batch
+
+	b.mx.Lock()
+	if b.gen != gen || len(b.pending) == 0 {
+		b.mx.Unlock()
+		return
+	}
+	batch := b.take()
+	b.mx.Unlock()
+
+	v.runBatch(val, batch)
+}
+
+// runBatch validates a batch and applies the results to its messages, in order.
+func (v *validation) runBatch(val *validatorImpl, batch []*batchEntry) {
+	results := v.validateBatchMsgs(val, batch)
+
+	for i, e := range batch {
+		result := results[i]
+		if result == ValidationAccept && e.result != ValidationAccept {
+			result = e.result
+		}
+
+		if len(e.vals) > 0 && (result == ValidationAccept || result == ValidationIgnore) {
+			v.validateBatch(e.vals, e.src, e.msg, result)
+			continue
+		}
+		v.complete(e.src, e.msg, result)
+	}
+}
+
+// validateBatchMsgs invokes a batch validator, subject to the validator throttle.
+func (v *validation) validateBatchMsgs(val *validatorImpl, batch []*batchEntry) []ValidationResult {
+	results := make([]ValidationResult, len(batch))
+
+	select {
+	case val.validateThrottle <- struct{}{}:
+		defer func() { <-val.validateThrottle }()
+	default:
+		v.p.logger.Debugw("batch validation throttled", "topic", val.topic, "size", len(batch))
+		for i := range results {
+			results[i] = validationThrottled
+		}
+		return results
+	}
+
+	ctx := v.p.ctx
+	if val.validateTimeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, val.validateTimeout)
+		defer cancel()
+	}
+
+	msgs := make([]*Message, len(batch))
+	for i, e := range batch {
+		msgs[i] = e.msg
+	}
+
+	start := time.Now()
+	res := val.batch.validate(ctx, msgs)
+	v.p.logger.Debugw("batch validation done", "topic", val.topic, "size", len(msgs), "took", time.Since(start))
+
+	if len(res) != len(msgs) {
+		v.p.logger.Warnw(fmt.Sprintf("Unexpected number of results from batch validator: %d for %d messages; ignoring messages", len(res), len(msgs)), "topic", val.topic)
+		for i := range results {
+			results[i] = ValidationIgnore
+		}
+		return results
+	}
+
+	for i, r := range res {
+		switch r {
+		case ValidationAccept, ValidationReject, ValidationIgnore:
+			results[i] = r
+		default:
+			v.p.logger.Warnw(fmt.Sprintf("Unexpected result from batch validator: %d; ignoring message", r), "peer", msgs[i].ReceivedFrom, "topic", val.topic)
+			results[i] = ValidationIgnore
+		}
+	}
+	return results
+}
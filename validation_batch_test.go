@@ -0,0 +1,149 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestBatchValidation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	tracer := &rejectReasonTracer{reasons: make(map[string]int)}
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0]),
+		// a single worker keeps the messages in arrival order
+		getGossipsub(ctx, hosts[1], WithValidateWorkers(1), WithEventTracer(tracer)),
+	}
+
+	const topic = "foobar"
+
+	var mx sync.Mutex
+	var batches []int
+	validator := func(ctx context.Context, msgs []*Message) []ValidationResult {
+		mx.Lock()
+		batches = append(batches, len(msgs))
+		mx.Unlock()
+
+		res := make([]ValidationResult, len(msgs))
+		for i, msg := range msgs {
+			switch {
+			case strings.HasSuffix(string(msg.Data), "bad"):
+				res[i] = ValidationReject
+			case strings.HasSuffix(string(msg.Data), "meh"):
+				res[i] = ValidationIgnore
+			default:
+				res[i] = ValidationAccept
+			}
+		}
+		return res
+	}
+	if err := psubs[1].RegisterTopicValidator(topic, validator, WithBatchValidation(4, 200*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := psubs[1].Subscribe(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	// locally published messages are validated right away, in a batch of their own
+	if err := psubs[1].Publish(topic, []byte("local bad")); err == nil {
+		t.Fatal("expected the local message to be rejected")
+	}
+
+	// two full batches, and one flushed after the wait
+	expected := 0
+	for i := 0; i < 10; i++ {
+		data := fmt.Sprintf("msg%d", i)
+		switch i {
+		case 2, 5:
+			data += " bad"
+		case 6:
+			data += " meh"
+		default:
+			expected++
+		}
+		if err := psubs[0].Publish(topic, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// batches are validated concurrently, but the messages of a batch are delivered in order
+	received := make(map[int]int)
+	for i := 0; i < expected; i++ {
+		rctx, rcancel := context.WithTimeout(ctx, 2*time.Second)
+		msg, err := sub.Next(rctx)
+		rcancel()
+		if err != nil {
+			t.Fatalf("received %d of %d messages: %s", i, expected, err)
+		}
+		var n int
+		if _, err := fmt.Sscanf(string(msg.Data), "msg%d", &n); err != nil || n == 2 || n == 5 || n == 6 {
+			t.Fatalf("unexpected message %s", msg.Data)
+		}
+		received[n] = i
+	}
+	if len(received) != expected {
+		t.Fatalf("expected %d distinct messages, got %d", expected, len(received))
+	}
+	for _, pair := range [][2]int{{0, 1}, {1, 3}, {4, 7}, {8, 9}} {
+		if received[pair[0]] > received[pair[1]] {
+			t.Fatalf("expected msg%d to be delivered before msg%d", pair[0], pair[1])
+		}
+	}
+
+	mx.Lock()
+	got := fmt.Sprint(batches)
+	mx.Unlock()
+	if expected := fmt.Sprint([]int{1, 4, 4, 2}); got != expected {
+		t.Fatalf("expected batches %s, got %s", expected, got)
+	}
+
+	if n := tracer.count(RejectValidationFailed); n != 3 {
+		t.Fatalf("expected 3 rejected messages, got %d", n)
+	}
+	if n := tracer.count(RejectValidationIgnored); n != 1 {
+		t.Fatalf("expected 1 ignored message, got %d", n)
+	}
+}
+
+func TestBatchValidatorRegistration(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getGossipsub(ctx, hosts[0])
+
+	batch := func(ctx context.Context, msgs []*Message) []ValidationResult {
+		return make([]ValidationResult, len(msgs))
+	}
+	single := func(ctx context.Context, from peer.ID, msg *Message) bool {
+		return true
+	}
+
+	if err := ps.RegisterTopicValidator("a", batch); err == nil {
+		t.Fatal("expected an error for a batch validator without batch validation")
+	}
+	if err := ps.RegisterTopicValidator("b", single, WithBatchValidation(4, time.Second)); err == nil {
+		t.Fatal("expected an error for batch validation without a batch validator")
+	}
+	if err := ps.RegisterTopicValidator("c", batch, WithBatchValidation(4, time.Second), WithValidatorInline(true)); err == nil {
+		t.Fatal("expected an error for an inline batch validator")
+	}
+	if err := ps.RegisterTopicValidator("d", batch, WithBatchValidation(0, time.Second)); err == nil {
+		t.Fatal("expected an error for an invalid batch size")
+	}
+	if err := ps.RegisterTopicValidator("e", BatchValidator(batch), WithBatchValidation(4, time.Second)); err != nil {
+		t.Fatal(err)
+	}
+}
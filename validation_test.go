@@ -4,10 +4,17 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
 
@@ -332,3 +339,591 @@ func TestValidateAssortedOptions(t *testing.T) {
 		}
 	}
 }
+
+type duplicateTracer struct {
+	mx         sync.Mutex
+	duplicates int
+	throttled  int
+}
+
+func (t *duplicateTracer) Trace(evt *pb.TraceEvent) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	switch evt.GetType() {
+	case pb.TraceEvent_DUPLICATE_MESSAGE:
+		t.duplicates++
+	case pb.TraceEvent_REJECT_MESSAGE:
+		if evt.GetRejectMessage().GetReason() == RejectValidationThrottled {
+			t.throttled++
+		}
+	}
+}
+
+func (t *duplicateTracer) counts() (duplicates, throttled int) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.duplicates, t.throttled
+}
+
+// getDuplicatesPubsub returns an unconnected pubsub where messages are identified by their data,
+// and a function pushing a message into it as if received from a peer.
+func getDuplicatesPubsub(t *testing.T, ctx context.Context, topic string, opts ...Option) (*PubSub, func(data string, from peer.ID)) {
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0], append([]Option{
+		WithMessageSignaturePolicy(StrictNoSign),
+		WithMessageIdFn(func(m *pb.Message) string { return string(m.Data) }),
+	}, opts...)...)
+
+	push := func(data string, from peer.ID) {
+		msg := &Message{Message: &pb.Message{Topic: &topic, Data: []byte(data)}, ReceivedFrom: from}
+		ps.eval <- func() {
+			ps.pushMsg(msg)
+		}
+	}
+	return ps, push
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for i := 0; !cond(); i++ {
+		if i == 100 {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func expectMessage(t *testing.T, ctx context.Context, sub *Subscription, data string) {
+	t.Helper()
+	rctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	msg, err := sub.Next(rctx)
+	if err != nil {
+		t.Fatalf("expected message %q: %s", data, err)
+	}
+	if string(msg.Data) != data {
+		t.Fatalf("expected message %q, got %q", data, msg.Data)
+	}
+}
+
+func expectNoMessage(t *testing.T, ctx context.Context, sub *Subscription) {
+	t.Helper()
+	rctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+
+	if msg, err := sub.Next(rctx); err == nil {
+		t.Fatalf("unexpected message %q", msg.Data)
+	}
+}
+
+func TestValidateThrottledCopy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "foobar"
+	tracer := &duplicateTracer{}
+	ps, push := getDuplicatesPubsub(t, ctx, topic, WithValidateThrottle(1), WithEventTracer(tracer))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	err := ps.RegisterTopicValidator(topic, func(ctx context.Context, p peer.ID, msg *Message) bool {
+		if string(msg.Data) == "block" {
+			close(started)
+			<-release
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := ps.Subscribe(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first copy is throttled while another message takes the only validation slot
+	push("block", "peer1")
+	<-started
+	push("msg", "peer1")
+	waitFor(t, "the message to be throttled", func() bool {
+		_, throttled := tracer.counts()
+		return throttled == 1
+	})
+	close(release)
+	expectMessage(t, ctx, sub, "block")
+
+	// the throttled message has no outcome, so it's not marked as seen and the next copy is delivered
+	push("msg", "peer2")
+	expectMessage(t, ctx, sub, "msg")
+	if dups, _ := tracer.counts(); dups != 0 {
+		t.Fatalf("expected no duplicates, got %d", dups)
+	}
+}
+
+// Test that the queued validations of the messages in a topic we leave are cancelled.
+func TestValidateQueueLeftTopic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "foobar"
+	tracer := &rejectReasonTracer{reasons: make(map[string]int)}
+	ps, push := getDuplicatesPubsub(t, ctx, "block", WithValidateWorkers(1), WithEventTracer(tracer))
+
+	// the only validation worker is busy with a message in another topic
+	started := make(chan struct{})
+	release := make(chan struct{})
+	err := ps.RegisterTopicValidator("block", func(ctx context.Context, p peer.ID, msg *Message) bool {
+		close(started)
+		<-release
+		return true
+	}, WithValidatorInline(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var validated int32
+	err = ps.RegisterTopicValidator(topic, func(ctx context.Context, p peer.ID, msg *Message) bool {
+		atomic.AddInt32(&validated, 1)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.Subscribe("block"); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := ps.Subscribe(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	push("block", "peer1")
+	<-started
+	for i := 0; i < 3; i++ {
+		msg := &Message{Message: &pb.Message{Topic: proto.String(topic), Data: []byte(fmt.Sprintf("msg%d", i))}, ReceivedFrom: "peer1"}
+		ps.eval <- func() {
+			ps.pushMsg(msg)
+		}
+	}
+
+	sub.Cancel()
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	waitFor(t, "the queued messages to be dropped", func() bool {
+		return tracer.count(RejectTopicLeft) == 3
+	})
+	if n := atomic.LoadInt32(&validated); n != 0 {
+		t.Fatalf("expected no validation in the topic we left, got %d", n)
+	}
+}
+
+func TestValidateCoalescedCopies(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "foobar"
+	tracer := &duplicateTracer{}
+
+	blockStarted := make(chan struct{})
+	releaseBlock := make(chan struct{})
+	defaultValidator := func(ctx context.Context, p peer.ID, msg *Message) bool {
+		if string(msg.Data) == "block" {
+			close(blockStarted)
+			<-releaseBlock
+		}
+		return true
+	}
+	ps, push := getDuplicatesPubsub(t, ctx, topic, WithEventTracer(tracer),
+		WithDefaultValidator(defaultValidator, WithValidatorConcurrency(1)))
+
+	var mx sync.Mutex
+	calls := make(map[string]int)
+	started := make(chan string)
+	release := make(chan struct{})
+	err := ps.RegisterTopicValidator(topic, func(ctx context.Context, p peer.ID, msg *Message) bool {
+		data := string(msg.Data)
+		mx.Lock()
+		calls[data]++
+		first := calls[data] == 1
+		mx.Unlock()
+
+		if first && data != "block" {
+			started <- data
+			<-release
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	validated := func(data string) int {
+		mx.Lock()
+		defer mx.Unlock()
+		return calls[data]
+	}
+	sub, err := ps.Subscribe(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// copies arriving while the first is validated share its outcome
+	push("shared", "peer1")
+	<-started
+	push("shared", "peer2")
+	push("shared", "peer3")
+	waitFor(t, "the copies to be coalesced", func() bool {
+		dups, _ := tracer.counts()
+		return dups == 2
+	})
+	release <- struct{}{}
+	expectMessage(t, ctx, sub, "shared")
+	expectNoMessage(t, ctx, sub)
+	if n := validated("shared"); n != 1 {
+		t.Fatalf("expected the message to be validated once, got %d", n)
+	}
+
+	// the first copy is throttled by the default validator, whose only slot is taken, while the
+	// topic validator runs; the coalesced copies are validated in turn
+	push("block", "peer1")
+	<-blockStarted
+	push("msg", "peer1")
+	<-started
+	push("msg", "peer2")
+	push("msg", "peer3")
+	waitFor(t, "the copies to be coalesced", func() bool {
+		dups, _ := tracer.counts()
+		return dups == 4
+	})
+	close(releaseBlock)
+	expectMessage(t, ctx, sub, "block")
+
+	release <- struct{}{}
+	expectMessage(t, ctx, sub, "msg")
+	expectNoMessage(t, ctx, sub)
+	if _, throttled := tracer.counts(); throttled < 1 {
+		t.Fatal("expected the first copy to be throttled")
+	}
+	if n := validated("msg"); n < 2 {
+		t.Fatalf("expected the message to be validated again, got %d validations", n)
+	}
+}
+
+func TestValidateDuplicatesStress(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const (
+		topic  = "foobar"
+		count  = 200
+		copies = 5
+	)
+	ps, push := getDuplicatesPubsub(t, ctx, topic, WithValidateQueueSize(count*copies))
+
+	var mx sync.Mutex
+	calls := make(map[string]int)
+	err := ps.RegisterTopicValidator(topic, func(ctx context.Context, p peer.ID, msg *Message) bool {
+		mx.Lock()
+		calls[string(msg.Data)]++
+		mx.Unlock()
+
+		time.Sleep(time.Duration(rand.Intn(2000)) * time.Microsecond)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := ps.Subscribe(topic, WithBufferSize(count))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// subscriptions come and go while the messages are validated
+	churnCtx, churnCancel := context.WithCancel(ctx)
+	defer churnCancel()
+	go func() {
+		for churnCtx.Err() == nil {
+			s, err := ps.Subscribe(topic)
+			if err != nil {
+				return
+			}
+			time.Sleep(time.Millisecond)
+			s.Cancel()
+		}
+	}()
+
+	// every peer sends a copy of every message, in its own order
+	var wg sync.WaitGroup
+	for i := 0; i < copies; i++ {
+		wg.Add(1)
+		go func(from peer.ID) {
+			defer wg.Done()
+			for _, j := range rand.Perm(count) {
+				push(fmt.Sprintf("msg%d", j), from)
+			}
+		}(peer.ID(fmt.Sprintf("peer%d", i)))
+	}
+	wg.Wait()
+
+	received := make(map[string]int)
+	for i := 0; i < count; i++ {
+		rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+		msg, err := sub.Next(rctx)
+		rcancel()
+		if err != nil {
+			t.Fatalf("received %d of %d messages: %s", len(received), count, err)
+		}
+		received[string(msg.Data)]++
+	}
+	expectNoMessage(t, ctx, sub)
+
+	for data, n := range received {
+		if n != 1 {
+			t.Fatalf("expected message %s to be delivered once, got %d", data, n)
+		}
+	}
+	mx.Lock()
+	defer mx.Unlock()
+	for data, n := range calls {
+		if n != 1 {
+			t.Fatalf("expected message %s to be validated once, got %d", data, n)
+		}
+	}
+}
+
+func TestValidateEarlySignatureRejection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 2)
+	ps := getPubsub(ctx, hosts[0])
+	nosign := getPubsub(ctx, hosts[1], WithMessageSignaturePolicy(StrictNoSign), WithNoAuthor())
+
+	topic := "foobar"
+	sub, err := ps.Subscribe(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nosign.Subscribe(topic); err != nil {
+		t.Fatal(err)
+	}
+
+	priv, _, err := crypto.GenerateEd25519Key(rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	author, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seqno uint64
+	makeMsg := func(data string, signed bool) *pb.Message {
+		seqno++
+		m := &pb.Message{From: []byte(author), Seqno: []byte(fmt.Sprint(seqno)), Topic: &topic, Data: []byte(data)}
+		if signed {
+			if err := signMessage(author, priv, m); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return m
+	}
+
+	good := makeMsg("good", true)
+	bad := makeMsg("bad", true)
+	bad.Data = []byte("tampered")
+	unsigned := makeMsg("unsigned", false)
+	seen := makeMsg("seen", true)
+	ps.markSeen(ps.idGen.RawID(seen))
+	other := makeMsg("other", true)
+	otherTopic := "other"
+	other.Topic = &otherTopic
+
+	rpc := &RPC{RPC: pb.RPC{Publish: []*pb.Message{bad, good, unsigned, seen, other}}, from: "peer"}
+	ps.val.verifyIncoming(rpc)
+
+	// the copies of seen messages and the messages in other topics are left unverified
+	for i, expected := range []uint8{sigInvalid, sigValid, sigUnverified, sigUnverified, sigUnverified} {
+		if rpc.sig(i) != expected {
+			t.Fatalf("unexpected verification %v", rpc.sigs)
+		}
+	}
+
+	// the message with an invalid signature is rejected before the validation queue
+	rejections, cancelFeed := ps.RejectedMessages()
+	defer cancelFeed()
+	ps.eval <- func() {
+		ps.handleIncomingRPC(rpc)
+	}
+
+	for _, reason := range []string{RejectInvalidSignature, RejectMissingSignature} {
+		select {
+		case rec := <-rejections:
+			if rec.Reason != RejectReason(reason) || rec.ReceivedFrom != "peer" {
+				t.Fatalf("unexpected rejection %+v", rec)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected a rejection for %q", reason)
+		}
+	}
+	expectMessage(t, ctx, sub, "good")
+	expectNoMessage(t, ctx, sub)
+
+	// signatures are not verified without StrictSign
+	rpc = &RPC{RPC: pb.RPC{Publish: []*pb.Message{makeMsg("signed", true)}}, from: "peer"}
+	nosign.val.verifyIncoming(rpc)
+	if rpc.sigs != nil {
+		t.Fatalf("unexpected verification %v", rpc.sigs)
+	}
+}
+
+func TestSignatureVerificationWorkers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	if _, err := NewFloodSub(ctx, hosts[0], WithSignatureVerificationWorkers(0)); err == nil {
+		t.Fatal("expected an error for no signature verification workers")
+	}
+	ps := getPubsub(ctx, hosts[0], WithSignatureVerificationWorkers(4))
+
+	topic := "foobar"
+	sub, err := ps.Subscribe(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	priv, _, err := crypto.GenerateEd25519Key(rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	author, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every third message is tampered with
+	var pmsgs []*pb.Message
+	for i := 0; i < 16; i++ {
+		m := &pb.Message{From: []byte(author), Seqno: []byte(fmt.Sprint(i + 1)), Topic: &topic, Data: []byte(fmt.Sprint(i))}
+		if err := signMessage(author, priv, m); err != nil {
+			t.Fatal(err)
+		}
+		if i%3 == 0 {
+			m.Data = []byte("tampered")
+		}
+		pmsgs = append(pmsgs, m)
+	}
+
+	rpc := &RPC{RPC: pb.RPC{Publish: pmsgs}, from: "peer"}
+	ps.val.verifyIncoming(rpc)
+	for i := range pmsgs {
+		expected := sigValid
+		if i%3 == 0 {
+			expected = sigInvalid
+		}
+		if rpc.sig(i) != expected {
+			t.Fatalf("unexpected verification %v", rpc.sigs)
+		}
+	}
+
+	// the messages with an invalid signature are rejected as without the workers, and the others
+	// are delivered
+	rejections, cancelFeed := ps.RejectedMessages()
+	defer cancelFeed()
+	ps.eval <- func() {
+		ps.handleIncomingRPC(rpc)
+	}
+
+	for i := 0; i < 16; i += 3 {
+		select {
+		case rec := <-rejections:
+			if rec.Reason != RejectInvalidSignature || rec.ReceivedFrom != "peer" {
+				t.Fatalf("unexpected rejection %+v", rec)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected a rejection for an invalid signature")
+		}
+	}
+	received := make(map[string]bool)
+	for len(received) < 10 {
+		rctx, rcancel := context.WithTimeout(ctx, 5*time.Second)
+		msg, err := sub.Next(rctx)
+		rcancel()
+		if err != nil {
+			t.Fatalf("expected %d more messages: %s", 10-len(received), err)
+		}
+		received[string(msg.Data)] = true
+	}
+	for i := 0; i < 16; i++ {
+		if received[fmt.Sprint(i)] == (i%3 == 0) {
+			t.Fatalf("unexpected delivery of message %d: %v", i, received)
+		}
+	}
+	expectNoMessage(t, ctx, sub)
+}
+
+// BenchmarkSignatureVerification verifies the signatures of the 64 messages of an RPC, inline and
+// with signature verification workers; the time per RPC drops about linearly with the number of
+// workers, up to the number of cores.
+func BenchmarkSignatureVerification(b *testing.B) {
+	const count = 64
+
+	for _, key := range []struct {
+		name string
+		gen  func() (crypto.PrivKey, crypto.PubKey, error)
+	}{
+		{"Ed25519", func() (crypto.PrivKey, crypto.PubKey, error) { return crypto.GenerateEd25519Key(nil) }},
+		{"Secp256k1", func() (crypto.PrivKey, crypto.PubKey, error) { return crypto.GenerateSecp256k1Key(nil) }},
+	} {
+		priv, _, err := key.gen()
+		if err != nil {
+			b.Fatal(err)
+		}
+		author, err := peer.IDFromPrivateKey(priv)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		topic := "foobar"
+		msgs := make([]*Message, count)
+		for i := range msgs {
+			m := &pb.Message{From: []byte(author), Seqno: []byte(fmt.Sprint(i + 1)), Topic: &topic, Data: make([]byte, 256)}
+			if err := signMessage(author, priv, m); err != nil {
+				b.Fatal(err)
+			}
+			msgs[i] = &Message{Message: m}
+		}
+
+		for _, workers := range []int{0, 1, 2, 4, 8, 16} {
+			b.Run(fmt.Sprintf("%s/Workers=%d", key.name, workers), func(b *testing.B) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				v := newValidation()
+				v.validateWorkers = 0
+				v.sigWorkers = workers
+				v.Start(&PubSub{ctx: ctx, logger: log, sigVerifiers: newTopicVerifiers()})
+
+				sigs := make([]uint8, count)
+				results := make([]*uint8, count)
+				for i := range results {
+					results[i] = &sigs[i]
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					v.verifySignatures(msgs, results)
+				}
+				b.StopTimer()
+
+				for _, sig := range sigs {
+					if sig != sigValid {
+						b.Fatal("expected valid signatures")
+					}
+				}
+			})
+		}
+	}
+}
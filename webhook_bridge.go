@@ -0,0 +1,323 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// WebhookDeliveryMode selects the delivery guarantee of the WebhookBridge.
+type WebhookDeliveryMode int
+
+const (
+	// WebhookAtMostOnce posts each message once; a message whose post fails is dropped, as it
+	// may have been processed by the endpoint anyway. This is the default.
+	WebhookAtMostOnce WebhookDeliveryMode = iota
+	// WebhookAtLeastOnce retries the post of a message after a transport error or a 5xx or 429
+	// response, so the endpoint may receive a message more than once.
+	WebhookAtLeastOnce
+)
+
+var (
+	// WebhookBridgeConcurrency is the default number of messages posted concurrently.
+	WebhookBridgeConcurrency = 8
+	// WebhookBridgeMaxRetries is the default number of times the post of a message is retried
+	// with WebhookAtLeastOnce before the message is dropped.
+	WebhookBridgeMaxRetries = 5
+	// WebhookBridgeRetryBackoff is the default initial backoff between retries; it doubles with
+	// every attempt.
+	WebhookBridgeRetryBackoff = 100 * time.Millisecond
+	// WebhookBridgeMaxRetryBackoff caps the backoff between retries.
+	WebhookBridgeMaxRetryBackoff = 10 * time.Second
+)
+
+// WebhookEnvelope is the JSON document posted by the WebhookBridge for each message; the byte
+// fields are base64 encoded.
+type WebhookEnvelope struct {
+	Topic      string    `json:"topic"`
+	From       string    `json:"from,omitempty"`
+	Seqno      []byte    `json:"seqno,omitempty"`
+	Data       []byte    `json:"data"`
+	ID         []byte    `json:"id"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// WebhookBridgeOpt is an option for NewWebhookBridge.
+type WebhookBridgeOpt func(*WebhookBridge) error
+
+// WebhookBridge posts the messages of a set of topics to an HTTP endpoint, for consumers that are
+// not written in Go; see NewWebhookBridge.
+type WebhookBridge struct {
+	logger   Logger
+	ctx      context.Context
+	cancel   func()
+	endpoint string
+	client   *http.Client
+	mode     WebhookDeliveryMode
+	drop     func(*Message, error)
+
+	concurrency     int
+	maxRetries      int
+	retryBackoff    time.Duration
+	maxRetryBackoff time.Duration
+	subOpts         []SubOpt
+
+	subs []*Subscription
+	// inflight limits the messages being posted
+	inflight chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewWebhookBridge subscribes to the given topics and POSTs each message delivered to the
+// subscriptions to endpoint, as a JSON WebhookEnvelope, until Close is called.
+// Up to a bounded number of messages are posted concurrently, so the messages are not necessarily
+// posted in order. When all the posts are in flight, the bridge stops reading the subscriptions,
+// which buffer the messages until their buffer is full, after which pubsub drops the messages of the
+// bridge like for any slow subscriber.
+// By default each message is posted at most once; use WithWebhookDeliveryMode to retry failed posts.
+// The messages that are not delivered are passed to the drop handler, if any.
+func NewWebhookBridge(ps *PubSub, endpoint string, topics []string, opts ...WebhookBridgeOpt) (*WebhookBridge, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook endpoint: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid webhook endpoint: unsupported scheme %q", u.Scheme)
+	}
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("no topics to bridge")
+	}
+
+	ctx, cancel := context.WithCancel(ps.ctx)
+	b := &WebhookBridge{
+		logger:          ps.logger,
+		ctx:             ctx,
+		cancel:          cancel,
+		endpoint:        endpoint,
+		client:          http.DefaultClient,
+		mode:            WebhookAtMostOnce,
+		concurrency:     WebhookBridgeConcurrency,
+		maxRetries:      WebhookBridgeMaxRetries,
+		retryBackoff:    WebhookBridgeRetryBackoff,
+		maxRetryBackoff: WebhookBridgeMaxRetryBackoff,
+	}
+
+	for _, opt := range opts {
+		err := opt(b)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	b.inflight = make(chan struct{}, b.concurrency)
+
+	for _, topic := range topics {
+		sub, err := ps.Subscribe(topic, b.subOpts...)
+		if err != nil {
+			for _, sub := range b.subs {
+				sub.Cancel()
+			}
+			cancel()
+			return nil, err
+		}
+		b.subs = append(b.subs, sub)
+	}
+
+	for _, sub := range b.subs {
+		b.wg.Add(1)
+		go b.readLoop(sub)
+	}
+
+	return b, nil
+}
+
+// WithWebhookDeliveryMode sets the delivery guarantee of the bridge; the default is
+// WebhookAtMostOnce.
+func WithWebhookDeliveryMode(mode WebhookDeliveryMode) WebhookBridgeOpt {
+	return func(b *WebhookBridge) error {
+		switch mode {
+		case WebhookAtMostOnce, WebhookAtLeastOnce:
+			b.mode = mode
+			return nil
+		default:
+			return fmt.Errorf("unknown webhook delivery mode: %d", mode)
+		}
+	}
+}
+
+// WithWebhookConcurrency bounds the number of messages posted concurrently; the default is
+// WebhookBridgeConcurrency.
+func WithWebhookConcurrency(n int) WebhookBridgeOpt {
+	return func(b *WebhookBridge) error {
+		if n <= 0 {
+			return fmt.Errorf("webhook concurrency must be positive")
+		}
+		b.concurrency = n
+		return nil
+	}
+}
+
+// WithWebhookRetry sets the number of retries for the post of a message with WebhookAtLeastOnce,
+// and the initial backoff between retries.
+func WithWebhookRetry(maxRetries int, backoff time.Duration) WebhookBridgeOpt {
+	return func(b *WebhookBridge) error {
+		if maxRetries < 0 {
+			return fmt.Errorf("number of retries must be >= 0")
+		}
+		if backoff <= 0 {
+			return fmt.Errorf("retry backoff must be positive")
+		}
+		b.maxRetries = maxRetries
+		b.retryBackoff = backoff
+		return nil
+	}
+}
+
+// WithWebhookDropHandler sets a function invoked with the messages that could not be delivered,
+// and the error of their last post. It is invoked concurrently by the posting goroutines.
+func WithWebhookDropHandler(drop func(*Message, error)) WebhookBridgeOpt {
+	return func(b *WebhookBridge) error {
+		b.drop = drop
+		return nil
+	}
+}
+
+// WithWebhookClient sets the http client used to post the messages; the default is
+// http.DefaultClient.
+func WithWebhookClient(client *http.Client) WebhookBridgeOpt {
+	return func(b *WebhookBridge) error {
+		if client == nil {
+			return fmt.Errorf("nil http client")
+		}
+		b.client = client
+		return nil
+	}
+}
+
+// WithWebhookSubOpts sets the options of the subscriptions of the bridge, for instance
+// WithBufferSize to buffer more messages while the endpoint is slow.
+func WithWebhookSubOpts(opts ...SubOpt) WebhookBridgeOpt {
+	return func(b *WebhookBridge) error {
+		b.subOpts = append(b.subOpts, opts...)
+		return nil
+	}
+}
+
+// Close cancels the subscriptions of the bridge and aborts the posts in flight, waiting for them
+// to return; the aborted messages are passed to the drop handler.
+func (b *WebhookBridge) Close() {
+	b.cancel()
+	for _, sub := range b.subs {
+		sub.Cancel()
+	}
+	b.wg.Wait()
+}
+
+func (b *WebhookBridge) readLoop(sub *Subscription) {
+	defer b.wg.Done()
+
+	for {
+		msg, err := sub.Next(b.ctx)
+		if err != nil {
+			return
+		}
+
+		select {
+		case b.inflight <- struct{}{}:
+		case <-b.ctx.Done():
+			b.dropMessage(msg, b.ctx.Err())
+			return
+		}
+
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.deliver(msg)
+			<-b.inflight
+		}()
+	}
+}
+
+func (b *WebhookBridge) deliver(msg *Message) {
+	env := &WebhookEnvelope{
+		Topic:      msg.GetTopic(),
+		Seqno:      msg.GetSeqno(),
+		Data:       msg.GetData(),
+		ID:         []byte(msg.ID),
+		ReceivedAt: msg.arrived,
+	}
+	// the author is omitted from unsigned messages
+	if len(msg.Message.GetFrom()) > 0 {
+		env.From = msg.GetFrom().String()
+	}
+	body, err := json.Marshal(env)
+	if err != nil {
+		b.dropMessage(msg, err)
+		return
+	}
+
+	backoff := b.retryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := b.doPost(body)
+		if err == nil {
+			return
+		}
+
+		if !retry || b.mode == WebhookAtMostOnce || attempt >= b.maxRetries {
+			b.dropMessage(msg, err)
+			return
+		}
+
+		b.logger.Debugw("error posting message to webhook; retrying", "topic", msg.GetTopic(), "msgid", msg.ID, "backoff", backoff, "err", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-b.ctx.Done():
+			b.dropMessage(msg, b.ctx.Err())
+			return
+		}
+
+		backoff *= 2
+		if backoff > b.maxRetryBackoff {
+			backoff = b.maxRetryBackoff
+		}
+	}
+}
+
+// doPost posts a single message; it returns whether the request should be retried on error.
+func (b *WebhookBridge) doPost(body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(b.ctx, http.MethodPost, b.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return b.ctx.Err() == nil, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("webhook endpoint returned %s", resp.Status)
+	case resp.StatusCode >= 300:
+		return false, fmt.Errorf("webhook endpoint returned %s", resp.Status)
+	}
+
+	return false, nil
+}
+
+func (b *WebhookBridge) dropMessage(msg *Message, err error) {
+	b.logger.Debugw("dropping message for webhook", "topic", msg.GetTopic(), "msgid", msg.ID, "err", err)
+	if b.drop != nil {
+		b.drop(msg, err)
+	}
+}
@@ -0,0 +1,288 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockWebhook is a webhook endpoint that fails the first attempts to post each message.
+type mockWebhook struct {
+	mx       sync.Mutex
+	failures int
+	attempts map[string]int
+	received []WebhookEnvelope
+}
+
+func (mw *mockWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var env WebhookEnvelope
+	if r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&env) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	mw.mx.Lock()
+	defer mw.mx.Unlock()
+
+	mw.attempts[string(env.ID)]++
+	if mw.attempts[string(env.ID)] <= mw.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	mw.received = append(mw.received, env)
+}
+
+func (mw *mockWebhook) delivered() []WebhookEnvelope {
+	mw.mx.Lock()
+	defer mw.mx.Unlock()
+	return append([]WebhookEnvelope(nil), mw.received...)
+}
+
+// collectDrops returns a drop handler collecting the dropped messages.
+func collectDrops() (func(*Message, error), func() []*Message) {
+	var mx sync.Mutex
+	var dropped []*Message
+	return func(msg *Message, err error) {
+			mx.Lock()
+			defer mx.Unlock()
+			dropped = append(dropped, msg)
+		}, func() []*Message {
+			mx.Lock()
+			defer mx.Unlock()
+			return append([]*Message(nil), dropped...)
+		}
+}
+
+func TestWebhookBridgeAtLeastOnce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0])
+
+	mw := &mockWebhook{failures: 2, attempts: make(map[string]int)}
+	srv := httptest.NewServer(mw)
+	defer srv.Close()
+
+	drop, dropped := collectDrops()
+	bridge, err := NewWebhookBridge(ps, srv.URL, []string{"foo", "bar"},
+		WithWebhookDeliveryMode(WebhookAtLeastOnce),
+		WithWebhookRetry(3, time.Millisecond),
+		WithWebhookDropHandler(drop),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bridge.Close()
+
+	for i := 0; i < 5; i++ {
+		for _, topic := range []string{"foo", "bar"} {
+			if err := ps.Publish(topic, []byte(fmt.Sprintf("%s %d", topic, i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// every message is delivered, after failing twice
+	waitFor(t, "the messages to be delivered", func() bool { return len(mw.delivered()) == 10 })
+
+	seen := make(map[string]bool)
+	for _, env := range mw.delivered() {
+		if seen[string(env.Data)] {
+			t.Fatalf("message %q delivered twice", env.Data)
+		}
+		seen[string(env.Data)] = true
+		if env.From != hosts[0].ID().String() || len(env.Seqno) == 0 || len(env.ID) == 0 || env.ReceivedAt.IsZero() {
+			t.Fatalf("unexpected envelope %+v", env)
+		}
+		var topic string
+		var i int
+		if _, err := fmt.Sscanf(string(env.Data), "%s %d", &topic, &i); err != nil || topic != env.Topic {
+			t.Fatalf("unexpected topic %q for message %q", env.Topic, env.Data)
+		}
+	}
+	if d := dropped(); len(d) != 0 {
+		t.Fatalf("expected no dropped messages, got %d", len(d))
+	}
+}
+
+func TestWebhookBridgeAtMostOnce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0])
+
+	mw := &mockWebhook{failures: 1, attempts: make(map[string]int)}
+	srv := httptest.NewServer(mw)
+	defer srv.Close()
+
+	drop, dropped := collectDrops()
+	bridge, err := NewWebhookBridge(ps, srv.URL, []string{"foo"}, WithWebhookDropHandler(drop))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bridge.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := ps.Publish("foo", []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the failed posts are not retried, and the messages are dropped
+	waitFor(t, "the messages to be dropped", func() bool { return len(dropped()) == 5 })
+	if r := mw.delivered(); len(r) != 0 {
+		t.Fatalf("expected no delivered messages, got %d", len(r))
+	}
+	mw.mx.Lock()
+	defer mw.mx.Unlock()
+	for id, n := range mw.attempts {
+		if n != 1 {
+			t.Fatalf("expected message %s to be posted once, got %d", id, n)
+		}
+	}
+}
+
+func TestWebhookBridgeRetriesExhausted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0])
+
+	mw := &mockWebhook{failures: 10, attempts: make(map[string]int)}
+	srv := httptest.NewServer(mw)
+	defer srv.Close()
+
+	drop, dropped := collectDrops()
+	bridge, err := NewWebhookBridge(ps, srv.URL, []string{"foo"},
+		WithWebhookDeliveryMode(WebhookAtLeastOnce),
+		WithWebhookRetry(2, time.Millisecond),
+		WithWebhookDropHandler(drop),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bridge.Close()
+
+	if err := ps.Publish("foo", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the message to be dropped", func() bool { return len(dropped()) == 1 })
+	if d := dropped(); string(d[0].GetData()) != "hello" {
+		t.Fatalf("unexpected dropped message %q", d[0].GetData())
+	}
+	mw.mx.Lock()
+	defer mw.mx.Unlock()
+	for _, n := range mw.attempts {
+		if n != 3 {
+			t.Fatalf("expected 3 attempts, got %d", n)
+		}
+	}
+}
+
+func TestWebhookBridgeBackpressure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0])
+
+	// a slow endpoint, which holds the requests until released
+	var mx sync.Mutex
+	var inflight, maxInflight, delivered int
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mx.Lock()
+		inflight++
+		if inflight > maxInflight {
+			maxInflight = inflight
+		}
+		mx.Unlock()
+
+		<-release
+
+		mx.Lock()
+		inflight--
+		delivered++
+		mx.Unlock()
+	}))
+	defer srv.Close()
+
+	drop, dropped := collectDrops()
+	bridge, err := NewWebhookBridge(ps, srv.URL, []string{"foo"},
+		WithWebhookConcurrency(2),
+		WithWebhookSubOpts(WithBufferSize(4)),
+		WithWebhookDropHandler(drop),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the bridge holds the posts in flight, the message waiting for a post and the subscription
+	// buffer; the rest is dropped by pubsub, like for any slow subscriber
+	for i := 0; i < 20; i++ {
+		if err := ps.Publish("foo", []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "the posts to be in flight", func() bool {
+		mx.Lock()
+		defer mx.Unlock()
+		return inflight == 2
+	})
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	waitFor(t, "the buffered messages to be delivered", func() bool {
+		mx.Lock()
+		defer mx.Unlock()
+		return delivered >= 4
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	mx.Lock()
+	if maxInflight != 2 {
+		t.Fatalf("expected at most 2 posts in flight, got %d", maxInflight)
+	}
+	if delivered > 7 {
+		t.Fatalf("expected the slow endpoint to miss messages, got %d", delivered)
+	}
+	mx.Unlock()
+
+	bridge.Close()
+	if d := dropped(); len(d) != 0 {
+		t.Fatalf("expected no dropped messages, got %d", len(d))
+	}
+}
+
+func TestWebhookBridgeErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getNetHosts(t, ctx, 1)
+	ps := getPubsub(ctx, hosts[0])
+
+	for _, tc := range []struct {
+		endpoint string
+		topics   []string
+		opts     []WebhookBridgeOpt
+	}{
+		{"ftp://example.com", []string{"foo"}, nil},
+		{"http://example.com", nil, nil},
+		{"http://example.com", []string{"foo"}, []WebhookBridgeOpt{WithWebhookConcurrency(0)}},
+		{"http://example.com", []string{"foo"}, []WebhookBridgeOpt{WithWebhookRetry(-1, time.Second)}},
+		{"http://example.com", []string{"foo"}, []WebhookBridgeOpt{WithWebhookDeliveryMode(WebhookDeliveryMode(42))}},
+	} {
+		if _, err := NewWebhookBridge(ps, tc.endpoint, tc.topics, tc.opts...); err == nil {
+			t.Fatalf("expected an error for %s %v", tc.endpoint, tc.topics)
+		}
+	}
+}